@@ -43,6 +43,12 @@ const (
 	EmojiReact ActivityType = "EmojiReact"
 	Add        ActivityType = "Add"
 	Remove     ActivityType = "Remove"
+	Block      ActivityType = "Block"
+	Listen     ActivityType = "Listen"
+	Read       ActivityType = "Read"
+
+	TentativeAccept ActivityType = "TentativeAccept"
+	Reject          ActivityType = "Reject"
 )
 
 type anyActivity struct {
@@ -87,6 +93,12 @@ var (
 		EmojiReact: {},
 		Add:        {},
 		Remove:     {},
+		Block:      {},
+		Listen:     {},
+		Read:       {},
+
+		TentativeAccept: {},
+		Reject:          {},
 	}
 )
 
@@ -54,6 +54,35 @@ type Actor struct {
 	Attachment                []Attachment      `json:"attachment,omitempty"`
 }
 
+// UnmarshalJSON decodes an actor, including actors with multiple header images, like PeerTube channels.
+func (a *Actor) UnmarshalJSON(b []byte) error {
+	type actor Actor
+	var tmp struct {
+		actor
+		Image json.RawMessage `json:"image,omitempty"`
+	}
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+
+	*a = Actor(tmp.actor)
+
+	if len(tmp.Image) == 0 {
+		return nil
+	}
+
+	var images Array[Attachment]
+	if err := json.Unmarshal(tmp.Image, &images); err != nil {
+		return err
+	}
+
+	if len(images) > 0 {
+		a.Image = &images[0]
+	}
+
+	return nil
+}
+
 func (a *Actor) Scan(src any) error {
 	s, ok := src.(string)
 	if !ok {
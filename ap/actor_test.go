@@ -0,0 +1,43 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActorUnmarshal_Image(t *testing.T) {
+	var a Actor
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/users/alice","type":"Person","image":{"type":"Image","mediaType":"image/png","url":"https://example.com/header.png"}}`), &a))
+	assert.Equal(t, &Attachment{Type: Image, MediaType: "image/png", URL: "https://example.com/header.png"}, a.Image)
+}
+
+func TestActorUnmarshal_Images(t *testing.T) {
+	var a Actor
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/video-channels/cats","type":"Group","image":[{"type":"Image","mediaType":"image/jpeg","url":"https://example.com/banner-1920.jpg"},{"type":"Image","mediaType":"image/jpeg","url":"https://example.com/banner-600.jpg"}]}`), &a))
+	assert.Equal(t, Group, a.Type)
+	assert.Equal(t, &Attachment{Type: Image, MediaType: "image/jpeg", URL: "https://example.com/banner-1920.jpg"}, a.Image)
+}
+
+func TestActorUnmarshal_NoImage(t *testing.T) {
+	var a Actor
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/users/alice","type":"Person","image":null}`), &a))
+	assert.Nil(t, a.Image)
+}
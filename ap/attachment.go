@@ -16,18 +16,72 @@ limitations under the License.
 
 package ap
 
+import "encoding/json"
+
 type AttachmentType string
 
 const (
 	Image         AttachmentType = "Image"
+	Audio         AttachmentType = "Audio"
+	Video         AttachmentType = "Video"
+	Document      AttachmentType = "Document"
 	PropertyValue AttachmentType = "PropertyValue"
 )
 
 type Attachment struct {
-	Type      AttachmentType `json:"type,omitempty"`
-	MediaType string         `json:"mediaType,omitempty"`
-	URL       string         `json:"url,omitempty"`
-	Href      string         `json:"href,omitempty"`
-	Name      string         `json:"name,omitempty"`
-	Value     string         `json:"value,omitempty"`
+	Type       AttachmentType `json:"type,omitempty"`
+	MediaType  string         `json:"mediaType,omitempty"`
+	URL        string         `json:"url,omitempty"`
+	Href       string         `json:"href,omitempty"`
+	Name       string         `json:"name,omitempty"`
+	Value      string         `json:"value,omitempty"`
+	VerifiedAt *Time          `json:"verifiedAt,omitempty"`
+	Duration   string         `json:"duration,omitempty"`
+}
+
+type attachmentLink struct {
+	MediaType string `json:"mediaType"`
+	Href      string `json:"href"`
+}
+
+// UnmarshalJSON decodes an attachment with a URL or multiple links to the same media in different formats.
+func (a *Attachment) UnmarshalJSON(b []byte) error {
+	type attachment Attachment
+	var tmp struct {
+		attachment
+		URL json.RawMessage `json:"url,omitempty"`
+	}
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+
+	*a = Attachment(tmp.attachment)
+
+	if len(tmp.URL) == 0 || json.Unmarshal(tmp.URL, &a.URL) == nil {
+		return nil
+	}
+
+	var links Array[attachmentLink]
+	if err := json.Unmarshal(tmp.URL, &links); err != nil {
+		return err
+	}
+
+	// prefer a link with the attachment's media type
+	for _, link := range links {
+		if link.Href == "" {
+			continue
+		}
+
+		if a.MediaType == "" || link.MediaType == a.MediaType {
+			a.URL = link.Href
+			a.MediaType = link.MediaType
+			break
+		}
+
+		if a.URL == "" {
+			a.URL = link.Href
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,54 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentUnmarshal_URL(t *testing.T) {
+	var a Attachment
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Document","mediaType":"audio/mpeg","url":"https://example.com/a.mp3","duration":"PT1M30S"}`), &a))
+	assert.Equal(t, Attachment{Type: Document, MediaType: "audio/mpeg", URL: "https://example.com/a.mp3", Duration: "PT1M30S"}, a)
+}
+
+func TestAttachmentUnmarshal_Links(t *testing.T) {
+	var a Attachment
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Video","mediaType":"video/mp4","url":[{"type":"Link","mediaType":"text/html","href":"https://example.com/w/1"},{"type":"Link","mediaType":"video/mp4","href":"https://example.com/1.mp4"}]}`), &a))
+	assert.Equal(t, Attachment{Type: Video, MediaType: "video/mp4", URL: "https://example.com/1.mp4"}, a)
+}
+
+func TestAttachmentUnmarshal_LinksNoMediaType(t *testing.T) {
+	var a Attachment
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Video","url":[{"type":"Link","mediaType":"video/webm","href":"https://example.com/1.webm"},{"type":"Link","mediaType":"video/mp4","href":"https://example.com/1.mp4"}]}`), &a))
+	assert.Equal(t, Attachment{Type: Video, MediaType: "video/webm", URL: "https://example.com/1.webm"}, a)
+}
+
+func TestAttachmentUnmarshal_Link(t *testing.T) {
+	var a Attachment
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Audio","mediaType":"audio/ogg","url":{"type":"Link","mediaType":"text/html","href":"https://example.com/a"}}`), &a))
+	assert.Equal(t, Attachment{Type: Audio, MediaType: "audio/ogg", URL: "https://example.com/a"}, a)
+}
+
+func TestObjectUnmarshal_SingleAttachment(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/1","type":"Note","attachment":{"type":"Document","mediaType":"video/mp4","url":"https://example.com/1.mp4"}}`), &o))
+	assert.Equal(t, Array[Attachment]{{Type: Document, MediaType: "video/mp4", URL: "https://example.com/1.mp4"}}, o.Attachment)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+import "encoding/json"
+
+// Collection is a reference to a collection, like the replies to an [Object].
+// It can be represented as the ID of the collection or as an embedded collection with an ID.
+type Collection string
+
+type embeddedCollection struct {
+	ID string `json:"id"`
+}
+
+// UnmarshalJSON decodes a Collection from a string or an embedded collection.
+// Other representations are ignored and leave the Collection empty, so they don't fail decoding of the entire object.
+func (c *Collection) UnmarshalJSON(b []byte) error {
+	var id string
+	if err := json.Unmarshal(b, &id); err == nil {
+		*c = Collection(id)
+		return nil
+	}
+
+	var collection embeddedCollection
+	if err := json.Unmarshal(b, &collection); err != nil {
+		*c = ""
+		return nil
+	}
+
+	*c = Collection(collection.ID)
+	return nil
+}
+
+// CountedCollection is a reference to a collection, like the likes or shares of an [Object], and the number of items in
+// it. It can be represented as the ID of the collection or as an embedded collection with an ID and totalItems.
+type CountedCollection struct {
+	ID         string `json:"id"`
+	TotalItems *int64 `json:"totalItems,omitempty"`
+}
+
+// UnmarshalJSON decodes a CountedCollection from a string or an embedded collection.
+// Other representations are ignored and leave the CountedCollection empty, so they don't fail decoding of the entire
+// object.
+func (c *CountedCollection) UnmarshalJSON(b []byte) error {
+	var id string
+	if err := json.Unmarshal(b, &id); err == nil {
+		*c = CountedCollection{ID: id}
+		return nil
+	}
+
+	type countedCollection CountedCollection
+	var collection countedCollection
+	if err := json.Unmarshal(b, &collection); err != nil {
+		*c = CountedCollection{}
+		return nil
+	}
+
+	*c = CountedCollection(collection)
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionUnmarshal_String(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/1","type":"Note","replies":"https://example.com/1/replies"}`), &o))
+	assert.Equal(t, Collection("https://example.com/1/replies"), o.Replies)
+}
+
+func TestCollectionUnmarshal_Embedded(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/1","type":"Note","replies":{"id":"https://example.com/1/replies","type":"Collection","first":{"type":"CollectionPage","next":"https://example.com/1/replies?page=true","items":[]}}}`), &o))
+	assert.Equal(t, Collection("https://example.com/1/replies"), o.Replies)
+}
+
+func TestCollectionUnmarshal_Unknown(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/1","type":"Note","replies":["https://example.com/1/replies"]}`), &o))
+	assert.Equal(t, "https://example.com/1", o.ID)
+	assert.Empty(t, o.Replies)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/1","type":"Note","replies":{"id":1}}`), &o))
+	assert.Empty(t, o.Replies)
+}
+
+func TestCollectionMarshal(t *testing.T) {
+	buf, err := json.Marshal(&Object{ID: "https://example.com/1", Type: Note, Replies: "https://example.com/replies/1"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf), `"replies":"https://example.com/replies/1"`)
+
+	buf, err = json.Marshal(&Object{ID: "https://example.com/1", Type: Note})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(buf), `"replies"`)
+}
+
+func TestCountedCollectionUnmarshal_String(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/1","type":"Note","likes":"https://example.com/1/likes"}`), &o))
+	assert.Equal(t, &CountedCollection{ID: "https://example.com/1/likes"}, o.Likes)
+	assert.Nil(t, o.Shares)
+}
+
+func TestCountedCollectionUnmarshal_Embedded(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/1","type":"Note","likes":{"id":"https://example.com/1/likes","type":"Collection","totalItems":34},"shares":{"id":"https://example.com/1/shares","type":"Collection","totalItems":12}}`), &o))
+	assert.Equal(t, "https://example.com/1/likes", o.Likes.ID)
+	assert.Equal(t, int64(34), *o.Likes.TotalItems)
+	assert.Equal(t, "https://example.com/1/shares", o.Shares.ID)
+	assert.Equal(t, int64(12), *o.Shares.TotalItems)
+}
+
+func TestCountedCollectionUnmarshal_Unknown(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"https://example.com/1","type":"Note","likes":[],"shares":{"id":1}}`), &o))
+	assert.Equal(t, "https://example.com/1", o.ID)
+	assert.Empty(t, o.Likes.ID)
+	assert.Nil(t, o.Likes.TotalItems)
+	assert.Empty(t, o.Shares.ID)
+}
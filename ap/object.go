@@ -20,6 +20,8 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type ObjectType string
@@ -29,28 +31,37 @@ const (
 	Page     ObjectType = "Page"
 	Article  ObjectType = "Article"
 	Question ObjectType = "Question"
+	Event    ObjectType = "Event"
+
+	// media objects, which share type names with the equivalent attachments
+	AudioObject ObjectType = "Audio"
+	VideoObject ObjectType = "Video"
 )
 
 // Object represents most ActivityPub objects.
 // Actors are represented by [Actor].
 type Object struct {
-	Context      any          `json:"@context,omitempty"`
-	ID           string       `json:"id"`
-	Type         ObjectType   `json:"type"`
-	AttributedTo string       `json:"attributedTo,omitempty"`
-	InReplyTo    string       `json:"inReplyTo,omitempty"`
-	Content      string       `json:"content,omitempty"`
-	Summary      string       `json:"summary,omitempty"`
-	Sensitive    bool         `json:"sensitive,omitempty"`
-	Name         string       `json:"name,omitempty"`
-	Published    Time         `json:"published"`
-	Updated      *Time        `json:"updated,omitempty"`
-	To           Audience     `json:"to,omitempty"`
-	CC           Audience     `json:"cc,omitempty"`
-	Audience     string       `json:"audience,omitempty"`
-	Tag          Array[Tag]   `json:"tag,omitempty"`
-	Attachment   []Attachment `json:"attachment,omitempty"`
-	URL          string       `json:"url,omitempty"`
+	Context      any                `json:"@context,omitempty"`
+	ID           string             `json:"id"`
+	Type         ObjectType         `json:"type"`
+	AttributedTo string             `json:"attributedTo,omitempty"`
+	InReplyTo    string             `json:"inReplyTo,omitempty"`
+	Content      string             `json:"content,omitempty"`
+	ContentMap   map[string]string  `json:"contentMap,omitempty"`
+	Summary      string             `json:"summary,omitempty"`
+	Sensitive    bool               `json:"sensitive,omitempty"`
+	Name         string             `json:"name,omitempty"`
+	Published    Time               `json:"published"`
+	Updated      *Time              `json:"updated,omitempty"`
+	To           Audience           `json:"to,omitempty"`
+	CC           Audience           `json:"cc,omitempty"`
+	Audience     string             `json:"audience,omitempty"`
+	Tag          Array[Tag]         `json:"tag,omitempty"`
+	Attachment   Array[Attachment]  `json:"attachment,omitempty"`
+	URL          string             `json:"url,omitempty"`
+	Replies      Collection         `json:"replies,omitempty"`
+	Likes        *CountedCollection `json:"likes,omitempty"`
+	Shares       *CountedCollection `json:"shares,omitempty"`
 
 	// polls
 	VotersCount int64        `json:"votersCount,omitempty"`
@@ -58,6 +69,169 @@ type Object struct {
 	AnyOf       []PollOption `json:"anyOf,omitempty"`
 	EndTime     *Time        `json:"endTime,omitempty"`
 	Closed      *Time        `json:"closed,omitempty"`
+
+	// events, which use EndTime too
+	StartTime *Time  `json:"startTime,omitempty"`
+	Location  *Place `json:"location,omitempty"`
+
+	// audio and video
+	Duration string `json:"duration,omitempty"`
+	License  string `json:"license,omitempty"`
+
+	InteractionPolicy *InteractionPolicy `json:"interactionPolicy,omitempty"`
+}
+
+type objectAuthor struct {
+	Type ActorType `json:"type"`
+	ID   string    `json:"id"`
+}
+
+type objectLicense struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// UnmarshalJSON decodes an object, including media objects with multiple authors, multiple links, a numeric duration
+// or a license object.
+func (o *Object) UnmarshalJSON(b []byte) error {
+	type object Object
+	var tmp struct {
+		object
+		AttributedTo json.RawMessage `json:"attributedTo,omitempty"`
+		URL          json.RawMessage `json:"url,omitempty"`
+		Duration     json.RawMessage `json:"duration,omitempty"`
+		License      json.RawMessage `json:"license,omitempty"`
+		Licence      json.RawMessage `json:"licence,omitempty"`
+	}
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+
+	*o = Object(tmp.object)
+
+	if err := o.unmarshalAuthor(tmp.AttributedTo); err != nil {
+		return err
+	}
+
+	if len(tmp.Duration) > 0 && json.Unmarshal(tmp.Duration, &o.Duration) != nil {
+		// Funkwhale specifies the duration in seconds
+		var sec float64
+		if err := json.Unmarshal(tmp.Duration, &sec); err != nil {
+			return err
+		}
+		o.Duration = "PT" + strconv.FormatFloat(sec, 'f', -1, 64) + "S"
+	}
+
+	if err := o.unmarshalURL(tmp.URL); err != nil {
+		return err
+	}
+
+	// PeerTube uses British spelling
+	license := tmp.License
+	if len(license) == 0 {
+		license = tmp.Licence
+	}
+	if len(license) > 0 && json.Unmarshal(license, &o.License) != nil {
+		var obj objectLicense
+		if err := json.Unmarshal(license, &obj); err != nil {
+			return err
+		}
+
+		o.License = obj.Name
+		if o.License == "" {
+			o.License = obj.URL
+		}
+	}
+
+	return nil
+}
+
+// unmarshalAuthor decodes the author of an object: if a PeerTube video is attributed to an account and a channel, the
+// video belongs to the channel and appears in the feed of users who follow it.
+func (o *Object) unmarshalAuthor(raw json.RawMessage) error {
+	if len(raw) == 0 || json.Unmarshal(raw, &o.AttributedTo) == nil {
+		return nil
+	}
+
+	var authors Array[json.RawMessage]
+	if err := json.Unmarshal(raw, &authors); err != nil {
+		return err
+	}
+
+	for _, author := range authors {
+		var id string
+		if json.Unmarshal(author, &id) == nil {
+			if o.AttributedTo == "" {
+				o.AttributedTo = id
+			}
+			continue
+		}
+
+		var actor objectAuthor
+		if err := json.Unmarshal(author, &actor); err != nil {
+			return err
+		}
+
+		if actor.Type == Group {
+			o.AttributedTo = actor.ID
+			break
+		}
+
+		if o.AttributedTo == "" {
+			o.AttributedTo = actor.ID
+		}
+	}
+
+	return nil
+}
+
+// unmarshalURL decodes the URL of an object and adds an attachment for the stream of an audio or video object.
+func (o *Object) unmarshalURL(raw json.RawMessage) error {
+	if len(raw) == 0 || json.Unmarshal(raw, &o.URL) == nil {
+		return nil
+	}
+
+	var links Array[attachmentLink]
+	if err := json.Unmarshal(raw, &links); err != nil {
+		return err
+	}
+
+	var stream *attachmentLink
+	for i, link := range links {
+		if link.Href == "" {
+			continue
+		}
+
+		if o.URL == "" && (link.MediaType == "" || link.MediaType == "text/html") {
+			o.URL = link.Href
+		} else if stream == nil && ((o.Type == AudioObject && strings.HasPrefix(link.MediaType, "audio/")) || (o.Type == VideoObject && strings.HasPrefix(link.MediaType, "video/"))) {
+			stream = &links[i]
+		}
+	}
+
+	if stream == nil {
+		return nil
+	}
+
+	for _, attachment := range o.Attachment {
+		if attachment.URL == stream.Href {
+			return nil
+		}
+	}
+
+	attachmentType := Audio
+	if o.Type == VideoObject {
+		attachmentType = Video
+	}
+
+	o.Attachment = append(o.Attachment, Attachment{
+		Type:      attachmentType,
+		MediaType: stream.MediaType,
+		URL:       stream.Href,
+		Duration:  o.Duration,
+	})
+
+	return nil
 }
 
 func (o *Object) IsPublic() bool {
@@ -0,0 +1,81 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectUnmarshal_PeerTubeVideo(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Video","id":"https://example.com/videos/watch/1","name":"Cats","duration":"PT83S","licence":{"identifier":"1","name":"Attribution"},"attributedTo":[{"type":"Person","id":"https://example.com/accounts/alice"},{"type":"Group","id":"https://example.com/video-channels/cats"}],"url":[{"type":"Link","mediaType":"text/html","href":"https://example.com/w/1"},{"type":"Link","mediaType":"application/x-mpegURL","href":"https://example.com/1.m3u8"},{"type":"Link","mediaType":"video/mp4","href":"https://example.com/1-720.mp4","height":720},{"type":"Link","mediaType":"video/mp4","href":"https://example.com/1-480.mp4","height":480}]}`), &o))
+	assert.Equal(t, VideoObject, o.Type)
+	assert.Equal(t, "https://example.com/video-channels/cats", o.AttributedTo)
+	assert.Equal(t, "https://example.com/w/1", o.URL)
+	assert.Equal(t, "PT83S", o.Duration)
+	assert.Equal(t, "Attribution", o.License)
+	assert.Equal(t, Array[Attachment]{{Type: Video, MediaType: "video/mp4", URL: "https://example.com/1-720.mp4", Duration: "PT83S"}}, o.Attachment)
+}
+
+func TestObjectUnmarshal_FunkwhaleAudio(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Audio","id":"https://example.com/federation/music/uploads/1","name":"Song","duration":254,"license":"http://creativecommons.org/licenses/by/4.0/","attributedTo":"https://example.com/federation/actors/band","url":[{"type":"Link","mediaType":"text/html","href":"https://example.com/library/tracks/1"},{"type":"Link","mediaType":"audio/ogg","href":"https://example.com/1.ogg"}]}`), &o))
+	assert.Equal(t, AudioObject, o.Type)
+	assert.Equal(t, "https://example.com/federation/actors/band", o.AttributedTo)
+	assert.Equal(t, "https://example.com/library/tracks/1", o.URL)
+	assert.Equal(t, "PT254S", o.Duration)
+	assert.Equal(t, "http://creativecommons.org/licenses/by/4.0/", o.License)
+	assert.Equal(t, Array[Attachment]{{Type: Audio, MediaType: "audio/ogg", URL: "https://example.com/1.ogg", Duration: "PT254S"}}, o.Attachment)
+}
+
+func TestObjectUnmarshal_StreamAlreadyAttached(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Audio","id":"https://example.com/1","attachment":[{"type":"Audio","mediaType":"audio/ogg","url":"https://example.com/1.ogg"}],"url":{"type":"Link","mediaType":"audio/ogg","href":"https://example.com/1.ogg"}}`), &o))
+	assert.Equal(t, "", o.URL)
+	assert.Equal(t, Array[Attachment]{{Type: Audio, MediaType: "audio/ogg", URL: "https://example.com/1.ogg"}}, o.Attachment)
+}
+
+func TestObjectUnmarshal_LinksInNote(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Note","id":"https://example.com/1","url":[{"type":"Link","href":"https://example.com/@alice/1"},{"type":"Link","mediaType":"video/mp4","href":"https://example.com/1.mp4"}]}`), &o))
+	assert.Equal(t, "https://example.com/@alice/1", o.URL)
+	assert.Empty(t, o.Attachment)
+}
+
+func TestObjectUnmarshal_AuthorIDs(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Note","id":"https://example.com/1","attributedTo":["https://example.com/users/alice","https://example.com/users/bob"]}`), &o))
+	assert.Equal(t, "https://example.com/users/alice", o.AttributedTo)
+}
+
+func TestObjectUnmarshal_Roundtrip(t *testing.T) {
+	var o Object
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Video","id":"https://example.com/1","duration":"PT1M","licence":{"name":"Attribution"},"url":[{"type":"Link","mediaType":"text/html","href":"https://example.com/w/1"},{"type":"Link","mediaType":"video/mp4","href":"https://example.com/1.mp4"}]}`), &o))
+
+	j, err := json.Marshal(o)
+	assert.NoError(t, err)
+
+	var again Object
+	assert.NoError(t, json.Unmarshal(j, &again))
+	assert.Equal(t, o.URL, again.URL)
+	assert.Equal(t, o.Duration, again.Duration)
+	assert.Equal(t, o.License, again.License)
+	assert.Equal(t, o.Attachment, again.Attachment)
+}
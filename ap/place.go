@@ -0,0 +1,78 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Place represents the location of an [Event].
+type Place struct {
+	Type    string `json:"type,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+type postalAddress struct {
+	StreetAddress   string `json:"streetAddress"`
+	PostalCode      string `json:"postalCode"`
+	AddressLocality string `json:"addressLocality"`
+	AddressRegion   string `json:"addressRegion"`
+	AddressCountry  string `json:"addressCountry"`
+}
+
+type anyPlace struct {
+	Type    string          `json:"type"`
+	Name    string          `json:"name"`
+	Address json.RawMessage `json:"address"`
+}
+
+// UnmarshalJSON decodes a Place, where address can be a string or a PostalAddress.
+func (p *Place) UnmarshalJSON(b []byte) error {
+	var place anyPlace
+	if err := json.Unmarshal(b, &place); err != nil {
+		return err
+	}
+
+	p.Type = place.Type
+	p.Name = place.Name
+	p.Address = ""
+
+	if len(place.Address) == 0 || string(place.Address) == "null" {
+		return nil
+	}
+
+	if err := json.Unmarshal(place.Address, &p.Address); err == nil {
+		return nil
+	}
+
+	var address postalAddress
+	if err := json.Unmarshal(place.Address, &address); err != nil {
+		return err
+	}
+
+	parts := make([]string, 0, 5)
+	for _, part := range []string{address.StreetAddress, address.PostalCode, address.AddressLocality, address.AddressRegion, address.AddressCountry} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	p.Address = strings.Join(parts, ", ")
+
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceUnmarshal_StringAddress(t *testing.T) {
+	var p Place
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Place","name":"Community center","address":"1 Main St., Springfield"}`), &p))
+	assert.Equal(t, Place{Type: "Place", Name: "Community center", Address: "1 Main St., Springfield"}, p)
+}
+
+func TestPlaceUnmarshal_PostalAddress(t *testing.T) {
+	var p Place
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Place","name":"Community center","address":{"type":"PostalAddress","streetAddress":"1 Main St.","postalCode":"12345","addressLocality":"Springfield","addressRegion":"","addressCountry":"USA"}}`), &p))
+	assert.Equal(t, Place{Type: "Place", Name: "Community center", Address: "1 Main St., 12345, Springfield, USA"}, p)
+}
+
+func TestPlaceUnmarshal_NoAddress(t *testing.T) {
+	var p Place
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"Place","name":"Online","address":null}`), &p))
+	assert.Equal(t, Place{Type: "Place", Name: "Online"}, p)
+}
+
+func TestPlaceMarshal(t *testing.T) {
+	buf, err := json.Marshal(&Object{ID: "https://example.com/1", Type: Event, Location: &Place{Type: "Place", Name: "Community center", Address: "1 Main St."}})
+	assert.NoError(t, err)
+
+	var o Object
+	assert.NoError(t, json.Unmarshal(buf, &o))
+	assert.Equal(t, &Place{Type: "Place", Name: "Community center", Address: "1 Main St."}, o.Location)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+// InteractionPolicy restricts interaction with an object.
+type InteractionPolicy struct {
+	CanReply *InteractionRule `json:"canReply,omitempty"`
+}
+
+// InteractionRule lists actors allowed to interact with an object: [Public], actor IDs or the followers collection of
+// the object's author.
+type InteractionRule struct {
+	Always           Audience `json:"always"`
+	ApprovalRequired Audience `json:"approvalRequired"`
+}
+
+func includes(actors Audience, author *Actor, actor string, follower bool) bool {
+	return actors.Contains(Public) || actors.Contains(actor) || (follower && author.Followers != "" && actors.Contains(author.Followers))
+}
+
+// CanReply determines if an actor can reply to o, according to its interaction policy, given whether or not the actor
+// follows the author of o. The author and mentioned actors can always reply. If approval is true, the reply needs to be
+// approved by the author.
+func (o *Object) CanReply(author *Actor, actor string, follower bool) (allowed, approval bool) {
+	if o.InteractionPolicy == nil || o.InteractionPolicy.CanReply == nil || actor == o.AttributedTo {
+		return true, false
+	}
+
+	for _, tag := range o.Tag {
+		if tag.Type == Mention && tag.Href == actor {
+			return true, false
+		}
+	}
+
+	if includes(o.InteractionPolicy.CanReply.Always, author, actor, follower) {
+		return true, false
+	}
+
+	if includes(o.InteractionPolicy.CanReply.ApprovalRequired, author, actor, follower) {
+		return true, true
+	}
+
+	return false, false
+}
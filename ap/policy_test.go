@@ -0,0 +1,74 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObject_CanReplyNoPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	var o Object
+	assert.NoError(json.Unmarshal([]byte(`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","interactionPolicy":{"canQuote":{"automaticApproval":["https://www.w3.org/ns/activitystreams#Public"]}}}`), &o))
+
+	allowed, approval := o.CanReply(&Actor{ID: "https://127.0.0.1/user/dan"}, "https://::1/user/erin", false)
+	assert.True(allowed)
+	assert.False(approval)
+}
+
+func TestObject_CanReplyFollowers(t *testing.T) {
+	assert := assert.New(t)
+
+	var o Object
+	assert.NoError(json.Unmarshal([]byte(`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","tag":[{"type":"Mention","name":"@frank@::1","href":"https://::1/user/frank"}],"interactionPolicy":{"canReply":{"always":["https://127.0.0.1/followers/dan"],"approvalRequired":[]}}}`), &o))
+
+	author := Actor{ID: "https://127.0.0.1/user/dan", Followers: "https://127.0.0.1/followers/dan"}
+
+	allowed, approval := o.CanReply(&author, "https://::1/user/erin", true)
+	assert.True(allowed)
+	assert.False(approval)
+
+	allowed, _ = o.CanReply(&author, "https://::1/user/erin", false)
+	assert.False(allowed)
+
+	allowed, _ = o.CanReply(&author, "https://::1/user/frank", false)
+	assert.True(allowed)
+
+	allowed, _ = o.CanReply(&author, "https://127.0.0.1/user/dan", false)
+	assert.True(allowed)
+}
+
+func TestObject_CanReplyApprovalRequired(t *testing.T) {
+	assert := assert.New(t)
+
+	var o Object
+	assert.NoError(json.Unmarshal([]byte(`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","interactionPolicy":{"canReply":{"always":["https://::1/user/erin"],"approvalRequired":["https://www.w3.org/ns/activitystreams#Public"]}}}`), &o))
+
+	author := Actor{ID: "https://127.0.0.1/user/dan"}
+
+	allowed, approval := o.CanReply(&author, "https://::1/user/erin", false)
+	assert.True(allowed)
+	assert.False(approval)
+
+	allowed, approval = o.CanReply(&author, "https://::1/user/frank", false)
+	assert.True(allowed)
+	assert.True(approval)
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bench generates realistic data for benchmarks and performance regression tests.
+package bench
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/migrations"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Domain is the domain of the generated instance.
+const Domain = "localhost.localdomain:8443"
+
+// Population describes the size of a generated instance.
+type Population struct {
+	LocalUsers     int
+	RemoteUsers    int
+	RemoteDomains  int
+	PostsPerUser   int
+	FollowsPerUser int
+	SharesPerUser  int
+}
+
+// Instance is a generated instance, backed by a temporary database.
+type Instance struct {
+	Config    *cfg.Config
+	DB        *sql.DB
+	Local     []*ap.Actor
+	Remote    []*ap.Actor
+	NobodyKey httpsig.Key
+
+	path  string
+	rand  *rand.Rand
+	notes []string
+}
+
+// New creates an instance with local users, remote users, posts by remote users and a follow graph.
+// The same seed always produces the same data.
+func New(ctx context.Context, p Population, seed uint64) (*Instance, error) {
+	f, err := os.CreateTemp("", "tootik-bench-*.sqlite3")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	db, err := sql.Open("sqlite3", f.Name()+"?_journal_mode=WAL")
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	i := &Instance{
+		Config: &cfg,
+		DB:     db,
+		path:   f.Name(),
+		rand:   rand.New(rand.NewPCG(seed, seed)),
+	}
+
+	if err := i.populate(ctx, p); err != nil {
+		i.Close()
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// Close deletes the instance.
+func (i *Instance) Close() {
+	i.DB.Close()
+	os.Remove(i.path)
+}
+
+func (i *Instance) populate(ctx context.Context, p Population) error {
+	if err := migrations.Run(ctx, Domain, i.DB); err != nil {
+		return err
+	}
+
+	for j := range p.LocalUsers {
+		actor, _, err := user.Create(ctx, Domain, i.Config, i.DB, fmt.Sprintf("user%d", j), ap.Person, nil)
+		if err != nil {
+			return err
+		}
+
+		i.Local = append(i.Local, actor)
+	}
+
+	var err error
+	if _, i.NobodyKey, err = user.CreateNobody(ctx, Domain, i.Config, i.DB); err != nil {
+		return err
+	}
+
+	tx, err := i.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for j := range p.RemoteUsers {
+		host := fmt.Sprintf("%d.example.org", j%max(1, p.RemoteDomains))
+		actor := ap.Actor{
+			ID:                fmt.Sprintf("https://%s/user/remote%d", host, j),
+			Type:              ap.Person,
+			PreferredUsername: fmt.Sprintf("remote%d", j),
+			Inbox:             fmt.Sprintf("https://%s/inbox/remote%d", host, j),
+			Followers:         fmt.Sprintf("https://%s/followers/remote%d", host, j),
+		}
+
+		if _, err := tx.ExecContext(ctx, `insert into persons(id, actor) values($1, $2)`, actor.ID, &actor); err != nil {
+			return err
+		}
+
+		i.Remote = append(i.Remote, &actor)
+	}
+
+	if len(i.Remote) == 0 {
+		return tx.Commit()
+	}
+
+	now := time.Now()
+
+	for _, author := range i.Remote {
+		for range p.PostsPerUser {
+			if _, err := i.insertNote(ctx, tx, author, "", now.Add(-time.Duration(i.rand.IntN(7*24*60))*time.Minute)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, follower := range i.Local {
+		for _, followed := range i.pick(p.FollowsPerUser) {
+			if _, err := tx.ExecContext(
+				ctx,
+				`insert into follows(id, follower, followed, accepted) values($1, $2, $3, 1)`,
+				fmt.Sprintf("https://%s/follow/%s/%s", Domain, follower.PreferredUsername, followed.PreferredUsername),
+				follower.ID,
+				followed.ID,
+			); err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(
+				ctx,
+				`insert into follows(id, follower, followed, accepted) values($1, $2, $3, 1)`,
+				fmt.Sprintf("%s#follow/%s", followed.ID, follower.PreferredUsername),
+				followed.ID,
+				follower.ID,
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, sharer := range i.Remote {
+		if len(i.notes) == 0 {
+			break
+		}
+
+		for range p.SharesPerUser {
+			if _, err := tx.ExecContext(
+				ctx,
+				`insert into shares(note, by, inserted) values($1, $2, $3)`,
+				i.notes[i.rand.IntN(len(i.notes))],
+				sharer.ID,
+				now.Add(-time.Duration(i.rand.IntN(7*24*60))*time.Minute).Unix(),
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// newNoteID returns a new post ID for a remote user.
+func (i *Instance) newNoteID(author *ap.Actor) string {
+	id := fmt.Sprintf("%s/note/%d", strings.TrimSuffix(author.ID, "/user/"+author.PreferredUsername), len(i.notes))
+	i.notes = append(i.notes, id)
+	return id
+}
+
+// pick returns n random remote users.
+func (i *Instance) pick(n int) []*ap.Actor {
+	n = min(n, len(i.Remote))
+	actors := make([]*ap.Actor, 0, n)
+	for _, j := range i.rand.Perm(len(i.Remote))[:n] {
+		actors = append(actors, i.Remote[j])
+	}
+	return actors
+}
+
+func (i *Instance) insertNote(ctx context.Context, tx *sql.Tx, author *ap.Actor, inReplyTo string, published time.Time) (string, error) {
+	id := i.newNoteID(author)
+
+	note := ap.Object{
+		ID:           id,
+		Type:         ap.Note,
+		AttributedTo: author.ID,
+		InReplyTo:    inReplyTo,
+		Content:      fmt.Sprintf("<p>Post number %d, written by %s</p>", len(i.notes), author.PreferredUsername),
+		Published:    ap.Time{Time: published},
+	}
+
+	// most posts are public, the rest are followers-only
+	if i.rand.IntN(4) == 0 {
+		note.To.Add(author.Followers)
+	} else {
+		note.To.Add(ap.Public)
+		note.CC.Add(author.Followers)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`insert into notes(id, author, object, public, inserted) values($1, $2, $3, $4, $5)`,
+		id,
+		author.ID,
+		&note,
+		note.IsPublic(),
+		published.Unix(),
+	); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Thread creates a thread by remote users, with replies up to depth levels deep and up to width replies to each post.
+func (i *Instance) Thread(ctx context.Context, depth, width int) (string, error) {
+	if len(i.Remote) == 0 {
+		return "", fmt.Errorf("no remote users")
+	}
+
+	tx, err := i.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	published := time.Now().Add(-time.Hour * 24)
+
+	root, err := i.insertNote(ctx, tx, i.Remote[i.rand.IntN(len(i.Remote))], "", published)
+	if err != nil {
+		return "", err
+	}
+
+	parents := []string{root}
+	for range depth {
+		var replies []string
+		for _, parent := range parents {
+			for range 1 + i.rand.IntN(width) {
+				published = published.Add(time.Second)
+				reply, err := i.insertNote(ctx, tx, i.Remote[i.rand.IntN(len(i.Remote))], parent, published)
+				if err != nil {
+					return "", err
+				}
+				replies = append(replies, reply)
+			}
+		}
+		parents = replies
+	}
+
+	return root, tx.Commit()
+}
+
+// QueueActivities adds n Create activities by remote users to the inbox.
+func (i *Instance) QueueActivities(ctx context.Context, n int) error {
+	if len(i.Remote) == 0 {
+		return fmt.Errorf("no remote users")
+	}
+
+	for range n {
+		author := i.Remote[i.rand.IntN(len(i.Remote))]
+		id := i.newNoteID(author)
+
+		if _, err := i.DB.ExecContext(
+			ctx,
+			`insert into inbox(sender, activity, raw) values($1, $2, $2)`,
+			author.ID,
+			fmt.Sprintf(
+				`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"%[1]s/create","type":"Create","actor":"%[2]s","object":{"id":"%[1]s","type":"Note","attributedTo":"%[2]s","content":"Hello #world %[3]d","tag":[{"type":"Hashtag","name":"#world"}],"to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["%[4]s"]},"to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["%[4]s"]}`,
+				id,
+				author.ID,
+				len(i.notes),
+				author.Followers,
+			),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bench
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dimkr/tootik/inbox"
+)
+
+func BenchmarkFeedUpdater(b *testing.B) {
+	instance, err := New(
+		context.Background(),
+		Population{
+			LocalUsers:     10,
+			RemoteUsers:    1000,
+			RemoteDomains:  50,
+			PostsPerUser:   20,
+			FollowsPerUser: 100,
+			SharesPerUser:  5,
+		},
+		1,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer instance.Close()
+
+	updater := inbox.FeedUpdater{
+		Domain: Domain,
+		Config: instance.Config,
+		DB:     instance.DB,
+	}
+
+	for range b.N {
+		b.StopTimer()
+
+		if _, err := instance.DB.Exec(`delete from feed`); err != nil {
+			b.Fatal(err)
+		}
+
+		b.StartTimer()
+
+		if err := updater.Run(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
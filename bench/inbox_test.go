@@ -0,0 +1,126 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bench
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/inbox"
+)
+
+func BenchmarkProcessBatch(b *testing.B) {
+	instance, err := New(
+		context.Background(),
+		Population{
+			LocalUsers:     10,
+			RemoteUsers:    1000,
+			RemoteDomains:  50,
+			PostsPerUser:   10,
+			FollowsPerUser: 100,
+		},
+		1,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer instance.Close()
+
+	queue := inbox.Queue{
+		Domain:    Domain,
+		Config:    instance.Config,
+		BlockList: &fed.BlockList{},
+		DB:        instance.DB,
+		Resolver:  fed.NewResolver(nil, Domain, instance.Config, &http.Client{}, instance.DB),
+		Key:       instance.NobodyKey,
+	}
+
+	for range b.N {
+		b.StopTimer()
+
+		if err := instance.QueueActivities(context.Background(), instance.Config.ActivitiesBatchSize); err != nil {
+			b.Fatal(err)
+		}
+
+		b.StartTimer()
+
+		if n, err := queue.ProcessBatch(context.Background()); err != nil {
+			b.Fatal(err)
+		} else if n != instance.Config.ActivitiesBatchSize {
+			b.Fatalf("%d != %d", n, instance.Config.ActivitiesBatchSize)
+		}
+	}
+}
+
+func BenchmarkProcessBacklog(b *testing.B) {
+	const backlog = 10000
+
+	for range b.N {
+		b.StopTimer()
+
+		instance, err := New(
+			context.Background(),
+			Population{
+				LocalUsers:     10,
+				RemoteUsers:    1000,
+				RemoteDomains:  50,
+				PostsPerUser:   10,
+				FollowsPerUser: 100,
+			},
+			1,
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// don't drop activities when the queue is full
+		instance.Config.MaxActivitiesQueueSize = backlog * 2
+
+		if err := instance.QueueActivities(context.Background(), backlog); err != nil {
+			b.Fatal(err)
+		}
+
+		queue := inbox.Queue{
+			Domain:    Domain,
+			Config:    instance.Config,
+			BlockList: &fed.BlockList{},
+			DB:        instance.DB,
+			Resolver:  fed.NewResolver(nil, Domain, instance.Config, &http.Client{}, instance.DB),
+			Key:       instance.NobodyKey,
+		}
+
+		b.StartTimer()
+
+		processed := 0
+		for processed < backlog {
+			n, err := queue.ProcessBatch(context.Background())
+			if err != nil {
+				b.Fatal(err)
+			} else if n == 0 {
+				b.Fatalf("%d != %d", processed, backlog)
+			}
+
+			processed += n
+		}
+
+		b.StopTimer()
+
+		instance.Close()
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bench
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/front"
+	"github.com/dimkr/tootik/inbox"
+	"github.com/stretchr/testify/assert"
+)
+
+// fullScan matches a query plan step that reads an entire table or index
+var fullScan = regexp.MustCompile(`^SCAN (\w+)(?: USING (?:COVERING )?INDEX \w+)?$`)
+
+func queryPlan(t *testing.T, db *sql.DB, query string, args ...any) []string {
+	rows, err := db.Query(`explain query plan `+query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, detail)
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return plan
+}
+
+func TestQueryPlan(t *testing.T) {
+	instance, err := New(
+		context.Background(),
+		Population{
+			LocalUsers:     2,
+			RemoteUsers:    100,
+			RemoteDomains:  10,
+			PostsPerUser:   5,
+			FollowsPerUser: 10,
+			SharesPerUser:  1,
+		},
+		1,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer instance.Close()
+
+	root, err := instance.Thread(context.Background(), 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updater := inbox.FeedUpdater{
+		Domain: Domain,
+		Config: instance.Config,
+		DB:     instance.DB,
+	}
+	if err := updater.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a thread can contain deleted posts
+	if _, err := instance.DB.Exec(`insert into tombstones(id, parent, inserted) select id || '/deleted', id, inserted from notes`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := instance.DB.Exec(`analyze`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		Name string
+		// Scans lists tables that are expected to be scanned, like recursive CTEs
+		Scans []string
+		Query string
+		Args  []any
+	}{
+		{
+			Name:  "feed",
+			Query: front.FeedQuery,
+			Args:  []any{instance.Local[0].ID, 30, 0},
+		},
+		{
+			Name:  "thread",
+			Scans: []string{"thread", "t", "hidden", "tops"},
+			Query: front.ThreadQuery,
+			Args:  []any{root, instance.Config.ThreadMaxDepth, 30, 0},
+		},
+		{
+			Name:  "feed update",
+			Query: inbox.FeedUpdateQuery,
+			Args:  []any{instance.Local[0].ID, 0},
+		},
+		{
+			Name:  "resolve",
+			Query: fed.ResolveQuery,
+			Args:  []any{instance.Remote[0].PreferredUsername, "0.example.org"},
+		},
+		{
+			Name:  "outbox",
+			Query: front.PublicOutboxQuery,
+			Args:  []any{instance.Remote[0].ID, 30, 0},
+		},
+		{
+			Name:  "recipients",
+			Query: fed.RecipientsQuery,
+			Args:  []any{instance.Local[0].ID, "https://" + Domain + "/%", "https://0.example.org/%", 0},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, step := range queryPlan(t, instance.DB, test.Query, test.Args...) {
+				if m := fullScan.FindStringSubmatch(step); m != nil && !slices.Contains(test.Scans, m[1]) {
+					assert.Fail(t, "query scans a whole table or index", step)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bench
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/front"
+	"github.com/dimkr/tootik/front/text/gmi"
+)
+
+func BenchmarkThread(b *testing.B) {
+	instance, err := New(
+		context.Background(),
+		Population{
+			LocalUsers:    1,
+			RemoteUsers:   1000,
+			RemoteDomains: 50,
+			PostsPerUser:  10,
+		},
+		1,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer instance.Close()
+
+	// other threads make the thread harder to find
+	for range 100 {
+		if _, err := instance.Thread(context.Background(), 3, 4); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	root, err := instance.Thread(context.Background(), 5, 4)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	handler, err := front.NewHandler(Domain, false, instance.Config, fed.NewResolver(nil, Domain, instance.Config, &http.Client{}, instance.DB), instance.DB, instance.DB)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	u, err := url.Parse("/users/thread/" + strings.TrimPrefix(root, "https://"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for range b.N {
+		w := gmi.Wrap(io.Discard)
+		handler.Handle(
+			&front.Request{
+				Context: context.Background(),
+				URL:     u,
+				Log:     slog.Default(),
+				User:    instance.Local[0],
+			},
+			w,
+		)
+		w.Flush()
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certs loads TLS certificates and reloads them when they change.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Pair is a TLS certificate and its private key, reloaded without restarting the server that uses them.
+type Pair struct {
+	CertPath string
+	KeyPath  string
+
+	cert atomic.Pointer[tls.Certificate]
+}
+
+const reloadDelay = time.Second * 5
+
+// Load loads a certificate and its private key.
+func Load(certPath, keyPath string) (*Pair, error) {
+	p := Pair{CertPath: certPath, KeyPath: keyPath}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Reload replaces the certificate with the one currently on disk, or keeps the current one on failure.
+func (p *Pair) Reload() error {
+	cert, err := tls.LoadX509KeyPair(p.CertPath, p.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	p.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate implements [tls.Config.GetCertificate].
+func (p *Pair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return p.cert.Load(), nil
+}
+
+// Watch reloads the certificate when the certificate or the key change, or when SIGHUP is received.
+func (p *Pair) Watch(ctx context.Context) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	certDir := filepath.Dir(p.CertPath)
+	certAbsPath := filepath.Join(certDir, filepath.Base(p.CertPath))
+
+	keyDir := filepath.Dir(p.KeyPath)
+	keyAbsPath := filepath.Join(keyDir, filepath.Base(p.KeyPath))
+
+	if err := w.Add(certDir); err != nil {
+		return err
+	}
+
+	if keyDir != certDir {
+		if err := w.Add(keyDir); err != nil {
+			return err
+		}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	// the certificate and the key are usually replaced one after another
+	timer := time.NewTimer(math.MaxInt64)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+
+			if (event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) && (event.Name == certAbsPath || event.Name == keyAbsPath) {
+				slog.Info("File has changed", "name", event.Name)
+				timer.Reset(reloadDelay)
+			}
+
+		case <-sigs:
+			slog.Info("Received reload signal")
+			timer.Reset(0)
+
+		case <-timer.C:
+			if err := p.Reload(); err != nil {
+				slog.Warn("Failed to reload certificate", "cert", p.CertPath, "key", p.KeyPath, "error", err)
+			} else {
+				slog.Info("Reloaded certificate", "cert", p.CertPath)
+			}
+
+		case <-w.Errors:
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writePair(t *testing.T, certPath, keyPath, name string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privDer, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commonName(t *testing.T, p *Pair) string {
+	cert, err := p.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed.Subject.CommonName
+}
+
+func TestPair_Reload(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	writePair(t, certPath, keyPath, "a.localdomain")
+
+	p, err := Load(certPath, keyPath)
+	assert.NoError(err)
+	assert.Equal("a.localdomain", commonName(t, p))
+
+	writePair(t, certPath, keyPath, "b.localdomain")
+	assert.Equal("a.localdomain", commonName(t, p))
+
+	assert.NoError(p.Reload())
+	assert.Equal("b.localdomain", commonName(t, p))
+}
+
+func TestPair_ReloadInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	writePair(t, certPath, keyPath, "a.localdomain")
+
+	p, err := Load(certPath, keyPath)
+	assert.NoError(err)
+
+	// the key doesn't match the certificate yet
+	writePair(t, certPath, filepath.Join(dir, "other.pem"), "b.localdomain")
+	assert.Error(p.Reload())
+	assert.Equal("a.localdomain", commonName(t, p))
+}
+
+func TestPair_LoadMissing(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Load(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	assert.Error(t, err)
+}
@@ -18,20 +18,43 @@ limitations under the License.
 package cfg
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
 	"math"
 	"regexp"
+	"strings"
 	"time"
 )
 
 // Config represents a tootik configuration file.
 type Config struct {
-	DatabaseOptions string
+	DatabaseOptions     string
+	ReadDatabaseOptions string
+
+	LogLevels      map[string]slog.Level
+	MaxLogFileSize int64
+	MaxLogFiles    int
+
+	Admins []string
 
 	RequireRegistration        bool
+	RequireInvitation          bool
+	MaxInvitationsPerUser      int
 	RegistrationInterval       time.Duration
 	CertificateApprovalTimeout time.Duration
 	UserNameRegex              string
 	CompiledUserNameRegex      *regexp.Regexp `json:"-"`
+	ReservedUserNames          []string
+
+	Rules        []string
+	RulesVersion string
+
+	ActorPath     string
+	InboxPath     string
+	OutboxPath    string
+	FollowersPath string
+	PostURL       string
 
 	MaxPostsLength     int
 	MaxPostsPerDay     int64
@@ -44,8 +67,14 @@ type Config struct {
 	ShareThrottleFactor int64
 	ShareThrottleUnit   time.Duration
 
-	PollMaxOptions int
-	PollDuration   time.Duration
+	PollMaxOptions           int
+	PollDuration             time.Duration
+	PollMaxDuration          time.Duration
+	PollRefreshInterval      time.Duration
+	PollRefreshBatchSize     int
+	MinPollRefreshInterval   time.Duration
+	PollRefreshRetryInterval time.Duration
+	MaxPollRefreshAttempts   int
 
 	MaxDisplayNameLength int
 	MaxBioLength         int
@@ -54,46 +83,111 @@ type Config struct {
 	MaxAvatarHeight      int
 	AvatarWidth          int
 	AvatarHeight         int
+	MaxAvatarPixels      int64
+	AvatarColors         int
+	MaxHeaderSize        int64
+	MaxHeaderWidth       int
+	MaxHeaderHeight      int
+	HeaderWidth          int
+	HeaderHeight         int
+	AvatarFetchInterval  time.Duration
+	AvatarFetchTimeout   time.Duration
+	AvatarFetchBatchSize int
 	MinActorEditInterval time.Duration
+	MaxProfileFields     int
+	MaxAliases           int
+	MaxProfileFieldName  int
+	MaxProfileFieldValue int
 
 	MaxFollowsPerUser   int
 	FollowAcceptTimeout time.Duration
+	MaxFollowedHashtags int
+
+	EnableCommunityCreation bool
+	MaxCommunitiesPerUser   int
 
 	MaxBookmarksPerUser int
 	MinBookmarkInterval time.Duration
 
+	MaxFiltersPerUser int
+
 	PostsPerPage   int
 	RepliesPerPage int
 	MaxOffset      int
 
 	SharesPerPost int
 
+	RSSItems int
+
+	ThreadMaxDepth   int
+	ContextAncestors int
+	ContextDepth     int
+
+	MaxCompletions int
+
 	MaxRequestBodySize int64
 	MaxRequestAge      time.Duration
+	TrustedProxies     []string
+	AuthorizedFetch    bool
+
+	InboxRequestInterval        time.Duration
+	InboxRequestBurst           int
+	VerificationFailureInterval time.Duration
+	MaxVerificationFailures     int
+	MaxRejections               int
+	InboxDeduplicationWindow    time.Duration
+
+	ACMEEmail        string
+	ACMEDirectoryURL string
+
+	KeyCacheTTL              time.Duration
+	MaxKeyCacheSize          int
+	VerificationCacheTTL     time.Duration
+	MaxVerificationCacheSize int
 
 	MaxResponseBodySize int64
 
 	CompactViewMaxRunes int
 	CompactViewMaxLines int
+	ArticlePageMaxRunes int
 
 	CacheUpdateTimeout time.Duration
 
-	GeminiRequestTimeout time.Duration
+	GeminiRequestTimeout  time.Duration
+	GeminiRenderBudget    time.Duration
+	MaxGeminiResponseSize int64
+
+	MaxConnections         int
+	MaxConnectionsPerIP    int
+	MaxSlowDownConnections int
+	ConnectionInterval     time.Duration
+	ConnectionBurst        int
 
 	GopherRequestTimeout time.Duration
 	LineWidth            int
+	GopherASCII          bool
+	GopherTokenTTL       time.Duration
 
 	GuppyRequestTimeout time.Duration
 	MaxGuppySessions    int
 	GuppyChunkTimeout   time.Duration
 	MaxSentGuppyChunks  int
 
+	FingerMaxPosts     int
+	FingerMaxFollowers int
+	FingerLineWidth    int
+	FingerASCII        bool
+
 	DeliveryBatchSize     int
 	DeliveryRetryInterval int64
 	MaxDeliveryAttempts   int
 	DeliveryTimeout       time.Duration
 	DeliveryWorkers       int
 	DeliveryWorkerBuffer  int
+	MaxRequestsPerDomain  int
+	DomainRequestInterval time.Duration
+	DormantDomainTimeout  time.Duration
+	DormantDomainProbe    time.Duration
 
 	OutboxPollingInterval time.Duration
 
@@ -109,32 +203,122 @@ type Config struct {
 
 	ResolverCacheTTL        time.Duration
 	ResolverRetryInterval   time.Duration
+	WebFingerCacheTTL       time.Duration
 	ResolverMaxIdleConns    int
 	ResolverIdleConnTimeout time.Duration
 	MaxInstanceRecoveryTime time.Duration
 	MaxResolverRequests     int
 
+	ResolverFailureCacheTTL     time.Duration
+	MaxResolverFailureCacheSize int
+
+	Proxy                   string
+	OnionProxy              string
+	OnionInsecureSkipVerify bool
+	OnionDeliveryTimeout    time.Duration
+
+	DNSCacheTTL       time.Duration
+	MaxDNSCacheSize   int
+	DNSTimeout        time.Duration
+	DialTimeout       time.Duration
+	DialFallbackDelay time.Duration
+
+	FetchTimeout            time.Duration
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+
 	FollowersSyncBatchSize int
 	FollowersSyncInterval  time.Duration
 
 	FeedUpdateInterval time.Duration
 
+	LinkVerificationInterval time.Duration
+	LinkVerificationTimeout  time.Duration
+
 	NotesTTL          time.Duration
 	InvisiblePostsTTL time.Duration
 	DeliveryTTL       time.Duration
 	SharesTTL         time.Duration
 	ActorTTL          time.Duration
 	FeedTTL           time.Duration
+	TombstonesTTL     time.Duration
+	RemotePostsTTL    time.Duration
+
+	PostExpiryBatchSize   int
+	MaxPostExpiryAttempts int
+
+	MaxDatabaseSize    int64
+	EvictionBatchSize  int
+	MaxEvictionBatches int
+
+	ConsistencyCheckBatchSize int
 
 	FillNodeInfoUsage bool
+
+	LimitSilencedDomains bool
+
+	EnableHTMLFrontend bool
+
+	TranslationURL     string
+	TranslationKey     string
+	TranslationTimeout time.Duration
+
+	ReplicationCommand    []string
+	ReplicationInterval   time.Duration
+	ReplicationTimeout    time.Duration
+	ReplicationHealthFile string
+
+	MaintenanceInterval time.Duration
+
+	BackupDirectory string
+	BackupInterval  time.Duration
+	MaxBackups      int
+
+	PollResultsUpdateInterval       time.Duration
+	FollowMoveInterval              time.Duration
+	ActorUpdateInterval             time.Duration
+	PostExpiryInterval              time.Duration
+	FollowersSyncPollingInterval    time.Duration
+	LinkVerificationPollingInterval time.Duration
+	GarbageCollectionInterval       time.Duration
+	ConsistencyCheckInterval        time.Duration
+	BlockListUpdateInterval         time.Duration
+	JobJitterInterval               time.Duration
+
+	MaxCachedPages   int
+	LocalCacheTTL    time.Duration
+	OutboxCacheTTL   time.Duration
+	HashtagCacheTTL  time.Duration
+	HashtagsCacheTTL time.Duration
+	StatusCacheTTL   time.Duration
+
+	RemoteCountsCacheTTL   time.Duration
+	MaxCachedRemoteCounts  int
+	MaxRemoteCountsFetches int
 }
 
 // FillDefaults replaces missing or invalid settings with defaults.
 func (c *Config) FillDefaults() {
+	if c.MaxLogFileSize <= 0 {
+		c.MaxLogFileSize = 1024 * 1024 * 64
+	}
+
+	if c.MaxLogFiles <= 0 {
+		c.MaxLogFiles = 5
+	}
+
 	if c.DatabaseOptions == "" {
 		c.DatabaseOptions = "_journal_mode=WAL&_synchronous=1&_busy_timeout=5000"
 	}
 
+	if c.ReadDatabaseOptions == "" {
+		c.ReadDatabaseOptions = "mode=ro&_busy_timeout=5000"
+	}
+
+	if c.MaxInvitationsPerUser <= 0 {
+		c.MaxInvitationsPerUser = 5
+	}
+
 	if c.RegistrationInterval <= 0 {
 		c.RegistrationInterval = time.Hour
 	}
@@ -149,6 +333,36 @@ func (c *Config) FillDefaults() {
 
 	c.CompiledUserNameRegex = regexp.MustCompile(c.UserNameRegex)
 
+	if c.ReservedUserNames == nil {
+		c.ReservedUserNames = []string{"admin", "root"}
+	}
+
+	// users must accept the rules again when they change, unless the version is set explicitly
+	if len(c.Rules) > 0 && c.RulesVersion == "" {
+		hash := sha256.Sum256([]byte(strings.Join(c.Rules, "\n")))
+		c.RulesVersion = hex.EncodeToString(hash[:8])
+	}
+
+	if c.ActorPath == "" {
+		c.ActorPath = "/user/{username}"
+	}
+
+	if c.InboxPath == "" {
+		c.InboxPath = "/inbox/{username}"
+	}
+
+	if c.OutboxPath == "" {
+		c.OutboxPath = "/outbox/{username}"
+	}
+
+	if c.FollowersPath == "" {
+		c.FollowersPath = "/followers/{username}"
+	}
+
+	if !strings.Contains(c.PostURL, "{post}") {
+		c.PostURL = "gemini://{domain}/view/{post}"
+	}
+
 	if c.MaxPostsLength <= 0 {
 		c.MaxPostsLength = 500
 	}
@@ -189,6 +403,30 @@ func (c *Config) FillDefaults() {
 		c.PollDuration = time.Hour * 24 * 30
 	}
 
+	if c.PollMaxDuration < c.PollDuration {
+		c.PollMaxDuration = c.PollDuration
+	}
+
+	if c.PollRefreshInterval <= 0 {
+		c.PollRefreshInterval = time.Minute * 10
+	}
+
+	if c.PollRefreshBatchSize <= 0 {
+		c.PollRefreshBatchSize = 16
+	}
+
+	if c.MinPollRefreshInterval <= 0 {
+		c.MinPollRefreshInterval = time.Minute
+	}
+
+	if c.PollRefreshRetryInterval <= 0 {
+		c.PollRefreshRetryInterval = time.Hour
+	}
+
+	if c.MaxPollRefreshAttempts <= 0 {
+		c.MaxPollRefreshAttempts = 5
+	}
+
 	if c.MaxDisplayNameLength <= 0 {
 		c.MaxDisplayNameLength = 30
 	}
@@ -217,10 +455,66 @@ func (c *Config) FillDefaults() {
 		c.AvatarHeight = 400
 	}
 
+	if c.MaxAvatarPixels <= 0 {
+		c.MaxAvatarPixels = 1024 * 1024
+	}
+
+	if c.AvatarColors <= 0 || c.AvatarColors > 256 {
+		c.AvatarColors = 256
+	}
+
+	if c.MaxHeaderSize <= 0 {
+		c.MaxHeaderSize = 4 * 1024 * 1024
+	}
+
+	if c.MaxHeaderWidth <= 0 {
+		c.MaxHeaderWidth = 3000
+	}
+
+	if c.MaxHeaderHeight <= 0 {
+		c.MaxHeaderHeight = 1500
+	}
+
+	if c.HeaderWidth <= 0 {
+		c.HeaderWidth = 1500
+	}
+
+	if c.HeaderHeight <= 0 {
+		c.HeaderHeight = 500
+	}
+
+	if c.AvatarFetchInterval <= 0 {
+		c.AvatarFetchInterval = time.Minute * 10
+	}
+
+	if c.AvatarFetchTimeout <= 0 {
+		c.AvatarFetchTimeout = time.Second * 10
+	}
+
+	if c.AvatarFetchBatchSize <= 0 {
+		c.AvatarFetchBatchSize = 32
+	}
+
 	if c.MinActorEditInterval <= 0 {
 		c.MinActorEditInterval = time.Minute * 30
 	}
 
+	if c.MaxProfileFields <= 0 {
+		c.MaxProfileFields = 4
+	}
+
+	if c.MaxAliases <= 0 {
+		c.MaxAliases = 4
+	}
+
+	if c.MaxProfileFieldName <= 0 {
+		c.MaxProfileFieldName = 30
+	}
+
+	if c.MaxProfileFieldValue <= 0 {
+		c.MaxProfileFieldValue = 200
+	}
+
 	if c.MaxFollowsPerUser <= 0 {
 		c.MaxFollowsPerUser = 150
 	}
@@ -229,6 +523,14 @@ func (c *Config) FillDefaults() {
 		c.FollowAcceptTimeout = time.Hour * 24 * 2
 	}
 
+	if c.MaxFollowedHashtags <= 0 {
+		c.MaxFollowedHashtags = 50
+	}
+
+	if c.MaxCommunitiesPerUser <= 0 {
+		c.MaxCommunitiesPerUser = 3
+	}
+
 	if c.MaxBookmarksPerUser <= 0 {
 		c.MaxBookmarksPerUser = 100
 	}
@@ -237,6 +539,10 @@ func (c *Config) FillDefaults() {
 		c.MinBookmarkInterval = time.Second * 5
 	}
 
+	if c.MaxFiltersPerUser <= 0 {
+		c.MaxFiltersPerUser = 20
+	}
+
 	if c.PostsPerPage <= 0 {
 		c.PostsPerPage = 30
 	}
@@ -253,6 +559,26 @@ func (c *Config) FillDefaults() {
 		c.SharesPerPost = 10
 	}
 
+	if c.RSSItems <= 0 {
+		c.RSSItems = 20
+	}
+
+	if c.ThreadMaxDepth <= 0 {
+		c.ThreadMaxDepth = 4
+	}
+
+	if c.ContextAncestors <= 0 {
+		c.ContextAncestors = 3
+	}
+
+	if c.ContextDepth <= 0 {
+		c.ContextDepth = 2
+	}
+
+	if c.MaxCompletions <= 0 {
+		c.MaxCompletions = 10
+	}
+
 	if c.MaxRequestBodySize <= 0 {
 		c.MaxRequestBodySize = 1024 * 1024
 	}
@@ -261,6 +587,46 @@ func (c *Config) FillDefaults() {
 		c.MaxRequestAge = time.Minute * 5
 	}
 
+	if c.InboxRequestInterval <= 0 {
+		c.InboxRequestInterval = time.Millisecond * 100
+	}
+
+	if c.InboxRequestBurst <= 0 {
+		c.InboxRequestBurst = 500
+	}
+
+	if c.VerificationFailureInterval <= 0 {
+		c.VerificationFailureInterval = time.Minute
+	}
+
+	if c.MaxVerificationFailures <= 0 {
+		c.MaxVerificationFailures = 30
+	}
+
+	if c.MaxRejections <= 0 {
+		c.MaxRejections = 1000
+	}
+
+	if c.InboxDeduplicationWindow <= 0 {
+		c.InboxDeduplicationWindow = time.Hour * 24
+	}
+
+	if c.KeyCacheTTL <= 0 {
+		c.KeyCacheTTL = time.Minute * 10
+	}
+
+	if c.MaxKeyCacheSize <= 0 {
+		c.MaxKeyCacheSize = 1024
+	}
+
+	if c.VerificationCacheTTL <= 0 {
+		c.VerificationCacheTTL = c.MaxRequestAge
+	}
+
+	if c.MaxVerificationCacheSize <= 0 {
+		c.MaxVerificationCacheSize = 4096
+	}
+
 	if c.MaxResponseBodySize <= 0 {
 		c.MaxResponseBodySize = 1024 * 1024
 	}
@@ -271,6 +637,9 @@ func (c *Config) FillDefaults() {
 	if c.CompactViewMaxLines <= 0 {
 		c.CompactViewMaxLines = 4
 	}
+	if c.ArticlePageMaxRunes <= 0 {
+		c.ArticlePageMaxRunes = 8000
+	}
 
 	if c.CacheUpdateTimeout <= 0 {
 		c.CacheUpdateTimeout = time.Second * 5
@@ -280,6 +649,34 @@ func (c *Config) FillDefaults() {
 		c.GeminiRequestTimeout = time.Second * 30
 	}
 
+	if c.GeminiRenderBudget <= 0 {
+		c.GeminiRenderBudget = time.Second * 20
+	}
+
+	if c.MaxGeminiResponseSize <= 0 {
+		c.MaxGeminiResponseSize = 2 * 1024 * 1024
+	}
+
+	if c.MaxConnections <= 0 {
+		c.MaxConnections = 1024
+	}
+
+	if c.MaxConnectionsPerIP <= 0 {
+		c.MaxConnectionsPerIP = 16
+	}
+
+	if c.MaxSlowDownConnections <= 0 {
+		c.MaxSlowDownConnections = 16
+	}
+
+	if c.ConnectionInterval <= 0 {
+		c.ConnectionInterval = time.Millisecond * 200
+	}
+
+	if c.ConnectionBurst <= 0 {
+		c.ConnectionBurst = 30
+	}
+
 	if c.GopherRequestTimeout <= 0 {
 		c.GopherRequestTimeout = time.Second * 30
 	}
@@ -288,6 +685,10 @@ func (c *Config) FillDefaults() {
 		c.LineWidth = 70
 	}
 
+	if c.GopherTokenTTL <= 0 {
+		c.GopherTokenTTL = time.Hour * 24 * 7
+	}
+
 	if c.GuppyRequestTimeout <= 0 {
 		c.GuppyRequestTimeout = time.Second * 30
 	}
@@ -304,6 +705,18 @@ func (c *Config) FillDefaults() {
 		c.MaxSentGuppyChunks = 8
 	}
 
+	if c.FingerMaxPosts <= 0 {
+		c.FingerMaxPosts = 5
+	}
+
+	if c.FingerMaxFollowers <= 0 {
+		c.FingerMaxFollowers = 20
+	}
+
+	if c.FingerLineWidth <= 0 {
+		c.FingerLineWidth = 80
+	}
+
 	if c.DeliveryBatchSize <= 0 {
 		c.DeliveryBatchSize = 16
 	}
@@ -320,6 +733,42 @@ func (c *Config) FillDefaults() {
 		c.DeliveryTimeout = time.Minute * 5
 	}
 
+	if c.OnionDeliveryTimeout <= 0 {
+		c.OnionDeliveryTimeout = c.DeliveryTimeout * 2
+	}
+
+	if c.DNSCacheTTL <= 0 {
+		c.DNSCacheTTL = time.Minute * 5
+	}
+
+	if c.MaxDNSCacheSize <= 0 {
+		c.MaxDNSCacheSize = 1024
+	}
+
+	if c.DNSTimeout <= 0 {
+		c.DNSTimeout = time.Second * 5
+	}
+
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = time.Second * 30
+	}
+
+	if c.DialFallbackDelay <= 0 {
+		c.DialFallbackDelay = time.Millisecond * 300
+	}
+
+	if c.FetchTimeout <= 0 {
+		c.FetchTimeout = time.Second * 30
+	}
+
+	if c.CircuitBreakerThreshold <= 0 {
+		c.CircuitBreakerThreshold = 5
+	}
+
+	if c.CircuitBreakerCooldown <= 0 {
+		c.CircuitBreakerCooldown = time.Minute * 5
+	}
+
 	if c.DeliveryWorkers <= 0 || c.DeliveryWorkers > math.MaxInt {
 		c.DeliveryWorkers = 4
 	}
@@ -328,6 +777,22 @@ func (c *Config) FillDefaults() {
 		c.DeliveryWorkerBuffer = 16
 	}
 
+	if c.MaxRequestsPerDomain <= 0 {
+		c.MaxRequestsPerDomain = 2
+	}
+
+	if c.DomainRequestInterval <= 0 {
+		c.DomainRequestInterval = time.Millisecond * 100
+	}
+
+	if c.DormantDomainTimeout <= 0 {
+		c.DormantDomainTimeout = time.Hour * 24 * 3
+	}
+
+	if c.DormantDomainProbe <= 0 {
+		c.DormantDomainProbe = time.Hour * 6
+	}
+
 	if c.OutboxPollingInterval <= 0 {
 		c.OutboxPollingInterval = time.Second * 5
 	}
@@ -372,6 +837,10 @@ func (c *Config) FillDefaults() {
 		c.ResolverRetryInterval = time.Hour * 6
 	}
 
+	if c.WebFingerCacheTTL <= 0 {
+		c.WebFingerCacheTTL = time.Hour * 24 * 7
+	}
+
 	if c.ResolverMaxIdleConns <= 0 {
 		c.ResolverMaxIdleConns = 128
 	}
@@ -388,6 +857,14 @@ func (c *Config) FillDefaults() {
 		c.MaxResolverRequests = 16
 	}
 
+	if c.ResolverFailureCacheTTL <= 0 {
+		c.ResolverFailureCacheTTL = time.Minute
+	}
+
+	if c.MaxResolverFailureCacheSize <= 0 {
+		c.MaxResolverFailureCacheSize = 1024
+	}
+
 	if c.FollowersSyncBatchSize <= 0 {
 		c.FollowersSyncBatchSize = 64
 	}
@@ -400,6 +877,14 @@ func (c *Config) FillDefaults() {
 		c.FeedUpdateInterval = time.Minute * 10
 	}
 
+	if c.LinkVerificationInterval <= 0 {
+		c.LinkVerificationInterval = time.Hour * 24
+	}
+
+	if c.LinkVerificationTimeout <= 0 {
+		c.LinkVerificationTimeout = time.Second * 10
+	}
+
 	if c.NotesTTL <= 0 {
 		c.NotesTTL = time.Hour * 24 * 30
 	}
@@ -423,4 +908,128 @@ func (c *Config) FillDefaults() {
 	if c.FeedTTL <= 0 {
 		c.FeedTTL = time.Hour * 24 * 7
 	}
+
+	if c.TombstonesTTL <= 0 {
+		c.TombstonesTTL = time.Hour * 24 * 30
+	}
+
+	if c.PostExpiryBatchSize <= 0 {
+		c.PostExpiryBatchSize = 100
+	}
+
+	if c.MaxPostExpiryAttempts <= 0 {
+		c.MaxPostExpiryAttempts = 3
+	}
+
+	if c.EvictionBatchSize <= 0 {
+		c.EvictionBatchSize = 1000
+	}
+
+	if c.MaxEvictionBatches <= 0 {
+		c.MaxEvictionBatches = 100
+	}
+
+	if c.ConsistencyCheckBatchSize <= 0 {
+		c.ConsistencyCheckBatchSize = 1000
+	}
+
+	if c.TranslationTimeout <= 0 {
+		c.TranslationTimeout = time.Second * 10
+	}
+
+	if c.ReplicationInterval <= 0 {
+		c.ReplicationInterval = time.Minute * 10
+	}
+
+	if c.ReplicationTimeout <= 0 {
+		c.ReplicationTimeout = time.Second * 3
+	}
+
+	if c.MaintenanceInterval <= 0 {
+		c.MaintenanceInterval = time.Hour * 24
+	}
+
+	if c.BackupInterval <= 0 {
+		c.BackupInterval = time.Hour * 24
+	}
+
+	if c.MaxBackups <= 0 {
+		c.MaxBackups = 7
+	}
+
+	if c.PollResultsUpdateInterval <= 0 {
+		c.PollResultsUpdateInterval = time.Hour / 2
+	}
+
+	if c.FollowMoveInterval <= 0 {
+		c.FollowMoveInterval = time.Hour * 6
+	}
+
+	if c.ActorUpdateInterval <= 0 {
+		c.ActorUpdateInterval = time.Hour
+	}
+
+	if c.PostExpiryInterval <= 0 {
+		c.PostExpiryInterval = time.Hour
+	}
+
+	if c.FollowersSyncPollingInterval <= 0 {
+		c.FollowersSyncPollingInterval = time.Hour * 6
+	}
+
+	if c.LinkVerificationPollingInterval <= 0 {
+		c.LinkVerificationPollingInterval = time.Hour
+	}
+
+	if c.GarbageCollectionInterval <= 0 {
+		c.GarbageCollectionInterval = time.Hour * 12
+	}
+
+	if c.ConsistencyCheckInterval <= 0 {
+		c.ConsistencyCheckInterval = time.Hour * 24
+	}
+
+	if c.BlockListUpdateInterval <= 0 {
+		c.BlockListUpdateInterval = time.Minute
+	}
+
+	if c.JobJitterInterval <= 0 {
+		c.JobJitterInterval = time.Minute
+	}
+
+	if c.MaxCachedPages <= 0 {
+		c.MaxCachedPages = 256
+	}
+
+	if c.LocalCacheTTL <= 0 {
+		c.LocalCacheTTL = time.Minute * 15
+	}
+
+	if c.OutboxCacheTTL <= 0 {
+		c.OutboxCacheTTL = time.Minute * 5
+	}
+
+	if c.HashtagCacheTTL <= 0 {
+		c.HashtagCacheTTL = time.Minute * 5
+	}
+
+	if c.HashtagsCacheTTL <= 0 {
+		c.HashtagsCacheTTL = time.Minute * 30
+	}
+
+	if c.StatusCacheTTL <= 0 {
+		c.StatusCacheTTL = time.Minute * 5
+	}
+
+	if c.RemoteCountsCacheTTL <= 0 {
+		c.RemoteCountsCacheTTL = time.Minute * 10
+	}
+
+	if c.MaxCachedRemoteCounts <= 0 {
+		c.MaxCachedRemoteCounts = 1024
+	}
+
+	if c.MaxRemoteCountsFetches <= 0 {
+		c.MaxRemoteCountsFetches = 4
+	}
 }
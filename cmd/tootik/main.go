@@ -18,12 +18,14 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"database/sql"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
+	"math/rand/v2"
 	"net/http"
 	"os"
 
@@ -33,6 +35,7 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/buildinfo"
@@ -46,38 +49,46 @@ import (
 	"github.com/dimkr/tootik/front/guppy"
 	tplain "github.com/dimkr/tootik/front/text/plain"
 	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/front/web"
 	"github.com/dimkr/tootik/icon"
 	"github.com/dimkr/tootik/inbox"
+	"github.com/dimkr/tootik/logging"
+	"github.com/dimkr/tootik/metrics"
 	"github.com/dimkr/tootik/migrations"
 	"github.com/dimkr/tootik/outbox"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const (
-	pollResultsUpdateInterval = time.Hour / 2
-	garbageCollectionInterval = time.Hour * 12
-	followMoveInterval        = time.Hour * 6
-	followSyncInterval        = time.Hour * 6
+var (
+	activeListeners = metrics.NewGauge("tootik_listener_active", "Whether a listener is running", "listener")
+	jobDuration     = metrics.NewSummary("tootik_job_duration_seconds", "Duration of periodic jobs", "job")
 )
 
 var (
 	domain        = flag.String("domain", "localhost.localdomain:8443", "Domain name")
-	logLevel      = flag.Int("loglevel", int(slog.LevelInfo), "Logging verbosity")
+	logLevel      = flag.Int("loglevel", int(slog.LevelInfo), "Logging verbosity (see LogLevels for per-subsystem levels)")
+	logFile       = flag.String("logfile", "", "Log file path (stderr if empty)")
 	dbPath        = flag.String("db", "db.sqlite3", "database path")
+	readDBPath    = flag.String("readdb", "", "Read-only database path (i.e. a read replica)")
 	gemCert       = flag.String("gemcert", "gemini-cert.pem", "Gemini TLS certificate")
 	gemKey        = flag.String("gemkey", "gemini-key.pem", "Gemini TLS key")
+	gemGenCert    = flag.Bool("gemgencert", false, "Generate a self-signed Gemini TLS certificate if missing")
 	gemAddr       = flag.String("gemaddr", ":8965", "Gemini listening address")
 	gopherAddr    = flag.String("gopheraddr", ":8070", "Gopher listening address")
 	fingerAddr    = flag.String("fingeraddr", ":8079", "Finger listening address")
 	guppyAddr     = flag.String("guppyaddr", ":6775", "Guppy listening address")
+	metricsAddr   = flag.String("metricsaddr", "", "Prometheus metrics listening address")
 	cert          = flag.String("cert", "cert.pem", "HTTPS TLS certificate")
 	key           = flag.String("key", "key.pem", "HTTPS TLS key")
-	addr          = flag.String("addr", ":8443", "HTTPS listening address")
+	addr          = flag.String("addr", ":8443", "HTTPS listening address (or unix:PATH)")
+	acmeCache     = flag.String("acmecache", "", "Obtain the HTTPS TLS certificate using ACME and cache it in this directory")
+	acmeAddr      = flag.String("acmeaddr", "", "ACME HTTP-01 challenge listening address (TLS-ALPN-01 is used if empty)")
 	blockListPath = flag.String("blocklist", "", "Blocklist CSV")
 	closed        = flag.Bool("closed", false, "Disable new user registration")
 	plain         = flag.Bool("plain", false, "Use HTTP instead of HTTPS")
 	cfgPath       = flag.String("cfg", "", "Configuration file")
 	dumpCfg       = flag.Bool("dumpcfg", false, "Print default configuration and exit")
+	gcDryRun      = flag.Bool("gcdryrun", false, "Print what garbage collection would delete and exit")
 	version       = flag.Bool("version", false, "Print version and exit")
 )
 
@@ -87,9 +98,17 @@ func main() {
 		flag.PrintDefaults()
 
 		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]...\n\tRun tootik\n", os.Args[0])
-		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... add-community NAME\n\tAdd a community\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... add-community NAME [OWNER]\n\tAdd a community, optionally owned and moderated by a user\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... set-bio NAME PATH\n\tSet user's bio\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... set-avatar NAME PATH\n\tSet user's avatar\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... set-header NAME PATH\n\tSet user's header image\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... backup PATH\n\tBack up the database while tootik is running\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... doctor\n\tCheck if other servers can reach this server\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... migrate [-to VERSION]\n\tApply or revert migrations, until the database schema version is VERSION (latest if unspecified)\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... peers\n\tList known servers and the status of deliveries to them\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... fetch URL|USER@HOST\n\tFetch and print an object or an actor\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... import-follows PATH\n\tImport follows from a CSV file of follower,followed pairs\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s [flag]... import-blocks PATH\n\tImport domain blocks from a CSV file in Mastodon's format\n", os.Args[0])
 
 		os.Exit(2)
 	}
@@ -101,7 +120,7 @@ func main() {
 	}
 
 	cmd := flag.Arg(0)
-	if !((cmd == "" && flag.NArg() == 0) || (cmd == "add-community" && flag.NArg() == 2 && flag.Arg(1) != "") || ((cmd == "set-bio" || cmd == "set-avatar") && flag.NArg() == 3 && flag.Arg(1) != "" && flag.Arg(2) != "")) {
+	if !((cmd == "" && flag.NArg() == 0) || (cmd == "add-community" && (flag.NArg() == 2 || (flag.NArg() == 3 && flag.Arg(2) != "")) && flag.Arg(1) != "") || ((cmd == "set-bio" || cmd == "set-avatar" || cmd == "set-header") && flag.NArg() == 3 && flag.Arg(1) != "" && flag.Arg(2) != "") || (cmd == "backup" && flag.NArg() == 2 && flag.Arg(1) != "") || ((cmd == "doctor" || cmd == "peers") && flag.NArg() == 1) || ((cmd == "fetch" || cmd == "import-follows" || cmd == "import-blocks") && flag.NArg() == 2 && flag.Arg(1) != "") || cmd == "migrate") {
 		flag.Usage()
 	}
 
@@ -134,24 +153,49 @@ func main() {
 
 	cfg.FillDefaults()
 
-	opts := slog.HandlerOptions{Level: slog.Level(*logLevel)}
-	if opts.Level == slog.LevelDebug {
+	if err := fed.CheckPaths(*domain, &cfg); err != nil {
+		panic(err)
+	}
+
+	var logOutput io.Writer = os.Stderr
+	if *logFile != "" {
+		f, err := logging.OpenFile(*logFile, cfg.MaxLogFileSize, cfg.MaxLogFiles)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		logOutput = f
+	}
+
+	// filtering by level happens in logging.Handler
+	opts := slog.HandlerOptions{Level: slog.Level(math.MinInt)}
+	if slog.Level(*logLevel) == slog.LevelDebug {
 		opts.AddSource = true
 	}
+	for _, level := range cfg.LogLevels {
+		if level == slog.LevelDebug {
+			opts.AddSource = true
+		}
+	}
+
+	logHandler, err := logging.NewHandler(slog.NewJSONHandler(logOutput, &opts), slog.Level(*logLevel), cfg.LogLevels)
+	if err != nil {
+		panic(err)
+	}
 
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &opts)))
+	slog.SetDefault(slog.New(logHandler))
 	slog.SetLogLoggerLevel(slog.Level(*logLevel))
 
-	var blockList *fed.BlockList
+	blockList := &fed.BlockList{}
 	if *blockListPath != "" {
 		var err error
-		blockList, err = fed.NewBlockList(*blockListPath)
+		blockList, err = fed.NewBlockList(*blockListPath, cfg.LimitSilencedDomains)
 		if err != nil {
 			panic(err)
 		}
-
-		defer blockList.Close()
 	}
+	defer blockList.Close()
 
 	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?%s", *dbPath, cfg.DatabaseOptions))
 	if err != nil {
@@ -161,21 +205,30 @@ func main() {
 
 	slog.Debug("Starting", "version", buildinfo.Version, "cfg", &cfg)
 
-	transport := http.Transport{
-		MaxIdleConns:    cfg.ResolverMaxIdleConns,
-		IdleConnTimeout: cfg.ResolverIdleConnTimeout,
-		TLSClientConfig: &tls.Config{
-			MinVersion: tls.VersionTLS12,
-		},
+	transport, err := fed.NewTransport(&cfg)
+	if err != nil {
+		panic(err)
 	}
 	client := http.Client{
-		Transport: &transport,
+		Transport: transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 	}
 	resolver := fed.NewResolver(blockList, *domain, &cfg, &client, db)
 
+	// links in profile fields are supplied by users and may point to internal services
+	publicTransport, err := fed.NewPublicTransport(&cfg)
+	if err != nil {
+		panic(err)
+	}
+	publicClient := http.Client{
+		Transport: publicTransport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	sigs := make(chan os.Signal, 1)
@@ -195,21 +248,173 @@ func main() {
 		}
 	}()
 
+	if cmd == "migrate" {
+		migrateFlags := flag.NewFlagSet("migrate", flag.ExitOnError)
+		to := migrateFlags.Int("to", migrations.Latest(), "Database schema version")
+		migrateFlags.Parse(flag.Args()[1:])
+		if migrateFlags.NArg() > 0 {
+			flag.Usage()
+		}
+
+		if err := migrations.Migrate(ctx, *domain, db, *to); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if *gcDryRun {
+		gc := data.GarbageCollector{
+			Domain: *domain,
+			Config: &cfg,
+			DB:     db,
+		}
+		if err := gc.DryRun(ctx, os.Stdout); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
 	if err := migrations.Run(ctx, *domain, db); err != nil {
 		panic(err)
 	}
 
-	_, nobodyKey, err := user.CreateNobody(ctx, *domain, db)
+	if err := blockList.Refresh(ctx, db); err != nil {
+		panic(err)
+	}
+
+	_, nobodyKey, err := user.CreateNobody(ctx, *domain, &cfg, db)
 	if err != nil {
 		panic(err)
 	}
 
 	switch cmd {
+	case "backup":
+		if err := data.Backup(ctx, db, flag.Arg(1)); err != nil {
+			panic(err)
+		}
+
+		return
+
+	case "doctor":
+		doctor := fed.Doctor{
+			Domain:         *domain,
+			Config:         &cfg,
+			Client:         &client,
+			Key:            nobodyKey,
+			GeminiCertPath: *gemCert,
+			GeminiKeyPath:  *gemKey,
+		}
+
+		// the HTTPS certificate is managed by ACME, or by a reverse proxy if tootik uses plain HTTP
+		if *acmeCache == "" && !*plain {
+			doctor.CertPath = *cert
+			doctor.KeyPath = *key
+		}
+
+		if err := doctor.Run(ctx, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+
+	case "peers", "fetch":
+		inspector := fed.Inspector{
+			Domain:   *domain,
+			Config:   &cfg,
+			DB:       db,
+			Resolver: resolver,
+			Key:      nobodyKey,
+		}
+
+		if cmd == "peers" {
+			err = inspector.Peers(ctx, os.Stdout)
+		} else {
+			err = inspector.Fetch(ctx, os.Stdout, flag.Arg(1))
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+
+	case "import-follows":
+		f, err := os.Open(flag.Arg(1))
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		importer := fed.FollowImporter{
+			Domain:   *domain,
+			Config:   &cfg,
+			DB:       db,
+			Resolver: resolver,
+			Key:      nobodyKey,
+		}
+
+		if err := importer.Import(ctx, os.Stdout, f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+
+	case "import-blocks":
+		f, err := os.Open(flag.Arg(1))
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		importer := fed.DomainBlockImporter{
+			Domain: *domain,
+			DB:     db,
+		}
+
+		if err := importer.Import(ctx, os.Stdout, f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+
 	case "add-community":
-		_, _, err := user.Create(ctx, *domain, db, flag.Arg(1), ap.Group, nil)
+		if exists, err := user.CheckName(ctx, *domain, &cfg, db, flag.Arg(1)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		} else if exists {
+			fmt.Fprintln(os.Stderr, user.ErrNameTaken)
+			os.Exit(1)
+		}
+
+		var ownerID string
+		if flag.NArg() == 3 {
+			if err := db.QueryRowContext(
+				ctx,
+				`select id from persons where host = ? and actor->>'$.preferredUsername' = ? and actor->>'$.type' = 'Person'`,
+				*domain,
+				flag.Arg(2),
+			).Scan(&ownerID); err != nil {
+				panic(err)
+			}
+		}
+
+		group, _, err := user.Create(ctx, *domain, &cfg, db, flag.Arg(1), ap.Group, nil)
 		if err != nil {
 			panic(err)
 		}
+
+		if ownerID != "" {
+			if _, err := db.ExecContext(ctx, `insert into communities(id, owner) values(?, ?)`, group.ID, ownerID); err != nil {
+				panic(err)
+			}
+		}
+
 		return
 
 	case "set-bio":
@@ -313,32 +518,116 @@ func main() {
 		}
 
 		return
+
+	case "set-header":
+		header, err := os.ReadFile(flag.Arg(2))
+		if err != nil {
+			panic(err)
+		}
+
+		resized, err := icon.ScaleHeader(&cfg, header)
+		if err != nil {
+			panic(err)
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer tx.Rollback()
+
+		userName := flag.Arg(1)
+
+		var actorID string
+		if err := tx.QueryRowContext(
+			ctx,
+			`select id from persons where host = ? and actor->>'$.preferredUsername' = ?`,
+			*domain,
+			userName,
+		).Scan(&actorID); err != nil {
+			panic(err)
+		}
+
+		now := time.Now()
+
+		if _, err := tx.ExecContext(
+			ctx,
+			"update persons set actor = json_set(actor, '$.image', json_object('type', 'Image', 'mediaType', $1, 'url', $2), '$.updated', $3) where id = $4",
+			icon.MediaType,
+			fmt.Sprintf("https://%s/header/%s%s#%d", *domain, userName, icon.FileNameExtension, now.UnixNano()),
+			now.Format(time.RFC3339Nano),
+			actorID,
+		); err != nil {
+			panic(err)
+		}
+
+		if _, err := tx.ExecContext(
+			ctx,
+			"insert into headers(name, buf) values($1, $2) on conflict(name) do update set buf = $2",
+			userName,
+			string(resized),
+		); err != nil {
+			panic(err)
+		}
+
+		if err := outbox.UpdateActor(ctx, *domain, tx, actorID); err != nil {
+			panic(err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	readDB := db
+	if *readDBPath != "" {
+		readDB, err = sql.Open("sqlite3", fmt.Sprintf("file:%s?%s", *readDBPath, cfg.ReadDatabaseOptions))
+		if err != nil {
+			panic(err)
+		}
+		defer readDB.Close()
 	}
 
-	handler, err := front.NewHandler(*domain, *closed, &cfg, resolver, db)
+	handler, err := front.NewHandler(*domain, *closed, &cfg, resolver, db, readDB)
 	if err != nil {
 		panic(err)
 	}
 
-	for _, svc := range []struct {
+	var frontend http.Handler
+	if cfg.EnableHTMLFrontend {
+		frontend = &web.Handler{
+			Domain:  *domain,
+			Config:  &cfg,
+			Handler: handler,
+		}
+	}
+
+	type service struct {
 		Name     string
 		Listener interface {
 			ListenAndServe(context.Context) error
 		}
-	}{
+	}
+
+	services := []service{
 		{
 			"HTTPS",
 			&fed.Listener{
-				Domain:   *domain,
-				Closed:   *closed,
-				Config:   &cfg,
-				DB:       db,
-				ActorKey: nobodyKey,
-				Resolver: resolver,
-				Addr:     *addr,
-				Cert:     *cert,
-				Key:      *key,
-				Plain:    *plain,
+				Domain:    *domain,
+				Closed:    *closed,
+				Config:    &cfg,
+				DB:        db,
+				ActorKey:  nobodyKey,
+				Resolver:  resolver,
+				Addr:      *addr,
+				Cert:      *cert,
+				Key:       *key,
+				ACMECache: *acmeCache,
+				ACMEAddr:  *acmeAddr,
+				Plain:     *plain,
+				Frontend:  frontend,
 			},
 		},
 		{
@@ -351,6 +640,7 @@ func main() {
 				Addr:     *gemAddr,
 				CertPath: *gemCert,
 				KeyPath:  *gemKey,
+				GenCert:  *gemGenCert,
 			},
 		},
 		{
@@ -380,12 +670,27 @@ func main() {
 				Addr:    *guppyAddr,
 			},
 		},
-	} {
+	}
+
+	if *metricsAddr != "" {
+		services = append(services, service{
+			"Metrics",
+			&metrics.Listener{
+				Config: &cfg,
+				DB:     db,
+				Addr:   *metricsAddr,
+			},
+		})
+	}
+
+	for _, svc := range services {
 		wg.Add(1)
 		go func() {
+			activeListeners.Set(1, svc.Name)
 			if err := svc.Listener.ListenAndServe(ctx); err != nil {
 				slog.Error("Listener has failed", "listener", svc.Name, "error", err)
 			}
+			activeListeners.Set(0, svc.Name)
 			cancel()
 			wg.Done()
 		}()
@@ -406,6 +711,7 @@ func main() {
 				DB:        db,
 				Resolver:  resolver,
 				Key:       nobodyKey,
+				Cache:     &handler,
 			},
 		},
 		{
@@ -446,16 +752,27 @@ func main() {
 		},
 		{
 			"poller",
-			pollResultsUpdateInterval,
+			cfg.PollResultsUpdateInterval,
 			&outbox.Poller{
 				Domain: *domain,
 				Config: &cfg,
 				DB:     db,
 			},
 		},
+		{
+			"polls",
+			cfg.PollRefreshInterval,
+			&inbox.PollRefresher{
+				Domain:   *domain,
+				Config:   &cfg,
+				DB:       db,
+				Resolver: resolver,
+				Key:      nobodyKey,
+			},
+		},
 		{
 			"mover",
-			followMoveInterval,
+			cfg.FollowMoveInterval,
 			&outbox.Mover{
 				Domain:   *domain,
 				DB:       db,
@@ -463,9 +780,26 @@ func main() {
 				Key:      nobodyKey,
 			},
 		},
+		{
+			"actors",
+			cfg.ActorUpdateInterval,
+			&outbox.ActorUpdater{
+				Domain: *domain,
+				DB:     db,
+			},
+		},
+		{
+			"expiry",
+			cfg.PostExpiryInterval,
+			&outbox.Expirer{
+				Domain: *domain,
+				Config: &cfg,
+				DB:     db,
+			},
+		},
 		{
 			"sync",
-			followSyncInterval,
+			cfg.FollowersSyncPollingInterval,
 			&fed.Syncer{
 				Domain:   *domain,
 				Config:   &cfg,
@@ -474,39 +808,106 @@ func main() {
 				Key:      nobodyKey,
 			},
 		},
+		{
+			"relme",
+			cfg.LinkVerificationPollingInterval,
+			&fed.LinkVerifier{
+				Domain: *domain,
+				Config: &cfg,
+				DB:     db,
+				Client: &publicClient,
+			},
+		},
+		{
+			"avatars",
+			cfg.AvatarFetchInterval,
+			&fed.AvatarFetcher{
+				Domain: *domain,
+				Config: &cfg,
+				DB:     db,
+				Client: &publicClient,
+			},
+		},
+		{
+			"blocklist",
+			cfg.BlockListUpdateInterval,
+			&fed.BlockListUpdater{
+				BlockList: blockList,
+				DB:        db,
+			},
+		},
 		{
 			"gc",
-			garbageCollectionInterval,
+			cfg.GarbageCollectionInterval,
 			&data.GarbageCollector{
 				Domain: *domain,
 				Config: &cfg,
 				DB:     db,
 			},
 		},
+		{
+			"consistency",
+			cfg.ConsistencyCheckInterval,
+			&data.ConsistencyChecker{
+				Domain: *domain,
+				Config: &cfg,
+				DB:     db,
+			},
+		},
+		{
+			"replicate",
+			cfg.ReplicationInterval,
+			&data.Replicator{
+				Config: &cfg,
+				DB:     db,
+				Path:   *dbPath,
+			},
+		},
+		{
+			"backup",
+			cfg.BackupInterval,
+			&data.Snapshotter{
+				Config: &cfg,
+				DB:     db,
+			},
+		},
+		{
+			"maintain",
+			cfg.MaintenanceInterval,
+			&data.Maintainer{
+				Config: &cfg,
+				DB:     db,
+				Path:   *dbPath,
+			},
+		},
 	} {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			defer cancel()
 
-			t := time.NewTicker(job.Interval)
-			defer t.Stop()
+			// random delays prevent jobs with the same interval from running at the same time
+			delay := rand.N(cfg.JobJitterInterval)
 
 			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case <-time.After(delay):
+				}
+
 				slog.Info("Running periodic job", "job", job.Name)
 				start := time.Now()
 				if err := job.Runner.Run(ctx); err != nil {
 					slog.Error("Periodic job has failed", "job", job.Name, "error", err)
 					break
 				}
-				slog.Info("Done running periodic job", "job", job.Name, "duration", time.Since(start).String())
+				duration := time.Since(start)
+				jobDuration.Observe(duration.Seconds(), job.Name)
+				slog.Info("Done running periodic job", "job", job.Name, "duration", duration.String())
 
-				select {
-				case <-ctx.Done():
-					return
-
-				case <-t.C:
-				}
+				delay = job.Interval + rand.N(cfg.JobJitterInterval)
 			}
 		}()
 	}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/dimkr/tootik/cfg"
+)
+
+// ConsistencyChecker deletes data that belongs to actors that no longer exist, like rows left behind by versions of
+// tootik that didn't delete everything when an actor was deleted.
+type ConsistencyChecker struct {
+	Domain string
+	Config *cfg.Config
+	DB     *sql.DB
+}
+
+// DeleteActor deletes an actor, its posts (leaving tombstones behind), follows, shares and other data associated with it.
+func DeleteActor(ctx context.Context, tx *sql.Tx, id string) error {
+	for _, query := range []string{
+		`insert or ignore into tombstones (id, parent, inserted) select id, object->>'$.inReplyTo', inserted from notes where author = $1`,
+		`delete from notesfts where exists (select 1 from notes where notes.author = $1 and notesfts.id = notes.id)`,
+		`delete from shares where by = $1 or exists (select 1 from notes where notes.author = $1 and notes.id = shares.note)`,
+		`delete from bookmarks where exists (select 1 from notes where notes.author = $1 and notes.id = bookmarks.note)`,
+		`delete from rsvps where actor = $1`,
+		`delete from feed where sharer->>'$.id' = $1 or author->>'$.id' = $1`,
+		`delete from notes where author = $1`,
+		`delete from follows where follower = $1 or followed = $1`,
+		`delete from webfinger where actor = $1`,
+		`delete from avatars where actor = $1`,
+		`delete from persons where id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// Run deletes orphaned rows, in batches of ConsistencyCheckBatchSize rows.
+func (c *ConsistencyChecker) Run(ctx context.Context) error {
+	for _, orphans := range []struct {
+		Table string
+		Query string
+		Args  []any
+	}{
+		{"notesfts", `delete from notesfts where rowid in (select notesfts.rowid from notesfts join notes on notes.id = notesfts.id where notes.host != ? and not exists (select 1 from persons where persons.id = notes.author) limit ?)`, []any{c.Domain}},
+		{"notes", `delete from notes where rowid in (select rowid from notes where host != ? and not exists (select 1 from persons where persons.id = notes.author) limit ?)`, []any{c.Domain}},
+		{"follows", `delete from follows where rowid in (select rowid from follows where not exists (select 1 from persons where persons.id = follows.follower) or not exists (select 1 from persons where persons.id = follows.followed) limit ?)`, nil},
+		{"feed", `delete from feed where rowid in (select rowid from feed where not exists (select 1 from persons where persons.id = feed.author->>'$.id') or (sharer is not null and not exists (select 1 from persons where persons.id = feed.sharer->>'$.id')) limit ?)`, nil},
+		{"shares", `delete from shares where rowid in (select rowid from shares where not exists (select 1 from persons where persons.id = shares.by) limit ?)`, nil},
+		{"rsvps", `delete from rsvps where rowid in (select rowid from rsvps where not exists (select 1 from persons where persons.id = rsvps.actor) limit ?)`, nil},
+		{"webfinger", `delete from webfinger where rowid in (select rowid from webfinger where not exists (select 1 from persons where persons.id = webfinger.actor) limit ?)`, nil},
+		{"avatars", `delete from avatars where rowid in (select rowid from avatars where not exists (select 1 from persons where persons.id = avatars.actor) limit ?)`, nil},
+	} {
+		args := append(orphans.Args, c.Config.ConsistencyCheckBatchSize)
+
+		// each batch is a separate transaction, so the database is not locked for a long time
+		var deleted int64
+		for {
+			res, err := c.DB.ExecContext(ctx, orphans.Query, args...)
+			if err != nil {
+				return fmt.Errorf("failed to delete orphaned rows from %s: %w", orphans.Table, err)
+			}
+
+			n, err := res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to delete orphaned rows from %s: %w", orphans.Table, err)
+			}
+
+			deleted += n
+
+			if n < int64(c.Config.ConsistencyCheckBatchSize) {
+				break
+			}
+		}
+
+		if deleted > 0 {
+			slog.Info("Deleted orphaned rows", "table", orphans.Table, "rows", deleted)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/mattn/go-sqlite3"
+)
+
+const snapshotPrefix = "tootik-"
+
+// Backup copies the database to a new file, using SQLite's online backup API.
+func Backup(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := backup(ctx, db, path); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+func backup(ctx context.Context, db *sql.DB, path string) error {
+	dst, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	srcConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dstDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			dstSQLite, ok := dstDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unsupported connection: %T", dstDriverConn)
+			}
+
+			srcSQLite, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unsupported connection: %T", srcDriverConn)
+			}
+
+			b, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+
+			// copy all pages in one step, so the backup doesn't restart if the database changes
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return fmt.Errorf("failed to back up: %w", err)
+			}
+
+			return b.Finish()
+		})
+	})
+}
+
+// Snapshotter periodically backs up the database and deletes old backups.
+type Snapshotter struct {
+	Config *cfg.Config
+	DB     *sql.DB
+}
+
+func (s *Snapshotter) rotate() error {
+	snapshots, err := filepath.Glob(filepath.Join(s.Config.BackupDirectory, snapshotPrefix+"*.sqlite3"))
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) <= s.Config.MaxBackups {
+		return nil
+	}
+
+	// file names contain the time of the snapshot, so the oldest snapshots come first
+	slices.Sort(snapshots)
+
+	for _, path := range snapshots[:len(snapshots)-s.Config.MaxBackups] {
+		slog.Info("Deleting old backup", "path", path)
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run backs up the database, then deletes old backups.
+func (s *Snapshotter) Run(ctx context.Context) error {
+	if s.Config.BackupDirectory == "" {
+		return nil
+	}
+
+	start := time.Now()
+
+	path := filepath.Join(s.Config.BackupDirectory, snapshotPrefix+start.UTC().Format("20060102150405")+".sqlite3")
+	tmp := path + ".tmp"
+
+	// a failed backup shouldn't stop the server: we report it and try again later
+	if err := Backup(ctx, s.DB, tmp); err != nil {
+		slog.Error("Backup has failed", "path", path, "error", err)
+		return nil
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		slog.Error("Backup has failed", "path", path, "error", err)
+		os.Remove(tmp)
+		return nil
+	}
+
+	slog.Info("Backed up database", "path", path, "duration", time.Since(start).String())
+
+	if err := s.rotate(); err != nil {
+		slog.Warn("Failed to delete old backups", "error", err)
+	}
+
+	return nil
+}
@@ -20,9 +20,16 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/migrations"
 )
 
 type GarbageCollector struct {
@@ -31,63 +38,257 @@ type GarbageCollector struct {
 	DB     *sql.DB
 }
 
-// Collect deletes old data.
+type database interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+// dryRun counts the rows deleted by each statement of a transaction that is never committed.
+type dryRun struct {
+	*sql.Tx
+	deleted map[string]int64
+}
+
+func (d *dryRun) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := d.Tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		// deletions start with "delete from $table"
+		if fields := strings.Fields(query); len(fields) > 2 && fields[0] == "delete" {
+			d.deleted[fields[2]] += n
+		}
+	}
+
+	return res, nil
+}
+
+// usedSpace returns the size of the database, excluding free pages.
+func usedSpace(ctx context.Context, db database) (int64, error) {
+	var pageCount, freePages, pageSize int64
+	if err := db.QueryRowContext(ctx, `select page_count, freelist_count, page_size from pragma_page_count(), pragma_freelist_count(), pragma_page_size()`).Scan(&pageCount, &freePages, &pageSize); err != nil {
+		return 0, err
+	}
+
+	return (pageCount - freePages) * pageSize, nil
+}
+
+// deletePosts deletes the posts returned by a query and adds tombstones for posts with replies, so threads stay
+// connected.
+func deletePosts(ctx context.Context, db database, query string, args ...any) (int64, error) {
+	if _, err := db.ExecContext(ctx, `insert or ignore into tombstones (id, parent, inserted) select id, object->>'$.inReplyTo', inserted from notes where id in (`+query+`) and exists (select 1 from notes replies where replies.object->>'$.inReplyTo' = notes.id)`, args...); err != nil {
+		return 0, err
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from notesfts where id in (`+query+`)`, args...); err != nil {
+		return 0, err
+	}
+
+	res, err := db.ExecContext(ctx, `delete from notes where id in (`+query+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Run deletes old data.
 func (gc *GarbageCollector) Run(ctx context.Context) error {
-	now := time.Now()
+	return gc.collect(ctx, gc.DB)
+}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from notesfts where id in (select notes.id from notes left join follows on follows.followed in (notes.author, notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or (notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = follows.followed)) or (notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = follows.followed)) where follows.accepted = 1 and notes.inserted < $1 and notes.host != $2 and follows.id is null and not exists (select 1 from bookmarks where bookmarks.note = notesfts.id))`, now.Add(-gc.Config.InvisiblePostsTTL).Unix(), gc.Domain); err != nil {
-		return fmt.Errorf("failed to remove invisible posts: %w", err)
+// DryRun prints how many rows [GarbageCollector.Run] would delete from each table and how much space it would free.
+// It runs garbage collection on a temporary copy of the database, so the database is only read.
+func (gc *GarbageCollector) DryRun(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "tootik-gc-*")
+	if err != nil {
+		return err
 	}
+	defer os.RemoveAll(dir)
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from notes where id in (select notes.id from notes left join follows on follows.followed in (notes.author, notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or (notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = follows.followed)) or (notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = follows.followed)) where follows.accepted = 1 and notes.inserted < $1 and notes.host != $2 and follows.id is null and not exists (select 1 from bookmarks where bookmarks.note = notes.id))`, now.Add(-gc.Config.InvisiblePostsTTL).Unix(), gc.Domain); err != nil {
-		return fmt.Errorf("failed to remove invisible posts: %w", err)
+	path := filepath.Join(dir, "db.sqlite3")
+	if err := backup(ctx, gc.DB, path); err != nil {
+		return fmt.Errorf("failed to copy the database: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from notesfts where id in (select id from notes where inserted < $1 and author not in (select followed from follows where accepted = 1) and host != $2 and not exists (select 1 from bookmarks where bookmarks.note = notes.id))`, now.Add(-gc.Config.InvisiblePostsTTL).Unix(), gc.Domain); err != nil {
-		return fmt.Errorf("failed to remove posts by authors without followers: %w", err)
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrations.Run(ctx, gc.Domain, db); err != nil {
+		return fmt.Errorf("failed to migrate the copied database: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := usedSpace(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	d := dryRun{Tx: tx, deleted: map[string]int64{}}
+	if err := gc.collect(ctx, &d); err != nil {
+		return err
+	}
+
+	after, err := usedSpace(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	tables := make([]string, 0, len(d.deleted))
+	for table := range d.deleted {
+		tables = append(tables, table)
+	}
+	slices.Sort(tables)
+
+	for _, table := range tables {
+		fmt.Fprintf(w, "Would delete %d rows from %s\n", d.deleted[table], table)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from notes where inserted < $1 and author not in (select followed from follows where accepted = 1) and host != $2 and not exists (select 1 from bookmarks where bookmarks.note = notes.id)`, now.Add(-gc.Config.InvisiblePostsTTL).Unix(), gc.Domain); err != nil {
+	fmt.Fprintf(w, "Would free %d bytes\n", max(0, before-after))
+	return nil
+}
+
+// evict deletes the oldest posts by federated users until the database is smaller than MaxDatabaseSize, or until
+// MaxEvictionBatches batches are deleted.
+func (gc *GarbageCollector) evict(ctx context.Context, db database) error {
+	for range gc.Config.MaxEvictionBatches {
+		size, err := usedSpace(ctx, db)
+		if err != nil {
+			return fmt.Errorf("failed to get database size: %w", err)
+		}
+
+		if size <= gc.Config.MaxDatabaseSize {
+			return nil
+		}
+
+		// posts bookmarked or replied to by local users, and posts pinned in a community, are not evicted
+		evictable := `select notes.id from notes where notes.host != $1 and not exists (select 1 from bookmarks where bookmarks.note = notes.id) and not exists (select 1 from communities where communities.pinned = notes.id) and not exists (select 1 from notes replies where replies.object->>'$.inReplyTo' = notes.id and replies.host = $1) order by notes.inserted limit $2`
+
+		if n, err := deletePosts(ctx, db, evictable, gc.Domain, gc.Config.EvictionBatchSize); err != nil {
+			return fmt.Errorf("failed to evict posts: %w", err)
+		} else if n == 0 {
+			slog.Warn("Database is too big but there are no posts to evict", "size", size, "max", gc.Config.MaxDatabaseSize)
+			return nil
+		}
+	}
+
+	slog.Warn("Database is still too big after eviction", "batches", gc.Config.MaxEvictionBatches)
+	return nil
+}
+
+func (gc *GarbageCollector) collect(ctx context.Context, db database) error {
+	now := time.Now()
+
+	invisible := `select notes.id from notes left join follows on follows.followed in (notes.author, notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or (notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = follows.followed)) or (notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = follows.followed)) where follows.accepted = 1 and notes.inserted < $1 and notes.host != $2 and follows.id is null and not exists (select 1 from bookmarks where bookmarks.note = notes.id)`
+	if _, err := deletePosts(ctx, db, invisible, now.Add(-gc.Config.InvisiblePostsTTL).Unix(), gc.Domain); err != nil {
+		return fmt.Errorf("failed to remove invisible posts: %w", err)
+	}
+
+	unfollowed := `select id from notes where inserted < $1 and author not in (select followed from follows where accepted = 1) and host != $2 and not exists (select 1 from bookmarks where bookmarks.note = notes.id)`
+	if _, err := deletePosts(ctx, db, unfollowed, now.Add(-gc.Config.InvisiblePostsTTL).Unix(), gc.Domain); err != nil {
 		return fmt.Errorf("failed to remove posts by authors without followers: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from notesfts where id in (select id from notes where inserted < ? and host != ? and not exists (select 1 from bookmarks where bookmarks.note = notes.id))`, now.Add(-gc.Config.NotesTTL).Unix(), gc.Domain); err != nil {
+	if _, err := deletePosts(ctx, db, `select id from notes where inserted < $1 and host != $2 and not exists (select 1 from bookmarks where bookmarks.note = notes.id)`, now.Add(-gc.Config.NotesTTL).Unix(), gc.Domain); err != nil {
 		return fmt.Errorf("failed to remove old posts: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from notes where inserted < ? and host != ? and not exists (select 1 from bookmarks where bookmarks.note = notes.id)`, now.Add(-gc.Config.NotesTTL).Unix(), gc.Domain); err != nil {
-		return fmt.Errorf("failed to remove old posts: %w", err)
+	// RemotePostsTTL is disabled by default and can delete posts sooner than NotesTTL, but bookmarked posts are kept
+	if gc.Config.RemotePostsTTL > 0 {
+		if _, err := deletePosts(ctx, db, `select id from notes where inserted < $1 and host != $2 and not exists (select 1 from bookmarks where bookmarks.note = notes.id)`, now.Add(-gc.Config.RemotePostsTTL).Unix(), gc.Domain); err != nil {
+			return fmt.Errorf("failed to remove expired posts: %w", err)
+		}
+	}
+
+	if gc.Config.MaxDatabaseSize > 0 {
+		if err := gc.evict(ctx, db); err != nil {
+			return err
+		}
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from hashtags where not exists (select 1 from notes where notes.id = hashtags.note)`); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from hashtags where not exists (select 1 from notes where notes.id = hashtags.note)`); err != nil {
 		return fmt.Errorf("failed to remove old hashtags: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from shares where not exists (select 1 from persons where persons.id = shares.by) or (inserted < ? and not exists (select 1 from notes where notes.id = shares.note))`, now.Add(-gc.Config.SharesTTL).Unix()); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from filters where expires < unixepoch()`); err != nil {
+		return fmt.Errorf("failed to remove expired filters: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from gophertokens where inserted < ?`, now.Add(-gc.Config.GopherTokenTTL).Unix()); err != nil {
+		return fmt.Errorf("failed to remove expired Gopher tokens: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from translations where not exists (select 1 from notes where notes.id = translations.note and notes.updated = translations.updated)`); err != nil {
+		return fmt.Errorf("failed to remove old translations: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from pollrefreshes where not exists (select 1 from notes where notes.id = pollrefreshes.poll)`); err != nil {
+		return fmt.Errorf("failed to remove poll refresh attempts: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from rsvps where not exists (select 1 from notes where notes.id = rsvps.event) or not exists (select 1 from persons where persons.id = rsvps.actor)`); err != nil {
+		return fmt.Errorf("failed to remove old RSVPs: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from shares where not exists (select 1 from persons where persons.id = shares.by) or (inserted < ? and not exists (select 1 from notes where notes.id = shares.note))`, now.Add(-gc.Config.SharesTTL).Unix()); err != nil {
 		return fmt.Errorf("failed to remove old shares: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from outbox where inserted < ? and host != ?`, now.Add(-gc.Config.DeliveryTTL).Unix(), gc.Domain); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from outbox where inserted < ? and host != ?`, now.Add(-gc.Config.DeliveryTTL).Unix(), gc.Domain); err != nil {
 		return fmt.Errorf("failed to remove old posts: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from follows where accepted = 0 and inserted < ?`, now.Add(-gc.Config.FollowAcceptTimeout).Unix()); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from retries where last < ?`, now.Add(-gc.Config.DeliveryTTL).Unix()); err != nil {
+		return fmt.Errorf("failed to remove old delivery retries: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from deliverylog where updated < ?`, now.Add(-gc.Config.DeliveryTTL).Unix()); err != nil {
+		return fmt.Errorf("failed to remove old delivery log entries: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from undelivered where inserted < ?`, now.Add(-gc.Config.DeliveryTTL).Unix()); err != nil {
+		return fmt.Errorf("failed to remove old undelivered activities: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from follows where accepted = 0 and inserted < ?`, now.Add(-gc.Config.FollowAcceptTimeout).Unix()); err != nil {
 		return fmt.Errorf("failed to remove failed follow requests: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from persons where updated < ? and host != ? and not exists (select 1 from follows where followed = persons.id) and not exists (select 1 from follows where follower = persons.id) and not exists (select 1 from notes where notes.author = persons.id) and not exists (select 1 from shares where shares.by = persons.id)`, now.Add(-gc.Config.ActorTTL).Unix(), gc.Domain); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from persons where updated < ? and host != ? and not exists (select 1 from follows where followed = persons.id) and not exists (select 1 from follows where follower = persons.id) and not exists (select 1 from notes where notes.author = persons.id) and not exists (select 1 from shares where shares.by = persons.id)`, now.Add(-gc.Config.ActorTTL).Unix(), gc.Domain); err != nil {
 		return fmt.Errorf("failed to remove idle actors: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from feed where inserted < ?`, now.Add(-gc.Config.FeedTTL).Unix()); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from webfinger where updated < ?`, now.Add(-gc.Config.WebFingerCacheTTL).Unix()); err != nil {
+		return fmt.Errorf("failed to remove old WebFinger cache entries: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from seen where inserted < ?`, now.Add(-gc.Config.InboxDeduplicationWindow).Unix()); err != nil {
+		return fmt.Errorf("failed to remove old processed activities: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from feed where inserted < ?`, now.Add(-gc.Config.FeedTTL).Unix()); err != nil {
 		return fmt.Errorf("failed to trim feed: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from bookmarks where not exists (select 1 from persons where persons.id = bookmarks.by)`); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from tombstones where deleted < ?`, now.Add(-gc.Config.TombstonesTTL).Unix()); err != nil {
+		return fmt.Errorf("failed to remove old tombstones: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `delete from bookmarks where not exists (select 1 from persons where persons.id = bookmarks.by)`); err != nil {
 		return fmt.Errorf("failed to remove bookmarks by deleted users: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(
+	if _, err := db.ExecContext(
 		ctx,
 		`delete from bookmarks where not exists (
 			select 1 from notes
@@ -106,11 +307,11 @@ func (gc *GarbageCollector) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to invisible bookmarks: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from certificates where approved = 0 and inserted < ?`, now.Add(-gc.Config.CertificateApprovalTimeout).Unix()); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from certificates where approved = 0 and inserted < ?`, now.Add(-gc.Config.CertificateApprovalTimeout).Unix()); err != nil {
 		return fmt.Errorf("failed to remove timed out certificate approval requests: %w", err)
 	}
 
-	if _, err := gc.DB.ExecContext(ctx, `delete from certificates where expires < unixepoch()`); err != nil {
+	if _, err := db.ExecContext(ctx, `delete from certificates where expires < unixepoch()`); err != nil {
 		return fmt.Errorf("failed to remove expired certificates: %w", err)
 	}
 
@@ -0,0 +1,111 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/metrics"
+)
+
+// Maintainer truncates the WAL and updates query planner statistics.
+type Maintainer struct {
+	Config *cfg.Config
+	DB     *sql.DB
+	Path   string
+}
+
+var databaseSize = metrics.NewGauge("tootik_database_size_bytes", "Size of the database and WAL files", "file")
+
+func (m *Maintainer) walSize() int64 {
+	if m.Path == "" {
+		return 0
+	}
+
+	info, err := os.Stat(m.Path + "-wal")
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0
+	} else if err != nil {
+		slog.Warn("Failed to get WAL size", "error", err)
+		return 0
+	}
+
+	return info.Size()
+}
+
+// Run runs maintenance tasks and logs the database size.
+func (m *Maintainer) Run(ctx context.Context) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	walBefore := m.walSize()
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA optimize`); err != nil {
+		return fmt.Errorf("failed to optimize: %w", err)
+	}
+
+	// the WAL is truncated last, after optimization has written to it
+	var busy, log, checkpointed int
+	if err := conn.QueryRowContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &log, &checkpointed); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+
+	// a busy database is not an error: the WAL is truncated next time
+	if busy == 1 {
+		slog.Warn("Failed to truncate WAL while database is busy", "log", log, "checkpointed", checkpointed)
+	}
+
+	var pageCount, pageSize, freePages int64
+	if err := conn.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return fmt.Errorf("failed to get page count: %w", err)
+	}
+	if err := conn.QueryRowContext(ctx, `PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return fmt.Errorf("failed to get page size: %w", err)
+	}
+	if err := conn.QueryRowContext(ctx, `PRAGMA freelist_count`).Scan(&freePages); err != nil {
+		return fmt.Errorf("failed to get free pages count: %w", err)
+	}
+
+	size := pageCount * pageSize
+	wal := m.walSize()
+
+	databaseSize.Set(float64(size), "db")
+	databaseSize.Set(float64(wal), "wal")
+
+	slog.Info(
+		"Maintained database",
+		"duration", time.Since(start).String(),
+		"size", size,
+		"free", freePages*pageSize,
+		"wal_before", walBefore,
+		"wal", wal,
+	)
+
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+)
+
+// Replicator runs an external replication command at a consistent point in time.
+//
+// While the command runs, the database is locked for writing, so the database
+// file and the WAL file can be copied safely. Once the command succeeds, the
+// WAL is checkpointed and the health file (if any) is touched.
+type Replicator struct {
+	Config *cfg.Config
+	DB     *sql.DB
+	Path   string
+}
+
+func (r *Replicator) replicate(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return fmt.Errorf("failed to lock database: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `ROLLBACK`)
+
+	cmdCtx, cancel := context.WithTimeout(ctx, r.Config.ReplicationTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, r.Config.ReplicationCommand[0], r.Config.ReplicationCommand[1:]...)
+	cmd.Env = append(os.Environ(), "TOOTIK_DB="+r.Path, "TOOTIK_WAL="+r.Path+"-wal")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", r.Config.ReplicationCommand[0], err)
+	}
+
+	return nil
+}
+
+// Run runs the replication command, then checkpoints the WAL.
+func (r *Replicator) Run(ctx context.Context) error {
+	if len(r.Config.ReplicationCommand) == 0 {
+		return nil
+	}
+
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+
+	// a failed replication attempt shouldn't stop the server: we report it and try again later
+	if err := r.replicate(ctx, conn); err != nil {
+		slog.Error("Replication has failed", "error", err)
+		return nil
+	}
+
+	var busy, log, checkpointed int
+	if err := conn.QueryRowContext(ctx, `PRAGMA wal_checkpoint(PASSIVE)`).Scan(&busy, &log, &checkpointed); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+
+	slog.Info("Replicated database", "duration", time.Since(start).String(), "busy", busy, "log", log, "checkpointed", checkpointed)
+
+	if r.Config.ReplicationHealthFile == "" {
+		return nil
+	}
+
+	if err := os.WriteFile(r.Config.ReplicationHealthFile, []byte(strconv.FormatInt(time.Now().Unix(), 10)+"\n"), 0o644); err != nil {
+		slog.Warn("Failed to update replication health file", "error", err)
+	}
+
+	return nil
+}
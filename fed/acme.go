@@ -0,0 +1,68 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newCertManager returns a [autocert.Manager] that obtains and renews the HTTPS certificate.
+func (l *Listener) newCertManager() *autocert.Manager {
+	host := l.Domain
+	if h, _, err := net.SplitHostPort(l.Domain); err == nil {
+		host = h
+	}
+
+	m := autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(l.ACMECache),
+		HostPolicy: autocert.HostWhitelist(host),
+		Email:      l.Config.ACMEEmail,
+	}
+
+	if l.Config.ACMEDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: l.Config.ACMEDirectoryURL}
+	}
+
+	return &m
+}
+
+// serveChallenges responds to HTTP-01 challenges and redirects all other requests to HTTPS.
+func (l *Listener) serveChallenges(ctx context.Context, m *autocert.Manager) {
+	server := http.Server{
+		Addr:        l.ACMEAddr,
+		Handler:     m.HTTPHandler(nil),
+		ReadTimeout: time.Second * 30,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Warn("Failed to serve ACME challenges", "error", err)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACME_HostPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	l := Listener{
+		Domain:    "localhost.localdomain:8443",
+		Config:    &cfg,
+		ACMECache: t.TempDir(),
+	}
+
+	m := l.newCertManager()
+	assert.NoError(m.HostPolicy(context.Background(), "localhost.localdomain"))
+	assert.Error(m.HostPolicy(context.Background(), "other.localdomain"))
+	assert.Nil(m.Client)
+
+	cfg.ACMEDirectoryURL = "https://acme.localdomain/directory"
+	assert.Equal("https://acme.localdomain/directory", l.newCertManager().Client.DirectoryURL)
+}
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestACME_Challenges(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "db.sqlite3")+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	l := Listener{
+		Domain:    "localhost.localdomain",
+		Config:    &cfg,
+		DB:        db,
+		Addr:      freeAddr(t),
+		ACMECache: filepath.Join(dir, "acme"),
+		ACMEAddr:  freeAddr(t),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- l.ListenAndServe(ctx)
+	}()
+
+	client := http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	get := func(path string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, "http://"+l.ACMEAddr+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Host = "localhost.localdomain"
+		return client.Do(req)
+	}
+
+	var resp *http.Response
+	for range 50 {
+		resp, err = get("/.well-known/nodeinfo")
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	assert.NoError(err)
+	resp.Body.Close()
+
+	// requests that aren't challenges are redirected to HTTPS
+	assert.Equal(http.StatusFound, resp.StatusCode)
+	assert.Equal("https://localhost.localdomain/.well-known/nodeinfo", resp.Header.Get("Location"))
+
+	// unknown challenges are rejected
+	resp, err = get("/.well-known/acme-challenge/x")
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	cancel()
+	assert.NoError(<-done)
+}
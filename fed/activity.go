@@ -28,6 +28,10 @@ import (
 )
 
 func (l *Listener) handleActivity(w http.ResponseWriter, r *http.Request, prefix string) {
+	if !l.authorizeFetch(w, r) {
+		return
+	}
+
 	activityID := fmt.Sprintf("https://%s/%s/%s", l.Domain, prefix, r.PathValue("hash"))
 
 	slog.Info("Fetching activity", "activity", activityID)
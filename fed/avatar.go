@@ -0,0 +1,243 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/icon"
+)
+
+// AvatarFetcher caches avatars of local and remote users in multiple sizes.
+//
+// Remote avatars are fetched again when the URL changes. If fetching or decoding fails, the avatar is not fetched
+// again until the URL changes. Remote avatars are fetched only from the actor's server or its subdomains, and Client
+// should refuse to connect to private addresses.
+type AvatarFetcher struct {
+	Domain string
+	Config *cfg.Config
+	DB     *sql.DB
+	Client Client
+
+	// since is the last update time of actors checked by the previous run
+	since int64
+}
+
+type pendingAvatar struct {
+	ID, Host, Name, URL string
+}
+
+func (f *AvatarFetcher) fetch(ctx context.Context, avatarURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.Config.AvatarFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, avatarURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %d", avatarURL, resp.StatusCode)
+	}
+
+	if resp.ContentLength > f.Config.MaxAvatarSize {
+		return nil, fmt.Errorf("failed to fetch %s: avatar is too big", avatarURL)
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, f.Config.MaxAvatarSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", avatarURL, err)
+	}
+
+	if int64(len(buf)) > f.Config.MaxAvatarSize {
+		return nil, fmt.Errorf("failed to fetch %s: avatar is too big", avatarURL)
+	}
+
+	return buf, nil
+}
+
+func (f *AvatarFetcher) load(ctx context.Context, avatar *pendingAvatar) ([]byte, error) {
+	if avatar.Host != f.Domain {
+		u, err := url.Parse(avatar.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid avatar URL: %s", avatar.URL)
+		}
+
+		if host := u.Hostname(); host != avatar.Host && !strings.HasSuffix(host, "."+avatar.Host) {
+			return nil, fmt.Errorf("invalid avatar host: %s", host)
+		}
+
+		return f.fetch(ctx, avatar.URL)
+	}
+
+	// local avatars are either uploaded or generated
+	var buf []byte
+	if err := f.DB.QueryRowContext(ctx, `SELECT buf FROM icons WHERE name = ?`, avatar.Name).Scan(&buf); errors.Is(err, sql.ErrNoRows) {
+		return icon.Generate(avatar.Name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func (f *AvatarFetcher) save(ctx context.Context, avatar *pendingAvatar, variants map[int][]byte) error {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(avatar.ID)))
+
+	tx, err := f.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO avatars(actor, hash, url) VALUES($1, $2, $3) ON CONFLICT(actor) DO UPDATE SET url = $3, fetched = UNIXEPOCH()`,
+		avatar.ID,
+		hash,
+		avatar.URL,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM avatarvariants WHERE hash = ?`, hash); err != nil {
+		return err
+	}
+
+	for size, buf := range variants {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO avatarvariants(hash, size, buf) VALUES(?, ?, ?)`, hash, size, buf); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Run fetches a batch of new or changed avatars and deletes avatars of deleted users.
+func (f *AvatarFetcher) Run(ctx context.Context) error {
+	// only actors updated since the previous run can have a new avatar
+	rows, err := f.DB.QueryContext(
+		ctx,
+		`SELECT id, host, actor->>'$.preferredUsername', url, updated FROM
+		(
+			SELECT id, host, actor, updated, COALESCE(actor->>'$.icon[0].url', actor->>'$.icon.url') AS url FROM persons
+			WHERE updated >= ?
+		) pending
+		WHERE
+			url IS NOT NULL AND
+			NOT EXISTS (SELECT 1 FROM avatars WHERE avatars.actor = pending.id AND avatars.url = pending.url)
+		ORDER BY updated
+		LIMIT ?`,
+		f.since,
+		f.Config.AvatarFetchBatchSize,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fetch avatars to cache: %w", err)
+	}
+
+	var pending []pendingAvatar
+	since := f.since
+	for rows.Next() {
+		var avatar pendingAvatar
+		var updated int64
+		if err := rows.Scan(&avatar.ID, &avatar.Host, &avatar.Name, &avatar.URL, &updated); err != nil {
+			slog.Warn("Failed to scan avatar", "error", err)
+			continue
+		}
+		pending = append(pending, avatar)
+		since = max(since, updated)
+	}
+	rows.Close()
+
+	for _, avatar := range pending {
+		var variants map[int][]byte
+		if buf, err := f.load(ctx, &avatar); err != nil {
+			slog.Info("Failed to fetch avatar", "actor", avatar.ID, "url", avatar.URL, "error", err)
+		} else if variants, err = icon.Variants(f.Config, buf); err != nil {
+			slog.Info("Failed to scale avatar", "actor", avatar.ID, "url", avatar.URL, "error", err)
+		}
+
+		if err := f.save(ctx, &avatar, variants); err != nil {
+			return fmt.Errorf("failed to save avatar of %s: %w", avatar.ID, err)
+		}
+	}
+
+	f.since = since
+
+	if _, err := f.DB.ExecContext(ctx, `DELETE FROM avatars WHERE NOT EXISTS (SELECT 1 FROM persons WHERE persons.id = avatars.actor)`); err != nil {
+		return fmt.Errorf("failed to delete avatars: %w", err)
+	}
+
+	if _, err := f.DB.ExecContext(ctx, `DELETE FROM avatarvariants WHERE NOT EXISTS (SELECT 1 FROM avatars WHERE avatars.hash = avatarvariants.hash)`); err != nil {
+		return fmt.Errorf("failed to delete avatars: %w", err)
+	}
+
+	return nil
+}
+
+func (l *Listener) handleAvatar(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.Atoi(r.PathValue("size"))
+	if err != nil || !slices.Contains(icon.Sizes[:], size) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	hash, ok := strings.CutSuffix(r.PathValue("hash"), icon.FileNameExtension)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var buf []byte
+	var fetched int64
+	if err := l.DB.QueryRowContext(r.Context(), `SELECT avatarvariants.buf, avatars.fetched FROM avatarvariants JOIN avatars ON avatars.hash = avatarvariants.hash WHERE avatarvariants.hash = ? AND avatarvariants.size = ?`, hash, size).Scan(&buf, &fetched); errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.Warn("Failed to get cached avatar", "hash", hash, "size", size, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeConditional(w, r, icon.MediaType, buf, time.Unix(fetched, 0))
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvatar_LocalAndRemote(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, _, err = user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	for _, name := range []string{"dan", "eve"} {
+		_, err = db.Exec(
+			`insert into persons (id, actor) values(?,?)`,
+			"https://0.0.0.0/user/"+name,
+			fmt.Sprintf(`{"type":"Person","id":"https://0.0.0.0/user/%s","preferredUsername":"%s","icon":{"type":"Image","url":"https://0.0.0.0/avatars/%s.png"}}`, name, name, name),
+		)
+		assert.NoError(err)
+	}
+
+	// avatars on other servers are not fetched
+	_, err = db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://0.0.0.0/user/frank",
+		`{"type":"Person","id":"https://0.0.0.0/user/frank","preferredUsername":"frank","icon":{"type":"Image","url":"https://127.0.0.1/avatars/frank.png"}}`,
+	)
+	assert.NoError(err)
+
+	m := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for x := range 300 {
+		for y := range 200 {
+			m.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(png.Encode(&buf, m))
+
+	client := newTestClient(map[string]testResponse{
+		"https://0.0.0.0/avatars/dan.png": {
+			Response: newTestResponse(http.StatusOK, buf.String()),
+		},
+		"https://0.0.0.0/avatars/eve.png": {
+			Response: newTestResponse(http.StatusNotFound, ""),
+		},
+	})
+
+	fetcher := AvatarFetcher{
+		Domain: "localhost.localdomain",
+		Config: &cfg,
+		DB:     db,
+		Client: &client,
+	}
+
+	assert.NoError(fetcher.Run(context.Background()))
+	assert.Empty(client.Data)
+
+	var count int
+	assert.NoError(db.QueryRow(`select count(*) from avatars`).Scan(&count))
+	assert.Equal(4, count)
+
+	assert.NoError(db.QueryRow(`select count(*) from avatarvariants`).Scan(&count))
+	assert.Equal(6, count)
+
+	// avatars that failed to fetch are not fetched again until the URL changes
+	assert.NoError(fetcher.Run(context.Background()))
+
+	l := Listener{
+		Domain: "localhost.localdomain",
+		Config: &cfg,
+		DB:     db,
+	}
+
+	get := func(size int, actorID string) *httptest.ResponseRecorder {
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(actorID)))
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost.localdomain/avatar/%d/%s.gif", size, hash), nil)
+		req.SetPathValue("size", fmt.Sprint(size))
+		req.SetPathValue("hash", hash+".gif")
+
+		w := httptest.NewRecorder()
+		l.handleAvatar(w, req)
+		return w
+	}
+
+	for _, size := range []int{48, 192} {
+		resp := get(size, "https://0.0.0.0/user/dan")
+		assert.Equal(http.StatusOK, resp.Code)
+
+		dim, err := gif.DecodeConfig(resp.Body)
+		assert.NoError(err)
+		assert.Equal(size, dim.Width)
+		assert.Equal(size, dim.Height)
+	}
+
+	assert.Equal(http.StatusOK, get(96, "https://localhost.localdomain/user/alice").Code)
+	assert.Equal(http.StatusNotFound, get(96, "https://0.0.0.0/user/eve").Code)
+	assert.Equal(http.StatusNotFound, get(100, "https://0.0.0.0/user/dan").Code)
+
+	_, err = db.Exec(`update persons set actor = json_set(actor, '$.icon.url', 'https://0.0.0.0/avatars/eve2.png'), updated = unixepoch() where id = 'https://0.0.0.0/user/eve'`)
+	assert.NoError(err)
+
+	client.Data["https://0.0.0.0/avatars/eve2.png"] = testResponse{
+		Response: newTestResponse(http.StatusOK, buf.String()),
+	}
+
+	assert.NoError(fetcher.Run(context.Background()))
+	assert.Empty(client.Data)
+
+	assert.Equal(http.StatusOK, get(96, "https://0.0.0.0/user/eve").Code)
+
+	_, err = db.Exec(`delete from persons where id = 'https://0.0.0.0/user/dan'`)
+	assert.NoError(err)
+
+	assert.NoError(fetcher.Run(context.Background()))
+
+	assert.Equal(http.StatusNotFound, get(96, "https://0.0.0.0/user/dan").Code)
+}
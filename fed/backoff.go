@@ -0,0 +1,117 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// retryDue is true if a failed delivery can be retried: the delay after each failed attempt is twice the previous
+	// one, plus up to 50% to avoid retrying many deliveries at once, and doesn't end before Retry-After
+	retryDue = `retries.last + cast(? * (1 << min(retries.attempts - 1, 16)) * (1 + retries.jitter) as integer) <= unixepoch() and retries.notbefore <= unixepoch()`
+
+	maxRetryAfter = time.Hour * 24
+)
+
+// parseRetryAfter parses the Retry-After header, which contains either a number of seconds or a date.
+func parseRetryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0
+	}
+
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+
+	var d time.Duration
+	if sec, err := strconv.ParseInt(header, 10, 64); err == nil {
+		d = time.Duration(sec) * time.Second
+	} else if t, err := http.ParseTime(header); err == nil {
+		d = t.Sub(now)
+	} else {
+		return 0
+	}
+
+	return max(0, min(d, maxRetryAfter))
+}
+
+// isDue determines whether a delivery attempt is due, after previous attempts to deliver the same activity to the same
+// inbox have failed, and whether delivery to this inbox has failed too many times and should be given up.
+func (q *Queue) isDue(ctx context.Context, task deliveryTask) (bool, bool, error) {
+	var due, exhausted bool
+	if err := q.DB.QueryRowContext(
+		ctx,
+		`select `+retryDue+`, attempts >= ? from retries where activity = ? and inbox = ?`,
+		q.Config.DeliveryRetryInterval,
+		q.Config.MaxDeliveryAttempts,
+		task.Job.Activity.ID,
+		task.Inbox,
+	).Scan(&due, &exhausted); errors.Is(err, sql.ErrNoRows) {
+		return true, false, nil
+	} else if err != nil {
+		return false, false, err
+	}
+
+	return due, exhausted, nil
+}
+
+func (q *Queue) scheduleRetry(ctx context.Context, task deliveryTask, retryAfter time.Duration) {
+	if _, err := q.DB.ExecContext(
+		ctx,
+		`insert into retries(activity, inbox, attempts, last, jitter, notbefore) values($1, $2, 1, unixepoch(), $3, unixepoch() + $4) on conflict(activity, inbox) do update set attempts = attempts + 1, last = unixepoch(), notbefore = unixepoch() + $4`,
+		task.Job.Activity.ID,
+		task.Inbox,
+		rand.Float64()/2,
+		int64(retryAfter/time.Second),
+	); err != nil {
+		slog.Error("Failed to schedule delivery retry", "activity", task.Job.Activity.ID, "inbox", task.Inbox, "error", err)
+	}
+}
+
+func (q *Queue) cancelRetry(ctx context.Context, task deliveryTask) {
+	if _, err := q.DB.ExecContext(
+		ctx,
+		`delete from retries where activity = ? and inbox = ?`,
+		task.Job.Activity.ID,
+		task.Inbox,
+	); err != nil {
+		slog.Error("Failed to remove delivery retry", "activity", task.Job.Activity.ID, "inbox", task.Inbox, "error", err)
+	}
+}
+
+// deferRetry postpones delivery to an inbox by DeliveryRetryInterval, without counting a failed attempt.
+func (q *Queue) deferRetry(ctx context.Context, task deliveryTask) {
+	if _, err := q.DB.ExecContext(
+		ctx,
+		`insert into retries(activity, inbox, attempts, last, jitter, notbefore) values($1, $2, 0, unixepoch(), $3, unixepoch() + $4) on conflict(activity, inbox) do update set notbefore = unixepoch() + $4`,
+		task.Job.Activity.ID,
+		task.Inbox,
+		rand.Float64()/2,
+		q.Config.DeliveryRetryInterval,
+	); err != nil {
+		slog.Error("Failed to defer delivery", "activity", task.Job.Activity.ID, "inbox", task.Inbox, "error", err)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_ParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	resp := func(status int, retryAfter string) *http.Response {
+		r := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			r.Header.Set("Retry-After", retryAfter)
+		}
+		return r
+	}
+
+	assert.Equal(time.Duration(0), parseRetryAfter(nil, now))
+	assert.Equal(time.Duration(0), parseRetryAfter(resp(http.StatusTooManyRequests, ""), now))
+	assert.Equal(time.Minute*2, parseRetryAfter(resp(http.StatusTooManyRequests, "120"), now))
+	assert.Equal(time.Hour, parseRetryAfter(resp(http.StatusServiceUnavailable, "Wed, 01 Jan 2025 01:00:00 GMT"), now))
+	assert.Equal(time.Duration(0), parseRetryAfter(resp(http.StatusServiceUnavailable, "Tue, 31 Dec 2024 23:00:00 GMT"), now))
+	assert.Equal(maxRetryAfter, parseRetryAfter(resp(http.StatusTooManyRequests, "9999999"), now))
+	assert.Equal(time.Duration(0), parseRetryAfter(resp(http.StatusTooManyRequests, "soon"), now))
+	assert.Equal(time.Duration(0), parseRetryAfter(resp(http.StatusInternalServerError, "120"), now))
+}
+
+func TestBackoff_Exponential(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	var attempts int
+	assert.NoError(db.QueryRow(`select attempts from retries where activity = 'https://localhost.localdomain/create/1' and inbox = 'https://ip6-allnodes/inbox/dan'`).Scan(&attempts))
+	assert.Equal(1, attempts)
+
+	client.Data = map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	}
+
+	// the first retry is due after DeliveryRetryInterval
+	assert.NoError(q.process(context.Background()))
+	assert.Len(client.Data, 1)
+
+	_, err := db.Exec(`update retries set last = last - ?`, q.Config.DeliveryRetryInterval*3/2)
+	assert.NoError(err)
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	assert.NoError(db.QueryRow(`select attempts from retries`).Scan(&attempts))
+	assert.Equal(2, attempts)
+
+	client.Data = map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	}
+
+	// the second retry is due after twice as much time
+	_, err = db.Exec(`update retries set last = last - ?`, q.Config.DeliveryRetryInterval*3/2)
+	assert.NoError(err)
+
+	assert.NoError(q.process(context.Background()))
+	assert.Len(client.Data, 1)
+
+	_, err = db.Exec(`update retries set last = last - ?`, q.Config.DeliveryRetryInterval*3/2)
+	assert.NoError(err)
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	var count int
+	assert.NoError(db.QueryRow(`select count(*) from retries`).Scan(&count))
+	assert.Equal(0, count)
+}
+
+func TestBackoff_RetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": []string{"3600"}},
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	q.Config.DeliveryRetryInterval = 0
+
+	client.Data = map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	}
+
+	assert.NoError(q.process(context.Background()))
+	assert.Len(client.Data, 1)
+
+	_, err := db.Exec(`update retries set notbefore = notbefore - 3600`)
+	assert.NoError(err)
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	var sent int
+	assert.NoError(db.QueryRow(`select sent from outbox`).Scan(&sent))
+	assert.Equal(1, sent)
+}
+
+func TestBackoff_RetryNotDue(t *testing.T) {
+	assert := assert.New(t)
+
+	failure := func() testResponse {
+		return testResponse{
+			Response: &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		}
+	}
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan":  failure(),
+		"https://ip6-allnodes/inbox/erin": failure(),
+	})
+
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	q.Config.MaxDeliveryAttempts = 2
+
+	_, err := db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://ip6-allnodes/user/erin",
+		`{"type":"Person","id":"https://ip6-allnodes/user/erin","preferredUsername":"erin","inbox":"https://ip6-allnodes/inbox/erin"}`,
+	)
+	assert.NoError(err)
+
+	_, err = db.Exec(`INSERT INTO follows(id, follower, inserted, accepted, followed) VALUES ('https://ip6-allnodes/follow/2', 'https://ip6-allnodes/user/erin', UNIXEPOCH() - 5, 1, 'https://localhost.localdomain/user/alice')`)
+	assert.NoError(err)
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	// only the retry to dan is due: erin is skipped and this doesn't count as a failed attempt
+	_, err = db.Exec(`update retries set last = last - ? where inbox = 'https://ip6-allnodes/inbox/dan'`, q.Config.DeliveryRetryInterval*3/2)
+	assert.NoError(err)
+
+	client.Data = map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan":  failure(),
+		"https://ip6-allnodes/inbox/erin": failure(),
+	}
+
+	assert.NoError(q.process(context.Background()))
+	assert.Len(client.Data, 1)
+
+	var attempts int
+	assert.NoError(db.QueryRow(`select attempts from retries where inbox = 'https://ip6-allnodes/inbox/erin'`).Scan(&attempts))
+	assert.Equal(1, attempts)
+
+	assert.NoError(db.QueryRow(`select attempts from outbox`).Scan(&attempts))
+	assert.Equal(1, attempts)
+
+	// delivery to dan is given up, but erin is still retried
+	_, err = db.Exec(`update retries set last = last - ? where inbox = 'https://ip6-allnodes/inbox/erin'`, q.Config.DeliveryRetryInterval*3/2)
+	assert.NoError(err)
+
+	client.Data = map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": failure(),
+		"https://ip6-allnodes/inbox/erin": {
+			Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	}
+
+	assert.NoError(q.process(context.Background()))
+	assert.Len(client.Data, 1)
+	assert.Contains(client.Data, "https://ip6-allnodes/inbox/dan")
+
+	var sent int
+	assert.NoError(db.QueryRow(`select sent, attempts from outbox`).Scan(&sent, &attempts))
+	assert.Equal(0, sent)
+	assert.Equal(2, attempts)
+}
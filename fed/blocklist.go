@@ -17,9 +17,12 @@ limitations under the License.
 package fed
 
 import (
+	"context"
+	"database/sql"
 	"encoding/csv"
 	"io"
 	"log/slog"
+	"maps"
 	"math"
 	"os"
 	"path/filepath"
@@ -31,48 +34,80 @@ import (
 )
 
 // BlockList is a list of blocked domains.
+//
+// It combines domains listed in a CSV file with domains suspended through domain blocks in the database. If
+// LimitSilencedDomains is set, domains with "silence" severity in the CSV file are limited rather than blocked: they're
+// written to the database, to hide their posts from public timelines.
 type BlockList struct {
-	lock    sync.Mutex
-	wg      sync.WaitGroup
-	w       *fsnotify.Watcher
-	domains map[string]struct{}
+	lock      sync.Mutex
+	wg        sync.WaitGroup
+	w         *fsnotify.Watcher
+	domains   map[string]struct{}
+	limited   map[string]struct{}
+	synced    bool
+	suspended map[string]struct{}
+}
+
+// BlockListUpdater periodically synchronizes limited domains in the blocklist file with the database, then reloads
+// suspended domains from the database.
+//
+// Only the server should run it: a command that runs without the blocklist file would remove limited domains.
+type BlockListUpdater struct {
+	BlockList *BlockList
+	DB        *sql.DB
 }
 
 const blockListReloadDelay = time.Second * 5
 
-func loadBlocklist(path string) (map[string]struct{}, error) {
+func loadBlocklist(path string, limitSilenced bool) (map[string]struct{}, map[string]struct{}, error) {
 	blockedDomains := make(map[string]struct{})
+	limitedDomains := make(map[string]struct{})
 
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer f.Close()
 
 	c := csv.NewReader(f)
 	first := true
+	domainColumn, severityColumn := 0, -1
 	for {
 		r, err := c.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		if first {
 			first = false
+
+			for i, name := range r {
+				switch strings.TrimPrefix(strings.ToLower(name), "#") {
+				case "domain":
+					domainColumn = i
+				case "severity":
+					severityColumn = i
+				}
+			}
+
 			continue
 		}
 
-		blockedDomains[r[0]] = struct{}{}
+		if limitSilenced && severityColumn >= 0 && r[severityColumn] == "silence" {
+			limitedDomains[r[domainColumn]] = struct{}{}
+		} else {
+			blockedDomains[r[domainColumn]] = struct{}{}
+		}
 	}
 
-	return blockedDomains, nil
+	return blockedDomains, limitedDomains, nil
 }
 
-func NewBlockList(path string) (*BlockList, error) {
-	domains, err := loadBlocklist(path)
+func NewBlockList(path string, limitSilenced bool) (*BlockList, error) {
+	domains, limited, err := loadBlocklist(path, limitSilenced)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +124,7 @@ func NewBlockList(path string) (*BlockList, error) {
 	}
 	absPath := filepath.Join(dir, filepath.Base(path))
 
-	b := &BlockList{w: w, domains: domains}
+	b := &BlockList{w: w, domains: domains, limited: limited}
 
 	timer := time.NewTimer(math.MaxInt64)
 	timer.Stop()
@@ -111,22 +146,24 @@ func NewBlockList(path string) (*BlockList, error) {
 				}
 
 			case <-timer.C:
-				newDomains, err := loadBlocklist(path)
+				newDomains, newLimited, err := loadBlocklist(path, limitSilenced)
 				if err != nil {
 					slog.Warn("Failed to reload blocklist", "path", path, "error", err)
 					continue
 				}
 
 				// continue if the old list wasn't empty and the new one is empty; maybe the file was opened with O_TRUNC
-				if len(b.domains) > 0 && len(newDomains) == 0 {
+				if len(b.domains)+len(b.limited) > 0 && len(newDomains)+len(newLimited) == 0 {
 					slog.Warn("New blocklist is empty")
 					continue
 				}
 
 				b.lock.Lock()
 				b.domains = newDomains
+				b.limited = newLimited
+				b.synced = false
 				b.lock.Unlock()
-				slog.Info("Reloaded blocklist", "path", path, "length", len(newDomains))
+				slog.Info("Reloaded blocklist", "path", path, "length", len(newDomains), "limited", len(newLimited))
 			}
 		}
 	}()
@@ -145,6 +182,9 @@ func (b *BlockList) Contains(domain string) bool {
 		if _, contains := b.domains[domain]; contains {
 			return true
 		}
+		if _, contains := b.suspended[domain]; contains {
+			return true
+		}
 		if i := strings.IndexRune(domain, '.'); i == -1 {
 			return false
 		} else {
@@ -153,8 +193,95 @@ func (b *BlockList) Contains(domain string) bool {
 	}
 }
 
+func (b *BlockList) sync(ctx context.Context, db *sql.DB) error {
+	// limited domains are owned by the blocklist file
+	if b.w == nil {
+		return nil
+	}
+
+	b.lock.Lock()
+	if b.synced {
+		b.lock.Unlock()
+		return nil
+	}
+	limited := b.limited
+	b.lock.Unlock()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `delete from domainblocks where file = 1`); err != nil {
+		return err
+	}
+
+	// domain blocks imported to the database take precedence over the file
+	for host := range limited {
+		if _, err := tx.ExecContext(ctx, `insert into domainblocks(host, severity, file) values(?, 'silence', 1) on conflict(host) do nothing`, host); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	// the file might have been reloaded while synchronizing
+	if maps.Equal(b.limited, limited) {
+		b.synced = true
+	}
+	b.lock.Unlock()
+
+	return nil
+}
+
+// Refresh reloads suspended domains from the database.
+func (b *BlockList) Refresh(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `select host from domainblocks where severity = 'suspend'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	suspended := make(map[string]struct{})
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return err
+		}
+
+		suspended[host] = struct{}{}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	b.suspended = suspended
+	b.lock.Unlock()
+
+	return nil
+}
+
+// Run synchronizes limited domains with the database and reloads suspended domains.
+func (u *BlockListUpdater) Run(ctx context.Context) error {
+	if err := u.BlockList.sync(ctx, u.DB); err != nil {
+		return err
+	}
+
+	return u.BlockList.Refresh(ctx, u.DB)
+}
+
 // Close frees resources.
 func (b *BlockList) Close() {
+	if b.w == nil {
+		return
+	}
+
 	b.w.Close()
 	b.wg.Wait()
 }
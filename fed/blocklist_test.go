@@ -17,6 +17,9 @@ limitations under the License.
 package fed
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -87,3 +90,62 @@ func TestBlockList_BlockedSubdomainByDomainEndsWithDot(t *testing.T) {
 
 	assert.True(blockList.Contains("social.0.0.0.0.com."))
 }
+
+func TestBlockList_LimitedDomain(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "blocklist.csv")
+	assert.NoError(os.WriteFile(path, []byte(`#domain,#severity,#reject_media,#reject_reports,#public_comment,#obfuscate
+0.0.0.0.com,suspend,false,false,,false
+1.1.1.1.com,silence,false,false,,false
+2.2.2.2.com,silence,false,false,,false
+`), 0o600))
+
+	blocked, err := NewBlockList(path, false)
+	assert.NoError(err)
+	defer blocked.Close()
+
+	assert.True(blocked.Contains("0.0.0.0.com"))
+	assert.True(blocked.Contains("1.1.1.1.com"))
+
+	blockList, err := NewBlockList(path, true)
+	assert.NoError(err)
+	defer blockList.Close()
+
+	assert.True(blockList.Contains("0.0.0.0.com"))
+	assert.False(blockList.Contains("1.1.1.1.com"))
+
+	client := newTestClient(map[string]testResponse{})
+	_, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	_, err = db.Exec(`insert into domainblocks (host, severity) values('2.2.2.2.com', 'suspend'), ('3.3.3.3.com', 'silence')`)
+	assert.NoError(err)
+
+	assert.NoError((&BlockListUpdater{BlockList: blockList, DB: db}).Run(context.Background()))
+	assert.True(blockList.Contains("2.2.2.2.com"))
+
+	rows, err := db.Query(`select host, severity, file from domainblocks order by host`)
+	assert.NoError(err)
+	defer rows.Close()
+
+	var blocks []string
+	for rows.Next() {
+		var host, severity string
+		var file bool
+		assert.NoError(rows.Scan(&host, &severity, &file))
+		if file {
+			blocks = append(blocks, host+" "+severity+" (file)")
+		} else {
+			blocks = append(blocks, host+" "+severity)
+		}
+	}
+	assert.Equal([]string{"1.1.1.1.com silence (file)", "2.2.2.2.com suspend", "3.3.3.3.com silence"}, blocks)
+
+	// a command that runs without the blocklist file must not remove limited domains
+	assert.NoError((&BlockListUpdater{BlockList: &BlockList{}, DB: db}).Run(context.Background()))
+
+	var limited int
+	assert.NoError(db.QueryRow(`select count(*) from domainblocks where file = 1`).Scan(&limited))
+	assert.Equal(1, limited)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreakers stops requests to a key after consecutive timeouts, for some time.
+// The zero value is an empty set of circuit breakers.
+type circuitBreakers[K comparable] struct {
+	lock     sync.Mutex
+	breakers map[K]*circuitBreaker
+	pruned   time.Time
+}
+
+type circuitBreaker struct {
+	timeouts int
+	updated  time.Time
+	opened   time.Time
+}
+
+const circuitBreakersPruneInterval = time.Minute
+
+// Allow determines whether or not a request can be sent.
+//
+// Once cooldown has passed since the circuit breaker has opened, it allows one request through, every cooldown.
+func (b *circuitBreakers[K]) Allow(key K, now time.Time, cooldown time.Duration) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c, ok := b.breakers[key]
+	if !ok || c.opened.IsZero() {
+		return true
+	}
+
+	if now.Sub(c.opened) < cooldown {
+		return false
+	}
+
+	c.opened = now
+	return true
+}
+
+// Success closes the circuit breaker of a key.
+func (b *circuitBreakers[K]) Success(key K) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.breakers, key)
+}
+
+// Timeout records a timeout and returns true if the circuit breaker of a key has opened.
+func (b *circuitBreakers[K]) Timeout(key K, now time.Time, threshold int, cooldown time.Duration) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.breakers == nil {
+		b.breakers = map[K]*circuitBreaker{}
+	}
+
+	// forget timeouts that happened long ago
+	if now.Sub(b.pruned) >= circuitBreakersPruneInterval {
+		for k, c := range b.breakers {
+			if now.Sub(c.updated) >= cooldown && (c.opened.IsZero() || now.Sub(c.opened) >= cooldown) {
+				delete(b.breakers, k)
+			}
+		}
+
+		b.pruned = now
+	}
+
+	c, ok := b.breakers[key]
+	if !ok {
+		c = &circuitBreaker{}
+		b.breakers[key] = c
+	}
+
+	c.timeouts++
+	c.updated = now
+
+	if c.timeouts >= threshold && c.opened.IsZero() {
+		c.opened = now
+		return true
+	}
+
+	if !c.opened.IsZero() {
+		c.opened = now
+	}
+
+	return false
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dimkr/tootik/metrics"
+)
+
+var deliveryQueueDepth = metrics.NewGauge("tootik_delivery_queue_depth", "Outgoing requests waiting for a delivery worker", "domain")
+
+// deliveryBuckets holds pending delivery tasks in a bucket per domain, and hands them to a pool of workers in a
+// round-robin order across domains.
+//
+// The number and frequency of concurrent requests to each domain is limited, so a slow domain occupies a limited
+// number of workers and doesn't delay delivery to other domains. Tasks for the same inbox are handed out one at a
+// time and in order.
+type deliveryBuckets struct {
+	maxInFlight int
+	interval    time.Duration
+	capacity    int
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	buckets map[string]*deliveryBucket
+	hosts   []string
+	queued  map[deliveryTaskKey]struct{}
+	pending int
+	closed  bool
+}
+
+type deliveryBucket struct {
+	tasks    []deliveryTask
+	inFlight int
+	inboxes  map[string]struct{}
+	last     time.Time
+}
+
+type deliveryTaskKey struct {
+	Activity, Inbox string
+}
+
+func newDeliveryBuckets(maxInFlight int, interval time.Duration, capacity int) *deliveryBuckets {
+	b := &deliveryBuckets{
+		maxInFlight: maxInFlight,
+		interval:    interval,
+		capacity:    capacity,
+		buckets:     map[string]*deliveryBucket{},
+		queued:      map[deliveryTaskKey]struct{}{},
+	}
+	b.cond = sync.NewCond(&b.lock)
+	return b
+}
+
+// Push adds a task to the bucket of its domain, unless the same activity is already queued for delivery to the same
+// inbox. It blocks while the buckets are full.
+func (b *deliveryBuckets) Push(task deliveryTask) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	key := deliveryTaskKey{Activity: task.Job.Activity.ID, Inbox: task.Inbox}
+	if _, ok := b.queued[key]; ok {
+		return
+	}
+	b.queued[key] = struct{}{}
+
+	for b.pending >= b.capacity {
+		b.cond.Wait()
+	}
+
+	host := task.Request.URL.Host
+
+	bucket, ok := b.buckets[host]
+	if !ok {
+		bucket = &deliveryBucket{inboxes: map[string]struct{}{}}
+		b.buckets[host] = bucket
+	}
+
+	if len(bucket.tasks) == 0 {
+		b.hosts = append(b.hosts, host)
+	}
+
+	bucket.tasks = append(bucket.tasks, task)
+	b.pending++
+	deliveryQueueDepth.Set(float64(len(bucket.tasks)), host)
+
+	b.cond.Broadcast()
+}
+
+// Close notifies workers that no more tasks will be pushed.
+func (b *deliveryBuckets) Close() {
+	b.lock.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.lock.Unlock()
+}
+
+// pop returns the next task that can be handed to a worker, or how long to wait until a task is ready.
+func (b *deliveryBuckets) pop(now time.Time, ignoreLimits bool) (deliveryTask, time.Duration, bool) {
+	var wait time.Duration
+
+	for i, host := range b.hosts {
+		bucket := b.buckets[host]
+
+		if !ignoreLimits {
+			if bucket.inFlight >= b.maxInFlight {
+				continue
+			}
+
+			if d := bucket.last.Add(b.interval).Sub(now); d > 0 {
+				if wait == 0 || d < wait {
+					wait = d
+				}
+				continue
+			}
+		}
+
+		for j, task := range bucket.tasks {
+			if _, ok := bucket.inboxes[task.Inbox]; ok && !ignoreLimits {
+				continue
+			}
+
+			bucket.tasks = append(bucket.tasks[:j], bucket.tasks[j+1:]...)
+			bucket.inFlight++
+			bucket.inboxes[task.Inbox] = struct{}{}
+			bucket.last = now
+			b.pending--
+			deliveryQueueDepth.Set(float64(len(bucket.tasks)), host)
+
+			// move this domain to the end of the queue
+			b.hosts = append(b.hosts[:i], b.hosts[i+1:]...)
+			if len(bucket.tasks) > 0 {
+				b.hosts = append(b.hosts, host)
+			}
+
+			return task, 0, true
+		}
+	}
+
+	return deliveryTask{}, wait, false
+}
+
+// Next blocks until a task can be handed to a worker. It returns false when the buckets are closed and empty.
+//
+// Every task returned by Next must be followed by a call to Done. Once ctx is done, Next hands out the remaining
+// tasks without waiting.
+func (b *deliveryBuckets) Next(ctx context.Context) (deliveryTask, bool) {
+	stop := context.AfterFunc(ctx, func() {
+		b.lock.Lock()
+		b.cond.Broadcast()
+		b.lock.Unlock()
+	})
+	defer stop()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for {
+		task, wait, ok := b.pop(time.Now(), ctx.Err() != nil)
+		if ok {
+			b.cond.Broadcast()
+			return task, true
+		}
+
+		if b.closed && b.pending == 0 {
+			return deliveryTask{}, false
+		}
+
+		if wait > 0 {
+			t := time.AfterFunc(wait, func() {
+				b.lock.Lock()
+				b.cond.Broadcast()
+				b.lock.Unlock()
+			})
+			b.cond.Wait()
+			t.Stop()
+		} else {
+			b.cond.Wait()
+		}
+	}
+}
+
+// Done marks a task returned by Next as done.
+func (b *deliveryBuckets) Done(task deliveryTask) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	bucket := b.buckets[task.Request.URL.Host]
+	bucket.inFlight--
+	delete(bucket.inboxes, task.Inbox)
+
+	b.cond.Broadcast()
+}
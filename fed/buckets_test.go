@@ -0,0 +1,243 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDeliveryTask(t *testing.T, activity, inbox string) deliveryTask {
+	req, err := http.NewRequest(http.MethodPost, inbox, nil)
+	assert.NoError(t, err)
+
+	return deliveryTask{
+		Job:     deliveryJob{Activity: &ap.Activity{ID: activity}},
+		Request: req,
+		Inbox:   inbox,
+	}
+}
+
+func TestBuckets_RoundRobin(t *testing.T) {
+	assert := assert.New(t)
+
+	buckets := newDeliveryBuckets(5, 0, 5)
+	for _, inbox := range []string{
+		"https://a.localdomain/inbox/1",
+		"https://a.localdomain/inbox/2",
+		"https://a.localdomain/inbox/3",
+		"https://b.localdomain/inbox/1",
+		"https://c.localdomain/inbox/1",
+	} {
+		buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", inbox))
+	}
+	buckets.Close()
+
+	var order []string
+	for {
+		task, ok := buckets.Next(context.Background())
+		if !ok {
+			break
+		}
+
+		order = append(order, task.Inbox)
+		buckets.Done(task)
+	}
+
+	assert.Equal(
+		[]string{
+			"https://a.localdomain/inbox/1",
+			"https://b.localdomain/inbox/1",
+			"https://c.localdomain/inbox/1",
+			"https://a.localdomain/inbox/2",
+			"https://a.localdomain/inbox/3",
+		},
+		order,
+	)
+}
+
+func TestBuckets_Duplicate(t *testing.T) {
+	assert := assert.New(t)
+
+	buckets := newDeliveryBuckets(5, 0, 5)
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/1"))
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/2", "https://a.localdomain/inbox/1"))
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/1"))
+	buckets.Close()
+
+	var activities []string
+	for {
+		task, ok := buckets.Next(context.Background())
+		if !ok {
+			break
+		}
+
+		activities = append(activities, task.Job.Activity.ID)
+		buckets.Done(task)
+	}
+
+	assert.Equal([]string{"https://localhost.localdomain/create/1", "https://localhost.localdomain/create/2"}, activities)
+}
+
+func TestBuckets_Concurrency(t *testing.T) {
+	assert := assert.New(t)
+
+	buckets := newDeliveryBuckets(2, time.Millisecond*10, 6)
+	for i := range 6 {
+		buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/"+strconv.Itoa(i)))
+	}
+	buckets.Close()
+
+	var active, maxActive atomic.Int32
+	var wg sync.WaitGroup
+
+	start := time.Now()
+
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				task, ok := buckets.Next(context.Background())
+				if !ok {
+					return
+				}
+
+				n := active.Add(1)
+				for {
+					m := maxActive.Load()
+					if n <= m || maxActive.CompareAndSwap(m, n) {
+						break
+					}
+				}
+
+				time.Sleep(time.Millisecond * 20)
+				active.Add(-1)
+				buckets.Done(task)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(int32(2), maxActive.Load())
+	assert.GreaterOrEqual(time.Since(start), time.Millisecond*50)
+}
+
+func TestBuckets_SameInbox(t *testing.T) {
+	assert := assert.New(t)
+
+	buckets := newDeliveryBuckets(2, 0, 3)
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/1"))
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/2", "https://a.localdomain/inbox/1"))
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/2"))
+	buckets.Close()
+
+	first, ok := buckets.Next(context.Background())
+	assert.True(ok)
+	assert.Equal("https://localhost.localdomain/create/1", first.Job.Activity.ID)
+	assert.Equal("https://a.localdomain/inbox/1", first.Inbox)
+
+	// the second activity to the same inbox must wait for the first one
+	second, ok := buckets.Next(context.Background())
+	assert.True(ok)
+	assert.Equal("https://a.localdomain/inbox/2", second.Inbox)
+
+	buckets.Done(second)
+	buckets.Done(first)
+
+	third, ok := buckets.Next(context.Background())
+	assert.True(ok)
+	assert.Equal("https://localhost.localdomain/create/2", third.Job.Activity.ID)
+	assert.Equal("https://a.localdomain/inbox/1", third.Inbox)
+	buckets.Done(third)
+
+	_, ok = buckets.Next(context.Background())
+	assert.False(ok)
+}
+
+func TestBuckets_SlowDomain(t *testing.T) {
+	assert := assert.New(t)
+
+	buckets := newDeliveryBuckets(1, 0, 3)
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/1"))
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/2"))
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://b.localdomain/inbox/1"))
+	buckets.Close()
+
+	slow, ok := buckets.Next(context.Background())
+	assert.True(ok)
+	assert.Equal("https://a.localdomain/inbox/1", slow.Inbox)
+
+	// while a request to a.localdomain is in progress, other workers can deliver to b.localdomain
+	fast, ok := buckets.Next(context.Background())
+	assert.True(ok)
+	assert.Equal("https://b.localdomain/inbox/1", fast.Inbox)
+	buckets.Done(fast)
+
+	done := make(chan struct{})
+	go func() {
+		task, ok := buckets.Next(context.Background())
+		assert.True(ok)
+		assert.Equal("https://a.localdomain/inbox/2", task.Inbox)
+		buckets.Done(task)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		assert.Fail("second request to a.localdomain was not delayed")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	buckets.Done(slow)
+	<-done
+}
+
+func TestBuckets_Cancel(t *testing.T) {
+	assert := assert.New(t)
+
+	buckets := newDeliveryBuckets(1, time.Hour, 2)
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/1"))
+	buckets.Push(newTestDeliveryTask(t, "https://localhost.localdomain/create/1", "https://a.localdomain/inbox/2"))
+	buckets.Close()
+
+	task, ok := buckets.Next(context.Background())
+	assert.True(ok)
+	buckets.Done(task)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	// once the context is done, the remaining task is handed out without waiting
+	task, ok = buckets.Next(ctx)
+	assert.True(ok)
+	assert.Equal("https://a.localdomain/inbox/2", task.Inbox)
+	buckets.Done(task)
+
+	_, ok = buckets.Next(ctx)
+	assert.False(ok)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditional_Actor(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, _, err = user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	l := Listener{
+		Domain: "localhost.localdomain",
+		Config: &cfg,
+		DB:     db,
+	}
+
+	get := func(f func(http.ResponseWriter, *http.Request), path, name string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "https://localhost.localdomain"+path, nil)
+		req.SetPathValue("username", name)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		f(w, req)
+		return w
+	}
+
+	actor := get(l.handleUser, "/user/alice", "alice", nil)
+	assert.Equal(http.StatusOK, actor.Code)
+	assert.NotEmpty(actor.Body.String())
+
+	etag := actor.Header().Get("ETag")
+	assert.NotEmpty(etag)
+
+	lastModified := actor.Header().Get("Last-Modified")
+	assert.NotEmpty(lastModified)
+
+	notModified := get(l.handleUser, "/user/alice", "alice", map[string]string{"If-None-Match": etag})
+	assert.Equal(http.StatusNotModified, notModified.Code)
+	assert.Empty(notModified.Body.String())
+
+	notModified = get(l.handleUser, "/user/alice", "alice", map[string]string{"If-Modified-Since": lastModified})
+	assert.Equal(http.StatusNotModified, notModified.Code)
+
+	_, err = db.Exec(`update persons set actor = json_set(actor, '$.summary', 'hello', '$.updated', ?) where actor->>'$.preferredUsername' = 'alice'`, time.Now().Add(time.Hour).Format(time.RFC3339Nano))
+	assert.NoError(err)
+
+	modified := get(l.handleUser, "/user/alice", "alice", map[string]string{"If-None-Match": etag})
+	assert.Equal(http.StatusOK, modified.Code)
+	assert.Contains(modified.Body.String(), "hello")
+	assert.NotEqual(etag, modified.Header().Get("ETag"))
+
+	// if the actor doesn't say when it was last modified, the response says when it was created
+	_, err = db.Exec(`update persons set actor = json_remove(actor, '$.updated', '$.published') where actor->>'$.preferredUsername' = 'alice'`)
+	assert.NoError(err)
+
+	actor = get(l.handleUser, "/user/alice", "alice", nil)
+	assert.Equal(http.StatusOK, actor.Code)
+	assert.NotEmpty(actor.Header().Get("Last-Modified"))
+	assert.NotContains(actor.Header().Get("Last-Modified"), "1970")
+
+	icon := get(l.handleIcon, "/icon/alice.gif", "alice.gif", nil)
+	assert.Equal(http.StatusOK, icon.Code)
+	assert.NotEmpty(icon.Header().Get("ETag"))
+
+	notModified = get(l.handleIcon, "/icon/alice.gif", "alice.gif", map[string]string{"If-None-Match": icon.Header().Get("ETag")})
+	assert.Equal(http.StatusNotModified, notModified.Code)
+
+	outbox := get(l.handleOutbox, "/outbox/alice", "alice", nil)
+	assert.Equal(http.StatusOK, outbox.Code)
+
+	notModified = get(l.handleOutbox, "/outbox/alice", "alice", map[string]string{"If-None-Match": outbox.Header().Get("ETag")})
+	assert.Equal(http.StatusNotModified, notModified.Code)
+}
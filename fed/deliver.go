@@ -22,11 +22,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"hash/crc32"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -34,8 +34,16 @@ import (
 	"github.com/dimkr/tootik/cfg"
 	"github.com/dimkr/tootik/data"
 	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/metrics"
 )
 
+// RecipientsQuery lists the federated followers of an actor, for wide delivery of an activity.
+const RecipientsQuery = `select distinct follower from follows where followed = ? and follower not like ? and follower not like ? and accepted = 1 and inserted < ?`
+
+var deliveries = metrics.NewCounter("tootik_deliveries_total", "Attempts to deliver an activity to a server", "domain", "result")
+
+var errDomainPaused = errors.New("delivery to domain is paused")
+
 type Queue struct {
 	Domain   string
 	Config   *cfg.Config
@@ -58,10 +66,22 @@ type deliveryTask struct {
 type deliveryEvent struct {
 	Job  deliveryJob
 	Done bool
+
+	// Deferred is true if delivery to a recipient was postponed without an attempt, because its domain is paused or
+	// dormant, or because the next retry is not due yet.
+	Deferred bool
+}
+
+type deliveryResult struct {
+	Failed, Deferred bool
 }
 
 // Process polls the queue of outgoing activities and delivers them to other servers.
 // Delivery happens in batches, with multiple workers, timeout and retries.
+// High priority activities are delivered first.
+// Outgoing requests are queued in a bucket per domain, and a fixed pool of workers pulls them in a round-robin order
+// across domains. The number and frequency of concurrent requests to each domain is limited, so a slow domain doesn't
+// block delivery to other domains.
 // The listing of additional activities and recipients runs in parallel with delivery.
 // If possible, wide deliveries (e.g. public posts) are performed using the sharedInbox endpoint, greatly reducing the
 // number of outgoing requests when many recipients share the same endpoint.
@@ -95,18 +115,26 @@ func (q *Queue) process(ctx context.Context) error {
 		where
 			outbox.sent = 0 and
 			(
-				outbox.attempts = 0 or
+				(
+					outbox.attempts = 0 and
+					not exists (select 1 from retries where retries.activity = outbox.activity->>'$.id' and retries.notbefore > unixepoch())
+				) or
+				exists (select 1 from retries where retries.activity = outbox.activity->>'$.id' and retries.attempts < ? and `+retryDue+`) or
 				(
 					outbox.attempts < ? and
+					not exists (select 1 from retries where retries.activity = outbox.activity->>'$.id') and
 					outbox.last <= unixepoch() - ?
 				)
 			)
 		order by
+			outbox.priority desc,
 			outbox.attempts asc,
 			outbox.last asc
 		limit ?`,
 		q.Config.MaxDeliveryAttempts,
 		q.Config.DeliveryRetryInterval,
+		q.Config.MaxDeliveryAttempts,
+		q.Config.DeliveryRetryInterval,
 		q.Config.DeliveryBatchSize,
 	)
 	if err != nil {
@@ -114,29 +142,36 @@ func (q *Queue) process(ctx context.Context) error {
 	}
 	defer rows.Close()
 
+	domains, err := q.loadDomains(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch domain status: %w", err)
+	}
+
 	events := make(chan deliveryEvent)
-	tasks := make([]chan deliveryTask, 0, q.Config.DeliveryWorkers)
 	var wg sync.WaitGroup
-	results := make(chan map[deliveryJob]bool)
+	results := make(chan map[deliveryJob]deliveryResult)
+	buckets := newDeliveryBuckets(q.Config.MaxRequestsPerDomain, q.Config.DomainRequestInterval, q.Config.DeliveryWorkers*q.Config.DeliveryWorkerBuffer)
 
-	// start worker routines, each with its own task queue
+	// start worker routines that share the per-domain buckets
 	wg.Add(q.Config.DeliveryWorkers)
 	for range q.Config.DeliveryWorkers {
-		ch := make(chan deliveryTask, q.Config.DeliveryWorkerBuffer)
-
 		go func() {
-			q.consume(ctx, ch, events)
+			q.consume(ctx, buckets, domains, events)
 			wg.Done()
 		}()
-
-		tasks = append(tasks, ch)
 	}
 
 	go func() {
-		r := make(map[deliveryJob]bool, q.Config.DeliveryBatchSize)
+		r := make(map[deliveryJob]deliveryResult, q.Config.DeliveryBatchSize)
 
 		for event := range events {
-			r[event.Job] = event.Done
+			result := r[event.Job]
+			if event.Deferred {
+				result.Deferred = true
+			} else if !event.Done {
+				result.Failed = true
+			}
+			r[event.Job] = result
 		}
 
 		results <- r
@@ -168,10 +203,10 @@ func (q *Queue) process(ctx context.Context) error {
 			continue
 		}
 
+		// the attempt is counted only if delivery to a recipient fails
 		if _, err := q.DB.ExecContext(
 			ctx,
-			`update outbox set last = unixepoch(), attempts = ? where activity->>'$.id' = ? and sender = ?`,
-			deliveryAttempts+1,
+			`update outbox set last = unixepoch() where activity->>'$.id' = ? and sender = ?`,
 			activity.ID,
 			actor.ID,
 		); err != nil {
@@ -185,7 +220,7 @@ func (q *Queue) process(ctx context.Context) error {
 		}
 
 		// notify about the new job and mark it as successful until a worker notifies otherwise
-		events <- deliveryEvent{job, true}
+		events <- deliveryEvent{Job: job, Done: true}
 
 		// queue tasks for all outgoing requests while workers are busy with previous tasks
 		if err := q.queueTasks(
@@ -195,7 +230,7 @@ func (q *Queue) process(ctx context.Context) error {
 			httpsig.Key{ID: actor.PublicKey.ID, PrivateKey: privKey},
 			time.Unix(inserted, 0),
 			&followers,
-			tasks,
+			buckets,
 			events,
 		); err != nil {
 			slog.Warn("Failed to queue activity for delivery", "id", activity.ID, "attempts", deliveryAttempts, "error", err)
@@ -203,9 +238,7 @@ func (q *Queue) process(ctx context.Context) error {
 	}
 
 	// notify workers that no more tasks will be queued
-	for _, ch := range tasks {
-		close(ch)
-	}
+	buckets.Close()
 
 	// wait for all workers to finish their tasks
 	wg.Wait()
@@ -214,9 +247,31 @@ func (q *Queue) process(ctx context.Context) error {
 	close(events)
 
 	// receive and save job results
-	for job, done := range <-results {
-		if !done {
-			slog.Info("Failed to deliver an activity to at least one recipient", "id", job.Activity.ID)
+	for job, result := range <-results {
+		// the activity is given up when delivery to all failed recipients has failed too many times, and postponed
+		// delivery to some recipients is not a delivery attempt
+		if result.Failed || result.Deferred {
+			failed := 0
+			if result.Failed {
+				failed = 1
+			}
+
+			if _, err := q.DB.ExecContext(
+				ctx,
+				`update outbox set attempts = coalesce((select min(attempts) from retries where retries.activity = $1), attempts + $2) where activity->>'$.id' = $1 and sender = $3`,
+				job.Activity.ID,
+				failed,
+				job.Sender.ID,
+			); err != nil {
+				slog.Error("Failed to save delivery attempts", "id", job.Activity.ID, "error", err)
+			}
+
+			if result.Failed {
+				slog.Info("Failed to deliver an activity to at least one recipient", "id", job.Activity.ID)
+			} else {
+				slog.Info("Postponed delivery of an activity to at least one recipient", "id", job.Activity.ID)
+			}
+
 			continue
 		}
 
@@ -235,8 +290,16 @@ func (q *Queue) process(ctx context.Context) error {
 	return nil
 }
 
-func (q *Queue) deliverWithTimeout(parent context.Context, task deliveryTask) error {
-	ctx, cancel := context.WithTimeout(parent, q.Config.DeliveryTimeout)
+// deliverWithTimeout sends an activity and returns the response status code and how long to wait before the next
+// attempt, if the recipient asks to slow down.
+func (q *Queue) deliverWithTimeout(parent context.Context, task deliveryTask) (int, time.Duration, error) {
+	// requests over Tor are slower
+	timeout := q.Config.DeliveryTimeout
+	if isOnion(task.Request.URL.Hostname()) {
+		timeout = q.Config.OnionDeliveryTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
 	req := task.Request.WithContext(ctx)
@@ -244,63 +307,104 @@ func (q *Queue) deliverWithTimeout(parent context.Context, task deliveryTask) er
 	resp, err := q.Resolver.send(task.Key, req)
 	if err == nil {
 		resp.Body.Close()
+		return resp.StatusCode, 0, nil
 	}
-	return err
-}
 
-func (q *Queue) consume(ctx context.Context, requests <-chan deliveryTask, events chan<- deliveryEvent) {
-	tried := map[string]map[string]struct{}{}
+	if resp == nil {
+		return 0, 0, err
+	}
 
-	for task := range requests {
-		if m, ok := tried[task.Job.Activity.ID]; ok {
-			if _, ok := m[task.Inbox]; ok {
-				// if we have a duplicate task, skip without querying the deliveries table
-				continue
-			}
-			m[task.Inbox] = struct{}{}
-		} else {
-			tried[task.Job.Activity.ID] = map[string]struct{}{task.Inbox: {}}
-		}
+	return resp.StatusCode, parseRetryAfter(resp, time.Now()), err
+}
 
-		var delivered int
-		if err := q.DB.QueryRowContext(
-			ctx,
-			`select exists (select 1 from deliveries where activity = ? and inbox = ?)`,
-			task.Job.Activity.ID,
-			task.Inbox,
-		).Scan(&delivered); err != nil {
-			slog.Error("Failed to check if delivered already", "to", task.Inbox, "activity", task.Job.Activity.ID, "error", err)
-			events <- deliveryEvent{task.Job, false}
-			continue
+func (q *Queue) consume(ctx context.Context, buckets *deliveryBuckets, domains map[string]domainStatus, events chan<- deliveryEvent) {
+	for {
+		task, ok := buckets.Next(ctx)
+		if !ok {
+			return
 		}
 
-		if delivered == 1 {
-			slog.Info("Skipping recipient", "to", task.Inbox, "activity", task.Job.Activity.ID)
-			continue
-		}
+		q.deliver(ctx, task, domains, events)
+		buckets.Done(task)
+	}
+}
 
-		slog.Info("Delivering activity to recipient", "inbox", task.Inbox, "activity", task.Job.Activity.ID)
+func (q *Queue) deliver(ctx context.Context, task deliveryTask, domains map[string]domainStatus, events chan<- deliveryEvent) {
+	var delivered int
+	if err := q.DB.QueryRowContext(
+		ctx,
+		`select exists (select 1 from deliveries where activity = ? and inbox = ?)`,
+		task.Job.Activity.ID,
+		task.Inbox,
+	).Scan(&delivered); err != nil {
+		slog.Error("Failed to check if delivered already", "to", task.Inbox, "activity", task.Job.Activity.ID, "error", err)
+		events <- deliveryEvent{Job: task.Job}
+		return
+	}
 
-		if err := q.deliverWithTimeout(ctx, task); err == nil {
-			slog.Info("Successfully sent an activity", "from", task.Job.Sender.ID, "to", task.Inbox, "activity", task.Job.Activity.ID)
-		} else {
-			slog.Warn("Failed to send an activity", "from", task.Job.Sender.ID, "to", task.Inbox, "activity", task.Job.Activity.ID, "error", err)
-			if !errors.Is(err, ErrBlockedDomain) {
-				events <- deliveryEvent{task.Job, false}
-			}
+	if delivered == 1 {
+		slog.Info("Skipping recipient", "to", task.Inbox, "activity", task.Job.Activity.ID)
+		return
+	}
 
-			continue
-		}
+	if due, exhausted, err := q.isDue(ctx, task); err != nil {
+		slog.Error("Failed to check if delivery is due", "to", task.Inbox, "activity", task.Job.Activity.ID, "error", err)
+		events <- deliveryEvent{Job: task.Job}
+		return
+	} else if exhausted {
+		slog.Debug("Delivery to recipient has failed too many times", "to", task.Inbox, "activity", task.Job.Activity.ID)
+		events <- deliveryEvent{Job: task.Job}
+		return
+	} else if !due {
+		slog.Debug("Delivery retry is not due yet", "to", task.Inbox, "activity", task.Job.Activity.ID)
+		events <- deliveryEvent{Job: task.Job, Deferred: true}
+		return
+	}
 
-		if _, err := q.DB.ExecContext(
-			ctx,
-			`insert into deliveries(activity, inbox) values (?, ?)`,
-			task.Job.Activity.ID,
-			task.Inbox,
-		); err != nil {
-			slog.Error("Failed to record delivery", "activity", task.Job.Activity.ID, "inbox", task.Inbox, "error", err)
-			events <- deliveryEvent{task.Job, false}
+	if paused, skip := q.checkDomain(ctx, domains, task.Request.URL.Host); paused {
+		slog.Info("Delivery to domain is paused", "to", task.Inbox, "activity", task.Job.Activity.ID)
+		q.recordFailure(ctx, task, false)
+		q.logDelivery(ctx, task, false, 0, errDomainPaused)
+		q.deferRetry(ctx, task)
+		events <- deliveryEvent{Job: task.Job, Deferred: true}
+		return
+	} else if skip {
+		slog.Info("Skipping dormant domain", "to", task.Inbox, "activity", task.Job.Activity.ID)
+		q.recordFailure(ctx, task, false)
+		q.deferRetry(ctx, task)
+		events <- deliveryEvent{Job: task.Job, Deferred: true}
+		return
+	}
+
+	slog.Info("Delivering activity to recipient", "inbox", task.Inbox, "activity", task.Job.Activity.ID)
+
+	if status, retryAfter, err := q.deliverWithTimeout(ctx, task); err == nil {
+		slog.Info("Successfully sent an activity", "from", task.Job.Sender.ID, "to", task.Inbox, "activity", task.Job.Activity.ID)
+		deliveries.Inc(task.Request.URL.Host, "success")
+		q.recordSuccess(ctx, task)
+		q.cancelRetry(ctx, task)
+		q.logDelivery(ctx, task, true, status, nil)
+	} else {
+		slog.Warn("Failed to send an activity", "from", task.Job.Sender.ID, "to", task.Inbox, "activity", task.Job.Activity.ID, "error", err)
+		if !errors.Is(err, ErrBlockedDomain) {
+			deliveries.Inc(task.Request.URL.Host, "failure")
+			q.recordFailure(ctx, task, true)
+			q.logDelivery(ctx, task, true, status, err)
+			q.scheduleRetry(ctx, task, retryAfter)
+			events <- deliveryEvent{Job: task.Job}
 		}
+
+		return
+	}
+
+	if _, err := q.DB.ExecContext(
+		ctx,
+		`insert into deliveries(activity, inbox) values (?, ?)`,
+		task.Job.Activity.ID,
+		task.Inbox,
+	); err != nil {
+		slog.Error("Failed to record delivery", "activity", task.Job.Activity.ID, "inbox", task.Inbox, "error", err)
+		events <- deliveryEvent{Job: task.Job}
 	}
 }
 
@@ -311,7 +415,7 @@ func (q *Queue) queueTasks(
 	key httpsig.Key,
 	inserted time.Time,
 	followers *partialFollowers,
-	tasks []chan deliveryTask,
+	buckets *deliveryBuckets,
 	events chan<- deliveryEvent,
 ) error {
 	activityID, err := url.Parse(job.Activity.ID)
@@ -339,7 +443,7 @@ func (q *Queue) queueTasks(
 	if wideDelivery {
 		followers, err := q.DB.QueryContext(
 			ctx,
-			`select distinct follower from follows where followed = ? and follower not like ? and follower not like ? and accepted = 1 and inserted < ?`,
+			RecipientsQuery,
 			job.Sender.ID,
 			fmt.Sprintf("https://%s/%%", q.Domain),
 			fmt.Sprintf("https://%s/%%", activityID.Host),
@@ -380,11 +484,17 @@ func (q *Queue) queueTasks(
 			continue
 		}
 
+		// local actors and collections don't need delivery
+		if strings.HasPrefix(actorID, fmt.Sprintf("https://%s/", q.Domain)) {
+			slog.Debug("Skipping local recipient", "to", actorID, "activity", job.Activity.ID)
+			continue
+		}
+
 		to, err := q.Resolver.ResolveID(ctx, key, actorID, ap.Offline)
 		if err != nil {
 			slog.Warn("Failed to resolve a recipient", "to", actorID, "activity", job.Activity.ID, "error", err)
 			if !errors.Is(err, ErrActorGone) && !errors.Is(err, ErrBlockedDomain) {
-				events <- deliveryEvent{job, false}
+				events <- deliveryEvent{Job: job}
 			}
 			continue
 		}
@@ -401,7 +511,7 @@ func (q *Queue) queueTasks(
 		req, err := http.NewRequest(http.MethodPost, inbox, bytes.NewReader(rawActivity))
 		if err != nil {
 			slog.Warn("Failed to create new request", "to", actorID, "activity", job.Activity.ID, "inbox", inbox, "error", err)
-			events <- deliveryEvent{job, false}
+			events <- deliveryEvent{Job: job}
 			continue
 		}
 
@@ -424,13 +534,12 @@ func (q *Queue) queueTasks(
 
 		slog.Info("Queueing activity for delivery", "inbox", inbox, "activity", job.Activity.ID)
 
-		// assign a task to a random worker but use one worker per inbox, so activities are delivered once per inbox
-		tasks[crc32.ChecksumIEEE([]byte(inbox))%uint32(len(tasks))] <- deliveryTask{
+		buckets.Push(deliveryTask{
 			Job:     job,
 			Key:     key,
 			Request: req,
 			Inbox:   inbox,
-		}
+		})
 	}
 
 	return nil
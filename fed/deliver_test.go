@@ -20,10 +20,15 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/cfg"
@@ -68,10 +73,10 @@ func TestDeliver_TwoUsersTwoPosts(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
-	bob, _, err := user.Create(context.Background(), "localhost.localdomain", db, "bob", ap.Person, nil)
+	bob, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "bob", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -176,10 +181,10 @@ func TestDeliver_ForwardedPost(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
-	bob, _, err := user.Create(context.Background(), "localhost.localdomain", db, "bob", ap.Person, nil)
+	bob, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "bob", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -273,10 +278,10 @@ func TestDeliver_OneFailed(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
-	bob, _, err := user.Create(context.Background(), "localhost.localdomain", db, "bob", ap.Person, nil)
+	bob, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "bob", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -323,6 +328,18 @@ func TestDeliver_OneFailed(t *testing.T) {
 	assert.NoError(q.process(context.Background()))
 	assert.Empty(client.Data)
 
+	var status, attempts int
+	var errString sql.NullString
+	assert.NoError(db.QueryRow(`select status, attempts, error from deliverylog where activity = 'https://localhost.localdomain/create/1' and inbox = 'https://ip6-allnodes/inbox/dan'`).Scan(&status, &attempts, &errString))
+	assert.Equal(http.StatusInternalServerError, status)
+	assert.Equal(1, attempts)
+	assert.True(errString.Valid)
+
+	assert.NoError(db.QueryRow(`select status, attempts, error from deliverylog where activity = 'https://localhost.localdomain/create/1' and inbox = 'https://ip6-allnodes/inbox/erin'`).Scan(&status, &attempts, &errString))
+	assert.Equal(http.StatusOK, status)
+	assert.Equal(1, attempts)
+	assert.False(errString.Valid)
+
 	reply := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://localhost.localdomain/create/2","type":"Create","actor":"https://localhost.localdomain/user/bob","object":{"id":"https://localhost.localdomain/note/2","type":"Note","attributedTo":"https://localhost.localdomain/user/bob","content":"bye","inReplyTo":"https://localhost.localdomain/note/1","to":["https://localhost.localdomain/user/alice","https://localhost.localdomain/followers/bob"],"cc":[]},"to":["https://localhost.localdomain/user/alice","https://localhost.localdomain/followers/bob"],"cc":[]}`
 
 	_, err = db.Exec(
@@ -381,7 +398,7 @@ func TestDeliver_OneFailedRetry(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -472,7 +489,7 @@ func TestDeliver_OneInvalidURLRetry(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -562,7 +579,7 @@ func TestDeliver_MaxAttempts(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -660,7 +677,7 @@ func TestDeliver_SharedInbox(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -751,7 +768,7 @@ func TestDeliver_SharedInboxRetry(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -856,7 +873,7 @@ func TestDeliver_SharedInboxUnknownActor(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -948,7 +965,7 @@ func TestDeliver_SharedInboxSingleWorker(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -1039,7 +1056,7 @@ func TestDeliver_SameInbox(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -1130,10 +1147,10 @@ func TestDeliver_ToAndCCDuplicates(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
-	bob, _, err := user.Create(context.Background(), "localhost.localdomain", db, "bob", ap.Person, nil)
+	bob, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "bob", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -1238,10 +1255,10 @@ func TestDeliver_PublicInTo(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
-	bob, _, err := user.Create(context.Background(), "localhost.localdomain", db, "bob", ap.Person, nil)
+	bob, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "bob", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -1346,10 +1363,10 @@ func TestDeliver_AuthorInTo(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	alice, _, err := user.Create(context.Background(), "localhost.localdomain", db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
 	assert.NoError(err)
 
-	bob, _, err := user.Create(context.Background(), "localhost.localdomain", db, "bob", ap.Person, nil)
+	bob, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "bob", ap.Person, nil)
 	assert.NoError(err)
 
 	_, err = db.Exec(
@@ -1417,3 +1434,170 @@ func TestDeliver_AuthorInTo(t *testing.T) {
 	assert.NoError(q.process(context.Background()))
 	assert.Empty(client.Data)
 }
+
+func TestDeliver_Priority(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	q.Config.DeliveryBatchSize = 1
+
+	_, err := db.Exec(
+		`INSERT INTO outbox (activity, sender, priority) VALUES (?, ?, 1)`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://localhost.localdomain/follow/1","type":"Follow","actor":"https://localhost.localdomain/user/alice","object":"https://ip6-allnodes/user/dan","to":["https://ip6-allnodes/user/dan"],"cc":[]}`,
+		"https://localhost.localdomain/user/alice",
+	)
+	assert.NoError(err)
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	var sent int
+	assert.NoError(db.QueryRow(`select sent from outbox where activity->>'$.id' = 'https://localhost.localdomain/follow/1'`).Scan(&sent))
+	assert.Equal(1, sent)
+
+	assert.NoError(db.QueryRow(`select sent from outbox where activity->>'$.id' = 'https://localhost.localdomain/create/1'`).Scan(&sent))
+	assert.Equal(0, sent)
+}
+
+// slowTestClient blocks requests to one host until released.
+type slowTestClient struct {
+	*testClient
+	Host     string
+	Release  chan struct{}
+	active   atomic.Int32
+	inFlight atomic.Int32
+}
+
+func (c *slowTestClient) Do(r *http.Request) (*http.Response, error) {
+	if r.URL.Host == c.Host {
+		n := c.active.Add(1)
+		defer c.active.Add(-1)
+
+		for {
+			m := c.inFlight.Load()
+			if n <= m || c.inFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		<-c.Release
+	}
+
+	return c.testClient.Do(r)
+}
+
+func TestDeliver_SlowDomain(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+
+	blockList := BlockList{}
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+	cfg.DeliveryWorkers = 3
+	cfg.MaxRequestsPerDomain = 1
+	cfg.DomainRequestInterval = time.Millisecond
+
+	inner := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: newTestResponse(http.StatusOK, `{}`),
+		},
+		"https://ip6-allnodes/inbox/erin": {
+			Response: newTestResponse(http.StatusOK, `{}`),
+		},
+		"https://ip6-allrouters/inbox/frank": {
+			Response: newTestResponse(http.StatusOK, `{}`),
+		},
+		"https://ip6-allrouters/inbox/grace": {
+			Response: newTestResponse(http.StatusOK, `{}`),
+		},
+	})
+
+	client := slowTestClient{
+		testClient: &inner,
+		Host:       "ip6-allnodes",
+		Release:    make(chan struct{}),
+	}
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	for i, follower := range []string{"https://ip6-allnodes/user/dan", "https://ip6-allnodes/user/erin", "https://ip6-allrouters/user/frank", "https://ip6-allrouters/user/grace"} {
+		u, err := url.Parse(follower)
+		assert.NoError(err)
+
+		name := strings.TrimPrefix(u.Path, "/user/")
+
+		_, err = db.Exec(
+			`insert into persons (id, actor) values(?,?)`,
+			follower,
+			fmt.Sprintf(`{"type":"Person","id":"%s","preferredUsername":"%s","inbox":"https://%s/inbox/%s"}`, follower, name, u.Host, name),
+		)
+		assert.NoError(err)
+
+		_, err = db.Exec(`INSERT INTO follows(id, follower, inserted, accepted, followed) VALUES (?, ?, UNIXEPOCH() - 5, 1, 'https://localhost.localdomain/user/alice')`, fmt.Sprintf("https://%s/follow/%d", u.Host, i), follower)
+		assert.NoError(err)
+	}
+
+	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
+
+	q := Queue{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: resolver,
+	}
+
+	post := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://localhost.localdomain/create/1","type":"Create","actor":"https://localhost.localdomain/user/alice","object":{"id":"https://localhost.localdomain/note/1","type":"Note","attributedTo":"https://localhost.localdomain/user/alice","content":"hello","to":["https://localhost.localdomain/followers/alice"],"cc":[]},"to":["https://localhost.localdomain/followers/alice"],"cc":[]}`
+
+	_, err = db.Exec(
+		`INSERT INTO outbox (activity, sender) VALUES (?,?)`,
+		post,
+		alice.ID,
+	)
+	assert.NoError(err)
+
+	done := make(chan error)
+	go func() {
+		done <- q.process(context.Background())
+	}()
+
+	// delivery to ip6-allrouters is not blocked by ip6-allnodes
+	assert.Eventually(func() bool {
+		inner.Lock()
+		defer inner.Unlock()
+
+		_, frank := inner.Data["https://ip6-allrouters/inbox/frank"]
+		_, grace := inner.Data["https://ip6-allrouters/inbox/grace"]
+		return !frank && !grace
+	}, time.Second*5, time.Millisecond*10)
+
+	close(client.Release)
+	assert.NoError(<-done)
+
+	assert.Empty(inner.Data)
+	assert.Equal(int32(1), client.inFlight.Load())
+}
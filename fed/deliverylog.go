@@ -0,0 +1,57 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+)
+
+const maxDeliveryLogErrorLength = 256
+
+// logDelivery records the outcome of an attempt to deliver an activity, so the sender can see why delivery failed.
+func (q *Queue) logDelivery(ctx context.Context, task deliveryTask, attempted bool, status int, err error) {
+	var statusCode sql.NullInt32
+	if status > 0 {
+		statusCode = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+
+	var errString sql.NullString
+	if err != nil {
+		errString.String = truncate(err.Error(), maxDeliveryLogErrorLength)
+		errString.Valid = true
+	}
+
+	increment := 0
+	if attempted {
+		increment = 1
+	}
+
+	if _, err := q.DB.ExecContext(
+		ctx,
+		`insert into deliverylog(activity, inbox, host, status, attempts, error) values($1, $2, $3, $4, $5, $6) on conflict(activity, inbox) do update set status = coalesce($4, status), attempts = attempts + $5, error = $6, updated = unixepoch()`,
+		task.Job.Activity.ID,
+		task.Inbox,
+		task.Request.URL.Host,
+		statusCode,
+		increment,
+		errString,
+	); err != nil {
+		slog.Error("Failed to record delivery outcome", "activity", task.Job.Activity.ID, "inbox", task.Inbox, "error", err)
+	}
+}
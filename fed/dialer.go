@@ -0,0 +1,214 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/metrics"
+)
+
+var dnsCache = metrics.NewCounter("tootik_dns_cache_total", "Host name lookups by cache result", "result")
+
+// dialer connects to other servers.
+//
+// It caches DNS lookup results until their TTL or [cfg.Config.DNSCacheTTL] expires, and implements Happy Eyeballs (RFC 8305): if the host has both IPv6 and IPv4 addresses,
+// it tries the other address family too when connection to the first address family fails or takes too long.
+type dialer struct {
+	Config *cfg.Config
+	Lookup func(context.Context, string) ([]net.IPAddr, time.Duration, error)
+	Dial   func(context.Context, string, string) (net.Conn, error)
+
+	lookups flightGroup[string, []net.IPAddr]
+	cache   ttlCache[string, []net.IPAddr]
+}
+
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+func newDialer(cfg *cfg.Config) *dialer {
+	d := net.Dialer{Timeout: cfg.DialTimeout}
+
+	return &dialer{
+		Config: cfg,
+		Lookup: lookupIPAddr,
+		Dial:   d.DialContext,
+	}
+}
+
+func (d *dialer) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if addrs, ok := d.cache.Get(host, time.Now()); ok {
+		dnsCache.Inc("hit")
+		return addrs, nil
+	}
+
+	dnsCache.Inc("miss")
+
+	addrs, _, err := d.lookups.Do(ctx, host, func() ([]net.IPAddr, error) {
+		ctx, cancel := context.WithTimeout(ctx, d.Config.DNSTimeout)
+		defer cancel()
+
+		addrs, ttl, err := d.Lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("no addresses for %s", host)
+		}
+
+		if ttl = min(ttl, d.Config.DNSCacheTTL); ttl > 0 {
+			d.cache.Set(host, addrs, time.Now(), ttl, d.Config.MaxDNSCacheSize)
+		}
+		return addrs, nil
+	})
+	return addrs, err
+}
+
+// partition splits addresses into addresses of the same family as the first address, and all others.
+func partition(network string, addrs []net.IPAddr) (primary, fallback []net.IPAddr) {
+	for _, addr := range addrs {
+		ipv4 := addr.IP.To4() != nil
+		if (network == "tcp4" && !ipv4) || (network == "tcp6" && ipv4) {
+			continue
+		}
+
+		if len(primary) == 0 || (primary[0].IP.To4() != nil) == ipv4 {
+			primary = append(primary, addr)
+		} else {
+			fallback = append(fallback, addr)
+		}
+	}
+
+	return
+}
+
+func (d *dialer) dialSerial(ctx context.Context, network string, addrs []net.IPAddr, port string) (net.Conn, error) {
+	var firstErr error
+
+	for _, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		conn, err := d.Dial(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, firstErr
+}
+
+func (d *dialer) dialParallel(ctx context.Context, network string, primary, fallback []net.IPAddr, port string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, 2)
+	start := func(addrs []net.IPAddr, primary bool) {
+		go func() {
+			conn, err := d.dialSerial(ctx, network, addrs, port)
+			results <- dialResult{conn: conn, err: err, primary: primary}
+		}()
+	}
+
+	start(primary, true)
+	pending := 1
+
+	timer := time.NewTimer(d.Config.DialFallbackDelay)
+	defer timer.Stop()
+
+	fallbackStarted := false
+	var firstErr error
+
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				start(fallback, false)
+				fallbackStarted = true
+				pending++
+			}
+
+		case res := <-results:
+			pending--
+
+			if res.err == nil {
+				// the other attempt might still succeed: close its connection
+				if pending > 0 {
+					go func() {
+						if res := <-results; res.conn != nil {
+							res.conn.Close()
+						}
+					}()
+				}
+
+				return res.conn, nil
+			}
+
+			if firstErr == nil || res.primary {
+				firstErr = res.err
+			}
+
+			if !fallbackStarted {
+				start(fallback, false)
+				fallbackStarted = true
+				pending++
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(host) != nil {
+		return d.Dial(ctx, network, address)
+	}
+
+	addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	primary, fallback := partition(network, addrs)
+	if len(primary) == 0 {
+		return nil, fmt.Errorf("no %s addresses for %s", network, host)
+	}
+
+	if len(fallback) == 0 {
+		return d.dialSerial(ctx, network, primary, port)
+	}
+
+	return d.dialParallel(ctx, network, primary, fallback, port)
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type testDialer struct {
+	lock    sync.Mutex
+	lookups int
+	dials   []string
+	ttl     time.Duration
+}
+
+func newTestDialer(t *testing.T, addrs []string, dial func(context.Context, string) error) (*dialer, *testDialer) {
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	td := testDialer{ttl: time.Hour}
+
+	d := dialer{
+		Config: &cfg,
+		Lookup: func(_ context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+			td.lock.Lock()
+			td.lookups++
+			ttl := td.ttl
+			td.lock.Unlock()
+
+			if addrs == nil {
+				return nil, 0, errors.New("no such host")
+			}
+
+			ips := make([]net.IPAddr, len(addrs))
+			for i, addr := range addrs {
+				ips[i].IP = net.ParseIP(addr)
+			}
+
+			return ips, ttl, nil
+		},
+		Dial: func(ctx context.Context, _, address string) (net.Conn, error) {
+			td.lock.Lock()
+			td.dials = append(td.dials, address)
+			td.lock.Unlock()
+
+			if err := dial(ctx, address); err != nil {
+				return nil, err
+			}
+
+			client, server := net.Pipe()
+			t.Cleanup(func() { server.Close() })
+			return client, nil
+		},
+	}
+
+	return &d, &td
+}
+
+func succeed(context.Context, string) error {
+	return nil
+}
+
+func TestDialer_CacheHit(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"192.0.2.1"}, succeed)
+
+	for range 2 {
+		conn, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+		assert.NoError(err)
+		conn.Close()
+	}
+
+	assert.Equal(1, td.lookups)
+	assert.Equal([]string{"192.0.2.1:443", "192.0.2.1:443"}, td.dials)
+}
+
+func TestDialer_CacheExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"192.0.2.1"}, succeed)
+	d.Config.DNSCacheTTL = time.Millisecond
+
+	conn, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+	assert.NoError(err)
+	conn.Close()
+
+	time.Sleep(time.Millisecond * 10)
+
+	conn, err = d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+	assert.NoError(err)
+	conn.Close()
+
+	assert.Equal(2, td.lookups)
+}
+
+func TestDialer_RecordTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"192.0.2.1"}, succeed)
+	td.ttl = time.Millisecond
+
+	conn, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+	assert.NoError(err)
+	conn.Close()
+
+	time.Sleep(time.Millisecond * 10)
+
+	// the record TTL is shorter than DNSCacheTTL
+	conn, err = d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+	assert.NoError(err)
+	conn.Close()
+
+	assert.Equal(2, td.lookups)
+}
+
+func TestDialer_ZeroTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"192.0.2.1"}, succeed)
+	td.ttl = 0
+
+	for range 2 {
+		conn, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+		assert.NoError(err)
+		conn.Close()
+	}
+
+	assert.Equal(2, td.lookups)
+}
+
+func TestDNSTTL_Record(t *testing.T) {
+	assert := assert.New(t)
+
+	name := dnsmessage.MustNewName("a.localdomain.")
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true})
+	assert.NoError(b.StartQuestions())
+	assert.NoError(b.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}))
+	assert.NoError(b.StartAnswers())
+	assert.NoError(b.AResource(dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: 300}, dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}))
+	assert.NoError(b.AResource(dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.AResource{A: [4]byte{192, 0, 2, 2}}))
+	msg, err := b.Finish()
+	assert.NoError(err)
+
+	var ttl dnsTTL
+	assert.Equal(time.Duration(math.MaxInt64), ttl.Get())
+
+	ttl.record(msg)
+	assert.Equal(time.Minute, ttl.Get())
+
+	// responses over TCP are prefixed by their length and can be split across reads
+	ttl = dnsTTL{}
+	prefixed := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	prefixed = append(prefixed, msg...)
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		server.Write(prefixed[:5])
+		server.Write(prefixed[5:])
+		server.Close()
+	}()
+
+	conn := dnsStreamConn{Conn: client, ttl: &ttl}
+	_, err = io.ReadAll(&conn)
+	assert.NoError(err)
+	assert.Equal(time.Minute, ttl.Get())
+}
+
+func TestDialer_LookupFailureNotCached(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, nil, succeed)
+
+	for range 2 {
+		_, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+		assert.Error(err)
+	}
+
+	assert.Equal(2, td.lookups)
+	assert.Empty(td.dials)
+}
+
+func TestDialer_IPAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"192.0.2.1"}, succeed)
+
+	conn, err := d.DialContext(context.Background(), "tcp", "[2001:db8::1]:443")
+	assert.NoError(err)
+	conn.Close()
+
+	assert.Equal(0, td.lookups)
+	assert.Equal([]string{"[2001:db8::1]:443"}, td.dials)
+}
+
+func TestDialer_NextAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"192.0.2.1", "192.0.2.2"}, func(_ context.Context, address string) error {
+		if address == "192.0.2.1:443" {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	conn, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+	assert.NoError(err)
+	conn.Close()
+
+	assert.Equal([]string{"192.0.2.1:443", "192.0.2.2:443"}, td.dials)
+}
+
+func TestDialer_SlowIPv6(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"2001:db8::1", "192.0.2.1"}, func(ctx context.Context, address string) error {
+		if address == "[2001:db8::1]:443" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	d.Config.DialFallbackDelay = time.Millisecond * 10
+
+	conn, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+	assert.NoError(err)
+	conn.Close()
+
+	td.lock.Lock()
+	defer td.lock.Unlock()
+	assert.Equal([]string{"[2001:db8::1]:443", "192.0.2.1:443"}, td.dials)
+}
+
+func TestDialer_BrokenIPv6(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"2001:db8::1", "192.0.2.1"}, func(_ context.Context, address string) error {
+		if address == "[2001:db8::1]:443" {
+			return errors.New("network is unreachable")
+		}
+		return nil
+	})
+	d.Config.DialFallbackDelay = time.Hour
+
+	conn, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+	assert.NoError(err)
+	conn.Close()
+
+	assert.Equal([]string{"[2001:db8::1]:443", "192.0.2.1:443"}, td.dials)
+}
+
+func TestDialer_AllFailed(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"2001:db8::1", "192.0.2.1"}, func(_ context.Context, address string) error {
+		return errors.New("connection to " + address + " refused")
+	})
+	d.Config.DialFallbackDelay = time.Hour
+
+	_, err := d.DialContext(context.Background(), "tcp", "a.localdomain:443")
+	assert.EqualError(err, "connection to [2001:db8::1]:443 refused")
+
+	assert.Equal([]string{"[2001:db8::1]:443", "192.0.2.1:443"}, td.dials)
+}
+
+func TestDialer_IPv4Only(t *testing.T) {
+	assert := assert.New(t)
+
+	d, td := newTestDialer(t, []string{"2001:db8::1", "192.0.2.1"}, succeed)
+
+	conn, err := d.DialContext(context.Background(), "tcp4", "a.localdomain:443")
+	assert.NoError(err)
+	conn.Close()
+
+	assert.Equal([]string{"192.0.2.1:443"}, td.dials)
+}
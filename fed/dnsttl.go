@@ -0,0 +1,149 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsTTL tracks the smallest TTL of all answers received during a DNS lookup.
+type dnsTTL struct {
+	lock  sync.Mutex
+	ttl   uint32
+	known bool
+}
+
+// dnsPacketConn is a UDP connection to a DNS server that records the TTL of answers.
+type dnsPacketConn struct {
+	*net.UDPConn
+	ttl *dnsTTL
+}
+
+// dnsStreamConn is a TCP connection to a DNS server that records the TTL of answers.
+type dnsStreamConn struct {
+	net.Conn
+	ttl *dnsTTL
+	buf []byte
+}
+
+// record parses a DNS response and updates the TTL.
+func (t *dnsTTL) record(msg []byte) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return
+	}
+
+	if err := p.SkipAllQuestions(); err != nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for {
+		h, err := p.AnswerHeader()
+		if err != nil {
+			return
+		}
+
+		if !t.known || h.TTL < t.ttl {
+			t.ttl = h.TTL
+			t.known = true
+		}
+
+		if err := p.SkipAnswer(); err != nil {
+			return
+		}
+	}
+}
+
+// Get returns the smallest TTL, or the maximum duration if no answers were received.
+func (t *dnsTTL) Get() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.known {
+		return math.MaxInt64
+	}
+
+	return time.Duration(t.ttl) * time.Second
+}
+
+func (c *dnsPacketConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	if n > 0 {
+		c.ttl.record(b[:n])
+	}
+	return n, err
+}
+
+// Read reads responses prefixed by their length and records the TTL of each complete response.
+func (c *dnsStreamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.buf = append(c.buf, b[:n]...)
+
+		for len(c.buf) >= 2 {
+			l := int(binary.BigEndian.Uint16(c.buf))
+			if len(c.buf) < 2+l {
+				break
+			}
+
+			c.ttl.record(c.buf[2 : 2+l])
+			c.buf = c.buf[2+l:]
+		}
+	}
+	return n, err
+}
+
+// lookupIPAddr looks up the addresses of a host, like [net.Resolver.LookupIPAddr], and returns how long they can be
+// cached: the smallest TTL of all DNS answers, or the maximum duration if the host is resolved without a DNS query,
+// i.e. through /etc/hosts.
+func lookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	var ttl dnsTTL
+	var d net.Dialer
+
+	r := net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := d.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+
+			if udp, ok := conn.(*net.UDPConn); ok {
+				return &dnsPacketConn{UDPConn: udp, ttl: &ttl}, nil
+			}
+
+			return &dnsStreamConn{Conn: conn, ttl: &ttl}, nil
+		},
+	}
+
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return addrs, ttl.Get(), nil
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/httpsig"
+	"github.com/google/uuid"
+)
+
+// certificateExpiryWarning is the remaining validity period of a certificate that triggers a warning.
+const certificateExpiryWarning = time.Hour * 24 * 14
+
+// ErrDoctorFailed is returned by [Doctor.Run] if at least one check has failed.
+var ErrDoctorFailed = errors.New("some checks have failed")
+
+// Doctor checks whether or not other servers can reach this server, the same way they do.
+type Doctor struct {
+	Domain         string
+	Config         *cfg.Config
+	Client         Client
+	Key            httpsig.Key
+	CertPath       string
+	KeyPath        string
+	GeminiCertPath string
+	GeminiKeyPath  string
+}
+
+type doctorReport struct {
+	w      io.Writer
+	failed bool
+}
+
+func (r *doctorReport) ok(format string, a ...any) {
+	fmt.Fprintf(r.w, "✓ %s\n", fmt.Sprintf(format, a...))
+}
+
+func (r *doctorReport) warn(format string, a ...any) {
+	fmt.Fprintf(r.w, "! %s\n", fmt.Sprintf(format, a...))
+}
+
+func (r *doctorReport) fail(format string, a ...any) {
+	fmt.Fprintf(r.w, "✗ %s\n", fmt.Sprintf(format, a...))
+	r.failed = true
+}
+
+// Run runs all checks and prints the results.
+func (d *Doctor) Run(ctx context.Context, w io.Writer) error {
+	report := doctorReport{w: w}
+	s := sender{Domain: d.Domain, Config: d.Config, client: d.Client}
+
+	d.checkDNS(ctx, &report)
+
+	if d.CertPath != "" {
+		d.checkCertificate(&report, "HTTPS", d.CertPath, d.KeyPath, true)
+	}
+
+	if d.GeminiCertPath != "" {
+		d.checkCertificate(&report, "Gemini", d.GeminiCertPath, d.GeminiKeyPath, false)
+	}
+
+	if actorID, ok := d.checkWebFinger(ctx, &report, &s); ok {
+		d.checkActor(ctx, &report, &s, actorID)
+		d.checkSignature(ctx, &report, &s, actorID)
+	}
+
+	if report.failed {
+		return ErrDoctorFailed
+	}
+
+	return nil
+}
+
+func (d *Doctor) checkDNS(ctx context.Context, report *doctorReport) {
+	host, _, err := net.SplitHostPort(d.Domain)
+	if err != nil {
+		host = d.Domain
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		report.fail("Failed to resolve %s: %v (add an A or AAAA record for %s, or check -domain)", host, err, host)
+		return
+	}
+
+	report.ok("%s resolves to %s", host, strings.Join(addrs, ", "))
+}
+
+func (d *Doctor) checkCertificate(report *doctorReport, name, certPath, keyPath string, verifyHost bool) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		report.fail("Failed to load %s certificate: %v (check -cert, -key, -gemcert and -gemkey)", name, err)
+		return
+	}
+
+	if verifyHost {
+		host, _, err := net.SplitHostPort(d.Domain)
+		if err != nil {
+			host = d.Domain
+		}
+
+		if err := pair.Leaf.VerifyHostname(host); err != nil {
+			report.fail("%s certificate is not valid for %s: %v", name, host, err)
+			return
+		}
+	}
+
+	d.checkExpiry(report, name, pair.Leaf)
+}
+
+func (d *Doctor) checkExpiry(report *doctorReport, name string, cert *x509.Certificate) {
+	left := time.Until(cert.NotAfter)
+	if left <= 0 {
+		report.fail("%s certificate expired at %s", name, cert.NotAfter.UTC().Format(time.DateTime))
+	} else if left < certificateExpiryWarning {
+		report.warn("%s certificate expires at %s", name, cert.NotAfter.UTC().Format(time.DateTime))
+	} else {
+		report.ok("%s certificate is valid until %s", name, cert.NotAfter.UTC().Format(time.DateTime))
+	}
+}
+
+func (d *Doctor) checkWebFinger(ctx context.Context, report *doctorReport, s *sender) (string, bool) {
+	finger := fmt.Sprintf("https://%s/.well-known/webfinger?resource=acct:nobody@%s", d.Domain, d.Domain)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, finger, nil)
+	if err != nil {
+		report.fail("Failed to fetch %s: %v", finger, err)
+		return "", false
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.send(d.Key, req)
+	if err != nil {
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			report.fail("Failed to fetch %s: %v (the HTTPS certificate is invalid)", finger, err)
+		} else {
+			report.fail("Failed to fetch %s: %v (make sure the reverse proxy forwards all requests to tootik)", finger, err)
+		}
+		return "", false
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		d.checkExpiry(report, "Public HTTPS", resp.TLS.PeerCertificates[0])
+	}
+
+	var webFinger webFingerResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, d.Config.MaxResponseBodySize)).Decode(&webFinger); err != nil {
+		report.fail("Failed to decode %s: %v", finger, err)
+		return "", false
+	}
+
+	for _, link := range webFinger.Links {
+		if link.Rel != "self" || (link.Type != "application/activity+json" && link.Type != `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`) {
+			continue
+		}
+
+		if expected := strings.TrimSuffix(d.Key.ID, "#main-key"); link.Href != expected {
+			report.fail("%s points to %s instead of %s (check -domain)", finger, link.Href, expected)
+			return "", false
+		}
+
+		report.ok("%s is reachable", finger)
+		return link.Href, true
+	}
+
+	report.fail("%s does not point to an actor", finger)
+	return "", false
+}
+
+func (d *Doctor) checkActor(ctx context.Context, report *doctorReport, s *sender, actorID string) {
+	resp, err := s.Get(ctx, d.Key, actorID)
+	if err != nil {
+		report.fail("Failed to fetch %s: %v (make sure the reverse proxy forwards all requests to tootik)", actorID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var actor ap.Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, d.Config.MaxResponseBodySize)).Decode(&actor); err != nil {
+		report.fail("Failed to decode %s: %v", actorID, err)
+		return
+	}
+
+	if actor.ID != actorID {
+		report.fail("%s has ID %s (check -domain)", actorID, actor.ID)
+		return
+	}
+
+	if actor.PublicKey.ID == "" || actor.PublicKey.PublicKeyPem == "" {
+		report.fail("%s has no public key", actorID)
+		return
+	}
+
+	report.ok("%s is reachable", actorID)
+}
+
+// checkSignature sends a signed activity that passes signature verification but fails validation, to make sure the
+// reverse proxy passes the headers used to verify signatures.
+func (d *Doctor) checkSignature(ctx context.Context, report *doctorReport, s *sender, actorID string) {
+	inbox := user.URL(d.Domain, d.Config.InboxPath, "nobody")
+
+	body, err := json.Marshal(ap.Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		ID:      fmt.Sprintf("https://%s/doctor/%s", d.Domain, uuid.NewString()),
+		Type:    ap.Follow,
+		Actor:   actorID,
+		Object:  actorID,
+	})
+	if err != nil {
+		report.fail("Failed to send activity to %s: %v", inbox, err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		report.fail("Failed to send activity to %s: %v", inbox, err)
+		return
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.send(d.Key, req)
+	if resp == nil && err != nil {
+		report.fail("Failed to send activity to %s: %v", inbox, err)
+		return
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusOK, http.StatusAccepted:
+		report.ok("Signed requests to %s pass verification", inbox)
+
+	case http.StatusUnauthorized:
+		report.fail("Signed requests to %s fail verification (make sure the reverse proxy passes the original Host header and the request body as-is)", inbox)
+
+	default:
+		report.fail("Failed to send activity to %s: %s", inbox, resp.Status)
+	}
+}
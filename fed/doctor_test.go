@@ -0,0 +1,151 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/httpsig"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDoctorTestServer(t *testing.T, inboxStatus int) (*httptest.Server, string) {
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	domain := strings.TrimPrefix(server.URL, "https://")
+
+	mux.HandleFunc("GET /.well-known/webfinger", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"subject":"acct:nobody@%s","links":[{"rel":"self","type":"application/activity+json","href":"https://%s/user/nobody"}]}`, domain, domain)
+	})
+
+	mux.HandleFunc("GET /user/nobody", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"https://%s/user/nobody","type":"Application","preferredUsername":"nobody","publicKey":{"id":"https://%s/user/nobody#main-key","owner":"https://%s/user/nobody","publicKeyPem":"x"}}`, domain, domain, domain)
+	})
+
+	mux.HandleFunc("POST /inbox/nobody", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(inboxStatus)
+	})
+
+	return server, domain
+}
+
+func runDoctor(t *testing.T, server *httptest.Server, domain string) (string, error) {
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := Doctor{
+		Domain: domain,
+		Config: &cfg,
+		Client: server.Client(),
+		Key:    httpsig.Key{ID: fmt.Sprintf("https://%s/user/nobody#main-key", domain), PrivateKey: priv},
+	}
+
+	var out bytes.Buffer
+	err = d.Run(context.Background(), &out)
+	return out.String(), err
+}
+
+func TestDoctor_HappyFlow(t *testing.T) {
+	assert := assert.New(t)
+
+	server, domain := newDoctorTestServer(t, http.StatusBadRequest)
+
+	out, err := runDoctor(t, server, domain)
+	assert.NoError(err)
+	assert.Contains(out, "✓ 127.0.0.1 resolves to 127.0.0.1\n")
+	assert.Contains(out, fmt.Sprintf("✓ https://%s/.well-known/webfinger?resource=acct:nobody@%s is reachable\n", domain, domain))
+	assert.Contains(out, fmt.Sprintf("✓ https://%s/user/nobody is reachable\n", domain))
+	assert.Contains(out, fmt.Sprintf("✓ Signed requests to https://%s/inbox/nobody pass verification\n", domain))
+	assert.NotContains(out, "✗")
+}
+
+func TestDoctor_InvalidSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	server, domain := newDoctorTestServer(t, http.StatusUnauthorized)
+
+	out, err := runDoctor(t, server, domain)
+	assert.ErrorIs(err, ErrDoctorFailed)
+	assert.Contains(out, fmt.Sprintf("✗ Signed requests to https://%s/inbox/nobody fail verification", domain))
+	assert.Contains(out, "Host header")
+}
+
+func TestDoctor_InboxError(t *testing.T) {
+	assert := assert.New(t)
+
+	server, domain := newDoctorTestServer(t, http.StatusBadGateway)
+
+	out, err := runDoctor(t, server, domain)
+	assert.ErrorIs(err, ErrDoctorFailed)
+	assert.Contains(out, fmt.Sprintf("✗ Failed to send activity to https://%s/inbox/nobody: 502 Bad Gateway\n", domain))
+}
+
+func TestDoctor_WrongDomain(t *testing.T) {
+	assert := assert.New(t)
+
+	server, _ := newDoctorTestServer(t, http.StatusBadRequest)
+
+	out, err := runDoctor(t, server, "localhost.invalid")
+	assert.ErrorIs(err, ErrDoctorFailed)
+	assert.Contains(out, "✗ Failed to resolve localhost.invalid")
+	assert.NotContains(out, "✓")
+}
+
+func TestDoctor_ProbeIsRejected(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	l, _ := newInboxTestListener(t, &cfg)
+
+	// the activity sent by the doctor passes signature verification but it's never queued
+	body := `{"@context":"https://www.w3.org/ns/activitystreams","id":"https://localhost.localdomain/doctor/1","type":"Follow","actor":"https://localhost.localdomain/user/nobody","object":"https://localhost.localdomain/user/nobody"}`
+	req := newSignedTestRequest(t, l.ActorKey, body, time.Now())
+	req.SetPathValue("username", "alice")
+
+	w := httptest.NewRecorder()
+	l.handleInbox(w, req)
+	assert.Equal(http.StatusBadRequest, w.Code)
+
+	var queued int
+	assert.NoError(l.DB.QueryRow(`select count(*) from inbox`).Scan(&queued))
+	assert.Zero(queued)
+
+	// and it doesn't clutter the list of rejected activities
+	var rejected int
+	assert.NoError(l.DB.QueryRow(`select count(*) from rejections`).Scan(&rejected))
+	assert.Zero(rejected)
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrDomainBlocksIncomplete is returned by [DomainBlockImporter.Import] if at least one domain block was not imported.
+var ErrDomainBlocksIncomplete = errors.New("some domain blocks were not imported")
+
+// DomainBlockImporter imports domain blocks exported by Mastodon or shared in Mastodon's domain block CSV format.
+type DomainBlockImporter struct {
+	Domain string
+	DB     *sql.DB
+}
+
+func (i *DomainBlockImporter) importBlock(ctx context.Context, host, severity, comment string) (string, error) {
+	host = strings.ToLower(strings.Trim(strings.TrimSpace(host), "."))
+	if host == "" {
+		return "", errors.New("domain is empty")
+	}
+
+	if strings.Contains(host, "*") {
+		return "", fmt.Errorf("%s is obfuscated", host)
+	}
+
+	if strings.ContainsAny(host, "/:@ \t") {
+		return "", fmt.Errorf("%s is not a domain", host)
+	}
+
+	if host == i.Domain {
+		return "", fmt.Errorf("%s is this server", host)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "", "suspend":
+		severity = "suspend"
+
+	case "silence":
+
+	case "noop":
+		return "", nil
+
+	default:
+		return "", fmt.Errorf("%s has invalid severity: %s", host, severity)
+	}
+
+	var nullComment sql.NullString
+	if comment != "" {
+		nullComment = sql.NullString{String: comment, Valid: true}
+	}
+
+	if _, err := i.DB.ExecContext(
+		ctx,
+		`insert into domainblocks(host, severity, comment) values($1, $2, $3) on conflict(host) do update set severity = $2, comment = $3, file = 0`,
+		host,
+		severity,
+		nullComment,
+	); err != nil {
+		return "", fmt.Errorf("failed to block %s: %w", host, err)
+	}
+
+	return severity, nil
+}
+
+// Import reads domain blocks from a CSV file in the format used by Mastodon and adds them to the database.
+//
+// The header row is optional: without it, the first column is the domain and the second column, if present, is the
+// severity. Domains with "suspend" severity (or no severity) are blocked like domains in the blocklist file, while
+// content from domains with "silence" severity is accepted but hidden from public timelines. Domain blocks with "noop"
+// severity are skipped, and existing blocks of the same domain are replaced.
+func (i *DomainBlockImporter) Import(ctx context.Context, w io.Writer, r io.Reader) error {
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = -1
+	c.TrimLeadingSpace = true
+
+	domainColumn, severityColumn, commentColumn := 0, 1, -1
+
+	var suspended, silenced, skipped, failed int
+	for {
+		record, err := c.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read domain blocks: %w", err)
+		}
+
+		line, _ := c.FieldPos(0)
+
+		// use the header to find the columns, if there is one
+		if line == 1 {
+			domain, severity, comment := -1, -1, -1
+			for j, name := range record {
+				switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#")) {
+				case "domain":
+					domain = j
+				case "severity":
+					severity = j
+				case "public_comment", "comment":
+					comment = j
+				}
+			}
+
+			if domain >= 0 {
+				domainColumn, severityColumn, commentColumn = domain, severity, comment
+				continue
+			}
+		}
+
+		if domainColumn >= len(record) {
+			fmt.Fprintf(w, "Line %d: domain is missing\n", line)
+			failed++
+			continue
+		}
+
+		var severity, comment string
+		if severityColumn >= 0 && severityColumn < len(record) {
+			severity = record[severityColumn]
+		}
+		if commentColumn >= 0 && commentColumn < len(record) {
+			comment = record[commentColumn]
+		}
+
+		if imported, err := i.importBlock(ctx, record[domainColumn], severity, comment); err != nil {
+			fmt.Fprintf(w, "Line %d: %v\n", line, err)
+			failed++
+		} else if imported == "suspend" {
+			suspended++
+		} else if imported == "silence" {
+			silenced++
+		} else {
+			skipped++
+		}
+	}
+
+	fmt.Fprintf(w, "Imported %d domain blocks (%d suspended, %d silenced), %d skipped, %d failed\n", suspended+silenced, suspended, silenced, skipped, failed)
+
+	if failed > 0 {
+		return ErrDomainBlocksIncomplete
+	}
+
+	return nil
+}
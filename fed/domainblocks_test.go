@@ -0,0 +1,109 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainBlockImporter_Import(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{})
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	_, err := db.Exec(`insert into domainblocks (host, severity) values('ip6-allrouters', 'suspend')`)
+	assert.NoError(err)
+
+	importer := DomainBlockImporter{
+		Domain: q.Domain,
+		DB:     db,
+	}
+
+	var buf bytes.Buffer
+	assert.ErrorIs(
+		importer.Import(
+			context.Background(),
+			&buf,
+			strings.NewReader(`#domain,#severity,#reject_media,#reject_reports,#public_comment,#obfuscate
+ip6-allnodes,suspend,false,false,spam,false
+Ip6-AllRouters.,silence,false,false,,false
+ip6-localnet,noop,true,false,,false
+ip6-*net,suspend,false,false,,true
+localhost.localdomain,suspend,false,false,,false
+ip6-mcastprefix,limit,false,false,,false
+`),
+		),
+		ErrDomainBlocksIncomplete,
+	)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(lines, 4)
+	assert.Equal("Line 5: ip6-*net is obfuscated", lines[0])
+	assert.Equal("Line 6: localhost.localdomain is this server", lines[1])
+	assert.Equal("Line 7: ip6-mcastprefix has invalid severity: limit", lines[2])
+	assert.Equal("Imported 2 domain blocks (1 suspended, 1 silenced), 1 skipped, 3 failed", lines[3])
+
+	var severity, comment string
+	assert.NoError(db.QueryRow(`select severity, comment from domainblocks where host = 'ip6-allnodes'`).Scan(&severity, &comment))
+	assert.Equal("suspend", severity)
+	assert.Equal("spam", comment)
+
+	assert.NoError(db.QueryRow(`select severity from domainblocks where host = 'ip6-allrouters'`).Scan(&severity))
+	assert.Equal("silence", severity)
+
+	var count int
+	assert.NoError(db.QueryRow(`select count(*) from domainblocks`).Scan(&count))
+	assert.Equal(2, count)
+
+	blockList := BlockList{}
+	assert.NoError(blockList.Refresh(context.Background(), db))
+	assert.True(blockList.Contains("ip6-allnodes"))
+	assert.True(blockList.Contains("social.ip6-allnodes"))
+	assert.False(blockList.Contains("ip6-allrouters"))
+}
+
+func TestDomainBlockImporter_NoHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{})
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	importer := DomainBlockImporter{
+		Domain: q.Domain,
+		DB:     db,
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(
+		importer.Import(
+			context.Background(),
+			&buf,
+			strings.NewReader(`ip6-allnodes
+ip6-allrouters,silence
+`),
+		),
+	)
+	assert.Equal("Imported 2 domain blocks (1 suspended, 1 silenced), 0 skipped, 0 failed\n", buf.String())
+}
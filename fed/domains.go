@@ -0,0 +1,137 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type domainStatus struct {
+	Paused, Dormant bool
+}
+
+// loadDomains returns the status of all paused or dormant domains, so delivery doesn't need to query it for each
+// recipient.
+func (q *Queue) loadDomains(ctx context.Context) (map[string]domainStatus, error) {
+	rows, err := q.DB.QueryContext(ctx, `select host, paused, dormant from domains where paused = 1 or dormant = 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := map[string]domainStatus{}
+	for rows.Next() {
+		var host string
+		var status domainStatus
+		if err := rows.Scan(&host, &status.Paused, &status.Dormant); err != nil {
+			return nil, err
+		}
+
+		domains[host] = status
+	}
+
+	return domains, rows.Err()
+}
+
+// checkDomain determines whether delivery to a domain is paused, or should be skipped because the domain is dormant.
+// Delivery to a dormant domain is attempted once every DormantDomainProbe, to detect when it comes back.
+func (q *Queue) checkDomain(ctx context.Context, domains map[string]domainStatus, host string) (bool, bool) {
+	status := domains[host]
+	if status.Paused || !status.Dormant {
+		return status.Paused, false
+	}
+
+	// only one delivery attempt, by one worker, can claim the next probe
+	if res, err := q.DB.ExecContext(
+		ctx,
+		`update domains set lastfailure = unixepoch() where host = ? and dormant = 1 and lastfailure <= unixepoch() - ?`,
+		host,
+		int64(q.Config.DormantDomainProbe/time.Second),
+	); err != nil {
+		slog.Error("Failed to claim probe", "host", host, "error", err)
+		return false, true
+	} else if n, err := res.RowsAffected(); err != nil {
+		slog.Error("Failed to claim probe", "host", host, "error", err)
+		return false, true
+	} else if n == 0 {
+		return false, true
+	}
+
+	slog.Info("Probing dormant domain", "host", host)
+	return false, false
+}
+
+func (q *Queue) isDormant(ctx context.Context, host string) bool {
+	var dormant int
+	if err := q.DB.QueryRowContext(ctx, `select exists (select 1 from domains where host = ? and dormant = 1)`, host).Scan(&dormant); err != nil {
+		slog.Error("Failed to check if domain is dormant", "host", host, "error", err)
+		return false
+	}
+
+	return dormant == 1
+}
+
+func (q *Queue) recordSuccess(ctx context.Context, task deliveryTask) {
+	wasDormant := q.isDormant(ctx, task.Request.URL.Host)
+
+	if _, err := q.DB.ExecContext(
+		ctx,
+		`insert into domains(host, lastsuccess) values($1, unixepoch()) on conflict(host) do update set lastsuccess = unixepoch(), failures = 0, failingsince = null, dormant = 0`,
+		task.Request.URL.Host,
+	); err != nil {
+		slog.Error("Failed to record successful delivery", "host", task.Request.URL.Host, "error", err)
+	} else if wasDormant {
+		slog.Info("Dormant domain is back", "host", task.Request.URL.Host)
+	}
+
+	if _, err := q.DB.ExecContext(
+		ctx,
+		`delete from undelivered where host = ? and activity = ?`,
+		task.Request.URL.Host,
+		task.Job.Activity.ID,
+	); err != nil {
+		slog.Error("Failed to remove activity from domain queue", "host", task.Request.URL.Host, "activity", task.Job.Activity.ID, "error", err)
+	}
+}
+
+func (q *Queue) recordFailure(ctx context.Context, task deliveryTask, attempted bool) {
+	if attempted {
+		wasDormant := q.isDormant(ctx, task.Request.URL.Host)
+
+		if _, err := q.DB.ExecContext(
+			ctx,
+			`insert into domains(host, lastfailure, failures, failingsince) values($1, unixepoch(), 1, unixepoch()) on conflict(host) do update set lastfailure = unixepoch(), failures = failures + 1, failingsince = coalesce(failingsince, unixepoch()), dormant = (coalesce(failingsince, unixepoch()) <= unixepoch() - $2)`,
+			task.Request.URL.Host,
+			int64(q.Config.DormantDomainTimeout/time.Second),
+		); err != nil {
+			slog.Error("Failed to record failed delivery", "host", task.Request.URL.Host, "error", err)
+		} else if !wasDormant && q.isDormant(ctx, task.Request.URL.Host) {
+			slog.Warn("Domain is dormant", "host", task.Request.URL.Host)
+		}
+	}
+
+	if _, err := q.DB.ExecContext(
+		ctx,
+		`insert into undelivered(host, activity) values(?, ?) on conflict(host, activity) do nothing`,
+		task.Request.URL.Host,
+		task.Job.Activity.ID,
+	); err != nil {
+		slog.Error("Failed to add activity to domain queue", "host", task.Request.URL.Host, "activity", task.Job.Activity.ID, "error", err)
+	}
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDomainsTestQueue(t *testing.T, client *testClient) (*Queue, *sql.DB, func()) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+
+	blockList := BlockList{}
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	_, err = db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://ip6-allnodes/user/dan",
+		`{"type":"Person","id":"https://ip6-allnodes/user/dan","preferredUsername":"dan","inbox":"https://ip6-allnodes/inbox/dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = db.Exec(`INSERT INTO follows(id, follower, inserted, accepted, followed) VALUES ('https://ip6-allnodes/follow/1', 'https://ip6-allnodes/user/dan', UNIXEPOCH() - 5, 1, 'https://localhost.localdomain/user/alice')`)
+	assert.NoError(err)
+
+	_, err = db.Exec(
+		`INSERT INTO outbox (activity, sender) VALUES (?,?)`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://localhost.localdomain/create/1","type":"Create","actor":"https://localhost.localdomain/user/alice","object":{"id":"https://localhost.localdomain/note/1","type":"Note","attributedTo":"https://localhost.localdomain/user/alice","content":"hello","to":["https://localhost.localdomain/followers/alice"],"cc":[]},"to":["https://localhost.localdomain/followers/alice"],"cc":[]}`,
+		alice.ID,
+	)
+	assert.NoError(err)
+
+	q := Queue{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(&blockList, "localhost.localdomain", &cfg, client, db),
+	}
+
+	return &q, db, func() {
+		db.Close()
+		os.Remove(path)
+	}
+}
+
+func TestDomains_FailureAndRecovery(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	var failures, queued int
+	var lastSuccess sql.NullInt64
+	assert.NoError(db.QueryRow(`select failures, lastsuccess from domains where host = 'ip6-allnodes'`).Scan(&failures, &lastSuccess))
+	assert.Equal(1, failures)
+	assert.False(lastSuccess.Valid)
+
+	assert.NoError(db.QueryRow(`select count(*) from undelivered where host = 'ip6-allnodes' and activity = 'https://localhost.localdomain/create/1'`).Scan(&queued))
+	assert.Equal(1, queued)
+
+	q.Config.DeliveryRetryInterval = 0
+
+	client.Data = map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	}
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	assert.NoError(db.QueryRow(`select failures, lastsuccess from domains where host = 'ip6-allnodes'`).Scan(&failures, &lastSuccess))
+	assert.Equal(0, failures)
+	assert.True(lastSuccess.Valid)
+
+	assert.NoError(db.QueryRow(`select count(*) from undelivered`).Scan(&queued))
+	assert.Equal(0, queued)
+}
+
+func TestDomains_Paused(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	_, err := db.Exec(`insert into domains(host, paused) values('ip6-allnodes', 1)`)
+	assert.NoError(err)
+
+	assert.NoError(q.process(context.Background()))
+	assert.Len(client.Data, 1)
+
+	var failures, queued, sent, attempts int
+	assert.NoError(db.QueryRow(`select failures from domains where host = 'ip6-allnodes'`).Scan(&failures))
+	assert.Equal(0, failures)
+
+	assert.NoError(db.QueryRow(`select count(*) from undelivered where host = 'ip6-allnodes'`).Scan(&queued))
+	assert.Equal(1, queued)
+
+	// delivery to a paused domain doesn't use up delivery attempts
+	assert.NoError(db.QueryRow(`select sent, attempts from outbox`).Scan(&sent, &attempts))
+	assert.Equal(0, sent)
+	assert.Equal(0, attempts)
+
+	// and isn't retried before DeliveryRetryInterval
+	assert.NoError(q.process(context.Background()))
+	assert.Len(client.Data, 1)
+
+	assert.NoError(db.QueryRow(`select sent, attempts from outbox`).Scan(&sent, &attempts))
+	assert.Equal(0, sent)
+	assert.Equal(0, attempts)
+
+	_, err = db.Exec(`update domains set paused = 0 where host = 'ip6-allnodes'`)
+	assert.NoError(err)
+
+	_, err = db.Exec(`update retries set notbefore = unixepoch() - 1`)
+	assert.NoError(err)
+
+	q.Config.DeliveryRetryInterval = 0
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	assert.NoError(db.QueryRow(`select sent from outbox`).Scan(&sent))
+	assert.Equal(1, sent)
+}
+
+func TestDomains_Dormant(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	_, err := db.Exec(`insert into domains(host, failures, failingsince) values('ip6-allnodes', 100, unixepoch() - ?)`, int64(q.Config.DormantDomainTimeout/time.Second)+1)
+	assert.NoError(err)
+
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	var dormant int
+	assert.NoError(db.QueryRow(`select dormant from domains where host = 'ip6-allnodes'`).Scan(&dormant))
+	assert.Equal(1, dormant)
+
+	q.Config.DeliveryRetryInterval = 0
+
+	_, err = db.Exec(
+		`INSERT INTO outbox (activity, sender) VALUES (?,?)`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://localhost.localdomain/create/2","type":"Create","actor":"https://localhost.localdomain/user/alice","object":{"id":"https://localhost.localdomain/note/2","type":"Note","attributedTo":"https://localhost.localdomain/user/alice","content":"hello again","to":["https://localhost.localdomain/followers/alice"],"cc":[]},"to":["https://localhost.localdomain/followers/alice"],"cc":[]}`,
+		"https://localhost.localdomain/user/alice",
+	)
+	assert.NoError(err)
+
+	client.Data = map[string]testResponse{
+		"https://ip6-allnodes/inbox/dan": {
+			Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	}
+
+	// delivery to the dormant domain is postponed without using up delivery attempts
+	assert.NoError(q.process(context.Background()))
+	assert.Len(client.Data, 1)
+
+	var pending, attempts int
+	assert.NoError(db.QueryRow(`select count(*) from outbox where sent = 0`).Scan(&pending))
+	assert.Equal(2, pending)
+
+	assert.NoError(db.QueryRow(`select attempts from outbox where activity->>'$.id' = 'https://localhost.localdomain/create/2'`).Scan(&attempts))
+	assert.Equal(0, attempts)
+
+	_, err = db.Exec(
+		`INSERT INTO outbox (activity, sender) VALUES (?,?)`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://localhost.localdomain/create/3","type":"Create","actor":"https://localhost.localdomain/user/alice","object":{"id":"https://localhost.localdomain/note/3","type":"Note","attributedTo":"https://localhost.localdomain/user/alice","content":"anyone there?","to":["https://localhost.localdomain/followers/alice"],"cc":[]},"to":["https://localhost.localdomain/followers/alice"],"cc":[]}`,
+		"https://localhost.localdomain/user/alice",
+	)
+	assert.NoError(err)
+
+	_, err = db.Exec(`update domains set lastfailure = unixepoch() - ? where host = 'ip6-allnodes'`, int64(q.Config.DormantDomainProbe/time.Second))
+	assert.NoError(err)
+
+	// the next probe succeeds and the domain is no longer dormant
+	assert.NoError(q.process(context.Background()))
+	assert.Empty(client.Data)
+
+	var failures int
+	assert.NoError(db.QueryRow(`select dormant, failures from domains where host = 'ip6-allnodes'`).Scan(&dormant, &failures))
+	assert.Equal(0, dormant)
+	assert.Equal(0, failures)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls with the same key, so only one of them does the work.
+// The zero value is an empty group.
+type flightGroup[K comparable, V any] struct {
+	lock    sync.Mutex
+	flights map[K]*flight[V]
+}
+
+type flight[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Do calls f, unless a call with the same key is in progress: in this case, it waits for the result of this call
+// instead. The returned bool is true if the result is shared with another caller.
+func (g *flightGroup[K, V]) Do(ctx context.Context, key K, f func() (V, error)) (V, bool, error) {
+	for {
+		g.lock.Lock()
+
+		if g.flights == nil {
+			g.flights = map[K]*flight[V]{}
+		}
+
+		if fl, ok := g.flights[key]; ok {
+			g.lock.Unlock()
+
+			select {
+			case <-ctx.Done():
+				var zero V
+				return zero, false, ctx.Err()
+
+			case <-fl.done:
+			}
+
+			// if the caller that did the work was cancelled, try again
+			if (errors.Is(fl.err, context.Canceled) || errors.Is(fl.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				continue
+			}
+
+			return fl.value, true, fl.err
+		}
+
+		fl := &flight[V]{done: make(chan struct{})}
+		g.flights[key] = fl
+		g.lock.Unlock()
+
+		func() {
+			defer func() {
+				g.lock.Lock()
+				delete(g.flights, key)
+				g.lock.Unlock()
+
+				close(fl.done)
+			}()
+
+			fl.value, fl.err = f()
+		}()
+
+		return fl.value, false, fl.err
+	}
+}
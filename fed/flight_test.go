@@ -0,0 +1,100 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightGroup_Waiting(t *testing.T) {
+	assert := assert.New(t)
+
+	var g flightGroup[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go g.Do(context.Background(), "a", func() (int, error) {
+		close(started)
+		<-release
+		return 1, nil
+	})
+
+	<-started
+
+	// other keys are unaffected
+	v, shared, err := g.Do(context.Background(), "b", func() (int, error) {
+		return 2, nil
+	})
+	assert.NoError(err)
+	assert.False(shared)
+	assert.Equal(2, v)
+
+	time.AfterFunc(time.Millisecond*100, func() { close(release) })
+
+	v, shared, err = g.Do(context.Background(), "a", func() (int, error) {
+		return 3, nil
+	})
+	assert.NoError(err)
+	assert.True(shared)
+	assert.Equal(1, v)
+
+	// the result is not reused after the first call returns
+	v, shared, err = g.Do(context.Background(), "a", func() (int, error) {
+		return 4, nil
+	})
+	assert.NoError(err)
+	assert.False(shared)
+	assert.Equal(4, v)
+}
+
+func TestFlightGroup_Cancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	var g flightGroup[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go g.Do(context.Background(), "a", func() (int, error) {
+		close(started)
+		<-release
+		return 0, context.Canceled
+	})
+
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := g.Do(ctx, "a", func() (int, error) {
+		return 2, nil
+	})
+	assert.ErrorIs(err, context.Canceled)
+
+	time.AfterFunc(time.Millisecond*100, func() { close(release) })
+
+	// the first caller was cancelled, so this caller does the work
+	v, shared, err := g.Do(context.Background(), "a", func() (int, error) {
+		return 3, nil
+	})
+	assert.NoError(err)
+	assert.False(shared)
+	assert.Equal(3, v)
+}
@@ -134,7 +134,16 @@ func (l *Listener) handleFollowers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := l.DB.QueryContext(r.Context(), `SELECT follower FROM follows WHERE followed = 'https://' || ? || '/user/' || ? AND follower LIKE 'https://' || ? || '/' || '%'`, l.Domain, name, u.Host)
+	var actorID, followers string
+	if err := l.DB.QueryRowContext(r.Context(), `SELECT id, actor->>'$.followers' FROM persons WHERE actor->>'$.preferredUsername' = ? AND host = ?`, name, l.Domain).Scan(&actorID, &followers); errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := l.DB.QueryContext(r.Context(), `SELECT follower FROM follows WHERE followed = ? AND follower LIKE 'https://' || ? || '/' || '%'`, actorID, u.Host)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -155,7 +164,7 @@ func (l *Listener) handleFollowers(w http.ResponseWriter, r *http.Request) {
 
 	collection, err := json.Marshal(map[string]any{
 		"@context":     "https://www.w3.org/ns/activitystreams",
-		"id":           fmt.Sprintf("https://%s/followers/%s?domain=%s", l.Domain, name, u.Host),
+		"id":           fmt.Sprintf("%s?domain=%s", followers, u.Host),
 		"type":         "OrderedCollection",
 		"orderedItems": items,
 	})
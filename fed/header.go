@@ -0,0 +1,54 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/icon"
+)
+
+func (l *Listener) handleHeader(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("username"), icon.HeaderFileNameExtension)
+	if !ok {
+		// headers uploaded before the switch to PNG are GIFs
+		name, ok = strings.CutSuffix(r.PathValue("username"), icon.FileNameExtension)
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Looking up header", "name", name)
+
+	var buf []byte
+	if err := l.DB.QueryRowContext(r.Context(), `select buf from headers where name = ?`, name).Scan(&buf); errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.Warn("Failed to get header", "name", name, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeConditional(w, r, http.DetectContentType(buf), buf, time.Time{})
+}
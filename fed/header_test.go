@@ -0,0 +1,91 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/icon"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader_PNGAndGIF(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	var b bytes.Buffer
+	assert.NoError(gif.Encode(&b, image.NewRGBA(image.Rect(0, 0, 300, 300)), nil))
+
+	header, err := icon.ScaleHeader(&cfg, b.Bytes())
+	assert.NoError(err)
+
+	_, err = db.Exec(`insert into headers(name, buf) values('alice', ?), ('bob', ?)`, string(header), b.String())
+	assert.NoError(err)
+
+	l := Listener{
+		Domain: "localhost.localdomain",
+		Config: &cfg,
+		DB:     db,
+	}
+
+	get := func(name string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "https://localhost.localdomain/header/"+name, nil)
+		r.SetPathValue("username", name)
+		w := httptest.NewRecorder()
+		l.handleHeader(w, r)
+		return w
+	}
+
+	resp := get("alice.png")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("image/png", resp.Header().Get("Content-Type"))
+	assert.Equal(header, resp.Body.Bytes())
+
+	// headers uploaded before the switch to PNG are still served
+	resp = get("bob.gif")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("image/gif", resp.Header().Get("Content-Type"))
+	assert.Equal(b.Bytes(), resp.Body.Bytes())
+
+	assert.Equal(http.StatusNotFound, get("alice").Code)
+	assert.Equal(http.StatusNotFound, get("carol.png").Code)
+}
@@ -21,7 +21,7 @@ import (
 	"net/http"
 )
 
-func addHostMeta(mux *http.ServeMux, domain string) {
+func addHostMeta(mux *serveMux, domain string) {
 	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">
   <Link rel="lrdd" template="https://%s/.well-known/webfinger?resource={uri}"/>
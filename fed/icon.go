@@ -22,6 +22,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/dimkr/tootik/icon"
 )
@@ -43,8 +44,7 @@ func (l *Listener) handleIcon(w http.ResponseWriter, r *http.Request) {
 		return
 	} else if len(cache) > 0 {
 		slog.Debug("Sending cached icon", "name", name)
-		w.Header().Set("Content-Type", icon.MediaType)
-		w.Write(cache)
+		writeConditional(w, r, icon.MediaType, cache, time.Time{})
 		return
 	}
 
@@ -75,6 +75,5 @@ func (l *Listener) handleIcon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", icon.MediaType)
-	w.Write(buf)
+	writeConditional(w, r, icon.MediaType, buf, time.Time{})
 }
@@ -0,0 +1,165 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/outbox"
+)
+
+// ErrImportIncomplete is returned by [FollowImporter.Import] if at least one follow was not imported.
+var ErrImportIncomplete = errors.New("some follows were not imported")
+
+// FollowImporter recreates follows exported by software that used to run on this domain.
+type FollowImporter struct {
+	Domain   string
+	Config   *cfg.Config
+	DB       *sql.DB
+	Resolver *Resolver
+	Key      httpsig.Key
+}
+
+func (i *FollowImporter) resolve(ctx context.Context, actor string) (*ap.Actor, error) {
+	if strings.HasPrefix(actor, "https://") {
+		return i.Resolver.ResolveID(ctx, i.Key, actor, 0)
+	}
+
+	if name, host, ok := strings.Cut(strings.TrimPrefix(actor, "@"), "@"); ok && name != "" && host != "" && !strings.Contains(host, "/") {
+		return i.Resolver.Resolve(ctx, i.Key, host, name, 0)
+	}
+
+	return nil, errors.New("actor must be user@host or an https:// URL")
+}
+
+func (i *FollowImporter) importFollow(ctx context.Context, follower, followed string) (bool, error) {
+	from, err := i.resolve(ctx, follower)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve %s: %w", follower, err)
+	}
+
+	to, err := i.resolve(ctx, followed)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve %s: %w", followed, err)
+	}
+
+	if from.ID == to.ID {
+		return false, fmt.Errorf("%s cannot follow itself", from.ID)
+	}
+
+	var local int
+	if err := i.DB.QueryRowContext(ctx, `select count(*) from persons where id in ($1, $2) and host = $3`, from.ID, to.ID, i.Domain).Scan(&local); err != nil {
+		return false, fmt.Errorf("failed to check if %s or %s is local: %w", from.ID, to.ID, err)
+	} else if local == 0 {
+		return false, fmt.Errorf("neither %s nor %s is local", from.ID, to.ID)
+	}
+
+	followID, err := outbox.NewID(i.Domain, "follow")
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := i.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`insert into follows (id, follower, followed, accepted) select $1, $2, $3, 1 where not exists (select 1 from follows where follower = $2 and followed = $3)`,
+		followID,
+		from.ID,
+		to.ID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert follow %s: %w", followID, err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return false, fmt.Errorf("failed to insert follow %s: %w", followID, err)
+	} else if n == 0 {
+		return false, nil
+	}
+
+	// if this server knows the followed actor's followers digest, it's stale now: synchronize during the next run of the
+	// followers synchronization job, to drop the follow if the other server doesn't recognize it
+	if _, err := tx.ExecContext(ctx, `update follows_sync set changed = 0 where actor = ?`, to.ID); err != nil {
+		return false, fmt.Errorf("failed to schedule followers synchronization for %s: %w", to.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to insert follow %s: %w", followID, err)
+	}
+
+	return true, nil
+}
+
+// Import reads follower,followed pairs from a CSV file and recreates these follows, if at least one of the two actors
+// is local. Each actor can be user@host or an actor ID.
+//
+// Servers that follow local users receive the updated followers digest with the next post, and the followers
+// synchronization job synchronizes follows of remote actors.
+func (i *FollowImporter) Import(ctx context.Context, w io.Writer, r io.Reader) error {
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = 2
+	c.TrimLeadingSpace = true
+	c.Comment = '#'
+
+	var imported, existing, failed int
+	for {
+		record, err := c.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read follows: %w", err)
+		}
+
+		line, _ := c.FieldPos(0)
+
+		// skip the header, if there is one
+		if line == 1 && !strings.Contains(record[0], "@") && !strings.HasPrefix(record[0], "https://") {
+			continue
+		}
+
+		if ok, err := i.importFollow(ctx, record[0], record[1]); err != nil {
+			fmt.Fprintf(w, "Line %d: %v\n", line, err)
+			failed++
+		} else if ok {
+			imported++
+		} else {
+			existing++
+		}
+	}
+
+	fmt.Fprintf(w, "Imported %d follows, %d already exist, %d failed\n", imported, existing, failed)
+
+	if failed > 0 {
+		return ErrImportIncomplete
+	}
+
+	return nil
+}
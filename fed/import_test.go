@@ -0,0 +1,99 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/front/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowImporter_Import(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{})
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	_, key, err := user.CreateNobody(context.Background(), q.Domain, q.Config, db)
+	assert.NoError(err)
+
+	_, err = db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://ip6-allrouters/user/erin",
+		`{"type":"Person","id":"https://ip6-allrouters/user/erin","preferredUsername":"erin","inbox":"https://ip6-allrouters/inbox/erin"}`,
+	)
+	assert.NoError(err)
+
+	_, err = db.Exec(`insert into follows_sync(actor, url, digest, changed) values('https://ip6-allnodes/user/dan', 'https://ip6-allnodes/followers_synchronization/dan', 'x', unixepoch())`)
+	assert.NoError(err)
+
+	importer := FollowImporter{
+		Domain:   q.Domain,
+		Config:   q.Config,
+		DB:       db,
+		Resolver: q.Resolver,
+		Key:      key,
+	}
+
+	var buf bytes.Buffer
+	assert.ErrorIs(
+		importer.Import(
+			context.Background(),
+			&buf,
+			strings.NewReader(`Follower,Followed
+https://ip6-allnodes/user/dan,https://localhost.localdomain/user/alice
+@dan@ip6-allnodes,alice@localhost.localdomain
+# alice follows dan
+https://localhost.localdomain/user/alice,dan@ip6-allnodes
+https://ip6-allrouters/user/erin,https://localhost.localdomain/user/alice
+dan@ip6-allnodes,https://ip6-allrouters/user/erin
+https://localhost.localdomain/user/bob,https://ip6-allnodes/user/dan
+alice@localhost.localdomain,alice@localhost.localdomain
+alice,dan
+`),
+		),
+		ErrImportIncomplete,
+	)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(lines, 5)
+	assert.Equal("Line 7: neither https://ip6-allnodes/user/dan nor https://ip6-allrouters/user/erin is local", lines[0])
+	assert.Contains(lines[1], "Line 8: failed to resolve https://localhost.localdomain/user/bob")
+	assert.Equal("Line 9: https://localhost.localdomain/user/alice cannot follow itself", lines[2])
+	assert.Equal("Line 10: failed to resolve alice: actor must be user@host or an https:// URL", lines[3])
+	assert.Equal("Imported 2 follows, 2 already exist, 4 failed", lines[4])
+
+	var count int
+	assert.NoError(db.QueryRow(`select count(*) from follows where follower = 'https://localhost.localdomain/user/alice' and followed = 'https://ip6-allnodes/user/dan' and accepted = 1`).Scan(&count))
+	assert.Equal(1, count)
+
+	assert.NoError(db.QueryRow(`select count(*) from follows where follower = 'https://ip6-allrouters/user/erin' and followed = 'https://localhost.localdomain/user/alice' and accepted = 1`).Scan(&count))
+	assert.Equal(1, count)
+
+	assert.NoError(db.QueryRow(`select count(*) from follows where followed = 'https://localhost.localdomain/user/alice'`).Scan(&count))
+	assert.Equal(2, count)
+
+	var changed int64
+	assert.NoError(db.QueryRow(`select changed from follows_sync where actor = 'https://ip6-allnodes/user/dan'`).Scan(&changed))
+	assert.Less(changed, time.Now().Add(-q.Config.FollowersSyncInterval).Unix())
+}
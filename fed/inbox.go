@@ -24,12 +24,49 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/metrics"
+	"github.com/google/uuid"
 )
 
+var (
+	inboxThrottled            = metrics.NewCounter("tootik_inbox_throttled_total", "Incoming activities rejected by rate limits", "reason")
+	inboxVerificationFailures = metrics.NewCounter("tootik_inbox_verification_failures_total", "Incoming activities with an invalid signature")
+	inboxDuplicates           = metrics.NewCounter("tootik_inbox_duplicates_total", "Incoming activities dropped because they were processed recently")
+)
+
+// remoteHost returns the address of the client that sent a request, without the port.
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+// urlHost returns the host part of a URL, or an empty string if invalid.
+func urlHost(s string) string {
+	if u, err := url.Parse(s); err == nil {
+		return u.Host
+	}
+
+	return ""
+}
+
+// tooManyRequests asks the sender to retry later.
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
 func (l *Listener) getActivityOrigin(activity *ap.Activity, sender *ap.Actor) (string, bool, error) {
 	if activity.ID == "" {
 		return "", false, errors.New("unspecified activity ID")
@@ -118,13 +155,15 @@ func (l *Listener) validateActivity(activity *ap.Activity, origin string, depth
 				// actors from $origin can only follow ours
 			} else if innerUrl.Host != l.Domain {
 				return fmt.Errorf("invalid object host: %s", innerUrl.Host)
+			} else if _, ok := user.ParseID(l.Domain, l.Config, inner); !ok {
+				return fmt.Errorf("invalid object: %s", inner)
 			}
 		} else {
 			return fmt.Errorf("invalid object: %T", activity.Object)
 		}
 
-	case ap.Accept:
-		// $origin can only accept Follow activities that belong to us
+	case ap.Accept, ap.Reject:
+		// $origin can only accept or reject Follow activities that belong to us
 		switch v := activity.Object.(type) {
 		case *ap.Activity:
 			if v.Type != ap.Follow {
@@ -183,7 +222,7 @@ func (l *Listener) validateActivity(activity *ap.Activity, origin string, depth
 			return fmt.Errorf("invalid object: %T", obj)
 		}
 
-	case ap.Announce:
+	case ap.Announce, ap.Listen, ap.Read:
 		// we always unwrap nested Announce, validate the inner activity and don't allow nesting
 		if _, ok := activity.Object.(*ap.Activity); ok {
 			return errors.New("announce must not be nested")
@@ -227,19 +266,23 @@ func (l *Listener) fetchObject(ctx context.Context, id string) (bool, []byte, er
 func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 	receiver := r.PathValue("username")
 
+	// the request ID is saved with the activity, so it appears in all log lines about this activity
+	requestID := uuid.NewString()
+	log := slog.With("request", requestID)
+
 	var registered int
 	if err := l.DB.QueryRowContext(r.Context(), `select exists (select 1 from persons where actor->>'$.preferredUsername' = ? and host = ?)`, receiver, l.Domain).Scan(&registered); err != nil {
-		slog.Warn("Failed to check if receiving user exists", "receiver", receiver, "error", err)
+		log.Warn("Failed to check if receiving user exists", "receiver", receiver, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	} else if registered == 0 {
-		slog.Debug("Receiving user does not exist", "receiver", receiver)
+		log.Debug("Receiving user does not exist", "receiver", receiver)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
 	if r.ContentLength > l.Config.MaxRequestBodySize {
-		slog.Warn("Ignoring big request", "size", r.ContentLength)
+		log.Warn("Ignoring big request", "size", r.ContentLength)
 		w.WriteHeader(http.StatusRequestEntityTooLarge)
 		return
 	}
@@ -252,13 +295,40 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 
 	var activity ap.Activity
 	if err := json.Unmarshal(rawActivity, &activity); err != nil {
-		slog.Warn("Failed to unmarshal activity", "body", string(rawActivity), "error", err)
+		log.Warn("Failed to unmarshal activity", "body", string(rawActivity), "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	r.Body = io.NopCloser(bytes.NewReader(rawActivity))
 
+	claimed := urlHost(activity.Actor)
+
+	// the same activity can be forwarded by many servers: if we processed it recently, we don't verify it again
+	if activity.ID != "" {
+		var seen int
+		if err := l.DB.QueryRowContext(r.Context(), `select exists (select 1 from seen where activity = ? and inserted > ?)`, activity.ID, time.Now().Add(-l.Config.InboxDeduplicationWindow).Unix()).Scan(&seen); err != nil {
+			log.Warn("Failed to check if activity was processed recently", "activity", activity.ID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else if seen == 1 {
+			log.Debug("Ignoring recently processed activity", "activity", activity.ID, "client", r.RemoteAddr)
+			inboxDuplicates.Inc()
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	// clients that repeatedly send requests with an invalid signature are throttled temporarily: the actor is
+	// controlled by the client, so a client can't get another server throttled by sending requests on its behalf
+	client := remoteHost(r)
+	if wait := l.verificationFailures.Wait(client, time.Now(), l.Config.VerificationFailureInterval, l.Config.MaxVerificationFailures); wait > 0 {
+		log.Debug("Throttling client after repeated verification failures", "activity", activity.ID, "domain", claimed, "client", r.RemoteAddr)
+		inboxThrottled.Inc("verification")
+		tooManyRequests(w, wait)
+		return
+	}
+
 	// if actor is deleted, ignore this activity if we don't know this actor
 	var flags ap.ResolverFlag
 	if activity.Type == ap.Delete {
@@ -272,19 +342,50 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if errors.Is(err, ErrActorNotCached) {
-			slog.Debug("Ignoring Delete activity for unknown actor", "error", err)
+			log.Debug("Ignoring Delete activity for unknown actor", "error", err)
 			w.WriteHeader(http.StatusOK)
 			return
 		}
 		if errors.Is(err, ErrBlockedDomain) {
-			slog.Debug("Failed to verify activity", "activity", activity.ID, "type", activity.Type, "error", err)
+			log.Debug("Failed to verify activity", "activity", activity.ID, "type", activity.Type, "client", r.RemoteAddr, "error", err)
+			l.reject(r.Context(), activity.ID, activity.Actor, claimed, r.RemoteAddr, rejectedBlocked, false, err)
 		} else {
-			slog.Warn("Failed to verify activity", "activity", activity.ID, "type", activity.Type, "error", err)
+			log.Warn("Failed to verify activity", "activity", activity.ID, "type", activity.Type, "client", r.RemoteAddr, "error", err)
+			inboxVerificationFailures.Inc()
+			l.verificationFailures.Take(client, time.Now(), l.Config.VerificationFailureInterval, l.Config.MaxVerificationFailures)
+
+			reason := rejectedSignature
+			if errors.Is(err, ErrYoungActor) {
+				reason = rejectedYoung
+			}
+			l.reject(r.Context(), activity.ID, activity.Actor, claimed, r.RemoteAddr, reason, false, err)
 		}
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
+	// the sender can retry later if it sends too many activities in a short time
+	senderHost := urlHost(sender.ID)
+	if wait := l.inboxRequests.Take(senderHost, time.Now(), l.Config.InboxRequestInterval, l.Config.InboxRequestBurst); wait > 0 {
+		log.Debug("Throttling domain", "activity", activity.ID, "sender", sender.ID)
+		inboxThrottled.Inc("rate")
+		tooManyRequests(w, wait)
+		return
+	}
+
+	// the same activity can be delivered to multiple local users, or to both a personal inbox and the shared inbox
+	// (the same activity forwarded by another sender is ignored when inserted into the queue)
+	var queuedBefore int
+	if err := l.DB.QueryRowContext(r.Context(), `select exists (select 1 from inbox where sender = ? and raw->>'$.id' = ?)`, sender.ID, activity.ID).Scan(&queuedBefore); err != nil {
+		log.Warn("Failed to check if activity is already queued", "activity", activity.ID, "sender", sender.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if queuedBefore == 1 {
+		log.Debug("Activity is already queued", "activity", activity.ID, "sender", sender.ID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	/*
 		we have 4 activities:
 		1. the one we received, in its JSON form (rawActivity): we need it in case we're going to forward it
@@ -302,13 +403,13 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 		different servers, we need to fetch the latter from its origin; in other words, the Announce that wraps an
 		activity shouldn't change the validation flow because it's not the Announce that needs to be validated
 	*/
-	for queued.Type == ap.Announce {
+	for queued.Type == ap.Announce || queued.Type == ap.Listen || queued.Type == ap.Read {
 		if inner, ok := queued.Object.(*ap.Activity); ok {
 			queued = inner
 		} else if o, ok := queued.Object.(*ap.Object); ok {
-			slog.Debug("Wrapping object with Update activity", "activity", activity.ID, "sender", sender.ID, "object", o.ID)
+			log.Debug("Wrapping object with Update activity", "activity", activity.ID, "sender", sender.ID, "object", o.ID)
 
-			// hack for Lemmy: wrap a Page inside Announce with Update
+			// hack for Lemmy: wrap a Page inside Announce with Update (Funkwhale does the same with Listen)
 			queued = &ap.Activity{
 				ID:     o.ID,
 				Type:   ap.Update,
@@ -328,18 +429,19 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 	*/
 	origin, forwarded, err := l.getActivityOrigin(queued, sender)
 	if err != nil {
-		slog.Warn("Failed to determine whether or not activity is forwarded", "activity", activity.ID, "sender", sender.ID, "error", err)
+		log.Warn("Failed to determine whether or not activity is forwarded", "activity", activity.ID, "sender", sender.ID, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	/* if we don't support this activity or it's invalid, we don't want to fetch it (we validate again later) */
 	if err := l.validateActivity(queued, origin, 0); errors.Is(err, ap.ErrUnsupportedActivity) {
-		slog.Debug("Activity is unsupported", "activity", activity.ID, "sender", sender.ID, "error", err)
+		log.Debug("Activity is unsupported", "activity", activity.ID, "sender", sender.ID, "error", err)
 		w.WriteHeader(http.StatusOK)
 		return
 	} else if err != nil {
-		slog.Warn("Activity is invalid", "activity", activity.ID, "sender", sender.ID, "error", err)
+		log.Warn("Activity is invalid", "activity", activity.ID, "sender", sender.ID, "error", err)
+		l.reject(r.Context(), activity.ID, sender.ID, senderHost, r.RemoteAddr, rejectedInvalid, true, err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	} else if forwarded {
@@ -352,13 +454,13 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 			case string:
 				id = o
 			default:
-				slog.Warn("Ignoring invalid forwarded Delete activity", "activity", activity.ID, "sender", sender.ID)
+				log.Warn("Ignoring invalid forwarded Delete activity", "activity", activity.ID, "sender", sender.ID)
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 		}
 
-		slog.Info("Fetching forwarded object", "activity", activity.ID, "id", id, "sender", sender.ID)
+		log.Info("Fetching forwarded object", "activity", activity.ID, "id", id, "sender", sender.ID)
 
 		if exists, fetched, err := l.fetchObject(r.Context(), id); !exists && queued.Type == ap.Delete {
 			queued = &ap.Activity{
@@ -368,11 +470,11 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 				Object: id,
 			}
 		} else if err == nil && exists && activity.Type == ap.Delete {
-			slog.Warn("Ignoring forwarded Delete activity for existing object", "activity", activity.ID, "id", id, "sender", sender.ID)
+			log.Warn("Ignoring forwarded Delete activity for existing object", "activity", activity.ID, "id", id, "sender", sender.ID)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		} else if err != nil {
-			slog.Warn("Failed to fetch forwarded object", "activity", activity.ID, "id", id, "sender", sender.ID, "error", err)
+			log.Warn("Failed to fetch forwarded object", "activity", activity.ID, "id", id, "sender", sender.ID, "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		} else if queued.Type == ap.Update {
@@ -381,12 +483,12 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 				// hack for Mastodon: we get the updated Note when we fetch an Update activity
 				var post ap.Object
 				if err := json.Unmarshal([]byte(fetched), &post); err != nil {
-					slog.Warn("Ignoring invalid forwarded Update activity", "activity", activity.ID, "sender", sender.ID, "error", err)
+					log.Warn("Ignoring invalid forwarded Update activity", "activity", activity.ID, "sender", sender.ID, "error", err)
 					w.WriteHeader(http.StatusBadRequest)
 					return
 				}
 
-				slog.Debug("Wrapping forwarded Update activity", "activity", activity.ID, "sender", sender.ID)
+				log.Debug("Wrapping forwarded Update activity", "activity", activity.ID, "sender", sender.ID)
 				queued = &ap.Activity{
 					ID:     queued.ID,
 					Type:   ap.Update,
@@ -399,7 +501,7 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 		} else {
 			var parsed ap.Activity
 			if err := json.Unmarshal([]byte(fetched), &parsed); err != nil {
-				slog.Warn("Ignoring invalid forwarded activity", "activity", activity.ID, "sender", sender.ID, "error", err)
+				log.Warn("Ignoring invalid forwarded activity", "activity", activity.ID, "sender", sender.ID, "error", err)
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
@@ -409,11 +511,12 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 
 		// we must validate the original activity because the forwarded one can be valid while the original isn't
 		if err := l.validateActivity(queued, origin, 0); errors.Is(err, ap.ErrUnsupportedActivity) {
-			slog.Debug("Activity is unsupported", "activity", activity.ID, "sender", sender.ID, "error", err)
+			log.Debug("Activity is unsupported", "activity", activity.ID, "sender", sender.ID, "error", err)
 			w.WriteHeader(http.StatusOK)
 			return
 		} else if err != nil {
-			slog.Warn("Activity is invalid", "activity", activity.ID, "sender", sender.ID, "error", err)
+			log.Warn("Activity is invalid", "activity", activity.ID, "sender", sender.ID, "error", err)
+			l.reject(r.Context(), activity.ID, sender.ID, senderHost, r.RemoteAddr, rejectedInvalid, true, err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -421,20 +524,32 @@ func (l *Listener) handleInbox(w http.ResponseWriter, r *http.Request) {
 
 	if _, err = l.DB.ExecContext(
 		r.Context(),
-		`INSERT OR IGNORE INTO inbox (sender, activity, raw) VALUES(?,?,?)`,
+		`INSERT OR IGNORE INTO inbox (sender, activity, raw, request) VALUES(?,?,?,?)`,
 		sender.ID,
 		queued,
 		string(rawActivity),
+		requestID,
 	); err != nil {
-		slog.Error("Failed to insert activity", "sender", sender.ID, "error", err)
+		log.Error("Failed to insert activity", "sender", sender.ID, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	// a sender can't get activities by other servers dropped by sending an activity with the same ID first
+	if urlHost(activity.ID) != senderHost {
+		log.Debug("Not recording forwarded activity as processed", "activity", activity.ID, "sender", sender.ID)
+	} else if _, err = l.DB.ExecContext(
+		r.Context(),
+		`INSERT OR IGNORE INTO seen (activity) VALUES(?)`,
+		activity.ID,
+	); err != nil {
+		log.Warn("Failed to record activity as processed", "activity", activity.ID, "error", err)
+	}
+
 	followersSync := r.Header.Get("Collection-Synchronization")
 	if followersSync != "" {
 		if err := l.saveFollowersDigest(r.Context(), sender, followersSync); err != nil {
-			slog.Warn("Failed to save followers sync header", "sender", sender.ID, "header", followersSync, "error", err)
+			log.Warn("Failed to save followers sync header", "sender", sender.ID, "header", followersSync, "error", err)
 		}
 	}
 
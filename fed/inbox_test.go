@@ -0,0 +1,441 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInbox_SharedInboxDedup(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, nobodyKey, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	_, _, err = user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	_, _, err = user.Create(context.Background(), "localhost.localdomain", &cfg, db, "bob", ap.Person, nil)
+	assert.NoError(err)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	assert.NoError(err)
+
+	publicKeyPem, err := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	assert.NoError(err)
+
+	for _, name := range []string{"dan", "g1", "g2"} {
+		_, err = db.Exec(
+			`insert into persons (id, actor) values(?,?)`,
+			"https://0.0.0.0/user/"+name,
+			fmt.Sprintf(`{"type":"Person","id":"https://0.0.0.0/user/%s","preferredUsername":"%s","inbox":"https://0.0.0.0/inbox/%s","publicKey":{"id":"https://0.0.0.0/user/%s#main-key","owner":"https://0.0.0.0/user/%s","publicKeyPem":%s}}`, name, name, name, name, name, publicKeyPem),
+		)
+		assert.NoError(err)
+	}
+
+	client := newTestClient(map[string]testResponse{})
+
+	l := Listener{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(&BlockList{}, "localhost.localdomain", &cfg, &client, db),
+		ActorKey: nobodyKey,
+	}
+
+	deliver := func(sender, receiver, body string) int {
+		req := newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/user/" + sender + "#main-key", PrivateKey: priv}, body, time.Now())
+		req.SetPathValue("username", receiver)
+
+		w := httptest.NewRecorder()
+		l.handleInbox(w, req)
+		return w.Code
+	}
+
+	create := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/create/1","type":"Create","actor":"https://0.0.0.0/user/dan","object":{"id":"https://0.0.0.0/note/1","type":"Note","attributedTo":"https://0.0.0.0/user/dan","content":"hello","to":["https://localhost.localdomain/user/alice","https://localhost.localdomain/user/bob"]},"to":["https://localhost.localdomain/user/alice","https://localhost.localdomain/user/bob"]}`
+
+	assert.Equal(http.StatusOK, deliver("dan", "alice", create))
+	assert.Equal(http.StatusOK, deliver("dan", "bob", create))
+	assert.Equal(http.StatusOK, deliver("dan", "nobody", create))
+
+	// the same activity forwarded by another sender is not queued again
+	assert.Equal(http.StatusOK, deliver("g1", "nobody", create))
+
+	var count int
+	assert.NoError(db.QueryRow(`select count(*) from inbox`).Scan(&count))
+	assert.Equal(1, count)
+
+	// the same post shared by two groups is queued twice, so both shares are recorded
+	for _, group := range []string{"g1", "g2"} {
+		announce := fmt.Sprintf(`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/announce/%s/1","type":"Announce","actor":"https://0.0.0.0/user/%s","object":%s,"to":["https://www.w3.org/ns/activitystreams#Public"]}`, group, group, create)
+		assert.Equal(http.StatusOK, deliver(group, "nobody", announce))
+		assert.Equal(http.StatusOK, deliver(group, "alice", announce))
+	}
+
+	assert.NoError(db.QueryRow(`select count(*) from inbox`).Scan(&count))
+	assert.Equal(3, count)
+
+	// each queued activity has the ID of the request that delivered it
+	assert.NoError(db.QueryRow(`select count(distinct request) from inbox`).Scan(&count))
+	assert.Equal(3, count)
+}
+
+func TestInbox_UnsupportedUndo(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, nobodyKey, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	_, _, err = user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	assert.NoError(err)
+
+	publicKeyPem, err := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	assert.NoError(err)
+
+	_, err = db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://0.0.0.0/user/dan",
+		fmt.Sprintf(`{"type":"Person","id":"https://0.0.0.0/user/dan","preferredUsername":"dan","inbox":"https://0.0.0.0/inbox/dan","publicKey":{"id":"https://0.0.0.0/user/dan#main-key","owner":"https://0.0.0.0/user/dan","publicKeyPem":%s}}`, publicKeyPem),
+	)
+	assert.NoError(err)
+
+	client := newTestClient(map[string]testResponse{})
+
+	l := Listener{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(&BlockList{}, "localhost.localdomain", &cfg, &client, db),
+		ActorKey: nobodyKey,
+	}
+
+	for _, body := range []string{
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/block/1","type":"Block","actor":"https://0.0.0.0/user/dan","object":"https://localhost.localdomain/user/alice"}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/undo/1","type":"Undo","actor":"https://0.0.0.0/user/dan","object":{"id":"https://0.0.0.0/block/1","type":"Block","actor":"https://0.0.0.0/user/dan","object":"https://localhost.localdomain/user/alice"}}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/undo/2","type":"Undo","actor":"https://0.0.0.0/user/dan","object":{"id":"https://0.0.0.0/like/1","type":"Like","actor":"https://0.0.0.0/user/dan","object":"https://localhost.localdomain/post/1"}}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/undo/3","type":"Undo","actor":"https://0.0.0.0/user/dan","object":{"id":"https://0.0.0.0/react/1","type":"EmojiReact","actor":"https://0.0.0.0/user/dan","object":"https://localhost.localdomain/post/1","content":"👍"}}`,
+	} {
+		req := newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/user/dan#main-key", PrivateKey: priv}, body, time.Now())
+		req.SetPathValue("username", "alice")
+
+		w := httptest.NewRecorder()
+		l.handleInbox(w, req)
+		assert.Equal(http.StatusOK, w.Code)
+	}
+
+	var count int
+	assert.NoError(db.QueryRow(`select count(*) from inbox`).Scan(&count))
+	assert.Equal(0, count)
+}
+
+func newInboxTestListener(t *testing.T, cfg *cfg.Config) (*Listener, *rsa.PrivateKey) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	t.Cleanup(func() { os.Remove(path) })
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	t.Cleanup(func() { db.Close() })
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, nobodyKey, err := user.CreateNobody(context.Background(), "localhost.localdomain", cfg, db)
+	assert.NoError(err)
+
+	_, _, err = user.Create(context.Background(), "localhost.localdomain", cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	assert.NoError(err)
+
+	publicKeyPem, err := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	assert.NoError(err)
+
+	_, err = db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://0.0.0.0/user/dan",
+		fmt.Sprintf(`{"type":"Person","id":"https://0.0.0.0/user/dan","preferredUsername":"dan","inbox":"https://0.0.0.0/inbox/dan","publicKey":{"id":"https://0.0.0.0/user/dan#main-key","owner":"https://0.0.0.0/user/dan","publicKeyPem":%s}}`, publicKeyPem),
+	)
+	assert.NoError(err)
+
+	client := newTestClient(map[string]testResponse{})
+
+	return &Listener{
+		Domain:   "localhost.localdomain",
+		Config:   cfg,
+		DB:       db,
+		Resolver: NewResolver(&BlockList{}, "localhost.localdomain", cfg, &client, db),
+		ActorKey: nobodyKey,
+	}, priv
+}
+
+func TestInbox_RateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+	cfg.InboxRequestBurst = 2
+	cfg.InboxRequestInterval = time.Hour
+
+	l, priv := newInboxTestListener(t, &cfg)
+
+	for i, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		body := fmt.Sprintf(`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/block/%d","type":"Block","actor":"https://0.0.0.0/user/dan","object":"https://localhost.localdomain/user/alice"}`, i)
+		req := newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/user/dan#main-key", PrivateKey: priv}, body, time.Now())
+		req.SetPathValue("username", "alice")
+
+		w := httptest.NewRecorder()
+		l.handleInbox(w, req)
+		assert.Equal(expected, w.Code)
+
+		if expected == http.StatusTooManyRequests {
+			assert.Equal("3600", w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestInbox_VerificationFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+	cfg.MaxVerificationFailures = 2
+	cfg.VerificationFailureInterval = time.Hour
+
+	l, priv := newInboxTestListener(t, &cfg)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	for i, test := range []struct {
+		Key      *rsa.PrivateKey
+		Client   string
+		Expected int
+	}{
+		{other, "192.0.2.1:1234", http.StatusUnauthorized},
+		{other, "192.0.2.1:1234", http.StatusUnauthorized},
+		// the client is throttled even if the signature is valid
+		{priv, "192.0.2.1:5678", http.StatusTooManyRequests},
+		// the same actor is unaffected when its activities are sent by another client
+		{priv, "192.0.2.2:1234", http.StatusOK},
+	} {
+		body := fmt.Sprintf(`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/block/%d","type":"Block","actor":"https://0.0.0.0/user/dan","object":"https://localhost.localdomain/user/alice"}`, i)
+		req := newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/user/dan#main-key", PrivateKey: test.Key}, body, time.Now())
+		req.SetPathValue("username", "alice")
+		req.RemoteAddr = test.Client
+
+		w := httptest.NewRecorder()
+		l.handleInbox(w, req)
+		assert.Equal(test.Expected, w.Code)
+	}
+}
+
+func TestInbox_Rejections(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+	cfg.MaxRejections = 2
+
+	l, priv := newInboxTestListener(t, &cfg)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	for i := range 3 {
+		body := fmt.Sprintf(`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/block/%d","type":"Block","actor":"https://0.0.0.0/user/dan","object":"https://localhost.localdomain/user/alice"}`, i)
+		req := newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/user/dan#main-key", PrivateKey: other}, body, time.Now())
+		req.SetPathValue("username", "alice")
+
+		w := httptest.NewRecorder()
+		l.handleInbox(w, req)
+		assert.Equal(http.StatusUnauthorized, w.Code)
+	}
+
+	body := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/create/1","type":"Create","actor":"https://0.0.0.0/user/dan","object":{"id":"https://1.1.1.1/note/1","type":"Note","attributedTo":"https://0.0.0.0/user/dan","content":"hello","to":["https://www.w3.org/ns/activitystreams#Public"]}}`
+	req := newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/user/dan#main-key", PrivateKey: priv}, body, time.Now())
+	req.SetPathValue("username", "alice")
+
+	w := httptest.NewRecorder()
+	l.handleInbox(w, req)
+	assert.Equal(http.StatusBadRequest, w.Code)
+
+	rows, err := l.DB.Query(`select activity, sender, host, reason, verified from rejections order by rowid`)
+	assert.NoError(err)
+	defer rows.Close()
+
+	var rejections [][5]string
+	for rows.Next() {
+		var row [5]string
+		assert.NoError(rows.Scan(&row[0], &row[1], &row[2], &row[3], &row[4]))
+		rejections = append(rejections, row)
+	}
+
+	assert.Equal(
+		[][5]string{
+			{"https://0.0.0.0/block/2", "https://0.0.0.0/user/dan", "0.0.0.0", rejectedSignature, "0"},
+			{"https://0.0.0.0/create/1", "https://0.0.0.0/user/dan", "0.0.0.0", rejectedInvalid, "1"},
+		},
+		rejections,
+	)
+}
+
+func TestInbox_UnverifiedRejectionsSampled(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+	cfg.MaxVerificationFailures = unverifiedRejectionBurst * 2
+
+	l, _ := newInboxTestListener(t, &cfg)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	for i := range unverifiedRejectionBurst * 2 {
+		body := fmt.Sprintf(`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/block/%d","type":"Block","actor":"https://0.0.0.0/user/dan","object":"https://localhost.localdomain/user/alice"}`, i)
+		req := newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/user/dan#main-key", PrivateKey: other}, body, time.Now())
+		req.SetPathValue("username", "alice")
+
+		w := httptest.NewRecorder()
+		l.handleInbox(w, req)
+		assert.Equal(http.StatusUnauthorized, w.Code)
+	}
+
+	var count int
+	assert.NoError(l.DB.QueryRow(`select count(*) from rejections`).Scan(&count))
+	assert.Equal(unverifiedRejectionBurst, count)
+}
+
+func TestInbox_RecentlyProcessed(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	l, priv := newInboxTestListener(t, &cfg)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	deliver := func(key *rsa.PrivateKey, body string) int {
+		req := newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/user/dan#main-key", PrivateKey: key}, body, time.Now())
+		req.SetPathValue("username", "alice")
+
+		w := httptest.NewRecorder()
+		l.handleInbox(w, req)
+		return w.Code
+	}
+
+	create := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://0.0.0.0/create/1","type":"Create","actor":"https://0.0.0.0/user/dan","object":{"id":"https://0.0.0.0/note/1","type":"Note","attributedTo":"https://0.0.0.0/user/dan","content":"hello","to":["https://localhost.localdomain/user/alice"]},"to":["https://localhost.localdomain/user/alice"]}`
+
+	assert.Equal(http.StatusOK, deliver(priv, create))
+
+	// a replay of a recently processed activity is dropped before signature verification
+	assert.Equal(http.StatusOK, deliver(other, create))
+
+	var count int
+	assert.NoError(l.DB.QueryRow(`select count(*) from inbox`).Scan(&count))
+	assert.Equal(1, count)
+
+	assert.NoError(l.DB.QueryRow(`select count(*) from rejections`).Scan(&count))
+	assert.Equal(0, count)
+
+	// activities are forgotten after a while
+	_, err = l.DB.Exec(`update seen set inserted = inserted - 60*60*24*2`)
+	assert.NoError(err)
+
+	assert.Equal(http.StatusUnauthorized, deliver(other, create))
+
+	assert.NoError(l.DB.QueryRow(`select count(*) from rejections`).Scan(&count))
+	assert.Equal(1, count)
+}
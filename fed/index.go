@@ -19,6 +19,12 @@ package fed
 import "net/http"
 
 func (l *Listener) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if l.Frontend != nil && shouldRedirect(r) {
+		w.Header().Set("Location", "/web/local")
+		w.WriteHeader(http.StatusFound)
+		return
+	}
+
 	w.Header().Set("Location", "gemini://"+l.Domain)
 	w.WriteHeader(http.StatusMovedPermanently)
 }
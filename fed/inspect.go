@@ -0,0 +1,174 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/httpsig"
+)
+
+// Inspector prints what this server knows about other servers, for debugging federation from the command line.
+type Inspector struct {
+	Domain   string
+	Config   *cfg.Config
+	DB       *sql.DB
+	Resolver *Resolver
+	Key      httpsig.Key
+}
+
+// Peers prints all known domains, the number of local users they follow and are followed by, and the outcome of the
+// last delivery to each domain.
+func (i *Inspector) Peers(ctx context.Context, w io.Writer) error {
+	rows, err := i.DB.QueryContext(
+		ctx,
+		`select peers.host, (
+			select count(*) from follows
+			join persons on persons.id = follows.follower
+			where persons.host = peers.host and follows.accepted = 1
+		), (
+			select count(*) from follows
+			join persons on persons.id = follows.followed
+			where persons.host = peers.host and follows.accepted = 1
+		), domains.lastsuccess, coalesce(domains.failures, 0), coalesce(domains.paused, 0), deliverylog.status, deliverylog.error
+		from (
+			select host from persons where host != $1
+			union
+			select host from domains
+		) peers
+		left join domains on domains.host = peers.host
+		left join deliverylog on deliverylog.rowid = (select rowid from deliverylog where deliverylog.host = peers.host order by updated desc limit 1)
+		order by peers.host`,
+		i.Domain,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list peers: %w", err)
+	}
+	defer rows.Close()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tFOLLOWERS\tFOLLOWING\tLAST SUCCESS\tFAILURES\tLAST STATUS")
+
+	for rows.Next() {
+		var host string
+		var followers, following, failures int64
+		var paused bool
+		var lastSuccess, status sql.NullInt64
+		var lastError sql.NullString
+		if err := rows.Scan(&host, &followers, &following, &lastSuccess, &failures, &paused, &status, &lastError); err != nil {
+			return fmt.Errorf("failed to scan peer: %w", err)
+		}
+
+		success := "never"
+		if lastSuccess.Valid {
+			success = time.Unix(lastSuccess.Int64, 0).UTC().Format(time.DateTime)
+		}
+
+		last := "-"
+		if lastError.Valid {
+			last = lastError.String
+		} else if status.Valid {
+			last = strconv.FormatInt(status.Int64, 10)
+		}
+		if paused {
+			last = "paused, " + last
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\t%s\n", host, followers, following, success, failures, last)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list peers: %w", err)
+	}
+
+	return tw.Flush()
+}
+
+func (i *Inspector) get(ctx context.Context, url string) ([]byte, error) {
+	resp, err := i.Resolver.Get(ctx, i.Key, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > i.Config.MaxResponseBodySize {
+		return nil, fmt.Errorf("failed to fetch %s: response is too big", url)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, i.Config.MaxResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+
+	return body, nil
+}
+
+// Fetch fetches an object or an actor (if target is user@host) and prints it, without caching it.
+func (i *Inspector) Fetch(ctx context.Context, w io.Writer, target string) error {
+	if name, host, ok := strings.Cut(target, "@"); ok && !strings.Contains(target, "/") {
+		body, err := i.get(ctx, fmt.Sprintf("https://%s/.well-known/webfinger?resource=acct:%s@%s", host, name, host))
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", target, err)
+		}
+
+		var webFinger webFingerResponse
+		if err := json.Unmarshal(body, &webFinger); err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", target, err)
+		}
+
+		target = ""
+		for _, link := range webFinger.Links {
+			if link.Rel == "self" && (link.Type == "application/activity+json" || link.Type == `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`) && link.Href != "" {
+				target = link.Href
+				break
+			}
+		}
+
+		if target == "" {
+			return fmt.Errorf("failed to resolve %s@%s: no profile link", name, host)
+		}
+	}
+
+	if !strings.HasPrefix(target, "https://") {
+		return errors.New("target must be user@host or an https:// URL")
+	}
+
+	body, err := i.get(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "\t"); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", target, err)
+	}
+	buf.WriteByte('\n')
+
+	_, err = buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/front/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspector_Peers(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{})
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	_, err := db.Exec(`insert into domains(host, lastsuccess, failures) values('ip6-allnodes', 1700000000, 2)`)
+	assert.NoError(err)
+
+	_, err = db.Exec(`insert into deliverylog(activity, inbox, host, status, attempts, error) values('https://localhost.localdomain/create/1', 'https://ip6-allnodes/inbox/dan', 'ip6-allnodes', 500, 1, 'failed to send request')`)
+	assert.NoError(err)
+
+	_, err = db.Exec(`insert into domains(host, paused) values('ip6-allrouters', 1)`)
+	assert.NoError(err)
+
+	inspector := Inspector{
+		Domain:   q.Domain,
+		Config:   q.Config,
+		DB:       db,
+		Resolver: q.Resolver,
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(inspector.Peers(context.Background(), &buf))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(lines, 3)
+	assert.Equal([]string{"DOMAIN", "FOLLOWERS", "FOLLOWING", "LAST", "SUCCESS", "FAILURES", "LAST", "STATUS"}, strings.Fields(lines[0]))
+	assert.Equal([]string{"ip6-allnodes", "1", "0", "2023-11-14", "22:13:20", "2", "failed", "to", "send", "request"}, strings.Fields(lines[1]))
+	assert.Equal([]string{"ip6-allrouters", "0", "0", "never", "0", "paused,", "-"}, strings.Fields(lines[2]))
+}
+
+func TestInspector_Fetch(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/note/1": {
+			Response: newTestResponse(http.StatusOK, `{"id":"https://ip6-allnodes/note/1","type":"Note"}`),
+		},
+	})
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	_, key, err := user.CreateNobody(context.Background(), q.Domain, q.Config, db)
+	assert.NoError(err)
+
+	inspector := Inspector{
+		Domain:   q.Domain,
+		Config:   q.Config,
+		DB:       db,
+		Resolver: q.Resolver,
+		Key:      key,
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(inspector.Fetch(context.Background(), &buf, "https://ip6-allnodes/note/1"))
+	assert.Equal("{\n\t\"id\": \"https://ip6-allnodes/note/1\",\n\t\"type\": \"Note\"\n}\n", buf.String())
+
+	assert.Error(inspector.Fetch(context.Background(), &buf, "http://ip6-allnodes/note/1"))
+}
+
+func TestInspector_FetchActor(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newTestClient(map[string]testResponse{
+		"https://ip6-allnodes/.well-known/webfinger?resource=acct:erin@ip6-allnodes": {
+			Response: newTestResponse(http.StatusOK, `{"subject":"acct:erin@ip6-allnodes","links":[{"rel":"self","type":"application/activity+json","href":"https://ip6-allnodes/user/erin"}]}`),
+		},
+		"https://ip6-allnodes/user/erin": {
+			Response: newTestResponse(http.StatusOK, `{"id":"https://ip6-allnodes/user/erin","type":"Person","preferredUsername":"erin"}`),
+		},
+	})
+	q, db, cleanup := newDomainsTestQueue(t, &client)
+	defer cleanup()
+
+	_, key, err := user.CreateNobody(context.Background(), q.Domain, q.Config, db)
+	assert.NoError(err)
+
+	inspector := Inspector{
+		Domain:   q.Domain,
+		Config:   q.Config,
+		DB:       db,
+		Resolver: q.Resolver,
+		Key:      key,
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(inspector.Fetch(context.Background(), &buf, "erin@ip6-allnodes"))
+	assert.Equal("{\n\t\"id\": \"https://ip6-allnodes/user/erin\",\n\t\"type\": \"Person\",\n\t\"preferredUsername\": \"erin\"\n}\n", buf.String())
+
+	var cached int
+	assert.NoError(db.QueryRow(`select exists (select 1 from persons where id = 'https://ip6-allnodes/user/erin')`).Scan(&cached))
+	assert.Equal(0, cached)
+}
@@ -18,163 +18,220 @@ package fed
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"database/sql"
 	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
-	"math"
 	"net"
 	"net/http"
-	"path/filepath"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/certs"
 	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
 	"github.com/dimkr/tootik/httpsig"
-	"github.com/fsnotify/fsnotify"
 )
 
 type Listener struct {
-	Domain   string
-	Closed   bool
-	Config   *cfg.Config
-	DB       *sql.DB
-	Resolver *Resolver
-	ActorKey httpsig.Key
-	Addr     string
-	Cert     string
-	Key      string
-	Plain    bool
+	Domain    string
+	Closed    bool
+	Config    *cfg.Config
+	DB        *sql.DB
+	Resolver  *Resolver
+	ActorKey  httpsig.Key
+	Addr      string
+	Cert      string
+	Key       string
+	ACMECache string
+	ACMEAddr  string
+	Plain     bool
+	Frontend  http.Handler
+
+	keys                 ttlCache[string, verificationKey]
+	signatures           ttlCache[[sha256.Size]byte, ap.Actor]
+	inboxRequests        tokenBuckets[string]
+	verificationFailures tokenBuckets[string]
+	unverifiedRejections tokenBuckets[string]
 }
 
-const certReloadDelay = time.Second * 5
-
-// ListenAndServe handles HTTP requests from other servers.
-func (l *Listener) ListenAndServe(ctx context.Context) error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /robots.txt", robots)
+func (l *Listener) newMux() (*http.ServeMux, error) {
+	mux := &serveMux{ServeMux: http.NewServeMux()}
+	mux.HandleFunc("GET /robots.txt", l.robots)
 	mux.HandleFunc("GET /.well-known/webfinger", l.handleWebFinger)
-	mux.HandleFunc("GET /user/{username}", l.handleUser)
 	mux.HandleFunc("GET /icon/{username}", l.handleIcon)
-	mux.HandleFunc("POST /inbox/{username}", l.handleInbox)
-	mux.HandleFunc("GET /outbox/{username}", l.handleOutbox)
+	mux.HandleFunc("GET /avatar/{size}/{hash}", l.handleAvatar)
+	mux.HandleFunc("GET /header/{username}", l.handleHeader)
 	mux.HandleFunc("GET /post/{hash}", l.handlePost)
+	mux.HandleFunc("GET /replies/{hash}", l.handleReplies)
 	mux.HandleFunc("GET /create/{hash}", l.handleCreate)
 	mux.HandleFunc("GET /update/{hash}", l.handleUpdate)
 	mux.HandleFunc("GET /followers_synchronization/{username}", l.handleFollowers)
+	mux.HandleFunc("GET /oembed", l.handleOEmbed)
+	mux.HandleFunc("GET /hashtag/{tag}/rss", l.handleHashtagRSS)
 	mux.HandleFunc("GET /{$}", l.handleIndex)
 
+	if l.Frontend != nil {
+		mux.HandleFunc("GET /web/{path...}", func(w http.ResponseWriter, r *http.Request) {
+			if !l.authorizeFetch(w, r) {
+				return
+			}
+
+			l.Frontend.ServeHTTP(w, r)
+		})
+	}
+
+	if l.Config.RequireInvitation && !l.Closed {
+		mux.HandleFunc("GET /register", l.handleRegisterForm)
+		mux.HandleFunc("POST /register", l.handleRegister)
+	}
+
 	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
 		slog.Debug("Received request to non-existing path", "path", r.URL.Path)
 		w.WriteHeader(http.StatusNotFound)
 	})
 
 	if err := addNodeInfo(mux, l.Domain, l.Closed, l.Config, l.DB); err != nil {
-		return err
+		return nil, err
 	}
 
 	addHostMeta(mux, l.Domain)
 
-	w, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
+	if err := checkPaths(l.Config, mux.patterns); err != nil {
+		return nil, err
 	}
-	defer w.Close()
 
-	certDir := filepath.Dir(l.Cert)
-	certAbsPath := filepath.Join(certDir, filepath.Base(l.Cert))
-
-	keyDir := filepath.Dir(l.Key)
-	keyAbsPath := filepath.Join(keyDir, filepath.Base(l.Key))
+	// actors created before a path change are still reachable through the old path
+	for _, pattern := range user.ActorPaths(l.Config) {
+		mux.HandleFunc("GET "+pattern, l.handleUser)
+	}
 
-	if !l.Plain {
-		if err := w.Add(certDir); err != nil {
-			return err
-		}
+	for _, pattern := range user.InboxPaths(l.Config) {
+		mux.HandleFunc("POST "+pattern, l.handleInbox)
+	}
 
-		if keyDir != certDir {
-			if err := w.Add(keyDir); err != nil {
-				return err
-			}
-		}
+	for _, pattern := range user.OutboxPaths(l.Config) {
+		mux.HandleFunc("GET "+pattern, l.handleOutbox)
 	}
 
-	for ctx.Err() == nil {
-		var wg sync.WaitGroup
-		serverCtx, stopServer := context.WithCancel(ctx)
-
-		server := http.Server{
-			Addr:    l.Addr,
-			Handler: http.TimeoutHandler(mux, time.Second*30, ""),
-			BaseContext: func(net.Listener) context.Context {
-				return serverCtx
-			},
-			ReadTimeout: time.Second * 30,
-			TLSConfig: &tls.Config{
-				MinVersion: tls.VersionTLS12,
-			},
-		}
+	return mux.ServeMux, nil
+}
 
-		wg.Add(1)
-		go func() {
-			<-serverCtx.Done()
+// ListenAndServe handles HTTP requests from other servers.
+func (l *Listener) ListenAndServe(ctx context.Context) error {
+	mux, err := l.newMux()
+	if err != nil {
+		return err
+	}
 
-			// shut down gracefully only on reload
-			if ctx.Err() == nil {
-				slog.Info("Shutting down server")
-				server.Shutdown(ctx)
-			}
+	trusted, err := parseProxies(l.Config.TrustedProxies)
+	if err != nil {
+		return err
+	}
 
-			server.Close()
-			wg.Done()
-		}()
+	// requests received over a unix socket are always sent by a reverse proxy
+	socket, unix := strings.CutPrefix(l.Addr, "unix:")
+
+	server := http.Server{
+		Addr:    l.Addr,
+		Handler: http.TimeoutHandler(withProxies(trusted, unix, mux), time.Second*30, ""),
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+		ReadTimeout: time.Second * 30,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		},
+	}
 
-		timer := time.NewTimer(math.MaxInt64)
-		timer.Stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	serverCtx, stopServer := context.WithCancel(ctx)
+	defer stopServer()
 
-			for {
-				select {
-				case <-serverCtx.Done():
-					return
+	// certificates are reloaded without restarting the server
+	if !l.Plain && l.ACMECache != "" {
+		m := l.newCertManager()
 
-				case event, ok := <-w.Events:
-					if !ok {
-						continue
-					}
+		server.TLSConfig.GetCertificate = m.GetCertificate
+		server.TLSConfig.NextProtos = m.TLSConfig().NextProtos
 
-					if (event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) && (event.Name == certAbsPath || event.Name == keyAbsPath) {
-						slog.Info("Stopping server: file has changed", "name", event.Name)
-						timer.Reset(certReloadDelay)
-					}
+		if l.ACMEAddr != "" {
+			wg.Add(1)
+			go func() {
+				l.serveChallenges(serverCtx, m)
+				wg.Done()
+			}()
+		}
+	} else if !l.Plain {
+		pair, err := certs.Load(l.Cert, l.Key)
+		if err != nil {
+			return err
+		}
 
-				case <-timer.C:
-					server.Shutdown(context.Background())
-					return
+		server.TLSConfig.GetCertificate = pair.GetCertificate
 
-				case <-w.Errors:
-				}
+		wg.Add(1)
+		go func() {
+			if err := pair.Watch(serverCtx); err != nil {
+				slog.Warn("Failed to watch certificate", "error", err)
 			}
+			wg.Done()
 		}()
+	}
 
-		slog.Info("Starting server")
-		var err error
-		if l.Plain {
-			err = server.ListenAndServe()
-		} else {
-			err = server.ListenAndServeTLS(l.Cert, l.Key)
-		}
+	wg.Add(1)
+	go func() {
+		<-serverCtx.Done()
+		server.Close()
+		wg.Done()
+	}()
+
+	slog.Info("Starting server")
+	if unix {
+		err = l.serveUnix(&server, socket)
+	} else if l.Plain {
+		err = server.ListenAndServe()
+	} else {
+		err = server.ListenAndServeTLS("", "")
+	}
 
-		stopServer()
-		wg.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Listener) serveUnix(server *http.Server, socket string) error {
+	// remove the socket left behind if tootik was killed, but never anything else
+	if info, err := os.Lstat(socket); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return fmt.Errorf("cannot listen on %s: not a socket", socket)
+		}
 
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := os.Remove(socket); err != nil {
 			return err
 		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
 	}
 
-	return nil
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+
+	if l.Plain {
+		return server.Serve(ln)
+	}
+
+	return server.ServeTLS(ln, "", "")
 }
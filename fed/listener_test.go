@@ -0,0 +1,258 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListener_ActorPath(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+	assert.Equal("https://localhost.localdomain/user/alice", alice.ID)
+
+	cfg.ActorPath = "/users/{username}"
+	cfg.InboxPath = "/users/{username}/inbox"
+	cfg.OutboxPath = "/users/{username}/outbox"
+	cfg.FollowersPath = "/users/{username}/{username}"
+	assert.Error(CheckPaths("localhost.localdomain", &cfg))
+	cfg.FollowersPath = "/users/{username}/followers"
+	assert.NoError(CheckPaths("localhost.localdomain", &cfg))
+
+	bob, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "bob", ap.Person, nil)
+	assert.NoError(err)
+	assert.Equal("https://localhost.localdomain/users/bob", bob.ID)
+	assert.Equal("https://localhost.localdomain/users/bob#main-key", bob.PublicKey.ID)
+	assert.Equal("https://localhost.localdomain/users/bob/inbox", bob.Inbox)
+	assert.Equal("https://localhost.localdomain/users/bob/outbox", bob.Outbox)
+	assert.Equal("https://localhost.localdomain/users/bob/followers", bob.Followers)
+	assert.Equal("https://localhost.localdomain/users/nobody/inbox", bob.Endpoints["sharedInbox"])
+
+	l := Listener{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(nil, "localhost.localdomain", &cfg, &http.Client{}, db),
+	}
+
+	mux, err := l.newMux()
+	assert.NoError(err)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://localhost.localdomain"+path, nil))
+		return w
+	}
+
+	var actor ap.Actor
+	resp := get("/users/bob")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &actor))
+	assert.Equal(bob.ID, actor.ID)
+
+	resp = get("/user/alice")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &actor))
+	assert.Equal(alice.ID, actor.ID)
+
+	var collection map[string]any
+	resp = get("/users/bob/outbox")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &collection))
+	assert.Equal(bob.Outbox, collection["id"])
+
+	resp = get("/outbox/alice")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &collection))
+	assert.Equal(alice.Outbox, collection["id"])
+
+	var webFinger webFingerResponse
+	resp = get("/.well-known/webfinger?resource=https://localhost.localdomain/users/bob")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &webFinger))
+	assert.Equal(bob.ID, webFinger.Links[0].Href)
+
+	resp = get("/.well-known/webfinger?resource=https://localhost.localdomain/user/alice")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &webFinger))
+	assert.Equal(alice.ID, webFinger.Links[0].Href)
+
+	assert.Equal(http.StatusNotFound, get("/.well-known/webfinger?resource=https://localhost.localdomain/post/bob").Code)
+
+	resolved, err := l.Resolver.ResolveID(context.Background(), key, bob.ID, 0)
+	assert.NoError(err)
+	assert.Equal(bob.ID, resolved.ID)
+
+	resolved, err = l.Resolver.ResolveID(context.Background(), key, alice.ID, 0)
+	assert.NoError(err)
+	assert.Equal(alice.ID, resolved.ID)
+
+	_, err = l.Resolver.ResolveID(context.Background(), key, "https://localhost.localdomain/post/bob", 0)
+	assert.ErrorIs(err, ErrNoLocalActor)
+
+	follow := ap.Activity{
+		ID:     "https://ip6-allnodes/follow/1",
+		Type:   ap.Follow,
+		Actor:  "https://ip6-allnodes/user/dan",
+		Object: bob.ID,
+	}
+	assert.NoError(l.validateActivity(&follow, "ip6-allnodes", 0))
+
+	follow.Object = alice.ID
+	assert.NoError(l.validateActivity(&follow, "ip6-allnodes", 0))
+
+	follow.Object = "https://localhost.localdomain/post/bob"
+	assert.Error(l.validateActivity(&follow, "ip6-allnodes", 0))
+}
+
+func TestListener_OverlappingActorPath(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, paths := range [][4]string{
+		{"/post/{username}", "", "", ""},
+		{"", "/web/{username}", "", ""},
+		{"", "", "/users/{username}", "/users/{username}"},
+		{"/{username}", "/{username}/inbox", "", ""},
+		{"/{username}/x", "", "", ""},
+		{"/users/{username}/", "", "", ""},
+		{"/users/{id}", "", "", ""},
+		{"users/{username}", "", "", ""},
+	} {
+		cfg := cfg.Config{
+			ActorPath:     paths[0],
+			InboxPath:     paths[1],
+			OutboxPath:    paths[2],
+			FollowersPath: paths[3],
+		}
+		cfg.FillDefaults()
+
+		l := Listener{
+			Domain:   "localhost.localdomain",
+			Config:   &cfg,
+			Frontend: http.NotFoundHandler(),
+		}
+
+		_, err := l.newMux()
+		assert.Error(err, paths)
+	}
+
+	cfg := cfg.Config{
+		ActorPath:     "/users/{username}",
+		InboxPath:     "/users/{username}/inbox",
+		OutboxPath:    "/users/{username}/outbox",
+		FollowersPath: "/users/{username}/followers",
+	}
+	cfg.FillDefaults()
+	assert.NoError(CheckPaths("localhost.localdomain", &cfg))
+
+	cfg.ActorPath = ""
+	cfg.FillDefaults()
+	assert.Equal("/user/{username}", cfg.ActorPath)
+	assert.NoError(CheckPaths("localhost.localdomain", &cfg))
+}
+
+func TestListener_CaseInsensitiveWebFinger(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	l := Listener{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(nil, "localhost.localdomain", &cfg, &http.Client{}, db),
+	}
+
+	mux, err := l.newMux()
+	assert.NoError(err)
+
+	for _, resource := range []string{"acct:alice@localhost.localdomain", "acct:Alice@localhost.localdomain", "ALICE"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://localhost.localdomain/.well-known/webfinger?resource="+resource, nil))
+		assert.Equal(http.StatusOK, w.Code)
+
+		var webFinger struct {
+			Subject string `json:"subject"`
+			webFingerResponse
+		}
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &webFinger))
+		assert.Equal("acct:alice@localhost.localdomain", webFinger.Subject)
+		assert.Equal(alice.ID, webFinger.Links[0].Href)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://localhost.localdomain/.well-known/webfinger?resource=acct:bob@localhost.localdomain", nil))
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	resolved, err := l.Resolver.Resolve(context.Background(), key, "localhost.localdomain", "Alice", 0)
+	assert.NoError(err)
+	assert.Equal(alice.ID, resolved.ID)
+}
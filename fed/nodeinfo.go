@@ -31,7 +31,19 @@ import (
 
 const nodeInfoUpdateInterval = time.Hour * 6
 
-func addNodeInfo20Stub(mux *http.ServeMux, closed bool) error {
+// nodeInfoMetadata returns server metadata, including the rules users must accept
+func nodeInfoMetadata(cfg *cfg.Config) map[string]any {
+	if len(cfg.Rules) == 0 {
+		return map[string]any{}
+	}
+
+	return map[string]any{
+		"rules":        cfg.Rules,
+		"rulesVersion": cfg.RulesVersion,
+	}
+}
+
+func addNodeInfo20Stub(mux *serveMux, closed bool, cfg *cfg.Config) error {
 	body, err := json.Marshal(map[string]any{
 		"version": "2.0",
 		"software": map[string]any{
@@ -54,7 +66,7 @@ func addNodeInfo20Stub(mux *http.ServeMux, closed bool) error {
 			"localPosts": 0,
 		},
 		"openRegistrations": !closed,
-		"metadata":          map[string]any{},
+		"metadata":          nodeInfoMetadata(cfg),
 	})
 	if err != nil {
 		return err
@@ -68,7 +80,7 @@ func addNodeInfo20Stub(mux *http.ServeMux, closed bool) error {
 	return nil
 }
 
-func addNodeInfo(mux *http.ServeMux, domain string, closed bool, cfg *cfg.Config, db *sql.DB) error {
+func addNodeInfo(mux *serveMux, domain string, closed bool, cfg *cfg.Config, db *sql.DB) error {
 	if body, err := json.Marshal(map[string]any{
 		"links": map[string]any{
 			"rel":  "http://nodeinfo.diaspora.software/ns/schema/2.0",
@@ -84,7 +96,7 @@ func addNodeInfo(mux *http.ServeMux, domain string, closed bool, cfg *cfg.Config
 	}
 
 	if !cfg.FillNodeInfoUsage {
-		return addNodeInfo20Stub(mux, closed)
+		return addNodeInfo20Stub(mux, closed, cfg)
 	}
 
 	l := lock.New()
@@ -156,7 +168,7 @@ func addNodeInfo(mux *http.ServeMux, domain string, closed bool, cfg *cfg.Config
 				"localPosts": localPosts,
 			},
 			"openRegistrations": !closed,
-			"metadata":          map[string]any{},
+			"metadata":          nodeInfoMetadata(cfg),
 		}); err != nil {
 			slog.Warn("Failed to build nodeinfo response", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -24,20 +24,21 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
 )
 
-func (l *Listener) getCollection(w http.ResponseWriter, username string) {
-	first := fmt.Sprintf("https://%s/outbox/%s?0", l.Domain, username)
+func (l *Listener) getCollection(w http.ResponseWriter, r *http.Request, username, outbox string, totalItems int) {
+	first := outbox + "?0"
 
 	collection := map[string]any{
 		"@context":   "https://www.w3.org/ns/activitystreams",
-		"id":         fmt.Sprintf("https://%s/outbox/%s", l.Domain, username),
+		"id":         outbox,
 		"type":       "OrderedCollection",
 		"first":      first,
 		"last":       first,
-		"totalItems": 0,
+		"totalItems": totalItems,
 	}
 
 	slog.Info("Listing activities by user", "username", username)
@@ -49,15 +50,14 @@ func (l *Listener) getCollection(w http.ResponseWriter, username string) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/activity+json; charset=utf-8")
-	w.Write(j)
+	writeConditional(w, r, "application/activity+json; charset=utf-8", j, time.Time{})
 }
 
 func (l *Listener) handleOutbox(w http.ResponseWriter, r *http.Request) {
 	username := r.PathValue("username")
 
-	var actorID sql.NullString
-	if err := l.DB.QueryRowContext(r.Context(), `select id from persons where actor->>'$.preferredUsername' = ? and host = ?`, username, l.Domain).Scan(&actorID); err != nil {
+	var actorID, actorType, outbox sql.NullString
+	if err := l.DB.QueryRowContext(r.Context(), `select id, actor->>'$.type', actor->>'$.outbox' from persons where actor->>'$.preferredUsername' = ? and host = ?`, username, l.Domain).Scan(&actorID, &actorType, &outbox); err != nil {
 		slog.Warn("Failed to check if user exists", "username", username, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -68,17 +68,26 @@ func (l *Listener) handleOutbox(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if shouldRedirect(r) {
-		outbox := fmt.Sprintf("gemini://%s/outbox/%s", l.Domain, strings.TrimPrefix(actorID.String, "https://"))
-		slog.Info("Redirecting to outbox over Gemini", "outbox", outbox)
-		w.Header().Set("Location", outbox)
+		location := fmt.Sprintf("gemini://%s/outbox/%s", l.Domain, strings.TrimPrefix(actorID.String, "https://"))
+		slog.Info("Redirecting to outbox over Gemini", "outbox", location)
+		w.Header().Set("Location", location)
 		w.WriteHeader(http.StatusMovedPermanently)
 		return
 	}
 
+	if !l.authorizeFetch(w, r) {
+		return
+	}
+
 	slog.Info("Fetching activities by user", "username", username)
 
+	if ap.ActorType(actorType.String) == ap.Group {
+		l.getGroupActivities(w, r, username, actorID.String, outbox.String)
+		return
+	}
+
 	if r.URL.RawQuery == "" {
-		l.getCollection(w, username)
+		l.getCollection(w, r, username, outbox.String, 0)
 		return
 	}
 
@@ -94,13 +103,13 @@ func (l *Listener) handleOutbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	first := fmt.Sprintf("https://%s/outbox/%s?0", l.Domain, username)
+	first := outbox.String + "?0"
 
 	page := map[string]any{
 		"@context":     []string{"https://www.w3.org/ns/activitystreams"},
-		"id":           fmt.Sprintf("https://%s/outbox/%s?%d", l.Domain, username, since),
+		"id":           fmt.Sprintf("%s?%d", outbox.String, since),
 		"type":         "OrderedCollectionPage",
-		"partOf":       fmt.Sprintf("https://%s/outbox/%s", l.Domain, username),
+		"partOf":       outbox.String,
 		"orderedItems": []ap.Activity{},
 		"next":         first,
 		"prev":         first,
@@ -113,6 +122,71 @@ func (l *Listener) handleOutbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/activity+json; charset=utf-8")
-	w.Write(j)
+	writeConditional(w, r, "application/activity+json; charset=utf-8", j, time.Time{})
+}
+
+// getGroupActivities lists activities announced by a group, like FEP-1b12 says.
+func (l *Listener) getGroupActivities(w http.ResponseWriter, r *http.Request, username, groupID, outbox string) {
+	if r.URL.RawQuery == "" {
+		var count int
+		if err := l.DB.QueryRowContext(r.Context(), `select count(*) from outbox where sender = ? and activity->>'$.type' = 'Announce'`, groupID).Scan(&count); err != nil {
+			slog.Warn("Failed to count activities", "username", username, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		l.getCollection(w, r, username, outbox, count)
+		return
+	}
+
+	offset, err := strconv.Atoi(r.URL.RawQuery)
+	if err != nil || offset < 0 || offset > l.Config.MaxOffset {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rows, err := l.DB.QueryContext(r.Context(), `select activity from outbox where sender = ? and activity->>'$.type' = 'Announce' order by inserted desc, rowid desc limit ? offset ?`, groupID, l.Config.PostsPerPage, offset)
+	if err != nil {
+		slog.Warn("Failed to list activities", "username", username, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]json.RawMessage, 0, l.Config.PostsPerPage)
+	for rows.Next() {
+		var activity string
+		if err := rows.Scan(&activity); err != nil {
+			rows.Close()
+			slog.Warn("Failed to scan activity", "username", username, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		items = append(items, json.RawMessage(activity))
+	}
+	rows.Close()
+
+	page := map[string]any{
+		"@context":     []string{"https://www.w3.org/ns/activitystreams"},
+		"id":           fmt.Sprintf("%s?%d", outbox, offset),
+		"type":         "OrderedCollectionPage",
+		"partOf":       outbox,
+		"orderedItems": items,
+	}
+
+	if offset > 0 {
+		page["prev"] = fmt.Sprintf("%s?%d", outbox, max(0, offset-l.Config.PostsPerPage))
+	}
+
+	if len(items) == l.Config.PostsPerPage && offset+l.Config.PostsPerPage <= l.Config.MaxOffset {
+		page["next"] = fmt.Sprintf("%s?%d", outbox, offset+l.Config.PostsPerPage)
+	}
+
+	j, err := json.Marshal(page)
+	if err != nil {
+		slog.Warn("Failed to marshal page", "username", username, "offset", offset, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeConditional(w, r, "application/activity+json; charset=utf-8", j, time.Time{})
 }
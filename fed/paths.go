@@ -0,0 +1,135 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+)
+
+// serveMux is a [http.ServeMux] that remembers the path patterns it handles.
+type serveMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *serveMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.ServeMux.HandleFunc(pattern, handler)
+
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		m.patterns = append(m.patterns, path)
+	} else {
+		m.patterns = append(m.patterns, pattern)
+	}
+}
+
+// CheckPaths returns an error if ActorPath, InboxPath, OutboxPath or FollowersPath is invalid or overlaps another
+// path served by tootik.
+func CheckPaths(domain string, cfg *cfg.Config) error {
+	l := Listener{
+		Domain:   domain,
+		Config:   cfg,
+		Frontend: http.NotFoundHandler(),
+	}
+	_, err := l.newMux()
+	return err
+}
+
+// overlap determines whether or not some path matches two path patterns.
+func overlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+
+	if strings.HasSuffix(a[0], "...}") || strings.HasSuffix(b[0], "...}") {
+		return true
+	}
+
+	aWildcard := a[0] != "{$}" && strings.HasPrefix(a[0], "{")
+	bWildcard := b[0] != "{$}" && strings.HasPrefix(b[0], "{")
+
+	switch {
+	case aWildcard && bWildcard:
+	case aWildcard:
+		if b[0] == "{$}" {
+			return false
+		}
+	case bWildcard:
+		if a[0] == "{$}" {
+			return false
+		}
+	case a[0] != b[0]:
+		return false
+	}
+
+	return overlap(a[1:], b[1:])
+}
+
+// validPath determines whether or not a path pattern contains a single {username} segment and no other wildcards.
+func validPath(pattern string) bool {
+	prefix, suffix, ok := strings.Cut(pattern, "{username}")
+	return ok &&
+		strings.HasPrefix(prefix, "/") &&
+		strings.HasSuffix(prefix, "/") &&
+		(suffix == "" || (suffix[0] == '/' && !strings.HasSuffix(suffix, "/"))) &&
+		!strings.ContainsAny(prefix+suffix, "{}")
+}
+
+// checkPaths returns an error if a configurable path is invalid, or if it or the path it replaced (which is still
+// served for existing users) overlaps one of the routes or another configurable path.
+func checkPaths(cfg *cfg.Config, routes []string) error {
+	paths := []struct {
+		Name     string
+		Patterns []string
+	}{
+		{"ActorPath", user.ActorPaths(cfg)},
+		{"InboxPath", user.InboxPaths(cfg)},
+		{"OutboxPath", user.OutboxPaths(cfg)},
+		{"FollowersPath", []string{cfg.FollowersPath}},
+	}
+
+	var used []string
+	for _, route := range routes {
+		// the catch-all route overlaps everything
+		if route != "/" {
+			used = append(used, route)
+		}
+	}
+
+	for _, path := range paths {
+		if !validPath(path.Patterns[0]) {
+			return fmt.Errorf("invalid %s: %s", path.Name, path.Patterns[0])
+		}
+
+		for _, pattern := range path.Patterns {
+			segments := strings.Split(pattern[1:], "/")
+			for _, other := range used {
+				if overlap(segments, strings.Split(other[1:], "/")) {
+					return fmt.Errorf("%s %s overlaps %s", path.Name, pattern, other)
+				}
+			}
+
+			used = append(used, pattern)
+		}
+	}
+
+	return nil
+}
@@ -23,8 +23,10 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/outbox"
 )
 
 func (l *Listener) handlePost(w http.ResponseWriter, r *http.Request) {
@@ -32,12 +34,31 @@ func (l *Listener) handlePost(w http.ResponseWriter, r *http.Request) {
 
 	if shouldRedirect(r) {
 		url := fmt.Sprintf("gemini://%s/view/%s%s", l.Domain, l.Domain, r.URL.Path)
-		slog.Info("Redirecting to post over Gemini", "url", url)
+		if l.Frontend != nil && !l.Config.AuthorizedFetch {
+			url = fmt.Sprintf("/web/view/%s%s", l.Domain, r.URL.Path)
+		}
+
+		// show a preview with OpenGraph metadata if possible, so links unfurl in chat apps, unless fetching requires a signature
+		if !l.Config.AuthorizedFetch {
+			if note, author, err := l.fetchPreview(r, postID); err == nil {
+				slog.Info("Sending post preview", "post", postID, "url", url)
+				l.writePreview(w, &note, &author, url)
+				return
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				slog.Warn("Failed to fetch post", "post", postID, "error", err)
+			}
+		}
+
+		slog.Info("Redirecting to post", "url", url)
 		w.Header().Set("Location", url)
 		w.WriteHeader(http.StatusMovedPermanently)
 		return
 	}
 
+	if !l.authorizeFetch(w, r) {
+		return
+	}
+
 	slog.Info("Fetching post", "post", postID)
 
 	var note ap.Object
@@ -52,6 +73,16 @@ func (l *Listener) handlePost(w http.ResponseWriter, r *http.Request) {
 
 	note.Context = "https://www.w3.org/ns/activitystreams"
 
+	// posts published before the replies collection was added don't have this property
+	if note.Replies == "" {
+		note.Replies = ap.Collection(fmt.Sprintf("https://%s/replies/%s", l.Domain, r.PathValue("hash")))
+	}
+
+	// posts published before the url property was added don't have it
+	if note.URL == "" {
+		note.URL = outbox.PostURL(l.Domain, l.Config, note.ID)
+	}
+
 	j, err := json.Marshal(note)
 	if err != nil {
 		slog.Warn("Failed to marshal post", "post", postID, "error", err)
@@ -59,6 +90,5 @@ func (l *Listener) handlePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/activity+json; charset=utf-8")
-	w.Write(j)
+	writeConditional(w, r, "application/activity+json; charset=utf-8", j, time.Time{})
 }
@@ -0,0 +1,152 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text/plain"
+	"github.com/dimkr/tootik/outbox"
+)
+
+const maxExcerptRunes = 200
+
+type oEmbed struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	AuthorURL    string `json:"author_url"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+}
+
+// excerpt returns a short, single-line summary of a post, without text hidden behind a content warning
+func excerpt(note *ap.Object) string {
+	var s string
+	if note.Summary != "" {
+		s, _ = plain.FromHTML(note.Summary)
+	} else if !note.Sensitive {
+		s, _ = plain.FromHTML(note.Content)
+	}
+
+	s = strings.Join(strings.Fields(s), " ")
+
+	return truncate(s, maxExcerptRunes)
+}
+
+// truncate shortens a string to limit runes, replacing the last one with an ellipsis if needed.
+func truncate(s string, limit int) string {
+	if runes := []rune(s); len(runes) > limit {
+		return string(runes[:limit-1]) + "…"
+	}
+
+	return s
+}
+
+func authorName(author *ap.Actor) string {
+	if author.Name != "" {
+		return author.Name
+	}
+
+	return author.PreferredUsername
+}
+
+func (l *Listener) fetchPreview(r *http.Request, postID string) (ap.Object, ap.Actor, error) {
+	var note ap.Object
+	var author ap.Actor
+	err := l.DB.QueryRowContext(r.Context(), `select notes.object, persons.actor from notes join persons on persons.id = notes.author where notes.id = ? and notes.public = 1`, postID).Scan(&note, &author)
+	return note, author, err
+}
+
+// writePreview responds with an HTML page that contains OpenGraph metadata and redirects to the post.
+func (l *Listener) writePreview(w http.ResponseWriter, note *ap.Object, author *ap.Actor, target string) {
+	title := html.EscapeString(fmt.Sprintf("Post by %s", authorName(author)))
+	description := html.EscapeString(excerpt(note))
+	escapedTarget := html.EscapeString(target)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html prefix=\"og: https://ogp.me/ns#\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", title)
+	fmt.Fprintf(w, "<meta property=\"og:type\" content=\"article\">\n<meta property=\"og:site_name\" content=\"%s\">\n", html.EscapeString(l.Domain))
+	fmt.Fprintf(w, "<meta property=\"og:url\" content=\"%s\">\n", html.EscapeString(note.ID))
+	fmt.Fprintf(w, "<meta property=\"og:title\" content=\"%s\">\n<meta property=\"og:description\" content=\"%s\">\n", title, description)
+	fmt.Fprintf(w, "<meta property=\"article:published_time\" content=\"%s\">\n", note.Published.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "<meta property=\"article:author\" content=\"%s\">\n", html.EscapeString(author.ID))
+	fmt.Fprintf(w, "<link rel=\"canonical\" href=\"%s\">\n", html.EscapeString(outbox.PostURL(l.Domain, l.Config, note.ID)))
+	fmt.Fprintf(w, "<link rel=\"alternate\" type=\"application/json+oembed\" href=\"%s\">\n", html.EscapeString(fmt.Sprintf("https://%s/oembed?url=%s", l.Domain, url.QueryEscape(note.ID))))
+	fmt.Fprintf(w, "<meta http-equiv=\"refresh\" content=\"0; url=%s\">\n</head>\n", escapedTarget)
+	fmt.Fprintf(w, "<body>\n<p><a href=\"%s\">%s</a></p>\n<p>%s</p>\n</body>\n</html>\n", escapedTarget, title, description)
+}
+
+func (l *Listener) handleOEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if format := query.Get("format"); format != "" && format != "json" {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	postID := query.Get("url")
+	if !strings.HasPrefix(postID, fmt.Sprintf("https://%s/post/", l.Domain)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	note, author, err := l.fetchPreview(r, postID)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		slog.Warn("Failed to fetch post", "post", postID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	title := excerpt(&note)
+	if title == "" {
+		title = fmt.Sprintf("Post by %s", authorName(&author))
+	}
+
+	j, err := json.Marshal(oEmbed{
+		Version:      "1.0",
+		Type:         "link",
+		Title:        title,
+		AuthorName:   authorName(&author),
+		AuthorURL:    author.ID,
+		ProviderName: l.Domain,
+		ProviderURL:  fmt.Sprintf("https://%s", l.Domain),
+	})
+	if err != nil {
+		slog.Warn("Failed to marshal oEmbed response", "post", postID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(j)
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/migrations"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreview(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	_, err = db.Exec(
+		`insert into notes(id, author, object, public) values(?, ?, ?, 1)`,
+		"https://localhost.localdomain/post/public",
+		alice.ID,
+		`{"type":"Note","id":"https://localhost.localdomain/post/public","attributedTo":"`+alice.ID+`","content":"<p>Hello &amp; goodbye</p>","published":"2025-01-02T03:04:05Z","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+	assert.NoError(err)
+
+	_, err = db.Exec(
+		`insert into notes(id, author, object, public) values(?, ?, ?, 0)`,
+		"https://localhost.localdomain/post/private",
+		alice.ID,
+		`{"type":"Note","id":"https://localhost.localdomain/post/private","attributedTo":"`+alice.ID+`","content":"<p>Secret</p>","published":"2025-01-02T03:04:05Z"}`,
+	)
+	assert.NoError(err)
+
+	l := Listener{Domain: "localhost.localdomain", Config: &cfg, DB: db}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /post/{hash}", l.handlePost)
+	mux.HandleFunc("GET /oembed", l.handleOEmbed)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "https://localhost.localdomain"+path, nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	preview := get("/post/public", "text/html,application/xhtml+xml")
+	assert.Equal(http.StatusOK, preview.Code)
+	assert.Equal("text/html; charset=utf-8", preview.Header().Get("Content-Type"))
+	body := preview.Body.String()
+	assert.Contains(body, `<meta property="og:title" content="Post by alice">`)
+	assert.Contains(body, `<meta property="og:description" content="Hello &amp; goodbye">`)
+	assert.Contains(body, `<meta property="article:published_time" content="2025-01-02T03:04:05Z">`)
+	assert.Contains(body, `<meta property="article:author" content="https://localhost.localdomain/user/alice">`)
+	assert.Contains(body, `href="https://localhost.localdomain/oembed?url=https%3A%2F%2Flocalhost.localdomain%2Fpost%2Fpublic"`)
+	assert.Contains(body, `<meta http-equiv="refresh" content="0; url=gemini://localhost.localdomain/view/localhost.localdomain/post/public">`)
+	assert.Contains(body, `<link rel="canonical" href="gemini://localhost.localdomain/view/localhost.localdomain/post/public">`)
+
+	private := get("/post/private", "text/html")
+	assert.Equal(http.StatusMovedPermanently, private.Code)
+	assert.NotContains(private.Body.String(), "Secret")
+
+	post := get("/post/public", "application/activity+json")
+	assert.Equal(http.StatusOK, post.Code)
+	assert.Equal("application/activity+json; charset=utf-8", post.Header().Get("Content-Type"))
+
+	var note ap.Object
+	assert.NoError(json.Unmarshal(post.Body.Bytes(), &note))
+	assert.Equal("gemini://localhost.localdomain/view/localhost.localdomain/post/public", note.URL)
+
+	oembed := get("/oembed?url="+url.QueryEscape("https://localhost.localdomain/post/public"), "application/json")
+	assert.Equal(http.StatusOK, oembed.Code)
+
+	var resp map[string]string
+	assert.NoError(json.Unmarshal(oembed.Body.Bytes(), &resp))
+	assert.Equal("1.0", resp["version"])
+	assert.Equal("link", resp["type"])
+	assert.Equal("Hello & goodbye", resp["title"])
+	assert.Equal("alice", resp["author_name"])
+	assert.Equal(alice.ID, resp["author_url"])
+	assert.Equal("localhost.localdomain", resp["provider_name"])
+
+	assert.Equal(http.StatusNotFound, get("/oembed?url="+url.QueryEscape("https://localhost.localdomain/post/private"), "").Code)
+	assert.Equal(http.StatusNotFound, get("/oembed?url="+url.QueryEscape("https://example.com/post/public"), "").Code)
+	assert.Equal(http.StatusNotImplemented, get("/oembed?format=xml&url="+url.QueryEscape("https://localhost.localdomain/post/public"), "").Code)
+}
+
+func TestExcerpt(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("a b", excerpt(&ap.Object{Content: "<p>a\n\nb</p>"}))
+	assert.Equal("CW", excerpt(&ap.Object{Content: "<p>hidden</p>", Summary: "CW", Sensitive: true}))
+	assert.Equal("", excerpt(&ap.Object{Content: "<p>hidden</p>", Sensitive: true}))
+	assert.Equal(strings.Repeat("a", maxExcerptRunes-1)+"…", excerpt(&ap.Object{Content: strings.Repeat("a", maxExcerptRunes+1)}))
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// proxies is a list of reverse proxies trusted to pass the client address in X-Forwarded-For.
+type proxies []netip.Prefix
+
+func parseProxies(list []string) (proxies, error) {
+	p := make(proxies, 0, len(list))
+
+	for _, s := range list {
+		if prefix, err := netip.ParsePrefix(s); err == nil {
+			p = append(p, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(s); err == nil {
+			p = append(p, netip.PrefixFrom(addr, addr.BitLen()))
+		} else {
+			return nil, fmt.Errorf("invalid proxy: %s", s)
+		}
+	}
+
+	return p, nil
+}
+
+func (p proxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientAddr returns the address of the client that sent a request through trusted proxies.
+func (p proxies) clientAddr(r *http.Request, unix bool) (string, bool) {
+	if !unix {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return "", false
+		}
+
+		addr, err := netip.ParseAddr(host)
+		if err != nil || !p.contains(addr) {
+			return "", false
+		}
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		return "", false
+	}
+
+	// each proxy appends the address it received the request from, so the client is the last untrusted address
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		client = addr.Unmap().String()
+
+		if !p.contains(addr) {
+			break
+		}
+	}
+
+	return client, client != ""
+}
+
+// withProxies replaces the address and the scheme of requests sent through trusted proxies with those of the client.
+func withProxies(p proxies, unix bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if client, ok := p.clientAddr(r, unix); ok {
+			r.RemoteAddr = net.JoinHostPort(client, "0")
+
+			if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+				r.URL.Scheme = proto
+			}
+		}
+
+		slog.Debug("Handling request", "method", r.Method, "path", r.URL.Path, "client", r.RemoteAddr)
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxies_ClientAddr(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := parseProxies([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		RemoteAddr, Forwarded, Client string
+		Unix, OK                      bool
+	}{
+		{"1.2.3.4:1234", "5.6.7.8", "", false, false},
+		{"10.1.2.3:1234", "", "", false, false},
+		{"10.1.2.3:1234", "5.6.7.8", "5.6.7.8", false, true},
+		{"192.168.1.1:1234", "5.6.7.8", "5.6.7.8", false, true},
+		{"192.168.1.2:1234", "5.6.7.8", "", false, false},
+		{"[::1]:1234", "5.6.7.8", "5.6.7.8", false, true},
+		{"10.1.2.3:1234", "9.9.9.9, 5.6.7.8, 10.4.5.6", "5.6.7.8", false, true},
+		{"10.1.2.3:1234", "10.7.8.9, 10.4.5.6", "10.7.8.9", false, true},
+		{"10.1.2.3:1234", "x, 5.6.7.8", "5.6.7.8", false, true},
+		{"10.1.2.3:1234", "x", "", false, false},
+		{"@", "5.6.7.8", "5.6.7.8", true, true},
+		{"@", "", "", true, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.RemoteAddr
+		if test.Forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.Forwarded)
+		}
+
+		client, ok := p.clientAddr(r, test.Unix)
+		assert.Equal(test.OK, ok, test.RemoteAddr+" "+test.Forwarded)
+		assert.Equal(test.Client, client, test.RemoteAddr+" "+test.Forwarded)
+	}
+}
+
+func TestProxies_Invalid(t *testing.T) {
+	_, err := parseProxies([]string{"10.0.0.0/8", "localhost"})
+	assert.Error(t, err)
+}
+
+func TestProxies_Handler(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := parseProxies([]string{"127.0.0.1"})
+	assert.NoError(err)
+
+	var remoteAddr, scheme string
+	h := withProxies(p, false, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+		scheme = r.URL.Scheme
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "5.6.7.8")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal("5.6.7.8:0", remoteAddr)
+	assert.Equal("https", scheme)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "1.2.3.4:1234"
+	r.Header.Set("X-Forwarded-For", "5.6.7.8")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal("1.2.3.4:1234", remoteAddr)
+	assert.Equal("", scheme)
+}
+
+func TestListener_UnixSocket(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "db.sqlite3")+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	socket := filepath.Join(dir, "tootik.sock")
+
+	// a socket left behind by a previous run is replaced
+	stale, err := net.Listen("unix", socket)
+	assert.NoError(err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l := Listener{
+		Domain: "localhost.localdomain",
+		Config: &cfg,
+		DB:     db,
+		Addr:   "unix:" + socket,
+		Plain:  true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- l.ListenAndServe(ctx)
+	}()
+
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	var resp *http.Response
+	for range 50 {
+		resp, err = client.Get("http://localhost.localdomain/robots.txt")
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	assert.NoError(err)
+
+	if resp != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(err)
+		assert.Equal(http.StatusOK, resp.StatusCode)
+		assert.NotEmpty(body)
+	}
+
+	cancel()
+	assert.NoError(<-done)
+}
+
+func TestListener_UnixSocketNotSocket(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "db.sqlite3")+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	// a regular file is never deleted
+	path := filepath.Join(dir, "db.sqlite3")
+
+	l := Listener{
+		Domain: "localhost.localdomain",
+		Config: &cfg,
+		DB:     db,
+		Addr:   "unix:" + path,
+		Plain:  true,
+	}
+
+	assert.Error(l.ListenAndServe(context.Background()))
+
+	_, err = os.Stat(path)
+	assert.NoError(err)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/dimkr/tootik/cfg"
+)
+
+// ErrPrivateAddress is returned when a request to a private, loopback or link-local address is refused.
+var ErrPrivateAddress = errors.New("refusing to connect to a private address")
+
+type publicTransport struct {
+	Lookup    func(context.Context, string) ([]net.IPAddr, error)
+	Transport http.RoundTripper
+}
+
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// refusePrivate is a [net.Dialer] Control function that refuses to connect to non-public addresses.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("cannot connect to %s: %w", address, ErrPrivateAddress)
+	}
+
+	return nil
+}
+
+// NewPublicTransport returns a [http.RoundTripper] like [NewTransport], for requests to URLs supplied by users.
+//
+// Requests to hosts with private, loopback or link-local addresses are refused, including redirects to such hosts.
+func NewPublicTransport(cfg *cfg.Config) (http.RoundTripper, error) {
+	t, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// if there's a proxy, we connect to the proxy and not to the host, so we can only check the host's addresses
+	if cfg.Proxy == "" {
+		d := newDialer(cfg)
+		public := net.Dialer{Timeout: cfg.DialTimeout, Control: refusePrivate}
+		d.Dial = public.DialContext
+		t.clearnet.DialContext = d.DialContext
+	}
+
+	return &publicTransport{
+		Lookup:    net.DefaultResolver.LookupIPAddr,
+		Transport: t,
+	}, nil
+}
+
+func (t *publicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+
+	if !isOnion(host) {
+		addrs := []net.IPAddr{{IP: net.ParseIP(host)}}
+		if addrs[0].IP == nil {
+			var err error
+			if addrs, err = t.Lookup(req.Context(), host); err != nil {
+				return nil, err
+			} else if len(addrs) == 0 {
+				return nil, fmt.Errorf("no addresses for %s", host)
+			}
+		}
+
+		for _, addr := range addrs {
+			if !isPublicIP(addr.IP) {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return nil, fmt.Errorf("cannot reach %s: %w", host, ErrPrivateAddress)
+			}
+		}
+	}
+
+	return t.Transport.RoundTrip(req)
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/front/user"
+)
+
+const (
+	maxRegistrationFormSize = 16 * 1024
+	generatedCertValidity   = time.Hour * 24 * 365 * 5
+)
+
+func (l *Listener) handleRegisterForm(w http.ResponseWriter, r *http.Request) {
+	domain := html.EscapeString(l.Domain)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Register on %s</title>\n</head>\n<body>\n<h1>Register on %s</h1>\n", domain, domain)
+	w.Write([]byte("<p>Registration requires an invitation code from an existing user.</p>\n"))
+
+	if len(l.Config.Rules) > 0 {
+		w.Write([]byte("<h2>Rules</h2>\n<ol>\n"))
+		for _, rule := range l.Config.Rules {
+			fmt.Fprintf(w, "<li>%s</li>\n", html.EscapeString(rule))
+		}
+		w.Write([]byte("</ol>\n<p>You must accept these rules before you can post.</p>\n"))
+	}
+
+	w.Write([]byte("<form method=\"post\" action=\"/register\">\n"))
+	w.Write([]byte("<p><label>Invitation code<br><input name=\"code\" required></label></p>\n"))
+	w.Write([]byte("<p><label>User name<br><input name=\"name\"></label></p>\n"))
+	w.Write([]byte("<p><label>Client certificate (PEM)<br><textarea name=\"cert\" rows=\"10\" cols=\"64\"></textarea></label></p>\n"))
+	w.Write([]byte("<p><label>Signature (base64)<br><textarea name=\"signature\" rows=\"4\" cols=\"64\"></textarea></label></p>\n"))
+	w.Write([]byte("<p><button type=\"submit\">Register</button></p>\n</form>\n"))
+	w.Write([]byte("<p>If you paste a client certificate, your user name is its common name and the user name field is ignored.</p>\n"))
+	// openssl can't sign from a pipe with an Ed25519 key, so the challenge is written to a file first
+	fmt.Fprintf(w, "<p>To prove that the certificate is yours, sign the invitation code using its private key:</p>\n<pre>printf '%s' > challenge.txt\nopenssl pkeyutl -sign -rawin -inkey key.pem -in challenge.txt | base64 -w 0</pre>\n", html.EscapeString(registrationChallenge(l.Domain, "CODE")))
+	fmt.Fprintf(w, "<p>Otherwise, a certificate and a private key are generated for you and downloaded: import them into your Gemini client, then open gemini://%s/users.</p>\n</body>\n</html>\n", domain)
+}
+
+// generateCertificate generates a self-signed client certificate for a new user.
+func generateCertificate(name string) (*x509.Certificate, []byte, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	der, err := x509.CreateCertificate(
+		rand.Reader,
+		&x509.Certificate{
+			SerialNumber: serial,
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    now.Add(-time.Minute),
+			NotAfter:     now.Add(generatedCertValidity),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		},
+		&x509.Certificate{Subject: pkix.Name{CommonName: name}},
+		&priv.PublicKey,
+		priv,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privDer, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		return nil, nil, err
+	}
+	if err := pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: privDer}); err != nil {
+		return nil, nil, err
+	}
+
+	return cert, buf.Bytes(), nil
+}
+
+// registrationChallenge returns the message a user must sign to prove possession of a certificate's private key.
+//
+// The invitation code is secret and can be used only once, so a signature can't be replayed.
+func registrationChallenge(domain, code string) string {
+	return fmt.Sprintf("tootik registration %s %s", domain, code)
+}
+
+// verifyPossession verifies a signature over the registration challenge, using a certificate's public key.
+func verifyPossession(cert *x509.Certificate, domain, code string, signature []byte) error {
+	var alg x509.SignatureAlgorithm
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		alg = x509.SHA256WithRSA
+	case *ecdsa.PublicKey:
+		alg = x509.ECDSAWithSHA256
+	case ed25519.PublicKey:
+		alg = x509.PureEd25519
+	default:
+		return fmt.Errorf("unsupported key type: %T", cert.PublicKey)
+	}
+
+	return cert.CheckSignature(alg, []byte(registrationChallenge(domain, code)), signature)
+}
+
+func (l *Listener) handleRegister(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRegistrationFormSize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	code := strings.TrimSpace(r.PostForm.Get("code"))
+	if code == "" {
+		http.Error(w, "No invitation code", http.StatusBadRequest)
+		return
+	}
+
+	var name string
+	var cert *x509.Certificate
+	var bundle []byte
+	if certPem := strings.TrimSpace(r.PostForm.Get("cert")); certPem != "" {
+		block, _ := pem.Decode([]byte(certPem))
+		if block == nil || block.Type != "CERTIFICATE" {
+			http.Error(w, "Invalid certificate", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			http.Error(w, "Invalid certificate", http.StatusBadRequest)
+			return
+		}
+
+		if time.Now().After(cert.NotAfter) {
+			http.Error(w, "Certificate has expired", http.StatusBadRequest)
+			return
+		}
+
+		// certificates are public, so anyone could register an account for someone else's certificate
+		signature, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(r.PostForm.Get("signature")), ""))
+		if err != nil || len(signature) == 0 {
+			http.Error(w, "No signature", http.StatusBadRequest)
+			return
+		}
+
+		if err := verifyPossession(cert, l.Domain, code, signature); err != nil {
+			slog.Info("Failed to verify certificate possession", "error", err)
+			http.Error(w, "Invalid signature", http.StatusForbidden)
+			return
+		}
+
+		name = cert.Subject.CommonName
+	} else {
+		name = strings.TrimSpace(r.PostForm.Get("name"))
+	}
+
+	if exists, err := user.CheckName(r.Context(), l.Domain, l.Config, l.DB, name); exists || errors.Is(err, user.ErrNameTaken) {
+		http.Error(w, "User name is taken", http.StatusConflict)
+		return
+	} else if errors.Is(err, user.ErrReservedName) {
+		http.Error(w, "User name is reserved", http.StatusBadRequest)
+		return
+	} else if errors.Is(err, user.ErrConfusableName) {
+		http.Error(w, "User name contains confusable characters", http.StatusBadRequest)
+		return
+	} else if errors.Is(err, user.ErrInvalidName) {
+		http.Error(w, "Invalid user name", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		slog.Warn("Failed to check if user exists", "name", name, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var lastRegister sql.NullInt64
+	if err := l.DB.QueryRowContext(r.Context(), `select max(inserted) from certificates`).Scan(&lastRegister); err != nil {
+		slog.Warn("Failed to check last registration time", "name", name, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if lastRegister.Valid && time.Since(time.Unix(lastRegister.Int64, 0)) < l.Config.RegistrationInterval {
+		http.Error(w, "Registration is closed, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	if cert == nil {
+		var err error
+		cert, bundle, err = generateCertificate(name)
+		if err != nil {
+			slog.Warn("Failed to generate certificate", "name", name, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	slog.Info("Creating new invited user over HTTPS", "name", name)
+
+	if _, _, err := user.CreateInvited(r.Context(), l.Domain, l.Config, l.DB, name, code, cert); errors.Is(err, user.ErrInvalidInvitation) {
+		http.Error(w, "Invalid invitation code", http.StatusForbidden)
+		return
+	} else if errors.Is(err, user.ErrNameTaken) {
+		http.Error(w, "User name is taken", http.StatusConflict)
+		return
+	} else if err != nil {
+		slog.Warn("Failed to create new user", "name", name, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if bundle != nil {
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pem"`, name))
+		w.Write(bundle)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("gemini://%s/users", l.Domain))
+	w.WriteHeader(http.StatusSeeOther)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+const (
+	maxRejectionErrorLength = 256
+
+	// rejections of requests that fail verification are sampled, because anyone can send such requests
+	unverifiedRejectionInterval = time.Minute
+	unverifiedRejectionBurst    = 10
+)
+
+// Reasons for rejecting an incoming activity.
+const (
+	rejectedSignature = "signature"
+	rejectedBlocked   = "blocked"
+	rejectedYoung     = "young"
+	rejectedInvalid   = "invalid"
+)
+
+// reject records the reason for rejecting an incoming activity, so administrators can diagnose configuration problems
+// without searching the logs; only the last [cfg.Config.MaxRejections] rejections are kept.
+//
+// If the request has failed verification, the sender and its host are claimed by the client and may be forged.
+func (l *Listener) reject(ctx context.Context, activity, sender, host, client, reason string, verified bool, err error) {
+	// activities sent by this server are rejected on purpose by the doctor command
+	if verified && host == l.Domain {
+		return
+	}
+
+	if !verified && l.unverifiedRejections.Take(reason, time.Now(), unverifiedRejectionInterval, unverifiedRejectionBurst) > 0 {
+		return
+	}
+
+	msg := truncate(err.Error(), maxRejectionErrorLength)
+
+	tx, err := l.DB.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Warn("Failed to record rejection", "activity", activity, "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`insert into rejections(activity, sender, host, client, reason, error, verified) values(?, ?, ?, ?, ?, ?, ?)`,
+		sql.NullString{String: activity, Valid: activity != ""},
+		sql.NullString{String: sender, Valid: sender != ""},
+		sql.NullString{String: host, Valid: host != ""},
+		client,
+		reason,
+		msg,
+		verified,
+	); err != nil {
+		slog.Warn("Failed to record rejection", "activity", activity, "error", err)
+		return
+	}
+
+	if _, err := tx.ExecContext(ctx, `delete from rejections where rowid <= (select max(rowid) from rejections) - ?`, l.Config.MaxRejections); err != nil {
+		slog.Warn("Failed to trim rejections", "error", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Warn("Failed to record rejection", "activity", activity, "error", err)
+	}
+}
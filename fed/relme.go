@@ -0,0 +1,220 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/text/plain"
+	"github.com/dimkr/tootik/outbox"
+)
+
+// LinkVerifier verifies links in profile fields of local users.
+//
+// A link is verified if the linked page contains a link back to the user, with rel="me".
+type LinkVerifier struct {
+	Domain string
+	Config *cfg.Config
+	DB     *sql.DB
+	Client Client
+}
+
+var (
+	relMeTagRegex  = regexp.MustCompile(`(?i)<(?:a|link)\s[^>]*>`)
+	relMeAttrRegex = regexp.MustCompile(`(?i)\s(rel|href)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// hasRelMe checks if a HTML document links to a URL with rel="me".
+func hasRelMe(body, url string) bool {
+	for _, tag := range relMeTagRegex.FindAllString(body, -1) {
+		var rel, href string
+		for _, attr := range relMeAttrRegex.FindAllStringSubmatch(tag, -1) {
+			value := html.UnescapeString(attr[2] + attr[3] + attr[4])
+			if strings.EqualFold(attr[1], "rel") {
+				rel = value
+			} else {
+				href = value
+			}
+		}
+
+		if href == url && slices.Contains(strings.Fields(strings.ToLower(rel)), "me") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *LinkVerifier) verify(ctx context.Context, link string, actor *ap.Actor) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.Config.LinkVerificationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to fetch %s: %d", link, resp.StatusCode)
+	}
+
+	if resp.ContentLength > v.Config.MaxResponseBodySize {
+		return false, fmt.Errorf("failed to fetch %s: response is too big", link)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, v.Config.MaxResponseBodySize))
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch %s: %w", link, err)
+	}
+
+	return hasRelMe(string(body), actor.ID), nil
+}
+
+func (v *LinkVerifier) verifyActor(ctx context.Context, actor *ap.Actor) error {
+	now := time.Now()
+
+	verified := map[string]*ap.Time{}
+
+	for _, field := range actor.Attachment {
+		if field.Type != ap.PropertyValue {
+			continue
+		}
+
+		if field.VerifiedAt != nil && now.Sub(field.VerifiedAt.Time) < v.Config.LinkVerificationInterval {
+			verified[field.Value] = field.VerifiedAt
+			continue
+		}
+
+		_, links := plain.FromHTML(field.Value)
+		if len(links) != 1 {
+			continue
+		}
+
+		for link := range links.Keys() {
+			if !strings.HasPrefix(link, "https://") {
+				break
+			}
+
+			if ok, err := v.verify(ctx, link, actor); err != nil {
+				slog.Info("Failed to verify link", "actor", actor.ID, "link", link, "error", err)
+			} else if ok {
+				verified[field.Value] = &ap.Time{Time: now.UTC().Truncate(time.Second)}
+			}
+
+			break
+		}
+	}
+
+	tx, err := v.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// fields might have changed while we were busy fetching links
+	var current ap.Actor
+	if err := tx.QueryRowContext(ctx, `SELECT actor FROM persons WHERE id = ?`, actor.ID).Scan(&current); err != nil {
+		return err
+	}
+
+	changed := false
+	for i, field := range current.Attachment {
+		if field.Type != ap.PropertyValue {
+			continue
+		}
+
+		verifiedAt := verified[field.Value]
+		if (field.VerifiedAt == nil) != (verifiedAt == nil) {
+			changed = true
+		}
+		current.Attachment[i].VerifiedAt = verifiedAt
+	}
+
+	j, err := json.Marshal(current.Attachment)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE persons SET actor = json_set(actor, '$.attachment', json(?)) WHERE id = ?`, string(j), actor.ID); err != nil {
+		return err
+	}
+
+	// followers only need to know when a link becomes verified or stops being verified
+	if changed {
+		slog.Info("Verification state of links has changed", "actor", actor.ID)
+
+		if err := outbox.UpdateActor(ctx, v.Domain, tx, actor.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Run verifies links in profile fields of local users, if not verified or verified long ago.
+func (v *LinkVerifier) Run(ctx context.Context) error {
+	rows, err := v.DB.QueryContext(
+		ctx,
+		`SELECT actor FROM persons WHERE host = ? AND EXISTS (SELECT 1 FROM json_each(actor->'$.attachment') WHERE value->>'$.type' = 'PropertyValue' AND value->>'$.value' LIKE '%href="https://%' AND (value->>'$.verifiedAt' IS NULL OR UNIXEPOCH(value->>'$.verifiedAt') < ?))`,
+		v.Domain,
+		time.Now().Add(-v.Config.LinkVerificationInterval).Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fetch users to verify: %w", err)
+	}
+
+	var actors []ap.Actor
+	for rows.Next() {
+		var actor ap.Actor
+		if err := rows.Scan(&actor); err != nil {
+			slog.Warn("Failed to scan user", "error", err)
+			continue
+		}
+		actors = append(actors, actor)
+	}
+	rows.Close()
+
+	for _, actor := range actors {
+		if err := v.verifyActor(ctx, &actor); err != nil {
+			return fmt.Errorf("failed to verify links of %s: %w", actor.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/migrations"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasRelMe(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(hasRelMe(`<html><a href="https://localhost.localdomain/user/alice" rel="me">Me</a></html>`, "https://localhost.localdomain/user/alice"))
+	assert.True(hasRelMe(`<html><head><LINK rel='me nofollow' href='https://localhost.localdomain/user/alice'></head></html>`, "https://localhost.localdomain/user/alice"))
+	assert.True(hasRelMe(`<a rel=me href=https://localhost.localdomain/user/alice>`, "https://localhost.localdomain/user/alice"))
+	assert.False(hasRelMe(`<a href="https://localhost.localdomain/user/alice">Me</a>`, "https://localhost.localdomain/user/alice"))
+	assert.False(hasRelMe(`<a href="https://localhost.localdomain/user/bob" rel="me">Me</a>`, "https://localhost.localdomain/user/alice"))
+	assert.False(hasRelMe(`<a href="https://localhost.localdomain/user/alice" rel="meh">Me</a>`, "https://localhost.localdomain/user/alice"))
+}
+
+func TestLinkVerifier_Run(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	_, err = db.Exec(
+		`update persons set actor = json_set(actor, '$.attachment', json(?)) where id = ?`,
+		`[{"type":"PropertyValue","name":"Blog","value":"<a href=\"https://blog.example.com\" rel=\"me\">https://blog.example.com</a>"},{"type":"PropertyValue","name":"Code","value":"<a href=\"https://code.example.com\" rel=\"me\">https://code.example.com</a>"},{"type":"PropertyValue","name":"Pronouns","value":"<p>they/them</p>"}]`,
+		alice.ID,
+	)
+	assert.NoError(err)
+
+	client := newTestClient(map[string]testResponse{
+		"https://blog.example.com": {
+			Response: newTestResponse(200, `<html><body><a href="https://localhost.localdomain/user/alice" rel="me">Fediverse</a></body></html>`),
+		},
+		"https://code.example.com": {
+			Response: newTestResponse(200, `<html><body><a href="https://localhost.localdomain/user/alice">Fediverse</a></body></html>`),
+		},
+	})
+
+	verifier := LinkVerifier{
+		Domain: "localhost.localdomain",
+		Config: &cfg,
+		DB:     db,
+		Client: &client,
+	}
+	assert.NoError(verifier.Run(context.Background()))
+	assert.Empty(client.Data)
+
+	var actor ap.Actor
+	assert.NoError(db.QueryRow(`select actor from persons where id = ?`, alice.ID).Scan(&actor))
+	assert.Len(actor.Attachment, 3)
+	assert.NotNil(actor.Attachment[0].VerifiedAt)
+	assert.Nil(actor.Attachment[1].VerifiedAt)
+	assert.Nil(actor.Attachment[2].VerifiedAt)
+
+	var updates int
+	assert.NoError(db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Update' and activity->>'$.object' = ?`, alice.ID).Scan(&updates))
+	assert.Equal(1, updates)
+
+	client.Data = map[string]testResponse{
+		"https://code.example.com": {
+			Response: newTestResponse(404, ""),
+		},
+	}
+
+	assert.NoError(verifier.Run(context.Background()))
+	assert.Empty(client.Data)
+
+	assert.NoError(db.QueryRow(`select actor from persons where id = ?`, alice.ID).Scan(&actor))
+	assert.NotNil(actor.Attachment[0].VerifiedAt)
+	assert.Nil(actor.Attachment[1].VerifiedAt)
+
+	assert.NoError(db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Update' and activity->>'$.object' = ?`, alice.ID).Scan(&updates))
+	assert.Equal(1, updates)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (l *Listener) handleReplies(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	postID := fmt.Sprintf("https://%s/post/%s", l.Domain, hash)
+	collectionID := fmt.Sprintf("https://%s/replies/%s", l.Domain, hash)
+
+	if !l.authorizeFetch(w, r) {
+		return
+	}
+
+	var exists int
+	if err := l.DB.QueryRowContext(r.Context(), `select exists (select 1 from notes where id = ? and public = 1)`, postID).Scan(&exists); err != nil {
+		slog.Warn("Failed to check if post exists", "post", postID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	} else if exists == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var total int
+	if err := l.DB.QueryRowContext(r.Context(), `select count(*) from notes where object->>'$.inReplyTo' = ? and public = 1`, postID).Scan(&total); err != nil {
+		slog.Warn("Failed to count replies", "post", postID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var collection map[string]any
+
+	if r.URL.RawQuery == "" {
+		slog.Info("Fetching replies", "post", postID)
+
+		collection = map[string]any{
+			"@context":   "https://www.w3.org/ns/activitystreams",
+			"id":         collectionID,
+			"type":       "OrderedCollection",
+			"totalItems": total,
+			"first":      collectionID + "?0",
+		}
+	} else {
+		offset, err := strconv.Atoi(r.URL.RawQuery)
+		if err != nil || offset < 0 {
+			slog.Warn("Failed to parse offset", "post", postID, "query", r.URL.RawQuery, "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		slog.Info("Fetching replies page", "post", postID, "offset", offset)
+
+		rows, err := l.DB.QueryContext(r.Context(), `select id from notes where object->>'$.inReplyTo' = ? and public = 1 order by inserted, id limit ? offset ?`, postID, l.Config.RepliesPerPage, offset)
+		if err != nil {
+			slog.Warn("Failed to fetch replies", "post", postID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		items := []string{}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				slog.Warn("Failed to scan reply", "post", postID, "error", err)
+				continue
+			}
+			items = append(items, id)
+		}
+
+		collection = map[string]any{
+			"@context":     "https://www.w3.org/ns/activitystreams",
+			"id":           fmt.Sprintf("%s?%d", collectionID, offset),
+			"type":         "OrderedCollectionPage",
+			"partOf":       collectionID,
+			"orderedItems": items,
+		}
+
+		if offset+l.Config.RepliesPerPage < total {
+			collection["next"] = fmt.Sprintf("%s?%d", collectionID, offset+l.Config.RepliesPerPage)
+		}
+	}
+
+	j, err := json.Marshal(collection)
+	if err != nil {
+		slog.Warn("Failed to marshal replies", "post", postID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeConditional(w, r, "application/activity+json; charset=utf-8", j, time.Time{})
+}
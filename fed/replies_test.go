@@ -0,0 +1,123 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/migrations"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplies(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.RepliesPerPage = 2
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	insert := func(id, inReplyTo string, public int) {
+		_, err := db.Exec(
+			`insert into notes(id, author, object, public) values(?, ?, ?, ?)`,
+			id,
+			alice.ID,
+			fmt.Sprintf(`{"type":"Note","id":"%s","attributedTo":"%s","inReplyTo":"%s","content":"hi","published":"2025-01-02T03:04:05Z","to":["https://www.w3.org/ns/activitystreams#Public"]}`, id, alice.ID, inReplyTo),
+			public,
+		)
+		assert.NoError(err)
+	}
+
+	insert("https://localhost.localdomain/post/1", "", 1)
+	insert("https://127.0.0.1/note/1", "https://localhost.localdomain/post/1", 1)
+	insert("https://127.0.0.1/note/2", "https://localhost.localdomain/post/1", 0)
+	insert("https://127.0.0.1/note/3", "https://localhost.localdomain/post/1", 1)
+	insert("https://127.0.0.1/note/4", "https://localhost.localdomain/post/1", 1)
+	insert("https://localhost.localdomain/post/2", "", 0)
+
+	l := Listener{Domain: "localhost.localdomain", Config: &cfg, DB: db}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /post/{hash}", l.handlePost)
+	mux.HandleFunc("GET /replies/{hash}", l.handleReplies)
+
+	get := func(path string) map[string]any {
+		r := httptest.NewRequest(http.MethodGet, "https://localhost.localdomain"+path, nil)
+		r.Header.Set("Accept", "application/activity+json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			return map[string]any{"status": float64(w.Code)}
+		}
+
+		var m map[string]any
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &m))
+		return m
+	}
+
+	assert.Equal("https://localhost.localdomain/replies/1", get("/post/1")["replies"])
+
+	collection := get("/replies/1")
+	assert.Equal("OrderedCollection", collection["type"])
+	assert.Equal(float64(3), collection["totalItems"])
+	assert.Equal("https://localhost.localdomain/replies/1?0", collection["first"])
+
+	page := get("/replies/1?0")
+	assert.Equal("OrderedCollectionPage", page["type"])
+	assert.Equal([]any{"https://127.0.0.1/note/1", "https://127.0.0.1/note/3"}, page["orderedItems"])
+	assert.Equal("https://localhost.localdomain/replies/1?2", page["next"])
+
+	page = get("/replies/1?2")
+	assert.Equal([]any{"https://127.0.0.1/note/4"}, page["orderedItems"])
+	assert.NotContains(page, "next")
+
+	_, err = db.Exec(`delete from notes where id = 'https://127.0.0.1/note/1'`)
+	assert.NoError(err)
+
+	assert.Equal(float64(2), get("/replies/1")["totalItems"])
+	assert.Equal([]any{"https://127.0.0.1/note/3", "https://127.0.0.1/note/4"}, get("/replies/1?0")["orderedItems"])
+
+	assert.Equal(float64(http.StatusNotFound), get("/replies/2")["status"])
+	assert.Equal(float64(http.StatusNotFound), get("/replies/3")["status"])
+	assert.Equal(float64(http.StatusBadRequest), get("/replies/1?x")["status"])
+}
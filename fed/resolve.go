@@ -35,12 +35,15 @@ import (
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/cfg"
 	"github.com/dimkr/tootik/data"
+	"github.com/dimkr/tootik/front/user"
 	"github.com/dimkr/tootik/httpsig"
 	"github.com/dimkr/tootik/lock"
+	"github.com/dimkr/tootik/metrics"
 )
 
 type webFingerResponse struct {
-	Subject string `json:"subject"`
+	Subject string   `json:"subject"`
+	Aliases []string `json:"aliases"`
 	Links   []struct {
 		Rel  string `json:"rel"`
 		Type string `json:"type"`
@@ -55,6 +58,29 @@ type Resolver struct {
 	BlockedDomains *BlockList
 	db             *sql.DB
 	locks          []lock.Lock
+	resolutions    flightGroup[string, resolution]
+	failures       ttlCache[string, error]
+}
+
+// resolution is the result of a shared actor resolution: the caller that did the work receives the actors, while
+// other callers receive deep copies decoded from a snapshot taken before the actors were returned.
+type resolution struct {
+	actor, cached         *ap.Actor
+	actorJSON, cachedJSON []byte
+}
+
+// copyActor decodes a deep copy of an actor from a snapshot, so each caller can modify its own copy.
+func copyActor(snapshot []byte) *ap.Actor {
+	if snapshot == nil {
+		return nil
+	}
+
+	var actor ap.Actor
+	if err := json.Unmarshal(snapshot, &actor); err != nil {
+		return nil
+	}
+
+	return &actor
 }
 
 var (
@@ -69,6 +95,14 @@ var (
 	ErrYoungActor     = errors.New("actor is too young")
 )
 
+var (
+	resolverCache  = metrics.NewCounter("tootik_resolver_cache_total", "Actor lookups by cache result", "result")
+	webFingerCache = metrics.NewCounter("tootik_webfinger_cache_total", "WebFinger lookups by cache result", "result")
+)
+
+// ResolveQuery looks up a cached actor by name and host.
+const ResolveQuery = `select actor, updated, fetched, inserted from persons where actor->>'$.preferredUsername' = $1 and host = $2`
+
 // NewResolver returns a new [Resolver].
 func NewResolver(blockedDomains *BlockList, domain string, cfg *cfg.Config, client Client, db *sql.DB) *Resolver {
 	r := Resolver{
@@ -100,7 +134,12 @@ func (r *Resolver) ResolveID(ctx context.Context, key httpsig.Key, id string, fl
 	}
 
 	var name string
-	if flags&ap.InstanceActor == 0 {
+	if u.Host == r.Domain && flags&ap.InstanceActor == 0 {
+		var ok bool
+		if name, ok = user.ParseID(r.Domain, r.Config, id); !ok {
+			return nil, fmt.Errorf("cannot resolve %s: %w", id, ErrNoLocalActor)
+		}
+	} else if flags&ap.InstanceActor == 0 {
 		name = path.Base(u.Path)
 
 		// strip the leading @ if URL follows the form https://a.b/@c
@@ -126,8 +165,55 @@ func (r *Resolver) Resolve(ctx context.Context, key httpsig.Key, host, name stri
 	}
 }
 
+// tryResolveOnce is like [Resolver.tryResolve] but concurrent resolutions of the same actor share the result, and
+// recently failed resolutions of an actor that is not cached fail immediately.
+func (r *Resolver) tryResolveOnce(ctx context.Context, key httpsig.Key, host, name string, flags ap.ResolverFlag) (*ap.Actor, *ap.Actor, error) {
+	if flags&ap.Offline != 0 || host == r.Domain {
+		return r.tryResolve(ctx, key, host, name, flags)
+	}
+
+	handle := name + "@" + host
+
+	if err, ok := r.failures.Get(handle, time.Now()); ok {
+		resolverCache.Inc("failure")
+		return nil, nil, err
+	}
+
+	res, shared, err := r.resolutions.Do(ctx, handle, func() (resolution, error) {
+		actor, cachedActor, err := r.tryResolve(ctx, key, host, name, flags)
+		if err != nil && actor == nil && cachedActor == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			r.failures.Set(handle, err, time.Now(), r.Config.ResolverFailureCacheTTL, r.Config.MaxResolverFailureCacheSize)
+		}
+
+		res := resolution{actor: actor, cached: cachedActor}
+
+		// callers can modify the returned actor, so callers that share the result get their own copy
+		if actor != nil {
+			var marshalErr error
+			if res.actorJSON, marshalErr = json.Marshal(actor); marshalErr != nil {
+				return resolution{}, fmt.Errorf("failed to marshal %s: %w", actor.ID, marshalErr)
+			}
+		}
+
+		if cachedActor != nil {
+			var marshalErr error
+			if res.cachedJSON, marshalErr = json.Marshal(cachedActor); marshalErr != nil {
+				return resolution{}, fmt.Errorf("failed to marshal %s: %w", cachedActor.ID, marshalErr)
+			}
+		}
+
+		return res, err
+	})
+
+	if shared {
+		return copyActor(res.actorJSON), copyActor(res.cachedJSON), err
+	}
+
+	return res.actor, res.cached, err
+}
+
 func (r *Resolver) tryResolveOrCache(ctx context.Context, key httpsig.Key, host, name string, flags ap.ResolverFlag) (*ap.Actor, error) {
-	actor, cachedActor, err := r.tryResolve(ctx, key, host, name, flags)
+	actor, cachedActor, err := r.tryResolveOnce(ctx, key, host, name, flags)
 	if err != nil && cachedActor != nil && cachedActor.Published != nil && time.Since(cachedActor.Published.Time) < r.Config.MinActorAge {
 		slog.Warn("Failed to update cached actor", "host", host, "name", name, "error", err)
 		return nil, ErrYoungActor
@@ -143,39 +229,35 @@ func (r *Resolver) tryResolveOrCache(ctx context.Context, key httpsig.Key, host,
 }
 
 func deleteActor(ctx context.Context, db *sql.DB, id string) {
-	if _, err := db.ExecContext(ctx, `delete from notesfts where exists (select 1 from notes where notes.author = ? and notesfts.id = notes.id)`, id); err != nil {
-		slog.Warn("Failed to delete notes by actor", "id", id, "error", err)
-	}
-
-	if _, err := db.ExecContext(ctx, `delete from shares where by = $1 or exists (select 1 from notes where notes.author = ? and notes.id = shares.note)`, id); err != nil {
-		slog.Warn("Failed to delete shares by actor", "id", id, "error", err)
-	}
-
-	if _, err := db.ExecContext(ctx, `delete from bookmarks where exists (select 1 from notes where notes.author = ? and notes.id = bookmarks.note)`, id); err != nil {
-		slog.Warn("Failed to delete bookmarks by actor", "id", id, "error", err)
-	}
-
-	if _, err := db.ExecContext(ctx, `delete from feed where sharer->>'$.id' = ?`, id); err != nil {
-		slog.Warn("Failed to delete shares by actor", "id", id, "error", err)
-	}
-
-	if _, err := db.ExecContext(ctx, `delete from feed where author->>'$.id' = ?`, id); err != nil {
-		slog.Warn("Failed to delete shares by actor", "id", id, "error", err)
-	}
-
-	if _, err := db.ExecContext(ctx, `delete from notes where author = ?`, id); err != nil {
-		slog.Warn("Failed to delete notes by actor", "id", id, "error", err)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Warn("Failed to delete actor", "id", id, "error", err)
+		return
 	}
+	defer tx.Rollback()
 
-	if _, err := db.ExecContext(ctx, `delete from follows where follower = $1 or followed = $1`, id); err != nil {
-		slog.Warn("Failed to delete follows for actor", "id", id, "error", err)
+	if err := data.DeleteActor(ctx, tx, id); err != nil {
+		slog.Warn("Failed to delete actor", "id", id, "error", err)
+		return
 	}
 
-	if _, err := db.ExecContext(ctx, `delete from persons where id = ?`, id); err != nil {
+	if err := tx.Commit(); err != nil {
 		slog.Warn("Failed to delete actor", "id", id, "error", err)
 	}
 }
 
+// cacheWebFinger caches the actor ID returned by a WebFinger query for the queried name: aliases are not cached,
+// because a server can claim aliases that belong to another server.
+func cacheWebFinger(ctx context.Context, tx *sql.Tx, host, name, actorID string) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO webfinger(resource, actor) VALUES($1, $2) ON CONFLICT(resource) DO UPDATE SET actor = $2, updated = UNIXEPOCH()`,
+		name+"@"+host,
+		actorID,
+	)
+	return err
+}
+
 func (r *Resolver) tryResolve(ctx context.Context, key httpsig.Key, host, name string, flags ap.ResolverFlag) (*ap.Actor, *ap.Actor, error) {
 	slog.Debug("Resolving actor", "host", host, "name", name)
 
@@ -203,7 +285,16 @@ func (r *Resolver) tryResolve(ctx context.Context, key httpsig.Key, host, name s
 	var updated, inserted int64
 	var fetched sql.NullInt64
 	var sinceLastUpdate time.Duration
-	if err := r.db.QueryRowContext(ctx, `select actor, updated, fetched, inserted from persons where actor->>'$.preferredUsername' = $1 and host = $2`, name, host).Scan(&tmp, &updated, &fetched, &inserted); err != nil && !errors.Is(err, sql.ErrNoRows) {
+	err := r.db.QueryRowContext(ctx, ResolveQuery, name, host).Scan(&tmp, &updated, &fetched, &inserted)
+	if errors.Is(err, sql.ErrNoRows) && isLocal {
+		// local user names are unique regardless of case
+		err = r.db.QueryRowContext(ctx, `select actor, updated, fetched, inserted from persons where host = $1 and actor->>'$.preferredUsername' = $2 collate nocase order by inserted limit 1`, host, name).Scan(&tmp, &updated, &fetched, &inserted)
+	}
+	if errors.Is(err, sql.ErrNoRows) && !isLocal {
+		// the actor might be hosted on another domain, like a subdomain of the domain in its handle
+		err = r.db.QueryRowContext(ctx, `select persons.actor, persons.updated, persons.fetched, persons.inserted from webfinger join persons on persons.id = webfinger.actor where webfinger.resource = $1 and webfinger.updated > $2`, name+"@"+host, time.Now().Add(-r.Config.WebFingerCacheTTL).Unix()).Scan(&tmp, &updated, &fetched, &inserted)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, nil, fmt.Errorf("failed to fetch %s%s cache: %w", name, host, err)
 	} else if err == nil {
 		cachedActor = &tmp
@@ -218,6 +309,7 @@ func (r *Resolver) tryResolve(ctx context.Context, key httpsig.Key, host, name s
 			slog.Info("Updating old cache entry for actor", "id", cachedActor.ID)
 		} else {
 			slog.Debug("Resolved actor using cache", "id", cachedActor.ID)
+			resolverCache.Inc("hit")
 			return nil, cachedActor, nil
 		}
 	}
@@ -230,6 +322,8 @@ func (r *Resolver) tryResolve(ctx context.Context, key httpsig.Key, host, name s
 		return nil, nil, fmt.Errorf("cannot resolve %s@%s: %w", name, host, ErrActorNotCached)
 	}
 
+	resolverCache.Inc("miss")
+
 	if cachedActor != nil {
 		if _, err := r.db.ExecContext(
 			ctx,
@@ -240,77 +334,98 @@ func (r *Resolver) tryResolve(ctx context.Context, key httpsig.Key, host, name s
 		}
 	}
 
-	finger := fmt.Sprintf("https://%s/.well-known/webfinger?resource=acct:%s@%s", host, name, host)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, finger, nil)
-	if err != nil {
-		return nil, cachedActor, fmt.Errorf("failed to fetch %s: %w", finger, err)
+	// when an old cache entry is updated, we repeat the WebFinger query in case the actor ID has changed
+	var profile string
+	var webFinger *webFingerResponse
+	if cachedActor == nil {
+		if err := r.db.QueryRowContext(ctx, `select actor from webfinger where resource = $1 and updated > $2`, name+"@"+host, time.Now().Add(-r.Config.WebFingerCacheTTL).Unix()).Scan(&profile); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("failed to fetch %s@%s WebFinger cache: %w", name, host, err)
+		}
 	}
-	req.Header.Set("User-Agent", userAgent)
 
-	resp, err := r.send(key, req)
-	if err != nil {
-		if resp != nil && (resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound) {
-			if cachedActor != nil {
-				slog.Warn("Actor is gone, deleting associated objects", "id", cachedActor.ID)
-				deleteActor(ctx, r.db, cachedActor.ID)
-			}
-			return nil, nil, fmt.Errorf("failed to fetch %s: %w", finger, ErrActorGone)
+	if profile != "" {
+		webFingerCache.Inc("hit")
+	} else {
+		webFingerCache.Inc("miss")
+
+		finger := fmt.Sprintf("https://%s/.well-known/webfinger?resource=acct:%s@%s", host, name, host)
+
+		fetchCtx, cancel := context.WithTimeout(ctx, r.Config.FetchTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, finger, nil)
+		if err != nil {
+			return nil, cachedActor, fmt.Errorf("failed to fetch %s: %w", finger, err)
 		}
+		req.Header.Set("User-Agent", userAgent)
+
+		resp, err := r.send(key, req)
+		if err != nil {
+			if resp != nil && (resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound) {
+				if cachedActor != nil {
+					slog.Warn("Actor is gone, deleting associated objects", "id", cachedActor.ID)
+					deleteActor(ctx, r.db, cachedActor.ID)
+				}
+				return nil, nil, fmt.Errorf("failed to fetch %s: %w", finger, ErrActorGone)
+			}
 
-		var (
-			urlError *url.Error
-			opError  *net.OpError
-			dnsError *net.DNSError
-		)
-		// if it's been a while since the last update and the server's domain is expired (NXDOMAIN), actor is gone
-		if sinceLastUpdate > r.Config.MaxInstanceRecoveryTime && errors.As(err, &urlError) && errors.As(urlError.Err, &opError) && errors.As(opError.Err, &dnsError) && dnsError.IsNotFound {
-			if cachedActor != nil {
-				slog.Warn("Server is probably gone, deleting associated objects", "id", cachedActor.ID)
-				deleteActor(ctx, r.db, cachedActor.ID)
+			var (
+				urlError *url.Error
+				opError  *net.OpError
+				dnsError *net.DNSError
+			)
+			// if it's been a while since the last update and the server's domain is expired (NXDOMAIN), actor is gone
+			if sinceLastUpdate > r.Config.MaxInstanceRecoveryTime && errors.As(err, &urlError) && errors.As(urlError.Err, &opError) && errors.As(opError.Err, &dnsError) && dnsError.IsNotFound {
+				if cachedActor != nil {
+					slog.Warn("Server is probably gone, deleting associated objects", "id", cachedActor.ID)
+					deleteActor(ctx, r.db, cachedActor.ID)
+				}
+				return nil, nil, fmt.Errorf("failed to fetch %s: %w", finger, err)
 			}
-			return nil, nil, fmt.Errorf("failed to fetch %s: %w", finger, err)
-		}
 
-		return nil, cachedActor, fmt.Errorf("failed to fetch %s: %w", finger, err)
-	}
-	defer resp.Body.Close()
+			return nil, cachedActor, fmt.Errorf("failed to fetch %s: %w", finger, err)
+		}
+		defer resp.Body.Close()
 
-	if resp.ContentLength > r.Config.MaxResponseBodySize {
-		return nil, cachedActor, fmt.Errorf("failed to decode %s response: response is too big", finger)
-	}
+		if resp.ContentLength > r.Config.MaxResponseBodySize {
+			return nil, cachedActor, fmt.Errorf("failed to decode %s response: response is too big", finger)
+		}
 
-	var webFingerResponse webFingerResponse
-	if err := json.NewDecoder(io.LimitReader(resp.Body, r.Config.MaxResponseBodySize)).Decode(&webFingerResponse); err != nil {
-		return nil, cachedActor, fmt.Errorf("failed to decode %s response: %w", finger, err)
-	}
+		var webFingerResponse webFingerResponse
+		if err := json.NewDecoder(io.LimitReader(resp.Body, r.Config.MaxResponseBodySize)).Decode(&webFingerResponse); err != nil {
+			return nil, cachedActor, fmt.Errorf("failed to decode %s response: %w", finger, err)
+		}
 
-	profile := ""
+		for _, link := range webFingerResponse.Links {
+			if link.Rel != "self" {
+				continue
+			}
 
-	for _, link := range webFingerResponse.Links {
-		if link.Rel != "self" {
-			continue
-		}
+			if link.Type != "application/activity+json" && link.Type != `application/ld+json; profile="https://www.w3.org/ns/activitystreams"` {
+				continue
+			}
 
-		if link.Type != "application/activity+json" && link.Type != `application/ld+json; profile="https://www.w3.org/ns/activitystreams"` {
-			continue
+			if link.Href != "" {
+				profile = link.Href
+				break
+			}
 		}
 
-		if link.Href != "" {
-			profile = link.Href
-			break
+		if profile == "" {
+			return nil, cachedActor, fmt.Errorf("no profile link in %s response", finger)
 		}
-	}
 
-	if profile == "" {
-		return nil, cachedActor, fmt.Errorf("no profile link in %s response", finger)
+		webFinger = &webFingerResponse
 	}
 
 	if cachedActor != nil && profile != cachedActor.ID {
 		return nil, cachedActor, fmt.Errorf("%s does not match %s", profile, cachedActor.ID)
 	}
 
-	req, err = http.NewRequestWithContext(ctx, http.MethodGet, profile, nil)
+	fetchCtx, cancel := context.WithTimeout(ctx, r.Config.FetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, profile, nil)
 	if err != nil {
 		return nil, cachedActor, fmt.Errorf("failed to send request to %s: %w", profile, err)
 	}
@@ -326,7 +441,7 @@ func (r *Resolver) tryResolve(ctx context.Context, key httpsig.Key, host, name s
 	req.Header.Set("User-Agent", userAgent)
 	req.Header.Add("Accept", "application/activity+json")
 
-	resp, err = r.send(key, req)
+	resp, err := r.send(key, req)
 	if err != nil {
 		return nil, cachedActor, fmt.Errorf("failed to fetch %s: %w", profile, err)
 	}
@@ -354,6 +469,7 @@ func (r *Resolver) tryResolve(ctx context.Context, key httpsig.Key, host, name s
 	if err != nil {
 		return nil, cachedActor, fmt.Errorf("failed to cache %s: %w", actor.ID, err)
 	}
+	defer tx.Rollback()
 
 	if _, err := tx.ExecContext(
 		ctx,
@@ -364,6 +480,12 @@ func (r *Resolver) tryResolve(ctx context.Context, key httpsig.Key, host, name s
 		return nil, cachedActor, fmt.Errorf("failed to cache %s: %w", actor.ID, err)
 	}
 
+	if webFinger != nil {
+		if err := cacheWebFinger(ctx, tx, host, name, actor.ID); err != nil {
+			return nil, cachedActor, fmt.Errorf("failed to cache %s: %w", actor.ID, err)
+		}
+	}
+
 	if _, err := tx.ExecContext(
 		ctx,
 		`UPDATE feed SET author = ? WHERE author->>'$.id' = ?`,
@@ -20,12 +20,14 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/cfg"
@@ -94,7 +96,7 @@ func TestResolve_LocalActor(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	nobody, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	nobody, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -130,7 +132,7 @@ func TestResolve_LocalActorDoesNotExist(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -206,7 +208,7 @@ func TestResolve_FederatedInstanceActor(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -242,7 +244,7 @@ func TestResolve_FederatedActorInvalidURL(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -274,7 +276,7 @@ func TestResolve_FederatedActorInvalidScheme(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -306,7 +308,7 @@ func TestResolve_FederatedActorEmptyName(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -382,7 +384,7 @@ func TestResolve_FederatedActorFirstTime(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -462,7 +464,7 @@ func TestResolve_FederatedActorFirstTimeThroughMention(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -498,7 +500,7 @@ func TestResolve_FederatedActorFirstTimeOffline(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -531,7 +533,7 @@ func TestResolve_FederatedActorFirstTimeCancelled(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -620,7 +622,7 @@ func TestResolve_FederatedActorFirstTimeInvalidWebFingerLink(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -700,7 +702,7 @@ func TestResolve_FederatedActorFirstTimeActorIDMismatch(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -777,7 +779,7 @@ func TestResolve_FederatedActorCached(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -843,7 +845,7 @@ func TestResolve_FederatedActorCachedInvalidActorHost(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -900,7 +902,7 @@ func TestResolve_FederatedActorCachedActorHostWithPort(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -977,7 +979,7 @@ func TestResolve_FederatedActorCachedActorHostSubdomain(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -989,7 +991,7 @@ func TestResolve_FederatedActorCachedActorHostSubdomain(t *testing.T) {
 	assert.Equal("https://tootik.0.0.0.0/user/dan", actor.ID)
 	assert.Equal("https://0.0.0.0/inbox/dan", actor.Inbox)
 
-	_, err = db.Exec(`update persons set updated = unixepoch() - 60*60*24*7, fetched = unixepoch() - 60*60*7 where id = 'https://0.0.0.0/user/dan'`)
+	_, err = db.Exec(`update persons set updated = unixepoch() - 60*60*24*7, fetched = unixepoch() - 60*60*7 where id = 'https://tootik.0.0.0.0/user/dan'`)
 	assert.NoError(err)
 
 	client.Data = map[string]testResponse{
@@ -1113,7 +1115,7 @@ func TestResolve_FederatedActorCachedActorHostSubdomainFetchedRecently(t *testin
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -1202,7 +1204,7 @@ func TestResolve_FederatedActorCachedActorIDChanged(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -1318,7 +1320,7 @@ func TestResolve_FederatedActorCachedButBlocked(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -1411,7 +1413,7 @@ func TestResolve_FederatedActorOldCache(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -1554,7 +1556,7 @@ func TestResolve_FederatedActorOldCacheWasSuspended(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -1694,7 +1696,7 @@ func TestResolve_FederatedActorOldCacheWasNew(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -1833,7 +1835,7 @@ func TestResolve_FederatedActorOldCacheUpdateFailed(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -1954,7 +1956,7 @@ func TestResolve_FederatedActorOldCacheStillNew(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2084,7 +2086,7 @@ func TestResolve_FederatedActorOldCacheWasOld(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2218,7 +2220,7 @@ func TestResolve_FederatedActorOldCacheWasNewNowUnknown(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2348,7 +2350,7 @@ func TestResolve_FederatedActorOldCacheFetchedRecently(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2437,7 +2439,7 @@ func TestResolve_FederatedActorOldCacheButOffline(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2506,7 +2508,7 @@ func TestResolve_FederatedActorOldCacheInvalidID(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2583,7 +2585,7 @@ func TestResolve_FederatedActorOldCacheInvalidWebFingerResponse(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2683,7 +2685,7 @@ func TestResolve_FederatedActorOldCacheBigWebFingerResponse(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2801,7 +2803,7 @@ func TestResolve_FederatedActorOldCacheInvalidActor(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -2923,7 +2925,7 @@ func TestResolve_FederatedActorOldCacheBigActor(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -3061,7 +3063,7 @@ func TestResolve_FederatedActorNoProfileLink(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -3183,7 +3185,7 @@ func TestResolve_FederatedActorOldCacheWebFingerError(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -3285,7 +3287,7 @@ func TestResolve_FederatedActorOldCacheActorError(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -3410,7 +3412,7 @@ func TestResolve_FederatedActorOldCacheActorDeleted(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	tx, err := db.BeginTx(context.Background(), nil)
@@ -3546,7 +3548,7 @@ func TestResolve_FederatedActorFirstTimeWrongID(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -3606,7 +3608,7 @@ func TestResolve_FederatedActorFirstTimeDeleted(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	tx, err := db.BeginTx(context.Background(), nil)
@@ -3705,7 +3707,7 @@ func TestResolve_FederatedActorFirstTimeTooYoung(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -3783,7 +3785,7 @@ func TestResolve_FederatedActorFirstTimeSuspended(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -3860,7 +3862,7 @@ func TestResolve_FederatedActorWrongIDCached(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -3946,7 +3948,7 @@ func TestResolve_FederatedActorWrongIDCachedOldCache(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -4082,7 +4084,7 @@ func TestResolve_FederatedActorWrongIDOldCache(t *testing.T) {
 
 	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
 
-	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", db)
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
 	assert.NoError(err)
 
 	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
@@ -4211,3 +4213,373 @@ func TestResolve_FederatedActorWrongIDOldCache(t *testing.T) {
 	assert.Equal("https://0.0.0.0/users/dan", actor.ID)
 	assert.Equal("https://0.0.0.0/inbox/dan456", actor.Inbox)
 }
+
+func TestResolve_WebFingerCache(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+
+	blockList := BlockList{}
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	actor := `{
+		"@context": [
+			"https://www.w3.org/ns/activitystreams",
+			"https://w3id.org/security/v1"
+		],
+		"id": "https://tootik.0.0.0.0/user/dan",
+		"type": "Person",
+		"inbox": "https://tootik.0.0.0.0/inbox/dan",
+		"outbox": "https://tootik.0.0.0.0/outbox/dan",
+		"preferredUsername": "dan",
+		"followers": "https://tootik.0.0.0.0/followers/dan",
+		"endpoints": {
+			"sharedInbox": "https://tootik.0.0.0.0/inbox/nobody"
+		}
+	}`
+
+	client := newTestClient(map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+			Response: newTestResponse(
+				http.StatusOK,
+				`{
+					"aliases": [
+						"https://tootik.0.0.0.0/user/dan",
+						"acct:dan@tootik.0.0.0.0",
+						"acct:dan@1.1.1.1"
+					],
+					"links": [
+						{
+							"href": "https://tootik.0.0.0.0/user/dan",
+							"rel": "self",
+							"type": "application/activity+json"
+						}
+					],
+					"subject": "acct:dan@0.0.0.0"
+				}`,
+			),
+		},
+		"https://tootik.0.0.0.0/user/dan": {
+			Response: newTestResponse(http.StatusOK, actor),
+		},
+	})
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
+
+	resolved, err := resolver.Resolve(context.Background(), key, "0.0.0.0", "dan", 0)
+	assert.NoError(err)
+	assert.Empty(client.Data)
+	assert.Equal("https://tootik.0.0.0.0/user/dan", resolved.ID)
+
+	// aliases are not cached
+	var resources []string
+	rows, err := db.Query(`select resource from webfinger where actor = 'https://tootik.0.0.0.0/user/dan' order by resource`)
+	assert.NoError(err)
+	for rows.Next() {
+		var resource string
+		assert.NoError(rows.Scan(&resource))
+		resources = append(resources, resource)
+	}
+	rows.Close()
+	assert.Equal([]string{"dan@0.0.0.0"}, resources)
+
+	// the handle is resolved using the cache, although the actor is hosted on a subdomain
+	resolved, err = resolver.Resolve(context.Background(), key, "0.0.0.0", "dan", 0)
+	assert.NoError(err)
+	assert.Equal("https://tootik.0.0.0.0/user/dan", resolved.ID)
+
+	// if the actor is deleted from the cache, it's fetched again without a WebFinger query
+	_, err = db.Exec(`delete from persons where id = 'https://tootik.0.0.0.0/user/dan'`)
+	assert.NoError(err)
+
+	client.Data = map[string]testResponse{
+		"https://tootik.0.0.0.0/user/dan": {
+			Response: newTestResponse(http.StatusOK, actor),
+		},
+	}
+
+	resolved, err = resolver.Resolve(context.Background(), key, "0.0.0.0", "dan", 0)
+	assert.NoError(err)
+	assert.Empty(client.Data)
+	assert.Equal("https://tootik.0.0.0.0/user/dan", resolved.ID)
+}
+
+func TestResolve_WebFingerCacheExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+
+	blockList := BlockList{}
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	finger := `{
+		"links": [
+			{
+				"href": "https://tootik.0.0.0.0/user/dan",
+				"rel": "self",
+				"type": "application/activity+json"
+			}
+		],
+		"subject": "acct:dan@0.0.0.0"
+	}`
+
+	actor := `{
+		"@context": [
+			"https://www.w3.org/ns/activitystreams",
+			"https://w3id.org/security/v1"
+		],
+		"id": "https://tootik.0.0.0.0/user/dan",
+		"type": "Person",
+		"inbox": "https://tootik.0.0.0.0/inbox/dan",
+		"outbox": "https://tootik.0.0.0.0/outbox/dan",
+		"preferredUsername": "dan",
+		"followers": "https://tootik.0.0.0.0/followers/dan",
+		"endpoints": {
+			"sharedInbox": "https://tootik.0.0.0.0/inbox/nobody"
+		}
+	}`
+
+	client := newTestClient(map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+			Response: newTestResponse(http.StatusOK, finger),
+		},
+		"https://tootik.0.0.0.0/user/dan": {
+			Response: newTestResponse(http.StatusOK, actor),
+		},
+	})
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
+
+	_, err = resolver.Resolve(context.Background(), key, "0.0.0.0", "dan", 0)
+	assert.NoError(err)
+	assert.Empty(client.Data)
+
+	_, err = db.Exec(`update webfinger set updated = unixepoch() - 60*60*24*8`)
+	assert.NoError(err)
+
+	_, err = db.Exec(`delete from persons where id = 'https://tootik.0.0.0.0/user/dan'`)
+	assert.NoError(err)
+
+	client.Data = map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+			Response: newTestResponse(http.StatusOK, finger),
+		},
+		"https://tootik.0.0.0.0/user/dan": {
+			Response: newTestResponse(http.StatusOK, actor),
+		},
+	}
+
+	_, err = resolver.Resolve(context.Background(), key, "0.0.0.0", "dan", 0)
+	assert.NoError(err)
+	assert.Empty(client.Data)
+}
+
+func TestResolve_FederatedActorConcurrentFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+
+	blockList := BlockList{}
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	// only one request is allowed: all other resolutions must share its result or use the failure cache
+	client := newTestClient(map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+			Response: newTestResponse(http.StatusInternalServerError, `{}`),
+		},
+	})
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := resolver.ResolveID(context.Background(), key, "https://0.0.0.0/user/dan", 0)
+			assert.Error(err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Empty(client.Data)
+
+	// the failure is forgotten after a while
+	resolver.failures.Delete("dan@0.0.0.0")
+
+	client.Data = map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+			Response: newTestResponse(http.StatusInternalServerError, `{}`),
+		},
+	}
+
+	_, err = resolver.ResolveID(context.Background(), key, "https://0.0.0.0/user/dan", 0)
+	assert.Error(err)
+	assert.Empty(client.Data)
+}
+
+func TestResolve_CopyActor(t *testing.T) {
+	assert := assert.New(t)
+
+	actor := ap.Actor{
+		ID:         "https://0.0.0.0/user/dan",
+		Type:       ap.Person,
+		Endpoints:  map[string]string{"sharedInbox": "https://0.0.0.0/inbox/nobody"},
+		Attachment: []ap.Attachment{{Type: ap.PropertyValue, Name: "a", Value: "b"}},
+	}
+
+	snapshot, err := json.Marshal(&actor)
+	assert.NoError(err)
+
+	copied := copyActor(snapshot)
+	assert.Equal(actor.ID, copied.ID)
+	assert.Equal(actor.Attachment, copied.Attachment)
+
+	// modifying the copy doesn't change the original
+	copied.Endpoints["sharedInbox"] = "https://1.1.1.1/inbox/nobody"
+	copied.Attachment[0].Value = "c"
+	assert.Equal("https://0.0.0.0/inbox/nobody", actor.Endpoints["sharedInbox"])
+	assert.Equal("b", actor.Attachment[0].Value)
+
+	assert.Nil(copyActor(nil))
+}
+
+func TestResolve_CircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+
+	blockList := BlockList{}
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+	cfg.CircuitBreakerThreshold = 2
+
+	client := newTestClient(nil)
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, key, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	resolver := NewResolver(&blockList, "localhost.localdomain", &cfg, &client, db)
+
+	for range 2 {
+		client.Data = map[string]testResponse{
+			"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+				Error: context.DeadlineExceeded,
+			},
+		}
+
+		_, err := resolver.ResolveID(context.Background(), key, "https://0.0.0.0/user/dan", 0)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+		assert.Empty(client.Data)
+	}
+
+	// requests to this server are suspended after two consecutive timeouts
+	_, err = resolver.ResolveID(context.Background(), key, "https://0.0.0.0/user/dan", 0)
+	assert.ErrorIs(err, ErrCircuitOpen)
+
+	_, err = resolver.ResolveID(context.Background(), key, "https://0.0.0.0/user/erin", 0)
+	assert.ErrorIs(err, ErrCircuitOpen)
+
+	resolver.failures.Delete("dan@0.0.0.0")
+	resolver.failures.Delete("erin@0.0.0.0")
+
+	// other servers are not affected
+	client.Data = map[string]testResponse{
+		"https://1.2.3.4/.well-known/webfinger?resource=acct:dan@1.2.3.4": {
+			Response: newTestResponse(http.StatusInternalServerError, `{}`),
+		},
+	}
+
+	_, err = resolver.ResolveID(context.Background(), key, "https://1.2.3.4/user/dan", 0)
+	assert.Error(err)
+	assert.NotErrorIs(err, ErrCircuitOpen)
+	assert.Empty(client.Data)
+
+	// after a while, one request is allowed through and the server responds
+	cfg.CircuitBreakerCooldown = time.Nanosecond
+
+	client.Data = map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+			Response: newTestResponse(http.StatusInternalServerError, `{}`),
+		},
+	}
+
+	_, err = resolver.ResolveID(context.Background(), key, "https://0.0.0.0/user/dan", 0)
+	assert.Error(err)
+	assert.NotErrorIs(err, ErrCircuitOpen)
+	assert.Empty(client.Data)
+
+	cfg.CircuitBreakerCooldown = time.Hour
+
+	client.Data = map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:erin@0.0.0.0": {
+			Response: newTestResponse(http.StatusInternalServerError, `{}`),
+		},
+	}
+
+	_, err = resolver.ResolveID(context.Background(), key, "https://0.0.0.0/user/erin", 0)
+	assert.Error(err)
+	assert.NotErrorIs(err, ErrCircuitOpen)
+	assert.Empty(client.Data)
+}
@@ -18,8 +18,11 @@ package fed
 
 import "net/http"
 
-func robots(w http.ResponseWriter, r *http.Request) {
+func (l *Listener) robots(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte("User-agent: *\n"))
+	if l.Frontend != nil {
+		w.Write([]byte("Allow: /web/\n"))
+	}
 	w.Write([]byte("Disallow: /\n"))
 }
@@ -0,0 +1,152 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/outbox"
+)
+
+var rssHashtagRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+}
+
+type rssItem struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	GUID        string         `xml:"guid"`
+	PubDate     string         `xml:"pubDate"`
+	Description string         `xml:"description"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// rssEnclosures returns the audio and video attachments of a post.
+func rssEnclosures(note *ap.Object) []rssEnclosure {
+	var enclosures []rssEnclosure
+
+	for _, attachment := range note.Attachment {
+		if attachment.URL == "" {
+			continue
+		}
+
+		if attachment.Type == ap.Audio || attachment.Type == ap.Video || strings.HasPrefix(attachment.MediaType, "audio/") || strings.HasPrefix(attachment.MediaType, "video/") {
+			enclosures = append(enclosures, rssEnclosure{URL: attachment.URL, Type: attachment.MediaType})
+		}
+	}
+
+	return enclosures
+}
+
+func (l *Listener) handleHashtagRSS(w http.ResponseWriter, r *http.Request) {
+	tag := r.PathValue("tag")
+	if !rssHashtagRegex.MatchString(tag) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	rows, err := l.DB.QueryContext(
+		r.Context(),
+		`select notes.object, persons.actor from notes join hashtags on notes.id = hashtags.note join persons on persons.id = notes.author where notes.public = 1 and hashtags.hashtag = ? and not exists (select 1 from domainblocks where domainblocks.severity = 'silence' and (domainblocks.host = notes.host or notes.host like '%.' || domainblocks.host)) order by notes.inserted desc limit ?`,
+		tag,
+		l.Config.RSSItems,
+	)
+	if err != nil {
+		slog.Warn("Failed to fetch posts", "hashtag", tag, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       fmt.Sprintf("Posts tagged #%s on %s", tag, l.Domain),
+			Link:        fmt.Sprintf("gemini://%s/hashtag/%s", l.Domain, tag),
+			Description: fmt.Sprintf("Recent public posts tagged #%s", tag),
+		},
+	}
+
+	for rows.Next() {
+		var note ap.Object
+		var author ap.Actor
+		if err := rows.Scan(&note, &author); err != nil {
+			slog.Warn("Failed to scan post", "hashtag", tag, "error", err)
+			continue
+		}
+
+		title := excerpt(&note)
+		if title == "" {
+			title = fmt.Sprintf("Post by %s", authorName(&author))
+		}
+
+		// posts with a content warning are represented by their summary
+		description := note.Content
+		if note.Summary != "" || note.Sensitive {
+			description = excerpt(&note)
+		}
+
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       title,
+			Link:        outbox.PostURL(l.Domain, l.Config, note.ID),
+			GUID:        note.ID,
+			PubDate:     note.Published.UTC().Format(http.TimeFormat),
+			Description: description,
+			Enclosures:  rssEnclosures(&note),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Warn("Failed to fetch posts", "hashtag", tag, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	b, err := xml.Marshal(feed)
+	if err != nil {
+		slog.Warn("Failed to marshal RSS feed", "hashtag", tag, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
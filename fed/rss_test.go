@@ -0,0 +1,116 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/migrations"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashtagRSS(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	alice, _, err := user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	for _, post := range []struct {
+		id, object string
+		public     int
+	}{
+		{"https://localhost.localdomain/post/1", `{"type":"Note","id":"https://localhost.localdomain/post/1","attributedTo":"` + alice.ID + `","content":"<p>Hello &amp; #world</p>","published":"2025-01-02T03:04:05Z","attachment":[{"type":"Document","mediaType":"audio/ogg","url":"https://localhost.localdomain/a.ogg"},{"type":"Image","mediaType":"image/png","url":"https://localhost.localdomain/a.png"}]}`, 1},
+		{"https://localhost.localdomain/post/2", `{"type":"Note","id":"https://localhost.localdomain/post/2","attributedTo":"` + alice.ID + `","content":"<p>Spoiler #world</p>","summary":"CW","sensitive":true,"published":"2025-01-02T03:04:06Z"}`, 1},
+		{"https://localhost.localdomain/post/3", `{"type":"Note","id":"https://localhost.localdomain/post/3","attributedTo":"` + alice.ID + `","content":"<p>Secret #world</p>","published":"2025-01-02T03:04:07Z"}`, 0},
+	} {
+		_, err = db.Exec(`insert into notes(id, author, object, public) values(?, ?, ?, ?)`, post.id, alice.ID, post.object, post.public)
+		assert.NoError(err)
+
+		_, err = db.Exec(`insert into hashtags(note, hashtag) values(?, 'world')`, post.id)
+		assert.NoError(err)
+	}
+
+	_, err = db.Exec(`update notes set inserted = inserted + 1 where id = 'https://localhost.localdomain/post/2'`)
+	assert.NoError(err)
+
+	l := Listener{Domain: "localhost.localdomain", Config: &cfg, DB: db}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hashtag/{tag}/rss", l.handleHashtagRSS)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "https://localhost.localdomain"+path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	resp := get("/hashtag/world/rss")
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("application/rss+xml; charset=utf-8", resp.Header().Get("Content-Type"))
+
+	var feed rssFeed
+	assert.NoError(xml.Unmarshal(resp.Body.Bytes(), &feed))
+	assert.Equal("2.0", feed.Version)
+	assert.Equal("gemini://localhost.localdomain/hashtag/world", feed.Channel.Link)
+	assert.Len(feed.Channel.Items, 2)
+
+	assert.Equal("CW", feed.Channel.Items[0].Title)
+	assert.Equal("CW", feed.Channel.Items[0].Description)
+	assert.Empty(feed.Channel.Items[0].Enclosures)
+
+	assert.Equal("Hello & #world", feed.Channel.Items[1].Title)
+	assert.Equal("https://localhost.localdomain/post/1", feed.Channel.Items[1].GUID)
+	assert.Equal("gemini://localhost.localdomain/view/localhost.localdomain/post/1", feed.Channel.Items[1].Link)
+	assert.Equal("Thu, 02 Jan 2025 03:04:05 GMT", feed.Channel.Items[1].PubDate)
+	assert.Equal("<p>Hello &amp; #world</p>", feed.Channel.Items[1].Description)
+	assert.Equal([]rssEnclosure{{URL: "https://localhost.localdomain/a.ogg", Type: "audio/ogg"}}, feed.Channel.Items[1].Enclosures)
+
+	empty := get("/hashtag/nothing/rss")
+	assert.Equal(http.StatusOK, empty.Code)
+	var emptyFeed rssFeed
+	assert.NoError(xml.Unmarshal(empty.Body.Bytes(), &emptyFeed))
+	assert.Empty(emptyFeed.Channel.Items)
+
+	assert.Equal(http.StatusNotFound, get("/hashtag/a-b/rss").Code)
+}
@@ -18,24 +18,52 @@ package fed
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/dimkr/tootik/buildinfo"
 	"github.com/dimkr/tootik/cfg"
 	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/metrics"
 )
 
 type sender struct {
-	Domain string
-	Config *cfg.Config
-	client Client
+	Domain   string
+	Config   *cfg.Config
+	client   Client
+	breakers circuitBreakers[string]
 }
 
-var userAgent = "tootik/" + buildinfo.Version
+// cancelOnClose cancels the context of a request when its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// ErrCircuitOpen is returned when requests to a server are suspended after consecutive timeouts.
+var ErrCircuitOpen = errors.New("server is not responding")
+
+var (
+	userAgent = "tootik/" + buildinfo.Version
+
+	circuitBreakerOpened = metrics.NewCounter("tootik_circuit_breaker_opened_total", "Times requests to a server were suspended after consecutive timeouts", "domain")
+)
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
 
 func (s *sender) send(key httpsig.Key, req *http.Request) (*http.Response, error) {
 	urlString := req.URL.String()
@@ -56,11 +84,25 @@ func (s *sender) send(key httpsig.Key, req *http.Request) (*http.Response, error
 		return nil, fmt.Errorf("failed to sign request for %s: %w", urlString, err)
 	}
 
+	if !s.breakers.Allow(req.URL.Host, time.Now(), s.Config.CircuitBreakerCooldown) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("failed to send request to %s: %w", urlString, ErrCircuitOpen)
+	}
+
 	resp, err := s.client.Do(req)
 	if err != nil {
+		if isTimeout(err) && s.breakers.Timeout(req.URL.Host, time.Now(), s.Config.CircuitBreakerThreshold, s.Config.CircuitBreakerCooldown) {
+			slog.Warn("Suspending requests to server after consecutive timeouts", "host", req.URL.Host)
+			circuitBreakerOpened.Inc(req.URL.Host)
+		}
+
 		return nil, fmt.Errorf("failed to send request to %s: %w", urlString, err)
 	}
 
+	s.breakers.Success(req.URL.Host)
+
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		defer resp.Body.Close()
 
@@ -78,14 +120,25 @@ func (s *sender) send(key httpsig.Key, req *http.Request) (*http.Response, error
 	return resp, nil
 }
 
+// Get fetches an object and fails if the response doesn't arrive within FetchTimeout.
 func (s *sender) Get(ctx context.Context, key httpsig.Key, url string) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.Config.FetchTimeout)
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("failed to send request to %s: %w", url, err)
 	}
 
 	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Accept", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
 
-	return s.send(key, req)
+	resp, err := s.send(key, req)
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
 }
@@ -0,0 +1,92 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBuckets limits the rate of events per key, using a token bucket for each key.
+// The zero value is an empty set of buckets.
+type tokenBuckets[K comparable] struct {
+	lock    sync.Mutex
+	buckets map[K]*tokenBucket
+	pruned  time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+const tokenBucketsPruneInterval = time.Minute
+
+// get returns the bucket of a key, after adding tokens that have accumulated since the last update.
+func (b *tokenBuckets[K]) get(key K, now time.Time, interval time.Duration, burst int) *tokenBucket {
+	if b.buckets == nil {
+		b.buckets = map[K]*tokenBucket{}
+	}
+
+	// forget buckets that are full again
+	if now.Sub(b.pruned) >= tokenBucketsPruneInterval {
+		for k, t := range b.buckets {
+			if now.Sub(t.updated) >= time.Duration(float64(burst)-t.tokens)*interval {
+				delete(b.buckets, k)
+			}
+		}
+
+		b.pruned = now
+	}
+
+	t, ok := b.buckets[key]
+	if !ok {
+		t = &tokenBucket{tokens: float64(burst), updated: now}
+		b.buckets[key] = t
+		return t
+	}
+
+	t.tokens = min(float64(burst), t.tokens+float64(now.Sub(t.updated))/float64(interval))
+	t.updated = now
+	return t
+}
+
+// Wait returns the time until the bucket of a key has a token, or 0 if it's not empty.
+func (b *tokenBuckets[K]) Wait(key K, now time.Time, interval time.Duration, burst int) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if t := b.get(key, now, interval, burst); t.tokens < 1 {
+		return time.Duration((1 - t.tokens) * float64(interval))
+	}
+
+	return 0
+}
+
+// Take removes a token from the bucket of a key, or returns the time until it has one if it's empty.
+func (b *tokenBuckets[K]) Take(key K, now time.Time, interval time.Duration, burst int) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	t := b.get(key, now, interval, burst)
+	if t.tokens < 1 {
+		return time.Duration((1 - t.tokens) * float64(interval))
+	}
+
+	t.tokens--
+	return 0
+}
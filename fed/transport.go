@@ -0,0 +1,133 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/metrics"
+)
+
+var connections = metrics.NewCounter("tootik_connections_total", "Connections used for requests to other servers", "domain", "reused")
+
+// ErrNoOnionProxy is returned when tootik tries to talk to a .onion host without a proxy that can reach it.
+var ErrNoOnionProxy = errors.New("no proxy for .onion hosts")
+
+type transport struct {
+	clearnet *http.Transport
+	onion    *http.Transport
+}
+
+// isOnion determines whether or not a host name belongs to a Tor onion service.
+func isOnion(hostname string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(strings.ToLower(hostname), "."), ".onion")
+}
+
+func parseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+	}
+}
+
+// NewTransport returns a [http.RoundTripper] for requests to other servers.
+//
+// Connections to clearnet hosts are established using a dialer that caches DNS lookup results and tries both IPv6
+// and IPv4 addresses. If Proxy is set, requests are sent through this HTTP or SOCKS5 proxy. Requests to .onion hosts are sent through
+// OnionProxy and fail if it's unset.
+func NewTransport(cfg *cfg.Config) (http.RoundTripper, error) {
+	return newTransport(cfg)
+}
+
+func newTransport(cfg *cfg.Config) (*transport, error) {
+	t := transport{
+		clearnet: &http.Transport{
+			DialContext:     newDialer(cfg).DialContext,
+			MaxIdleConns:    cfg.ResolverMaxIdleConns,
+			IdleConnTimeout: cfg.ResolverIdleConnTimeout,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+			},
+		},
+	}
+
+	if cfg.Proxy != "" {
+		proxy, err := parseProxy(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+
+		t.clearnet.Proxy = http.ProxyURL(proxy)
+	}
+
+	if cfg.OnionProxy != "" {
+		proxy, err := parseProxy(cfg.OnionProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid onion proxy: %w", err)
+		}
+
+		t.onion = &http.Transport{
+			Proxy:           http.ProxyURL(proxy),
+			MaxIdleConns:    cfg.ResolverMaxIdleConns,
+			IdleConnTimeout: cfg.ResolverIdleConnTimeout,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				// onion services are authenticated by their address, and many use self-signed certificates
+				InsecureSkipVerify: cfg.OnionInsecureSkipVerify,
+			},
+		}
+	}
+
+	return &t, nil
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			connections.Inc(req.URL.Host, strconv.FormatBool(info.Reused))
+		},
+	}))
+
+	if !isOnion(req.URL.Hostname()) {
+		return t.clearnet.RoundTrip(req)
+	}
+
+	if t.onion == nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("cannot reach %s: %w", req.URL.Host, ErrNoOnionProxy)
+	}
+
+	return t.onion.RoundTrip(req)
+}
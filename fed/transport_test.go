@@ -0,0 +1,166 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestProxy returns a HTTP proxy that refuses all CONNECT requests and records their target.
+func newTestProxy(t *testing.T) (*httptest.Server, func() []string) {
+	var lock sync.Mutex
+	var targets []string
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		targets = append(targets, r.Method+" "+r.Host)
+		lock.Unlock()
+
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(proxy.Close)
+
+	return proxy, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return targets
+	}
+}
+
+func TestTransport_NoOnionProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	transport, err := NewTransport(&cfg)
+	assert.NoError(err)
+
+	client := http.Client{Transport: transport}
+	_, err = client.Get("https://abcdef.onion/user/dan")
+	assert.ErrorIs(err, ErrNoOnionProxy)
+
+	// onion addresses are not sent to the DNS server, regardless of case
+	_, err = client.Get("https://abcdef.ONION./user/dan")
+	assert.ErrorIs(err, ErrNoOnionProxy)
+}
+
+func TestTransport_OnionProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	proxy, targets := newTestProxy(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.OnionProxy = proxy.URL
+
+	transport, err := NewTransport(&cfg)
+	assert.NoError(err)
+
+	client := http.Client{Transport: transport}
+	_, err = client.Get("https://abcdef.onion/user/dan")
+	assert.Error(err)
+	assert.NotErrorIs(err, ErrNoOnionProxy)
+	assert.Equal([]string{"CONNECT abcdef.onion:443"}, targets())
+}
+
+func TestTransport_Proxy(t *testing.T) {
+	assert := assert.New(t)
+
+	proxy, targets := newTestProxy(t)
+	onionProxy, onionTargets := newTestProxy(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.Proxy = proxy.URL
+	cfg.OnionProxy = onionProxy.URL
+
+	transport, err := NewTransport(&cfg)
+	assert.NoError(err)
+
+	client := http.Client{Transport: transport}
+
+	_, err = client.Get("https://0.0.0.0/user/dan")
+	assert.Error(err)
+
+	_, err = client.Get("https://abcdef.onion:8443/user/dan")
+	assert.Error(err)
+
+	assert.Equal([]string{"CONNECT 0.0.0.0:443"}, targets())
+	assert.Equal([]string{"CONNECT abcdef.onion:8443"}, onionTargets())
+}
+
+func TestTransport_InvalidProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.Proxy = "ftp://127.0.0.1:21"
+
+	_, err := NewTransport(&cfg)
+	assert.Error(err)
+
+	cfg.Proxy = ""
+	cfg.OnionProxy = "tor"
+
+	_, err = NewTransport(&cfg)
+	assert.Error(err)
+}
+
+func TestPublicTransport_PrivateAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	transport, err := NewPublicTransport(&cfg)
+	assert.NoError(err)
+
+	transport.(*publicTransport).Lookup = func(context.Context, string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("10.0.0.1")}}, nil
+	}
+
+	client := http.Client{Transport: transport}
+
+	_, err = client.Get("https://127.0.0.1/admin")
+	assert.ErrorIs(err, ErrPrivateAddress)
+
+	_, err = client.Get("https://[fe80::1]/admin")
+	assert.ErrorIs(err, ErrPrivateAddress)
+
+	_, err = client.Get("https://intranet.example.com/admin")
+	assert.ErrorIs(err, ErrPrivateAddress)
+}
+
+func TestPublicTransport_RefusePrivate(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	d := net.Dialer{Control: refusePrivate}
+	_, err := d.Dial("tcp", server.Listener.Addr().String())
+	assert.ErrorIs(err, ErrPrivateAddress)
+}
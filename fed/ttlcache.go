@@ -0,0 +1,87 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is a bounded in-memory cache of values that expire.
+// The zero value is an empty cache.
+type ttlCache[K comparable, V any] struct {
+	lock    sync.Mutex
+	entries map[K]ttlCacheEntry[V]
+}
+
+type ttlCacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func (c *ttlCache[K, V]) Get(key K, now time.Time) (V, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	if !now.Before(e.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+// Set adds or replaces a value, evicting expired values if the cache is full.
+func (c *ttlCache[K, V]) Set(key K, value V, now time.Time, ttl time.Duration, max int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[K]ttlCacheEntry[V]{}
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= max {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+
+		// if nothing has expired, evict an arbitrary value
+		if len(c.entries) >= max {
+			for k := range c.entries {
+				delete(c.entries, k)
+				break
+			}
+		}
+	}
+
+	c.entries[key] = ttlCacheEntry[V]{value: value, expires: now.Add(ttl)}
+}
+
+func (c *ttlCache[K, V]) Delete(key K) {
+	c.lock.Lock()
+	delete(c.entries, key)
+	c.lock.Unlock()
+}
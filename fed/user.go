@@ -18,11 +18,13 @@ package fed
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 )
 
 func (l *Listener) handleUser(w http.ResponseWriter, r *http.Request) {
@@ -31,7 +33,8 @@ func (l *Listener) handleUser(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Looking up user", "name", name)
 
 	var actorID, actorString string
-	if err := l.DB.QueryRowContext(r.Context(), `select id, actor from persons where actor->>'$.preferredUsername' = ? and host = ?`, name, l.Domain).Scan(&actorID, &actorString); err != nil && errors.Is(err, sql.ErrNoRows) {
+	var updated int64
+	if err := l.DB.QueryRowContext(r.Context(), `select id, actor, coalesce(unixepoch(coalesce(actor->>'$.updated', actor->>'$.published')), inserted) from persons where actor->>'$.preferredUsername' = ? and host = ?`, name, l.Domain).Scan(&actorID, &actorString, &updated); err != nil && errors.Is(err, sql.ErrNoRows) {
 		slog.Info("Notifying about deleted user", "name", name)
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -40,8 +43,14 @@ func (l *Listener) handleUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// redirect browsers to the outbox page over Gemini
-	if shouldRedirect(r) {
+	// redirect browsers to the outbox page over HTTPS or Gemini
+	if shouldRedirect(r) && l.Frontend != nil {
+		outbox := fmt.Sprintf("/web/outbox/%s", strings.TrimPrefix(actorID, "https://"))
+		slog.Info("Redirecting to outbox over HTTPS", "outbox", outbox)
+		w.Header().Set("Location", outbox)
+		w.WriteHeader(http.StatusFound)
+		return
+	} else if shouldRedirect(r) {
 		outbox := fmt.Sprintf("gemini://%s/outbox/%s", l.Domain, strings.TrimPrefix(actorID, "https://"))
 		slog.Info("Redirecting to outbox over Gemini", "outbox", outbox)
 		w.Header().Set("Location", outbox)
@@ -49,6 +58,43 @@ func (l *Listener) handleUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", `application/activity+json; charset=utf-8`)
-	w.Write([]byte(actorString))
+	// the public key of an actor must be available without a signature, to allow verification of signatures
+	if l.Config.AuthorizedFetch && name != "nobody" && r.Header.Get("Signature") == "" {
+		slog.Info("Sending public key of user", "name", name)
+		w.Header().Add("Vary", "Authorization, Signature")
+		l.writePublicKey(w, r, actorString, time.Unix(updated, 0))
+		return
+	}
+
+	if name != "nobody" && !l.authorizeFetch(w, r) {
+		return
+	}
+
+	writeConditional(w, r, `application/activity+json; charset=utf-8`, []byte(actorString), time.Unix(updated, 0))
+}
+
+// publicActorFields are the actor fields sent in response to an unsigned request, if authorized fetch is enabled.
+var publicActorFields = []string{"@context", "id", "type", "preferredUsername", "inbox", "outbox", "endpoints", "publicKey"}
+
+func (l *Listener) writePublicKey(w http.ResponseWriter, r *http.Request, actorString string, updated time.Time) {
+	var actor map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(actorString), &actor); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	stripped := make(map[string]json.RawMessage, len(publicActorFields))
+	for _, field := range publicActorFields {
+		if v, ok := actor[field]; ok {
+			stripped[field] = v
+		}
+	}
+
+	j, err := json.Marshal(stripped)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeConditional(w, r, `application/activity+json; charset=utf-8`, j, updated)
 }
@@ -17,40 +17,187 @@ limitations under the License.
 package fed
 
 import (
+	"context"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/httpsig"
 )
 
-func (l *Listener) verify(r *http.Request, body []byte, flags ap.ResolverFlag) (*ap.Actor, error) {
-	sig, err := httpsig.Extract(r, body, l.Domain, time.Now(), l.Config.MaxRequestAge)
+type verificationKey struct {
+	Actor ap.Actor
+	Key   any
+}
+
+// keyDocument is a key that doesn't belong to an actor object, and points to its owner.
+type keyDocument struct {
+	ID         string `json:"id"`
+	Owner      string `json:"owner"`
+	Controller string `json:"controller"`
+}
+
+// resolveKeyOwner fetches a key and the actor it belongs to, if keyId points to a standalone key.
+func (l *Listener) resolveKeyOwner(ctx context.Context, keyID string) (*ap.Actor, error) {
+	_, body, err := l.fetchObject(ctx, keyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify message: %w", err)
+		return nil, err
+	}
+
+	var key keyDocument
+	if err := json.Unmarshal(body, &key); err != nil {
+		return nil, err
+	}
+
+	if key.ID != keyID {
+		return nil, fmt.Errorf("%s does not match %s", key.ID, keyID)
+	}
+
+	owner := key.Controller
+	if owner == "" {
+		owner = key.Owner
+	}
+	if owner == "" || owner == keyID {
+		return nil, errors.New("key has no owner")
+	}
+
+	keyURL, err := url.Parse(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerURL, err := url.Parse(owner)
+	if err != nil {
+		return nil, err
 	}
 
-	actor, err := l.Resolver.ResolveID(r.Context(), l.ActorKey, sig.KeyID, flags)
+	if ownerURL.Host != keyURL.Host {
+		return nil, fmt.Errorf("invalid owner host: %s", ownerURL.Host)
+	}
+
+	actor, err := l.Resolver.ResolveID(ctx, l.ActorKey, owner, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// the owner must agree that this is its key
+	if actor.PublicKey.ID != keyID {
+		return nil, fmt.Errorf("%s does not own %s", actor.ID, keyID)
+	}
+
+	return actor, nil
+}
+
+func (l *Listener) resolveKey(ctx context.Context, keyID string, flags ap.ResolverFlag) (*ap.Actor, any, error) {
+	actor, err := l.Resolver.ResolveID(ctx, l.ActorKey, keyID, flags)
+	if err != nil && flags&ap.Offline == 0 && !errors.Is(err, ErrBlockedDomain) {
+		// failed key lookups share the resolver's cache of failed lookups, so a key that can't be resolved isn't
+		// fetched again on every request
+		if ownerErr, ok := l.Resolver.failures.Get(keyID, time.Now()); ok {
+			slog.Debug("Failed to resolve key owner", "key", keyID, "error", ownerErr)
+		} else if owner, ownerErr := l.resolveKeyOwner(ctx, keyID); ownerErr == nil {
+			actor = owner
+			err = nil
+		} else {
+			slog.Debug("Failed to resolve key owner", "key", keyID, "error", ownerErr)
+
+			if !errors.Is(ownerErr, context.Canceled) && !errors.Is(ownerErr, context.DeadlineExceeded) {
+				l.Resolver.failures.Set(keyID, ownerErr, time.Now(), l.Config.ResolverFailureCacheTTL, l.Config.MaxResolverFailureCacheSize)
+			}
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get key %s to verify message: %w", sig.KeyID, err)
+		return nil, nil, fmt.Errorf("failed to get key %s to verify message: %w", keyID, err)
 	}
 
 	publicKeyPem, _ := pem.Decode([]byte(actor.PublicKey.PublicKeyPem))
+	if publicKeyPem == nil {
+		return nil, nil, fmt.Errorf("failed to verify message using %s: invalid key", keyID)
+	}
 
 	publicKey, err := x509.ParsePKIXPublicKey(publicKeyPem.Bytes)
 	if err != nil {
 		publicKey, err = x509.ParsePKCS1PublicKey(publicKeyPem.Bytes)
 		if err != nil {
-			return nil, fmt.Errorf("failed to verify message using %s: %w", sig.KeyID, err)
+			return nil, nil, fmt.Errorf("failed to verify message using %s: %w", keyID, err)
+		}
+	}
+
+	return actor, publicKey, nil
+}
+
+// verify verifies a signed request.
+// Public keys and verified signatures are cached, so repeated requests signed with the same key don't fetch it again,
+// and identical signed requests aren't verified again.
+func (l *Listener) verify(r *http.Request, body []byte, flags ap.ResolverFlag) (*ap.Actor, error) {
+	now := time.Now()
+
+	sig, err := httpsig.Extract(r, body, l.Domain, now, l.Config.MaxRequestAge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify message: %w", err)
+	}
+
+	hash := sig.Hash()
+	if actor, ok := l.signatures.Get(hash, now); ok {
+		return &actor, nil
+	}
+
+	if cached, ok := l.keys.Get(sig.KeyID, now); ok {
+		if err := sig.Verify(cached.Key); err == nil {
+			l.signatures.Set(hash, cached.Actor, now, l.Config.VerificationCacheTTL, l.Config.MaxVerificationCacheSize)
+			return &cached.Actor, nil
 		}
+
+		// the key might have changed
+		l.keys.Delete(sig.KeyID)
+	}
+
+	actor, publicKey, err := l.resolveKey(r.Context(), sig.KeyID, flags)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := sig.Verify(publicKey); err != nil {
 		return nil, fmt.Errorf("failed to verify message using %s: %w", sig.KeyID, err)
 	}
 
+	l.keys.Set(sig.KeyID, verificationKey{Actor: *actor, Key: publicKey}, now, l.Config.KeyCacheTTL, l.Config.MaxKeyCacheSize)
+	l.signatures.Set(hash, *actor, now, l.Config.VerificationCacheTTL, l.Config.MaxVerificationCacheSize)
+
 	return actor, nil
 }
+
+// authorizeFetch verifies a signed GET request, if fetching of ActivityPub objects requires a signature.
+func (l *Listener) authorizeFetch(w http.ResponseWriter, r *http.Request) bool {
+	if !l.Config.AuthorizedFetch {
+		return true
+	}
+
+	// the response depends on the signature, so caches must not serve it to others
+	w.Header().Add("Vary", "Authorization, Signature")
+
+	var flags ap.ResolverFlag
+	if sig, err := httpsig.Extract(r, nil, l.Domain, time.Now(), l.Config.MaxRequestAge); err != nil {
+		slog.Warn("Failed to verify fetch request", "path", r.URL.Path, "client", r.RemoteAddr, "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	} else if u, err := url.Parse(sig.KeyID); err == nil && u.Path == "/actor" {
+		// Mastodon signs fetch requests using its "instance actor"
+		flags = ap.InstanceActor
+	}
+
+	if _, err := l.verify(r, nil, flags); err != nil {
+		slog.Warn("Failed to verify fetch request", "path", r.URL.Path, "client", r.RemoteAddr, "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,349 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fed
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSignedTestRequest(t *testing.T, key httpsig.Key, body string, now time.Time) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, "https://localhost.localdomain/inbox/alice", bytes.NewReader([]byte(body)))
+	assert.NoError(t, err)
+
+	req.Header.Set("Content-Type", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
+	assert.NoError(t, httpsig.Sign(req, key, now))
+
+	return req
+}
+
+func TestVerify_KeyController(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, nobodyKey, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	assert.NoError(err)
+
+	publicKeyPem, err := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	assert.NoError(err)
+
+	client := newTestClient(map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:1@0.0.0.0": {
+			Response: newTestResponse(http.StatusNotFound, ""),
+		},
+		"https://0.0.0.0/keys/1": {
+			Response: newTestResponse(
+				http.StatusOK,
+				fmt.Sprintf(`{"id":"https://0.0.0.0/keys/1","type":"CryptographicKey","controller":"https://0.0.0.0/user/dan","publicKeyPem":%s}`, publicKeyPem),
+			),
+		},
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+			Response: newTestResponse(
+				http.StatusOK,
+				`{"subject":"acct:dan@0.0.0.0","links":[{"href":"https://0.0.0.0/user/dan","rel":"self","type":"application/activity+json"}]}`,
+			),
+		},
+		"https://0.0.0.0/user/dan": {
+			Response: newTestResponse(
+				http.StatusOK,
+				fmt.Sprintf(`{"@context":["https://www.w3.org/ns/activitystreams","https://w3id.org/security/v1"],"id":"https://0.0.0.0/user/dan","type":"Person","inbox":"https://0.0.0.0/inbox/dan","preferredUsername":"dan","followers":"https://0.0.0.0/followers/dan","publicKey":{"id":"https://0.0.0.0/keys/1","owner":"https://0.0.0.0/user/dan","publicKeyPem":%s}}`, publicKeyPem),
+			),
+		},
+	})
+
+	l := Listener{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(&BlockList{}, "localhost.localdomain", &cfg, &client, db),
+		ActorKey: nobodyKey,
+	}
+
+	key := httpsig.Key{ID: "https://0.0.0.0/keys/1", PrivateKey: priv}
+	now := time.Now()
+
+	body := `{"id":"https://0.0.0.0/create/1"}`
+	actor, err := l.verify(newSignedTestRequest(t, key, body, now), []byte(body), 0)
+	assert.NoError(err)
+	assert.Equal("https://0.0.0.0/user/dan", actor.ID)
+	assert.Empty(client.Data)
+
+	// an identical request is not verified again
+	actor, err = l.verify(newSignedTestRequest(t, key, body, now), []byte(body), 0)
+	assert.NoError(err)
+	assert.Equal("https://0.0.0.0/user/dan", actor.ID)
+
+	// the key is cached
+	body = `{"id":"https://0.0.0.0/create/2"}`
+	actor, err = l.verify(newSignedTestRequest(t, key, body, now), []byte(body), 0)
+	assert.NoError(err)
+	assert.Equal("https://0.0.0.0/user/dan", actor.ID)
+
+	// a tampered body doesn't match the cached signature
+	req := newSignedTestRequest(t, key, body, now)
+	_, err = l.verify(req, []byte(`{"id":"https://0.0.0.0/create/3"}`), 0)
+	assert.Error(err)
+}
+
+func TestVerify_KeyNotClaimedByOwner(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MinActorAge = 0
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, nobodyKey, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	client := newTestClient(map[string]testResponse{
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:1@0.0.0.0": {
+			Response: newTestResponse(http.StatusNotFound, ""),
+		},
+		"https://0.0.0.0/keys/1": {
+			Response: newTestResponse(
+				http.StatusOK,
+				`{"id":"https://0.0.0.0/keys/1","type":"CryptographicKey","owner":"https://0.0.0.0/user/dan"}`,
+			),
+		},
+		"https://0.0.0.0/.well-known/webfinger?resource=acct:dan@0.0.0.0": {
+			Response: newTestResponse(
+				http.StatusOK,
+				`{"subject":"acct:dan@0.0.0.0","links":[{"href":"https://0.0.0.0/user/dan","rel":"self","type":"application/activity+json"}]}`,
+			),
+		},
+		"https://0.0.0.0/user/dan": {
+			Response: newTestResponse(
+				http.StatusOK,
+				`{"@context":["https://www.w3.org/ns/activitystreams","https://w3id.org/security/v1"],"id":"https://0.0.0.0/user/dan","type":"Person","inbox":"https://0.0.0.0/inbox/dan","preferredUsername":"dan","followers":"https://0.0.0.0/followers/dan","publicKey":{"id":"https://0.0.0.0/user/dan#main-key","owner":"https://0.0.0.0/user/dan","publicKeyPem":""}}`,
+			),
+		},
+	})
+
+	l := Listener{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(&BlockList{}, "localhost.localdomain", &cfg, &client, db),
+		ActorKey: nobodyKey,
+	}
+
+	body := `{"id":"https://0.0.0.0/create/1"}`
+	_, err = l.verify(newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/keys/1", PrivateKey: priv}, body, time.Now()), []byte(body), 0)
+	assert.ErrorIs(err, ErrActorGone)
+	assert.Empty(client.Data)
+
+	// the key is not fetched again
+	body = `{"id":"https://0.0.0.0/create/2"}`
+	_, err = l.verify(newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/keys/1", PrivateKey: priv}, body, time.Now()), []byte(body), 0)
+	assert.Error(err)
+}
+
+func TestVerify_Offline(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, nobodyKey, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	client := newTestClient(map[string]testResponse{})
+
+	l := Listener{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(&BlockList{}, "localhost.localdomain", &cfg, &client, db),
+		ActorKey: nobodyKey,
+	}
+
+	body := `{"id":"https://0.0.0.0/delete/1"}`
+	_, err = l.verify(newSignedTestRequest(t, httpsig.Key{ID: "https://0.0.0.0/keys/1", PrivateKey: priv}, body, time.Now()), []byte(body), ap.Offline)
+	assert.ErrorIs(err, ErrActorNotCached)
+}
+
+func TestVerify_AuthorizedFetch(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := os.CreateTemp("", "tootik-*.sqlite3")
+	assert.NoError(err)
+	f.Close()
+
+	path := f.Name()
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	assert.NoError(err)
+	defer db.Close()
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.AuthorizedFetch = true
+
+	assert.NoError(migrations.Run(context.Background(), "localhost.localdomain", db))
+
+	_, nobodyKey, err := user.CreateNobody(context.Background(), "localhost.localdomain", &cfg, db)
+	assert.NoError(err)
+
+	_, _, err = user.Create(context.Background(), "localhost.localdomain", &cfg, db, "alice", ap.Person, nil)
+	assert.NoError(err)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+
+	client := newTestClient(map[string]testResponse{})
+
+	l := Listener{
+		Domain:   "localhost.localdomain",
+		Config:   &cfg,
+		DB:       db,
+		Resolver: NewResolver(&BlockList{}, "localhost.localdomain", &cfg, &client, db),
+		ActorKey: nobodyKey,
+	}
+
+	key := httpsig.Key{ID: "https://0.0.0.0/user/dan#main-key", PrivateKey: priv}
+	l.keys.Set(key.ID, verificationKey{Actor: ap.Actor{ID: "https://0.0.0.0/user/dan"}, Key: &priv.PublicKey}, time.Now(), cfg.KeyCacheTTL, cfg.MaxKeyCacheSize)
+
+	get := func(url, username string, signed bool) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		assert.NoError(err)
+
+		req.SetPathValue("username", username)
+		req.Header.Set("Accept", "application/activity+json")
+
+		if signed {
+			assert.NoError(httpsig.Sign(req, key, time.Now()))
+		}
+
+		w := httptest.NewRecorder()
+		if strings.Contains(url, "/outbox/") {
+			l.handleOutbox(w, req)
+		} else {
+			l.handleUser(w, req)
+		}
+
+		return w
+	}
+
+	// the public key is available without a signature, but other fields aren't
+	resp := get("https://localhost.localdomain/user/alice", "alice", false)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("Authorization, Signature", resp.Header().Get("Vary"))
+
+	var actor map[string]any
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &actor))
+	assert.Equal("https://localhost.localdomain/user/alice", actor["id"])
+	assert.Contains(actor, "publicKey")
+	assert.NotContains(actor, "followers")
+
+	resp = get("https://localhost.localdomain/user/alice", "alice", true)
+	assert.Equal(http.StatusOK, resp.Code)
+
+	actor = nil
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &actor))
+	assert.Contains(actor, "followers")
+
+	// the instance actor is always available
+	resp = get("https://localhost.localdomain/user/nobody", "nobody", false)
+	assert.Equal(http.StatusOK, resp.Code)
+
+	actor = nil
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &actor))
+	assert.Contains(actor, "followers")
+
+	resp = get("https://localhost.localdomain/outbox/alice", "alice", false)
+	assert.Equal(http.StatusUnauthorized, resp.Code)
+	assert.Equal("Authorization, Signature", resp.Header().Get("Vary"))
+	assert.Equal(http.StatusOK, get("https://localhost.localdomain/outbox/alice", "alice", true).Code)
+
+	cfg.AuthorizedFetch = false
+	assert.Equal(http.StatusOK, get("https://localhost.localdomain/outbox/alice", "alice", false).Code)
+}
@@ -19,12 +19,14 @@ package fed
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/url"
-	"path/filepath"
 	"strings"
+
+	"github.com/dimkr/tootik/front/user"
 )
 
 func (l *Listener) handleWebFinger(w http.ResponseWriter, r *http.Request) {
@@ -46,9 +48,13 @@ func (l *Listener) handleWebFinger(w http.ResponseWriter, r *http.Request) {
 
 	var username string
 
-	prefix := fmt.Sprintf("https://%s/", l.Domain)
-	if strings.HasPrefix(resource, prefix) {
-		username = filepath.Base(resource)
+	if strings.HasPrefix(resource, "https://") {
+		var ok bool
+		if username, ok = user.ParseID(l.Domain, l.Config, resource); !ok {
+			slog.Info("Received invalid resource", "resource", resource)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
 	} else {
 		var fields = strings.Split(resource, "@")
 
@@ -76,16 +82,20 @@ func (l *Listener) handleWebFinger(w http.ResponseWriter, r *http.Request) {
 
 	slog.Info("Looking up resource", "resource", resource, "user", username)
 
+	// local user names are unique regardless of case, but prefer an exact match if there are multiple users
 	var actorID sql.NullString
-	if err := l.DB.QueryRowContext(r.Context(), `select id from persons where actor->>'$.preferredUsername' = ? and host = ?`, username, l.Domain).Scan(&actorID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if !actorID.Valid {
+	if err := l.DB.QueryRowContext(
+		r.Context(),
+		`select id, actor->>'$.preferredUsername' from persons where host = $1 and actor->>'$.preferredUsername' = $2 collate nocase order by actor->>'$.preferredUsername' = $2 desc, inserted limit 1`,
+		l.Domain,
+		username,
+	).Scan(&actorID, &username); errors.Is(err, sql.ErrNoRows) {
 		slog.Info("Notifying that user does not exist", "user", username)
 		w.WriteHeader(http.StatusNotFound)
 		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
 	j, err := json.Marshal(map[string]any{
@@ -17,29 +17,107 @@ limitations under the License.
 package front
 
 import (
+	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/front/text"
 	"github.com/dimkr/tootik/outbox"
 )
 
-func (h *Handler) alias(w text.Writer, r *Request, args ...string) {
+func (h *Handler) aliases(w text.Writer, r *Request, args ...string) {
 	if r.User == nil {
 		w.Redirect("/users")
 		return
 	}
 
-	now := time.Now()
+	w.OK()
+	w.Title("🔗 Account Aliases")
+
+	aliases := r.User.AlsoKnownAs.CollectKeys()
+	for i, alias := range aliases {
+		w.Link("/users/outbox/"+strings.TrimPrefix(alias, "https://"), alias)
+		w.Linkf(fmt.Sprintf("/users/aliases/remove/%d", i), "➖ Remove %s", alias)
+	}
+
+	if len(aliases) == 0 {
+		w.Text("No aliases.")
+	}
+
+	if len(aliases) < h.Config.MaxAliases {
+		w.Empty()
+		w.Link("/users/alias", "➕ Add alias")
+	}
+
+	w.Subtitle("📦 Move Account")
+
+	if r.User.MovedTo != "" {
+		w.Textf("This account was moved to %s and can no longer publish, edit or share posts, follow users or respond to events.", r.User.MovedTo)
+		return
+	}
+
+	w.Text("To move this account to another account, add the other account as an alias of this account and add this account as an alias of the other account. Then, move this account: followers will follow the other account instead.")
+	w.Empty()
+	w.Link("/users/move", "📦 Move account")
+}
 
-	can := r.User.Published.Time.Add(h.Config.MinActorEditInterval)
-	if r.User.Updated != nil {
-		can = r.User.Updated.Time.Add(h.Config.MinActorEditInterval)
+func (h *Handler) canEditAliases(w text.Writer, r *Request) bool {
+	if r.User == nil {
+		w.Redirect("/users")
+		return false
 	}
-	if now.Before(can) {
+
+	can := h.canEditProfile(r.User)
+	if time.Now().Before(can) {
 		r.Log.Warn("Throttled request to set alias", "can", can)
 		w.Statusf(40, "Please wait for %s", time.Until(can).Truncate(time.Second).String())
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) setAliases(w text.Writer, r *Request, aliases *ap.Audience) bool {
+	tx, err := h.DB.BeginTx(r.Context, nil)
+	if err != nil {
+		r.Log.Warn("Failed to update aliases", "error", err)
+		w.Error()
+		return false
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		r.Context,
+		"update persons set actor = json_set(actor, '$.alsoKnownAs', json($1), '$.updated', $2) where id = $3",
+		aliases,
+		time.Now().Format(time.RFC3339Nano),
+		r.User.ID,
+	); err != nil {
+		r.Log.Error("Failed to update aliases", "error", err)
+		w.Error()
+		return false
+	}
+
+	if err := outbox.UpdateActor(r.Context, h.Domain, tx, r.User.ID); err != nil {
+		r.Log.Error("Failed to update aliases", "error", err)
+		w.Error()
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Log.Error("Failed to update aliases", "error", err)
+		w.Error()
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) alias(w text.Writer, r *Request, args ...string) {
+	if !h.canEditAliases(w, r) {
 		return
 	}
 
@@ -69,37 +147,45 @@ func (h *Handler) alias(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
-	tx, err := h.DB.BeginTx(r.Context, nil)
-	if err != nil {
-		r.Log.Warn("Failed to update alias", "error", err)
-		w.Error()
-		return
+	if !r.User.AlsoKnownAs.Contains(actor.ID) {
+		if len(r.User.AlsoKnownAs.OrderedMap) >= h.Config.MaxAliases {
+			w.Status(40, "Reached aliases limit")
+			return
+		}
+
+		aliases := ap.Audience{}
+		for id := range r.User.AlsoKnownAs.Keys() {
+			aliases.Add(id)
+		}
+		aliases.Add(actor.ID)
+
+		if !h.setAliases(w, r, &aliases) {
+			return
+		}
 	}
-	defer tx.Rollback()
 
-	if _, err := tx.ExecContext(
-		r.Context,
-		"update persons set actor = json_set(actor, '$.alsoKnownAs', json_array($1), '$.updated', $2) where id = $3",
-		actor.ID,
-		now.Format(time.RFC3339Nano),
-		r.User.ID,
-	); err != nil {
-		r.Log.Error("Failed to update alias", "error", err)
-		w.Error()
+	w.Redirect("/users/outbox/" + strings.TrimPrefix(actor.ID, "https://"))
+}
+
+func (h *Handler) removeAlias(w text.Writer, r *Request, args ...string) {
+	if !h.canEditAliases(w, r) {
 		return
 	}
 
-	if err := outbox.UpdateActor(r.Context, h.Domain, tx, r.User.ID); err != nil {
-		r.Log.Error("Failed to update alias", "error", err)
-		w.Error()
+	i, err := strconv.Atoi(args[1])
+	if err != nil || i >= len(r.User.AlsoKnownAs.OrderedMap) {
+		w.Status(40, "No such alias")
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		r.Log.Error("Failed to update alias", "error", err)
-		w.Error()
-		return
+	aliases := ap.Audience{}
+	for j, id := range r.User.AlsoKnownAs.CollectKeys() {
+		if j != i {
+			aliases.Add(id)
+		}
 	}
 
-	w.Redirect("/users/outbox/" + strings.TrimPrefix(actor.ID, "https://"))
+	if h.setAliases(w, r, &aliases) {
+		w.Redirect("/users/aliases")
+	}
 }
@@ -0,0 +1,186 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/front/text/plain"
+)
+
+// isArticle determines whether or not a post is long-form content with a title.
+func isArticle(note *ap.Object) bool {
+	return (note.Type == ap.Article || note.Type == ap.Page) && !note.Sensitive
+}
+
+// articlePages splits the lines of an article into pages, without splitting lines or preformatted text.
+func articlePages(lines []string, maxRunes int) [][]string {
+	var pages [][]string
+	var page []string
+	runes := 0
+	pre := false
+
+	for _, line := range lines {
+		if !pre && runes > 0 && runes+utf8.RuneCountInString(line) > maxRunes {
+			pages = append(pages, page)
+			page = nil
+			runes = 0
+		}
+
+		if strings.HasPrefix(line, "```") {
+			pre = !pre
+		}
+
+		// don't start a page with empty lines
+		if len(page) == 0 && line == "" {
+			continue
+		}
+
+		page = append(page, line)
+		runes += utf8.RuneCountInString(line)
+	}
+
+	if len(page) > 0 || len(pages) == 0 {
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+// printArticleLines prints lines returned by [plain.FromHTML] as gemtext.
+func printArticleLines(w text.Writer, lines []string) {
+	var pre []string
+	inPre := false
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "```") && inPre {
+			w.Raw("Preformatted text", strings.Join(pre, "\n"))
+			pre = pre[:0]
+			inPre = false
+		} else if strings.HasPrefix(line, "```") {
+			inPre = true
+		} else if inPre {
+			pre = append(pre, line)
+		} else if line == "" {
+			w.Empty()
+		} else if strings.HasPrefix(line, "* ") {
+			w.Item(line[2:])
+		} else if line == ">" {
+			w.Quote("")
+		} else if strings.HasPrefix(line, "> ") {
+			w.Quote(line[2:])
+		} else {
+			w.Text(line)
+		}
+	}
+
+	if inPre {
+		w.Raw("Preformatted text", strings.Join(pre, "\n"))
+	}
+}
+
+// printArticle prints the title and the first page of an article.
+func (h *Handler) printArticle(w text.Writer, r *Request, note *ap.Object, lines []string) {
+	if note.Name != "" {
+		title, _ := plain.FromHTML(note.Name)
+		w.Subtitle(title)
+	}
+
+	pages := articlePages(lines, h.Config.ArticlePageMaxRunes)
+	printArticleLines(w, pages[0])
+
+	if len(pages) > 1 {
+		w.Empty()
+		if r.User == nil {
+			w.Linkf(fmt.Sprintf("/read/%s?2", strings.TrimPrefix(note.ID, "https://")), "📖 Next page (2/%d)", len(pages))
+		} else {
+			w.Linkf(fmt.Sprintf("/users/read/%s?2", strings.TrimPrefix(note.ID, "https://")), "📖 Next page (2/%d)", len(pages))
+		}
+	}
+}
+
+func (h *Handler) read(w text.Writer, r *Request, args ...string) {
+	postID := "https://" + args[1]
+
+	page, err := getOffset(r.URL)
+	if err != nil {
+		r.Log.Info("Failed to parse query", "error", err)
+		w.Status(40, "Invalid query")
+		return
+	}
+
+	note, author, _, _, err := h.getPost(r, postID)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		r.Log.Info("Post was not found", "post", postID)
+		w.Status(40, "Post not found")
+		return
+	} else if err != nil {
+		r.Log.Info("Failed to find post", "post", postID, "error", err)
+		w.Error()
+		return
+	}
+
+	if !isArticle(&note) {
+		w.Status(40, "Post is not an article")
+		return
+	}
+
+	raw, _ := plain.FromHTML(note.Content)
+	pages := articlePages(strings.Split(raw, "\n"), h.Config.ArticlePageMaxRunes)
+
+	if page == 0 {
+		page = 1
+	} else if page < 0 || page > len(pages) {
+		w.Status(40, "Invalid page")
+		return
+	}
+
+	prefix := "/users"
+	if r.User == nil {
+		prefix = ""
+	}
+
+	title := "Untitled"
+	if note.Name != "" {
+		title, _ = plain.FromHTML(note.Name)
+	}
+
+	w.OK()
+	w.Titlef("📖 %s (%d/%d)", title, page, len(pages))
+	w.Link(fmt.Sprintf("%s/outbox/%s", prefix, strings.TrimPrefix(author.ID, "https://")), author.PreferredUsername)
+	w.Empty()
+
+	printArticleLines(w, pages[page-1])
+
+	w.Empty()
+
+	if page > 1 {
+		w.Linkf(fmt.Sprintf("%s/read/%s?%d", prefix, args[1], page-1), "Previous page (%d/%d)", page-1, len(pages))
+	}
+
+	if page < len(pages) {
+		w.Linkf(fmt.Sprintf("%s/read/%s?%d", prefix, args[1], page+1), "Next page (%d/%d)", page+1, len(pages))
+	}
+
+	w.Link(fmt.Sprintf("%s/view/%s", prefix, args[1]), "💬 Back to post")
+}
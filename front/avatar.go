@@ -32,17 +32,20 @@ var supportedImageTypes = map[string]struct{}{
 	"image/png":  {},
 	"image/jpeg": {},
 	"image/gif":  {},
+	"image/webp": {},
 }
 
-func (h *Handler) uploadAvatar(w text.Writer, r *Request, args ...string) {
+// readImage reads an uploaded profile image, after checking its size and type, and whether or not the user is allowed
+// to edit the profile.
+func (h *Handler) readImage(w text.Writer, r *Request, args []string, maxSize int64) ([]byte, time.Time, bool) {
 	if r.User == nil {
 		w.Redirect("/users")
-		return
+		return nil, time.Time{}, false
 	}
 
 	if r.Body == nil {
 		w.Redirect("/users/oops")
-		return
+		return nil, time.Time{}, false
 	}
 
 	var sizeStr, mimeType string
@@ -55,51 +58,57 @@ func (h *Handler) uploadAvatar(w text.Writer, r *Request, args ...string) {
 	} else {
 		r.Log.Warn("Invalid parameters")
 		w.Error()
-		return
+		return nil, time.Time{}, false
 	}
 
 	size, err := strconv.ParseInt(sizeStr, 10, 64)
 	if err != nil {
-		r.Log.Warn("Failed to parse avatar size", "error", err)
+		r.Log.Warn("Failed to parse image size", "error", err)
 		w.Status(40, "Invalid size")
-		return
+		return nil, time.Time{}, false
 	}
 
-	if size > h.Config.MaxAvatarSize {
+	if size > maxSize {
 		r.Log.Warn("Image is too big", "size", size)
 		w.Status(40, "Image is too big")
-		return
+		return nil, time.Time{}, false
 	}
 
 	if _, ok := supportedImageTypes[mimeType]; !ok {
 		r.Log.Warn("Image type is unsupported", "type", mimeType)
 		w.Status(40, "Unsupported image type")
-		return
+		return nil, time.Time{}, false
 	}
 
 	now := time.Now()
 
-	can := r.User.Published.Time.Add(h.Config.MinActorEditInterval)
-	if r.User.Updated != nil {
-		can = r.User.Updated.Time.Add(h.Config.MinActorEditInterval)
-	}
+	can := h.canEditProfile(r.User)
 	if now.Before(can) {
-		r.Log.Warn("Throttled request to set avatar", "can", can)
+		r.Log.Warn("Throttled request to set image", "can", can)
 		w.Statusf(40, "Please wait for %s", time.Until(can).Truncate(time.Second).String())
-		return
+		return nil, time.Time{}, false
 	}
 
 	buf := make([]byte, size)
 	n, err := io.ReadFull(r.Body, buf)
 	if err != nil {
-		r.Log.Warn("Failed to read avatar", "error", err)
+		r.Log.Warn("Failed to read image", "error", err)
 		w.Error()
-		return
+		return nil, time.Time{}, false
 	}
 
 	if int64(n) != size {
-		r.Log.Warn("Avatar is truncated")
+		r.Log.Warn("Image is truncated")
 		w.Error()
+		return nil, time.Time{}, false
+	}
+
+	return buf, now, true
+}
+
+func (h *Handler) uploadAvatar(w text.Writer, r *Request, args ...string) {
+	buf, now, ok := h.readImage(w, r, args, h.Config.MaxAvatarSize)
+	if !ok {
 		return
 	}
 
@@ -120,7 +129,7 @@ func (h *Handler) uploadAvatar(w text.Writer, r *Request, args ...string) {
 
 	if _, err := tx.ExecContext(
 		r.Context,
-		"update persons set actor = json_set(actor, '$.icon.url', $1, '$.icon[0].url', $1, '$.updated', $2) where id = $3",
+		"update persons set actor = json_set(actor, '$.icon.url', $1, '$.icon[0].url', $1, '$.updated', $2), updated = unixepoch() where id = $3",
 		// we add fragment because some servers cache the image until the URL changes
 		fmt.Sprintf("https://%s/icon/%s%s#%d", h.Domain, r.User.PreferredUsername, icon.FileNameExtension, now.UnixNano()),
 		now.Format(time.RFC3339Nano),
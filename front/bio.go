@@ -34,10 +34,7 @@ func (h *Handler) doBio(w text.Writer, r *Request, readInput func(text.Writer, *
 
 	now := time.Now()
 
-	can := r.User.Published.Time.Add(h.Config.MinActorEditInterval)
-	if r.User.Updated != nil {
-		can = r.User.Updated.Time.Add(h.Config.MinActorEditInterval)
-	}
+	can := h.canEditProfile(r.User)
 	if now.Before(can) {
 		r.Log.Warn("Throttled request to set summary", "can", can)
 		w.Statusf(40, "Please wait for %s", time.Until(can).Truncate(time.Second).String())
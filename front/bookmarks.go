@@ -56,10 +56,12 @@ func (h *Handler) bookmarks(w text.Writer, r *Request, args ...string) {
 				limit $2
 				offset $3`,
 				r.User.ID,
-				h.Config.PostsPerPage,
+				h.postsPerPage(r),
 				offset,
 			)
 		},
 		false,
+		false,
+		"",
 	)
 }
@@ -20,9 +20,11 @@ import (
 	"bytes"
 	"context"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/front/text"
 )
 
@@ -33,6 +35,64 @@ type chanWriter struct {
 type cacheEntry struct {
 	Value   []byte
 	Created time.Time
+	Path    string
+}
+
+// pageCache is a bounded cache of responses, keyed by URL and user.
+type pageCache struct {
+	lock    sync.Mutex
+	entries map[string]cacheEntry
+	max     int
+}
+
+func newPageCache(max int) *pageCache {
+	return &pageCache{
+		entries: map[string]cacheEntry{},
+		max:     max,
+	}
+}
+
+func (c *pageCache) Load(key string) (cacheEntry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+// Store adds or replaces a response, evicting the oldest response if the cache is full.
+func (c *pageCache) Store(key string, entry cacheEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.max {
+		var oldest string
+		var oldestCreated time.Time
+		for k, e := range c.entries {
+			if oldest == "" || e.Created.Before(oldestCreated) {
+				oldest = k
+				oldestCreated = e.Created
+			}
+		}
+		delete(c.entries, oldest)
+	}
+
+	c.entries[key] = entry
+}
+
+// Invalidate removes cached responses to requests for any of the given paths.
+func (c *pageCache) Invalidate(paths ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for k, e := range c.entries {
+		for _, path := range paths {
+			if strings.EqualFold(e.Path, path) {
+				delete(c.entries, k)
+				break
+			}
+		}
+	}
 }
 
 func (w chanWriter) Write(p []byte) (int, error) {
@@ -40,7 +100,7 @@ func (w chanWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
-func callAndCache(r *Request, w text.Writer, args []string, f func(text.Writer, *Request, ...string), key string, now time.Time, cache *sync.Map) {
+func callAndCache(r *Request, w text.Writer, args []string, f func(text.Writer, *Request, ...string), key string, now time.Time, cache *pageCache) {
 	c := make(chan []byte)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -91,12 +151,20 @@ func callAndCache(r *Request, w text.Writer, args []string, f func(text.Writer,
 	w2.Textf("(Cached response generated on %s)", now.Format(time.UnixDate))
 	w2.Flush()
 
-	cache.Store(key, cacheEntry{buf.Bytes(), now})
+	cache.Store(key, cacheEntry{buf.Bytes(), now, r.URL.Path})
 }
 
-func withCache(f func(text.Writer, *Request, ...string), d time.Duration, cache *sync.Map) func(text.Writer, *Request, ...string) {
+func withCache(f func(text.Writer, *Request, ...string), d time.Duration, cache *pageCache) func(text.Writer, *Request, ...string) {
 	return func(w text.Writer, r *Request, args ...string) {
+		// the response might contain links that depend on the user
 		key := r.URL.String()
+		if r.User != nil {
+			key += " " + r.User.ID
+		}
+		if r.preferences.NoEmoji {
+			key += " ascii"
+		}
+
 		now := time.Now()
 
 		entry, cached := cache.Load(key)
@@ -106,9 +174,9 @@ func withCache(f func(text.Writer, *Request, ...string), d time.Duration, cache
 			return
 		}
 
-		if entry.(cacheEntry).Created.After(now.Add(-d)) {
+		if entry.Created.After(now.Add(-d)) {
 			r.Log.Info("Sending cached response", "key", key)
-			w.Write(entry.(cacheEntry).Value)
+			w.Write(entry.Value)
 			return
 		}
 
@@ -116,3 +184,28 @@ func withCache(f func(text.Writer, *Request, ...string), d time.Duration, cache
 		callAndCache(r, w, args, f, key, now, cache)
 	}
 }
+
+// InvalidatePost removes cached pages that might show a post.
+func (h *Handler) InvalidatePost(note *ap.Object) {
+	paths := []string{
+		"/local",
+		"/users/local",
+		"/hashtags",
+		"/users/hashtags",
+		"/outbox/" + strings.TrimPrefix(note.AttributedTo, "https://"),
+	}
+
+	for _, tag := range note.Tag {
+		if tag.Type == ap.Hashtag && tag.Name != "" {
+			hashtag := "/hashtag/" + strings.TrimPrefix(tag.Name, "#")
+			paths = append(paths, hashtag, hashtag+"/recent", hashtag+"/shared")
+		}
+	}
+
+	h.cache.Invalidate(paths...)
+}
+
+// InvalidateActor removes cached pages that show an actor's profile and posts.
+func (h *Handler) InvalidateActor(id string) {
+	h.cache.Invalidate("/outbox/" + strings.TrimPrefix(id, "https://"))
+}
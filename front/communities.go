@@ -17,27 +17,34 @@ limitations under the License.
 package front
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/outbox"
 )
 
 func (h *Handler) communities(w text.Writer, r *Request, args ...string) {
 	rows, err := h.DB.QueryContext(
 		r.Context,
 		`
-			select persons.id, persons.actor->>'preferredUsername', max(notes.inserted) from notes
-			join persons
+			select persons.id, persons.actor->>'preferredUsername', max(notes.inserted), (select count(*) from follows where follows.followed = persons.id and follows.accepted = 1) from persons
+			left join notes
 			on
-				persons.id = notes.object->>'$.audience'
+				notes.object->>'$.audience' = persons.id
 			where
 				persons.host = $1 and
 				persons.actor->>'$.type' = 'Group'
 			group by
 				persons.id
 			order by
-				max(notes.inserted) desc
+				max(notes.inserted) desc nulls last,
+				persons.inserted desc
 		`,
 		h.Domain,
 	)
@@ -55,16 +62,27 @@ func (h *Handler) communities(w text.Writer, r *Request, args ...string) {
 
 	for rows.Next() {
 		var id, username string
-		var last int64
-		if err := rows.Scan(&id, &username, &last); err != nil {
+		var last sql.NullInt64
+		var members int64
+		if err := rows.Scan(&id, &username, &last, &members); err != nil {
 			r.Log.Warn("Failed to scan community", "error", err)
 			continue
 		}
 
-		if r.User == nil {
-			w.Linkf("/outbox/"+strings.TrimPrefix(id, "https://"), "%s %s", time.Unix(last, 0).Format(time.DateOnly), username)
+		link := "/outbox/" + strings.TrimPrefix(id, "https://")
+		if r.User != nil {
+			link = "/users" + link
+		}
+
+		label := fmt.Sprintf("%s (%d members)", username, members)
+		if members == 1 {
+			label = username + " (one member)"
+		}
+
+		if last.Valid {
+			w.Linkf(link, "%s %s", time.Unix(last.Int64, 0).Format(time.DateOnly), label)
 		} else {
-			w.Linkf("/users/outbox/"+strings.TrimPrefix(id, "https://"), "%s %s", time.Unix(last, 0).Format(time.DateOnly), username)
+			w.Link(link, label)
 		}
 
 		empty = false
@@ -75,4 +93,115 @@ func (h *Handler) communities(w text.Writer, r *Request, args ...string) {
 	if empty {
 		w.Text("No communities.")
 	}
+
+	if r.User != nil && h.Config.EnableCommunityCreation {
+		w.Separator()
+		w.Link("/users/communities/create", "🏗️ Create a community")
+	}
+}
+
+func (h *Handler) createCommunity(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if !h.Config.EnableCommunityCreation {
+		w.Status(40, "Community creation is disabled")
+		return
+	}
+
+	name, ok := readQuery(w, r, "Community name")
+	if !ok {
+		return
+	}
+
+	if exists, err := user.CheckName(r.Context, h.Domain, h.Config, h.DB, name); exists || errors.Is(err, user.ErrNameTaken) {
+		w.Status(40, "Name is already taken")
+		return
+	} else if errors.Is(err, user.ErrReservedName) {
+		w.Status(40, "Name is reserved")
+		return
+	} else if errors.Is(err, user.ErrConfusableName) {
+		w.Status(40, "Name contains confusable characters")
+		return
+	} else if errors.Is(err, user.ErrInvalidName) {
+		w.Status(40, "Invalid community name")
+		return
+	} else if err != nil {
+		r.Log.Warn("Failed to check community name", "name", name, "error", err)
+		w.Error()
+		return
+	}
+
+	var exists int
+	if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from certificates where user = ?)`, name).Scan(&exists); err != nil {
+		r.Log.Warn("Failed to check if name is taken", "name", name, "error", err)
+		w.Error()
+		return
+	} else if exists == 1 {
+		w.Status(40, "Name is already taken")
+		return
+	}
+
+	r.Log.Info("Creating new community", "name", name)
+
+	followID, err := outbox.NewID(h.Domain, "follow")
+	if err != nil {
+		r.Log.Warn("Failed to generate follow ID", "error", err)
+		w.Error()
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context, nil)
+	if err != nil {
+		r.Log.Warn("Failed to create new community", "name", name, "error", err)
+		w.Error()
+		return
+	}
+	defer tx.Rollback()
+
+	// the limit is checked inside the transaction, so concurrent requests can't exceed it
+	var owned int
+	if err := tx.QueryRowContext(r.Context, `select count(*) from communities where owner = ?`, r.User.ID).Scan(&owned); err != nil {
+		r.Log.Warn("Failed to count owned communities", "error", err)
+		w.Error()
+		return
+	}
+
+	if owned >= h.Config.MaxCommunitiesPerUser {
+		w.Status(40, "Reached communities limit")
+		return
+	}
+
+	group, _, err := user.CreateTx(r.Context, h.Domain, h.Config, tx, name, ap.Group)
+	if errors.Is(err, user.ErrNameTaken) {
+		w.Status(40, "Name is already taken")
+		return
+	} else if err != nil {
+		r.Log.Warn("Failed to create new community", "name", name, "error", err)
+		w.Status(40, "Failed to create new community")
+		return
+	}
+
+	if _, err := tx.ExecContext(r.Context, `insert into communities(id, owner) values(?, ?)`, group.ID, r.User.ID); err != nil {
+		r.Log.Warn("Failed to set community owner", "name", name, "error", err)
+		w.Error()
+		return
+	}
+
+	// local follows don't need to be accepted
+	if _, err := tx.ExecContext(r.Context, `insert into follows(id, follower, followed, accepted) values(?, ?, ?, 1)`, followID, r.User.ID, group.ID); err != nil {
+		r.Log.Warn("Failed to follow new community", "name", name, "error", err)
+		w.Error()
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Log.Warn("Failed to create new community", "name", name, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/outbox/" + strings.TrimPrefix(group.ID, "https://"))
 }
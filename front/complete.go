@@ -0,0 +1,92 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"strings"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// complete lists known users with a handle that starts with a prefix, for mention completion.
+func (h *Handler) complete(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	prefix, ok := readQuery(w, r, "Prefix (name or name@domain)")
+	if !ok {
+		return
+	}
+
+	name, host, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(prefix), "@"), "@")
+	if name == "" {
+		w.Status(40, "Bad input")
+		return
+	}
+
+	// exact matches first, then followed users, local users and everyone else
+	rows, err := h.DB.QueryContext(
+		r.Context,
+		`select id, actor->>'$.preferredUsername', host from persons
+		where
+			actor->>'$.preferredUsername' like $1 escape '\' and
+			host like $2 escape '\' and
+			actor->>'$.type' != 'Application'
+		order by
+			lower(actor->>'$.preferredUsername') = lower($3) desc,
+			exists (select 1 from follows where follower = $4 and followed = persons.id and accepted = 1) desc,
+			host = $5 desc,
+			actor->>'$.preferredUsername',
+			host
+		limit $6`,
+		likeEscaper.Replace(name)+"%",
+		likeEscaper.Replace(host)+"%",
+		name,
+		r.User.ID,
+		h.Domain,
+		h.Config.MaxCompletions,
+	)
+	if err != nil {
+		r.Log.Warn("Failed to complete user name", "prefix", prefix, "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+	w.Titlef("🔭 Users Matching '%s'", prefix)
+
+	found := false
+	for rows.Next() {
+		var id, preferredUsername, actorHost string
+		if err := rows.Scan(&id, &preferredUsername, &actorHost); err != nil {
+			r.Log.Warn("Failed to scan user", "error", err)
+			continue
+		}
+
+		w.Linkf("/users/outbox/"+strings.TrimPrefix(id, "https://"), "@%s@%s", preferredUsername, actorHost)
+		found = true
+	}
+
+	if !found {
+		w.Text("No matches.")
+	}
+}
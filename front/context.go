@@ -0,0 +1,160 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+type contextNode struct {
+	Depth          int
+	PostID         string
+	AuthorUserName sql.NullString
+	Inserted       int64
+}
+
+func (h *Handler) printContextNode(w text.Writer, r *Request, node *contextNode, center bool) {
+	inserted := formatTime(r, time.Unix(node.Inserted, 0).UTC())
+
+	// the user name is passed as an argument, so a [text.Writer] can tell it apart from symbols
+	var b strings.Builder
+	b.WriteString("%s ")
+	if node.Depth > 0 {
+		b.WriteString(strings.Repeat("·", node.Depth))
+		b.WriteByte(' ')
+	}
+	if !node.AuthorUserName.Valid {
+		b.WriteString("[deleted]")
+		w.Textf(b.String(), inserted)
+		return
+	}
+	b.WriteString("%s")
+	if center {
+		b.WriteString(" ┃ 📍")
+	}
+
+	if r.User == nil {
+		w.Linkf("/view/"+strings.TrimPrefix(node.PostID, "https://"), b.String(), inserted, node.AuthorUserName.String)
+	} else {
+		w.Linkf("/users/view/"+strings.TrimPrefix(node.PostID, "https://"), b.String(), inserted, node.AuthorUserName.String)
+	}
+}
+
+// context shows a post with a few of its ancestors and descendants.
+func (h *Handler) context(w text.Writer, r *Request, args ...string) {
+	postID := "https://" + args[1]
+
+	r.Log.Info("Viewing context", "post", postID)
+
+	rows, err := h.DB.QueryContext(
+		r.Context,
+		`with recursive ancestors(id, author, inserted, parent, distance) as (
+			select notes.id, notes.author, notes.inserted, notes.object->>'$.inReplyTo', 0 from notes where notes.id = $1
+			union all
+			select notes.id, notes.author, notes.inserted, notes.object->>'$.inReplyTo', a.distance + 1 from ancestors a join notes on notes.id = a.parent where a.distance < $2
+			union all
+			select tombstones.id, null, tombstones.inserted, tombstones.parent, a.distance + 1 from ancestors a join tombstones on tombstones.id = a.parent where a.distance < $2 and not exists (select 1 from notes where notes.id = a.parent)
+		)
+		select ancestors.id, ancestors.inserted, persons.actor->>'$.preferredUsername'
+		from ancestors
+		left join persons on persons.id = ancestors.author
+		where ancestors.author is null or persons.id is not null
+		order by ancestors.distance desc`,
+		postID,
+		h.Config.ContextAncestors,
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch ancestors", "post", postID, "error", err)
+		w.Error()
+		return
+	}
+
+	var ancestors []contextNode
+	for rows.Next() {
+		var node contextNode
+		if err := rows.Scan(&node.PostID, &node.Inserted, &node.AuthorUserName); err != nil {
+			r.Log.Warn("Failed to scan post", "post", postID, "error", err)
+			continue
+		}
+
+		ancestors = append(ancestors, node)
+	}
+	rows.Close()
+
+	if len(ancestors) == 0 || ancestors[len(ancestors)-1].PostID != postID {
+		r.Log.Info("Post was not found", "post", postID)
+		w.Status(40, "Post not found")
+		return
+	}
+
+	rows, err = h.DB.QueryContext(
+		r.Context,
+		`with recursive descendants(id, author, inserted, depth, path) as (
+			select notes.id, notes.author, notes.inserted, 0, '' from notes where notes.id = $1
+			union all
+			select notes.id, notes.author, notes.inserted, d.depth + 1, d.path || notes.inserted || notes.id from descendants d join notes on notes.object->>'$.inReplyTo' = +d.id where d.depth < $2
+			union all
+			select tombstones.id, null, tombstones.inserted, d.depth + 1, d.path || tombstones.inserted || tombstones.id from descendants d join tombstones on tombstones.parent = d.id where d.depth < $2 and not exists (select 1 from notes where notes.id = tombstones.id)
+		)
+		select descendants.depth, descendants.id, descendants.inserted, persons.actor->>'$.preferredUsername'
+		from descendants
+		left join persons on persons.id = descendants.author
+		where descendants.depth > 0 and (descendants.author is null or persons.id is not null)
+		order by descendants.path
+		limit $3`,
+		postID,
+		h.Config.ContextDepth,
+		h.postsPerPage(r),
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch descendants", "post", postID, "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+	w.Title("🧵 Context")
+
+	for i := range ancestors {
+		ancestors[i].Depth = i
+		h.printContextNode(w, r, &ancestors[i], i == len(ancestors)-1)
+	}
+
+	for rows.Next() {
+		var node contextNode
+		if err := rows.Scan(&node.Depth, &node.PostID, &node.Inserted, &node.AuthorUserName); err != nil {
+			r.Log.Warn("Failed to scan post", "post", postID, "error", err)
+			continue
+		}
+
+		node.Depth += len(ancestors) - 1
+		h.printContextNode(w, r, &node, false)
+	}
+
+	w.Separator()
+
+	if r.User == nil {
+		w.Link("/thread/"+strings.TrimPrefix(ancestors[0].PostID, "https://"), "View thread")
+	} else {
+		w.Link("/users/thread/"+strings.TrimPrefix(ancestors[0].PostID, "https://"), "View thread")
+	}
+}
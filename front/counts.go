@@ -0,0 +1,208 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/httpsig"
+)
+
+// remoteCounts is the number of likes and shares of a remote post, or -1 if unknown.
+type remoteCounts struct {
+	Likes  int64
+	Shares int64
+}
+
+type remoteCountsEntry struct {
+	remoteCounts
+	Expires time.Time
+}
+
+// remoteCountsCache is a bounded cache of likes and shares counts, fetched in the background so viewing a post never
+// waits for the server that hosts it.
+type remoteCountsCache struct {
+	lock     sync.Mutex
+	entries  map[string]remoteCountsEntry
+	fetching map[string]struct{}
+}
+
+func newRemoteCountsCache() *remoteCountsCache {
+	return &remoteCountsCache{
+		entries:  map[string]remoteCountsEntry{},
+		fetching: map[string]struct{}{},
+	}
+}
+
+// String formats the known counts, or returns an empty string if both are unknown.
+func (c remoteCounts) String() string {
+	var parts []string
+	if c.Shares >= 0 {
+		parts = append(parts, fmt.Sprintf("♻ %d", c.Shares))
+	}
+	if c.Likes >= 0 {
+		parts = append(parts, fmt.Sprintf("⭐ %d", c.Likes))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// merge replaces counts with known counts from other.
+func (c *remoteCounts) merge(other remoteCounts) {
+	if other.Likes >= 0 {
+		c.Likes = other.Likes
+	}
+	if other.Shares >= 0 {
+		c.Shares = other.Shares
+	}
+}
+
+// getRemoteCounts returns the number of likes and shares of a remote post: cached counts if they're fresh, otherwise
+// the counts specified by the post, if any. If the cached counts are missing or stale and the user is authenticated,
+// they're refreshed in the background.
+func (h *Handler) getRemoteCounts(r *Request, note *ap.Object) remoteCounts {
+	counts := remoteCounts{Likes: -1, Shares: -1}
+
+	if note.Likes == nil && note.Shares == nil {
+		return counts
+	}
+
+	post, err := url.Parse(note.ID)
+	if err != nil {
+		return counts
+	}
+
+	var likes, shares string
+	if note.Likes != nil {
+		// collections are fetched only from the server that hosts the post
+		if u, err := url.Parse(note.Likes.ID); err == nil && u.Host == post.Host {
+			likes = note.Likes.ID
+		}
+		if note.Likes.TotalItems != nil {
+			counts.Likes = *note.Likes.TotalItems
+		}
+	}
+	if note.Shares != nil {
+		if u, err := url.Parse(note.Shares.ID); err == nil && u.Host == post.Host {
+			shares = note.Shares.ID
+		}
+		if note.Shares.TotalItems != nil {
+			counts.Shares = *note.Shares.TotalItems
+		}
+	}
+
+	now := time.Now()
+
+	h.counts.lock.Lock()
+	defer h.counts.lock.Unlock()
+
+	entry, ok := h.counts.entries[note.ID]
+	if ok {
+		counts.merge(entry.remoteCounts)
+		if now.Before(entry.Expires) {
+			return counts
+		}
+	}
+
+	// fetching requires a key, in case the other server requires signed requests
+	if r.Key.ID == "" || (likes == "" && shares == "") {
+		return counts
+	}
+
+	if _, ok := h.counts.fetching[note.ID]; ok || len(h.counts.fetching) >= h.Config.MaxRemoteCountsFetches {
+		return counts
+	}
+
+	h.counts.fetching[note.ID] = struct{}{}
+
+	go h.fetchRemoteCounts(r.Log, r.Key, note.ID, likes, shares)
+
+	return counts
+}
+
+func (h *Handler) fetchTotalItems(ctx context.Context, log *slog.Logger, key httpsig.Key, url string) int64 {
+	if !strings.HasPrefix(url, "https://") {
+		return -1
+	}
+
+	resp, err := h.Resolver.Get(ctx, key, url)
+	if err != nil {
+		log.Info("Failed to fetch collection", "url", url, "error", err)
+		return -1
+	}
+	defer resp.Body.Close()
+
+	var collection struct {
+		TotalItems *int64 `json:"totalItems"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, h.Config.MaxResponseBodySize)).Decode(&collection); err != nil {
+		log.Info("Failed to decode collection", "url", url, "error", err)
+		return -1
+	}
+
+	if collection.TotalItems == nil || *collection.TotalItems < 0 {
+		return -1
+	}
+
+	return *collection.TotalItems
+}
+
+func (h *Handler) fetchRemoteCounts(log *slog.Logger, key httpsig.Key, id, likes, shares string) {
+	ctx := context.Background()
+
+	counts := remoteCounts{Likes: -1, Shares: -1}
+	if likes != "" {
+		counts.Likes = h.fetchTotalItems(ctx, log, key, likes)
+	}
+	if shares != "" {
+		counts.Shares = h.fetchTotalItems(ctx, log, key, shares)
+	}
+
+	now := time.Now()
+
+	h.counts.lock.Lock()
+	defer h.counts.lock.Unlock()
+
+	delete(h.counts.fetching, id)
+
+	if _, ok := h.counts.entries[id]; !ok && len(h.counts.entries) >= h.Config.MaxCachedRemoteCounts {
+		for k, e := range h.counts.entries {
+			if !now.Before(e.Expires) {
+				delete(h.counts.entries, k)
+			}
+		}
+
+		// if nothing has expired, evict an arbitrary entry
+		if len(h.counts.entries) >= h.Config.MaxCachedRemoteCounts {
+			for k := range h.counts.entries {
+				delete(h.counts.entries, k)
+				break
+			}
+		}
+	}
+
+	// failures are cached too, to avoid fetching again every time the post is viewed
+	h.counts.entries[id] = remoteCountsEntry{remoteCounts: counts, Expires: now.Add(h.Config.RemoteCountsCacheTTL)}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/httpsig"
+	"github.com/stretchr/testify/assert"
+)
+
+type countsResolver struct {
+	lock      sync.Mutex
+	responses map[string]string
+	requests  int
+}
+
+func (r *countsResolver) ResolveID(context.Context, httpsig.Key, string, ap.ResolverFlag) (*ap.Actor, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *countsResolver) Resolve(context.Context, httpsig.Key, string, string, ap.ResolverFlag) (*ap.Actor, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *countsResolver) Get(_ context.Context, _ httpsig.Key, url string) (*http.Response, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.requests++
+
+	body, ok := r.responses[url]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func newCountsTestHandler(resolver ap.Resolver) *Handler {
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	return &Handler{
+		Config:   &cfg,
+		Resolver: resolver,
+		counts:   newRemoteCountsCache(),
+	}
+}
+
+func waitForRemoteCounts(h *Handler) {
+	for {
+		h.counts.lock.Lock()
+		n := len(h.counts.fetching)
+		h.counts.lock.Unlock()
+
+		if n == 0 {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRemoteCounts_String(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("♻ 12 · ⭐ 34", remoteCounts{Likes: 34, Shares: 12}.String())
+	assert.Equal("⭐ 0", remoteCounts{Likes: 0, Shares: -1}.String())
+	assert.Equal("", remoteCounts{Likes: -1, Shares: -1}.String())
+}
+
+func TestGetRemoteCounts_Fetch(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := countsResolver{
+		responses: map[string]string{
+			"https://127.0.0.1/note/1/likes":  `{"id":"https://127.0.0.1/note/1/likes","type":"Collection","totalItems":34}`,
+			"https://127.0.0.1/note/1/shares": `{"id":"https://127.0.0.1/note/1/shares","type":"Collection","totalItems":12}`,
+		},
+	}
+	h := newCountsTestHandler(&resolver)
+
+	var total int64 = 3
+	note := ap.Object{
+		ID:     "https://127.0.0.1/note/1",
+		Likes:  &ap.CountedCollection{ID: "https://127.0.0.1/note/1/likes", TotalItems: &total},
+		Shares: &ap.CountedCollection{ID: "https://127.0.0.1/note/1/shares"},
+	}
+
+	r := Request{Context: context.Background(), Log: slog.Default(), Key: httpsig.Key{ID: "https://localhost.localdomain/key/alice"}}
+
+	// the fetch happens in the background: until it's done, the counts specified by the post are used
+	assert.Equal(remoteCounts{Likes: 3, Shares: -1}, h.getRemoteCounts(&r, &note))
+
+	waitForRemoteCounts(h)
+
+	assert.Equal(remoteCounts{Likes: 34, Shares: 12}, h.getRemoteCounts(&r, &note))
+	assert.Equal(2, resolver.requests)
+
+	// unauthenticated users see cached counts
+	assert.Equal(remoteCounts{Likes: 34, Shares: 12}, h.getRemoteCounts(&Request{Context: context.Background(), Log: slog.Default()}, &note))
+	assert.Equal(2, resolver.requests)
+}
+
+func TestGetRemoteCounts_Failure(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := countsResolver{}
+	h := newCountsTestHandler(&resolver)
+
+	var total int64 = 3
+	note := ap.Object{
+		ID:    "https://127.0.0.1/note/1",
+		Likes: &ap.CountedCollection{ID: "https://127.0.0.1/note/1/likes", TotalItems: &total},
+	}
+
+	r := Request{Context: context.Background(), Log: slog.Default(), Key: httpsig.Key{ID: "https://localhost.localdomain/key/alice"}}
+
+	assert.Equal(remoteCounts{Likes: 3, Shares: -1}, h.getRemoteCounts(&r, &note))
+
+	waitForRemoteCounts(h)
+
+	// failures are cached, and the counts specified by the post are used
+	assert.Equal(remoteCounts{Likes: 3, Shares: -1}, h.getRemoteCounts(&r, &note))
+	assert.Equal(1, resolver.requests)
+}
+
+func TestGetRemoteCounts_Unauthenticated(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := countsResolver{}
+	h := newCountsTestHandler(&resolver)
+
+	note := ap.Object{
+		ID:    "https://127.0.0.1/note/1",
+		Likes: &ap.CountedCollection{ID: "https://127.0.0.1/note/1/likes"},
+	}
+
+	assert.Equal(remoteCounts{Likes: -1, Shares: -1}, h.getRemoteCounts(&Request{Context: context.Background(), Log: slog.Default()}, &note))
+	waitForRemoteCounts(h)
+	assert.Equal(0, resolver.requests)
+}
+
+func TestGetRemoteCounts_OtherHost(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := countsResolver{}
+	h := newCountsTestHandler(&resolver)
+
+	note := ap.Object{
+		ID:     "https://127.0.0.1/note/1",
+		Likes:  &ap.CountedCollection{ID: "https://127.0.0.2/note/1/likes"},
+		Shares: &ap.CountedCollection{ID: "https://127.0.0.1.example.com/note/1/shares"},
+	}
+
+	r := Request{Context: context.Background(), Log: slog.Default(), Key: httpsig.Key{ID: "https://localhost.localdomain/key/alice"}}
+
+	assert.Equal(remoteCounts{Likes: -1, Shares: -1}, h.getRemoteCounts(&r, &note))
+	waitForRemoteCounts(h)
+	assert.Equal(0, resolver.requests)
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+)
+
+const (
+	maxDefaultSummaryLength = 100
+	maxDefaultHashtags      = 5
+)
+
+var defaultHashtagRegex = regexp.MustCompile(`^\w{1,32}$`)
+
+// postDefaults contains settings applied to new posts.
+type postDefaults struct {
+	Audience string
+	Summary  string
+	Hashtags []string
+	Replies  string
+}
+
+// getPostDefaults returns the default audience, content warning, hashtags and reply policy of a user.
+func (h *Handler) getPostDefaults(r *Request) (postDefaults, error) {
+	var audience, summary, hashtags, replies sql.NullString
+	if err := h.DB.QueryRowContext(r.Context, `select audience, cw, hashtags, replies from settings where actor = ?`, r.User.ID).Scan(&audience, &summary, &hashtags, &replies); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return postDefaults{}, err
+	}
+
+	defaults := postDefaults{Audience: "public", Summary: summary.String, Replies: "everyone"}
+
+	if replies.Valid {
+		defaults.Replies = replies.String
+	}
+
+	if audience.Valid {
+		defaults.Audience = audience.String
+	}
+
+	if hashtags.Valid {
+		if err := json.Unmarshal([]byte(hashtags.String), &defaults.Hashtags); err != nil {
+			return postDefaults{}, err
+		}
+	}
+
+	return defaults, nil
+}
+
+// replyPolicy returns the interaction policy of a new post: if replies are restricted, only the author, mentioned
+// users and the listed actors can reply.
+func replyPolicy(author *ap.Actor, replies string) *ap.InteractionPolicy {
+	switch replies {
+	case "followers":
+		policy := ap.InteractionPolicy{CanReply: &ap.InteractionRule{}}
+		policy.CanReply.Always.Add(author.Followers)
+		return &policy
+
+	case "mentioned":
+		return &ap.InteractionPolicy{CanReply: &ap.InteractionRule{}}
+
+	default:
+		return nil
+	}
+}
+
+// appendHashtags appends default hashtags missing from the content of a new post.
+func appendHashtags(content string, hashtags []string) string {
+	var missing []string
+
+hashtags:
+	for _, hashtag := range hashtags {
+		for _, existing := range hashtagRegex.FindAllString(content, -1) {
+			if strings.EqualFold(existing[1:], hashtag) {
+				continue hashtags
+			}
+		}
+
+		missing = append(missing, "#"+hashtag)
+	}
+
+	if len(missing) == 0 {
+		return content
+	}
+
+	return content + "\n\n" + strings.Join(missing, " ")
+}
+
+func (h *Handler) compose(w text.Writer, r *Request, args ...string) {
+	h.composeWith(w, r, func() (string, bool) {
+		return readQuery(w, r, "Post content")
+	})
+}
+
+func (h *Handler) uploadCompose(w text.Writer, r *Request, args ...string) {
+	h.composeWith(w, r, func() (string, bool) {
+		return h.readBody(w, r, args)
+	})
+}
+
+func (h *Handler) composeWith(w text.Writer, r *Request, readInput inputFunc) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	defaults, err := h.getPostDefaults(r)
+	if err != nil {
+		r.Log.Warn("Failed to get post defaults", "error", err)
+		w.Error()
+		return
+	}
+
+	to := ap.Audience{}
+	cc := ap.Audience{}
+
+	if defaults.Audience == "followers" {
+		to.Add(r.User.Followers)
+	} else {
+		to.Add(ap.Public)
+		cc.Add(r.User.Followers)
+	}
+
+	h.postWithDefaults(w, r, nil, nil, to, cc, "", defaults, readInput)
+}
+
+func (h *Handler) defaultAudience(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	// public is the default
+	var audience sql.NullString
+	if args[1] != "public" {
+		audience = sql.NullString{String: args[1], Valid: true}
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, audience) values($1, $2) on conflict(actor) do update set audience = $2`,
+		r.User.ID,
+		audience,
+	); err != nil {
+		r.Log.Warn("Failed to set default audience", "audience", args[1], "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+func (h *Handler) defaultSummary(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	summary, ok := readQuery(w, r, "Content warning for new posts")
+	if !ok {
+		return
+	}
+
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		w.Status(40, "Content warning is empty")
+		return
+	}
+
+	if utf8.RuneCountInString(summary) > maxDefaultSummaryLength {
+		w.Status(40, "Content warning is too long")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, cw) values($1, $2) on conflict(actor) do update set cw = $2`,
+		r.User.ID,
+		summary,
+	); err != nil {
+		r.Log.Warn("Failed to set default content warning", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+func (h *Handler) clearSummary(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `update settings set cw = null where actor = ?`, r.User.ID); err != nil {
+		r.Log.Warn("Failed to clear default content warning", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+func (h *Handler) defaultHashtags(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	input, ok := readQuery(w, r, fmt.Sprintf("Hashtags for new posts (up to %d, e.g. #gemini #smolweb)", maxDefaultHashtags))
+	if !ok {
+		return
+	}
+
+	var hashtags []string
+	for _, hashtag := range strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		hashtag = strings.TrimPrefix(hashtag, "#")
+		if !defaultHashtagRegex.MatchString(hashtag) {
+			w.Status(40, "Invalid hashtag")
+			return
+		}
+
+		hashtags = append(hashtags, hashtag)
+	}
+
+	if len(hashtags) == 0 {
+		w.Status(40, "No hashtags")
+		return
+	}
+
+	if len(hashtags) > maxDefaultHashtags {
+		w.Status(40, "Too many hashtags")
+		return
+	}
+
+	j, err := json.Marshal(hashtags)
+	if err != nil {
+		r.Log.Warn("Failed to marshal hashtags", "error", err)
+		w.Error()
+		return
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, hashtags) values($1, $2) on conflict(actor) do update set hashtags = $2`,
+		r.User.ID,
+		string(j),
+	); err != nil {
+		r.Log.Warn("Failed to set default hashtags", "hashtags", hashtags, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+func (h *Handler) clearHashtags(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `update settings set hashtags = null where actor = ?`, r.User.ID); err != nil {
+		r.Log.Warn("Failed to clear default hashtags", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+func (h *Handler) defaultReplies(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	// everyone is the default
+	var replies sql.NullString
+	if args[1] != "everyone" {
+		replies = sql.NullString{String: args[1], Valid: true}
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, replies) values($1, $2) on conflict(actor) do update set replies = $2`,
+		r.User.ID,
+		replies,
+	); err != nil {
+		r.Log.Warn("Failed to set reply policy", "replies", args[1], "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
@@ -51,5 +51,7 @@ func (h *Handler) delete(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
+	h.InvalidatePost(&note)
+
 	w.Redirect("/users/outbox/" + strings.TrimPrefix(r.User.ID, "https://"))
 }
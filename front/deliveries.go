@@ -0,0 +1,131 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+const maxDeliveriesPerPost = 200
+
+func (h *Handler) deliveries(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	postID := "https://" + args[1]
+
+	var exists int
+	if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from notes where id = ? and author = ?)`, postID, r.User.ID).Scan(&exists); err != nil {
+		r.Log.Warn("Failed to check if post exists", "post", postID, "error", err)
+		w.Error()
+		return
+	} else if exists == 0 {
+		r.Log.Info("Post was not found", "post", postID)
+		w.Status(40, "Post not found")
+		return
+	}
+
+	rows, err := h.DB.QueryContext(
+		r.Context,
+		`select outbox.activity->>'$.id', outbox.activity->>'$.type', outbox.inserted, outbox.sent, outbox.attempts, deliverylog.inbox, deliverylog.status, deliverylog.attempts, deliverylog.error, deliverylog.updated from outbox
+		left join deliverylog on deliverylog.activity = outbox.activity->>'$.id'
+		where
+			outbox.sender = $1 and
+			outbox.activity->>'$.object.id' = $2
+		order by outbox.inserted desc, deliverylog.error is null, deliverylog.inbox
+		limit $3`,
+		r.User.ID,
+		postID,
+		maxDeliveriesPerPost,
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch deliveries", "post", postID, "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+	w.Title("📬 Deliveries")
+
+	var last string
+	pending := false
+	for rows.Next() {
+		var activityID, activityType string
+		var inserted, attempts int64
+		var sent bool
+		var inbox, errString sql.NullString
+		var status, inboxAttempts, updated sql.NullInt64
+		if err := rows.Scan(&activityID, &activityType, &inserted, &sent, &attempts, &inbox, &status, &inboxAttempts, &errString, &updated); err != nil {
+			r.Log.Warn("Failed to scan delivery", "error", err)
+			continue
+		}
+
+		if activityID != last {
+			if last != "" {
+				w.Empty()
+			}
+
+			w.Subtitlef("%s (%s)", activityType, time.Unix(inserted, 0).UTC().Format(time.DateTime))
+
+			if sent {
+				w.Text("Delivered to all recipients.")
+			} else if attempts >= int64(h.Config.MaxDeliveryAttempts) {
+				w.Text("Delivery to some recipients has failed and won't be retried.")
+			} else if attempts == 0 {
+				w.Text("Waiting for delivery.")
+			} else {
+				w.Text("Delivery to some recipients has failed and will be retried.")
+				pending = true
+			}
+
+			last = activityID
+		}
+
+		if !inbox.Valid {
+			continue
+		}
+
+		host := text.SingleLine(strings.TrimPrefix(inbox.String, "https://"))
+
+		if !errString.Valid {
+			w.Itemf("✅ %s: delivered (%s)", host, time.Unix(updated.Int64, 0).UTC().Format(time.DateTime))
+		} else if status.Valid {
+			w.Itemf("❌ %s: %d after %d attempts (%s): %s", host, status.Int64, inboxAttempts.Int64, time.Unix(updated.Int64, 0).UTC().Format(time.DateTime), text.SingleLine(errString.String))
+		} else {
+			w.Itemf("❌ %s: failed after %d attempts (%s): %s", host, inboxAttempts.Int64, time.Unix(updated.Int64, 0).UTC().Format(time.DateTime), text.SingleLine(errString.String))
+		}
+	}
+
+	if last == "" {
+		w.Text("No deliveries.")
+	}
+
+	if pending {
+		w.Empty()
+		w.Link(r.URL.Path, "🔄 Refresh")
+	}
+
+	w.Empty()
+	w.Link("/users/view/"+args[1], "💬 Back to post")
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/graph"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/front/text/plain"
+)
+
+const (
+	digestMaxThreads = 10
+	digestMaxAge     = time.Hour * 24 * 7
+)
+
+func (h *Handler) digest(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	now := time.Now()
+
+	// the first digest covers the last day, and digests never go back more than a week
+	since := now.Add(-time.Hour * 24)
+	var last sql.NullInt64
+	if err := h.DB.QueryRowContext(r.Context, `select digest from settings where actor = ?`, r.User.ID).Scan(&last); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		r.Log.Warn("Failed to fetch last digest time", "error", err)
+		w.Error()
+		return
+	} else if last.Valid {
+		since = time.Unix(last.Int64, 0)
+	}
+	if now.Sub(since) > digestMaxAge {
+		since = now.Add(-digestMaxAge)
+	}
+
+	threads, err := h.DB.QueryContext(
+		r.Context,
+		`select feed.note, feed.author, null, feed.inserted from
+		feed
+		join
+		(
+			select object->>'$.inReplyTo' as id, count(*) as count from notes
+			where
+				inserted >= $1 and
+				object->>'$.inReplyTo' is not null
+			group by object->>'$.inReplyTo'
+		) replies
+		on
+			replies.id = feed.note->>'$.id'
+		where
+			feed.follower = $2 and
+			feed.sharer is null and
+			exists (select 1 from follows where follows.follower = $2 and follows.followed = feed.author->>'$.id' and follows.accepted = 1) and
+			not exists (select 1 from filters where filters.actor = $2 and filters.feed = 1 and filters.action = 'hide' and (filters.expires is null or filters.expires > unixepoch()) and (instr(lower(feed.note->>'$.content'), lower(filters.phrase)) or instr(lower(feed.note->>'$.name'), lower(filters.phrase)) or instr(lower(feed.note->>'$.summary'), lower(filters.phrase))))
+		order by
+			replies.count desc,
+			feed.inserted desc
+		limit $3`,
+		since.Unix(),
+		r.User.ID,
+		digestMaxThreads,
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch active threads", "error", err)
+		w.Error()
+		return
+	}
+
+	w.OK()
+	w.Title("📰 Digest")
+	w.Textf("Activity since %s.", since.UTC().Format(time.DateTime))
+
+	w.Empty()
+	w.Subtitle("🔥 Active Threads")
+	h.PrintNotes(w, r, threads, true, false, h.getFilters(r, filterFeed), "No active threads.")
+	threads.Close()
+
+	w.Empty()
+	w.Subtitle("🐾 New Followers")
+
+	followers, err := h.DB.QueryContext(
+		r.Context,
+		`select persons.actor from follows join persons on persons.id = follows.follower where follows.followed = $1 and follows.accepted = 1 and follows.inserted >= $2 order by follows.inserted desc`,
+		r.User.ID,
+		since.Unix(),
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch new followers", "error", err)
+	} else {
+		empty := true
+		for followers.Next() {
+			var follower ap.Actor
+			if err := followers.Scan(&follower); err != nil {
+				r.Log.Warn("Failed to scan follower", "error", err)
+				continue
+			}
+
+			w.Link("/users/outbox/"+strings.TrimPrefix(follower.ID, "https://"), follower.PreferredUsername)
+			empty = false
+		}
+		followers.Close()
+
+		if empty {
+			w.Text("No new followers.")
+		}
+	}
+
+	w.Empty()
+	w.Subtitle("📊 Poll Results")
+
+	polls, err := h.DB.QueryContext(
+		r.Context,
+		`select notes.object from notes
+		where
+			notes.object->>'$.type' = 'Question' and
+			(
+				notes.author = $1 or
+				exists (select 1 from notes votes where votes.object->>'$.inReplyTo' = notes.id and votes.author = $1 and votes.object->>'$.name' is not null)
+			) and
+			coalesce(unixepoch(notes.object->>'$.closed'), unixepoch(notes.object->>'$.endTime')) between $2 and unixepoch()
+		order by
+			notes.inserted desc`,
+		r.User.ID,
+		since.Unix(),
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch poll results", "error", err)
+	} else {
+		empty := true
+		for polls.Next() {
+			var poll ap.Object
+			if err := polls.Scan(&poll); err != nil {
+				r.Log.Warn("Failed to scan poll", "error", err)
+				continue
+			}
+
+			options := poll.OneOf
+			if len(options) == 0 {
+				options = poll.AnyOf
+			}
+
+			labels := make([]string, 0, len(options))
+			votes := make([]int64, 0, len(options))
+
+			for _, option := range options {
+				labels = append(labels, option.Name)
+				votes = append(votes, option.Replies.TotalItems)
+			}
+
+			if !empty {
+				w.Empty()
+			}
+
+			question, _ := plain.FromHTML(poll.Content)
+			w.Link("/users/view/"+strings.TrimPrefix(poll.ID, "https://"), question)
+			if len(options) > 0 {
+				w.Raw("Results graph", graph.Bars(labels, votes))
+			}
+
+			empty = false
+		}
+		polls.Close()
+
+		if empty {
+			w.Text("No poll results.")
+		}
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, digest) values($1, $2) on conflict(actor) do update set digest = $2`,
+		r.User.ID,
+		now.Unix(),
+	); err != nil {
+		r.Log.Warn("Failed to save last digest time", "error", err)
+	}
+}
@@ -19,7 +19,6 @@ package front
 import (
 	"database/sql"
 	"errors"
-	"math"
 	"time"
 
 	"github.com/dimkr/tootik/ap"
@@ -51,19 +50,13 @@ func (h *Handler) doEdit(w text.Writer, r *Request, args []string, readInput inp
 		return
 	}
 
-	var edits int
-	if err := h.DB.QueryRowContext(r.Context, `select count(*) from outbox where activity->>'$.object.id' = ? and sender = ? and (activity->>'$.type' = 'Update' or activity->>'$.type' = 'Create')`, note.ID, r.User.ID).Scan(&edits); err != nil {
+	canEdit, err := h.canEditPost(r, &note)
+	if err != nil {
 		r.Log.Warn("Failed to count post edits", "post", postID, "error", err)
 		w.Error()
 		return
 	}
 
-	lastEditTime := note.Published
-	if note.Updated != nil && *note.Updated != (ap.Time{}) {
-		lastEditTime = *note.Updated
-	}
-
-	canEdit := lastEditTime.Add(h.Config.EditThrottleUnit * time.Duration(math.Pow(h.Config.EditThrottleFactor, float64(edits))))
 	until := time.Until(canEdit)
 	if until > 0 {
 		r.Log.Warn("Throttled request to edit post", "note", note.ID, "can", canEdit)
@@ -0,0 +1,182 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/outbox"
+)
+
+var rsvpResponses = map[string]ap.ActivityType{
+	"accept":    ap.Accept,
+	"tentative": ap.TentativeAccept,
+	"reject":    ap.Reject,
+}
+
+var rsvpLabels = map[ap.ActivityType]string{
+	ap.Accept:          "✅ Going",
+	ap.TentativeAccept: "🤔 Maybe",
+	ap.Reject:          "❌ Not going",
+}
+
+// eventBody returns the body of an event, starting with its title, time and location.
+func eventBody(note *ap.Object, compact bool) string {
+	var b strings.Builder
+
+	name := note.Name
+	if name == "" {
+		name = "Untitled"
+	}
+	b.WriteString("📅 Event: ")
+	b.WriteString(html.EscapeString(name))
+
+	if compact {
+		if note.StartTime != nil {
+			fmt.Fprintf(&b, " (%s)", note.StartTime.UTC().Format(time.DateTime))
+		}
+		return b.String()
+	}
+
+	if note.StartTime != nil {
+		fmt.Fprintf(&b, "<br>🕒 Starts: %s", note.StartTime.UTC().Format(time.DateTime))
+	}
+
+	if note.EndTime != nil {
+		fmt.Fprintf(&b, "<br>🏁 Ends: %s", note.EndTime.UTC().Format(time.DateTime))
+	}
+
+	if note.Location != nil && note.Location.Name != "" && note.Location.Address != "" && note.Location.Name != note.Location.Address {
+		fmt.Fprintf(&b, "<br>📍 Location: %s, %s", html.EscapeString(note.Location.Name), html.EscapeString(note.Location.Address))
+	} else if note.Location != nil && note.Location.Name != "" {
+		fmt.Fprintf(&b, "<br>📍 Location: %s", html.EscapeString(note.Location.Name))
+	} else if note.Location != nil && note.Location.Address != "" {
+		fmt.Fprintf(&b, "<br>📍 Location: %s", html.EscapeString(note.Location.Address))
+	}
+
+	if note.Content != "" {
+		b.WriteString("<br><br>")
+		b.WriteString(note.Content)
+	}
+
+	return b.String()
+}
+
+// printRSVP prints the user's response to an event and links for changing it.
+func (h *Handler) printRSVP(w text.Writer, r *Request, note *ap.Object) {
+	if r.User == nil || strings.HasPrefix(note.ID, fmt.Sprintf("https://%s/", h.Domain)) {
+		return
+	}
+
+	w.Empty()
+	w.Subtitle("📅 RSVP")
+
+	var response sql.NullString
+	if err := h.DB.QueryRowContext(r.Context, `select response from rsvps where event = ? and actor = ?`, note.ID, r.User.ID).Scan(&response); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		r.Log.Warn("Failed to fetch RSVP", "event", note.ID, "error", err)
+	} else if response.Valid {
+		w.Textf("Your response: %s", rsvpLabels[ap.ActivityType(response.String)])
+	} else {
+		w.Text("You haven't responded yet.")
+	}
+
+	if note.StartTime != nil && time.Now().After(note.StartTime.Time) {
+		return
+	}
+
+	for _, option := range []string{"accept", "tentative", "reject"} {
+		if response.String != string(rsvpResponses[option]) {
+			w.Link(fmt.Sprintf("/users/rsvp/%s/%s", option, strings.TrimPrefix(note.ID, "https://")), rsvpLabels[rsvpResponses[option]])
+		}
+	}
+}
+
+func (h *Handler) rsvp(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if r.User.MovedTo != "" {
+		r.Log.Warn("Moved user cannot respond to events", "movedTo", r.User.MovedTo)
+		w.Status(40, "Account was moved to "+r.User.MovedTo)
+		return
+	}
+
+	response := rsvpResponses[args[1]]
+	eventID := "https://" + args[2]
+
+	var event ap.Object
+	if err := h.DB.QueryRowContext(
+		r.Context,
+		`select object from notes
+		where
+			notes.id = $1 and
+			notes.host != $2 and
+			notes.object->>'$.type' = 'Event' and
+			(
+				notes.public = 1 or
+				exists (select 1 from json_each(notes.object->'$.to') where exists (select 1 from follows join persons on persons.id = follows.followed where follows.follower = $3 and follows.followed = notes.author and (notes.author = value or persons.actor->>'$.followers' = value))) or
+				exists (select 1 from json_each(notes.object->'$.cc') where exists (select 1 from follows join persons on persons.id = follows.followed where follows.follower = $3 and follows.followed = notes.author and (notes.author = value or persons.actor->>'$.followers' = value))) or
+				exists (select 1 from json_each(notes.object->'$.to') where value = $3) or
+				exists (select 1 from json_each(notes.object->'$.cc') where value = $3)
+			)`,
+		eventID,
+		h.Domain,
+		r.User.ID,
+	).Scan(&event); err != nil && errors.Is(err, sql.ErrNoRows) {
+		r.Log.Info("Event was not found", "event", eventID)
+		w.Status(40, "Event not found")
+		return
+	} else if err != nil {
+		r.Log.Warn("Failed to fetch event", "event", eventID, "error", err)
+		w.Error()
+		return
+	}
+
+	if event.StartTime != nil && time.Now().After(event.StartTime.Time) {
+		w.Status(40, "Event has already started")
+		return
+	}
+
+	var previous sql.NullString
+	if err := h.DB.QueryRowContext(r.Context, `select response from rsvps where event = ? and actor = ?`, event.ID, r.User.ID).Scan(&previous); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		r.Log.Warn("Failed to fetch RSVP", "event", event.ID, "error", err)
+		w.Error()
+		return
+	} else if previous.String == string(response) {
+		w.Redirect("/users/view/" + args[2])
+		return
+	}
+
+	r.Log.Info("Responding to event", "event", event.ID, "response", response)
+
+	if err := outbox.RSVP(r.Context, h.Domain, r.User, &event, response, h.DB); err != nil {
+		r.Log.Warn("Failed to respond to event", "event", event.ID, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/view/" + args[2])
+}
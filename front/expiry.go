@@ -0,0 +1,65 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+const maxPostExpiryDays = 3650
+
+func (h *Handler) postExpiry(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	input, ok := readQuery(w, r, fmt.Sprintf("Delete posts older than (1-%d days, 0 to keep posts)", maxPostExpiryDays))
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || n < 0 || n > maxPostExpiryDays {
+		w.Status(40, "Invalid number of days")
+		return
+	}
+
+	// null means posts are kept, which is the default
+	var days sql.NullInt64
+	if n > 0 {
+		days = sql.NullInt64{Int64: int64(n), Valid: true}
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, expiry) values($1, $2) on conflict(actor) do update set expiry = $2`,
+		r.User.ID,
+		days,
+	); err != nil {
+		r.Log.Warn("Failed to set post expiry", "days", n, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
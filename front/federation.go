@@ -0,0 +1,196 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+const domainsPerPage = 20
+
+func (h *Handler) isAdmin(r *Request) bool {
+	return r.User != nil && slices.Contains(h.Config.Admins, r.User.ID)
+}
+
+func (h *Handler) federation(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if !h.isAdmin(r) {
+		r.Log.Warn("User is not an admin")
+		w.Status(40, "Forbidden")
+		return
+	}
+
+	offset, err := getOffset(r.URL)
+	if err != nil {
+		r.Log.Info("Failed to parse query", "url", r.URL, "error", err)
+		w.Status(40, "Invalid query")
+		return
+	}
+
+	if offset > h.Config.MaxOffset {
+		r.Log.Warn("Offset is too big", "offset", offset)
+		w.Statusf(40, "Offset must be <= %d", h.Config.MaxOffset)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(
+		r.Context,
+		`select domains.host, domains.lastsuccess, domains.failures, domains.paused, domains.dormant, (
+			select count(*) from undelivered
+			where
+				undelivered.host = domains.host and
+				exists (select 1 from outbox where outbox.activity->>'$.id' = undelivered.activity and outbox.sent = 0 and outbox.attempts < $1)
+		), (
+			select deliverylog.error from deliverylog
+			where deliverylog.host = domains.host and deliverylog.error is not null
+			order by deliverylog.updated desc
+			limit 1
+		) from domains
+		order by domains.paused desc, domains.dormant desc, domains.failures desc, domains.host
+		limit $2
+		offset $3`,
+		h.Config.MaxDeliveryAttempts,
+		domainsPerPage,
+		offset,
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch federation status", "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+
+	if offset > 0 {
+		w.Titlef("🌐 Federation (%d-%d)", offset, offset+domainsPerPage)
+	} else {
+		w.Title("🌐 Federation")
+	}
+
+	w.Link("/users/admin/rejections", "🚫 Rejected activities")
+	w.Empty()
+
+	count := 0
+	for rows.Next() {
+		var host string
+		var lastSuccess sql.NullInt64
+		var failures, queued int64
+		var paused, dormant bool
+		var lastError sql.NullString
+		if err := rows.Scan(&host, &lastSuccess, &failures, &paused, &dormant, &queued, &lastError); err != nil {
+			r.Log.Warn("Failed to scan domain", "error", err)
+			continue
+		}
+
+		if count > 0 {
+			w.Empty()
+		}
+
+		if paused {
+			w.Subtitlef("%s (paused)", host)
+		} else if dormant {
+			w.Subtitlef("%s (dormant)", host)
+		} else {
+			w.Subtitle(host)
+		}
+
+		if lastSuccess.Valid {
+			w.Itemf("Last successful delivery: %s", time.Unix(lastSuccess.Int64, 0).UTC().Format(time.DateTime))
+		} else {
+			w.Item("Last successful delivery: never")
+		}
+		w.Itemf("Consecutive failures: %d", failures)
+		w.Itemf("Queued activities: %d", queued)
+		if lastError.Valid {
+			w.Itemf("Last error: %s", text.SingleLine(lastError.String))
+		}
+
+		if paused {
+			w.Link("/users/admin/federation/resume/"+host, "▶️ Resume delivery")
+		} else {
+			w.Link("/users/admin/federation/pause/"+host, "⏸️ Pause delivery")
+		}
+
+		count++
+	}
+
+	if count == 0 {
+		w.Text("No deliveries.")
+	}
+
+	if offset >= domainsPerPage || count == domainsPerPage {
+		w.Separator()
+	}
+
+	if offset >= domainsPerPage {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset-domainsPerPage), "Previous page (%d-%d)", offset-domainsPerPage, offset)
+	}
+
+	if count == domainsPerPage && offset+domainsPerPage <= h.Config.MaxOffset {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+domainsPerPage), "Next page (%d-%d)", offset+domainsPerPage, offset+2*domainsPerPage)
+	}
+}
+
+func (h *Handler) setDomainPaused(w text.Writer, r *Request, host string, paused bool) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if !h.isAdmin(r) {
+		r.Log.Warn("User is not an admin")
+		w.Status(40, "Forbidden")
+		return
+	}
+
+	if host == h.Domain {
+		w.Status(40, "Cannot pause local delivery")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into domains(host, paused) values($1, $2) on conflict(host) do update set paused = $2`,
+		host,
+		paused,
+	); err != nil {
+		r.Log.Warn("Failed to update domain", "host", host, "error", err)
+		w.Error()
+		return
+	}
+
+	r.Log.Info("Updated domain", "host", host, "paused", paused)
+	w.Redirect("/users/admin/federation")
+}
+
+func (h *Handler) pauseDomain(w text.Writer, r *Request, args ...string) {
+	h.setDomainPaused(w, r, args[1], true)
+}
+
+func (h *Handler) resumeDomain(w text.Writer, r *Request, args ...string) {
+	h.setDomainPaused(w, r, args[1], false)
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/front/text/plain"
+	"github.com/dimkr/tootik/outbox"
+)
+
+func (h *Handler) fields(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	w.OK()
+	w.Title("🏷️ Profile Fields")
+
+	empty := true
+	for i, field := range r.User.Attachment {
+		if field.Type != ap.PropertyValue {
+			continue
+		}
+
+		raw, _ := plain.FromHTML(field.Value)
+		if field.VerifiedAt == nil {
+			w.Textf("%s: %s", field.Name, raw)
+		} else {
+			w.Textf("%s: %s ✓", field.Name, raw)
+		}
+		w.Linkf(fmt.Sprintf("/users/fields/remove/%d", i), "➖ Remove %s", field.Name)
+
+		empty = false
+	}
+
+	if empty {
+		w.Text("No fields.")
+	}
+
+	if len(r.User.Attachment) < h.Config.MaxProfileFields {
+		w.Empty()
+		w.Link("/users/fields/add", "➕ Add field")
+	}
+
+	w.Subtitle("Verified Links")
+	w.Textf("A link is marked as verified (✓) if the linked page links back to %s with rel=\"me\".", r.User.ID)
+}
+
+func (h *Handler) canEditFields(w text.Writer, r *Request) bool {
+	if r.User == nil {
+		w.Redirect("/users")
+		return false
+	}
+
+	can := h.canEditProfile(r.User)
+	if time.Now().Before(can) {
+		r.Log.Warn("Throttled request to set profile fields", "can", can)
+		w.Statusf(40, "Please wait for %s", time.Until(can).Truncate(time.Second).String())
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) setFields(w text.Writer, r *Request, fields []ap.Attachment) {
+	j, err := json.Marshal(fields)
+	if err != nil {
+		r.Log.Error("Failed to marshal profile fields", "error", err)
+		w.Error()
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context, nil)
+	if err != nil {
+		r.Log.Warn("Failed to update profile fields", "error", err)
+		w.Error()
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		r.Context,
+		"update persons set actor = json_set(actor, '$.attachment', json($1), '$.updated', $2) where id = $3",
+		string(j),
+		time.Now().Format(time.RFC3339Nano),
+		r.User.ID,
+	); err != nil {
+		r.Log.Error("Failed to update profile fields", "error", err)
+		w.Error()
+		return
+	}
+
+	if err := outbox.UpdateActor(r.Context, h.Domain, tx, r.User.ID); err != nil {
+		r.Log.Error("Failed to update profile fields", "error", err)
+		w.Error()
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Log.Error("Failed to update profile fields", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/fields")
+}
+
+func (h *Handler) addField(w text.Writer, r *Request, args ...string) {
+	if !h.canEditFields(w, r) {
+		return
+	}
+
+	if len(r.User.Attachment) >= h.Config.MaxProfileFields {
+		w.Status(40, "Reached fields limit")
+		return
+	}
+
+	input, ok := readQuery(w, r, "Field (name=value)")
+	if !ok {
+		return
+	}
+
+	name, value, ok := strings.Cut(input, "=")
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if !ok || name == "" || value == "" {
+		w.Status(40, "Bad input")
+		return
+	}
+
+	if utf8.RuneCountInString(name) > h.Config.MaxProfileFieldName {
+		w.Status(40, "Name is too long")
+		return
+	}
+
+	if utf8.RuneCountInString(value) > h.Config.MaxProfileFieldValue {
+		w.Status(40, "Value is too long")
+		return
+	}
+
+	field := ap.Attachment{
+		Type: ap.PropertyValue,
+		Name: name,
+	}
+
+	if u, err := url.Parse(value); err == nil && u.Scheme == "https" && u.Host != "" {
+		escaped := html.EscapeString(u.String())
+		field.Value = fmt.Sprintf(`<a href="%s" rel="me nofollow noopener noreferrer" target="_blank">%s</a>`, escaped, escaped)
+	} else {
+		field.Value = plain.ToHTML(value, nil)
+	}
+
+	h.setFields(w, r, append(append([]ap.Attachment{}, r.User.Attachment...), field))
+}
+
+func (h *Handler) removeField(w text.Writer, r *Request, args ...string) {
+	if !h.canEditFields(w, r) {
+		return
+	}
+
+	i, err := strconv.Atoi(args[1])
+	if err != nil || i >= len(r.User.Attachment) {
+		w.Status(40, "No such field")
+		return
+	}
+
+	fields := append(append([]ap.Attachment{}, r.User.Attachment[:i]...), r.User.Attachment[i+1:]...)
+	h.setFields(w, r, fields)
+}
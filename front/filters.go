@@ -0,0 +1,282 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/front/text/plain"
+)
+
+// Filter contexts
+const (
+	filterFeed          = "feed"
+	filterNotifications = "notifications"
+	filterThreads       = "threads"
+)
+
+const maxFilterPhraseLength = 100
+
+var filterDurationRegex = regexp.MustCompile(`^(\d{1,3})([hd])$`)
+
+type filter struct {
+	Phrase string
+}
+
+// getFilters returns the active filters of a user that collapse posts in a given context.
+//
+// Filters that hide posts are applied by the queries that fetch posts, so they don't make pages shorter.
+func (h *Handler) getFilters(r *Request, context string) []filter {
+	if r.User == nil || context == "" {
+		return nil
+	}
+
+	rows, err := h.DB.QueryContext(
+		r.Context,
+		`select phrase from filters where actor = $1 and action = 'collapse' and (expires is null or expires > unixepoch()) and ((feed = 1 and $2 = 'feed') or (notifications = 1 and $2 = 'notifications') or (threads = 1 and $2 = 'threads'))`,
+		r.User.ID,
+		context,
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch filters", "context", context, "error", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var filters []filter
+	for rows.Next() {
+		var f filter
+		if err := rows.Scan(&f.Phrase); err != nil {
+			r.Log.Warn("Failed to scan filter", "error", err)
+			continue
+		}
+		f.Phrase = strings.ToLower(f.Phrase)
+		filters = append(filters, f)
+	}
+
+	return filters
+}
+
+// matchFilter returns the first filter that matches a post.
+func matchFilter(filters []filter, note *ap.Object) *filter {
+	if len(filters) == 0 {
+		return nil
+	}
+
+	content, _ := plain.FromHTML(note.Content)
+	s := strings.ToLower(strings.Join([]string{note.Name, note.Summary, content}, "\n"))
+
+	for i := range filters {
+		if strings.Contains(s, filters[i].Phrase) {
+			return &filters[i]
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) filters(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context, `select id, phrase, feed, notifications, threads, action, expires from filters where actor = ? and (expires is null or expires > unixepoch()) order by inserted`, r.User.ID)
+	if err != nil {
+		r.Log.Warn("Failed to list filters", "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+	w.Title("🙈 Filters")
+
+	count := 0
+	for rows.Next() {
+		var id int64
+		var phrase, action string
+		var feed, notifications, threads bool
+		var expires sql.NullInt64
+		if err := rows.Scan(&id, &phrase, &feed, &notifications, &threads, &action, &expires); err != nil {
+			r.Log.Warn("Failed to scan filter", "error", err)
+			continue
+		}
+
+		var contexts []string
+		if feed {
+			contexts = append(contexts, filterFeed)
+		}
+		if notifications {
+			contexts = append(contexts, filterNotifications)
+		}
+		if threads {
+			contexts = append(contexts, filterThreads)
+		}
+
+		if expires.Valid {
+			w.Itemf("%s: %s in %s, until %s", phrase, action, strings.Join(contexts, ", "), time.Unix(expires.Int64, 0).UTC().Format(time.DateTime))
+		} else {
+			w.Itemf("%s: %s in %s", phrase, action, strings.Join(contexts, ", "))
+		}
+		w.Linkf("/users/filters/remove/"+strconv.FormatInt(id, 10), "➖ Remove %s", phrase)
+
+		count++
+	}
+
+	if count == 0 {
+		w.Text("No filters.")
+	}
+
+	if count < h.Config.MaxFiltersPerUser {
+		w.Empty()
+		w.Link("/users/filters/add/hide", "🙈 Hide posts")
+		w.Link("/users/filters/add/collapse", "🫣 Collapse posts")
+	}
+
+	w.Subtitle("Syntax")
+	w.Text("A filter hides or collapses posts that contain a word or a phrase, in your feed, mentions and replies:")
+	w.Raw("Filter example", "spoiler")
+	w.Text("To apply a filter only in some places, or make it expire after a number of hours or days:")
+	w.Raw("Filter example with contexts and duration", "[feed threads 12h] spoiler")
+	w.Text("Contexts are feed (your feed and the local feed), notifications (mentions) and threads (replies).")
+}
+
+func (h *Handler) addFilter(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	var count int
+	if err := h.DB.QueryRowContext(r.Context, `select count(*) from filters where actor = ? and (expires is null or expires > unixepoch())`, r.User.ID).Scan(&count); err != nil {
+		r.Log.Warn("Failed to count filters", "error", err)
+		w.Error()
+		return
+	}
+
+	if count >= h.Config.MaxFiltersPerUser {
+		w.Status(40, "Reached filters limit")
+		return
+	}
+
+	input, ok := readQuery(w, r, "Word or phrase (e.g. [feed 1d] spoiler)")
+	if !ok {
+		return
+	}
+
+	phrase := strings.TrimSpace(input)
+	feed, notifications, threads := true, true, true
+	var expires sql.NullInt64
+
+	if strings.HasPrefix(phrase, "[") {
+		options, rest, ok := strings.Cut(phrase[1:], "]")
+		if !ok {
+			w.Status(40, "Invalid filter")
+			return
+		}
+
+		phrase = strings.TrimSpace(rest)
+
+		if fields := strings.FieldsFunc(options, func(r rune) bool { return r == ' ' || r == ',' }); len(fields) > 0 {
+			feed, notifications, threads = false, false, false
+
+			for _, field := range fields {
+				switch field {
+				case filterFeed:
+					feed = true
+
+				case filterNotifications:
+					notifications = true
+
+				case filterThreads:
+					threads = true
+
+				default:
+					m := filterDurationRegex.FindStringSubmatch(field)
+					if m == nil || expires.Valid {
+						w.Status(40, "Invalid filter")
+						return
+					}
+
+					n, _ := strconv.ParseInt(m[1], 10, 64)
+					duration := time.Hour * time.Duration(n)
+					if m[2] == "d" {
+						duration *= 24
+					}
+
+					expires = sql.NullInt64{Int64: time.Now().Add(duration).Unix(), Valid: true}
+				}
+			}
+
+			// a duration without contexts applies everywhere
+			if !feed && !notifications && !threads {
+				feed, notifications, threads = true, true, true
+			}
+		}
+	}
+
+	if phrase == "" {
+		w.Status(40, "Empty filter")
+		return
+	}
+
+	if utf8.RuneCountInString(phrase) > maxFilterPhraseLength {
+		w.Status(40, "Filter is too long")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into filters(actor, phrase, feed, notifications, threads, action, expires) values(?, ?, ?, ?, ?, ?, ?)`,
+		r.User.ID,
+		phrase,
+		feed,
+		notifications,
+		threads,
+		args[1],
+		expires,
+	); err != nil {
+		r.Log.Warn("Failed to add filter", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/filters")
+}
+
+func (h *Handler) removeFilter(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `delete from filters where id = ? and actor = ?`, args[1], r.User.ID); err != nil {
+		r.Log.Warn("Failed to remove filter", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/filters")
+}
@@ -18,6 +18,7 @@ limitations under the License.
 package finger
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
@@ -33,7 +34,9 @@ import (
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/cfg"
 	"github.com/dimkr/tootik/data"
+	"github.com/dimkr/tootik/front/text"
 	"github.com/dimkr/tootik/front/text/plain"
+	"github.com/dimkr/tootik/ratelimit"
 )
 
 type Listener struct {
@@ -43,13 +46,152 @@ type Listener struct {
 	Addr   string
 }
 
-func (fl *Listener) handle(ctx context.Context, conn net.Conn) {
+func (fl *Listener) writeLines(w io.Writer, s string) {
+	if fl.Config.FingerASCII {
+		s = text.StripEmoji(s)
+	}
+
+	for _, line := range strings.Split(s, "\n") {
+		if line == "" {
+			w.Write([]byte{'\r', '\n'})
+			continue
+		}
+
+		for _, wrapped := range text.WordWrap(line, fl.Config.FingerLineWidth, -1) {
+			w.Write([]byte(wrapped))
+			w.Write([]byte{'\r', '\n'})
+		}
+	}
+}
+
+func (fl *Listener) writeLinks(w io.Writer, s string, links data.OrderedMap[string, string]) {
+	for link, alt := range links.All() {
+		if !strings.Contains(s, link) {
+			if alt == "" {
+				fl.writeLines(w, link)
+			} else {
+				fl.writeLines(w, fmt.Sprintf("%s [%s]", link, alt))
+			}
+		}
+	}
+}
+
+func (fl *Listener) writePosts(ctx context.Context, log *slog.Logger, w io.Writer, actor *ap.Actor) (int, error) {
+	rows, err := fl.DB.QueryContext(ctx, `select object->>'$.content', inserted from notes where public = 1 and author = ? order by inserted desc limit ?`, actor.ID, fl.Config.FingerMaxPosts)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var content string
+		var inserted int64
+		if err := rows.Scan(&content, &inserted); err != nil {
+			log.Warn("Failed to parse post", "error", err)
+			continue
+		}
+
+		if count > 0 {
+			w.Write([]byte{'\r', '\n'})
+		}
+
+		body, links := plain.FromHTML(content)
+
+		w.Write([]byte(time.Unix(inserted, 0).Format(time.DateOnly)))
+		w.Write([]byte{'\r', '\n'})
+		fl.writeLines(w, body)
+		fl.writeLinks(w, body, links)
+
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func (fl *Listener) plan(ctx context.Context, log *slog.Logger, w io.Writer, actor *ap.Actor) {
+	summary, links := plain.FromHTML(actor.Summary)
+
+	var buf bytes.Buffer
+	posts, err := fl.writePosts(ctx, log, &buf, actor)
+	if err != nil {
+		log.Warn("Failed to query posts", "error", err)
+		return
+	}
+
+	fmt.Fprintf(w, "Login: %s\r\nPlan:\r\n", actor.PreferredUsername)
+
+	if summary != "" {
+		fl.writeLines(w, summary)
+	}
+	fl.writeLinks(w, summary, links)
+
+	if posts > 0 && (summary != "" || len(links) > 0) {
+		w.Write([]byte{'\r', '\n'})
+	}
+
+	w.Write(buf.Bytes())
+
+	if posts == 0 && summary == "" && len(links) == 0 {
+		w.Write([]byte("No Plan.\r\n"))
+	}
+}
+
+func (fl *Listener) outbox(ctx context.Context, log *slog.Logger, w io.Writer, actor *ap.Actor) {
+	var buf bytes.Buffer
+	posts, err := fl.writePosts(ctx, log, &buf, actor)
+	if err != nil {
+		log.Warn("Failed to query posts", "error", err)
+		return
+	}
+
+	fmt.Fprintf(w, "Login: %s\r\nPosts:\r\n", actor.PreferredUsername)
+	w.Write(buf.Bytes())
+
+	if posts == 0 {
+		w.Write([]byte("No posts.\r\n"))
+	}
+}
+
+func (fl *Listener) followers(ctx context.Context, log *slog.Logger, w io.Writer, actor *ap.Actor) {
+	var count int
+	if err := fl.DB.QueryRowContext(ctx, `select count(*) from follows where followed = ? and accepted = 1`, actor.ID).Scan(&count); err != nil {
+		log.Warn("Failed to count followers", "error", err)
+		return
+	}
+
+	rows, err := fl.DB.QueryContext(ctx, `select persons.actor->>'$.preferredUsername', persons.host from follows join persons on persons.id = follows.follower where follows.followed = ? and follows.accepted = 1 order by follows.inserted desc limit ?`, actor.ID, fl.Config.FingerMaxFollowers)
+	if err != nil {
+		log.Warn("Failed to query followers", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	fmt.Fprintf(w, "Login: %s\r\nFollowers: %d\r\n", actor.PreferredUsername, count)
+
+	for rows.Next() {
+		var name, host string
+		if err := rows.Scan(&name, &host); err != nil {
+			log.Warn("Failed to parse follower", "error", err)
+			continue
+		}
+
+		fl.writeLines(w, fmt.Sprintf("%s@%s", name, host))
+	}
+
+	if count > fl.Config.FingerMaxFollowers {
+		fmt.Fprintf(w, "And %d more.\r\n", count-fl.Config.FingerMaxFollowers)
+	}
+}
+
+// Handle handles a single Finger query.
+func (fl *Listener) Handle(ctx context.Context, conn net.Conn) {
 	if err := conn.SetDeadline(time.Now().Add(fl.Config.GuppyRequestTimeout)); err != nil {
 		slog.Warn("Failed to set deadline", "error", err)
 		return
 	}
 
-	req := make([]byte, 34)
+	req := make([]byte, 64+len(fl.Domain))
 	total := 0
 	for {
 		n, err := conn.Read(req[total:])
@@ -92,6 +234,12 @@ func (fl *Listener) handle(ctx context.Context, conn net.Conn) {
 		user = user[:sep]
 	}
 
+	user, query, _ := strings.Cut(user, "+")
+	if query != "" && query != "outbox" && query != "followers" {
+		log.Warn("Invalid query specified")
+		return
+	}
+
 	var actor ap.Actor
 	if err := fl.DB.QueryRowContext(ctx, `select actor from persons where actor->>'$.preferredUsername' = ? and host = ?`, user, fl.Domain).Scan(&actor); err != nil && errors.Is(err, sql.ErrNoRows) {
 		log.Info("User does not exist")
@@ -102,82 +250,13 @@ func (fl *Listener) handle(ctx context.Context, conn net.Conn) {
 		return
 	}
 
-	summary, links := plain.FromHTML(actor.Summary)
-
-	posts := data.OrderedMap[string, int64]{}
-
-	rows, err := fl.DB.QueryContext(ctx, `select object->>'$.content', inserted from notes where public = 1 and author = ? order by inserted desc limit 5`, actor.ID)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		log.Warn("Failed to query posts", "error", err)
-		return
-	} else if err == nil {
-		for rows.Next() {
-			var content string
-			var inserted int64
-			if err := rows.Scan(&content, &inserted); err != nil {
-				log.Warn("Failed to parse post", "error", err)
-				continue
-			}
-			posts.Store(content, inserted)
-		}
-
-		rows.Close()
-	}
-
-	fmt.Fprintf(conn, "Login: %s\r\nPlan:\r\n", user)
-
-	for _, line := range strings.Split(summary, "\n") {
-		conn.Write([]byte(line))
-		conn.Write([]byte{'\r', '\n'})
-	}
-
-	for link, alt := range links.All() {
-		if !strings.Contains(summary, link) {
-			if alt == "" {
-				conn.Write([]byte(link))
-			} else {
-				fmt.Fprintf(conn, "%s [%s]", link, alt)
-			}
-			conn.Write([]byte{'\r', '\n'})
-		}
-	}
-
-	if summary != "" || len(links) > 0 {
-		conn.Write([]byte{'\r', '\n'})
-	}
-
-	i := 0
-	last := len(posts) - 1
-	for content, inserted := range posts.All() {
-		text, links := plain.FromHTML(content)
-
-		conn.Write([]byte(time.Unix(inserted, 0).Format(time.DateOnly)))
-		conn.Write([]byte{'\r', '\n'})
-		for _, line := range strings.Split(text, "\n") {
-			conn.Write([]byte(line))
-			conn.Write([]byte{'\r', '\n'})
-		}
-
-		for link, alt := range links.All() {
-			if !strings.Contains(text, link) {
-				if alt == "" {
-					conn.Write([]byte(link))
-				} else {
-					fmt.Fprintf(conn, "%s [%s]", link, alt)
-				}
-				conn.Write([]byte{'\r', '\n'})
-			}
-		}
-
-		if i < last {
-			conn.Write([]byte{'\r', '\n'})
-		}
-
-		i++
-	}
-
-	if len(posts) == 0 && summary == "" && len(links) == 0 {
-		conn.Write([]byte("No Plan.\r\n"))
+	switch query {
+	case "outbox":
+		fl.outbox(ctx, log, conn, &actor)
+	case "followers":
+		fl.followers(ctx, log, conn, &actor)
+	default:
+		fl.plan(ctx, log, conn, &actor)
 	}
 }
 
@@ -203,6 +282,8 @@ func (fl *Listener) ListenAndServe(ctx context.Context) error {
 		wg.Done()
 	}()
 
+	limiter := ratelimit.New(fl.Config)
+
 	conns := make(chan net.Conn)
 
 	wg.Add(1)
@@ -223,14 +304,21 @@ func (fl *Listener) ListenAndServe(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 		case conn := <-conns:
+			if err := limiter.Acquire(conn.RemoteAddr()); err != nil {
+				slog.Debug("Rejecting connection", "from", conn.RemoteAddr(), "error", err)
+				conn.Close()
+				continue
+			}
+
 			requestCtx, cancelRequest := context.WithTimeout(ctx, fl.Config.GuppyRequestTimeout)
 
 			timer := time.AfterFunc(fl.Config.GuppyRequestTimeout, cancelRequest)
 
 			wg.Add(1)
 			go func() {
-				fl.handle(requestCtx, conn)
+				fl.Handle(requestCtx, conn)
 				conn.Close()
+				limiter.Release(conn.RemoteAddr())
 				timer.Stop()
 				cancelRequest()
 				wg.Done()
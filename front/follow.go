@@ -27,6 +27,12 @@ func (h *Handler) follow(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
+	if r.User.MovedTo != "" {
+		r.Log.Warn("Moved user cannot follow users", "movedTo", r.User.MovedTo)
+		w.Status(40, "Account was moved to "+r.User.MovedTo)
+		return
+	}
+
 	followed := "https://" + args[1]
 
 	var exists int
@@ -42,6 +48,18 @@ func (h *Handler) follow(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
+	var banned int
+	if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from bans where community = ? and actor = ?)`, followed, r.User.ID).Scan(&banned); err != nil {
+		r.Log.Warn("Failed to check if user is banned", "followed", followed, "error", err)
+		w.Error()
+		return
+	}
+
+	if banned == 1 {
+		w.Status(40, "Banned from community")
+		return
+	}
+
 	var follows int
 	if err := h.DB.QueryRowContext(r.Context, `select count(*) from follows where follower = ?`, r.User.ID).Scan(&follows); err != nil {
 		r.Log.Warn("Failed to count follows", "error", err)
@@ -0,0 +1,160 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/front/text/plain"
+)
+
+var (
+	hashtagLinkRegex = regexp.MustCompile(`^(?:/users|gemini://[^/]+)?/hashtag/([a-zA-Z0-9]+)$`)
+	outboxLinkRegex  = regexp.MustCompile(`^(?:/users|gemini://[^/]+)?/outbox/(\S+)$`)
+)
+
+// getPostFormat returns the posting format of a user: plain text or gemtext.
+func (h *Handler) getPostFormat(r *Request) (string, error) {
+	var format sql.NullString
+	if err := h.DB.QueryRowContext(r.Context, `select format from settings where actor = ?`, r.User.ID).Scan(&format); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if format.Valid {
+		return format.String, nil
+	}
+
+	return "plain", nil
+}
+
+func (h *Handler) postFormat(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	// plain text is the default
+	var format sql.NullString
+	if args[1] != "plain" {
+		format = sql.NullString{String: args[1], Valid: true}
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, format) values($1, $2) on conflict(actor) do update set format = $2`,
+		r.User.ID,
+		format,
+	); err != nil {
+		r.Log.Warn("Failed to set posting format", "format", args[1], "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+// resolveLinks replaces local links in gemtext link lines with absolute URLs, and returns a Hashtag or a Mention tag for each link to a hashtag or a user.
+func (h *Handler) resolveLinks(r *Request, content string) (string, []ap.Tag) {
+	var tags []ap.Tag
+
+	lines := strings.Split(content, "\n")
+
+	type linkLine struct {
+		Index   int
+		Link    string
+		Label   string
+		Hashtag string
+		ActorID string
+	}
+
+	var links []linkLine
+	var actorIDs []string
+
+	for i, line := range lines {
+		link, label, ok := plain.ParseLink(line)
+		if !ok {
+			continue
+		}
+
+		if m := hashtagLinkRegex.FindStringSubmatch(link); m != nil {
+			links = append(links, linkLine{Index: i, Link: fmt.Sprintf("gemini://%s/hashtag/%s", h.Domain, m[1]), Label: label, Hashtag: m[1]})
+			continue
+		}
+
+		actorID := link
+		if m := outboxLinkRegex.FindStringSubmatch(link); m != nil {
+			actorID = "https://" + m[1]
+		}
+
+		links = append(links, linkLine{Index: i, Link: link, Label: label, ActorID: actorID})
+		actorIDs = append(actorIDs, actorID)
+	}
+
+	// all links to users are resolved using a single query
+	actors := map[string]*ap.Actor{}
+	if len(actorIDs) > 0 {
+		ids, err := json.Marshal(actorIDs)
+		if err != nil {
+			r.Log.Warn("Failed to check if links point to users", "error", err)
+		} else if rows, err := h.DB.QueryContext(r.Context, `select id, actor from persons where id in (select value from json_each(?))`, string(ids)); err != nil {
+			r.Log.Warn("Failed to check if links point to users", "error", err)
+		} else {
+			for rows.Next() {
+				var id string
+				var actor ap.Actor
+				if err := rows.Scan(&id, &actor); err != nil {
+					r.Log.Warn("Failed to check if link points to a user", "error", err)
+					continue
+				}
+				actors[id] = &actor
+			}
+			rows.Close()
+		}
+	}
+
+	for _, l := range links {
+		link := l.Link
+
+		if l.Hashtag != "" {
+			tags = append(tags, ap.Tag{Type: ap.Hashtag, Name: "#" + l.Hashtag, Href: link})
+		} else {
+			if actor, ok := actors[l.ActorID]; ok {
+				link = actor.ID
+				tags = append(tags, ap.Tag{Type: ap.Mention, Name: fmt.Sprintf("@%s@%s", actor.PreferredUsername, strings.Split(strings.TrimPrefix(actor.ID, "https://"), "/")[0]), Href: link})
+			} else if strings.HasPrefix(link, "/users/") {
+				link = fmt.Sprintf("gemini://%s%s", h.Domain, strings.TrimPrefix(link, "/users"))
+			} else if strings.HasPrefix(link, "/") {
+				link = fmt.Sprintf("gemini://%s%s", h.Domain, link)
+			}
+		}
+
+		if l.Label == "" {
+			lines[l.Index] = "=> " + link
+		} else {
+			lines[l.Index] = fmt.Sprintf("=> %s %s", link, l.Label)
+		}
+	}
+
+	return strings.Join(lines, "\n"), tags
+}
@@ -69,13 +69,14 @@ func (h *Handler) fts(w text.Writer, r *Request, args ...string) {
 					groups.actor->>'$.type' = 'Group' and exists (select 1 from shares where shares.by = groups.id and shares.note = notes.id)
 				where
 					notes.public = 1 and
-					notesfts.content match $1
+					notesfts.content match $1 and
+					not exists (select 1 from domainblocks where domainblocks.severity = 'silence' and (domainblocks.host = notes.host or notes.host like '%.' || domainblocks.host))
 				order by rank desc
 				limit $2
 				offset $3
 			`,
 			query,
-			h.Config.PostsPerPage,
+			h.postsPerPage(r),
 			offset,
 		)
 	} else {
@@ -90,7 +91,8 @@ func (h *Handler) fts(w text.Writer, r *Request, args ...string) {
 						notes.id = notesfts.id
 					where
 						notes.public = 1 and
-						notesfts.content match $1
+						notesfts.content match $1 and
+						not exists (select 1 from domainblocks where domainblocks.severity = 'silence' and (domainblocks.host = notes.host or notes.host like '%.' || domainblocks.host))
 					union all
 					select notes.id, notes.object, notes.author, notes.inserted, rank, 1 as aud from
 					follows
@@ -141,7 +143,7 @@ func (h *Handler) fts(w text.Writer, r *Request, args ...string) {
 			`,
 			query,
 			r.User.ID,
-			h.Config.PostsPerPage,
+			h.postsPerPage(r),
 			offset,
 		)
 	}
@@ -154,23 +156,23 @@ func (h *Handler) fts(w text.Writer, r *Request, args ...string) {
 	w.OK()
 
 	if offset > 0 {
-		w.Titlef("🔎 Search Results for '%s' (%d-%d)", query, offset, offset+h.Config.PostsPerPage)
+		w.Titlef("🔎 Search Results for '%s' (%d-%d)", query, offset, offset+h.postsPerPage(r))
 	} else {
 		w.Titlef("🔎 Search Results for '%s'", query)
 	}
 
-	count := h.PrintNotes(w, r, rows, true, false, "No results.")
+	count := h.PrintNotes(w, r, rows, true, false, nil, "No results.")
 	rows.Close()
 
-	if offset > 0 || count == h.Config.PostsPerPage {
+	if offset > 0 || count == h.postsPerPage(r) {
 		w.Separator()
 	}
 
-	if offset >= h.Config.PostsPerPage {
-		w.Linkf(fmt.Sprintf("%s?%s", r.URL.Path, url.PathEscape(fmt.Sprintf("%s skip %d", query, offset-h.Config.PostsPerPage))), "Previous page (%d-%d)", offset-h.Config.PostsPerPage, offset)
+	if offset >= h.postsPerPage(r) {
+		w.Linkf(fmt.Sprintf("%s?%s", r.URL.Path, url.PathEscape(fmt.Sprintf("%s skip %d", query, offset-h.postsPerPage(r)))), "Previous page (%d-%d)", offset-h.postsPerPage(r), offset)
 	}
 
-	if count == h.Config.PostsPerPage {
-		w.Linkf(fmt.Sprintf("%s?%s", r.URL.Path, url.PathEscape(fmt.Sprintf("%s skip %d", query, offset+h.Config.PostsPerPage))), "Next page (%d-%d)", offset+h.Config.PostsPerPage, offset+2*h.Config.PostsPerPage)
+	if count == h.postsPerPage(r) {
+		w.Linkf(fmt.Sprintf("%s?%s", r.URL.Path, url.PathEscape(fmt.Sprintf("%s skip %d", query, offset+h.postsPerPage(r)))), "Next page (%d-%d)", offset+h.postsPerPage(r), offset+2*h.postsPerPage(r))
 	}
 }
@@ -0,0 +1,70 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gemini
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+)
+
+const truncationNotice = "\n(Response truncated)\n"
+
+var errBudgetExceeded = errors.New("response exceeds render budget")
+
+// budgetWriter stops a response that grows too big or takes too long to render, so a request for a huge page doesn't
+// hog memory and database connections.
+type budgetWriter struct {
+	inner     *tls.Conn
+	cancel    context.CancelFunc
+	remaining int64
+	deadline  time.Time
+	written   bool
+	exceeded  bool
+}
+
+func (w *budgetWriter) Write(p []byte) (int, error) {
+	if w.exceeded {
+		return 0, errBudgetExceeded
+	}
+
+	if int64(len(p)) <= w.remaining && time.Now().Before(w.deadline) {
+		w.remaining -= int64(len(p))
+		w.written = true
+		return w.inner.Write(p)
+	}
+
+	w.exceeded = true
+
+	// stop database queries that render the rest of the response
+	w.cancel()
+
+	// if the status line hasn't been sent yet, we can still tell the client why the response is empty
+	if w.written {
+		w.inner.Write([]byte(truncationNotice))
+	} else {
+		w.inner.Write([]byte("40 Response is too big\r\n"))
+	}
+
+	return 0, errBudgetExceeded
+}
+
+// ConnectionState returns the state of the underlying TLS connection.
+func (w *budgetWriter) ConnectionState() tls.ConnectionState {
+	return w.inner.ConnectionState()
+}
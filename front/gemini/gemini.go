@@ -26,19 +26,24 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net"
 	"net/url"
 	"sync"
 	"time"
 
 	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/certs"
 	"github.com/dimkr/tootik/cfg"
 	"github.com/dimkr/tootik/data"
 	"github.com/dimkr/tootik/front"
 	"github.com/dimkr/tootik/front/text/gmi"
 	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/ratelimit"
 )
 
+const slowDownTimeout = time.Second * 5
+
 type Listener struct {
 	Domain   string
 	Config   *cfg.Config
@@ -47,6 +52,7 @@ type Listener struct {
 	Addr     string
 	CertPath string
 	KeyPath  string
+	GenCert  bool
 }
 
 func (gl *Listener) getUser(ctx context.Context, tlsConn *tls.Conn) (*ap.Actor, httpsig.Key, error) {
@@ -131,6 +137,17 @@ func (gl *Listener) Handle(ctx context.Context, conn net.Conn) {
 		}
 	}
 
+	// stop database queries and reading of the request body (i.e. a Titan upload) once the render budget is exceeded,
+	// even if nothing is written
+	deadline := time.Now().Add(gl.Config.GeminiRenderBudget)
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		slog.Warn("Failed to set deadline", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
 	r := front.Request{
 		Context: ctx,
 		Body:    conn,
@@ -143,7 +160,14 @@ func (gl *Listener) Handle(ctx context.Context, conn net.Conn) {
 		return
 	}
 
-	w := gmi.Wrap(conn)
+	budget := budgetWriter{
+		inner:     tlsConn,
+		cancel:    cancel,
+		remaining: gl.Config.MaxGeminiResponseSize,
+		deadline:  deadline,
+	}
+
+	w := gmi.Wrap(&budget)
 	defer w.Flush()
 
 	r.User, r.Key, err = gl.getUser(ctx, tlsConn)
@@ -173,19 +197,49 @@ func (gl *Listener) Handle(ctx context.Context, conn net.Conn) {
 	}
 
 	gl.Handler.Handle(&r, w)
+
+	// the response is streamed while it's rendered: wait until it's sent before checking if it was truncated
+	w.Flush()
+	if budget.exceeded {
+		r.Log.Warn("Truncated response", "error", errBudgetExceeded)
+	}
+}
+
+// slowDown responds with status 44 without reading the request.
+func (gl *Listener) slowDown(ctx context.Context, conn net.Conn) {
+	if err := conn.SetDeadline(time.Now().Add(slowDownTimeout)); err != nil {
+		return
+	}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return
+	}
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return
+	}
+
+	fmt.Fprintf(conn, "44 %d\r\n", max(1, int(math.Ceil(gl.Config.ConnectionInterval.Seconds()))))
 }
 
 // ListenAndServe handles Gemini requests.
 func (gl *Listener) ListenAndServe(ctx context.Context) error {
-	cert, err := tls.LoadX509KeyPair(gl.CertPath, gl.KeyPath)
+	if gl.GenCert {
+		if err := gl.generateCertificate(); err != nil {
+			return fmt.Errorf("failed to generate certificate: %w", err)
+		}
+	}
+
+	pair, err := certs.Load(gl.CertPath, gl.KeyPath)
 	if err != nil {
 		return err
 	}
 
 	config := tls.Config{
-		Certificates: []tls.Certificate{cert},
-		MinVersion:   tls.VersionTLS12,
-		ClientAuth:   tls.RequestClientCert,
+		GetCertificate: pair.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		ClientAuth:     tls.RequestClientCert,
 	}
 	l, err := tls.Listen("tcp", gl.Addr, &config)
 	if err != nil {
@@ -194,6 +248,14 @@ func (gl *Listener) ListenAndServe(ctx context.Context) error {
 
 	var wg sync.WaitGroup
 
+	wg.Add(1)
+	go func() {
+		if err := pair.Watch(ctx); err != nil {
+			slog.Warn("Failed to watch certificate", "error", err)
+		}
+		wg.Done()
+	}()
+
 	wg.Add(1)
 	go func() {
 		<-ctx.Done()
@@ -201,6 +263,8 @@ func (gl *Listener) ListenAndServe(ctx context.Context) error {
 		wg.Done()
 	}()
 
+	limiter := ratelimit.New(gl.Config)
+
 	conns := make(chan net.Conn)
 
 	wg.Add(1)
@@ -221,6 +285,31 @@ func (gl *Listener) ListenAndServe(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 		case conn := <-conns:
+			if err := limiter.Acquire(conn.RemoteAddr()); errors.Is(err, ratelimit.ErrTooManyConnections) {
+				slog.Debug("Rejecting connection", "from", conn.RemoteAddr(), "error", err)
+				conn.Close()
+				continue
+			} else if err != nil {
+				// the TLS handshake is expensive, so it counts towards MaxConnections and only a few run at once
+				if !limiter.Reserve() {
+					slog.Debug("Rejecting connection", "from", conn.RemoteAddr(), "error", err)
+					conn.Close()
+					continue
+				}
+
+				slog.Debug("Asking client to slow down", "from", conn.RemoteAddr(), "error", err)
+
+				wg.Add(1)
+				go func() {
+					gl.slowDown(ctx, conn)
+					conn.Close()
+					limiter.Unreserve()
+					wg.Done()
+				}()
+
+				continue
+			}
+
 			requestCtx, cancelRequest := context.WithTimeout(ctx, gl.Config.GeminiRequestTimeout)
 
 			timer := time.AfterFunc(gl.Config.GeminiRequestTimeout, cancelRequest)
@@ -235,6 +324,7 @@ func (gl *Listener) ListenAndServe(ctx context.Context) error {
 			wg.Add(1)
 			go func() {
 				gl.Handle(requestCtx, conn)
+				limiter.Release(conn.RemoteAddr())
 				timer.Stop()
 				cancelRequest()
 				wg.Done()
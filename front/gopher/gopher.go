@@ -18,20 +18,34 @@ limitations under the License.
 package gopher
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"database/sql"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/url"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/data"
 	"github.com/dimkr/tootik/front"
+	"github.com/dimkr/tootik/front/text"
 	"github.com/dimkr/tootik/front/text/gmap"
+	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/ratelimit"
 )
 
+var tokenRegex = regexp.MustCompile(`^(/u/([0-9a-f]{32}))(/.*)?$`)
+
 type Listener struct {
 	Domain  string
 	Config  *cfg.Config
@@ -39,13 +53,95 @@ type Listener struct {
 	Addr    string
 }
 
-func (gl *Listener) handle(ctx context.Context, conn net.Conn) {
+// writer turns Gemini input prompts into Gopher search items.
+type writer struct {
+	text.Writer
+	Domain   string
+	Selector string
+	ASCII    bool
+}
+
+func (w *writer) Status(code int, meta string) {
+	if code == 10 {
+		if w.ASCII {
+			meta = text.ToASCII(meta)
+		}
+		fmt.Fprintf(w, "7%s\t%s\t%s\t70\r\n", meta, w.Selector, w.Domain)
+		return
+	}
+
+	w.Writer.Status(code, meta)
+}
+
+func (w *writer) Statusf(code int, format string, a ...any) {
+	w.Status(code, fmt.Sprintf(format, a...))
+}
+
+// prefixWriter prepends a prefix to selectors of /users pages.
+type prefixWriter struct {
+	io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.Index(w.buf, []byte{'\r', '\n'})
+		if i == -1 {
+			break
+		}
+
+		line := w.buf[:i+2]
+		if fields := bytes.SplitN(line, []byte{'\t'}, 3); len(fields) == 3 && bytes.HasPrefix(fields[1], []byte("/users")) {
+			line = slices.Concat(fields[0], []byte{'\t'}, []byte(w.prefix), fields[1], []byte{'\t'}, fields[2])
+		}
+
+		if _, err := w.Writer.Write(line); err != nil {
+			return 0, err
+		}
+
+		w.buf = w.buf[i+2:]
+	}
+
+	return len(p), nil
+}
+
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	_, err := w.Writer.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+func (gl *Listener) getUser(ctx context.Context, token string) (*ap.Actor, httpsig.Key, error) {
+	var actor ap.Actor
+	var privKeyPem string
+	if err := gl.Handler.DB.QueryRowContext(ctx, `select persons.actor, persons.privkey from gophertokens join persons on persons.id = gophertokens.actor where gophertokens.hash = ? and gophertokens.inserted > ? and persons.host = ?`, fmt.Sprintf("%X", sha256.Sum256([]byte(token))), time.Now().Add(-gl.Config.GopherTokenTTL).Unix(), gl.Domain).Scan(&actor, &privKeyPem); err != nil {
+		return nil, httpsig.Key{}, err
+	}
+
+	privKey, err := data.ParsePrivateKey(privKeyPem)
+	if err != nil {
+		return nil, httpsig.Key{}, fmt.Errorf("failed to parse private key for %s: %w", actor.ID, err)
+	}
+
+	return &actor, httpsig.Key{ID: actor.PublicKey.ID, PrivateKey: privKey}, nil
+}
+
+// Handle handles a Gopher request.
+func (gl *Listener) Handle(ctx context.Context, conn net.Conn) {
 	if err := conn.SetDeadline(time.Now().Add(gl.Config.GopherRequestTimeout)); err != nil {
 		slog.Warn("Failed to set deadline", "error", err)
 		return
 	}
 
-	req := make([]byte, 128)
+	// requests may contain a post, as the search string
+	req := make([]byte, 128+gl.Config.MaxPostsLength*4)
 	total := 0
 	for {
 		n, err := conn.Read(req[total:])
@@ -72,7 +168,7 @@ func (gl *Listener) handle(ctx context.Context, conn net.Conn) {
 		}
 	}
 
-	path := string(req[:total-2])
+	path, search, hasSearch := strings.Cut(string(req[:total-2]), "\t")
 	if path == "" {
 		path = "/"
 	}
@@ -82,6 +178,35 @@ func (gl *Listener) handle(ctx context.Context, conn net.Conn) {
 		Body:    conn,
 	}
 
+	var out io.Writer = conn
+
+	// private addresses of users start with /u/$token
+	if m := tokenRegex.FindStringSubmatch(path); m != nil {
+		user, key, err := gl.getUser(ctx, m[2])
+		if err != nil && errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("Invalid token")
+			w := gmap.Wrap(conn, gl.Domain, gl.Config)
+			w.Status(40, "Invalid token")
+			w.Flush()
+			return
+		} else if err != nil {
+			slog.Warn("Failed to fetch user", "error", err)
+			return
+		}
+
+		r.User = user
+		r.Key = key
+
+		path = m[3]
+		if path == "" {
+			path = "/users"
+		}
+
+		prefixed := &prefixWriter{Writer: conn, prefix: m[1]}
+		defer prefixed.Flush()
+		out = prefixed
+	}
+
 	var err error
 	r.URL, err = url.Parse(path)
 	if err != nil {
@@ -89,9 +214,22 @@ func (gl *Listener) handle(ctx context.Context, conn net.Conn) {
 		return
 	}
 
-	r.Log = slog.With(slog.Group("request", "path", r.URL.Path))
+	if hasSearch {
+		r.URL.RawQuery = url.QueryEscape(search)
+	}
+
+	if r.User == nil {
+		r.Log = slog.With(slog.Group("request", "path", r.URL.Path))
+	} else {
+		r.Log = slog.With(slog.Group("request", "path", r.URL.Path, "user", r.User.ID))
+	}
+
+	var inner text.Writer = gmap.Wrap(out, gl.Domain, gl.Config)
+	if gl.Config.GopherASCII {
+		inner = text.ASCII(inner)
+	}
 
-	w := gmap.Wrap(conn, gl.Domain, gl.Config)
+	w := &writer{Writer: inner, Domain: gl.Domain, Selector: r.URL.Path, ASCII: gl.Config.GopherASCII}
 	defer w.Flush()
 
 	gl.Handler.Handle(&r, w)
@@ -119,6 +257,8 @@ func (gl *Listener) ListenAndServe(ctx context.Context) error {
 		wg.Done()
 	}()
 
+	limiter := ratelimit.New(gl.Config)
+
 	conns := make(chan net.Conn)
 
 	wg.Add(1)
@@ -139,15 +279,22 @@ func (gl *Listener) ListenAndServe(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 		case conn := <-conns:
+			if err := limiter.Acquire(conn.RemoteAddr()); err != nil {
+				slog.Debug("Rejecting connection", "from", conn.RemoteAddr(), "error", err)
+				conn.Close()
+				continue
+			}
+
 			requestCtx, cancelRequest := context.WithTimeout(ctx, gl.Config.GopherRequestTimeout)
 
 			timer := time.AfterFunc(gl.Config.GopherRequestTimeout, cancelRequest)
 
 			wg.Add(1)
 			go func() {
-				gl.handle(requestCtx, conn)
+				gl.Handle(requestCtx, conn)
 				conn.Write([]byte(".\r\n"))
 				conn.Close()
+				limiter.Release(conn.RemoteAddr())
 				timer.Stop()
 				cancelRequest()
 				wg.Done()
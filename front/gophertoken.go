@@ -0,0 +1,115 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+func (h *Handler) gopherAccess(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	var inserted int64
+	if err := h.DB.QueryRowContext(r.Context, `select coalesce(max(inserted), 0) from gophertokens where actor = ?`, r.User.ID).Scan(&inserted); err != nil {
+		r.Log.Warn("Failed to check for Gopher access", "error", err)
+		w.Error()
+		return
+	}
+
+	w.OK()
+	w.Title("🐹 Gopher Access")
+
+	w.Text("A private Gopher address lets you read, post, reply and follow over Gopher, without a client certificate.")
+	w.Empty()
+	w.Text("Gopher is not encrypted: anyone who can see your traffic or knows this address can act on your behalf.")
+	w.Textf("To limit the damage if it leaks, the address expires after %s.", h.Config.GopherTokenTTL)
+	w.Empty()
+
+	if inserted == 0 || time.Since(time.Unix(inserted, 0)) >= h.Config.GopherTokenTTL {
+		w.Text("Gopher access is disabled.")
+		w.Empty()
+		w.Link("/users/gopher/generate", "🔑 Generate a private Gopher address")
+		return
+	}
+
+	w.Textf("Gopher access is enabled since %s, until %s.", time.Unix(inserted, 0).Format(time.DateOnly), time.Unix(inserted, 0).Add(h.Config.GopherTokenTTL).Format(time.DateOnly))
+	w.Empty()
+	w.Link("/users/gopher/generate", "🔑 Replace private Gopher address")
+	w.Link("/users/gopher/revoke", "🔴 Disable Gopher access")
+}
+
+func (h *Handler) generateGopherToken(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		r.Log.Warn("Failed to generate Gopher token", "error", err)
+		w.Error()
+		return
+	}
+
+	token := hex.EncodeToString(buf[:])
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into gophertokens(actor, hash) values($1, $2) on conflict(actor) do update set hash = $2, inserted = unixepoch()`,
+		r.User.ID,
+		fmt.Sprintf("%X", sha256.Sum256([]byte(token))),
+	); err != nil {
+		r.Log.Warn("Failed to save Gopher token", "error", err)
+		w.Error()
+		return
+	}
+
+	r.Log.Info("Generated Gopher token")
+
+	w.OK()
+	w.Title("🐹 Gopher Access")
+	w.Text("Your private Gopher address is:")
+	w.Empty()
+	w.Linkf(fmt.Sprintf("gopher://%s/1/u/%s/users", h.Domain, token), "gopher://%s/1/u/%s/users", h.Domain, token)
+	w.Empty()
+	w.Text("This address is shown only once. Keep it secret.")
+	w.Textf("It expires on %s.", time.Now().Add(h.Config.GopherTokenTTL).Format(time.DateOnly))
+}
+
+func (h *Handler) revokeGopherToken(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `delete from gophertokens where actor = ?`, r.User.ID); err != nil {
+		r.Log.Warn("Failed to revoke Gopher token", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/gopher")
+}
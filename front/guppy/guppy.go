@@ -33,6 +33,7 @@ import (
 	"github.com/dimkr/tootik/cfg"
 	"github.com/dimkr/tootik/front"
 	"github.com/dimkr/tootik/front/text/guppy"
+	"github.com/dimkr/tootik/ratelimit"
 )
 
 type Listener struct {
@@ -249,9 +250,16 @@ func (gl *Listener) ListenAndServe(ctx context.Context) error {
 		}
 	}()
 
-	sessions := make(map[string]chan []byte)
+	type session struct {
+		acks chan []byte
+		from net.Addr
+	}
+
+	sessions := make(map[string]session)
 	done := make(chan string, gl.Config.MaxGuppySessions)
 
+	limiter := ratelimit.New(gl.Config)
+
 loop:
 	for {
 		keepClosing := true
@@ -259,8 +267,9 @@ loop:
 		for keepClosing {
 			select {
 			case k := <-done:
-				acks := sessions[k]
-				close(acks)
+				s := sessions[k]
+				close(s.acks)
+				limiter.Release(s.from)
 				delete(sessions, k)
 
 			default:
@@ -278,9 +287,9 @@ loop:
 			}
 			k := pkt.From.String()
 
-			if acks, ok := sessions[k]; ok {
-				if len(acks) < gl.Config.MaxSentGuppyChunks {
-					acks <- pkt.Data
+			if s, ok := sessions[k]; ok {
+				if len(s.acks) < gl.Config.MaxSentGuppyChunks {
+					s.acks <- pkt.Data
 				}
 				continue
 			}
@@ -290,8 +299,14 @@ loop:
 				continue
 			}
 
+			if err := limiter.Acquire(pkt.From); err != nil {
+				slog.Debug("Asking client to slow down", "from", pkt.From, "error", err)
+				l.WriteTo([]byte("4 Slow down\r\n"), pkt.From)
+				continue
+			}
+
 			acks := make(chan []byte, gl.Config.MaxSentGuppyChunks)
-			sessions[k] = acks
+			sessions[k] = session{acks, pkt.From}
 
 			requestCtx, cancelRequest := context.WithTimeout(ctx, gl.Config.GuppyRequestTimeout)
 
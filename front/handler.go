@@ -20,9 +20,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
-	"sync"
-	"time"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/cfg"
@@ -37,6 +36,10 @@ type Handler struct {
 	Config   *cfg.Config
 	Resolver ap.Resolver
 	DB       *sql.DB
+	cache    *pageCache
+	counts   *remoteCountsCache
+
+	translationClient *http.Client
 }
 
 var (
@@ -53,17 +56,24 @@ func serveStaticFile(lines []string, w text.Writer, _ *Request, _ ...string) {
 }
 
 // NewHandler returns a new [Handler].
-func NewHandler(domain string, closed bool, cfg *cfg.Config, resolver ap.Resolver, db *sql.DB) (Handler, error) {
+// Read-only pages that don't show the results of a write by the user are served using readDB, which can be a read replica of db.
+func NewHandler(domain string, closed bool, cfg *cfg.Config, resolver ap.Resolver, db, readDB *sql.DB) (Handler, error) {
 	h := Handler{
 		handlers: map[*regexp.Regexp]func(text.Writer, *Request, ...string){},
 		Domain:   domain,
 		Config:   cfg,
 		Resolver: resolver,
 		DB:       db,
+		cache:    newPageCache(cfg.MaxCachedPages),
+		counts:   newRemoteCountsCache(),
+
+		translationClient: &http.Client{Timeout: cfg.TranslationTimeout},
 	}
-	var cache sync.Map
 
-	h.handlers[regexp.MustCompile(`^/$`)] = withUserMenu(h.home)
+	ro := h
+	ro.DB = readDB
+
+	h.handlers[regexp.MustCompile(`^/$`)] = withUserMenu(ro.home)
 
 	h.handlers[regexp.MustCompile(`^/users$`)] = withUserMenu(h.users)
 	if closed {
@@ -75,37 +85,88 @@ func NewHandler(domain string, closed bool, cfg *cfg.Config, resolver ap.Resolve
 	}
 
 	h.handlers[regexp.MustCompile(`^/users/mentions$`)] = withUserMenu(h.mentions)
+	h.handlers[regexp.MustCompile(`^/users/digest$`)] = withUserMenu(h.digest)
 
-	h.handlers[regexp.MustCompile(`^/local$`)] = withCache(withUserMenu(h.local), time.Minute*15, &cache)
-	h.handlers[regexp.MustCompile(`^/users/local$`)] = withCache(withUserMenu(h.local), time.Minute*15, &cache)
+	h.handlers[regexp.MustCompile(`^/local$`)] = withCache(withUserMenu(ro.local), cfg.LocalCacheTTL, h.cache)
+	h.handlers[regexp.MustCompile(`^/users/local$`)] = h.withFilters(withUserMenu(h.local), withCache(withUserMenu(h.local), cfg.LocalCacheTTL, h.cache))
 
-	h.handlers[regexp.MustCompile(`^/outbox/(\S+)$`)] = withUserMenu(h.userOutbox)
+	h.handlers[regexp.MustCompile(`^/outbox/(\S+)$`)] = withCache(withUserMenu(ro.userOutbox), cfg.OutboxCacheTTL, h.cache)
 	h.handlers[regexp.MustCompile(`^/users/outbox/(\S+)$`)] = withUserMenu(h.userOutbox)
 	h.handlers[regexp.MustCompile(`^/users/me$`)] = withUserMenu(me)
 
 	h.handlers[regexp.MustCompile(`^/users/upload/avatar;([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.uploadAvatar
+	h.handlers[regexp.MustCompile(`^/users/upload/header;([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.uploadHeader
 	h.handlers[regexp.MustCompile(`^/users/bio$`)] = h.bio
 	h.handlers[regexp.MustCompile(`^/users/upload/bio;([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.uploadBio
 	h.handlers[regexp.MustCompile(`^/users/name$`)] = h.name
+	h.handlers[regexp.MustCompile(`^/users/aliases$`)] = h.aliases
 	h.handlers[regexp.MustCompile(`^/users/alias$`)] = h.alias
+	h.handlers[regexp.MustCompile(`^/users/aliases/remove/(\d+)$`)] = h.removeAlias
+	h.handlers[regexp.MustCompile(`^/users/fields$`)] = h.fields
+	h.handlers[regexp.MustCompile(`^/users/language$`)] = h.language
+	h.handlers[regexp.MustCompile(`^/users/languages$`)] = h.languages
+	h.handlers[regexp.MustCompile(`^/users/languages/all$`)] = h.allLanguages
+	h.handlers[regexp.MustCompile(`^/users/fields/add$`)] = h.addField
+	h.handlers[regexp.MustCompile(`^/users/fields/remove/(\d+)$`)] = h.removeField
 	h.handlers[regexp.MustCompile(`^/users/move$`)] = h.move
 	h.handlers[regexp.MustCompile(`^/users/certificates$`)] = withUserMenu(h.certificates)
 	h.handlers[regexp.MustCompile(`^/users/certificates/approve/(\S+)$`)] = withUserMenu(h.approve)
 	h.handlers[regexp.MustCompile(`^/users/certificates/revoke/(\S+)$`)] = withUserMenu(h.revoke)
-
-	h.handlers[regexp.MustCompile(`^/view/(\S+)$`)] = withUserMenu(h.view)
+	h.handlers[regexp.MustCompile(`^/users/gopher$`)] = withUserMenu(h.gopherAccess)
+	h.handlers[regexp.MustCompile(`^/users/gopher/generate$`)] = withUserMenu(h.generateGopherToken)
+	h.handlers[regexp.MustCompile(`^/users/gopher/revoke$`)] = h.revokeGopherToken
+	h.handlers[regexp.MustCompile(`^/users/limits$`)] = withUserMenu(h.limits)
+	h.handlers[regexp.MustCompile(`^/users/invitations$`)] = withUserMenu(h.invitations)
+	h.handlers[regexp.MustCompile(`^/users/admin/federation$`)] = withUserMenu(h.federation)
+	h.handlers[regexp.MustCompile(`^/users/admin/federation/pause/(\S+)$`)] = h.pauseDomain
+	h.handlers[regexp.MustCompile(`^/users/admin/federation/resume/(\S+)$`)] = h.resumeDomain
+	h.handlers[regexp.MustCompile(`^/users/admin/rejections$`)] = withUserMenu(h.rejections)
+	h.handlers[regexp.MustCompile(`^/users/invitations/create$`)] = h.createInvitation
+
+	h.handlers[regexp.MustCompile(`^/view/(\S+)$`)] = withUserMenu(ro.view)
 	h.handlers[regexp.MustCompile(`^/users/view/(\S+)$`)] = withUserMenu(h.view)
 
-	h.handlers[regexp.MustCompile(`^/thread/(\S+)$`)] = withUserMenu(h.thread)
+	h.handlers[regexp.MustCompile(`^/read/(\S+)$`)] = withUserMenu(ro.read)
+	h.handlers[regexp.MustCompile(`^/users/read/(\S+)$`)] = withUserMenu(h.read)
+
+	h.handlers[regexp.MustCompile(`^/links/(\S+)$`)] = withUserMenu(ro.links)
+	h.handlers[regexp.MustCompile(`^/users/links/(\S+)$`)] = withUserMenu(h.links)
+
+	h.handlers[regexp.MustCompile(`^/thread/(\S+)$`)] = withUserMenu(ro.thread)
 	h.handlers[regexp.MustCompile(`^/users/thread/(\S+)$`)] = withUserMenu(h.thread)
 
+	h.handlers[regexp.MustCompile(`^/context/(\S+)$`)] = withUserMenu(ro.context)
+	h.handlers[regexp.MustCompile(`^/users/context/(\S+)$`)] = withUserMenu(h.context)
+
 	h.handlers[regexp.MustCompile(`^/users/dm$`)] = h.dm
 	h.handlers[regexp.MustCompile(`^/users/whisper$`)] = h.whisper
 	h.handlers[regexp.MustCompile(`^/users/say$`)] = h.say
+	h.handlers[regexp.MustCompile(`^/users/compose$`)] = h.compose
 
 	h.handlers[regexp.MustCompile(`^/users/reply/(\S+)`)] = h.reply
 
-	h.handlers[regexp.MustCompile(`^/users/share/(\S+)`)] = h.share
+	h.handlers[regexp.MustCompile(`^/users/share/(followers/)?(\S+)`)] = h.share
+	h.handlers[regexp.MustCompile(`^/users/translate/(\S+)$`)] = h.translate
+	h.handlers[regexp.MustCompile(`^/users/refresh/(\S+)$`)] = h.refreshPoll
+	h.handlers[regexp.MustCompile(`^/users/deliveries/(\S+)$`)] = withUserMenu(h.deliveries)
+	h.handlers[regexp.MustCompile(`^/users/rsvp/(accept|tentative|reject)/(\S+)$`)] = h.rsvp
+	h.handlers[regexp.MustCompile(`^/users/feed/(default|chronological|hashtags)$`)] = h.feedAlgorithm
+	h.handlers[regexp.MustCompile(`^/users/format/(plain|gemtext)$`)] = h.postFormat
+	h.handlers[regexp.MustCompile(`^/users/expiry$`)] = h.postExpiry
+	h.handlers[regexp.MustCompile(`^/users/audience/(public|followers)$`)] = h.defaultAudience
+	h.handlers[regexp.MustCompile(`^/users/cw$`)] = h.defaultSummary
+	h.handlers[regexp.MustCompile(`^/users/cw/clear$`)] = h.clearSummary
+	h.handlers[regexp.MustCompile(`^/users/tags$`)] = h.defaultHashtags
+	h.handlers[regexp.MustCompile(`^/users/tags/clear$`)] = h.clearHashtags
+	h.handlers[regexp.MustCompile(`^/users/replies/(everyone|followers|mentioned)$`)] = h.defaultReplies
+	h.handlers[regexp.MustCompile(`^/users/pagesize$`)] = h.pageSize
+	h.handlers[regexp.MustCompile(`^/users/shares/(show|hide)$`)] = h.setPreference("hideshares", "hide")
+	h.handlers[regexp.MustCompile(`^/users/timezone$`)] = h.timeZone
+	h.handlers[regexp.MustCompile(`^/users/timestamps/(absolute|relative)$`)] = h.setPreference("relativetime", "relative")
+	h.handlers[regexp.MustCompile(`^/users/emoji/(on|off)$`)] = h.setPreference("noemoji", "off")
+	h.handlers[regexp.MustCompile(`^/users/filters$`)] = withUserMenu(h.filters)
+	h.handlers[regexp.MustCompile(`^/users/filters/add/(hide|collapse)$`)] = h.addFilter
+	h.handlers[regexp.MustCompile(`^/users/filters/remove/(\d+)$`)] = h.removeFilter
 	h.handlers[regexp.MustCompile(`^/users/unshare/(\S+)`)] = h.unshare
 
 	h.handlers[regexp.MustCompile(`^/users/bookmark/(\S+)`)] = h.bookmark
@@ -118,33 +179,48 @@ func NewHandler(domain string, closed bool, cfg *cfg.Config, resolver ap.Resolve
 	h.handlers[regexp.MustCompile(`^/users/upload/dm;([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.uploadDM
 	h.handlers[regexp.MustCompile(`^/users/upload/whisper;([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.uploadWhisper
 	h.handlers[regexp.MustCompile(`^/users/upload/say;([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.uploadSay
+	h.handlers[regexp.MustCompile(`^/users/upload/compose;([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.uploadCompose
 	h.handlers[regexp.MustCompile(`^/users/upload/edit/([^;]+);([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.editUpload
 	h.handlers[regexp.MustCompile(`^/users/upload/reply/([^;]+);([a-z]+)=([^;]+);([a-z]+)=([^;]+)`)] = h.replyUpload
 
 	h.handlers[regexp.MustCompile(`^/users/resolve$`)] = withUserMenu(h.resolve)
+	h.handlers[regexp.MustCompile(`^/users/complete$`)] = h.complete
 
 	h.handlers[regexp.MustCompile(`^/users/follow/(\S+)$`)] = withUserMenu(h.follow)
 	h.handlers[regexp.MustCompile(`^/users/unfollow/(\S+)$`)] = withUserMenu(h.unfollow)
 
 	h.handlers[regexp.MustCompile(`^/users/follows$`)] = withUserMenu(h.follows)
 
-	h.handlers[regexp.MustCompile(`^/communities$`)] = withUserMenu(h.communities)
+	h.handlers[regexp.MustCompile(`^/communities$`)] = withUserMenu(ro.communities)
 	h.handlers[regexp.MustCompile(`^/users/communities$`)] = withUserMenu(h.communities)
+	h.handlers[regexp.MustCompile(`^/users/communities/create$`)] = withUserMenu(h.createCommunity)
+	h.handlers[regexp.MustCompile(`^/users/communities/manage/([a-zA-Z0-9-_]+)$`)] = withUserMenu(h.moderate)
+	h.handlers[regexp.MustCompile(`^/users/communities/ban/([a-zA-Z0-9-_]+)$`)] = withUserMenu(h.ban)
+	h.handlers[regexp.MustCompile(`^/users/communities/unban/([a-zA-Z0-9-_]+)$`)] = withUserMenu(h.unban)
+	h.handlers[regexp.MustCompile(`^/users/communities/remove/(\S+)$`)] = withUserMenu(h.remove)
+	h.handlers[regexp.MustCompile(`^/users/communities/pin/(\S+)$`)] = withUserMenu(h.pin)
+	h.handlers[regexp.MustCompile(`^/users/communities/unpin/([a-zA-Z0-9-_]+)$`)] = withUserMenu(h.unpin)
+
+	h.handlers[regexp.MustCompile(`^/hashtag/([a-zA-Z0-9]+)(?:/(recent|shared))?$`)] = withCache(withUserMenu(ro.hashtag), cfg.HashtagCacheTTL, h.cache)
+	h.handlers[regexp.MustCompile(`^/users/hashtag/([a-zA-Z0-9]+)(?:/(recent|shared))?$`)] = withUserMenu(h.hashtag)
+	h.handlers[regexp.MustCompile(`^/users/hashtag/([a-zA-Z0-9]+)/follow$`)] = h.followHashtag
+	h.handlers[regexp.MustCompile(`^/users/hashtag/([a-zA-Z0-9]+)/unfollow$`)] = h.unfollowHashtag
 
-	h.handlers[regexp.MustCompile(`^/hashtag/([a-zA-Z0-9]+)$`)] = withCache(withUserMenu(h.hashtag), time.Minute*5, &cache)
-	h.handlers[regexp.MustCompile(`^/users/hashtag/([a-zA-Z0-9]+)$`)] = withCache(withUserMenu(h.hashtag), time.Minute*5, &cache)
+	h.handlers[regexp.MustCompile(`^/hashtags$`)] = withCache(withUserMenu(ro.hashtags), cfg.HashtagsCacheTTL, h.cache)
+	h.handlers[regexp.MustCompile(`^/users/hashtags$`)] = withCache(withUserMenu(ro.hashtags), cfg.HashtagsCacheTTL, h.cache)
 
-	h.handlers[regexp.MustCompile(`^/hashtags$`)] = withCache(withUserMenu(h.hashtags), time.Minute*30, &cache)
-	h.handlers[regexp.MustCompile(`^/users/hashtags$`)] = withCache(withUserMenu(h.hashtags), time.Minute*30, &cache)
+	h.handlers[regexp.MustCompile(`^/search$`)] = withUserMenu(h.search)
+	h.handlers[regexp.MustCompile(`^/users/search$`)] = withUserMenu(h.search)
 
-	h.handlers[regexp.MustCompile(`^/search$`)] = withUserMenu(search)
-	h.handlers[regexp.MustCompile(`^/users/search$`)] = withUserMenu(search)
+	h.handlers[regexp.MustCompile(`^/fts$`)] = withUserMenu(ro.fts)
+	h.handlers[regexp.MustCompile(`^/users/fts$`)] = withUserMenu(ro.fts)
 
-	h.handlers[regexp.MustCompile(`^/fts$`)] = withUserMenu(h.fts)
-	h.handlers[regexp.MustCompile(`^/users/fts$`)] = withUserMenu(h.fts)
+	h.handlers[regexp.MustCompile(`^/rules$`)] = withUserMenu(h.rules)
+	h.handlers[regexp.MustCompile(`^/users/rules$`)] = withUserMenu(h.rules)
+	h.handlers[regexp.MustCompile(`^/users/rules/accept$`)] = h.acceptRules
 
-	h.handlers[regexp.MustCompile(`^/status$`)] = withCache(withUserMenu(h.status), time.Minute*5, &cache)
-	h.handlers[regexp.MustCompile(`^/users/status$`)] = withCache(withUserMenu(h.status), time.Minute*5, &cache)
+	h.handlers[regexp.MustCompile(`^/status$`)] = withCache(withUserMenu(ro.status), cfg.StatusCacheTTL, h.cache)
+	h.handlers[regexp.MustCompile(`^/users/status$`)] = withCache(withUserMenu(ro.status), cfg.StatusCacheTTL, h.cache)
 
 	h.handlers[regexp.MustCompile(`^/oops`)] = withUserMenu(oops)
 	h.handlers[regexp.MustCompile(`^/users/oops`)] = withUserMenu(oops)
@@ -170,6 +246,16 @@ func (h *Handler) Handle(r *Request, w text.Writer) {
 	for re, handler := range h.handlers {
 		m := re.FindStringSubmatch(r.URL.Path)
 		if m != nil {
+			prefs, err := h.loadPreferences(r)
+			if err != nil {
+				r.Log.Warn("Failed to load preferences", "error", err)
+			}
+			r.preferences = prefs
+
+			if prefs.NoEmoji {
+				w = text.ASCII(w)
+			}
+
 			handler(w, r, m...)
 			return
 		}
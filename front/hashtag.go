@@ -18,34 +18,130 @@ package front
 
 import (
 	"database/sql"
+	"fmt"
 
 	"github.com/dimkr/tootik/front/text"
 )
 
 func (h *Handler) hashtag(w text.Writer, r *Request, args ...string) {
 	tag := args[1]
+	sort := args[2]
+
+	// by default, posts with many recent replies are shown first
+	title := "Posts Tagged #" + tag
+	order := "replies.count desc, notes.inserted/(24*60*60) desc, notes.inserted desc"
+	if sort == "recent" {
+		title = "Recent Posts Tagged #" + tag
+		order = "notes.inserted desc"
+	} else if sort == "shared" {
+		title = "Most Shared Posts Tagged #" + tag
+		order = "(select count(*) from shares where shares.note = notes.id) desc, notes.inserted desc"
+	}
 
 	h.showFeedPage(
 		w,
 		r,
-		"Posts Tagged #"+tag,
+		title,
 		func(offset int) (*sql.Rows, error) {
 			return h.DB.QueryContext(
 				r.Context,
-				`select notes.object, persons.actor, null, notes.inserted from notes join hashtags on notes.id = hashtags.note left join (select object->>'$.inReplyTo' as id, count(*) as count from notes where inserted >= unixepoch() - 7*24*60*60 group by object->>'$.inReplyTo') replies on notes.id = replies.id left join persons on notes.author = persons.id where notes.public = 1 and hashtags.hashtag = $1 order by replies.count desc, notes.inserted/(24*60*60) desc, notes.inserted desc limit $2 offset $3`,
+				`select notes.object, persons.actor, null, notes.inserted from notes join hashtags on notes.id = hashtags.note left join (select object->>'$.inReplyTo' as id, count(*) as count from notes where inserted >= unixepoch() - 7*24*60*60 group by object->>'$.inReplyTo') replies on notes.id = replies.id left join persons on notes.author = persons.id where notes.public = 1 and hashtags.hashtag = $1 and not exists (select 1 from domainblocks where domainblocks.severity = 'silence' and (domainblocks.host = notes.host or notes.host like '%.' || domainblocks.host)) order by `+order+` limit $2 offset $3`,
 				tag,
-				h.Config.PostsPerPage,
+				h.postsPerPage(r),
 				offset,
 			)
 		},
 		false,
+		false,
+		"",
 	)
 
 	w.Separator()
 
+	keys := make([]string, 7)
+	values := make([]int64, 7)
+	if graph := h.getGraph(r, `select strftime('%Y-%m-%d', datetime(day*60*60*24, 'unixepoch')), count(*) from (select notes.inserted/(60*60*24) as day from notes join hashtags on notes.id = hashtags.note where notes.public = 1 and hashtags.hashtag = ? and notes.inserted > unixepoch()-60*60*24*7 and notes.inserted < unixepoch()/(60*60*24)*(60*60*24) and not exists (select 1 from domainblocks where domainblocks.severity = 'silence' and (domainblocks.host = notes.host or notes.host like '%.' || domainblocks.host))) group by day order by day`, keys, values, tag); graph != "" {
+		w.Subtitle("Posts Per Day")
+		w.Raw("Posts per day graph", graph)
+		w.Empty()
+	}
+
+	prefix := "/hashtag/"
+	if r.User != nil {
+		prefix = "/users/hashtag/"
+	}
+
+	if sort != "" {
+		w.Link(prefix+tag, "💬 Most discussed")
+	}
+	if sort != "recent" {
+		w.Link(prefix+tag+"/recent", "⏳ Most recent")
+	}
+	if sort != "shared" {
+		w.Link(prefix+tag+"/shared", "♻️ Most shared")
+	}
+
+	w.Linkf(fmt.Sprintf("https://%s/hashtag/%s/rss", h.Domain, tag), "🗞️ RSS feed of #%s", tag)
+
 	if r.User == nil {
 		w.Link("/search", "🔎 Posts by hashtag")
+		return
+	}
+
+	var followed int
+	if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from followed_hashtags where follower = ? and hashtag = ?)`, r.User.ID, tag).Scan(&followed); err != nil {
+		r.Log.Warn("Failed to check if hashtag is followed", "hashtag", tag, "error", err)
+	} else if followed == 0 {
+		w.Linkf("/users/hashtag/"+tag+"/follow", "⚡ Follow #%s", tag)
 	} else {
-		w.Link("/users/search", "🔎 Posts by hashtag")
+		w.Linkf("/users/hashtag/"+tag+"/unfollow", "🔌 Unfollow #%s", tag)
+	}
+
+	w.Link("/users/search", "🔎 Posts by hashtag")
+}
+
+func (h *Handler) followHashtag(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
 	}
+
+	tag := args[1]
+
+	var followed int
+	if err := h.DB.QueryRowContext(r.Context, `select count(*) from followed_hashtags where follower = ?`, r.User.ID).Scan(&followed); err != nil {
+		r.Log.Warn("Failed to count followed hashtags", "error", err)
+		w.Error()
+		return
+	}
+
+	if followed >= h.Config.MaxFollowedHashtags {
+		w.Status(40, "Following too many hashtags")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `insert into followed_hashtags(follower, hashtag) values(?, ?) on conflict(follower, hashtag) do nothing`, r.User.ID, tag); err != nil {
+		r.Log.Warn("Failed to follow hashtag", "hashtag", tag, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/hashtag/" + tag)
+}
+
+func (h *Handler) unfollowHashtag(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	tag := args[1]
+
+	if _, err := h.DB.ExecContext(r.Context, `delete from followed_hashtags where follower = ? and hashtag = ?`, r.User.ID, tag); err != nil {
+		r.Log.Warn("Failed to unfollow hashtag", "hashtag", tag, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/hashtag/" + tag)
 }
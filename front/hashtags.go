@@ -107,7 +107,8 @@ func (h *Handler) hashtags(w text.Writer, r *Request, args ...string) {
 					on
 						notes.id = hashtags.note
 					where
-						inserted > unixepoch()-60*60*24*7
+						inserted > unixepoch()-60*60*24*7 and
+						not exists (select 1 from domainblocks where domainblocks.severity = 'silence' and (domainblocks.host = notes.host or notes.host like '%.' || domainblocks.host))
 				)
 				group by
 					hashtag
@@ -138,7 +139,8 @@ func (h *Handler) hashtags(w text.Writer, r *Request, args ...string) {
 				on
 					notes.id = hashtags.note
 				where
-					inserted > (unixepoch()/86400-6)*86400
+					inserted > (unixepoch()/86400-6)*86400 and
+					not exists (select 1 from domainblocks where domainblocks.severity = 'silence' and (domainblocks.host = notes.host or notes.host like '%.' || domainblocks.host))
 				group by
 					day,
 					hashtag
@@ -0,0 +1,88 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/icon"
+	"github.com/dimkr/tootik/outbox"
+)
+
+func (h *Handler) uploadHeader(w text.Writer, r *Request, args ...string) {
+	buf, now, ok := h.readImage(w, r, args, h.Config.MaxHeaderSize)
+	if !ok {
+		return
+	}
+
+	resized, err := icon.ScaleHeader(h.Config, buf)
+	if err != nil {
+		r.Log.Warn("Failed to read header", "error", err)
+		w.Error()
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context, nil)
+	if err != nil {
+		r.Log.Warn("Failed to set header", "error", err)
+		w.Error()
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		r.Context,
+		"update persons set actor = json_set(actor, '$.image', json_object('type', 'Image', 'mediaType', $1, 'url', $2), '$.updated', $3) where id = $4",
+		icon.HeaderMediaType,
+		// we add fragment because some servers cache the image until the URL changes
+		fmt.Sprintf("https://%s/header/%s%s#%d", h.Domain, r.User.PreferredUsername, icon.HeaderFileNameExtension, now.UnixNano()),
+		now.Format(time.RFC3339Nano),
+		r.User.ID,
+	); err != nil {
+		r.Log.Error("Failed to set header", "error", err)
+		w.Error()
+		return
+	}
+
+	if _, err := tx.ExecContext(
+		r.Context,
+		"insert into headers(name, buf) values($1, $2) on conflict(name) do update set buf = $2",
+		r.User.PreferredUsername,
+		string(resized),
+	); err != nil {
+		r.Log.Error("Failed to set header", "error", err)
+		w.Error()
+		return
+	}
+
+	if err := outbox.UpdateActor(r.Context, h.Domain, tx, r.User.ID); err != nil {
+		r.Log.Error("Failed to set header", "error", err)
+		w.Error()
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Log.Error("Failed to set header", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirectf("gemini://%s/users/outbox/%s", h.Domain, strings.TrimPrefix(r.User.ID, "https://"))
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+func (h *Handler) invitations(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context, `select code, invited from invitations where inviter = ? order by inserted desc`, r.User.ID)
+	if err != nil {
+		r.Log.Warn("Failed to fetch invitations", "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+	w.Title("🎟️ Invitations")
+
+	if !h.Config.RequireInvitation {
+		w.Text("Registration doesn't require an invitation.")
+		w.Empty()
+	}
+
+	count := 0
+	for rows.Next() {
+		var code string
+		var invited sql.NullString
+		if err := rows.Scan(&code, &invited); err != nil {
+			r.Log.Warn("Failed to scan invitation", "error", err)
+			continue
+		}
+
+		if invited.Valid {
+			w.Itemf("%s: used by %s", code, invited.String)
+		} else {
+			w.Itemf("%s: unused", code)
+		}
+
+		count++
+	}
+
+	if count == 0 {
+		w.Text("No invitations.")
+	}
+
+	if count < h.Config.MaxInvitationsPerUser {
+		w.Empty()
+		w.Link("/users/invitations/create", "➕ Create invitation")
+	}
+
+	if h.Config.RequireInvitation {
+		w.Empty()
+		w.Textf("Invited users can register over Gemini, or through https://%s/register if they don't have a client certificate yet.", h.Domain)
+	}
+}
+
+func (h *Handler) createInvitation(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		r.Log.Warn("Failed to generate invitation code", "error", err)
+		w.Error()
+		return
+	}
+
+	if res, err := h.DB.ExecContext(
+		r.Context,
+		`insert into invitations(code, inviter) select $1, $2 where (select count(*) from invitations where inviter = $2) < $3`,
+		hex.EncodeToString(buf[:]),
+		r.User.ID,
+		h.Config.MaxInvitationsPerUser,
+	); err != nil {
+		r.Log.Warn("Failed to create invitation", "error", err)
+		w.Error()
+		return
+	} else if n, err := res.RowsAffected(); err != nil {
+		r.Log.Warn("Failed to create invitation", "error", err)
+		w.Error()
+		return
+	} else if n == 0 {
+		w.Status(40, "Reached invitations limit")
+		return
+	}
+
+	w.Redirect("/users/invitations")
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+const maxLanguages = 10
+
+var languageRegex = regexp.MustCompile(`^[a-z]{2,3}(?:-[a-z0-9]{1,8})*$`)
+
+// getLanguage returns the default posting language of a user, if set.
+func (h *Handler) getLanguage(r *Request) (string, error) {
+	var language sql.NullString
+	if err := h.DB.QueryRowContext(r.Context, `select language from settings where actor = ?`, r.User.ID).Scan(&language); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return language.String, nil
+}
+
+func (h *Handler) language(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	language, ok := readQuery(w, r, "Language code (e.g. en)")
+	if !ok {
+		return
+	}
+
+	language = strings.ToLower(strings.TrimSpace(language))
+	if !languageRegex.MatchString(language) {
+		w.Status(40, "Invalid language code")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, language) values($1, $2) on conflict(actor) do update set language = $2`,
+		r.User.ID,
+		language,
+	); err != nil {
+		r.Log.Warn("Failed to set posting language", "language", language, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+func (h *Handler) languages(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	input, ok := readQuery(w, r, "Languages you read (e.g. en,de)")
+	if !ok {
+		return
+	}
+
+	var languages []string
+	for _, language := range strings.Split(input, ",") {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language == "" {
+			continue
+		}
+
+		if !languageRegex.MatchString(language) {
+			w.Status(40, "Invalid language code")
+			return
+		}
+
+		languages = append(languages, language)
+	}
+
+	if len(languages) == 0 {
+		w.Status(40, "No languages")
+		return
+	}
+
+	if len(languages) > maxLanguages {
+		w.Status(40, "Too many languages")
+		return
+	}
+
+	j, err := json.Marshal(languages)
+	if err != nil {
+		r.Log.Warn("Failed to marshal languages", "error", err)
+		w.Error()
+		return
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, languages) values($1, $2) on conflict(actor) do update set languages = $2`,
+		r.User.ID,
+		string(j),
+	); err != nil {
+		r.Log.Warn("Failed to set languages", "languages", languages, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+func (h *Handler) allLanguages(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `update settings set languages = null where actor = ?`, r.User.ID); err != nil {
+		r.Log.Warn("Failed to clear languages", "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+// withFilters calls f directly if the user hides posts in languages they don't read, filters posts in their feed or has display preferences, or cached otherwise.
+func (h *Handler) withFilters(f, cached func(text.Writer, *Request, ...string)) func(text.Writer, *Request, ...string) {
+	return func(w text.Writer, r *Request, args ...string) {
+		if r.User == nil {
+			cached(w, r, args...)
+			return
+		}
+
+		var filtered int
+		if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from settings where actor = $1 and languages is not null) or exists (select 1 from filters where actor = $1 and feed = 1 and (expires is null or expires > unixepoch()))`, r.User.ID).Scan(&filtered); err != nil {
+			r.Log.Warn("Failed to check if user filters posts", "error", err)
+			w.Error()
+			return
+		}
+
+		// cached responses don't reflect display preferences
+		if filtered == 1 || r.preferences.PostsPerPage > 0 || r.preferences.HideShares || r.preferences.RelativeTime || r.preferences.Location != nil {
+			f(w, r, args...)
+		} else {
+			cached(w, r, args...)
+		}
+	}
+}
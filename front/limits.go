@@ -0,0 +1,158 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+)
+
+const limitsMaxPosts = 10
+
+// canEditProfile returns the time when a user can edit their profile again.
+func (h *Handler) canEditProfile(user *ap.Actor) time.Time {
+	if user.Updated != nil {
+		return user.Updated.Time.Add(h.Config.MinActorEditInterval)
+	}
+
+	return user.Published.Time.Add(h.Config.MinActorEditInterval)
+}
+
+// postQuota returns the number of posts published by a user during the last day and the time when the user can publish another post.
+func (h *Handler) postQuota(r *Request, now time.Time) (int64, time.Time, error) {
+	var today, last sql.NullInt64
+	if err := h.DB.QueryRowContext(r.Context, `select count(*), max(inserted) from outbox where activity->>'$.actor' = $1 and sender = $1 and activity->>'$.type' = 'Create' and inserted > $2`, r.User.ID, now.Add(-24*time.Hour).Unix()).Scan(&today, &last); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	if !last.Valid {
+		return today.Int64, time.Time{}, nil
+	}
+
+	return today.Int64, time.Unix(last.Int64, 0).Add(max(1, time.Duration(today.Int64/h.Config.PostThrottleFactor)) * h.Config.PostThrottleUnit), nil
+}
+
+// shareQuota returns the number of shares and unshares by a user during the last day and the time when the user can share or unshare again.
+func (h *Handler) shareQuota(r *Request, now time.Time) (int64, time.Time, error) {
+	var today, last sql.NullInt64
+	if err := h.DB.QueryRowContext(r.Context, `select count(*), max(inserted) from outbox where activity->>'$.actor' = $1 and sender = $1 and (activity->>'$.type' = 'Announce' or activity->>'$.type' = 'Undo') and inserted > $2`, r.User.ID, now.Add(-24*time.Hour).Unix()).Scan(&today, &last); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	if !last.Valid {
+		return today.Int64, time.Time{}, nil
+	}
+
+	return today.Int64, time.Unix(last.Int64, 0).Add(max(1, time.Duration(today.Int64/h.Config.ShareThrottleFactor)) * h.Config.ShareThrottleUnit), nil
+}
+
+// canEditPost returns the time when a post can be edited again: the interval grows with every edit.
+func (h *Handler) canEditPost(r *Request, note *ap.Object) (time.Time, error) {
+	var edits int
+	if err := h.DB.QueryRowContext(r.Context, `select count(*) from outbox where activity->>'$.object.id' = ? and sender = ? and (activity->>'$.type' = 'Update' or activity->>'$.type' = 'Create')`, note.ID, r.User.ID).Scan(&edits); err != nil {
+		return time.Time{}, err
+	}
+
+	lastEditTime := note.Published
+	if note.Updated != nil && *note.Updated != (ap.Time{}) {
+		lastEditTime = *note.Updated
+	}
+
+	return lastEditTime.Add(h.Config.EditThrottleUnit * time.Duration(math.Pow(h.Config.EditThrottleFactor, float64(edits)))), nil
+}
+
+func formatWait(can, now time.Time) string {
+	if !can.After(now) {
+		return "now"
+	}
+
+	return "in " + can.Sub(now).Truncate(time.Second).String()
+}
+
+func (h *Handler) limits(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	now := time.Now()
+
+	posts, canPost, err := h.postQuota(r, now)
+	if err != nil {
+		r.Log.Warn("Failed to check posts quota", "error", err)
+		w.Error()
+		return
+	}
+
+	shares, canShare, err := h.shareQuota(r, now)
+	if err != nil {
+		r.Log.Warn("Failed to check shares quota", "error", err)
+		w.Error()
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context, `select object from notes where author = ? and object->>'$.name' is null order by inserted desc limit ?`, r.User.ID, limitsMaxPosts)
+	if err != nil {
+		r.Log.Warn("Failed to fetch posts", "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+	w.Title("⏳ Limits")
+
+	w.Subtitle("📝 Posts")
+	w.Itemf("Published during the last day: %d/%d", posts, h.Config.MaxPostsPerDay)
+	if posts >= h.Config.MaxPostsPerDay {
+		w.Item("Next post: after some of these posts are a day old")
+	} else {
+		w.Itemf("Next post: %s", formatWait(canPost, now))
+	}
+	w.Empty()
+
+	w.Subtitle("🔄 Shares")
+	w.Itemf("Shared or unshared during the last day: %d", shares)
+	w.Itemf("Next share: %s", formatWait(canShare, now))
+	w.Empty()
+
+	w.Subtitle("✏️ Edits")
+	w.Itemf("Profile: %s", formatWait(h.canEditProfile(r.User), now))
+
+	for rows.Next() {
+		var note ap.Object
+		if err := rows.Scan(&note); err != nil {
+			r.Log.Warn("Failed to scan post", "error", err)
+			continue
+		}
+
+		can, err := h.canEditPost(r, &note)
+		if err != nil {
+			r.Log.Warn("Failed to check when post can be edited", "post", note.ID, "error", err)
+			continue
+		}
+
+		if can.After(now) {
+			w.Linkf("/users/view/"+strings.TrimPrefix(note.ID, "https://"), "%s post: %s", note.Published.Format(time.DateOnly), formatWait(can, now))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/outbox"
+)
+
+// parsePostURL converts the gemini:// URL or the canonical URL of a local post to its ID.
+func (h *Handler) parsePostURL(s string) (string, bool) {
+	if prefix, suffix, ok := strings.Cut(strings.ReplaceAll(h.Config.PostURL, "{domain}", h.Domain), "{post}"); ok && len(s) > len(prefix)+len(suffix) && strings.HasPrefix(s, prefix) && strings.HasSuffix(s, suffix) {
+		return "https://" + s[len(prefix):len(s)-len(suffix)], true
+	}
+
+	if path, ok := strings.CutPrefix(s, fmt.Sprintf("gemini://%s/", h.Domain)); ok {
+		if id, ok := strings.CutPrefix(strings.TrimPrefix(path, "users/"), "view/"); ok && id != "" {
+			return "https://" + id, true
+		}
+
+		return "", false
+	}
+
+	if strings.HasPrefix(s, "https://") {
+		return s, true
+	}
+
+	return "", false
+}
+
+// resolvePostURL returns the ID of a post, given its ID, its URL or a link to a local post.
+func (h *Handler) resolvePostURL(r *Request, s string) (string, error) {
+	postID, ok := h.parsePostURL(s)
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+
+	var exists bool
+	if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from notes where id = ?)`, postID).Scan(&exists); err != nil {
+		return "", err
+	} else if exists {
+		return postID, nil
+	}
+
+	// remote posts can have a url property that points to an HTML page
+	if err := h.DB.QueryRowContext(r.Context, `select id from notes where object->>'$.url' = ?`, s).Scan(&postID); err != nil {
+		return "", err
+	}
+
+	return postID, nil
+}
+
+func (h *Handler) links(w text.Writer, r *Request, args ...string) {
+	postID := "https://" + args[1]
+	if strings.Contains(args[1], "://") {
+		var err error
+		if postID, err = h.resolvePostURL(r, args[1]); errors.Is(err, sql.ErrNoRows) {
+			w.Status(40, "Post not found")
+			return
+		} else if err != nil {
+			r.Log.Warn("Failed to resolve post URL", "url", args[1], "error", err)
+			w.Error()
+			return
+		}
+	}
+
+	note, _, _, _, err := h.getPost(r, postID)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		r.Log.Info("Post was not found", "post", postID)
+		w.Status(40, "Post not found")
+		return
+	} else if err != nil {
+		r.Log.Info("Failed to find post", "post", postID, "error", err)
+		w.Error()
+		return
+	}
+
+	w.OK()
+	w.Title("🔗 Links")
+
+	w.Subtitle("ActivityPub")
+	w.Link(note.ID, note.ID)
+
+	w.Empty()
+	w.Subtitle("Gemini")
+	w.Link(fmt.Sprintf("gemini://%s/view/%s", h.Domain, strings.TrimPrefix(note.ID, "https://")), "View on "+h.Domain)
+
+	canonical := note.URL
+	if strings.HasPrefix(note.ID, fmt.Sprintf("https://%s/", h.Domain)) {
+		canonical = outbox.PostURL(h.Domain, h.Config, note.ID)
+	}
+
+	if canonical != "" && canonical != note.ID {
+		w.Empty()
+		w.Subtitle("Canonical URL")
+		w.Link(canonical, canonical)
+	}
+}
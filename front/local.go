@@ -23,6 +23,12 @@ import (
 )
 
 func (h *Handler) local(w text.Writer, r *Request, args ...string) {
+	// authenticated users don't see posts in languages they don't read
+	var userID string
+	if r.User != nil {
+		userID = r.User.ID
+	}
+
 	h.showFeedPage(
 		w,
 		r,
@@ -45,17 +51,28 @@ func (h *Handler) local(w text.Writer, r *Request, args ...string) {
 						on notes.id = shares.note
 						join persons
 						on persons.id = notes.author
-						where notes.public = 1 and sharers.host = $1
+						where notes.public = 1 and shares.public = 1 and sharers.host = $1 and not exists (select 1 from settings where actor = $2 and hideshares) and not exists (select 1 from domainblocks where domainblocks.severity = 'silence' and (domainblocks.host = notes.host or notes.host like '%.' || domainblocks.host))
 					)
+					where
+						(
+							object->'$.contentMap' is null or
+							object->>'$.attributedTo' = $2 or
+							not exists (select 1 from settings where actor = $2 and languages is not null) or
+							exists (select 1 from json_each(object->'$.contentMap') contentmap, settings, json_each(settings.languages) languages where settings.actor = $2 and (lower(contentmap.key) = languages.value or lower(contentmap.key) like languages.value || '-%'))
+						) and
+						not exists (select 1 from filters where filters.actor = $2 and filters.feed = 1 and filters.action = 'hide' and (filters.expires is null or filters.expires > unixepoch()) and (instr(lower(object->>'$.content'), lower(filters.phrase)) or instr(lower(object->>'$.name'), lower(filters.phrase)) or instr(lower(object->>'$.summary'), lower(filters.phrase))))
 					order by inserted desc
-					limit $2
-					offset $3
+					limit $3
+					offset $4
 				`,
 				h.Domain,
-				h.Config.PostsPerPage,
+				userID,
+				h.postsPerPage(r),
 				offset,
 			)
 		},
 		true,
+		false,
+		filterFeed,
 	)
 }
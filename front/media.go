@@ -0,0 +1,145 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+)
+
+var durationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration parses an ISO 8601 duration, like PT1M30S.
+func parseDuration(s string) (time.Duration, bool) {
+	m := durationRegex.FindStringSubmatch(s)
+	if m == nil || s == "P" || s == "PT" {
+		return 0, false
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{time.Hour * 24, time.Hour, time.Minute} {
+		if m[i+1] == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		d += time.Duration(n) * unit
+	}
+
+	if m[4] != "" {
+		sec, err := strconv.ParseFloat(m[4], 64)
+		if err != nil {
+			return 0, false
+		}
+
+		d += time.Duration(sec * float64(time.Second))
+	}
+
+	return d, true
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", d/time.Hour, (d%time.Hour)/time.Minute, (d%time.Minute)/time.Second)
+	}
+
+	return fmt.Sprintf("%d:%02d", d/time.Minute, (d%time.Minute)/time.Second)
+}
+
+// mediaLabel returns a link label for an audio or video attachment, or an empty string for other attachments.
+func mediaLabel(attachment *ap.Attachment) string {
+	var emoji, name string
+	if attachment.Type == ap.Audio || strings.HasPrefix(attachment.MediaType, "audio/") {
+		emoji = "🔊"
+		name = "Audio"
+	} else if attachment.Type == ap.Video || strings.HasPrefix(attachment.MediaType, "video/") {
+		emoji = "🎞️"
+		name = "Video"
+	} else {
+		return ""
+	}
+
+	if attachment.Name != "" {
+		name = attachment.Name
+	}
+
+	var details []string
+	if attachment.MediaType != "" {
+		details = append(details, attachment.MediaType)
+	}
+	if d, ok := parseDuration(attachment.Duration); ok {
+		details = append(details, formatDuration(d))
+	}
+
+	if len(details) == 0 {
+		return emoji + " " + name
+	}
+
+	return fmt.Sprintf("%s %s (%s)", emoji, name, strings.Join(details, ", "))
+}
+
+// mediaBody returns the body of an audio or video object, starting with its title, duration and license.
+func mediaBody(note *ap.Object, compact bool) string {
+	var b strings.Builder
+
+	name := note.Name
+	if name == "" {
+		name = "Untitled"
+	}
+
+	if note.Type == ap.VideoObject {
+		b.WriteString("🎞️ Video: ")
+	} else {
+		b.WriteString("🔊 Audio: ")
+	}
+	b.WriteString(html.EscapeString(name))
+
+	d, ok := parseDuration(note.Duration)
+
+	if compact {
+		if ok {
+			fmt.Fprintf(&b, " (%s)", formatDuration(d))
+		}
+		return b.String()
+	}
+
+	if ok {
+		fmt.Fprintf(&b, "<br>⏱️ Duration: %s", formatDuration(d))
+	}
+
+	if note.License != "" {
+		fmt.Fprintf(&b, "<br>⚖️ License: %s", html.EscapeString(note.License))
+	}
+
+	if note.Content != "" {
+		b.WriteString("<br><br>")
+		b.WriteString(note.Content)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"PT30S":       time.Second * 30,
+		"PT1M30S":     time.Minute + time.Second*30,
+		"PT2H":        time.Hour * 2,
+		"P1DT1H":      time.Hour * 25,
+		"PT12.5S":     time.Second*12 + time.Millisecond*500,
+		"PT1H2M3.25S": time.Hour + time.Minute*2 + time.Second*3 + time.Millisecond*250,
+	} {
+		d, ok := parseDuration(s)
+		assert.True(t, ok, s)
+		assert.Equal(t, expected, d, s)
+	}
+
+	for _, s := range []string{"", "P", "PT", "1M30S", "PT1M30", "90"} {
+		_, ok := parseDuration(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestMediaLabel(t *testing.T) {
+	assert.Equal(t, "🔊 Audio (audio/mpeg, 1:30)", mediaLabel(&ap.Attachment{Type: ap.Document, MediaType: "audio/mpeg", Duration: "PT1M30S"}))
+	assert.Equal(t, "🎞️ Cats (video/mp4, 1:02:03)", mediaLabel(&ap.Attachment{Type: ap.Document, MediaType: "video/mp4", Name: "Cats", Duration: "PT1H2M3S"}))
+	assert.Equal(t, "🎞️ Video", mediaLabel(&ap.Attachment{Type: ap.Video}))
+	assert.Equal(t, "🔊 Audio (audio/ogg)", mediaLabel(&ap.Attachment{Type: ap.Audio, MediaType: "audio/ogg", Duration: "invalid"}))
+	assert.Equal(t, "", mediaLabel(&ap.Attachment{Type: ap.Image, MediaType: "image/png"}))
+	assert.Equal(t, "", mediaLabel(&ap.Attachment{Type: ap.Document, MediaType: "application/pdf"}))
+}
+
+func TestMediaBody(t *testing.T) {
+	video := ap.Object{Type: ap.VideoObject, Name: "Cats & dogs", Duration: "PT83S", License: "Attribution", Content: "<p>Cute</p>"}
+	assert.Equal(t, "🎞️ Video: Cats &amp; dogs<br>⏱️ Duration: 1:23<br>⚖️ License: Attribution<br><br><p>Cute</p>", mediaBody(&video, false))
+	assert.Equal(t, "🎞️ Video: Cats &amp; dogs (1:23)", mediaBody(&video, true))
+
+	audio := ap.Object{Type: ap.AudioObject}
+	assert.Equal(t, "🔊 Audio: Untitled", mediaBody(&audio, false))
+	assert.Equal(t, "🔊 Audio: Untitled", mediaBody(&audio, true))
+}
@@ -42,16 +42,23 @@ func (h *Handler) mentions(w text.Writer, r *Request, args ...string) {
 					(
 						exists (select 1 from json_each(note->'$.to') where value = $1) or
 						exists (select 1 from json_each(note->'$.cc') where value = $1)
-					)
+					) and
+					(
+						sharer is null or
+						not exists (select 1 from settings where actor = $1 and hideshares)
+					) and
+					not exists (select 1 from filters where filters.actor = $1 and filters.notifications = 1 and filters.action = 'hide' and (filters.expires is null or filters.expires > unixepoch()) and (instr(lower(note->>'$.content'), lower(filters.phrase)) or instr(lower(note->>'$.name'), lower(filters.phrase)) or instr(lower(note->>'$.summary'), lower(filters.phrase))))
 				order by
 					inserted desc
 				limit $2
 				offset $3`,
 				r.User.ID,
-				h.Config.PostsPerPage,
+				h.postsPerPage(r),
 				offset,
 			)
 		},
 		true,
+		false,
+		filterNotifications,
 	)
 }
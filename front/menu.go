@@ -32,6 +32,7 @@ func writeUserMenu(w text.Writer, user *ap.Actor) {
 	if user != nil {
 		w.Link("/users", "📻 My feed")
 		w.Link("/users/mentions", "📞 Mentions")
+		w.Link("/users/digest", "📰 Digest")
 		w.Link("/users/follows", "⚡️ Followed users")
 		w.Link("/users/me", "😈 My profile")
 	}
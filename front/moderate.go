@@ -0,0 +1,300 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/outbox"
+)
+
+// getOwnedCommunity returns a community owned by the user.
+func (h *Handler) getOwnedCommunity(w text.Writer, r *Request, name string) (*ap.Actor, bool) {
+	var group ap.Actor
+	if err := h.DB.QueryRowContext(r.Context, `select persons.actor from communities join persons on persons.id = communities.id where communities.owner = ? and persons.host = ? and persons.actor->>'$.preferredUsername' = ?`, r.User.ID, h.Domain, name).Scan(&group); err != nil && errors.Is(err, sql.ErrNoRows) {
+		r.Log.Warn("Community does not exist or not owned by user", "name", name)
+		w.Status(40, "No such community")
+		return nil, false
+	} else if err != nil {
+		r.Log.Warn("Failed to fetch community", "name", name, "error", err)
+		w.Error()
+		return nil, false
+	}
+
+	return &group, true
+}
+
+// getModeratedPost returns a post shared by a community owned by the user.
+func (h *Handler) getModeratedPost(w text.Writer, r *Request, postID string) (*ap.Actor, *ap.Object, bool) {
+	var group ap.Actor
+	var note ap.Object
+	if err := h.DB.QueryRowContext(r.Context, `select persons.actor, notes.object from notes join shares on shares.note = notes.id join communities on communities.id = shares.by join persons on persons.id = communities.id where notes.id = ? and communities.owner = ?`, postID, r.User.ID).Scan(&group, &note); err != nil && errors.Is(err, sql.ErrNoRows) {
+		r.Log.Warn("Post does not exist or not in a community owned by user", "post", postID)
+		w.Status(40, "Post not found")
+		return nil, nil, false
+	} else if err != nil {
+		r.Log.Warn("Failed to fetch post", "post", postID, "error", err)
+		w.Error()
+		return nil, nil, false
+	}
+
+	return &group, &note, true
+}
+
+func (h *Handler) moderate(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	group, ok := h.getOwnedCommunity(w, r, args[1])
+	if !ok {
+		return
+	}
+
+	var pinned sql.NullString
+	if err := h.DB.QueryRowContext(r.Context, `select pinned from communities where id = ?`, group.ID).Scan(&pinned); err != nil {
+		r.Log.Warn("Failed to fetch pinned post", "group", group.ID, "error", err)
+		w.Error()
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context, `select bans.actor, persons.actor->>'$.preferredUsername' from bans left join persons on persons.id = bans.actor where bans.community = ? order by bans.inserted desc`, group.ID)
+	if err != nil {
+		r.Log.Warn("Failed to list banned users", "group", group.ID, "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+	w.Titlef("🛡️ Moderating %s", group.PreferredUsername)
+
+	w.Link("/users/outbox/"+strings.TrimPrefix(group.ID, "https://"), "Community")
+
+	w.Subtitle("Pinned Post")
+
+	if pinned.Valid {
+		w.Link("/users/view/"+strings.TrimPrefix(pinned.String, "https://"), "📌 Pinned post")
+		w.Link("/users/communities/unpin/"+group.PreferredUsername, "Unpin")
+	} else {
+		w.Text("No pinned post.")
+	}
+
+	w.Subtitle("Banned Users")
+
+	empty := true
+	for rows.Next() {
+		var actorID string
+		var username sql.NullString
+		if err := rows.Scan(&actorID, &username); err != nil {
+			r.Log.Warn("Failed to scan banned user", "error", err)
+			continue
+		}
+
+		if username.Valid {
+			w.Linkf("/users/communities/unban/"+group.PreferredUsername+"?"+url.QueryEscape(actorID), "Unban %s (%s)", username.String, actorID)
+		} else {
+			w.Linkf("/users/communities/unban/"+group.PreferredUsername+"?"+url.QueryEscape(actorID), "Unban %s", actorID)
+		}
+
+		empty = false
+	}
+
+	if empty {
+		w.Text("No banned users.")
+	}
+
+	w.Empty()
+	w.Link("/users/communities/ban/"+group.PreferredUsername, "🚫 Ban a user")
+}
+
+func (h *Handler) ban(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	group, ok := h.getOwnedCommunity(w, r, args[1])
+	if !ok {
+		return
+	}
+
+	actorID, ok := readQuery(w, r, "User ID")
+	if !ok {
+		return
+	}
+
+	if actorID == group.ID || actorID == r.User.ID {
+		w.Status(40, "Cannot ban this user")
+		return
+	}
+
+	var exists int
+	if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from persons where id = ?)`, actorID).Scan(&exists); err != nil {
+		r.Log.Warn("Failed to check if user exists", "actor", actorID, "error", err)
+		w.Error()
+		return
+	} else if exists == 0 {
+		w.Status(40, "No such user")
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context, nil)
+	if err != nil {
+		r.Log.Warn("Failed to ban user", "group", group.ID, "actor", actorID, "error", err)
+		w.Error()
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(r.Context, `insert or ignore into bans(community, actor) values(?, ?)`, group.ID, actorID); err != nil {
+		r.Log.Warn("Failed to ban user", "group", group.ID, "actor", actorID, "error", err)
+		w.Error()
+		return
+	}
+
+	if _, err := tx.ExecContext(r.Context, `delete from follows where follower = ? and followed = ?`, actorID, group.ID); err != nil {
+		r.Log.Warn("Failed to ban user", "group", group.ID, "actor", actorID, "error", err)
+		w.Error()
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Log.Warn("Failed to ban user", "group", group.ID, "actor", actorID, "error", err)
+		w.Error()
+		return
+	}
+
+	r.Log.Info("Banned user", "group", group.ID, "actor", actorID)
+	w.Redirect("/users/communities/manage/" + group.PreferredUsername)
+}
+
+func (h *Handler) unban(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	group, ok := h.getOwnedCommunity(w, r, args[1])
+	if !ok {
+		return
+	}
+
+	actorID, ok := readQuery(w, r, "User ID")
+	if !ok {
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `delete from bans where community = ? and actor = ?`, group.ID, actorID); err != nil {
+		r.Log.Warn("Failed to unban user", "group", group.ID, "actor", actorID, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/communities/manage/" + group.PreferredUsername)
+}
+
+func (h *Handler) remove(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	group, note, ok := h.getModeratedPost(w, r, "https://"+args[1])
+	if !ok {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context, nil)
+	if err != nil {
+		r.Log.Warn("Failed to remove post", "group", group.ID, "post", note.ID, "error", err)
+		w.Error()
+		return
+	}
+	defer tx.Rollback()
+
+	if err := outbox.Remove(r.Context, h.Domain, tx, group, note); err != nil {
+		r.Log.Warn("Failed to remove post", "group", group.ID, "post", note.ID, "error", err)
+		w.Error()
+		return
+	}
+
+	if _, err := tx.ExecContext(r.Context, `update communities set pinned = null where id = ? and pinned = ?`, group.ID, note.ID); err != nil {
+		r.Log.Warn("Failed to remove post", "group", group.ID, "post", note.ID, "error", err)
+		w.Error()
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Log.Warn("Failed to remove post", "group", group.ID, "post", note.ID, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/outbox/" + strings.TrimPrefix(group.ID, "https://"))
+}
+
+func (h *Handler) pin(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	group, note, ok := h.getModeratedPost(w, r, "https://"+args[1])
+	if !ok {
+		return
+	}
+
+	if !note.IsPublic() {
+		w.Status(40, "Cannot pin a non-public post")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `update communities set pinned = ? where id = ?`, note.ID, group.ID); err != nil {
+		r.Log.Warn("Failed to pin post", "group", group.ID, "post", note.ID, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/outbox/" + strings.TrimPrefix(group.ID, "https://"))
+}
+
+func (h *Handler) unpin(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	group, ok := h.getOwnedCommunity(w, r, args[1])
+	if !ok {
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `update communities set pinned = null where id = ?`, group.ID); err != nil {
+		r.Log.Warn("Failed to unpin post", "group", group.ID, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/communities/manage/" + group.PreferredUsername)
+}
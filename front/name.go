@@ -35,10 +35,7 @@ func (h *Handler) name(w text.Writer, r *Request, args ...string) {
 
 	now := time.Now()
 
-	can := r.User.Published.Time.Add(h.Config.MinActorEditInterval)
-	if r.User.Updated != nil {
-		can = r.User.Updated.Time.Add(h.Config.MinActorEditInterval)
-	}
+	can := h.canEditProfile(r.User)
 	if now.Before(can) {
 		r.Log.Warn("Throttled request to set name", "can", can)
 		w.Statusf(40, "Please wait for %s", time.Until(can).Truncate(time.Second).String())
@@ -27,13 +27,36 @@ import (
 	"github.com/dimkr/tootik/data"
 	"github.com/dimkr/tootik/front/text"
 	"github.com/dimkr/tootik/front/text/plain"
+	"github.com/dimkr/tootik/icon"
 )
 
+// PublicOutboxQuery fetches a page of public posts and shares by a user.
+const PublicOutboxQuery = `select object, actor, sharer, max(inserted) from (
+		select notes.id, persons.actor, notes.object, notes.inserted, null as sharer from notes
+		join persons on persons.id = $1
+		where notes.author = $1 and notes.public = 1
+		union all
+		select notes.id, authors.actor, notes.object, shares.inserted, sharers.actor as by from
+		shares
+		join notes on notes.id = shares.note
+		join persons authors on authors.id = notes.author
+		join persons sharers on sharers.id = $1
+		where shares.by = $1 and notes.public = 1 and shares.public = 1
+	)
+	group by id
+	order by max(inserted) desc limit $2 offset $3`
+
 func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 	actorID := "https://" + args[1]
 
+	var userID sql.NullString
+	if r.User != nil {
+		userID = sql.NullString{String: r.User.ID, Valid: true}
+	}
+
 	var actor ap.Actor
-	if err := h.DB.QueryRowContext(r.Context, `select actor from persons where id = ?`, actorID).Scan(&actor); err != nil && errors.Is(err, sql.ErrNoRows) {
+	var owned int
+	if err := h.DB.QueryRowContext(r.Context, `select persons.actor, communities.id is not null from persons left join communities on communities.id = persons.id and communities.owner = ? where persons.id = ?`, userID, actorID).Scan(&actor, &owned); err != nil && errors.Is(err, sql.ErrNoRows) {
 		r.Log.Info("Person was not found", "actor", actorID)
 		w.Status(40, "User not found")
 		return
@@ -66,11 +89,11 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 				where notes.author = $1 and notes.public = 1 and notes.object->>'$.inReplyTo' is null
 			) u
 			join persons authors on authors.id = u.author
-			left join notes replies on replies.object->>'$.inReplyTo' = u.id
+			left join notes replies on replies.object->>'$.inReplyTo' = +u.id
 			group by u.id
 			order by max(u.inserted, coalesce(max(replies.inserted), 0)) / 86400 desc, count(replies.id) desc, u.inserted desc limit $2 offset $3`,
 			actorID,
-			h.Config.PostsPerPage,
+			h.postsPerPage(r),
 			offset,
 		)
 	} else if actor.Type == ap.Group && r.User != nil {
@@ -98,34 +121,21 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 					notes.object->>'$.inReplyTo' is null
 			) u
 			join persons authors on authors.id = u.author
-			left join notes replies on replies.object->>'$.inReplyTo' = u.id
+			left join notes replies on replies.object->>'$.inReplyTo' = +u.id
 			group by u.id
 			order by max(u.inserted, coalesce(max(replies.inserted), 0)) / 86400 desc, count(replies.id) desc, u.inserted desc limit $3 offset $4`,
 			actorID,
 			r.User.ID,
-			h.Config.PostsPerPage,
+			h.postsPerPage(r),
 			offset,
 		)
 	} else if r.User == nil {
 		// unauthenticated users can only see public posts
 		rows, err = h.DB.QueryContext(
 			r.Context,
-			`select object, actor, sharer, max(inserted) from (
-				select notes.id, persons.actor, notes.object, notes.inserted, null as sharer from notes
-				join persons on persons.id = $1
-				where notes.author = $1 and notes.public = 1
-				union all
-				select notes.id, authors.actor, notes.object, shares.inserted, sharers.actor as by from
-				shares
-				join notes on notes.id = shares.note
-				join persons authors on authors.id = notes.author
-				join persons sharers on sharers.id = $1
-				where shares.by = $1 and notes.public = 1
-			)
-			group by id
-			order by max(inserted) desc limit $2 offset $3`,
+			PublicOutboxQuery,
 			actorID,
-			h.Config.PostsPerPage,
+			h.postsPerPage(r),
 			offset,
 		)
 	} else if r.User.ID == actorID {
@@ -141,16 +151,16 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 				join notes on notes.id = shares.note
 				join persons authors on authors.id = notes.author
 				join persons sharers on sharers.id = $1
-				where shares.by = $1
+				where shares.by = $1 and not exists (select 1 from settings where actor = $1 and hideshares)
 			)
 			group by id
 			order by max(inserted) desc limit $2 offset $3`,
 			actorID,
-			h.Config.PostsPerPage,
+			h.postsPerPage(r),
 			offset,
 		)
 	} else {
-		// users can see only public posts by others, posts to followers if following, and DMs
+		// users can see only public posts and shares by others, posts and shares to followers if following, and DMs
 		rows, err = h.DB.QueryContext(
 			r.Context,
 			`select object, actor, sharer, max(inserted) from (
@@ -184,13 +194,16 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 				join notes on notes.id = shares.note
 				join persons authors on authors.id = notes.author
 				join persons sharers on sharers.id = $1
-				where shares.by = $1 and notes.public = 1
+				where shares.by = $1 and notes.public = 1 and (
+					shares.public = 1 or
+					exists (select 1 from follows where follower = $2 and followed = $1 and accepted = 1)
+				) and not exists (select 1 from settings where actor = $2 and hideshares)
 			)
 			group by id
 			order by max(inserted) desc limit $3 offset $4`,
 			actorID,
 			r.User.ID,
-			h.Config.PostsPerPage,
+			h.postsPerPage(r),
 			offset,
 		)
 	}
@@ -211,11 +224,11 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 	}
 
 	if actor.Type != ap.Person && offset > 0 {
-		w.Titlef("%s [%s] (%d-%d)", displayName, actor.Type, offset, offset+h.Config.PostsPerPage)
+		w.Titlef("%s [%s] (%d-%d)", displayName, actor.Type, offset, offset+h.postsPerPage(r))
 	} else if actor.Type != ap.Person {
 		w.Titlef("%s [%s]", displayName, actor.Type)
 	} else if offset > 0 {
-		w.Titlef("%s (%d-%d)", displayName, offset, offset+h.Config.PostsPerPage)
+		w.Titlef("%s (%d-%d)", displayName, offset, offset+h.postsPerPage(r))
 	} else {
 		w.Title(displayName)
 	}
@@ -223,7 +236,17 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 	showSeparator := false
 
 	if offset == 0 && len(actor.Icon) > 0 && actor.Icon[0].URL != "" {
-		w.Link(actor.Icon[0].URL, "Avatar")
+		// link to the cached copy, if available, so clients don't need to fetch the avatar from another server
+		var hash string
+		if err := h.DB.QueryRowContext(r.Context, `select avatars.hash from avatars where avatars.actor = $1 and avatars.url = $2 and exists (select 1 from avatarvariants where avatarvariants.hash = avatars.hash)`, actorID, actor.Icon[0].URL).Scan(&hash); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			r.Log.Warn("Failed to fetch cached avatar", "actor", actorID, "error", err)
+		}
+
+		if hash == "" {
+			w.Link(actor.Icon[0].URL, "Avatar")
+		} else {
+			w.Link(fmt.Sprintf("https://%s/avatar/%d/%s%s", h.Domain, icon.Sizes[len(icon.Sizes)-1], hash, icon.FileNameExtension), "Avatar")
+		}
 		showSeparator = true
 	}
 
@@ -262,7 +285,7 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 				w.Empty()
 			}
 
-			w.Textf("Joined: %s", actor.Published.Format(time.DateOnly))
+			w.Textf("Joined: %s", localTime(r, actor.Published.Time).Format(time.DateOnly))
 
 			firstProperty = false
 			showSeparator = true
@@ -286,7 +309,11 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 				w.Textf("%s: %s", prop.Name, raw)
 			} else {
 				for link := range links.Keys() {
-					w.Linkf(link, prop.Name)
+					if prop.VerifiedAt == nil {
+						w.Link(link, prop.Name)
+					} else {
+						w.Linkf(link, "%s ✓", prop.Name)
+					}
 					break
 				}
 			}
@@ -300,19 +327,31 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 		w.Separator()
 	}
 
-	count := h.PrintNotes(w, r, rows, true, actor.Type != ap.Group, "No posts.")
+	if offset == 0 && actor.Type == ap.Group {
+		var pinned ap.Object
+		var author ap.Actor
+		if err := h.DB.QueryRowContext(r.Context, `select notes.object, persons.actor from communities join notes on notes.id = communities.pinned join persons on persons.id = notes.author where communities.id = ? and notes.public = 1`, actorID).Scan(&pinned, &author); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			r.Log.Warn("Failed to fetch pinned post", "actor", actorID, "error", err)
+		} else if err == nil {
+			w.Subtitle("📌 Pinned")
+			h.PrintNote(w, r, &pinned, &author, nil, pinned.Published.Time, true, true, true, true)
+			w.Separator()
+		}
+	}
+
+	count := h.PrintNotes(w, r, rows, true, actor.Type != ap.Group, nil, "No posts.")
 	rows.Close()
 
-	if offset >= h.Config.PostsPerPage || count == h.Config.PostsPerPage {
+	if offset >= h.postsPerPage(r) || count == h.postsPerPage(r) {
 		w.Separator()
 	}
 
-	if offset >= h.Config.PostsPerPage {
-		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset-h.Config.PostsPerPage), "Previous page (%d-%d)", offset-h.Config.PostsPerPage, offset)
+	if offset >= h.postsPerPage(r) {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset-h.postsPerPage(r)), "Previous page (%d-%d)", offset-h.postsPerPage(r), offset)
 	}
 
-	if count == h.Config.PostsPerPage {
-		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+h.Config.PostsPerPage), "Next page (%d-%d)", offset+h.Config.PostsPerPage, offset+2*h.Config.PostsPerPage)
+	if count == h.postsPerPage(r) {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+h.postsPerPage(r)), "Next page (%d-%d)", offset+h.postsPerPage(r), offset+2*h.postsPerPage(r))
 	}
 
 	if r.User != nil && actorID != r.User.ID {
@@ -327,4 +366,8 @@ func (h *Handler) userOutbox(w text.Writer, r *Request, args ...string) {
 			w.Linkf("/users/unfollow/"+strings.TrimPrefix(actorID, "https://"), "🔌 Unfollow %s", actor.PreferredUsername)
 		}
 	}
+
+	if r.User != nil && actor.Type == ap.Group && owned == 1 {
+		w.Link("/users/communities/manage/"+actor.PreferredUsername, "🛡️ Moderate")
+	}
 }
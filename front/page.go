@@ -43,7 +43,9 @@ func getOffset(requestUrl *url.URL) (int, error) {
 	return int(offset), nil
 }
 
-func (h *Handler) showFeedPage(w text.Writer, r *Request, title string, query func(int) (*sql.Rows, error), printDaySeparators bool) {
+// showFeedPage shows a page of posts: if grouped is true, the query returns an additional column with the number of
+// other users who shared each post.
+func (h *Handler) showFeedPage(w text.Writer, r *Request, title string, query func(int) (*sql.Rows, error), printDaySeparators, grouped bool, filterContext string) {
 	offset, err := getOffset(r.URL)
 	if err != nil {
 		r.Log.Info("Failed to parse query", "url", r.URL, "error", err)
@@ -67,23 +69,23 @@ func (h *Handler) showFeedPage(w text.Writer, r *Request, title string, query fu
 
 	w.OK()
 	if offset > 0 {
-		w.Titlef("%s (%d-%d)", title, offset, offset+h.Config.PostsPerPage)
+		w.Titlef("%s (%d-%d)", title, offset, offset+h.postsPerPage(r))
 	} else {
 		w.Title(title)
 	}
 
-	count := h.PrintNotes(w, r, rows, true, printDaySeparators, "No posts.")
+	count := h.printNotes(w, r, rows, true, printDaySeparators, grouped, h.getFilters(r, filterContext), "No posts.")
 	rows.Close()
 
-	if offset >= h.Config.PostsPerPage || count == h.Config.PostsPerPage {
+	if offset >= h.postsPerPage(r) || count == h.postsPerPage(r) {
 		w.Separator()
 	}
 
-	if offset >= h.Config.PostsPerPage {
-		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset-h.Config.PostsPerPage), "Previous page (%d-%d)", offset-h.Config.PostsPerPage, offset)
+	if offset >= h.postsPerPage(r) {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset-h.postsPerPage(r)), "Previous page (%d-%d)", offset-h.postsPerPage(r), offset)
 	}
 
-	if count == h.Config.PostsPerPage && offset+h.Config.PostsPerPage <= h.Config.MaxOffset {
-		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+h.Config.PostsPerPage), "Next page (%d-%d)", offset+h.Config.PostsPerPage, offset+2*h.Config.PostsPerPage)
+	if count == h.postsPerPage(r) && offset+h.postsPerPage(r) <= h.Config.MaxOffset {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+h.postsPerPage(r)), "Next page (%d-%d)", offset+h.postsPerPage(r), offset+2*h.postsPerPage(r))
 	}
 }
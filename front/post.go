@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -37,37 +38,64 @@ const (
 )
 
 var (
-	mentionRegex = regexp.MustCompile(`\B@(\w+)(?:@((?:\w+\.)+\w+(?::\d{1,5}){0,1})){0,1}\b`)
-	hashtagRegex = regexp.MustCompile(`\B#\w{1,32}\b`)
-	pollRegex    = regexp.MustCompile(`^\[(?:(?i)POLL)\s+(.+)\s*\]\s*(.+)`)
+	mentionRegex      = regexp.MustCompile(`\B@(\w+)(?:@((?:\w+\.)+\w+(?::\d{1,5}){0,1})){0,1}\b`)
+	hashtagRegex      = regexp.MustCompile(`\B#\w{1,32}\b`)
+	pollRegex         = regexp.MustCompile(`^\[(?:(?i)POLL)((?::\w+)*)\s+(.+)\s*\]\s*(.+)`)
+	pollDurationRegex = regexp.MustCompile(`^(\d{1,5})([mhd])$`)
+	langRegex         = regexp.MustCompile(`^\[(?:(?i)LANG)\s+([a-zA-Z]{2,3}(?:-[a-zA-Z0-9]{1,8})*)\s*\]\s*`)
 )
 
 func (h *Handler) post(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo *ap.Object, to ap.Audience, cc ap.Audience, audience string, readInput inputFunc) {
+	var defaults postDefaults
+	if oldNote == nil && inReplyTo == nil {
+		var err error
+		if defaults, err = h.getPostDefaults(r); err != nil {
+			r.Log.Warn("Failed to get post defaults", "error", err)
+			w.Error()
+			return
+		}
+	}
+
+	h.postWithDefaults(w, r, oldNote, inReplyTo, to, cc, audience, defaults, readInput)
+}
+
+func (h *Handler) postWithDefaults(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo *ap.Object, to ap.Audience, cc ap.Audience, audience string, defaults postDefaults, readInput inputFunc) {
+	if r.User.MovedTo != "" {
+		r.Log.Warn("Moved user cannot post", "movedTo", r.User.MovedTo)
+		w.Status(40, "Account was moved to "+r.User.MovedTo)
+		return
+	}
+
+	if accepted, err := h.rulesAccepted(r); err != nil {
+		r.Log.Warn("Failed to check if rules were accepted", "error", err)
+		w.Error()
+		return
+	} else if !accepted {
+		r.Log.Info("User has not accepted the rules", "version", h.Config.RulesVersion)
+		w.Redirect("/users/rules")
+		return
+	}
+
 	now := ap.Time{Time: time.Now()}
 
 	if oldNote == nil {
-		var today, last sql.NullInt64
-		if err := h.DB.QueryRowContext(r.Context, `select count(*), max(inserted) from outbox where activity->>'$.actor' = $1 and sender = $1 and activity->>'$.type' = 'Create' and inserted > $2`, r.User.ID, now.Add(-24*time.Hour).Unix()).Scan(&today, &last); err != nil {
+		today, can, err := h.postQuota(r, now.Time)
+		if err != nil {
 			r.Log.Warn("Failed to check if new post needs to be throttled", "error", err)
 			w.Error()
 			return
 		}
 
-		if today.Valid && today.Int64 >= h.Config.MaxPostsPerDay {
-			r.Log.Warn("User has exceeded the daily posts quota", "posts", today.Int64)
+		if today >= h.Config.MaxPostsPerDay {
+			r.Log.Warn("User has exceeded the daily posts quota", "posts", today)
 			w.Status(40, "Reached daily posts quota")
 			return
 		}
 
-		if today.Valid && last.Valid {
-			t := time.Unix(last.Int64, 0)
-			can := t.Add(max(1, time.Duration(today.Int64/h.Config.PostThrottleFactor)) * h.Config.PostThrottleUnit)
-			until := time.Until(can)
-			if until > 0 {
-				r.Log.Warn("User is posting too frequently", "last", t, "can", can)
-				w.Statusf(40, "Please wait for %s", until.Truncate(time.Second).String())
-				return
-			}
+		if until := time.Until(can); until > 0 {
+			r.Log.Warn("User is posting too frequently", "can", can)
+			w.Statusf(40, "Please wait for %s", until.Truncate(time.Second).String())
+			return
 		}
 	}
 
@@ -76,6 +104,30 @@ func (h *Handler) post(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo
 		return
 	}
 
+	var language string
+	if m := langRegex.FindStringSubmatch(content); m != nil {
+		language = strings.ToLower(m[1])
+		content = content[len(m[0]):]
+	} else if oldNote != nil {
+		for language = range oldNote.ContentMap {
+			break
+		}
+	}
+
+	if language == "" {
+		var err error
+		if language, err = h.getLanguage(r); err != nil {
+			r.Log.Warn("Failed to get posting language", "error", err)
+			w.Error()
+			return
+		}
+	}
+
+	// default hashtags are added to public and followers-only posts, but not to DMs
+	if oldNote == nil && inReplyTo == nil && (to.Contains(ap.Public) || cc.Contains(ap.Public) || to.Contains(r.User.Followers)) && !pollRegex.MatchString(content) {
+		content = appendHashtags(content, defaults.Hashtags)
+	}
+
 	if utf8.RuneCountInString(content) > h.Config.MaxPostsLength {
 		w.Status(40, "Post is too long")
 		return
@@ -94,6 +146,8 @@ func (h *Handler) post(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo
 		postID = oldNote.ID
 	}
 
+	replies := ap.Collection(strings.Replace(postID, "/post/", "/replies/", 1))
+
 	var tags []ap.Tag
 
 	for _, hashtag := range hashtagRegex.FindAllString(content, -1) {
@@ -128,6 +182,33 @@ func (h *Handler) post(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo
 		cc.Add(actorID)
 	}
 
+	format, err := h.getPostFormat(r)
+	if err != nil {
+		r.Log.Warn("Failed to get posting format", "error", err)
+		w.Error()
+		return
+	}
+
+	if format == "gemtext" {
+		var linkTags []ap.Tag
+		content, linkTags = h.resolveLinks(r, content)
+
+	links:
+		for _, tag := range linkTags {
+			for _, other := range tags {
+				if other.Type == tag.Type && other.Href == tag.Href {
+					continue links
+				}
+			}
+
+			if tag.Type == ap.Mention {
+				cc.Add(tag.Href)
+			}
+
+			tags = append(tags, tag)
+		}
+	}
+
 	note := ap.Object{
 		Type:         ap.Note,
 		ID:           postID,
@@ -138,6 +219,19 @@ func (h *Handler) post(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo
 		CC:           cc,
 		Audience:     audience,
 		Tag:          tags,
+		Replies:      replies,
+		URL:          outbox.PostURL(h.Domain, h.Config, postID),
+	}
+
+	if defaults.Summary != "" {
+		note.Sensitive = true
+		note.Summary = defaults.Summary
+	}
+
+	if oldNote != nil {
+		note.InteractionPolicy = oldNote.InteractionPolicy
+	} else if inReplyTo == nil {
+		note.InteractionPolicy = replyPolicy(r.User, defaults.Replies)
 	}
 
 	anyRecipient := false
@@ -163,6 +257,18 @@ func (h *Handler) post(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo
 						return
 					}
 
+					if len(inReplyTo.OneOf) > 0 && oldNote == nil {
+						var voted int
+						if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from notes where object->>'$.inReplyTo' = ? and author = ? and object->>'$.name' is not null)`, inReplyTo.ID, r.User.ID).Scan(&voted); err != nil {
+							r.Log.Warn("Failed to check if user has voted", "poll", inReplyTo.ID, "error", err)
+							w.Error()
+							return
+						} else if voted == 1 {
+							w.Status(40, "Already voted")
+							return
+						}
+					}
+
 					note.Content = ""
 					note.Name = option.Name
 					note.To = ap.Audience{}
@@ -203,36 +309,75 @@ func (h *Handler) post(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo
 	}
 
 	if m := pollRegex.FindStringSubmatchIndex(note.Content); m != nil {
-		optionNames := strings.SplitN(note.Content[m[4]:], pollOptionsDelimeter, h.Config.PollMaxOptions+1)
+		// polls allow multiple choices unless :one is specified
+		multiple := true
+		duration := h.Config.PollDuration
+		for _, modifier := range strings.Split(note.Content[m[2]:m[3]], ":")[1:] {
+			if strings.EqualFold(modifier, "any") {
+				multiple = true
+				continue
+			}
+
+			if strings.EqualFold(modifier, "one") {
+				multiple = false
+				continue
+			}
+
+			d, ok := parsePollDuration(modifier)
+			if !ok {
+				w.Statusf(40, "Invalid poll option: %s", modifier)
+				return
+			}
+
+			if d > h.Config.PollMaxDuration {
+				w.Statusf(40, "Polls cannot last more than %s", h.Config.PollMaxDuration)
+				return
+			}
+
+			duration = d
+		}
+
+		optionNames := strings.SplitN(note.Content[m[6]:], pollOptionsDelimeter, h.Config.PollMaxOptions+1)
 		if len(optionNames) < pollMinOptions || len(optionNames) > h.Config.PollMaxOptions {
 			r.Log.Info("Received invalid poll", "content", note.Content)
 			w.Statusf(40, "Polls must have %d to %d options", pollMinOptions, h.Config.PollMaxOptions)
 			return
 		}
 
-		note.AnyOf = make([]ap.PollOption, len(optionNames))
+		options := make([]ap.PollOption, len(optionNames))
 
 		for i, optionName := range optionNames {
 			plainName, _ := plain.FromHTML(optionName)
-			note.AnyOf[i].Name = strings.TrimSpace(plainName)
+			options[i].Name = strings.TrimSpace(plainName)
 
-			if note.AnyOf[i].Name == "" {
+			if options[i].Name == "" {
 				w.Status(40, "Poll option cannot be empty")
 				return
 			}
 		}
 
+		if multiple {
+			note.AnyOf = options
+		} else {
+			note.OneOf = options
+		}
+
 		note.Type = ap.Question
-		note.Content = note.Content[m[2]:m[3]]
-		endTime := ap.Time{Time: time.Now().Add(h.Config.PollDuration)}
+		note.Content = note.Content[m[4]:m[5]]
+		endTime := ap.Time{Time: time.Now().Add(duration)}
 		note.EndTime = &endTime
 	}
 
-	if inReplyTo == nil || inReplyTo.Type != ap.Question {
+	if (inReplyTo == nil || inReplyTo.Type != ap.Question) && format == "gemtext" {
+		note.Content = plain.FromGemtext(note.Content, note.Tag)
+	} else if inReplyTo == nil || inReplyTo.Type != ap.Question {
 		note.Content = plain.ToHTML(note.Content, note.Tag)
 	}
 
-	var err error
+	if language != "" && note.Content != "" {
+		note.ContentMap = map[string]string{language: note.Content}
+	}
+
 	if oldNote != nil {
 		note.Published = oldNote.Published
 
@@ -257,9 +402,36 @@ func (h *Handler) post(w text.Writer, r *Request, oldNote *ap.Object, inReplyTo
 		return
 	}
 
+	h.InvalidatePost(&note)
+	if oldNote != nil {
+		h.InvalidatePost(oldNote)
+	}
+
 	if r.URL.Scheme == "titan" {
 		w.Redirectf("gemini://%s/users/view/%s", h.Domain, strings.TrimPrefix(postID, "https://"))
 	} else {
 		w.Redirectf("/users/view/%s", strings.TrimPrefix(postID, "https://"))
 	}
 }
+
+// parsePollDuration parses a poll duration like 30m, 12h or 3d.
+func parsePollDuration(s string) (time.Duration, bool) {
+	m := pollDurationRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil || n == 0 {
+		return 0, false
+	}
+
+	switch m[2] {
+	case "m":
+		return time.Duration(n) * time.Minute, true
+	case "h":
+		return time.Duration(n) * time.Hour, true
+	default:
+		return time.Duration(n) * time.Hour * 24, true
+	}
+}
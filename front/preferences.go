@@ -0,0 +1,235 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+const (
+	minPostsPerPage = 5
+	maxPostsPerPage = 100
+)
+
+// locations caches loaded time zones, because loading a time zone reads the time zone database.
+var locations sync.Map
+
+// loadLocation is like [time.LoadLocation] but caches time zones.
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// preferences are display preferences of a user.
+type preferences struct {
+	PostsPerPage int
+	HideShares   bool
+	RelativeTime bool
+	NoEmoji      bool
+	Location     *time.Location
+}
+
+// loadPreferences loads the display preferences of the signed in user.
+func (h *Handler) loadPreferences(r *Request) (preferences, error) {
+	var prefs preferences
+
+	if r.User == nil {
+		return prefs, nil
+	}
+
+	var postsPerPage sql.NullInt64
+	var hideShares, relativeTime, noEmoji sql.NullBool
+	var timeZone sql.NullString
+	if err := h.DB.QueryRowContext(r.Context, `select pagesize, hideshares, relativetime, noemoji, timezone from settings where actor = ?`, r.User.ID).Scan(&postsPerPage, &hideShares, &relativeTime, &noEmoji, &timeZone); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return prefs, err
+	}
+
+	prefs.PostsPerPage = int(postsPerPage.Int64)
+	prefs.HideShares = hideShares.Bool
+	prefs.RelativeTime = relativeTime.Bool
+	prefs.NoEmoji = noEmoji.Bool
+
+	if timeZone.Valid {
+		loc, err := loadLocation(timeZone.String)
+		if err != nil {
+			return prefs, err
+		}
+		prefs.Location = loc
+	}
+
+	return prefs, nil
+}
+
+// postsPerPage returns the number of posts to show in each page.
+func (h *Handler) postsPerPage(r *Request) int {
+	if r.preferences.PostsPerPage > 0 {
+		return r.preferences.PostsPerPage
+	}
+
+	return h.Config.PostsPerPage
+}
+
+// localTime converts a time to the user's time zone, if set.
+func localTime(r *Request, t time.Time) time.Time {
+	if r.preferences.Location == nil {
+		return t
+	}
+
+	return t.In(r.preferences.Location)
+}
+
+// formatTime formats the time a post was published or shared at.
+func formatTime(r *Request, t time.Time) string {
+	t = localTime(r, t)
+
+	if !r.preferences.RelativeTime {
+		return t.Format(time.DateOnly)
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", d/time.Minute)
+	case d < time.Hour*24:
+		return fmt.Sprintf("%dh ago", d/time.Hour)
+	case d < time.Hour*24*30:
+		return fmt.Sprintf("%dd ago", d/(time.Hour*24))
+	default:
+		return t.Format(time.DateOnly)
+	}
+}
+
+func (h *Handler) pageSize(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	input, ok := readQuery(w, r, fmt.Sprintf("Posts per page (%d-%d, 0 for default)", minPostsPerPage, maxPostsPerPage))
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || (n != 0 && (n < minPostsPerPage || n > maxPostsPerPage)) {
+		w.Status(40, "Invalid number of posts")
+		return
+	}
+
+	var postsPerPage sql.NullInt64
+	if n > 0 {
+		postsPerPage = sql.NullInt64{Int64: int64(n), Valid: true}
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, pagesize) values($1, $2) on conflict(actor) do update set pagesize = $2`,
+		r.User.ID,
+		postsPerPage,
+	); err != nil {
+		r.Log.Warn("Failed to set number of posts per page", "posts", n, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
+
+// setPreference returns a handler that enables or disables a boolean preference.
+func (h *Handler) setPreference(column, enable string) func(text.Writer, *Request, ...string) {
+	return func(w text.Writer, r *Request, args ...string) {
+		if r.User == nil {
+			w.Redirect("/users")
+			return
+		}
+
+		// null means disabled, which is the default
+		var value sql.NullBool
+		if args[1] == enable {
+			value = sql.NullBool{Bool: true, Valid: true}
+		}
+
+		if _, err := h.DB.ExecContext(
+			r.Context,
+			fmt.Sprintf(`insert into settings(actor, %s) values($1, $2) on conflict(actor) do update set %s = $2`, column, column),
+			r.User.ID,
+			value,
+		); err != nil {
+			r.Log.Warn("Failed to set preference", "preference", column, "value", args[1], "error", err)
+			w.Error()
+			return
+		}
+
+		w.Redirect("/users/settings")
+	}
+}
+
+func (h *Handler) timeZone(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	input, ok := readQuery(w, r, "Time zone (for example, Europe/Berlin or UTC)")
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(input)
+	if name == "" || name == "Local" {
+		w.Status(40, "Invalid time zone")
+		return
+	}
+
+	if _, err := loadLocation(name); err != nil {
+		r.Log.Info("Failed to load time zone", "time_zone", name, "error", err)
+		w.Status(40, "Invalid time zone")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into settings(actor, timezone) values($1, $2) on conflict(actor) do update set timezone = $2`,
+		r.User.ID,
+		name,
+	); err != nil {
+		r.Log.Warn("Failed to set time zone", "time_zone", name, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
@@ -30,6 +30,8 @@ import (
 	"github.com/dimkr/tootik/data"
 	"github.com/dimkr/tootik/front/text"
 	"github.com/dimkr/tootik/front/text/plain"
+	"github.com/dimkr/tootik/front/user"
+	inote "github.com/dimkr/tootik/inbox/note"
 )
 
 var verifiedRegex = regexp.MustCompile(`(\s*:[a-zA-Z0-9_]+:\s*)+`)
@@ -72,7 +74,7 @@ func getTextAndLinks(s string, maxRunes, maxLines int) ([]string, data.OrderedMa
 		}
 
 		// replace multiple empty lines with one […] line
-		if len(summary) > 0 && (len(summary) > 0 && summary[len(summary)-1] == "") {
+		if len(summary) > 0 && summary[len(summary)-1] == "" {
 			summary[len(summary)-1] = "[…]"
 		} else if len(summary) == maxLines-1 && summary[len(summary)-1] != "[…]" {
 			summary = append(summary, "[…]")
@@ -89,9 +91,7 @@ func getTextAndLinks(s string, maxRunes, maxLines int) ([]string, data.OrderedMa
 }
 
 func (h *Handler) getDisplayName(id, preferredUsername, name string, t ap.ActorType) string {
-	prefix := fmt.Sprintf("https://%s/user/", h.Domain)
-
-	isLocal := strings.HasPrefix(id, prefix)
+	_, isLocal := user.ParseID(h.Domain, h.Config, id)
 
 	emoji := "👽"
 	if t == ap.Group {
@@ -129,6 +129,10 @@ func (h *Handler) getActorDisplayName(actor *ap.Actor) string {
 }
 
 func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *ap.Actor, sharer *ap.Actor, published time.Time, compact, printAuthor, printParentAuthor, titleIsLink bool) {
+	h.printNote(w, r, note, author, sharer, 0, published, compact, printAuthor, printParentAuthor, titleIsLink)
+}
+
+func (h *Handler) printNote(w text.Writer, r *Request, note *ap.Object, author *ap.Actor, sharer *ap.Actor, otherSharers int, published time.Time, compact, printAuthor, printParentAuthor, titleIsLink bool) {
 	if note.AttributedTo == "" {
 		r.Log.Warn("Note has no author", "id", note.ID)
 		return
@@ -164,11 +168,25 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 		}
 	}
 
+	if note.Type == ap.Event && !note.Sensitive {
+		noteBody = eventBody(note, compact)
+	}
+
+	if (note.Type == ap.AudioObject || note.Type == ap.VideoObject) && !note.Sensitive {
+		noteBody = mediaBody(note, compact)
+	}
+
+	article := !compact && isArticle(note)
+	if article {
+		noteBody = note.Content
+	}
+
 	contentLines, inlineLinks := getTextAndLinks(noteBody, maxRunes, maxLines)
 
 	links := data.OrderedMap[string, string]{}
 
-	if note.URL != "" {
+	// the URL of a local post points to the post itself
+	if note.URL != "" && !strings.HasPrefix(note.ID, fmt.Sprintf("https://%s/", h.Domain)) {
 		links.Store(note.URL, "")
 	}
 
@@ -208,9 +226,9 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 
 	for _, attachment := range note.Attachment {
 		if attachment.URL != "" {
-			links.Store(attachment.URL, "")
+			links.Store(attachment.URL, mediaLabel(&attachment))
 		} else if attachment.Href != "" {
-			links.Store(attachment.Href, "")
+			links.Store(attachment.Href, mediaLabel(&attachment))
 		}
 	}
 
@@ -221,15 +239,30 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 
 	authorDisplayName := author.PreferredUsername
 
+	var sharers string
+	if sharer != nil && otherSharers == 1 {
+		sharers = sharer.PreferredUsername + " and 1 other you follow"
+	} else if sharer != nil && otherSharers > 1 {
+		sharers = fmt.Sprintf("%s and %d others you follow", sharer.PreferredUsername, otherSharers)
+	} else if sharer != nil {
+		sharers = sharer.PreferredUsername
+	}
+
+	// names are passed as arguments, so a [text.Writer] can tell them apart from symbols
 	var title string
+	var args []any
 	if printAuthor && sharer == nil {
-		title = fmt.Sprintf("%s %s", published.Format(time.DateOnly), authorDisplayName)
+		title = "%s %s"
+		args = []any{formatTime(r, published), authorDisplayName}
 	} else if printAuthor && sharer != nil {
-		title = fmt.Sprintf("%s %s ┃ 🔄 %s", published.Format(time.DateOnly), authorDisplayName, sharer.PreferredUsername)
+		title = "%s %s ┃ 🔄 %s"
+		args = []any{formatTime(r, published), authorDisplayName, sharers}
 	} else if sharer != nil {
-		title = fmt.Sprintf("%s 🔄 %s", published.Format(time.DateOnly), sharer.PreferredUsername)
+		title = "%s 🔄 %s"
+		args = []any{formatTime(r, published), sharers}
 	} else {
-		title = published.Format(time.DateOnly)
+		title = "%s"
+		args = []any{formatTime(r, published)}
 	}
 
 	if note.Updated != nil && *note.Updated != (ap.Time{}) {
@@ -277,21 +310,40 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 	}
 
 	if printParentAuthor && parentAuthor.Valid && parentAuthor.V.PreferredUsername != "" {
-		title += fmt.Sprintf(" ┃ RE: %s", parentAuthor.V.PreferredUsername)
+		title += " ┃ RE: %s"
+		args = append(args, parentAuthor.V.PreferredUsername)
 	} else if printParentAuthor && note.InReplyTo != "" && (!parentAuthor.Valid || parentAuthor.V.PreferredUsername == "") {
 		title += " ┃ RE: ?"
 	}
 
 	if !titleIsLink {
-		w.Link(note.ID, title)
+		w.Linkf(note.ID, title, args...)
 	} else if r.User == nil {
-		w.Link("/view/"+strings.TrimPrefix(note.ID, "https://"), title)
+		w.Linkf("/view/"+strings.TrimPrefix(note.ID, "https://"), title, args...)
+	} else {
+		w.Linkf("/users/view/"+strings.TrimPrefix(note.ID, "https://"), title, args...)
+	}
+
+	if article {
+		h.printArticle(w, r, note, contentLines)
 	} else {
-		w.Link("/users/view/"+strings.TrimPrefix(note.ID, "https://"), title)
+		for _, line := range contentLines {
+			w.Quote(line)
+		}
+	}
+
+	// replies in a feed are shown without their parent posts
+	if compact && printParentAuthor && note.InReplyTo != "" && r.User == nil {
+		w.Link("/context/"+strings.TrimPrefix(note.ID, "https://"), "🧵 Context")
+	} else if compact && printParentAuthor && note.InReplyTo != "" {
+		w.Link("/users/context/"+strings.TrimPrefix(note.ID, "https://"), "🧵 Context")
 	}
 
-	for _, line := range contentLines {
-		w.Quote(line)
+	if !compact && !strings.HasPrefix(note.ID, fmt.Sprintf("https://%s/", h.Domain)) {
+		if counts := h.getRemoteCounts(r, note).String(); counts != "" {
+			w.Empty()
+			w.Text(counts)
+		}
 	}
 
 	if !compact {
@@ -333,20 +385,20 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 1 as rank from shares
 						join notes on notes.id = shares.note
 						join persons on persons.id = shares.by
-						where shares.note = $1 and persons.actor->>'$.type' = 'Group'
+						where shares.note = $1 and shares.public = 1 and persons.actor->>'$.type' = 'Group'
 						union all
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 2 as rank from shares
 						join notes on notes.id = shares.note
 						join persons on persons.id = shares.by
-						where shares.note = $1
+						where shares.note = $1 and shares.public = 1
 						union all
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 3 as rank from shares
 						join persons on persons.id = shares.by
-						where shares.note = $1 and persons.host = $2
+						where shares.note = $1 and shares.public = 1 and persons.host = $2
 						union all
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 4 as rank from shares
 						join persons on persons.id = shares.by
-						where shares.note = $1 and persons.host != $2
+						where shares.note = $1 and shares.public = 1 and persons.host != $2
 					)
 					group by id
 					order by min(rank), inserted limit $3`,
@@ -362,25 +414,25 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 1 as rank from shares
 						join notes on notes.id = shares.note
 						join persons on persons.id = shares.by
-						where shares.note = $1 and persons.actor->>'$.type' = 'Group'
+						where shares.note = $1 and (shares.public = 1 or shares.by = $2) and persons.actor->>'$.type' = 'Group'
 						union all
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 2 as rank from shares
 						join notes on notes.id = shares.note
 						join persons on persons.id = shares.by
-						where shares.note = $1
+						where shares.note = $1 and (shares.public = 1 or shares.by = $2)
 						union all
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 3 as rank from shares
 						join follows on follows.followed = shares.by
 						join persons on persons.id = follows.followed
-						where shares.note = $1 and follows.follower = $2
+						where shares.note = $1 and follows.follower = $2 and (shares.public = 1 or follows.accepted = 1)
 						union all
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 4 as rank from shares
 						join persons on persons.id = shares.by
-						where shares.note = $1 and persons.host = $3
+						where shares.note = $1 and (shares.public = 1 or shares.by = $2) and persons.host = $3
 						union all
 						select persons.id, persons.actor->>'$.preferredUsername' as username, shares.inserted, 5 as rank from shares
 						join persons on persons.id = shares.by
-						where shares.note = $1 and persons.host != $3
+						where shares.note = $1 and (shares.public = 1 or shares.by = $2) and persons.host != $3
 					)
 					group by id
 					order by min(rank), inserted limit $4`,
@@ -432,6 +484,7 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 			w.Link(fmt.Sprintf("titan://%s/users/upload/edit/%s", h.Domain, strings.TrimPrefix(note.ID, "https://")), "Upload edited post")
 		}
 		if r.User != nil && note.AttributedTo == r.User.ID {
+			w.Link("/users/deliveries/"+strings.TrimPrefix(note.ID, "https://"), "📬 Deliveries")
 			w.Link("/users/delete/"+strings.TrimPrefix(note.ID, "https://"), "💣 Delete")
 		}
 		if r.User != nil && note.Type == ap.Question && note.Closed == nil && (note.EndTime == nil || time.Now().Before(note.EndTime.Time)) {
@@ -450,11 +503,16 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 				r.Log.Warn("Failed to check if post is shared", "id", note.ID, "error", err)
 			} else if shared == 0 {
 				w.Link("/users/share/"+strings.TrimPrefix(note.ID, "https://"), "🔁 Share")
+				w.Link("/users/share/followers/"+strings.TrimPrefix(note.ID, "https://"), "🔁 Share with followers")
 			} else {
 				w.Link("/users/unshare/"+strings.TrimPrefix(note.ID, "https://"), "🔄️ Unshare")
 			}
 		}
 
+		if r.User != nil && h.Config.TranslationURL != "" && !strings.HasPrefix(note.ID, fmt.Sprintf("https://%s/", h.Domain)) {
+			w.Link("/users/translate/"+strings.TrimPrefix(note.ID, "https://"), "🌐 Translate")
+		}
+
 		if r.User != nil {
 			var bookmarked int
 			if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from bookmarks where note = ? and by = ?)`, note.ID, r.User.ID).Scan(&bookmarked); err != nil {
@@ -467,26 +525,49 @@ func (h *Handler) PrintNote(w text.Writer, r *Request, note *ap.Object, author *
 		}
 
 		if r.User != nil {
-			w.Link("/users/reply/"+strings.TrimPrefix(note.ID, "https://"), "💬 Reply")
-			w.Link(fmt.Sprintf("titan://%s/users/upload/reply/%s", h.Domain, strings.TrimPrefix(note.ID, "https://")), "Upload reply")
+			// hide the reply links if the author doesn't allow replies by this user
+			if allowed, approval, err := inote.CanReply(r.Context, h.DB, note, author, r.User.ID); err != nil {
+				r.Log.Warn("Failed to check if user can reply", "id", note.ID, "error", err)
+			} else if allowed && approval {
+				w.Link("/users/reply/"+strings.TrimPrefix(note.ID, "https://"), "💬 Reply (requires approval)")
+				w.Link(fmt.Sprintf("titan://%s/users/upload/reply/%s", h.Domain, strings.TrimPrefix(note.ID, "https://")), "Upload reply")
+			} else if allowed {
+				w.Link("/users/reply/"+strings.TrimPrefix(note.ID, "https://"), "💬 Reply")
+				w.Link(fmt.Sprintf("titan://%s/users/upload/reply/%s", h.Domain, strings.TrimPrefix(note.ID, "https://")), "Upload reply")
+			}
 		}
 	}
 }
 
-func (h *Handler) PrintNotes(w text.Writer, r *Request, rows *sql.Rows, printParentAuthor, printDaySeparators bool, fallback string) int {
+func (h *Handler) PrintNotes(w text.Writer, r *Request, rows *sql.Rows, printParentAuthor, printDaySeparators bool, filters []filter, fallback string) int {
+	return h.printNotes(w, r, rows, printParentAuthor, printDaySeparators, false, filters, fallback)
+}
+
+// printNotes prints posts: if grouped is true, each row has an additional column with the number of other users who
+// shared the post.
+func (h *Handler) printNotes(w text.Writer, r *Request, rows *sql.Rows, printParentAuthor, printDaySeparators, grouped bool, filters []filter, fallback string) int {
 	var lastDay int64
 	count := 0
+	printed := 0
+
 	for rows.Next() {
 		var note ap.Object
 		var author sql.Null[ap.Actor]
 		var sharer sql.Null[ap.Actor]
 		var published int64
-		if err := rows.Scan(&note, &author, &sharer, &published); err != nil {
+		var otherSharers int
+		var err error
+		if grouped {
+			err = rows.Scan(&note, &author, &sharer, &published, &otherSharers)
+		} else {
+			err = rows.Scan(&note, &author, &sharer, &published)
+		}
+		if err != nil {
 			r.Log.Warn("Failed to scan post", "error", err)
 			continue
 		}
 
-		if note.Type != ap.Note && note.Type != ap.Page && note.Type != ap.Article && note.Type != ap.Question {
+		if note.Type != ap.Note && note.Type != ap.Page && note.Type != ap.Article && note.Type != ap.Question && note.Type != ap.Event && note.Type != ap.AudioObject && note.Type != ap.VideoObject {
 			r.Log.Warn("Post type is unsupported", "type", note.Type)
 			continue
 		}
@@ -496,25 +577,31 @@ func (h *Handler) PrintNotes(w text.Writer, r *Request, rows *sql.Rows, printPar
 			continue
 		}
 
+		if f := matchFilter(filters, &note); f != nil {
+			note.Sensitive = true
+			note.Summary = "Filtered: " + f.Phrase
+		}
+
 		currentDay := published / (60 * 60 * 24)
 
-		if count > 0 && printDaySeparators && currentDay != lastDay {
+		if printed > 0 && printDaySeparators && currentDay != lastDay {
 			w.Separator()
-		} else if count > 0 {
+		} else if printed > 0 {
 			w.Empty()
 		}
 
 		if sharer.Valid {
-			h.PrintNote(w, r, &note, &author.V, &sharer.V, time.Unix(published, 0), true, true, printParentAuthor, true)
+			h.printNote(w, r, &note, &author.V, &sharer.V, otherSharers, time.Unix(published, 0), true, true, printParentAuthor, true)
 		} else {
 			h.PrintNote(w, r, &note, &author.V, nil, time.Unix(published, 0), true, true, printParentAuthor, true)
 		}
 
 		lastDay = currentDay
 		count++
+		printed++
 	}
 
-	if count == 0 {
+	if printed == 0 {
 		w.Text(fallback)
 	}
 
@@ -0,0 +1,64 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/inbox"
+)
+
+func (h *Handler) refreshPoll(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	postID := "https://" + args[1]
+
+	var poll ap.Object
+	var updated int64
+	if err := h.DB.QueryRowContext(r.Context, `select object, max(inserted, updated) from notes where id = $1 and host != $2 and object->>'$.type' = 'Question'`, postID, h.Domain).Scan(&poll, &updated); err != nil && errors.Is(err, sql.ErrNoRows) {
+		r.Log.Warn("Attempted to refresh non-existing poll", "poll", postID, "error", err)
+		w.Status(40, "Poll not found")
+		return
+	} else if err != nil {
+		r.Log.Warn("Failed to fetch poll to refresh", "poll", postID, "error", err)
+		w.Error()
+		return
+	}
+
+	if time.Since(time.Unix(updated, 0)) < h.Config.MinPollRefreshInterval {
+		r.Log.Info("Poll results are up to date", "poll", poll.ID)
+		w.Redirect("/users/view/" + args[1])
+		return
+	}
+
+	r.Log.Info("Refreshing poll results", "poll", poll.ID)
+
+	if err := inbox.RefreshPoll(r.Context, h.Config, h.DB, h.Resolver, r.Key, &poll); err != nil {
+		r.Log.Warn("Failed to refresh poll results", "poll", poll.ID, "error", err)
+		w.Status(40, "Failed to refresh results")
+		return
+	}
+
+	w.Redirect("/users/view/" + args[1])
+}
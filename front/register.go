@@ -19,6 +19,9 @@ package front
 import (
 	"crypto/tls"
 	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/dimkr/tootik/ap"
@@ -33,7 +36,7 @@ func (h *Handler) register(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
-	tlsConn, ok := w.Unwrap().(*tls.Conn)
+	tlsConn, ok := w.Unwrap().(interface{ ConnectionState() tls.ConnectionState })
 	if !ok {
 		r.Log.Error("Invalid connection")
 		w.Error()
@@ -57,14 +60,23 @@ func (h *Handler) register(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
-	if userName == "" {
-		w.Status(40, "Invalid user name")
+	exists, err := user.CheckName(r.Context, h.Domain, h.Config, h.DB, userName)
+	if errors.Is(err, user.ErrNameTaken) {
+		w.Status(40, "Name is already taken")
 		return
-	}
-
-	if !h.Config.CompiledUserNameRegex.MatchString(userName) {
+	} else if errors.Is(err, user.ErrReservedName) {
+		w.Status(40, "Name is reserved")
+		return
+	} else if errors.Is(err, user.ErrConfusableName) {
+		w.Status(40, "Name contains confusable characters")
+		return
+	} else if errors.Is(err, user.ErrInvalidName) {
 		w.Status(40, "Invalid user name")
 		return
+	} else if err != nil {
+		r.Log.Warn("Failed to check user name", "name", userName, "error", err)
+		w.Error()
+		return
 	}
 
 	var lastRegister sql.NullInt64
@@ -82,13 +94,59 @@ func (h *Handler) register(w text.Writer, r *Request, args ...string) {
 		}
 	}
 
+	if h.Config.RequireInvitation {
+		// adding a certificate to an existing account doesn't require an invitation
+		if !exists {
+			if r.URL.RawQuery == "" {
+				w.Status(10, "Invitation code")
+				return
+			}
+
+			code, err := url.QueryUnescape(r.URL.RawQuery)
+			if err != nil {
+				r.Log.Info("Failed to decode invitation code", "name", userName, "error", err)
+				w.Status(40, "Bad input")
+				return
+			}
+
+			r.Log.Info("Creating new invited user", "name", userName)
+
+			if _, _, err := user.CreateInvited(r.Context, h.Domain, h.Config, h.DB, userName, strings.TrimSpace(code), clientCert); errors.Is(err, user.ErrInvalidInvitation) {
+				w.Status(40, "Invalid invitation code")
+				return
+			} else if errors.Is(err, user.ErrNameTaken) {
+				w.Status(40, "Name is already taken")
+				return
+			} else if err != nil {
+				r.Log.Warn("Failed to create new user", "name", userName, "error", err)
+				w.Status(40, "Failed to create new user")
+				return
+			}
+
+			if len(h.Config.Rules) > 0 {
+				w.Redirect("/users/rules")
+			} else {
+				w.Redirect("/users")
+			}
+			return
+		}
+	}
+
 	r.Log.Info("Creating new user", "name", userName)
 
-	if _, _, err := user.Create(r.Context, h.Domain, h.DB, userName, ap.Person, clientCert); err != nil {
+	if _, _, err := user.Create(r.Context, h.Domain, h.Config, h.DB, userName, ap.Person, clientCert); errors.Is(err, user.ErrNameTaken) {
+		w.Status(40, "Name is already taken")
+		return
+	} else if err != nil {
 		r.Log.Warn("Failed to create new user", "name", userName, "error", err)
 		w.Status(40, "Failed to create new user")
 		return
 	}
 
-	w.Redirect("/users")
+	// new users see the rules they must accept before they can post
+	if len(h.Config.Rules) > 0 {
+		w.Redirect("/users/rules")
+	} else {
+		w.Redirect("/users")
+	}
 }
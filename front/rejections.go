@@ -0,0 +1,143 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+const rejectionsPerPage = 20
+
+var rejectionReasons = map[string]string{
+	"signature": "Invalid signature",
+	"blocked":   "Blocked domain",
+	"young":     "New actor",
+	"invalid":   "Invalid activity",
+}
+
+func (h *Handler) rejections(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if !h.isAdmin(r) {
+		r.Log.Warn("User is not an admin")
+		w.Status(40, "Forbidden")
+		return
+	}
+
+	offset, err := getOffset(r.URL)
+	if err != nil {
+		r.Log.Info("Failed to parse query", "url", r.URL, "error", err)
+		w.Status(40, "Invalid query")
+		return
+	}
+
+	if offset > h.Config.MaxOffset {
+		r.Log.Warn("Offset is too big", "offset", offset)
+		w.Statusf(40, "Offset must be <= %d", h.Config.MaxOffset)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(
+		r.Context,
+		`select activity, sender, host, client, reason, error, verified, inserted from rejections
+		order by rowid desc
+		limit $1
+		offset $2`,
+		rejectionsPerPage,
+		offset,
+	)
+	if err != nil {
+		r.Log.Warn("Failed to fetch rejections", "error", err)
+		w.Error()
+		return
+	}
+	defer rows.Close()
+
+	w.OK()
+
+	if offset > 0 {
+		w.Titlef("🚫 Rejected Activities (%d-%d)", offset, offset+rejectionsPerPage)
+	} else {
+		w.Title("🚫 Rejected Activities")
+	}
+
+	count := 0
+	for rows.Next() {
+		var activity, sender, host, client sql.NullString
+		var reason, errString string
+		var verified bool
+		var inserted int64
+		if err := rows.Scan(&activity, &sender, &host, &client, &reason, &errString, &verified, &inserted); err != nil {
+			r.Log.Warn("Failed to scan rejection", "error", err)
+			continue
+		}
+
+		if count > 0 {
+			w.Empty()
+		}
+
+		label, ok := rejectionReasons[reason]
+		if !ok {
+			label = reason
+		}
+
+		if host.Valid && verified {
+			w.Subtitlef("%s: %s", text.SingleLine(host.String), label)
+		} else if host.Valid {
+			w.Subtitlef("%s (unverified): %s", text.SingleLine(host.String), label)
+		} else {
+			w.Subtitle(label)
+		}
+
+		w.Itemf("Time: %s", time.Unix(inserted, 0).UTC().Format(time.DateTime))
+		if activity.Valid {
+			w.Itemf("Activity: %s", text.SingleLine(activity.String))
+		}
+		if sender.Valid {
+			w.Itemf("Sender: %s", text.SingleLine(sender.String))
+		}
+		if client.Valid {
+			w.Itemf("Client: %s", text.SingleLine(client.String))
+		}
+		w.Itemf("Error: %s", text.SingleLine(errString))
+
+		count++
+	}
+
+	if count == 0 {
+		w.Text("No rejected activities.")
+	}
+
+	if offset >= rejectionsPerPage || count == rejectionsPerPage {
+		w.Separator()
+	}
+
+	if offset >= rejectionsPerPage {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset-rejectionsPerPage), "Previous page (%d-%d)", offset-rejectionsPerPage, offset)
+	}
+
+	if count == rejectionsPerPage && offset+rejectionsPerPage <= h.Config.MaxOffset {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+rejectionsPerPage), "Next page (%d-%d)", offset+rejectionsPerPage, offset+2*rejectionsPerPage)
+	}
+}
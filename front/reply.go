@@ -22,6 +22,7 @@ import (
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/front/text"
+	inote "github.com/dimkr/tootik/inbox/note"
 )
 
 func (h *Handler) doReply(w text.Writer, r *Request, args []string, readInput inputFunc) {
@@ -33,10 +34,11 @@ func (h *Handler) doReply(w text.Writer, r *Request, args []string, readInput in
 	postID := "https://" + args[1]
 
 	var note ap.Object
+	var author ap.Actor
 	if err := h.DB.QueryRowContext(
 		r.Context,
 		`
-		select notes.object from notes
+		select notes.object, persons.actor from notes
 		join persons on persons.id = notes.author
 		where
 			notes.id = $1 and
@@ -64,7 +66,7 @@ func (h *Handler) doReply(w text.Writer, r *Request, args []string, readInput in
 		`,
 		postID,
 		r.User.ID,
-	).Scan(&note); err != nil && errors.Is(err, sql.ErrNoRows) {
+	).Scan(&note, &author); err != nil && errors.Is(err, sql.ErrNoRows) {
 		r.Log.Warn("Post does not exist", "post", postID)
 		w.Status(40, "Post not found")
 		return
@@ -74,6 +76,19 @@ func (h *Handler) doReply(w text.Writer, r *Request, args []string, readInput in
 		return
 	}
 
+	// votes are replies, but the interaction policy shouldn't prevent users from voting
+	if note.Type != ap.Question {
+		if allowed, _, err := inote.CanReply(r.Context, h.DB, &note, &author, r.User.ID); err != nil {
+			r.Log.Warn("Failed to check if user can reply", "post", note.ID, "error", err)
+			w.Error()
+			return
+		} else if !allowed {
+			r.Log.Warn("User cannot reply to post", "post", note.ID)
+			w.Status(40, "Replies are restricted")
+			return
+		}
+	}
+
 	r.Log.Info("Replying to post", "post", note.ID)
 
 	to := ap.Audience{}
@@ -45,4 +45,6 @@ type Request struct {
 
 	// Key optionally specifies the signing key associated with User.
 	Key httpsig.Key
+
+	preferences preferences
 }
@@ -0,0 +1,99 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+// rulesAccepted checks if the user has accepted the current version of the server rules.
+func (h *Handler) rulesAccepted(r *Request) (bool, error) {
+	if len(h.Config.Rules) == 0 {
+		return true, nil
+	}
+
+	var accepted int
+	if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from rulesacceptance where actor = ? and version = ?)`, r.User.ID, h.Config.RulesVersion).Scan(&accepted); err != nil {
+		return false, err
+	}
+
+	return accepted == 1, nil
+}
+
+func (h *Handler) rules(w text.Writer, r *Request, args ...string) {
+	var accepted sql.NullInt64
+	if r.User != nil && len(h.Config.Rules) > 0 {
+		if err := h.DB.QueryRowContext(r.Context, `select inserted from rulesacceptance where actor = ? and version = ?`, r.User.ID, h.Config.RulesVersion).Scan(&accepted); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			r.Log.Warn("Failed to check if rules were accepted", "version", h.Config.RulesVersion, "error", err)
+			w.Error()
+			return
+		}
+	}
+
+	w.OK()
+	w.Title("📜 Rules")
+
+	if len(h.Config.Rules) == 0 {
+		w.Text("This server has no rules.")
+		return
+	}
+
+	for _, rule := range h.Config.Rules {
+		w.Item(rule)
+	}
+
+	if r.User == nil {
+		w.Empty()
+		w.Text("Users must accept these rules before they can post.")
+		return
+	}
+
+	w.Empty()
+
+	if accepted.Valid {
+		w.Textf("You accepted these rules on %s.", time.Unix(accepted.Int64, 0).Format(time.DateOnly))
+	} else {
+		w.Text("You must accept these rules before you can post.")
+		w.Link("/users/rules/accept", "✅ Accept rules")
+	}
+}
+
+func (h *Handler) acceptRules(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if len(h.Config.Rules) == 0 {
+		w.Redirect("/users/rules")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(r.Context, `insert into rulesacceptance(actor, version) values(?, ?) on conflict(actor, version) do nothing`, r.User.ID, h.Config.RulesVersion); err != nil {
+		r.Log.Warn("Failed to accept rules", "version", h.Config.RulesVersion, "error", err)
+		w.Error()
+		return
+	}
+
+	r.Log.Info("Accepted rules", "version", h.Config.RulesVersion)
+
+	w.Redirect("/users")
+}
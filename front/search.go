@@ -17,12 +17,15 @@ limitations under the License.
 package front
 
 import (
+	"database/sql"
+	"errors"
 	"net/url"
+	"strings"
 
 	"github.com/dimkr/tootik/front/text"
 )
 
-func search(w text.Writer, r *Request, args ...string) {
+func (h *Handler) search(w text.Writer, r *Request, args ...string) {
 	if r.URL.RawQuery == "" {
 		w.Status(10, "Hashtag")
 		return
@@ -35,6 +38,21 @@ func search(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
+	if strings.Contains(hashtag, "://") {
+		postID, err := h.resolvePostURL(r, strings.TrimSpace(hashtag))
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Status(40, "Post not found")
+		} else if err != nil {
+			r.Log.Warn("Failed to resolve post URL", "url", hashtag, "error", err)
+			w.Error()
+		} else if r.User == nil {
+			w.Redirect("/view/" + strings.TrimPrefix(postID, "https://"))
+		} else {
+			w.Redirect("/users/view/" + strings.TrimPrefix(postID, "https://"))
+		}
+		return
+	}
+
 	if r.User == nil && hashtag[0] == '#' {
 		w.Redirect("/hashtag/" + hashtag[1:])
 	} else if r.User == nil {
@@ -26,30 +26,19 @@ import (
 	"github.com/dimkr/tootik/outbox"
 )
 
-func (h *Handler) shouldThrottleShare(r *Request) (bool, error) {
-	now := time.Now()
-
-	var today, last sql.NullInt64
-	if err := h.DB.QueryRowContext(r.Context, `select count(*), max(inserted) from outbox where activity->>'$.actor' = $1 and sender = $1 and (activity->>'$.type' = 'Announce' or activity->>'$.type' = 'Undo') and inserted > $2`, r.User.ID, now.Add(-24*time.Hour).Unix()).Scan(&today, &last); err != nil {
-		return false, err
-	}
-
-	if !last.Valid {
-		return false, nil
-	}
-
-	t := time.Unix(last.Int64, 0)
-	interval := max(1, time.Duration(today.Int64/h.Config.ShareThrottleFactor)) * h.Config.ShareThrottleUnit
-	return now.Sub(t) < interval, nil
-}
-
 func (h *Handler) share(w text.Writer, r *Request, args ...string) {
 	if r.User == nil {
 		w.Redirect("/users")
 		return
 	}
 
-	postID := "https://" + args[1]
+	if r.User.MovedTo != "" {
+		r.Log.Warn("Moved user cannot share posts", "movedTo", r.User.MovedTo)
+		w.Status(40, "Account was moved to "+r.User.MovedTo)
+		return
+	}
+
+	postID := "https://" + args[2]
 
 	var note ap.Object
 	if err := h.DB.QueryRowContext(r.Context, `select object from notes where id = $1 and public = 1 and author != $2 and not exists (select 1 from shares where note = notes.id and by = $2)`, postID, r.User.ID).Scan(&note); err != nil && errors.Is(err, sql.ErrNoRows) {
@@ -62,11 +51,11 @@ func (h *Handler) share(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
-	if throttle, err := h.shouldThrottleShare(r); err != nil {
+	if _, can, err := h.shareQuota(r, time.Now()); err != nil {
 		r.Log.Warn("Failed to check if share needs to be throttled", "error", err)
 		w.Error()
 		return
-	} else if throttle {
+	} else if time.Now().Before(can) {
 		r.Log.Warn("User is sharing and unsharing too frequently")
 		w.Status(40, "Please wait before sharing")
 		return
@@ -80,7 +69,8 @@ func (h *Handler) share(w text.Writer, r *Request, args ...string) {
 	}
 	defer tx.Rollback()
 
-	if err := outbox.Announce(r.Context, h.Domain, tx, r.User, &note); err != nil {
+	// /users/share/followers/ shares the post with followers only
+	if err := outbox.Announce(r.Context, h.Domain, tx, r.User, &note, args[1] == ""); err != nil {
 		r.Log.Warn("Failed to share post", "post", postID, "error", err)
 		w.Error()
 		return
@@ -92,5 +82,5 @@ func (h *Handler) share(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
-	w.Redirectf("/users/view/" + args[1])
+	w.Redirectf("/users/view/" + args[2])
 }
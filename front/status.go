@@ -24,8 +24,8 @@ import (
 	"github.com/dimkr/tootik/front/text"
 )
 
-func (h *Handler) getGraph(r *Request, query string, keys []string, values []int64) string {
-	rows, err := h.DB.QueryContext(r.Context, query)
+func (h *Handler) getGraph(r *Request, query string, keys []string, values []int64, args ...any) string {
+	rows, err := h.DB.QueryContext(r.Context, query, args...)
 	if err != nil {
 		r.Log.Warn("Failed to data points", "query", query, "error", err)
 		return ""
@@ -33,7 +33,7 @@ func (h *Handler) getGraph(r *Request, query string, keys []string, values []int
 	defer rows.Close()
 
 	i := 0
-	for rows.Next() {
+	for i < len(keys) && rows.Next() {
 		if err := rows.Scan(&keys[i], &values[i]); err != nil {
 			r.Log.Warn("Failed to data point", "error", err)
 			i++
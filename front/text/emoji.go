@@ -0,0 +1,246 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package text
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+type emojiFilter struct {
+	Writer
+}
+
+// emojiRanges are ranges of code points used by emoji.
+var emojiRanges = [][2]rune{
+	{0x200d, 0x200d},   // zero width joiner
+	{0x231a, 0x231b},   // watch, hourglass
+	{0x23e9, 0x23fa},   // media controls, alarm clock, stopwatch
+	{0x25a0, 0x25ff},   // geometric shapes
+	{0x2600, 0x27bf},   // miscellaneous symbols, dingbats
+	{0x2b00, 0x2bff},   // stars, arrows and squares
+	{0xfe0f, 0xfe0f},   // emoji presentation selector
+	{0x1f000, 0x1faff}, // emoticons, pictographs, flags and more
+	{0xe0020, 0xe007f}, // tags
+}
+
+// asciiLabels are ASCII replacements for emoji and symbols used in pages.
+var asciiLabels = map[rune]string{
+	'📻': "[feed]",
+	'📞': "[mentions]",
+	'📰': "[digest]",
+	'⚡': "[follow]",
+	'😈': "[person]",
+	'👥': "[group]",
+	'🤖': "[bot]",
+	'👽': "[user]",
+	'📡': "[local]",
+	'🏕': "[communities]",
+	'🔥': "[hashtags]",
+	'🔭': "[profile]",
+	'🔖': "[bookmark]",
+	'🔎': "[search]",
+	'📣': "[post]",
+	'⚙': "[settings]",
+	'📊': "[status]",
+	'🛟': "[help]",
+	'💬': "[reply]",
+	'🔁': "[share]",
+	'🔄': "[share]",
+	'🔔': "[followers]",
+	'💌': "[dm]",
+	'🧵': "[thread]",
+	'🔗': "[link]",
+	'📖': "[read]",
+	'📅': "[event]",
+	'✅': "[yes]",
+	'❌': "[no]",
+	'🤔': "[maybe]",
+	'✓': "[verified]",
+	'👤': "[mention]",
+	'📌': "[pinned]",
+	'♻': "[shares]",
+	'⭐': "[likes]",
+	'⏳': "[recent]",
+	'🗞': "[rss]",
+	'📜': "[rules]",
+	'┃': "|",
+	'·': ".",
+	'─': "-",
+	'…': "...",
+	'→': "->",
+	'↳': "->",
+}
+
+func isEmoji(r rune) bool {
+	for _, rng := range emojiRanges {
+		if r >= rng[0] && r <= rng[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StripEmoji removes emoji from a string, with the space that follows an emoji at the beginning of the string or after another space.
+func StripEmoji(s string) string {
+	return replaceEmoji(s, nil)
+}
+
+// ToASCII replaces emoji and symbols used in pages with ASCII labels, and removes other emoji with the space that follows them.
+func ToASCII(s string) string {
+	return replaceEmoji(s, asciiLabels)
+}
+
+func replaceEmoji(s string, labels map[rune]string) string {
+	var b strings.Builder
+	stripped := false
+
+	for _, r := range s {
+		if label, ok := labels[r]; ok {
+			b.WriteString(label)
+			stripped = false
+			continue
+		}
+
+		if isEmoji(r) {
+			stripped = true
+			continue
+		}
+
+		if r == ' ' && stripped {
+			if prev := b.String(); prev == "" || strings.HasSuffix(prev, " ") {
+				stripped = false
+				continue
+			}
+		}
+
+		if r != ' ' {
+			stripped = false
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// labelIcon replaces the emoji or symbol at the beginning of a UI element with an ASCII label, and removes other emoji,
+// which may be part of user-provided content like a display name.
+func labelIcon(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if label, ok := asciiLabels[r]; ok {
+		return label + StripEmoji(strings.TrimLeft(s[size:], "\ufe0f"))
+	}
+
+	return StripEmoji(s)
+}
+
+// sprintf replaces emoji and symbols in format with ASCII labels and removes emoji from string arguments, which may
+// contain user-provided content.
+func sprintf(format string, a []any) string {
+	args := make([]any, len(a))
+	for i, arg := range a {
+		if s, ok := arg.(string); ok {
+			args[i] = StripEmoji(s)
+		} else {
+			args[i] = arg
+		}
+	}
+
+	return fmt.Sprintf(ToASCII(format), args...)
+}
+
+// ASCII wraps a [Writer] with a [Writer] that replaces emoji and symbols in UI elements with ASCII labels, removes
+// emoji from text and leaves preformatted text intact.
+func ASCII(w Writer) Writer {
+	return &emojiFilter{w}
+}
+
+// Write writes p as is, because it's the output of a clone and already filtered.
+func (w *emojiFilter) Write(p []byte) (int, error) {
+	return w.Writer.Write(p)
+}
+
+// Clone returns a filtering [Writer], so preformatted text written to the clone stays intact.
+func (w *emojiFilter) Clone(inner io.Writer) Writer {
+	return &emojiFilter{w.Writer.Clone(inner)}
+}
+
+func (w *emojiFilter) Status(code int, meta string) {
+	w.Writer.Status(code, labelIcon(meta))
+}
+
+func (w *emojiFilter) Statusf(code int, format string, a ...any) {
+	w.Writer.Status(code, sprintf(format, a))
+}
+
+func (w *emojiFilter) Title(title string) {
+	w.Writer.Title(labelIcon(title))
+}
+
+func (w *emojiFilter) Titlef(format string, a ...any) {
+	w.Writer.Title(sprintf(format, a))
+}
+
+func (w *emojiFilter) Subtitle(subtitle string) {
+	w.Writer.Subtitle(labelIcon(subtitle))
+}
+
+func (w *emojiFilter) Subtitlef(format string, a ...any) {
+	w.Writer.Subtitle(sprintf(format, a))
+}
+
+func (w *emojiFilter) Text(line string) {
+	w.Writer.Text(StripEmoji(line))
+}
+
+func (w *emojiFilter) Textf(format string, a ...any) {
+	w.Writer.Text(sprintf(format, a))
+}
+
+func (w *emojiFilter) Link(url, name string) {
+	w.Writer.Link(url, labelIcon(name))
+}
+
+func (w *emojiFilter) Linkf(url, format string, a ...any) {
+	w.Writer.Link(url, sprintf(format, a))
+}
+
+func (w *emojiFilter) Item(item string) {
+	w.Writer.Item(labelIcon(item))
+}
+
+func (w *emojiFilter) Itemf(format string, a ...any) {
+	w.Writer.Item(sprintf(format, a))
+}
+
+func (w *emojiFilter) Quote(quote string) {
+	w.Writer.Quote(StripEmoji(quote))
+}
+
+func (w *emojiFilter) Raw(alt, raw string) {
+	w.Writer.Raw(alt, raw)
+}
+
+func (w *emojiFilter) Separator() {
+	w.Writer.Empty()
+	w.Writer.Text("----")
+	w.Writer.Empty()
+}
@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"html"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/data"
@@ -41,13 +43,244 @@ var (
 	urlRegex          = regexp.MustCompile(`\b(https|http|gemini|titan|gopher|gophers|spartan|guppy):\/\/\S+\b`)
 	pDelim            = regexp.MustCompile(`([^\n])\n\n+([^\n])`)
 	mentionRegex      = regexp.MustCompile(`\B@(\w+)(?:@(?:(?:\w+\.)+\w+(?::\d{1,5}){0,1})){0,1}\b`)
+	preTags           = regexp.MustCompile(`(?s)<pre(?:\s+[^>]*)?>(.*?)</pre>`)
+	blockquoteTags    = regexp.MustCompile(`(?s)<blockquote(?:\s+[^>]*)?>(.*?)</blockquote>`)
+	listTags          = regexp.MustCompile(`<(/?)(ul|ol|li)(?:\s+[^>]*)?>`)
+	codeTags          = regexp.MustCompile(`(?s)<code(?:\s+[^>]*)?>(.*?)</code>`)
+	tableTags         = regexp.MustCompile(`(?s)<table(?:\s+[^>]*)?>(.*?)</table>`)
+	trTags            = regexp.MustCompile(`(?s)<tr(?:\s+[^>]*)?>(.*?)(?:</tr>|$)`)
+	cellTags          = regexp.MustCompile(`(?s)<(td|th)(?:\s+[^>]*)?>(.*?)(?:</(?:td|th)>|$)`)
+	spaces            = regexp.MustCompile(`\s+`)
+	blockTag          = `</?(?:p|h\d|div|ul|ol|li|blockquote|table|thead|tbody|tr|th|td)(?:\s+[^>]*)?>`
+	blockTagSpace     = regexp.MustCompile(`(` + blockTag + `)\s+(` + blockTag + `)`)
+	blockTagNewline   = regexp.MustCompile(`(?:\s*\n\s*)?(` + blockTag + `)(?:\s*\n\s*)?`)
+	preBlocks         = regexp.MustCompile(`\x01(\d+)\x02`)
+	placeholderChars  = regexp.MustCompile(`[\x00-\x02]`)
+	numericEntities   = regexp.MustCompile(`&#(?:[xX]([0-9a-fA-F]+)|([0-9]+));?`)
+	firstBlockDelim   = regexp.MustCompile(`^[\s\x00]*\x00[\n\x00]*`)
+	blockDelim        = regexp.MustCompile(`[ \t\n]*\x00[\n\x00]*`)
 )
 
+// blockBreak separates a block (a list, a quote or preformatted text) from surrounding text.
+const blockBreak = "\x00"
+
+// convertLists replaces list items with "* " or a number, indented by nesting depth.
+func convertLists(s string) string {
+	if !strings.Contains(s, "<li") {
+		return s
+	}
+
+	var b strings.Builder
+	var counters []int
+
+	for {
+		loc := listTags.FindStringSubmatchIndex(s)
+		if loc == nil {
+			break
+		}
+
+		b.WriteString(s[:loc[0]])
+		closing := loc[3] > loc[2]
+		tag := s[loc[4]:loc[5]]
+		s = s[loc[1]:]
+
+		switch {
+		case tag == "li" && closing:
+
+		case tag == "li":
+			b.WriteByte('\n')
+			if len(counters) > 1 {
+				b.WriteString(strings.Repeat("  ", len(counters)-1))
+			}
+			if len(counters) == 0 || counters[len(counters)-1] < 0 {
+				b.WriteString("* ")
+			} else {
+				counters[len(counters)-1]++
+				fmt.Fprintf(&b, "%d. ", counters[len(counters)-1])
+			}
+
+		case closing:
+			if len(counters) > 0 {
+				counters = counters[:len(counters)-1]
+			}
+			if len(counters) == 0 {
+				b.WriteString(blockBreak)
+			}
+
+		default:
+			if len(counters) == 0 {
+				b.WriteString(blockBreak)
+			}
+			if tag == "ol" {
+				counters = append(counters, 0)
+			} else {
+				counters = append(counters, -1)
+			}
+		}
+	}
+
+	b.WriteString(s)
+	return b.String()
+}
+
+// stripTags removes all tags from HTML and unescapes it.
+func stripTags(s string) string {
+	s = brTags.ReplaceAllString(s, "\n")
+	s = openTags.ReplaceAllString(s, "")
+	s = closeTags.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
+
+// convertTable renders a table as aligned columns, with a line under the header row.
+func convertTable(table string, links data.OrderedMap[string, string]) string {
+	var rows [][]string
+	var widths []int
+	header := false
+
+	for _, tr := range trTags.FindAllStringSubmatch(table, -1) {
+		var row []string
+		for i, cell := range cellTags.FindAllStringSubmatch(tr[1], -1) {
+			if len(rows) == 0 && cell[1] == "th" {
+				header = true
+			}
+
+			for _, m := range aTags.FindAllStringSubmatch(cell[2], -1) {
+				if !links.Contains(m[1]) {
+					links.Store(m[1], "")
+				}
+			}
+
+			text := strings.TrimSpace(spaces.ReplaceAllString(stripTags(cell[2]), " "))
+			row = append(row, text)
+
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(text))
+		}
+
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("```")
+
+	for i, row := range rows {
+		b.WriteByte('\n')
+
+		var line strings.Builder
+		for j, cell := range row {
+			if j > 0 {
+				line.WriteString(" | ")
+			}
+			line.WriteString(cell)
+			if j < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)))
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+
+		if i == 0 && header {
+			b.WriteByte('\n')
+			for j, width := range widths {
+				if j > 0 {
+					b.WriteString("-+-")
+				}
+				b.WriteString(strings.Repeat("-", width))
+			}
+		}
+	}
+
+	b.WriteString("\n```")
+	return b.String()
+}
+
 // FromHTML converts HTML to plain text and extracts links.
+// List items start with "* " or a number, quotes start with "> " and preformatted text and tables are surrounded by "```" lines.
 func FromHTML(text string) (string, data.OrderedMap[string, string]) {
-	res := html.UnescapeString(text)
 	links := data.OrderedMap[string, string]{}
 
+	// the characters used as placeholders can't appear in the input, even if escaped
+	text = placeholderChars.ReplaceAllString(text, "")
+	text = numericEntities.ReplaceAllStringFunc(text, func(m string) string {
+		groups := numericEntities.FindStringSubmatch(m)
+		var c uint64
+		var err error
+		if groups[1] != "" {
+			c, err = strconv.ParseUint(groups[1], 16, 32)
+		} else {
+			c, err = strconv.ParseUint(groups[2], 10, 32)
+		}
+		if err == nil && c <= 2 {
+			return ""
+		}
+		return m
+	})
+
+	// preformatted text is unescaped separately and restored after all other tags are removed
+	var pre []string
+	text = preTags.ReplaceAllStringFunc(text, func(m string) string {
+		pre = append(pre, "```\n"+strings.Trim(stripTags(preTags.FindStringSubmatch(m)[1]), "\n")+"\n```")
+		return fmt.Sprintf("%s\x01%d\x02%s", blockBreak, len(pre)-1, blockBreak)
+	})
+
+	// code outside of <pre> is preformatted only if it spans multiple lines
+	text = codeTags.ReplaceAllStringFunc(text, func(m string) string {
+		inner := codeTags.FindStringSubmatch(m)[1]
+		if !strings.Contains(inner, "\n") && !brTags.MatchString(inner) {
+			return m
+		}
+		pre = append(pre, "```\n"+strings.Trim(stripTags(inner), "\n")+"\n```")
+		return fmt.Sprintf("%s\x01%d\x02%s", blockBreak, len(pre)-1, blockBreak)
+	})
+
+	// whitespace between block elements and line breaks around them are insignificant
+	text = blockTagNewline.ReplaceAllString(text, "$1")
+	for {
+		stripped := blockTagSpace.ReplaceAllString(text, "$1$2")
+		if stripped == text {
+			break
+		}
+		text = stripped
+	}
+
+	text = tableTags.ReplaceAllStringFunc(text, func(m string) string {
+		pre = append(pre, convertTable(tableTags.FindStringSubmatch(m)[1], links))
+		return fmt.Sprintf("%s\x01%d\x02%s", blockBreak, len(pre)-1, blockBreak)
+	})
+
+	res := convert(html.UnescapeString(text), links)
+
+	if len(pre) > 0 {
+		res = preBlocks.ReplaceAllStringFunc(res, func(m string) string {
+			i, err := strconv.Atoi(preBlocks.FindStringSubmatch(m)[1])
+			if err != nil || i >= len(pre) {
+				return ""
+			}
+			return pre[i]
+		})
+	}
+
+	return res, links
+}
+
+func convert(res string, links data.OrderedMap[string, string]) string {
+	res = blockquoteTags.ReplaceAllStringFunc(res, func(m string) string {
+		lines := strings.Split(convert(blockquoteTags.FindStringSubmatch(m)[1], links), "\n")
+		for i, line := range lines {
+			if line == "" {
+				lines[i] = ">"
+			} else {
+				lines[i] = "> " + line
+			}
+		}
+		return blockBreak + strings.Join(lines, "\n") + blockBreak
+	})
+
+	res = convertLists(res)
+
 	for _, m := range mentionTags.FindAllString(res, -1) {
 		res = strings.Replace(res, m, "", 1)
 	}
@@ -116,7 +349,12 @@ func FromHTML(text string) (string, data.OrderedMap[string, string]) {
 		res = strings.Replace(res, m, "", 1)
 	}
 
-	return strings.TrimRight(res, " \n\r\t"), links
+	if strings.Contains(res, blockBreak) {
+		res = firstBlockDelim.ReplaceAllString(res, "")
+		res = blockDelim.ReplaceAllString(res, "\n\n")
+	}
+
+	return strings.TrimRight(res, " \n\r\t")
 }
 
 // ToHTML converts plain text to HTML.
@@ -125,6 +363,15 @@ func ToHTML(text string, tags []ap.Tag) string {
 		return ""
 	}
 
+	text = linkify(text, tags)
+
+	text = pDelim.ReplaceAllString(text, "$1</p><p>$2")
+	text = strings.ReplaceAll(text, "\n", "<br/>")
+	return fmt.Sprintf("<p>%s</p>", text)
+}
+
+// linkify wraps URLs and mentions with <a> tags.
+func linkify(text string, tags []ap.Tag) string {
 	var b strings.Builder
 
 	foundLink := false
@@ -171,7 +418,5 @@ func ToHTML(text string, tags []ap.Tag) string {
 		text = b.String()
 	}
 
-	text = pDelim.ReplaceAllString(text, "$1</p><p>$2")
-	text = strings.ReplaceAll(text, "\n", "<br/>")
-	return fmt.Sprintf("<p>%s</p>", text)
+	return text
 }
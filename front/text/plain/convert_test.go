@@ -17,6 +17,9 @@ limitations under the License.
 package plain
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/dimkr/tootik/ap"
@@ -24,6 +27,59 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestFromHTML_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatalf("Failed to list test files: %v", err)
+	}
+
+	for _, input := range inputs {
+		t.Run(strings.TrimSuffix(filepath.Base(input), ".html"), func(t *testing.T) {
+			html, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", input, err)
+			}
+
+			expected, err := os.ReadFile(strings.TrimSuffix(input, ".html") + ".txt")
+			if err != nil {
+				t.Fatalf("Failed to read expected output for %s: %v", input, err)
+			}
+
+			raw, _ := FromHTML(string(html))
+			assert.Equal(t, strings.TrimSuffix(string(expected), "\n"), raw)
+		})
+	}
+}
+
+func TestFromHTML_Table(t *testing.T) {
+	post := `<p>Before</p><table><tr><th>Name</th><th>Value</th></tr><tr><td><a href="https://localhost.localdomain/a">a</a></td><td>1</td></tr><tr><td>long name</td><td>22</td></tr></table><p>After</p>`
+	expected := "Before\n\n```\nName      | Value\n----------+------\na         | 1\nlong name | 22\n```\n\nAfter"
+	expectedLinks := data.OrderedMap[string, string]{}
+	expectedLinks.Store("https://localhost.localdomain/a", "")
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Equal(t, expectedLinks, links)
+}
+
+func TestFromHTML_TableNoHeader(t *testing.T) {
+	post := `<table><tr><td>a</td><td>b</td></tr><tr><td>cc</td></tr></table>`
+	expected := "```\na  | b\ncc\n```"
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Equal(t, data.OrderedMap[string, string]{}, links)
+}
+
+func TestFromHTML_MultiLineCode(t *testing.T) {
+	post := `<p>Inline <code>a &lt; b</code> and block:</p><p><code>a<br>b</code></p>`
+	expected := "Inline a < b and block:\n\n```\na\nb\n```"
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Equal(t, data.OrderedMap[string, string]{}, links)
+}
+
 func TestFromHTML_Empty(t *testing.T) {
 	post := ""
 	expected := post
@@ -192,6 +248,65 @@ func TestFromHTML_ImageAndSameLink(t *testing.T) {
 	assert.Equal(t, expectedLinks, links)
 }
 
+func TestFromHTML_List(t *testing.T) {
+	post := `<p>this is a list:</p><ul><li>first item</li><li>second item</li></ul><p>this is a paragraph</p>`
+	expected := "this is a list:\n\n* first item\n* second item\n\nthis is a paragraph"
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Empty(t, links)
+}
+
+func TestFromHTML_OrderedList(t *testing.T) {
+	post := `<ol><li>first item</li><li>second item</li></ol><p>this is a paragraph</p>`
+	expected := "1. first item\n2. second item\n\nthis is a paragraph"
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Empty(t, links)
+}
+
+func TestFromHTML_NestedList(t *testing.T) {
+	post := `this is a list:<ul><li>first item<ol><li>first sub-item</li><li>second sub-item</li></ol></li><li>second item</li></ul>`
+	expected := "this is a list:\n\n* first item\n  1. first sub-item\n  2. second sub-item\n* second item"
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Empty(t, links)
+}
+
+func TestFromHTML_Blockquote(t *testing.T) {
+	post := `<p>someone said:</p><blockquote><p>this is a paragraph</p><p>this is another paragraph</p></blockquote><p>and I agree</p>`
+	expected := "someone said:\n\n> this is a paragraph\n>\n> this is another paragraph\n\nand I agree"
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Empty(t, links)
+}
+
+func TestFromHTML_Pre(t *testing.T) {
+	post := `<p>this is code:</p><pre><code>#include &lt;stdio.h&gt;
+
+int main() {
+	return 0;
+}
+</code></pre><p>this is a paragraph</p>`
+	expected := "this is code:\n\n```\n#include <stdio.h>\n\nint main() {\n\treturn 0;\n}\n```\n\nthis is a paragraph"
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Empty(t, links)
+}
+
+func TestFromHTML_PrePlaceholder(t *testing.T) {
+	post := "<p>a\x015\x02b &#1;7&#x2; c&#10;d</p><pre>x</pre>"
+	expected := "a5b 7 c\nd\n\n```\nx\n```"
+
+	raw, links := FromHTML(post)
+	assert.Equal(t, expected, raw)
+	assert.Empty(t, links)
+}
+
 func TestToHTML_Empty(t *testing.T) {
 	post := ``
 	expected := post
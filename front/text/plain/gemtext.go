@@ -0,0 +1,173 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plain
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dimkr/tootik/ap"
+)
+
+var (
+	linkLineRegex = regexp.MustCompile(`^=>\s*(\S+)(?:\s+(.+))?$`)
+	linkSchemes   = map[string]struct{}{"https": {}, "http": {}, "gemini": {}, "titan": {}, "gopher": {}, "gophers": {}, "spartan": {}, "guppy": {}}
+)
+
+// ParseLink parses a gemtext link line and returns the link and its label, if any.
+func ParseLink(line string) (string, string, bool) {
+	m := linkLineRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return "", "", false
+	}
+
+	return m[1], strings.TrimSpace(m[2]), true
+}
+
+// isAllowedLink determines whether or not a link is an absolute URL with one of the schemes linkified in plain text.
+func isAllowedLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	_, ok := linkSchemes[strings.ToLower(u.Scheme)]
+	return ok
+}
+
+// linkToHTML converts a link to an <a> tag, using the Mention or Hashtag tag that points to it.
+func linkToHTML(link, label string, tags []ap.Tag) string {
+	for _, tag := range tags {
+		if tag.Href != link {
+			continue
+		}
+
+		if label == "" {
+			label = tag.Name
+		}
+
+		if tag.Type == ap.Mention {
+			return fmt.Sprintf(`<span class="h-card" translate="no"><a href="%s" class="u-url mention">%s</a></span>`, html.EscapeString(link), html.EscapeString(label))
+		}
+
+		if tag.Type == ap.Hashtag {
+			return fmt.Sprintf(`<a href="%s" class="mention hashtag" rel="tag">%s</a>`, html.EscapeString(link), html.EscapeString(label))
+		}
+	}
+
+	if label == "" {
+		label = link
+	}
+
+	return fmt.Sprintf(`<a href="%s" target="_blank" rel="nofollow noopener noreferrer">%s</a>`, html.EscapeString(link), html.EscapeString(label))
+}
+
+// FromGemtext converts gemtext to HTML.
+// Link lines become <a> tags (or text, if the link scheme is not allowed), list items become a list, quote lines become a quote and preformatted text is preserved.
+// Headings are treated as text.
+func FromGemtext(text string, tags []ap.Tag) string {
+	var b strings.Builder
+	var block []string
+	var kind string
+
+	flush := func() {
+		if len(block) == 0 {
+			return
+		}
+
+		switch kind {
+		case "text":
+			b.WriteString(ToHTML(strings.Join(block, "\n"), tags))
+
+		case "link":
+			b.WriteString("<p>")
+			b.WriteString(strings.Join(block, "<br/>"))
+			b.WriteString("</p>")
+
+		case "item":
+			b.WriteString("<ul>")
+			for _, item := range block {
+				b.WriteString("<li>")
+				b.WriteString(linkify(item, tags))
+				b.WriteString("</li>")
+			}
+			b.WriteString("</ul>")
+
+		case "quote":
+			b.WriteString("<blockquote>")
+			b.WriteString(ToHTML(strings.Join(block, "\n"), tags))
+			b.WriteString("</blockquote>")
+
+		case "pre":
+			b.WriteString("<pre>")
+			b.WriteString(html.EscapeString(strings.Join(block, "\n")))
+			b.WriteString("</pre>")
+		}
+
+		block = block[:0]
+	}
+
+	add := func(k, line string) {
+		if k != kind {
+			flush()
+			kind = k
+		}
+		block = append(block, line)
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if strings.HasPrefix(line, "```") {
+			if kind == "pre" {
+				flush()
+				kind = ""
+			} else {
+				flush()
+				kind = "pre"
+			}
+			continue
+		}
+
+		if kind == "pre" {
+			block = append(block, line)
+			continue
+		}
+
+		if link, label, ok := ParseLink(line); ok && isAllowedLink(link) {
+			add("link", linkToHTML(link, label, tags))
+		} else if ok {
+			add("text", line)
+		} else if strings.HasPrefix(line, "* ") {
+			add("item", strings.TrimSpace(line[2:]))
+		} else if strings.HasPrefix(line, ">") {
+			add("quote", strings.TrimSpace(line[1:]))
+		} else if strings.TrimSpace(line) == "" {
+			flush()
+			kind = ""
+		} else {
+			add("text", line)
+		}
+	}
+
+	flush()
+
+	return b.String()
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plain
+
+import (
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromGemtext_Text(t *testing.T) {
+	post := "Hello https://localhost.localdomain\nworld\n\nagain"
+	expected := `<p>Hello <a href="https://localhost.localdomain" target="_blank" rel="nofollow noopener noreferrer">https://localhost.localdomain</a><br/>world</p><p>again</p>`
+	assert.Equal(t, expected, FromGemtext(post, nil))
+}
+
+func TestFromGemtext_Links(t *testing.T) {
+	post := "=> gemini://localhost.localdomain/a\n=>gemini://localhost.localdomain/b  B & C\n=> https://localhost.localdomain/user/a\n=> gemini://localhost.localdomain/hashtag/x Tag"
+	tags := []ap.Tag{
+		{Type: ap.Mention, Name: "@a@localhost.localdomain", Href: "https://localhost.localdomain/user/a"},
+		{Type: ap.Hashtag, Name: "#x", Href: "gemini://localhost.localdomain/hashtag/x"},
+	}
+	expected := `<p><a href="gemini://localhost.localdomain/a" target="_blank" rel="nofollow noopener noreferrer">gemini://localhost.localdomain/a</a><br/><a href="gemini://localhost.localdomain/b" target="_blank" rel="nofollow noopener noreferrer">B &amp; C</a><br/><span class="h-card" translate="no"><a href="https://localhost.localdomain/user/a" class="u-url mention">@a@localhost.localdomain</a></span><br/><a href="gemini://localhost.localdomain/hashtag/x" class="mention hashtag" rel="tag">Tag</a></p>`
+	assert.Equal(t, expected, FromGemtext(post, tags))
+}
+
+func TestFromGemtext_LinkScheme(t *testing.T) {
+	post := "=> javascript:alert(1) Click\n=> /users\n=> gemini://localhost.localdomain/a"
+	expected := `<p>=> javascript:alert(1) Click<br/>=> /users</p><p><a href="gemini://localhost.localdomain/a" target="_blank" rel="nofollow noopener noreferrer">gemini://localhost.localdomain/a</a></p>`
+	assert.Equal(t, expected, FromGemtext(post, nil))
+}
+
+func TestFromGemtext_Blocks(t *testing.T) {
+	post := "# Title\n* a\n* b\n> c\n>\n> d\n```\nx < y\n\n```\nend"
+	expected := `<p># Title</p><ul><li>a</li><li>b</li></ul><blockquote><p>c</p><p>d</p></blockquote><pre>x &lt; y
+</pre><p>end</p>`
+	assert.Equal(t, expected, FromGemtext(post, nil))
+}
+
+func TestFromGemtext_RoundTrip(t *testing.T) {
+	post := "Hello\n=> gemini://localhost.localdomain/a A\n* a\n> b\n```\nc\n```"
+	raw, links := FromHTML(FromGemtext(post, nil))
+	assert.Equal(t, "Hello\n\nA\n\n* a\n\n> b\n\n```\nc\n```", raw)
+	assert.True(t, links.Contains("gemini://localhost.localdomain/a"))
+}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package text
 
+import "strings"
+
 // WordWrap wraps long lines.
 func WordWrap(text string, width, maxLines int) []string {
 	if text == "" {
@@ -51,3 +53,16 @@ func WordWrap(text string, width, maxLines int) []string {
 
 	return lines
 }
+
+// SingleLine turns untrusted text into a single line that cannot be mistaken for a link, a heading or a preformatted block.
+func SingleLine(text string) string {
+	line := strings.Join(strings.Fields(text), " ")
+
+	for {
+		trimmed := strings.TrimLeft(strings.TrimPrefix(strings.TrimPrefix(line, "```"), "=>"), "#> ")
+		if trimmed == line {
+			return line
+		}
+		line = trimmed
+	}
+}
@@ -21,11 +21,34 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/front/text"
 )
 
+// ThreadQuery fetches a page of replies to a post. Each page shows whole subtrees, and replies deeper than
+// ThreadMaxDepth are collapsed into their ancestor; +t.id has no affinity, so SQLite can look up replies using the
+// index on inReplyTo.
+const ThreadQuery = `with recursive thread(id, author, inserted, parent, depth, path, top, branch) as (
+		select notes.id, notes.author, notes.inserted, object->>'$.inReplyTo' as parent, 0 as depth, notes.inserted || notes.id as path, null as top, null as branch from notes where id = $1
+		union all
+		select tombstones.id, null, tombstones.inserted, tombstones.parent, 0, tombstones.inserted || tombstones.id, null, null from tombstones where id = $1 and not exists (select 1 from notes where notes.id = $1)
+		union all
+		select notes.id, notes.author, notes.inserted, notes.object->>'$.inReplyTo', t.depth + 1, t.path || notes.inserted || notes.id, case when t.depth = 0 then notes.id else t.top end, case when t.depth >= $2 then coalesce(t.branch, t.id) end from thread t join notes on notes.object->>'$.inReplyTo' = +t.id
+		union all
+		select tombstones.id, null, tombstones.inserted, tombstones.parent, t.depth + 1, t.path || tombstones.inserted || tombstones.id, case when t.depth = 0 then tombstones.id else t.top end, case when t.depth >= $2 then coalesce(t.branch, t.id) end from thread t join tombstones on tombstones.parent = t.id where not exists (select 1 from notes where notes.id = tombstones.id)
+	),
+	tops as (select id from thread where depth = 1 order by path limit $3 offset $4)
+	select thread.depth, thread.id, thread.inserted, persons.actor->>'$.preferredUsername', (select count(*) from thread hidden where hidden.branch = thread.id)
+	from thread
+	left join persons on persons.id = thread.author
+	where
+		(thread.author is null or persons.id is not null) and
+		thread.depth <= $2 and
+		((thread.depth = 0 and $4 = 0) or thread.top in (select id from tops))
+	order by thread.path`
+
 func (h *Handler) thread(w text.Writer, r *Request, args ...string) {
 	postID := "https://" + args[1]
 
@@ -39,7 +62,21 @@ func (h *Handler) thread(w text.Writer, r *Request, args ...string) {
 	r.Log.Info("Viewing thread", "post", postID)
 
 	var threadHead sql.NullString
-	if err := h.DB.QueryRowContext(r.Context, `with recursive thread(id, parent) as (select notes.id, notes.object->>'$.inReplyTo' as parent from notes where id = ? union all select notes.id, notes.object->>'$.inReplyTo' as parent from thread t join notes on notes.id = t.parent) select thread.id from thread where thread.parent is null limit 1`, postID).Scan(&threadHead); err != nil && !errors.Is(err, sql.ErrNoRows) {
+	var headDeleted bool
+	if err := h.DB.QueryRowContext(
+		r.Context,
+		`with recursive thread(id, parent, deleted) as (
+			select notes.id, notes.object->>'$.inReplyTo' as parent, 0 from notes where id = $1
+			union all
+			select tombstones.id, tombstones.parent, 1 from tombstones where id = $1 and not exists (select 1 from notes where notes.id = $1)
+			union all
+			select notes.id, notes.object->>'$.inReplyTo' as parent, 0 from thread t join notes on notes.id = t.parent
+			union all
+			select tombstones.id, tombstones.parent, 1 from thread t join tombstones on tombstones.id = t.parent where not exists (select 1 from notes where notes.id = t.parent)
+		)
+		select thread.id, thread.deleted from thread where thread.parent is null limit 1`,
+		postID,
+	).Scan(&threadHead, &headDeleted); err != nil && !errors.Is(err, sql.ErrNoRows) {
 		r.Log.Warn("Failed to fetch thread head", "error", err)
 		w.Error()
 		return
@@ -53,7 +90,14 @@ func (h *Handler) thread(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
-	rows, err := h.DB.QueryContext(r.Context, `select thread.depth, thread.id, strftime('%Y-%m-%d', datetime(thread.inserted, 'unixepoch')), persons.actor->>'$.preferredUsername' from (with recursive thread(id, author, inserted, parent, depth, path) as (select notes.id, notes.author, notes.inserted, object->>'$.inReplyTo' as parent, 0 as depth, notes.inserted || notes.id as path from notes where id = $1 union all select notes.id, notes.author, notes.inserted, notes.object->>'$.inReplyTo', t.depth + 1, t.path || notes.inserted || notes.id from thread t join notes on notes.object->>'$.inReplyTo' = t.id) select thread.depth, thread.id, thread.author, thread.inserted, thread.path from thread order by thread.path limit $2 offset $3) thread join persons on persons.id = thread.author order by thread.path`, postID, h.Config.PostsPerPage, offset)
+	rows, err := h.DB.QueryContext(
+		r.Context,
+		ThreadQuery,
+		postID,
+		h.Config.ThreadMaxDepth,
+		h.postsPerPage(r),
+		offset,
+	)
 	if err != nil {
 		r.Log.Info("Failed to fetch thread", "post", postID, "error", err)
 		w.Status(40, "Post not found")
@@ -64,24 +108,34 @@ func (h *Handler) thread(w text.Writer, r *Request, args ...string) {
 	w.OK()
 
 	var displayName string
-	if rootAuthorName.Valid {
+	if rootAuthorID == "" {
+		displayName = "[deleted]"
+	} else if rootAuthorName.Valid {
 		displayName = h.getDisplayName(rootAuthorID, rootAuthorUsername, rootAuthorName.String, ap.ActorType(rootAuthorType))
 	} else {
 		displayName = h.getDisplayName(rootAuthorID, rootAuthorUsername, "", ap.ActorType(rootAuthorType))
 	}
 
-	if offset > 0 && offset >= h.Config.PostsPerPage {
-		w.Titlef("🧵 Replies to %s (%d-%d)", displayName, offset, offset+h.Config.PostsPerPage)
+	if offset > 0 && offset >= h.postsPerPage(r) {
+		w.Titlef("🧵 Replies to %s (%d-%d)", displayName, offset, offset+h.postsPerPage(r))
 	} else {
 		w.Titlef("🧵 Replies to %s", displayName)
 	}
 
+	prefix := "/users"
+	if r.User == nil {
+		prefix = ""
+	}
+
 	count := 0
-	var firstNodeID string
+	subtrees := 0
 	for rows.Next() {
 		var node struct {
-			Depth                            int
-			PostID, Inserted, AuthorUserName string
+			Depth          int
+			PostID         string
+			Inserted       int64
+			AuthorUserName sql.NullString
+			Hidden         int
 		}
 
 		if err := rows.Scan(
@@ -89,30 +143,40 @@ func (h *Handler) thread(w text.Writer, r *Request, args ...string) {
 			&node.PostID,
 			&node.Inserted,
 			&node.AuthorUserName,
+			&node.Hidden,
 		); err != nil {
 			r.Log.Info("Failed to scan post", "post", postID, "error", err)
 			continue
 		}
 
+		inserted := formatTime(r, time.Unix(node.Inserted, 0).UTC())
+
 		var b strings.Builder
-		b.WriteString(node.Inserted)
-		b.WriteByte(' ')
+		b.WriteString("%s ")
 		if node.Depth > 0 {
 			for i := 0; i < node.Depth; i++ {
 				b.WriteRune('·')
 			}
 			b.WriteByte(' ')
 		}
-		b.WriteString(node.AuthorUserName)
 
-		if r.User == nil {
-			w.Link("/view/"+strings.TrimPrefix(node.PostID, "https://"), b.String())
+		// replies to a deleted post are shown under a placeholder
+		if node.AuthorUserName.Valid {
+			b.WriteString("%s")
+			w.Linkf(prefix+"/view/"+strings.TrimPrefix(node.PostID, "https://"), b.String(), inserted, node.AuthorUserName.String)
 		} else {
-			w.Link("/users/view/"+strings.TrimPrefix(node.PostID, "https://"), b.String())
+			b.WriteString("[deleted]")
+			w.Textf(b.String(), inserted)
+		}
+
+		if node.Hidden == 1 {
+			w.Linkf(prefix+"/thread/"+strings.TrimPrefix(node.PostID, "https://"), "%s "+strings.Repeat("·", node.Depth+1)+" ↳ 1 more reply", inserted)
+		} else if node.Hidden > 1 {
+			w.Linkf(prefix+"/thread/"+strings.TrimPrefix(node.PostID, "https://"), "%s "+strings.Repeat("·", node.Depth+1)+" ↳ %d more replies", inserted, node.Hidden)
 		}
 
-		if count == 0 {
-			firstNodeID = node.PostID
+		if node.Depth == 1 {
+			subtrees++
 		}
 
 		count++
@@ -124,25 +188,25 @@ func (h *Handler) thread(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
-	if (threadHead.Valid && count > 0 && threadHead.String != firstNodeID) || offset >= h.Config.PostsPerPage || count == h.Config.PostsPerPage {
+	if (threadHead.Valid && threadHead.String != postID) || offset >= h.postsPerPage(r) || subtrees == h.postsPerPage(r) {
 		w.Separator()
 	}
 
-	if threadHead.Valid && count > 0 && threadHead.String != firstNodeID && r.User == nil {
-		w.Link("/view/"+strings.TrimPrefix(threadHead.String, "https://"), "View first post in thread")
-	} else if threadHead.Valid && count > 0 && threadHead.String != firstNodeID {
-		w.Link("/users/view/"+strings.TrimPrefix(threadHead.String, "https://"), "View first post in thread")
+	if threadHead.Valid && threadHead.String != postID && headDeleted {
+		w.Link(prefix+"/thread/"+strings.TrimPrefix(threadHead.String, "https://"), "View first post in thread")
+	} else if threadHead.Valid && threadHead.String != postID {
+		w.Link(prefix+"/view/"+strings.TrimPrefix(threadHead.String, "https://"), "View first post in thread")
 	}
 
-	if offset > h.Config.PostsPerPage {
+	if offset > h.postsPerPage(r) {
 		w.Link(r.URL.Path, "First page")
 	}
 
-	if offset >= h.Config.PostsPerPage {
-		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset-h.Config.PostsPerPage), "Previous page (%d-%d)", offset-h.Config.PostsPerPage, offset)
+	if offset >= h.postsPerPage(r) {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset-h.postsPerPage(r)), "Previous page (%d-%d)", offset-h.postsPerPage(r), offset)
 	}
 
-	if count == h.Config.PostsPerPage {
-		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+h.Config.PostsPerPage), "Next page (%d-%d)", offset+h.Config.PostsPerPage, offset+2*h.Config.PostsPerPage)
+	if subtrees == h.postsPerPage(r) {
+		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+h.postsPerPage(r)), "Next page (%d-%d)", offset+h.postsPerPage(r), offset+2*h.postsPerPage(r))
 	}
 }
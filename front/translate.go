@@ -0,0 +1,225 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package front
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/front/text/plain"
+)
+
+const defaultTranslationLanguage = "en"
+
+type translateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type translateResponse struct {
+	TranslatedText string `json:"translatedText"`
+	Error          string `json:"error"`
+}
+
+// getTranslationLanguage returns the language posts are translated to.
+func (h *Handler) getTranslationLanguage(r *Request) (string, error) {
+	language, err := h.getLanguage(r)
+	if err != nil {
+		return "", err
+	}
+
+	if language == "" {
+		return defaultTranslationLanguage, nil
+	}
+
+	// LibreTranslate doesn't understand regional variants
+	language, _, _ = strings.Cut(language, "-")
+	return language, nil
+}
+
+// fetchTranslation translates text using a LibreTranslate server.
+func (h *Handler) fetchTranslation(ctx context.Context, s, source, target string) (string, error) {
+	body, err := json.Marshal(translateRequest{
+		Q:      s,
+		Source: source,
+		Target: target,
+		Format: "text",
+		APIKey: h.Config.TranslationKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.Config.TranslationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(h.Config.TranslationURL, "/")+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.translationClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var translated translateResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, h.Config.MaxResponseBodySize)).Decode(&translated); err != nil {
+		return "", fmt.Errorf("failed to decode translation: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to translate: %d %s", resp.StatusCode, translated.Error)
+	}
+
+	if translated.TranslatedText == "" {
+		return "", errors.New("empty translation")
+	}
+
+	return translated.TranslatedText, nil
+}
+
+func (h *Handler) translate(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	if h.Config.TranslationURL == "" {
+		w.Status(40, "Translation is disabled")
+		return
+	}
+
+	postID := "https://" + args[1]
+
+	// users can only translate posts they can see
+	note, _, _, _, err := h.getPost(r, postID)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		r.Log.Warn("Attempted to translate non-existing post", "post", postID, "error", err)
+		w.Status(40, "Post not found")
+		return
+	} else if err != nil {
+		r.Log.Warn("Failed to fetch post to translate", "post", postID, "error", err)
+		w.Error()
+		return
+	}
+
+	if strings.HasPrefix(note.ID, fmt.Sprintf("https://%s/", h.Domain)) {
+		w.Status(40, "Post not found")
+		return
+	}
+
+	target, err := h.getTranslationLanguage(r)
+	if err != nil {
+		r.Log.Warn("Failed to get translation language", "error", err)
+		w.Error()
+		return
+	}
+
+	// a translation of an edited post is stale
+	var cached int
+	if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from translations join notes on notes.id = translations.note where translations.note = ? and translations.language = ? and translations.updated = notes.updated)`, note.ID, target).Scan(&cached); err != nil {
+		r.Log.Warn("Failed to check if post is translated", "post", note.ID, "error", err)
+		w.Error()
+		return
+	} else if cached == 1 {
+		w.Redirect("/users/view/" + args[1])
+		return
+	}
+
+	source := "auto"
+	for language := range note.ContentMap {
+		source, _, _ = strings.Cut(language, "-")
+		break
+	}
+
+	s, _ := plain.FromHTML(note.Content)
+	if note.Name != "" && note.Type != ap.Question {
+		s = note.Name + "\n\n" + s
+	}
+
+	if strings.TrimSpace(s) == "" {
+		w.Status(40, "Nothing to translate")
+		return
+	}
+
+	r.Log.Info("Translating post", "post", note.ID, "source", source, "target", target)
+
+	translated, err := h.fetchTranslation(r.Context, s, source, target)
+	if err != nil {
+		r.Log.Warn("Failed to translate post", "post", note.ID, "error", err)
+		w.Status(40, "Failed to translate post")
+		return
+	}
+
+	if _, err := h.DB.ExecContext(
+		r.Context,
+		`insert into translations(note, language, content, updated) select $1, $2, $3, updated from notes where id = $1 on conflict(note, language, updated) do nothing`,
+		note.ID,
+		target,
+		translated,
+	); err != nil {
+		r.Log.Warn("Failed to cache translation", "post", note.ID, "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/view/" + args[1])
+}
+
+// printTranslation prints the cached translation of a post, if there is one.
+func (h *Handler) printTranslation(w text.Writer, r *Request, note *ap.Object) {
+	if r.User == nil || h.Config.TranslationURL == "" {
+		return
+	}
+
+	target, err := h.getTranslationLanguage(r)
+	if err != nil {
+		r.Log.Warn("Failed to get translation language", "error", err)
+		return
+	}
+
+	var translated string
+	if err := h.DB.QueryRowContext(r.Context, `select translations.content from translations join notes on notes.id = translations.note where translations.note = ? and translations.language = ? and translations.updated = notes.updated`, note.ID, target).Scan(&translated); errors.Is(err, sql.ErrNoRows) {
+		return
+	} else if err != nil {
+		r.Log.Warn("Failed to fetch translation", "post", note.ID, "error", err)
+		return
+	}
+
+	w.Empty()
+	w.Subtitle("🌐 Translation")
+
+	for _, line := range strings.Split(translated, "\n") {
+		w.Quote(line)
+	}
+}
@@ -25,12 +25,15 @@ import (
 	"crypto/x509"
 	"database/sql"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
 	"github.com/dimkr/tootik/httpsig"
 	"github.com/dimkr/tootik/icon"
+	"github.com/mattn/go-sqlite3"
 )
 
 func gen() (*rsa.PrivateKey, []byte, []byte, error) {
@@ -64,14 +67,13 @@ func gen() (*rsa.PrivateKey, []byte, []byte, error) {
 	return priv, privPem.Bytes(), pubPem.Bytes(), nil
 }
 
-// Create creates a new user.
-func Create(ctx context.Context, domain string, db *sql.DB, name string, actorType ap.ActorType, cert *x509.Certificate) (*ap.Actor, httpsig.Key, error) {
+func newActor(domain string, cfg *cfg.Config, name string, actorType ap.ActorType) (*ap.Actor, httpsig.Key, []byte, error) {
 	priv, privPem, pubPem, err := gen()
 	if err != nil {
-		return nil, httpsig.Key{}, fmt.Errorf("failed to generate key pair: %w", err)
+		return nil, httpsig.Key{}, nil, fmt.Errorf("failed to generate key pair: %w", err)
 	}
 
-	id := fmt.Sprintf("https://%s/user/%s", domain, name)
+	id := URL(domain, cfg.ActorPath, name)
 	actor := ap.Actor{
 		Context: []string{
 			"https://www.w3.org/ns/activitystreams",
@@ -87,15 +89,15 @@ func Create(ctx context.Context, domain string, db *sql.DB, name string, actorTy
 				URL:       fmt.Sprintf("https://%s/icon/%s%s", domain, name, icon.FileNameExtension),
 			},
 		},
-		Inbox:  fmt.Sprintf("https://%s/inbox/%s", domain, name),
-		Outbox: fmt.Sprintf("https://%s/outbox/%s", domain, name),
+		Inbox:  URL(domain, cfg.InboxPath, name),
+		Outbox: URL(domain, cfg.OutboxPath, name),
 		// use nobody's inbox as a shared inbox
 		Endpoints: map[string]string{
-			"sharedInbox": fmt.Sprintf("https://%s/inbox/nobody", domain),
+			"sharedInbox": URL(domain, cfg.InboxPath, "nobody"),
 		},
-		Followers: fmt.Sprintf("https://%s/followers/%s", domain, name),
+		Followers: URL(domain, cfg.FollowersPath, name),
 		PublicKey: ap.PublicKey{
-			ID:           fmt.Sprintf("https://%s/user/%s#main-key", domain, name),
+			ID:           id + "#main-key",
 			Owner:        id,
 			PublicKeyPem: string(pubPem),
 		},
@@ -103,20 +105,63 @@ func Create(ctx context.Context, domain string, db *sql.DB, name string, actorTy
 		Published:                 &ap.Time{Time: time.Now()},
 	}
 
-	key := httpsig.Key{ID: actor.PublicKey.ID, PrivateKey: priv}
+	return &actor, httpsig.Key{ID: actor.PublicKey.ID, PrivateKey: priv}, privPem, nil
+}
+
+// insertError wraps an error returned when a new user is inserted, and returns [ErrNameTaken] if the name is taken by
+// a user registered concurrently.
+func insertError(id string, err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return fmt.Errorf("failed to insert %s: %w", id, ErrNameTaken)
+	}
+
+	return fmt.Errorf("failed to insert %s: %w", id, err)
+}
+
+// CreateTx creates a new user without a client certificate, as part of a transaction.
+func CreateTx(ctx context.Context, domain string, cfg *cfg.Config, tx *sql.Tx, name string, actorType ap.ActorType) (*ap.Actor, httpsig.Key, error) {
+	actor, key, privPem, err := newActor(domain, cfg, name, actorType)
+	if err != nil {
+		return nil, httpsig.Key{}, err
+	}
+
+	if _, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO persons (id, actor, privkey, announced, skeleton) VALUES($1, $2, $3, $2, $4)`,
+		actor.ID,
+		actor,
+		string(privPem),
+		skeleton(name),
+	); err != nil {
+		return nil, httpsig.Key{}, insertError(actor.ID, err)
+	}
+
+	return actor, key, nil
+}
+
+// Create creates a new user.
+func Create(ctx context.Context, domain string, cfg *cfg.Config, db *sql.DB, name string, actorType ap.ActorType, cert *x509.Certificate) (*ap.Actor, httpsig.Key, error) {
+	actor, key, privPem, err := newActor(domain, cfg, name, actorType)
+	if err != nil {
+		return nil, httpsig.Key{}, err
+	}
+
+	id := actor.ID
 
 	if cert == nil {
 		if _, err = db.ExecContext(
 			ctx,
-			`INSERT INTO persons (id, actor, privkey) VALUES(?,?,?)`,
+			`INSERT INTO persons (id, actor, privkey, announced, skeleton) VALUES($1, $2, $3, $2, $4)`,
 			id,
-			&actor,
+			actor,
 			string(privPem),
+			skeleton(name),
 		); err != nil {
-			return nil, httpsig.Key{}, fmt.Errorf("failed to insert %s: %w", id, err)
+			return nil, httpsig.Key{}, insertError(id, err)
 		}
 
-		return &actor, key, nil
+		return actor, key, nil
 	}
 
 	tx, err := db.BeginTx(ctx, nil)
@@ -125,14 +170,16 @@ func Create(ctx context.Context, domain string, db *sql.DB, name string, actorTy
 	}
 	defer tx.Rollback()
 
+	// a certificate can be added to an existing user, but not to a new user with a name that looks like another
 	if _, err = tx.ExecContext(
 		ctx,
-		`INSERT OR IGNORE INTO persons (id, actor, privkey) VALUES(?,?,?)`,
+		`INSERT INTO persons (id, actor, privkey, announced, skeleton) VALUES($1, $2, $3, $2, $4) ON CONFLICT(id) DO NOTHING`,
 		id,
-		&actor,
+		actor,
 		string(privPem),
+		skeleton(name),
 	); err != nil {
-		return nil, httpsig.Key{}, fmt.Errorf("failed to insert %s: %w", id, err)
+		return nil, httpsig.Key{}, insertError(id, err)
 	}
 
 	if _, err = tx.ExecContext(
@@ -149,5 +196,5 @@ func Create(ctx context.Context, domain string, db *sql.DB, name string, actorTy
 		return nil, httpsig.Key{}, fmt.Errorf("failed to insert %s: %w", id, err)
 	}
 
-	return &actor, key, nil
+	return actor, key, nil
 }
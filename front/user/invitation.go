@@ -0,0 +1,58 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/httpsig"
+)
+
+// ErrInvalidInvitation is returned by [CreateInvited] if the invitation code does not exist or was already used.
+var ErrInvalidInvitation = errors.New("invalid invitation")
+
+// CreateInvited creates a new user using an invitation code.
+// The invitation is released if user creation fails, so it can be used again.
+func CreateInvited(ctx context.Context, domain string, cfg *cfg.Config, db *sql.DB, name, code string, cert *x509.Certificate) (*ap.Actor, httpsig.Key, error) {
+	res, err := db.ExecContext(ctx, `update invitations set invited = ? where code = ? and invited is null`, name, code)
+	if err != nil {
+		return nil, httpsig.Key{}, fmt.Errorf("failed to claim invitation: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, httpsig.Key{}, fmt.Errorf("failed to claim invitation: %w", err)
+	} else if n == 0 {
+		return nil, httpsig.Key{}, ErrInvalidInvitation
+	}
+
+	actor, key, err := Create(ctx, domain, cfg, db, name, ap.Person, cert)
+	if err != nil {
+		if _, releaseErr := db.ExecContext(context.Background(), `update invitations set invited = null where code = ? and invited = ?`, code, name); releaseErr != nil {
+			return nil, httpsig.Key{}, errors.Join(err, releaseErr)
+		}
+
+		return nil, httpsig.Key{}, err
+	}
+
+	return actor, key, nil
+}
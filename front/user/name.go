@@ -0,0 +1,159 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/dimkr/tootik/cfg"
+)
+
+var (
+	// ErrInvalidName is returned by [ValidateName] if a user name doesn't match [cfg.Config.UserNameRegex].
+	ErrInvalidName = errors.New("invalid user name")
+
+	// ErrReservedName is returned by [ValidateName] if a user name is reserved.
+	ErrReservedName = errors.New("user name is reserved")
+
+	// ErrConfusableName is returned by [ValidateName] if a user name contains characters that make it look like
+	// another name.
+	ErrConfusableName = errors.New("user name contains confusable characters")
+
+	// ErrNameTaken is returned by [CheckName] if a user name looks like the name of an existing user.
+	ErrNameTaken = errors.New("user name is taken")
+)
+
+// scriptGroups contains scripts that are commonly mixed in one word.
+var scriptGroups = map[*unicode.RangeTable]string{
+	unicode.Han:      "Jpan",
+	unicode.Hiragana: "Jpan",
+	unicode.Katakana: "Jpan",
+}
+
+// script returns the script of a letter.
+func script(r rune) string {
+	for name, table := range unicode.Scripts {
+		if name == "Common" || name == "Inherited" || !unicode.Is(table, r) {
+			continue
+		}
+
+		if group, ok := scriptGroups[table]; ok {
+			return group
+		}
+
+		return name
+	}
+
+	return ""
+}
+
+// skeleton folds a user name, so names that look alike have the same skeleton.
+func skeleton(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch r {
+		case 'I', '1', '|':
+			b.WriteRune('l')
+		case '0':
+			b.WriteRune('o')
+		default:
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// ValidateName checks if a user name is allowed.
+//
+// A user name must match [cfg.Config.UserNameRegex], which defines the allowed characters and length. It cannot be
+// one of [cfg.Config.ReservedUserNames] (regardless of case) or nobody. In addition, it cannot contain invisible
+// characters or combining marks, or letters from multiple scripts, like the Cyrillic а in аlice.
+func ValidateName(cfg *cfg.Config, name string) error {
+	if name == "" || !cfg.CompiledUserNameRegex.MatchString(name) {
+		return ErrInvalidName
+	}
+
+	if strings.EqualFold(name, "nobody") {
+		return ErrReservedName
+	}
+
+	for _, reserved := range cfg.ReservedUserNames {
+		if strings.EqualFold(name, reserved) {
+			return ErrReservedName
+		}
+	}
+
+	first := ""
+	for _, r := range name {
+		if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc) {
+			return ErrConfusableName
+		}
+
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		if s := script(r); first == "" {
+			first = s
+		} else if s != first {
+			return ErrConfusableName
+		}
+	}
+
+	return nil
+}
+
+// CheckName checks if a user name can be used by a new user and returns true if a user with exactly this name exists.
+//
+// User names are unique regardless of case, and a name cannot look like the name of another user: for example, if
+// alice exists, Alice and AIice (with an uppercase i) are taken. Names of existing users are not subject to
+// [ValidateName], so users registered before a policy change can still add client certificates.
+func CheckName(ctx context.Context, domain string, cfg *cfg.Config, db *sql.DB, name string) (bool, error) {
+	if name == "" || !cfg.CompiledUserNameRegex.MatchString(name) {
+		return false, ErrInvalidName
+	}
+
+	var exists, taken bool
+	if err := db.QueryRowContext(
+		ctx,
+		`select exists (select 1 from persons where actor->>'$.preferredUsername' = $1 and host = $2), exists (select 1 from persons where skeleton = $3)`,
+		name,
+		domain,
+		skeleton(name),
+	).Scan(&exists, &taken); err != nil {
+		return false, fmt.Errorf("failed to check if %s is taken: %w", name, err)
+	}
+
+	if exists {
+		return true, nil
+	}
+
+	if err := ValidateName(cfg, name); err != nil {
+		return false, err
+	}
+
+	if taken {
+		return false, ErrNameTaken
+	}
+
+	return false, nil
+}
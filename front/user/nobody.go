@@ -23,13 +23,14 @@ import (
 	"fmt"
 
 	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
 	"github.com/dimkr/tootik/data"
 	"github.com/dimkr/tootik/httpsig"
 )
 
 // CreateNobody creates the special "nobdoy" user.
 // This user is used to sign outgoing requests not initiated by a particular user.
-func CreateNobody(ctx context.Context, domain string, db *sql.DB) (*ap.Actor, httpsig.Key, error) {
+func CreateNobody(ctx context.Context, domain string, cfg *cfg.Config, db *sql.DB) (*ap.Actor, httpsig.Key, error) {
 	var actor ap.Actor
 	var privKeyPem string
 	if err := db.QueryRowContext(ctx, `select actor, privkey from persons where actor->>'$.preferredUsername' = 'nobody' and host = ?`, domain).Scan(&actor, &privKeyPem); err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -39,5 +40,5 @@ func CreateNobody(ctx context.Context, domain string, db *sql.DB) (*ap.Actor, ht
 		return &actor, httpsig.Key{ID: actor.PublicKey.ID, PrivateKey: privKey}, err
 	}
 
-	return Create(ctx, domain, db, "nobody", ap.Application, nil)
+	return Create(ctx, domain, cfg, db, "nobody", ap.Application, nil)
 }
@@ -0,0 +1,91 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/dimkr/tootik/cfg"
+)
+
+// paths of local actors and collections, before they became configurable
+const (
+	legacyActorPath  = "/user/{username}"
+	legacyInboxPath  = "/inbox/{username}"
+	legacyOutboxPath = "/outbox/{username}"
+)
+
+// URL returns the URL of a local actor or one of its collections.
+func URL(domain, pattern, name string) string {
+	return "https://" + domain + strings.Replace(pattern, "{username}", name, 1)
+}
+
+func paths(pattern, legacy string) []string {
+	if pattern == legacy {
+		return []string{pattern}
+	}
+
+	return []string{pattern, legacy}
+}
+
+// ActorPaths returns the path patterns of local actors, starting with the one used for new users.
+func ActorPaths(cfg *cfg.Config) []string {
+	return paths(cfg.ActorPath, legacyActorPath)
+}
+
+// InboxPaths returns the path patterns of local inboxes, starting with the one used for new users.
+func InboxPaths(cfg *cfg.Config) []string {
+	return paths(cfg.InboxPath, legacyInboxPath)
+}
+
+// OutboxPaths returns the path patterns of local outboxes, starting with the one used for new users.
+func OutboxPaths(cfg *cfg.Config) []string {
+	return paths(cfg.OutboxPath, legacyOutboxPath)
+}
+
+func matchPath(pattern, path string) (string, bool) {
+	prefix, suffix, _ := strings.Cut(pattern, "{username}")
+
+	name, ok := strings.CutPrefix(path, prefix)
+	if !ok {
+		return "", false
+	}
+
+	name, ok = strings.CutSuffix(name, suffix)
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+
+	return name, true
+}
+
+// ParseID extracts the user name from the ID of a local actor.
+func ParseID(domain string, cfg *cfg.Config, id string) (string, bool) {
+	u, err := url.Parse(id)
+	if err != nil || u.Scheme != "https" || u.Host != domain {
+		return "", false
+	}
+
+	for _, pattern := range ActorPaths(cfg) {
+		if name, ok := matchPath(pattern, u.Path); ok {
+			return name, true
+		}
+	}
+
+	return "", false
+}
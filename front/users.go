@@ -20,8 +20,35 @@ import (
 	"database/sql"
 
 	"github.com/dimkr/tootik/front/text"
+	"github.com/dimkr/tootik/inbox"
 )
 
+// FeedQuery fetches a page of a user's feed. If multiple followed users share a post, only the most recent share is
+// shown.
+const FeedQuery = `select note, author, sharer, inserted, case when sharer is null then 0 else (select count(*) - 1 from feed others where others.note->>'$.id' = feed.note->>'$.id' and others.follower = $1 and others.sharer is not null) end
+	from feed
+	where
+		follower = $1 and
+		(
+			note->'$.contentMap' is null or
+			author->>'$.id' = $1 or
+			not exists (select 1 from settings where actor = $1 and languages is not null) or
+			exists (select 1 from json_each(note->'$.contentMap') contentmap, settings, json_each(settings.languages) languages where settings.actor = $1 and (lower(contentmap.key) = languages.value or lower(contentmap.key) like languages.value || '-%'))
+		) and
+		(
+			sharer is null or
+			not exists (select 1 from settings where actor = $1 and hideshares)
+		) and
+		not exists (select 1 from filters where filters.actor = $1 and filters.feed = 1 and filters.action = 'hide' and (filters.expires is null or filters.expires > unixepoch()) and (instr(lower(note->>'$.content'), lower(filters.phrase)) or instr(lower(note->>'$.name'), lower(filters.phrase)) or instr(lower(note->>'$.summary'), lower(filters.phrase)))) and
+		(
+			sharer is null or
+			not exists (select 1 from feed newer where newer.note->>'$.id' = feed.note->>'$.id' and newer.follower = $1 and newer.sharer is not null and (newer.rank > feed.rank or (newer.rank = feed.rank and newer.rowid > feed.rowid)))
+		)
+	order by
+		rank desc
+	limit $2
+	offset $3`
+
 func (h *Handler) users(w text.Writer, r *Request, args ...string) {
 	if r.User == nil {
 		w.Redirect("/oops")
@@ -35,19 +62,60 @@ func (h *Handler) users(w text.Writer, r *Request, args ...string) {
 		func(offset int) (*sql.Rows, error) {
 			return h.DB.QueryContext(
 				r.Context,
-				`select note, author, sharer, inserted from
-				feed
-				where
-					follower = $1
-				order by
-					inserted desc
-				limit $2
-				offset $3`,
+				FeedQuery,
 				r.User.ID,
-				h.Config.PostsPerPage,
+				h.postsPerPage(r),
 				offset,
 			)
 		},
 		true,
+		true,
+		filterFeed,
 	)
 }
+
+func (h *Handler) feedAlgorithm(w text.Writer, r *Request, args ...string) {
+	if r.User == nil {
+		w.Redirect("/users")
+		return
+	}
+
+	// the default algorithm sorts the feed by the time posts were received or shared
+	var algorithm sql.NullString
+	if args[1] != "default" {
+		algorithm = sql.NullString{String: args[1], Valid: true}
+	}
+
+	tx, err := h.DB.BeginTx(r.Context, nil)
+	if err != nil {
+		r.Log.Warn("Failed to set feed algorithm", "algorithm", args[1], "error", err)
+		w.Error()
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		r.Context,
+		`insert into settings(actor, feed) values($1, $2) on conflict(actor) do update set feed = $2`,
+		r.User.ID,
+		algorithm,
+	); err != nil {
+		r.Log.Warn("Failed to set feed algorithm", "algorithm", args[1], "error", err)
+		w.Error()
+		return
+	}
+
+	if err := inbox.RankFeed(r.Context, tx, r.User.ID, 0); err != nil {
+		r.Log.Warn("Failed to sort feed", "algorithm", args[1], "error", err)
+		w.Error()
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Log.Warn("Failed to set feed algorithm", "algorithm", args[1], "error", err)
+		w.Error()
+		return
+	}
+
+	w.Redirect("/users/settings")
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/front/graph"
@@ -30,6 +31,20 @@ import (
 func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 	postID := "https://" + args[1]
 
+	// links shared from other protocols contain a scheme
+	if strings.Contains(args[1], "://") {
+		var err error
+		if postID, err = h.resolvePostURL(r, args[1]); errors.Is(err, sql.ErrNoRows) {
+			r.Log.Info("Post was not found", "url", args[1])
+			w.Status(40, "Post not found")
+			return
+		} else if err != nil {
+			r.Log.Warn("Failed to resolve post URL", "url", args[1], "error", err)
+			w.Error()
+			return
+		}
+	}
+
 	offset, err := getOffset(r.URL)
 	if err != nil {
 		r.Log.Info("Failed to parse query", "error", err)
@@ -39,58 +54,7 @@ func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 
 	r.Log.Info("Viewing post", "post", postID)
 
-	var note ap.Object
-	var author ap.Actor
-	var group sql.Null[ap.Actor]
-
-	if r.User == nil {
-		err = h.DB.QueryRowContext(
-			r.Context,
-			`
-			select notes.object, persons.actor, groups.actor from notes
-			join persons on persons.id = notes.author
-			left join (select id, actor from persons where actor->>'$.type' = 'Group') groups on exists (select 1 from shares where shares.by = groups.id and shares.note = $1)
-			where
-				notes.id = $1 and
-				notes.public = 1
-			`,
-			postID,
-		).Scan(&note, &author, &group)
-	} else {
-		err = h.DB.QueryRowContext(
-			r.Context,
-			`
-			select notes.object, persons.actor, groups.actor from notes
-			join persons on persons.id = notes.author
-			left join (select id, actor from persons where actor->>'$.type' = 'Group') groups on exists (select 1 from shares where shares.by = groups.id and shares.note = $1)
-			where
-				notes.id = $1 and
-				(
-					notes.public = 1 or
-					notes.author = $2 or
-					$2 in (notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or
-					(notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = $2)) or
-					(notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = $2)) or
-					exists (
-						select 1 from (
-							select persons.id, persons.actor->>'$.followers' as followers, persons.actor->>'$.type' as type from persons
-							join follows on follows.followed = persons.id
-							where
-								follows.accepted = 1 and
-								follows.follower = $2
-						) follows
-						where
-							follows.followers in (notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or
-							(notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = follows.followers)) or
-							(notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = follows.followers)) or
-							(follows.type = 'Group' and exists (select 1 from shares where shares.by = follows.id and shares.note = notes.id))
-					)
-				)
-			`,
-			postID,
-			r.User.ID,
-		).Scan(&note, &author, &group)
-	}
+	note, author, group, moderated, err := h.getPost(r, postID)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
 		r.Log.Info("Post was not found", "post", postID)
 		w.Status(40, "Post not found")
@@ -106,7 +70,7 @@ func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 		rows, err = h.DB.QueryContext(
 			r.Context,
 			`
-			select replies.object, persons.actor, null as sharer, replies.inserted from notes join notes replies on replies.object->>'$.inReplyTo' = notes.id
+			select replies.object, persons.actor, null as sharer, replies.inserted from notes join notes replies on replies.object->>'$.inReplyTo' = +notes.id
 			left join persons on persons.id = replies.author
 			where
 				notes.id = $1 and
@@ -122,7 +86,7 @@ func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 			r.Context,
 			`
 			select replies.object, persons.actor, null as sharer, replies.inserted from
-			notes join notes replies on replies.object->>'$.inReplyTo' = notes.id
+			notes join notes replies on replies.object->>'$.inReplyTo' = +notes.id
 			left join persons on persons.id = replies.author
 			where
 				notes.id = $1 and
@@ -145,7 +109,8 @@ func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 								(persons.actor->>'$.type' = 'Group' and exists (select 1 from shares where shares.by = persons.id and shares.note = replies.id))
 							)
 					)
-				)
+				) and
+				not exists (select 1 from filters where filters.actor = $2 and filters.threads = 1 and filters.action = 'hide' and (filters.expires is null or filters.expires > unixepoch()) and (instr(lower(replies.object->>'$.content'), lower(filters.phrase)) or instr(lower(replies.object->>'$.name'), lower(filters.phrase)) or instr(lower(replies.object->>'$.summary'), lower(filters.phrase))))
 			order by replies.inserted desc limit $3 offset $4
 			`,
 			postID,
@@ -160,29 +125,74 @@ func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 		return
 	}
 
+	var originalPostExists int
+	var threadHead sql.NullString
+	if note.InReplyTo != "" {
+		if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from notes where id = ?)`, note.InReplyTo).Scan(&originalPostExists); err != nil {
+			r.Log.Warn("Failed to check if parent post exists", "error", err)
+		}
+
+		if err := h.DB.QueryRowContext(r.Context, `with recursive thread(id, parent, depth) as (select notes.id, notes.object->>'$.inReplyTo' as parent, 1 as depth from notes where id = ? union all select notes.id, notes.object->>'$.inReplyTo' as parent, t.depth + 1 from thread t join notes on notes.id = t.parent) select id from thread order by depth desc limit 1`, note.InReplyTo).Scan(&threadHead); err != nil && errors.Is(err, sql.ErrNoRows) {
+			r.Log.Debug("First post in thread is missing")
+		} else if err != nil {
+			r.Log.Warn("Failed to fetch first post in thread", "error", err)
+		}
+	}
+
 	w.OK()
 
 	if offset > 0 {
 		w.Titlef("💬 Replies to %s (%d-%d)", author.PreferredUsername, offset, offset+h.Config.RepliesPerPage)
+		h.printThreadNavigation(w, r, &note, originalPostExists == 1, threadHead)
 	} else {
+		kind := "Post"
+		if note.Type == ap.Question {
+			kind = "Poll"
+		} else if note.Type == ap.Event {
+			kind = "Event"
+		} else if note.Type == ap.AudioObject {
+			kind = "Audio"
+		} else if note.Type == ap.VideoObject {
+			kind = "Video"
+		} else if isArticle(&note) {
+			kind = "Article"
+		}
+
 		if note.InReplyTo != "" {
 			w.Titlef("💬 Reply by %s", author.PreferredUsername)
 		} else if note.IsPublic() {
-			w.Titlef("📣 Post by %s", author.PreferredUsername)
+			w.Titlef("📣 %s by %s", kind, author.PreferredUsername)
+		} else if author.Followers != "" && (note.To.Contains(author.Followers) || note.CC.Contains(author.Followers)) {
+			w.Titlef("🔔 %s by %s", kind, author.PreferredUsername)
 		} else {
-			w.Titlef("🔔 Post by %s", author.PreferredUsername)
+			w.Titlef("💌 %s by %s", kind, author.PreferredUsername)
 		}
 
+		h.printThreadNavigation(w, r, &note, originalPostExists == 1, threadHead)
+
 		if group.Valid {
 			h.PrintNote(w, r, &note, &author, &group.V, note.Published.Time, false, false, true, false)
 		} else {
 			h.PrintNote(w, r, &note, &author, nil, note.Published.Time, false, false, true, false)
 		}
 
+		if moderated && note.IsPublic() {
+			w.Link("/users/communities/pin/"+strings.TrimPrefix(note.ID, "https://"), "📌 Pin in community")
+			w.Link("/users/communities/remove/"+strings.TrimPrefix(note.ID, "https://"), "🧹 Remove from community")
+		} else if moderated {
+			w.Link("/users/communities/remove/"+strings.TrimPrefix(note.ID, "https://"), "🧹 Remove from community")
+		}
+
+		if offset == 0 {
+			h.printTranslation(w, r, &note)
+		}
+
 		if note.Type == ap.Question && offset == 0 {
 			options := note.OneOf
+			multiple := false
 			if len(options) == 0 {
 				options = note.AnyOf
+				multiple = true
 			}
 
 			if len(options) > 0 {
@@ -203,9 +213,29 @@ func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 				}
 
 				w.Raw("Results graph", graph.Bars(labels, votes))
+
+				if multiple {
+					w.Text("Multiple choices allowed.")
+				}
+
+				if note.Closed != nil {
+					w.Textf("Poll closed on %s.", note.Closed.UTC().Format(time.DateTime))
+				} else if note.EndTime != nil && time.Now().After(note.EndTime.Time) {
+					w.Textf("Poll ended on %s.", note.EndTime.UTC().Format(time.DateTime))
+				} else if note.EndTime != nil {
+					w.Textf("Poll ends on %s.", note.EndTime.UTC().Format(time.DateTime))
+				}
+
+				if r.User != nil && !strings.HasPrefix(note.ID, fmt.Sprintf("https://%s/", h.Domain)) {
+					w.Link("/users/refresh/"+strings.TrimPrefix(note.ID, "https://"), "🔄 Refresh results")
+				}
 			}
 		}
 
+		if note.Type == ap.Event && offset == 0 {
+			h.printRSVP(w, r, &note)
+		}
+
 		if offset > 0 {
 			w.Empty()
 			w.Subtitlef("💬 Replies to %s (%d-%d)", author.PreferredUsername, offset, offset+h.Config.RepliesPerPage)
@@ -215,44 +245,18 @@ func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 		}
 	}
 
-	count := h.PrintNotes(w, r, rows, false, false, "No replies.")
+	count := h.PrintNotes(w, r, rows, false, false, h.getFilters(r, filterThreads), "No replies.")
 	rows.Close()
 
-	var originalPostExists int
-	var threadHead sql.NullString
-	if note.InReplyTo != "" {
-		if err := h.DB.QueryRowContext(r.Context, `select exists (select 1 from notes where id = ?)`, note.InReplyTo).Scan(&originalPostExists); err != nil {
-			r.Log.Warn("Failed to check if parent post exists", "error", err)
-		}
-
-		if err := h.DB.QueryRowContext(r.Context, `with recursive thread(id, parent, depth) as (select notes.id, notes.object->>'$.inReplyTo' as parent, 1 as depth from notes where id = ? union all select notes.id, notes.object->>'$.inReplyTo' as parent, t.depth + 1 from thread t join notes on notes.id = t.parent) select id from thread order by depth desc limit 1`, note.InReplyTo).Scan(&threadHead); err != nil && errors.Is(err, sql.ErrNoRows) {
-			r.Log.Debug("First post in thread is missing")
-		} else if err != nil {
-			r.Log.Warn("Failed to fetch first post in thread", "error", err)
-		}
-	}
-
 	var threadDepth int
-	if err := h.DB.QueryRowContext(r.Context, `with recursive thread(id, depth) as (select notes.id, 0 as depth from notes where id = ? union all select notes.id, t.depth + 1 from thread t join notes on notes.object->>'$.inReplyTo' = t.id where t.depth <= 3) select max(thread.depth) from thread`, note.ID).Scan(&threadDepth); err != nil {
+	if err := h.DB.QueryRowContext(r.Context, `with recursive thread(id, depth) as (select notes.id, 0 as depth from notes where id = ? union all select notes.id, t.depth + 1 from thread t join notes on notes.object->>'$.inReplyTo' = +t.id where t.depth <= 3) select max(thread.depth) from thread`, note.ID).Scan(&threadDepth); err != nil {
 		r.Log.Warn("Failed to query thread depth", "error", err)
 	}
 
-	if originalPostExists == 1 || (threadHead.Valid && threadHead.String != note.ID && threadHead.String != note.InReplyTo) || threadDepth > 2 || offset > h.Config.RepliesPerPage || offset >= h.Config.RepliesPerPage || count == h.Config.RepliesPerPage {
+	if threadDepth > 2 || offset > h.Config.RepliesPerPage || offset >= h.Config.RepliesPerPage || count == h.Config.RepliesPerPage {
 		w.Separator()
 	}
 
-	if originalPostExists == 1 && r.User == nil {
-		w.Link("/view/"+strings.TrimPrefix(note.InReplyTo, "https://"), "View parent post")
-	} else if originalPostExists == 1 {
-		w.Link("/users/view/"+strings.TrimPrefix(note.InReplyTo, "https://"), "View parent post")
-	}
-
-	if threadHead.Valid && threadHead.String != note.ID && threadHead.String != note.InReplyTo && r.User == nil {
-		w.Link("/view/"+strings.TrimPrefix(threadHead.String, "https://"), "View first post in thread")
-	} else if threadHead.Valid && threadHead.String != note.ID && threadHead.String != note.InReplyTo {
-		w.Link("/users/view/"+strings.TrimPrefix(threadHead.String, "https://"), "View first post in thread")
-	}
-
 	if threadDepth > 2 && r.User == nil {
 		w.Link("/thread/"+strings.TrimPrefix(postID, "https://"), "View thread")
 	} else if threadDepth > 2 {
@@ -271,3 +275,86 @@ func (h *Handler) view(w text.Writer, r *Request, args ...string) {
 		w.Linkf(fmt.Sprintf("%s?%d", r.URL.Path, offset+h.Config.RepliesPerPage), "Next page (%d-%d)", offset+h.Config.RepliesPerPage, offset+2*h.Config.RepliesPerPage)
 	}
 }
+
+// printThreadNavigation prints links to the parent post and the first post in the thread, if they exist.
+func (h *Handler) printThreadNavigation(w text.Writer, r *Request, note *ap.Object, parentExists bool, threadHead sql.NullString) {
+	prefix := "/users"
+	if r.User == nil {
+		prefix = ""
+	}
+
+	root := threadHead.Valid && threadHead.String != note.ID && threadHead.String != note.InReplyTo
+
+	if parentExists {
+		w.Link(prefix+"/view/"+strings.TrimPrefix(note.InReplyTo, "https://"), "View parent post")
+	}
+
+	if root {
+		w.Link(prefix+"/view/"+strings.TrimPrefix(threadHead.String, "https://"), "View first post in thread")
+	}
+
+	if parentExists || root {
+		w.Empty()
+	}
+}
+
+// getPost fetches a post visible to the user, its author and the community it belongs to, and determines whether or
+// not the user owns this community.
+func (h *Handler) getPost(r *Request, postID string) (ap.Object, ap.Actor, sql.Null[ap.Actor], bool, error) {
+	var note ap.Object
+	var author ap.Actor
+	var group sql.Null[ap.Actor]
+	var moderated bool
+	var err error
+
+	if r.User == nil {
+		err = h.DB.QueryRowContext(
+			r.Context,
+			`
+			select notes.object, persons.actor, groups.actor from notes
+			join persons on persons.id = notes.author
+			left join (select id, actor from persons where actor->>'$.type' = 'Group') groups on exists (select 1 from shares where shares.by = groups.id and shares.note = $1)
+			where
+				notes.id = $1 and
+				notes.public = 1
+			`,
+			postID,
+		).Scan(&note, &author, &group)
+	} else {
+		err = h.DB.QueryRowContext(
+			r.Context,
+			`
+			select notes.object, persons.actor, groups.actor, exists (select 1 from shares join communities on communities.id = shares.by where shares.note = notes.id and communities.owner = $1) from notes
+			join persons on persons.id = notes.author
+			left join (select id, actor from persons where actor->>'$.type' = 'Group') groups on exists (select 1 from shares where shares.by = groups.id and shares.note = $2)
+			where
+				notes.id = $2 and
+				(
+					notes.public = 1 or
+					notes.author = $1 or
+					$1 in (notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or
+					(notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = $1)) or
+					(notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = $1)) or
+					exists (
+						select 1 from (
+							select persons.id, persons.actor->>'$.followers' as followers, persons.actor->>'$.type' as type from persons
+							join follows on follows.followed = persons.id
+							where
+								follows.accepted = 1 and
+								follows.follower = $1
+						) follows
+						where
+							follows.followers in (notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or
+							(notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = follows.followers)) or
+							(notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = follows.followers)) or
+							(follows.type = 'Group' and exists (select 1 from shares where shares.by = follows.id and shares.note = notes.id))
+					)
+				)
+			`,
+			r.User.ID,
+			postID,
+		).Scan(&note, &author, &group, &moderated)
+	}
+
+	return note, author, group, moderated, err
+}
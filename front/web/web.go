@@ -0,0 +1,112 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package web exposes a read-only HTML interface.
+package web
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/front"
+)
+
+// public pages that don't require authentication and can be rendered as HTML
+var public = []*regexp.Regexp{
+	regexp.MustCompile(`^/local$`),
+	regexp.MustCompile(`^/outbox/\S+$`),
+	regexp.MustCompile(`^/view/\S+$`),
+}
+
+type Handler struct {
+	Domain  string
+	Config  *cfg.Config
+	Handler front.Handler
+}
+
+func isPublic(path string) bool {
+	path, _, _ = strings.Cut(path, "?")
+
+	for _, re := range public {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contentSecurityPolicy forbids scripts, frames and external resources: pages contain nothing but text, links and
+// the inline stylesheet.
+const contentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// ServeHTTP renders a public page as HTML.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	path := "/" + r.PathValue("path")
+
+	if h.Config.RequireRegistration || !isPublic(path) {
+		slog.Debug("Received request to non-public page", "path", path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	req := front.Request{
+		Context: r.Context(),
+		URL: &url.URL{
+			Scheme:   "https",
+			Host:     h.Domain,
+			Path:     path,
+			RawQuery: r.URL.RawQuery,
+		},
+		Log: slog.With(slog.Group("request", "path", path)),
+	}
+
+	var buf bytes.Buffer
+	hw := Wrap(&buf, h.Domain)
+	h.Handler.Handle(&req, hw)
+	hw.Flush()
+
+	status, body, _ := bytes.Cut(buf.Bytes(), []byte("\r\n"))
+	code, meta, _ := strings.Cut(string(status), " ")
+
+	switch code {
+	case "20":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(body)
+
+	case "30", "31":
+		w.Header().Set("Location", meta)
+		if code == "31" {
+			w.WriteHeader(http.StatusMovedPermanently)
+		} else {
+			w.WriteHeader(http.StatusFound)
+		}
+
+	case "42":
+		w.WriteHeader(http.StatusInternalServerError)
+
+	default:
+		http.Error(w, meta, http.StatusNotFound)
+	}
+}
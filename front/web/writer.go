@@ -0,0 +1,184 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package web
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/dimkr/tootik/front/text"
+)
+
+const style = `body{max-width:40em;margin:auto;padding:1em;font-family:sans-serif;line-height:1.4}p,blockquote,pre{margin:0}blockquote{border-left:3px solid #ccc;padding-left:.5em}pre{overflow-x:auto}`
+
+// writer builds HTML pages, preceded by a Gemini-like status line.
+type writer struct {
+	*text.LineWriter
+	Domain string
+	list   bool
+}
+
+// Wrap wraps an [io.Writer] with an HTML response writer.
+func Wrap(inner io.Writer, domain string) text.Writer {
+	return &writer{LineWriter: text.LineBuffered(inner), Domain: domain}
+}
+
+// url converts a link to a path that can be opened in a web browser, or returns an empty string if the link is unsafe.
+func (w *writer) url(link string) string {
+	if !strings.HasPrefix(link, "/") {
+		// links in remote posts can have any scheme, including javascript:
+		scheme, _, ok := strings.Cut(link, ":")
+		if !ok {
+			return ""
+		}
+
+		switch strings.ToLower(scheme) {
+		case "http", "https", "gemini", "mailto":
+			return link
+
+		default:
+			return ""
+		}
+	}
+
+	if isPublic(link) {
+		return "/web" + link
+	}
+
+	return "gemini://" + w.Domain + link
+}
+
+func (w *writer) endList() {
+	if w.list {
+		w.Write([]byte("</ul>\n"))
+		w.list = false
+	}
+}
+
+func (w *writer) Status(code int, meta string) {
+	fmt.Fprintf(w, "%d %s\r\n", code, meta)
+	if code == 20 {
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<meta charset=\"utf-8\">\n<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n<style>%s</style>\n", style)
+	} else {
+		w.Flush()
+	}
+}
+
+func (w *writer) Statusf(code int, format string, a ...any) {
+	w.Status(code, fmt.Sprintf(format, a...))
+}
+
+func (w *writer) OK() {
+	w.Status(20, "text/html")
+}
+
+func (w *writer) Error() {
+	w.Status(42, "Error")
+}
+
+func (w *writer) Redirect(link string) {
+	w.Status(30, w.url(link))
+}
+
+func (w *writer) Redirectf(format string, a ...any) {
+	w.Redirect(fmt.Sprintf(format, a...))
+}
+
+func (w *writer) Title(title string) {
+	w.endList()
+	escaped := html.EscapeString(title)
+	fmt.Fprintf(w, "<title>%s</title>\n<h1>%s</h1>\n", escaped, escaped)
+}
+
+func (w *writer) Titlef(format string, a ...any) {
+	w.Title(fmt.Sprintf(format, a...))
+}
+
+func (w *writer) Subtitle(subtitle string) {
+	w.endList()
+	fmt.Fprintf(w, "<h2>%s</h2>\n", html.EscapeString(subtitle))
+}
+
+func (w *writer) Subtitlef(format string, a ...any) {
+	w.Subtitle(fmt.Sprintf(format, a...))
+}
+
+func (w *writer) Text(line string) {
+	w.endList()
+	fmt.Fprintf(w, "<p>%s</p>\n", html.EscapeString(line))
+}
+
+func (w *writer) Textf(format string, a ...any) {
+	w.Text(fmt.Sprintf(format, a...))
+}
+
+func (w *writer) Empty() {
+	w.endList()
+	w.Write([]byte("<br>\n"))
+}
+
+func (w *writer) Link(url, name string) {
+	w.endList()
+
+	if href := w.url(url); href != "" {
+		fmt.Fprintf(w, "<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(href), html.EscapeString(name))
+	} else {
+		fmt.Fprintf(w, "<p>%s</p>\n", html.EscapeString(name))
+	}
+}
+
+func (w *writer) Linkf(url, format string, a ...any) {
+	w.Link(url, fmt.Sprintf(format, a...))
+}
+
+func (w *writer) Item(item string) {
+	if !w.list {
+		w.Write([]byte("<ul>\n"))
+		w.list = true
+	}
+	fmt.Fprintf(w, "<li>%s</li>\n", html.EscapeString(item))
+}
+
+func (w *writer) Itemf(format string, a ...any) {
+	w.Item(fmt.Sprintf(format, a...))
+}
+
+func (w *writer) Quote(quote string) {
+	w.endList()
+	fmt.Fprintf(w, "<blockquote>%s</blockquote>\n", html.EscapeString(quote))
+}
+
+func (w *writer) Raw(alt, raw string) {
+	w.endList()
+	fmt.Fprintf(w, "<pre aria-label=\"%s\">%s</pre>\n", html.EscapeString(alt), html.EscapeString(strings.TrimSuffix(raw, "\n")))
+}
+
+func (w *writer) Separator() {
+	w.endList()
+	w.Write([]byte("<hr>\n"))
+}
+
+func (w *writer) Flush() error {
+	w.endList()
+	return w.LineWriter.Flush()
+}
+
+func (w *writer) Clone(inner io.Writer) text.Writer {
+	return Wrap(inner, w.Domain)
+}
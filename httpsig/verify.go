@@ -202,3 +202,18 @@ func (s *Signature) Verify(key any) error {
 
 	return nil
 }
+
+// Hash returns a hash of the key ID, the signed headers and the signature.
+// Requests with identical signed headers and signature have the same hash.
+func (s *Signature) Hash() [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(s.KeyID))
+	h.Write([]byte{0})
+	h.Write([]byte(s.s))
+	h.Write([]byte{0})
+	h.Write(s.signature)
+
+	var hash [sha256.Size]byte
+	h.Sum(hash[:0])
+	return hash
+}
@@ -20,4 +20,9 @@ package icon
 const (
 	MediaType         = "image/gif"
 	FileNameExtension = ".gif"
+
+	// HeaderMediaType and HeaderFileNameExtension describe profile header images, which are too big and detailed for
+	// GIF.
+	HeaderMediaType         = "image/png"
+	HeaderFileNameExtension = ".png"
 )
@@ -23,38 +23,118 @@ import (
 	"image/draw"
 	"image/gif"
 	_ "image/jpeg"
-	_ "image/png"
+	"image/png"
 
 	"github.com/dimkr/tootik/cfg"
 	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
 )
 
-func Scale(cfg *cfg.Config, data []byte) ([]byte, error) {
+// Sizes are the dimensions of square avatar variants generated by [Variants], in ascending order.
+var Sizes = [...]int{48, 96, 192}
+
+func isAVIF(data []byte) bool {
+	return len(data) >= 12 && string(data[4:8]) == "ftyp" && (string(data[8:12]) == "avif" || string(data[8:12]) == "avis")
+}
+
+func decode(data []byte, maxWidth, maxHeight int, maxPixels int64) (image.Image, error) {
+	// there's no pure Go AVIF decoder
+	if isAVIF(data) {
+		return nil, errors.New("AVIF is unsupported")
+	}
+
+	// check the dimensions before decoding, to avoid decompression bombs
 	dim, _, err := image.DecodeConfig(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 
-	if dim.Height > cfg.MaxAvatarHeight || dim.Width > cfg.MaxAvatarWidth {
+	if dim.Height > maxHeight || dim.Width > maxWidth || int64(dim.Width)*int64(dim.Height) > maxPixels {
 		return nil, errors.New("too big")
 	}
 
 	im, _, err := image.Decode(bytes.NewReader(data))
+	return im, err
+}
+
+// crop returns the largest centered part of an image with the given aspect ratio.
+func crop(b image.Rectangle, width, height int) image.Rectangle {
+	if b.Dx()*height > b.Dy()*width {
+		w := b.Dy() * width / height
+		x := b.Min.X + (b.Dx()-w)/2
+		return image.Rect(x, b.Min.Y, x+w, b.Max.Y)
+	}
+
+	h := b.Dx() * height / width
+	y := b.Min.Y + (b.Dy()-h)/2
+	return image.Rect(b.Min.X, y, b.Max.X, y+h)
+}
+
+// resize downscales an image if it's bigger than the given dimensions.
+func resize(im image.Image, width, height int) image.Image {
+	b := im.Bounds()
+	if b.Dy() <= height && b.Dx() <= width {
+		return im
+	}
+
+	// crop the image instead of changing its aspect ratio
+	src := crop(b, width, height)
+
+	bounds := image.Rectangle{Min: image.Point{0, 0}, Max: image.Point{min(width, src.Dx()), min(height, src.Dy())}}
+	scaled := image.NewRGBA(bounds)
+	xdraw.NearestNeighbor.Scale(scaled, bounds, im, src, draw.Over, nil)
+	return scaled
+}
+
+func encode(cfg *cfg.Config, im image.Image, width, height int) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gif.Encode(&b, resize(im, width, height), &gif.Options{NumColors: cfg.AvatarColors}); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func Scale(cfg *cfg.Config, data []byte) ([]byte, error) {
+	im, err := decode(data, cfg.MaxAvatarWidth, cfg.MaxAvatarHeight, cfg.MaxAvatarPixels)
 	if err != nil {
 		return nil, err
 	}
 
-	if dim.Height > cfg.AvatarHeight || dim.Width > cfg.AvatarWidth {
-		bounds := image.Rectangle{Min: image.Point{0, 0}, Max: image.Point{cfg.AvatarWidth, cfg.AvatarHeight}}
-		scaled := image.NewRGBA(bounds)
-		xdraw.NearestNeighbor.Scale(scaled, bounds, im, im.Bounds(), draw.Over, nil)
-		im = scaled
+	return encode(cfg, im, cfg.AvatarWidth, cfg.AvatarHeight)
+}
+
+// ScaleHeader decodes a profile header image, downscales it if needed and encodes it as PNG.
+func ScaleHeader(cfg *cfg.Config, data []byte) ([]byte, error) {
+	im, err := decode(data, cfg.MaxHeaderWidth, cfg.MaxHeaderHeight, int64(cfg.MaxHeaderWidth)*int64(cfg.MaxHeaderHeight))
+	if err != nil {
+		return nil, err
 	}
 
 	var b bytes.Buffer
-	if err := gif.Encode(&b, im, &gif.Options{NumColors: 256}); err != nil {
+	if err := (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&b, resize(im, cfg.HeaderWidth, cfg.HeaderHeight)); err != nil {
 		return nil, err
 	}
 
 	return b.Bytes(), nil
 }
+
+// Variants decodes an image and returns a scaled copy for each of [Sizes].
+func Variants(cfg *cfg.Config, data []byte) (map[int][]byte, error) {
+	im, err := decode(data, cfg.MaxAvatarWidth, cfg.MaxAvatarHeight, cfg.MaxAvatarPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		buf, err := encode(cfg, im, size, size)
+		if err != nil {
+			return nil, err
+		}
+
+		variants[size] = buf
+	}
+
+	return variants, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/dimkr/tootik/ap"
+)
+
+// updateActor replaces the cached copy of an actor that sent an Update activity about itself, so changes like a new
+// display name, avatar or handle appear immediately instead of when the cache expires.
+func (q *Queue) updateActor(ctx context.Context, log *slog.Logger, sender *ap.Actor, rawActivity string) error {
+	var update struct {
+		Object ap.Actor `json:"object"`
+	}
+	if err := json.Unmarshal([]byte(rawActivity), &update); err != nil {
+		return fmt.Errorf("failed to unmarshal actor update: %w", err)
+	}
+
+	actor := &update.Object
+
+	// if the Update is wrapped with another activity, we don't have the updated actor
+	if actor.ID != sender.ID {
+		log.Debug("Ignoring wrapped actor update")
+		return nil
+	}
+
+	if actor.Updated != nil && sender.Updated != nil && !actor.Updated.After(sender.Updated.Time) {
+		log.Debug("Received old actor update")
+		return nil
+	}
+
+	if actor.PreferredUsername == "" || actor.Inbox == "" {
+		return fmt.Errorf("received invalid update for %s", actor.ID)
+	}
+
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", actor.ID, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE persons SET actor = $1, updated = UNIXEPOCH(), fetched = UNIXEPOCH() WHERE id = $2`,
+		actor,
+		actor.ID,
+	); err != nil {
+		return fmt.Errorf("failed to update %s: %w", actor.ID, err)
+	}
+
+	// posts in feeds show the author and the sharer as they were when the post was added
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE feed SET author = $1 WHERE author->>'$.id' = $2`,
+		actor,
+		actor.ID,
+	); err != nil {
+		return fmt.Errorf("failed to update %s in feeds: %w", actor.ID, err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE feed SET sharer = $1 WHERE sharer->>'$.id' = $2`,
+		actor,
+		actor.ID,
+	); err != nil {
+		return fmt.Errorf("failed to update %s in feeds: %w", actor.ID, err)
+	}
+
+	// the old handle might point to this actor in the WebFinger cache
+	if actor.PreferredUsername != sender.PreferredUsername {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM webfinger WHERE actor = $1`, actor.ID); err != nil {
+			return fmt.Errorf("failed to update %s: %w", actor.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update %s: %w", actor.ID, err)
+	}
+
+	if q.Cache != nil {
+		q.Cache.InvalidateActor(actor.ID)
+	}
+
+	if actor.MovedTo != "" && actor.MovedTo != sender.MovedTo {
+		log.Info("Actor has moved", "new", actor.MovedTo)
+	} else {
+		log.Info("Updated actor")
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/inbox/note"
+	"github.com/dimkr/tootik/outbox"
+)
+
+type pendingPost struct {
+	Log         *slog.Logger
+	Sender      *ap.Actor
+	Activity    *ap.Activity
+	RawActivity string
+	Post        *ap.Object
+	Shared      bool
+}
+
+// postBatch holds new posts received in a batch of activities, until they're inserted.
+type postBatch struct {
+	posts []pendingPost
+	ids   map[string]struct{}
+}
+
+func (b *postBatch) Add(p pendingPost) {
+	if b.ids == nil {
+		b.ids = map[string]struct{}{}
+	}
+
+	b.posts = append(b.posts, p)
+	b.ids[p.Post.ID] = struct{}{}
+}
+
+func (b *postBatch) Contains(id string) bool {
+	_, ok := b.ids[id]
+	return ok
+}
+
+// canDefer determines whether or not processing of an activity doesn't depend on posts still in the batch.
+func canDefer(activity *ap.Activity) bool {
+	for activity.Type == ap.Announce {
+		inner, ok := activity.Object.(*ap.Activity)
+		if !ok {
+			return true
+		}
+		activity = inner
+	}
+
+	return activity.Type == ap.Create
+}
+
+func (q *Queue) insertPost(ctx context.Context, tx *sql.Tx, inserter *note.Inserter, shares *sql.Stmt, p *pendingPost) error {
+	if err := inserter.Insert(ctx, p.Post); err != nil {
+		return fmt.Errorf("cannot insert %s: %w", p.Post.ID, err)
+	}
+
+	if p.Shared {
+		if _, err := shares.ExecContext(ctx, p.Post.ID, p.Sender.ID, p.Activity.ID); err != nil {
+			return fmt.Errorf("cannot insert share for %s by %s: %w", p.Post.ID, p.Sender.ID, err)
+		}
+	}
+
+	if err := outbox.ForwardActivity(ctx, q.Domain, q.Config, tx, p.Post, p.Activity, p.RawActivity); err != nil {
+		return fmt.Errorf("cannot forward %s: %w", p.Post.ID, err)
+	}
+
+	return nil
+}
+
+func (q *Queue) insertPostsTx(ctx context.Context, posts []pendingPost) ([]pendingPost, error) {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	inserter, err := note.NewInserter(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	defer inserter.Close()
+
+	shares, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO shares (note, by, activity) VALUES(?,?,?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer shares.Close()
+
+	inserted := make([]pendingPost, 0, len(posts))
+
+	for _, p := range posts {
+		// a post that can't be inserted shouldn't prevent insertion of other posts
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT post`); err != nil {
+			return nil, err
+		}
+
+		if err := q.insertPost(ctx, tx, inserter, shares, &p); err != nil {
+			p.Log.Warn("Failed to process activity", "error", err)
+
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO post`); err != nil {
+				return nil, err
+			}
+		} else {
+			inserted = append(inserted, p)
+		}
+
+		if _, err := tx.ExecContext(ctx, `RELEASE post`); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return inserted, nil
+}
+
+// insertPosts inserts all posts in the batch in one transaction, then resolves mentioned users.
+// If the transaction fails, each post is inserted in its own transaction.
+func (q *Queue) insertPosts(ctx context.Context, b *postBatch) {
+	if len(b.posts) == 0 {
+		return
+	}
+
+	posts := b.posts
+	b.posts = nil
+	clear(b.ids)
+
+	inserted, err := q.insertPostsTx(ctx, posts)
+	if err != nil {
+		slog.Warn("Failed to insert posts, retrying one by one", "count", len(posts), "error", err)
+
+		// the activities are removed from the queue after the batch, so retry in smaller transactions instead of losing posts
+		inserted = make([]pendingPost, 0, len(posts))
+		for _, p := range posts {
+			if one, err := q.insertPostsTx(ctx, []pendingPost{p}); err != nil {
+				p.Log.Warn("Failed to insert post", "error", err)
+			} else {
+				inserted = append(inserted, one...)
+			}
+		}
+	}
+
+	for _, p := range inserted {
+		p.Log.Info("Received a new post")
+
+		if q.Cache != nil {
+			q.Cache.InvalidatePost(p.Post)
+		}
+
+		mentionedUsers := ap.Audience{}
+
+		for _, tag := range p.Post.Tag {
+			if tag.Type == ap.Mention && tag.Href != p.Post.AttributedTo {
+				mentionedUsers.Add(tag.Href)
+			}
+		}
+
+		for id := range mentionedUsers.Keys() {
+			if _, err := q.Resolver.ResolveID(ctx, q.Key, id, 0); err != nil {
+				p.Log.Warn("Failed to resolve mention", "mention", id, "error", err)
+			}
+		}
+	}
+}
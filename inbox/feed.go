@@ -19,97 +19,199 @@ package inbox
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/dimkr/tootik/cfg"
 )
 
+// Feed algorithms, in addition to the default one that sorts the feed by the time posts were received or shared
+const (
+	// FeedChronological sorts the feed by publication time.
+	FeedChronological = "chronological"
+
+	// FeedHashtags is like the default algorithm, but posts tagged with followed hashtags are boosted.
+	FeedHashtags = "hashtags"
+)
+
+// hashtagBoost is the advantage given to posts tagged with a followed hashtag.
+const hashtagBoost = 24 * 60 * 60
+
 type FeedUpdater struct {
 	Domain string
 	Config *cfg.Config
 	DB     *sql.DB
 }
 
-func (u FeedUpdater) Run(ctx context.Context) error {
+// RankFeed sets the sort key of feed items inserted after since, according to the user's feed algorithm.
+func RankFeed(ctx context.Context, tx *sql.Tx, follower string, since int64) error {
+	var algorithm sql.NullString
+	if err := tx.QueryRowContext(ctx, `select feed from settings where actor = ?`, follower).Scan(&algorithm); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var err error
+	switch algorithm.String {
+	case FeedChronological:
+		// a post can't claim to be published after it was received and stay on top of the feed
+		_, err = tx.ExecContext(ctx, `update feed set rank = min(coalesce(unixepoch(note->>'$.published'), inserted), inserted) where follower = ? and inserted >= ?`, follower, since)
+
+	case FeedHashtags:
+		_, err = tx.ExecContext(
+			ctx,
+			`update feed set rank = inserted + case when exists (select 1 from hashtags join followed_hashtags on followed_hashtags.hashtag = hashtags.hashtag where hashtags.note = feed.note->>'$.id' and followed_hashtags.follower = feed.follower) then ? else 0 end where follower = ? and inserted >= ?`,
+			hashtagBoost,
+			follower,
+			since,
+		)
+
+	default:
+		_, err = tx.ExecContext(ctx, `update feed set rank = inserted where follower = ? and inserted >= ?`, follower, since)
+	}
+
+	return err
+}
+
+// FeedUpdateQuery adds posts by followed users, replies to the user's posts, posts tagged with followed hashtags and
+// shares by followed users to the feed of a user.
+const FeedUpdateQuery = `insert into feed(follower, note, author, sharer, inserted)
+	select follows.follower, notes.object as note, persons.actor as author, null as sharer, notes.inserted from
+	follows
+	join
+	persons
+	on
+		persons.id = follows.followed
+	join
+	notes
+	on
+		notes.author = follows.followed and
+		(
+			notes.public = 1 or
+			persons.actor->>'$.followers' in (notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or
+			follows.follower in (notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or
+			(notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = persons.actor->>'$.followers' or value = follows.follower)) or
+			(notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = persons.actor->>'$.followers' or value = follows.follower))
+		)
+	where
+		follows.follower = $1 and
+		notes.inserted >= $2 and
+		not exists (select 1 from feed where feed.follower = follows.follower and feed.note->>'$.id' = +notes.id and feed.sharer is null)
+	union
+	select myposts.author as follower, notes.object as note, authors.actor as author, null as sharer, notes.inserted from
+	notes myposts
+	join
+	notes
+	on
+		notes.object->>'$.inReplyTo' = +myposts.id
+	join
+	persons authors
+	on
+		authors.id = notes.author
+	where
+		notes.author != myposts.author and
+		notes.inserted >= $2 and
+		myposts.author = $1 and
+		not exists (select 1 from feed where feed.follower = myposts.author and feed.note->>'$.id' = +notes.id and feed.sharer is null)
+	union
+	select followed_hashtags.follower, notes.object as note, authors.actor as author, null as sharer, notes.inserted from
+	followed_hashtags
+	join
+	hashtags
+	on
+		hashtags.hashtag = followed_hashtags.hashtag
+	join
+	notes
+	on
+		notes.id = hashtags.note
+	join
+	persons authors
+	on
+		authors.id = notes.author
+	where
+		notes.public = 1 and
+		notes.author != followed_hashtags.follower and
+		notes.inserted >= $2 and
+		followed_hashtags.follower = $1 and
+		not exists (select 1 from feed where feed.follower = followed_hashtags.follower and feed.note->>'$.id' = +notes.id and feed.sharer is null)
+	union all
+	select follows.follower, notes.object as note, authors.actor as author, sharers.actor as sharer, shares.inserted from
+	follows
+	join
+	shares
+	on
+		shares.by = follows.followed
+	join
+	notes
+	on
+		notes.id = shares.note
+	join
+	persons authors
+	on
+		authors.id = notes.author
+	join
+	persons sharers
+	on
+		sharers.id = follows.followed
+	where
+		notes.public = 1 and
+		shares.inserted >= $2 and
+		follows.follower = $1 and
+		not exists (select 1 from feed where feed.follower = follows.follower and feed.note->>'$.id' = +notes.id and feed.sharer->>'$.id' = sharers.id)`
+
+func (u FeedUpdater) update(ctx context.Context, follower string) error {
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	since := int64(0)
 	var ts sql.NullInt64
-	if err := u.DB.QueryRowContext(ctx, `select max(inserted) from feed where follower != author->>'$.id' and (sharer is null or follower != sharer->>'$.id')`).Scan(&ts); err != nil {
+	if err := tx.QueryRowContext(ctx, `select max(inserted) from feed where follower = $1 and author->>'$.id' != $1 and (sharer is null or sharer->>'$.id' != $1)`, follower).Scan(&ts); err != nil {
 		return err
 	} else if ts.Valid {
 		since = ts.Int64
 	}
 
-	if _, err := u.DB.ExecContext(
+	if _, err := tx.ExecContext(
 		ctx,
-		`
-			insert into feed(follower, note, author, sharer, inserted)
-			select follows.follower, notes.object as note, persons.actor as author, null as sharer, notes.inserted from
-			follows
-			join
-			persons
-			on
-				persons.id = follows.followed
-			join
-			notes
-			on
-				notes.author = follows.followed and
-				(
-					notes.public = 1 or
-					persons.actor->>'$.followers' in (notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or
-					follows.follower in (notes.cc0, notes.to0, notes.cc1, notes.to1, notes.cc2, notes.to2) or
-					(notes.to2 is not null and exists (select 1 from json_each(notes.object->'$.to') where value = persons.actor->>'$.followers' or value = follows.follower)) or
-					(notes.cc2 is not null and exists (select 1 from json_each(notes.object->'$.cc') where value = persons.actor->>'$.followers' or value = follows.follower))
-				)
-			where
-				follows.follower like $1 and
-				notes.inserted >= $2 and
-				not exists (select 1 from feed where feed.follower = follows.follower and feed.note->>'$.id' = notes.id and feed.sharer is null)
-			union
-			select myposts.author as follower, notes.object as note, authors.actor as author, null as sharer, notes.inserted from
-			notes myposts
-			join
-			notes
-			on
-				notes.object->>'$.inReplyTo' = myposts.id
-			join
-			persons authors
-			on
-				authors.id = notes.author
-			where
-				notes.author != myposts.author and
-				notes.inserted >= $2 and
-				myposts.author like $1 and
-				not exists (select 1 from feed where feed.follower = myposts.author and feed.note->>'$.id' = notes.id and feed.sharer is null)
-			union all
-			select follows.follower, notes.object as note, authors.actor as author, sharers.actor as sharer, shares.inserted from
-			follows
-			join
-			shares
-			on
-				shares.by = follows.followed
-			join
-			notes
-			on
-				notes.id = shares.note
-			join
-			persons authors
-			on
-				authors.id = notes.author
-			join
-			persons sharers
-			on
-				sharers.id = follows.followed
-			where
-				notes.public = 1 and
-				shares.inserted >= $2 and
-				follows.follower like $1 and
-				not exists (select 1 from feed where feed.follower = follows.follower and feed.note->>'$.id' = notes.id and feed.sharer->>'$.id' = sharers.id)
-		`,
-		fmt.Sprintf("https://%s/%%", u.Domain),
+		FeedUpdateQuery,
+		follower,
 		since,
 	); err != nil {
 		return err
 	}
 
+	if err := RankFeed(ctx, tx, follower, since); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Run adds new posts to the feed of each local user.
+func (u FeedUpdater) Run(ctx context.Context) error {
+	rows, err := u.DB.QueryContext(ctx, `select id from persons where host = ?`, u.Domain)
+	if err != nil {
+		return err
+	}
+
+	var followers []string
+	for rows.Next() {
+		var follower string
+		if err := rows.Scan(&follower); err != nil {
+			rows.Close()
+			return err
+		}
+		followers = append(followers, follower)
+	}
+	rows.Close()
+
+	for _, follower := range followers {
+		if err := u.update(ctx, follower); err != nil {
+			return fmt.Errorf("failed to update feed of %s: %w", follower, err)
+		}
+	}
+
 	return nil
 }
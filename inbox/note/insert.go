@@ -58,8 +58,56 @@ func Flatten(note *ap.Object) string {
 	return b.String()
 }
 
+// Inserter inserts posts using prepared statements, which can be reused across multiple posts in one transaction.
+type Inserter struct {
+	notes    *sql.Stmt
+	fts      *sql.Stmt
+	hashtags *sql.Stmt
+}
+
+// NewInserter prepares the statements used to insert posts.
+func NewInserter(ctx context.Context, tx *sql.Tx) (*Inserter, error) {
+	notes, err := tx.PrepareContext(ctx, `INSERT INTO notes (id, author, object, public) VALUES(?,?,?,?)`)
+	if err != nil {
+		return nil, err
+	}
+
+	fts, err := tx.PrepareContext(ctx, `INSERT INTO notesfts (id, content) VALUES(?,?)`)
+	if err != nil {
+		notes.Close()
+		return nil, err
+	}
+
+	hashtags, err := tx.PrepareContext(ctx, `insert into hashtags (note, hashtag) values(?,?)`)
+	if err != nil {
+		notes.Close()
+		fts.Close()
+		return nil, err
+	}
+
+	return &Inserter{notes: notes, fts: fts, hashtags: hashtags}, nil
+}
+
+// Close frees the prepared statements.
+func (i *Inserter) Close() {
+	i.notes.Close()
+	i.fts.Close()
+	i.hashtags.Close()
+}
+
 // Insert inserts a post.
 func Insert(ctx context.Context, tx *sql.Tx, note *ap.Object) error {
+	i, err := NewInserter(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to insert note %s: %w", note.ID, err)
+	}
+	defer i.Close()
+
+	return i.Insert(ctx, note)
+}
+
+// Insert inserts a post.
+func (i *Inserter) Insert(ctx context.Context, note *ap.Object) error {
 	hashtags := map[string]string{}
 
 	for _, tag := range note.Tag {
@@ -83,9 +131,8 @@ func Insert(ctx context.Context, tx *sql.Tx, note *ap.Object) error {
 		public = 1
 	}
 
-	if _, err := tx.ExecContext(
+	if _, err := i.notes.ExecContext(
 		ctx,
-		`INSERT INTO notes (id, author, object, public) VALUES(?,?,?,?)`,
 		note.ID,
 		note.AttributedTo,
 		&note,
@@ -94,9 +141,8 @@ func Insert(ctx context.Context, tx *sql.Tx, note *ap.Object) error {
 		return fmt.Errorf("failed to insert note %s: %w", note.ID, err)
 	}
 
-	if _, err := tx.ExecContext(
+	if _, err := i.fts.ExecContext(
 		ctx,
-		`INSERT INTO notesfts (id, content) VALUES(?,?)`,
 		note.ID,
 		Flatten(note),
 	); err != nil {
@@ -104,7 +150,7 @@ func Insert(ctx context.Context, tx *sql.Tx, note *ap.Object) error {
 	}
 
 	for _, hashtag := range hashtags {
-		if _, err := tx.ExecContext(ctx, `insert into hashtags (note, hashtag) values(?,?)`, note.ID, hashtag); err != nil {
+		if _, err := i.hashtags.ExecContext(ctx, note.ID, hashtag); err != nil {
 			slog.Warn("Failed to tag post", "post", note.ID, "hashtag", hashtag, "error", err)
 		}
 	}
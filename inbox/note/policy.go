@@ -0,0 +1,40 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package note
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dimkr/tootik/ap"
+)
+
+// CanReply determines if an actor can reply to a post, according to the post's interaction policy.
+func CanReply(ctx context.Context, db *sql.DB, post *ap.Object, author *ap.Actor, actor string) (allowed, approval bool, err error) {
+	if post.InteractionPolicy == nil || post.InteractionPolicy.CanReply == nil {
+		return true, false, nil
+	}
+
+	var follower bool
+	if err := db.QueryRowContext(ctx, `select exists (select 1 from follows where follower = ? and followed = ? and accepted = 1)`, actor, author.ID).Scan(&follower); err != nil {
+		return false, false, fmt.Errorf("failed to check if %s follows %s: %w", actor, author.ID, err)
+	}
+
+	allowed, approval = post.CanReply(author, actor, follower)
+	return allowed, approval, nil
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/httpsig"
+)
+
+// PollRefresher fetches the final results of remote polls after they end.
+type PollRefresher struct {
+	Domain   string
+	Config   *cfg.Config
+	DB       *sql.DB
+	Resolver ap.Resolver
+	Key      httpsig.Key
+}
+
+// RefreshPoll fetches a remote poll and updates the cached results.
+func RefreshPoll(ctx context.Context, cfg *cfg.Config, db *sql.DB, resolver ap.Resolver, key httpsig.Key, poll *ap.Object) error {
+	resp, err := resolver.Get(ctx, key, poll.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", poll.ID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > cfg.MaxResponseBodySize {
+		return fmt.Errorf("failed to fetch %s: response is too big", poll.ID)
+	}
+
+	var fetched ap.Object
+	if err := json.NewDecoder(io.LimitReader(resp.Body, cfg.MaxResponseBodySize)).Decode(&fetched); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", poll.ID, err)
+	}
+
+	if fetched.ID != poll.ID {
+		return fmt.Errorf("%s does not match %s", fetched.ID, poll.ID)
+	}
+
+	if fetched.Type != ap.Question {
+		return fmt.Errorf("%s is not a poll: %s", poll.ID, fetched.Type)
+	}
+
+	if fetched.AttributedTo != poll.AttributedTo {
+		return fmt.Errorf("%s is attributed to %s instead of %s", poll.ID, fetched.AttributedTo, poll.AttributedTo)
+	}
+
+	// only results can change: the question and the options stay the same
+	poll.VotersCount = fetched.VotersCount
+	poll.EndTime = fetched.EndTime
+	poll.Closed = fetched.Closed
+
+	for i := range poll.OneOf {
+		for _, option := range fetched.OneOf {
+			if option.Name == poll.OneOf[i].Name {
+				poll.OneOf[i].Replies.TotalItems = option.Replies.TotalItems
+				break
+			}
+		}
+	}
+
+	for i := range poll.AnyOf {
+		for _, option := range fetched.AnyOf {
+			if option.Name == poll.AnyOf[i].Name {
+				poll.AnyOf[i].Replies.TotalItems = option.Replies.TotalItems
+				break
+			}
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", poll.ID, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`update notes set object = ?, updated = unixepoch() where id = ?`,
+		poll,
+		poll.ID,
+	); err != nil {
+		return fmt.Errorf("failed to update %s: %w", poll.ID, err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`update feed set note = ? where note->>'$.id' = ?`,
+		poll,
+		poll.ID,
+	); err != nil {
+		return fmt.Errorf("failed to update %s: %w", poll.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update %s: %w", poll.ID, err)
+	}
+
+	return nil
+}
+
+func (r *PollRefresher) Run(ctx context.Context) error {
+	// polls voted on by local users are refreshed once, after they end, and failed refreshes are retried with a growing
+	// interval between attempts
+	rows, err := r.DB.QueryContext(
+		ctx,
+		`select object from notes
+		where
+			object->>'$.type' = 'Question' and
+			host != $1 and
+			unixepoch(object->>'$.endTime') < unixepoch() and
+			updated < unixepoch(object->>'$.endTime') and
+			exists (select 1 from notes votes where votes.object->>'$.inReplyTo' = notes.id and votes.host = $1 and votes.object->>'$.name' is not null) and
+			not exists (select 1 from pollrefreshes where pollrefreshes.poll = notes.id and (pollrefreshes.attempts >= $2 or pollrefreshes.last > unixepoch() - $3 * pollrefreshes.attempts))
+		order by unixepoch(object->>'$.endTime')
+		limit $4`,
+		r.Domain,
+		r.Config.MaxPollRefreshAttempts,
+		int64(r.Config.PollRefreshRetryInterval/time.Second),
+		r.Config.PollRefreshBatchSize,
+	)
+	if err != nil {
+		return err
+	}
+
+	var polls []ap.Object
+	for rows.Next() {
+		var poll ap.Object
+		if err := rows.Scan(&poll); err != nil {
+			slog.Warn("Failed to scan poll", "error", err)
+			continue
+		}
+
+		polls = append(polls, poll)
+	}
+	rows.Close()
+
+	for _, poll := range polls {
+		slog.Info("Refreshing poll results", "poll", poll.ID)
+
+		if err := RefreshPoll(ctx, r.Config, r.DB, r.Resolver, r.Key, &poll); errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		} else if err != nil {
+			slog.Warn("Failed to refresh poll results", "poll", poll.ID, "error", err)
+
+			if _, err := r.DB.ExecContext(
+				ctx,
+				`insert into pollrefreshes(poll, attempts, last) values(?, 1, unixepoch()) on conflict(poll) do update set attempts = attempts + 1, last = unixepoch()`,
+				poll.ID,
+			); err != nil {
+				return err
+			}
+		} else if _, err := r.DB.ExecContext(ctx, `delete from pollrefreshes where poll = ?`, poll.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -35,6 +35,12 @@ import (
 	"github.com/dimkr/tootik/outbox"
 )
 
+// PageCache is a cache of pages that might show received posts and actors.
+type PageCache interface {
+	InvalidatePost(*ap.Object)
+	InvalidateActor(string)
+}
+
 type Queue struct {
 	Domain    string
 	Config    *cfg.Config
@@ -42,6 +48,7 @@ type Queue struct {
 	DB        *sql.DB
 	Resolver  ap.Resolver
 	Key       httpsig.Key
+	Cache     PageCache
 }
 
 type batchItem struct {
@@ -49,11 +56,12 @@ type batchItem struct {
 	RawActivity string
 	Sender      *ap.Actor
 	Shared      bool
+	Request     sql.NullString
 }
 
 var ErrActivityTooNested = errors.New("exceeded activity depth limit")
 
-func (q *Queue) processCreateActivity(ctx context.Context, log *slog.Logger, sender *ap.Actor, activity *ap.Activity, rawActivity string, post *ap.Object, shared bool) error {
+func (q *Queue) processCreateActivity(ctx context.Context, b *postBatch, log *slog.Logger, sender *ap.Actor, activity *ap.Activity, rawActivity string, post *ap.Object, shared bool) error {
 	prefix := fmt.Sprintf("https://%s/", q.Domain)
 	if strings.HasPrefix(sender.ID, prefix) || strings.HasPrefix(post.ID, prefix) || strings.HasPrefix(post.AttributedTo, prefix) || strings.HasPrefix(activity.Actor, prefix) {
 		return fmt.Errorf("received invalid Create for %s by %s from %s", post.ID, post.AttributedTo, activity.Actor)
@@ -77,6 +85,11 @@ func (q *Queue) processCreateActivity(ctx context.Context, log *slog.Logger, sen
 		return nil
 	}
 
+	// the post might be received twice in the same batch
+	if b.Contains(post.ID) {
+		q.insertPosts(ctx, b)
+	}
+
 	var audience sql.NullString
 	if err := q.DB.QueryRowContext(ctx, `select object->>'$.audience' from notes where id = ?`, post.ID).Scan(&audience); err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return fmt.Errorf("failed to check of %s is a duplicate: %w", post.ID, err)
@@ -131,61 +144,41 @@ func (q *Queue) processCreateActivity(ctx context.Context, log *slog.Logger, sen
 		return fmt.Errorf("failed to resolve %s: %w", post.AttributedTo, err)
 	}
 
-	tx, err := q.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("cannot insert %s: %w", post.ID, err)
+	// local users can restrict who can reply to their posts
+	if strings.HasPrefix(post.InReplyTo, prefix) {
+		var parent ap.Object
+		var parentAuthor ap.Actor
+		if err := q.DB.QueryRowContext(ctx, `select notes.object, persons.actor from notes join persons on persons.id = notes.author where notes.id = ?`, post.InReplyTo).Scan(&parent, &parentAuthor); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to fetch %s: %w", post.InReplyTo, err)
+		} else if err == nil && !(parent.Type == ap.Question && post.Name != "") {
+			// poll votes are replies too, but anyone who can see a poll can vote
+			if allowed, _, err := note.CanReply(ctx, q.DB, &parent, &parentAuthor, post.AttributedTo); err != nil {
+				return err
+			} else if !allowed {
+				log.Info("Ignoring reply forbidden by interaction policy", "parent", post.InReplyTo)
+				return nil
+			}
+		}
 	}
-	defer tx.Rollback()
 
 	// only the group itself has the authority to decide which posts belong to it
 	if post.Audience != sender.ID {
 		post.Audience = ""
 	}
 
-	if err := note.Insert(ctx, tx, post); err != nil {
-		return fmt.Errorf("cannot insert %s: %w", post.ID, err)
-	}
-
-	if shared {
-		if _, err := tx.ExecContext(
-			ctx,
-			`INSERT OR IGNORE INTO shares (note, by, activity) VALUES(?,?,?)`,
-			post.ID,
-			sender.ID,
-			activity.ID,
-		); err != nil {
-			return fmt.Errorf("cannot insert share for %s by %s: %w", post.ID, sender.ID, err)
-		}
-	}
-
-	if err := outbox.ForwardActivity(ctx, q.Domain, q.Config, tx, post, activity, rawActivity); err != nil {
-		return fmt.Errorf("cannot forward %s: %w", post.ID, err)
-	}
-
-	log.Info("Received a new post")
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("cannot insert %s: %w", post.ID, err)
-	}
-
-	mentionedUsers := ap.Audience{}
-
-	for _, tag := range post.Tag {
-		if tag.Type == ap.Mention && tag.Href != post.AttributedTo {
-			mentionedUsers.Add(tag.Href)
-		}
-	}
-
-	for id := range mentionedUsers.Keys() {
-		if _, err := q.Resolver.ResolveID(ctx, q.Key, id, 0); err != nil {
-			log.Warn("Failed to resolve mention", "mention", id, "error", err)
-		}
-	}
+	b.Add(pendingPost{
+		Log:         log,
+		Sender:      sender,
+		Activity:    activity,
+		RawActivity: rawActivity,
+		Post:        post,
+		Shared:      shared,
+	})
 
 	return nil
 }
 
-func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *ap.Actor, activity *ap.Activity, rawActivity string, depth int, shared bool) error {
+func (q *Queue) processActivity(ctx context.Context, b *postBatch, log *slog.Logger, sender *ap.Actor, activity *ap.Activity, rawActivity string, depth int, shared bool) error {
 	if depth == ap.MaxActivityDepth {
 		return ErrActivityTooNested
 	}
@@ -207,9 +200,23 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 		log.Info("Received delete request", "deleted", deleted)
 
 		if deleted == activity.Actor {
-			if _, err := q.DB.ExecContext(ctx, `delete from persons where id = ?`, deleted); err != nil {
+			tx, err := q.DB.BeginTx(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("cannot delete %s: %w", deleted, err)
+			}
+			defer tx.Rollback()
+
+			if err := data.DeleteActor(ctx, tx, deleted); err != nil {
+				return err
+			}
+
+			if err := tx.Commit(); err != nil {
 				return fmt.Errorf("failed to delete person %s: %w", deleted, err)
 			}
+
+			if q.Cache != nil {
+				q.Cache.InvalidateActor(deleted)
+			}
 		} else {
 			tx, err := q.DB.BeginTx(ctx, nil)
 			if err != nil {
@@ -229,6 +236,9 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 				return fmt.Errorf("failed to delete %s: %w", deleted, err)
 			}
 
+			if _, err := tx.ExecContext(ctx, `insert or ignore into tombstones (id, parent, inserted) select id, object->>'$.inReplyTo', inserted from notes where id = ?`, deleted); err != nil {
+				return fmt.Errorf("cannot delete %s: %w", deleted, err)
+			}
 			if _, err := tx.ExecContext(ctx, `delete from notesfts where id = ?`, deleted); err != nil {
 				return fmt.Errorf("cannot delete %s: %w", deleted, err)
 			}
@@ -245,6 +255,10 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 			if err := tx.Commit(); err != nil {
 				return fmt.Errorf("failed to delete %s: %w", deleted, err)
 			}
+
+			if q.Cache != nil {
+				q.Cache.InvalidatePost(&note)
+			}
 		}
 
 	case ap.Follow:
@@ -270,6 +284,14 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 			return fmt.Errorf("failed to fetch %s: %w", followed, err)
 		}
 
+		var banned int
+		if err := q.DB.QueryRowContext(ctx, `select exists (select 1 from bans where community = ? and actor = ?)`, followed, activity.Actor).Scan(&banned); err != nil {
+			return fmt.Errorf("failed to check if %s is banned from %s: %w", activity.Actor, followed, err)
+		} else if banned == 1 {
+			log.Info("Ignoring follow request from banned user", "follower", activity.Actor, "followed", followed)
+			return nil
+		}
+
 		log.Info("Approving follow request", "follower", activity.Actor, "followed", followed)
 
 		if err := outbox.Accept(ctx, q.Domain, followed, activity.Actor, activity.ID, q.DB); err != nil {
@@ -295,17 +317,60 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 			return fmt.Errorf("failed to accept follow %s: %w", followID, err)
 		}
 
+	case ap.Reject:
+		if sender.ID != activity.Actor {
+			return fmt.Errorf("received an invalid follow rejection for %s by %s", activity.Actor, sender.ID)
+		}
+
+		followID, ok := activity.Object.(string)
+		if ok && followID != "" {
+			log.Info("Follow is rejected", "follow", followID)
+		} else if followActivity, ok := activity.Object.(*ap.Activity); ok && followActivity.Type == ap.Follow && followActivity.ID != "" {
+			log.Info("Follow is rejected", "follow", followActivity.ID)
+			followID = followActivity.ID
+		} else {
+			return errors.New("received an invalid reject notification")
+		}
+
+		if _, err := q.DB.ExecContext(ctx, `delete from follows where id = ? and followed = ?`, followID, sender.ID); err != nil {
+			return fmt.Errorf("failed to remove rejected follow %s: %w", followID, err)
+		}
+
 	case ap.Undo:
 		inner, ok := activity.Object.(*ap.Activity)
 		if !ok {
 			return errors.New("received a request to undo a non-activity object")
 		}
 
-		if inner.Type == ap.Announce {
-			noteID, ok := inner.Object.(string)
-			if !ok {
+		// only an actor can undo its own activity, and only an undo sent by this actor is forwarded
+		forwardUndo := func() error {
+			if sender.ID != activity.Actor || inner.Actor != activity.Actor {
+				return nil
+			}
+
+			if err := outbox.ForwardUndo(ctx, q.Domain, q.DB, activity, rawActivity); err != nil {
+				return fmt.Errorf("failed to forward undo of %s: %w", inner.ID, err)
+			}
+
+			return nil
+		}
+
+		switch inner.Type {
+		case ap.Announce:
+			if inner.Actor != activity.Actor {
+				return fmt.Errorf("received an invalid undo request for %s by %s", inner.ID, activity.Actor)
+			}
+
+			var noteID string
+			if note, ok := inner.Object.(*ap.Object); ok {
+				noteID = note.ID
+			} else if s, ok := inner.Object.(string); ok {
+				noteID = s
+			}
+			if noteID == "" {
 				return errors.New("cannot undo Announce")
 			}
+
 			if _, err := q.DB.ExecContext(
 				ctx,
 				`delete from shares where note = ? and by = ?`,
@@ -314,12 +379,19 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 			); err != nil {
 				return fmt.Errorf("failed to remove share for %s by %s: %w", noteID, activity.Actor, err)
 			}
-			return nil
+
+			log.Info("Removed a share", "note", noteID, "by", activity.Actor)
+			return forwardUndo()
+
+		case ap.Like, ap.Dislike, ap.EmojiReact, ap.Block:
+			// we don't count reactions or keep track of blocks by federated users, so there's nothing to undo
+			log.Debug("Ignoring request to undo an untracked activity")
+			return forwardUndo()
 		}
 
 		if inner.Type != ap.Follow {
 			log.Debug("Ignoring request to undo a non-Follow activity")
-			return nil
+			return forwardUndo()
 		}
 
 		if sender.ID != activity.Actor {
@@ -360,7 +432,7 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 			return errors.New("received invalid Create")
 		}
 
-		return q.processCreateActivity(ctx, log, sender, activity, rawActivity, post, shared)
+		return q.processCreateActivity(ctx, b, log, sender, activity, rawActivity, post, shared)
 
 	case ap.Announce:
 		inner, ok := activity.Object.(*ap.Activity)
@@ -368,10 +440,11 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 			if postID, ok := activity.Object.(string); ok && postID != "" {
 				if _, err := q.DB.ExecContext(
 					ctx,
-					`INSERT OR IGNORE INTO shares (note, by, activity) VALUES(?,?,?)`,
+					`INSERT OR IGNORE INTO shares (note, by, activity, public) VALUES(?,?,?,?)`,
 					postID,
 					sender.ID,
 					activity.ID,
+					activity.IsPublic(),
 				); err != nil {
 					return fmt.Errorf("cannot insert share for %s by %s: %w", postID, sender.ID, err)
 				}
@@ -382,11 +455,13 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 		}
 
 		depth++
-		return q.processActivity(ctx, log.With("activity", inner, "depth", depth), sender, inner, rawActivity, depth, true)
+		return q.processActivity(ctx, b, log.With("activity", inner, "depth", depth), sender, inner, rawActivity, depth, true)
 
 	case ap.Update:
 		post, ok := activity.Object.(*ap.Object)
-		if !ok || post.ID == activity.Actor || post.ID == sender.ID {
+		if ok && post.ID == activity.Actor && post.ID == sender.ID {
+			return q.updateActor(ctx, log, sender, rawActivity)
+		} else if !ok || post.ID == activity.Actor || post.ID == sender.ID {
 			log.Debug("Ignoring unsupported Update object")
 			return nil
 		}
@@ -399,7 +474,7 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 		var lastChange int64
 		if err := q.DB.QueryRowContext(ctx, `select max(inserted, updated), object from notes where id = ? and author = ?`, post.ID, post.AttributedTo).Scan(&lastChange, &oldPost); err != nil && errors.Is(err, sql.ErrNoRows) {
 			log.Debug("Received Update for non-existing post")
-			return q.processCreateActivity(ctx, log, sender, activity, rawActivity, post, shared)
+			return q.processCreateActivity(ctx, b, log, sender, activity, rawActivity, post, shared)
 		} else if err != nil {
 			return fmt.Errorf("failed to get last update time for %s: %w", post.ID, err)
 		}
@@ -469,12 +544,18 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 			return fmt.Errorf("failed to update post %s: %w", post.ID, err)
 		}
 
+		if q.Cache != nil {
+			q.Cache.InvalidatePost(&oldPost)
+			q.Cache.InvalidatePost(post)
+		}
+
 		log.Info("Updated post")
 
 	case ap.Move:
 		log.Debug("Ignoring Move activity")
 
-	case ap.Like, ap.Dislike, ap.EmojiReact, ap.Add, ap.Remove:
+	case ap.Like, ap.Dislike, ap.EmojiReact, ap.Add, ap.Remove, ap.Block, ap.Listen, ap.Read:
+		// a track someone listened to or a book someone read is not a share, and an embedded object is queued as Update
 		log.Debug("Ignoring activity")
 
 	default:
@@ -488,21 +569,31 @@ func (q *Queue) processActivity(ctx context.Context, log *slog.Logger, sender *a
 	return nil
 }
 
-func (q *Queue) processActivityWithTimeout(parent context.Context, sender *ap.Actor, activity *ap.Activity, rawActivity string, shared bool) {
+func (q *Queue) processActivityWithTimeout(parent context.Context, b *postBatch, sender *ap.Actor, activity *ap.Activity, rawActivity string, shared bool, request sql.NullString) {
 	ctx, cancel := context.WithTimeout(parent, q.Config.ActivityProcessingTimeout)
 	defer cancel()
 
 	log := slog.With("activity", activity, "sender", sender.ID)
-	if err := q.processActivity(ctx, log, sender, activity, rawActivity, 1, shared); err != nil {
+	if request.Valid {
+		log = log.With("request", request.String)
+	}
+	if err := q.processActivity(ctx, b, log, sender, activity, rawActivity, 1, shared); err != nil {
 		log.Warn("Failed to process activity", "error", err)
 	}
 }
 
+func (q *Queue) insertPostsWithTimeout(parent context.Context, b *postBatch) {
+	ctx, cancel := context.WithTimeout(parent, q.Config.ActivityProcessingTimeout)
+	defer cancel()
+
+	q.insertPosts(ctx, b)
+}
+
 // ProcessBatch processes one batch of incoming activites in the queue.
 func (q *Queue) ProcessBatch(ctx context.Context) (int, error) {
 	slog.Debug("Polling activities queue")
 
-	rows, err := q.DB.QueryContext(ctx, `select inbox.id, persons.actor, inbox.activity, inbox.raw, inbox.raw->>'$.type' = 'Announce' as shared from (select * from inbox limit -1 offset case when (select count(*) from inbox) >= $1 then $1/10 else 0 end) inbox left join persons on persons.id = inbox.sender order by inbox.id limit $2`, q.Config.MaxActivitiesQueueSize, q.Config.ActivitiesBatchSize)
+	rows, err := q.DB.QueryContext(ctx, `select inbox.id, persons.actor, inbox.activity, inbox.raw, inbox.raw->>'$.type' = 'Announce' as shared, inbox.request from (select * from inbox limit -1 offset case when (select count(*) from inbox) >= $1 then $1/10 else 0 end) inbox left join persons on persons.id = inbox.sender order by inbox.id limit $2`, q.Config.MaxActivitiesQueueSize, q.Config.ActivitiesBatchSize)
 	if err != nil {
 		return 0, fmt.Errorf("failed to fetch activities to process: %w", err)
 	}
@@ -520,7 +611,8 @@ func (q *Queue) ProcessBatch(ctx context.Context) (int, error) {
 		var activity ap.Activity
 		var sender sql.Null[ap.Actor]
 		var shared bool
-		if err := rows.Scan(&id, &sender, &activity, &activityString, &shared); err != nil {
+		var request sql.NullString
+		if err := rows.Scan(&id, &sender, &activity, &activityString, &shared, &request); err != nil {
 			slog.Error("Failed to scan activity", "error", err)
 			continue
 		}
@@ -537,6 +629,7 @@ func (q *Queue) ProcessBatch(ctx context.Context) (int, error) {
 			RawActivity: activityString,
 			Sender:      &sender.V,
 			Shared:      shared,
+			Request:     request,
 		})
 	}
 	rows.Close()
@@ -545,10 +638,19 @@ func (q *Queue) ProcessBatch(ctx context.Context) (int, error) {
 		return 0, nil
 	}
 
+	var posts postBatch
+
 	for _, item := range batch {
-		q.processActivityWithTimeout(ctx, item.Sender, item.Activity, item.RawActivity, item.Shared)
+		// new posts are inserted together, before any activity that might refer to them
+		if !canDefer(item.Activity) {
+			q.insertPostsWithTimeout(ctx, &posts)
+		}
+
+		q.processActivityWithTimeout(ctx, &posts, item.Sender, item.Activity, item.RawActivity, item.Shared, item.Request)
 	}
 
+	q.insertPostsWithTimeout(ctx, &posts)
+
 	if _, err := q.DB.ExecContext(ctx, `delete from inbox where id <= ?`, maxID); err != nil {
 		return 0, fmt.Errorf("failed to delete processed activities: %w", err)
 	}
@@ -0,0 +1,131 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// File is a log file that gets rotated when it becomes too big.
+type File struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	lock sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenFile opens a log file for appending.
+// When the file reaches maxSize bytes, it's renamed to path.1, path.1 is renamed to path.2 and so on, and up to
+// maxFiles old files are kept.
+func OpenFile(path string, maxSize int64, maxFiles int) (*File, error) {
+	l := File{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+func (l *File) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.f = f
+	l.size = info.Size()
+	return nil
+}
+
+// shift renames old files and deletes the oldest one.
+func (l *File) shift() error {
+	if err := os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	for i := l.maxFiles - 1; i > 0; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if l.maxFiles > 0 {
+		return os.Rename(l.path, l.path+".1")
+	}
+
+	return os.Remove(l.path)
+}
+
+func (l *File) rotate() error {
+	l.f.Close()
+	l.f = nil
+
+	// if renaming fails, keep appending to the current file
+	err := l.shift()
+
+	if openErr := l.open(); openErr != nil {
+		return openErr
+	}
+
+	return err
+}
+
+func (l *File) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.f == nil {
+		return 0, fs.ErrClosed
+	}
+
+	if l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); l.f == nil {
+			return 0, err
+		}
+	}
+
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (l *File) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile_Rotate(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "tootik.log")
+
+	f, err := OpenFile(path, 10, 2)
+	assert.NoError(err)
+
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		n, err := f.Write([]byte(line))
+		assert.NoError(err)
+		assert.Equal(len(line), n)
+	}
+
+	assert.NoError(f.Close())
+
+	for suffix, expected := range map[string]string{"": "dddddddd\n", ".1": "cccccccc\n", ".2": "bbbbbbbb\n"} {
+		buf, err := os.ReadFile(path + suffix)
+		assert.NoError(err)
+		assert.Equal(expected, string(buf))
+	}
+
+	_, err = os.Stat(path + ".3")
+	assert.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestFile_Append(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "tootik.log")
+	assert.NoError(os.WriteFile(path, []byte("aaaa\n"), 0600))
+
+	f, err := OpenFile(path, 10, 1)
+	assert.NoError(err)
+
+	_, err = f.Write([]byte("bbbb\n"))
+	assert.NoError(err)
+
+	// the existing file is too big for this line
+	_, err = f.Write([]byte("c\n"))
+	assert.NoError(err)
+	assert.NoError(f.Close())
+
+	buf, err := os.ReadFile(path + ".1")
+	assert.NoError(err)
+	assert.Equal("aaaa\nbbbb\n", string(buf))
+
+	buf, err = os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal("c\n", string(buf))
+
+	_, err = f.Write([]byte("d\n"))
+	assert.Error(err)
+}
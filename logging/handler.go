@@ -0,0 +1,135 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging configures logging.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Subsystems maps subsystems that have their own log level to the packages they consist of.
+var Subsystems = map[string][]string{
+	"fed":   {"github.com/dimkr/tootik/fed"},
+	"inbox": {"github.com/dimkr/tootik/inbox"},
+	"front": {"github.com/dimkr/tootik/front"},
+	"jobs":  {"main", "github.com/dimkr/tootik/data", "github.com/dimkr/tootik/outbox"},
+}
+
+// Handler is a [slog.Handler] that filters records by the log level of the subsystem that logged them.
+type Handler struct {
+	inner   slog.Handler
+	def     slog.Level
+	levels  map[string]slog.Level
+	min     slog.Level
+	callers *sync.Map
+}
+
+// NewHandler returns a new [Handler] that passes records to inner.
+// Records logged by subsystems without a log level in levels are filtered using def.
+func NewHandler(inner slog.Handler, def slog.Level, levels map[string]slog.Level) (*Handler, error) {
+	min := def
+	for subsystem, level := range levels {
+		if _, ok := Subsystems[subsystem]; !ok {
+			return nil, fmt.Errorf("unknown subsystem: %s", subsystem)
+		}
+
+		if level < min {
+			min = level
+		}
+	}
+
+	return &Handler{
+		inner:   inner,
+		def:     def,
+		levels:  levels,
+		min:     min,
+		callers: &sync.Map{},
+	}, nil
+}
+
+// subsystem returns the subsystem a function belongs to.
+func subsystem(function string) string {
+	// github.com/dimkr/tootik/front/gemini.(*Listener).Handle -> github.com/dimkr/tootik/front/gemini
+	pkg := function
+	if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+		if j := strings.IndexByte(pkg[i:], '.'); j >= 0 {
+			pkg = pkg[:i+j]
+		}
+	} else if j := strings.IndexByte(pkg, '.'); j >= 0 {
+		pkg = pkg[:j]
+	}
+
+	for name, prefixes := range Subsystems {
+		for _, prefix := range prefixes {
+			if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// level returns the log level of the subsystem that logged a record.
+func (h *Handler) level(pc uintptr) slog.Level {
+	if len(h.levels) == 0 || pc == 0 {
+		return h.def
+	}
+
+	if level, ok := h.callers.Load(pc); ok {
+		return level.(slog.Level)
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+
+	level, ok := h.levels[subsystem(frame.Function)]
+	if !ok {
+		level = h.def
+	}
+
+	h.callers.Store(pc, level)
+	return level
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.min && h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.level(r.PC) {
+		return nil
+	}
+
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithAttrs(attrs)
+	return &c
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithGroup(name)
+	return &c
+}
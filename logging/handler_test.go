@@ -0,0 +1,72 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_Subsystem(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("fed", subsystem("github.com/dimkr/tootik/fed.(*Listener).handleInbox"))
+	assert.Equal("front", subsystem("github.com/dimkr/tootik/front/gemini.(*Listener).Handle.func1"))
+	assert.Equal("inbox", subsystem("github.com/dimkr/tootik/inbox/note.Insert"))
+	assert.Equal("jobs", subsystem("github.com/dimkr/tootik/data.(*GarbageCollector).Run"))
+	assert.Equal("jobs", subsystem("main.main"))
+	assert.Equal("", subsystem("github.com/dimkr/tootik/ap.(*Object).Scan"))
+	assert.Equal("", subsystem("github.com/dimkr/tootik/federation.Run"))
+}
+
+func TestHandler_Levels(t *testing.T) {
+	assert := assert.New(t)
+
+	Subsystems["test"] = []string{"github.com/dimkr/tootik/logging"}
+	defer delete(Subsystems, "test")
+
+	var buf bytes.Buffer
+	h, err := NewHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), slog.LevelWarn, map[string]slog.Level{"test": slog.LevelDebug})
+	assert.NoError(err)
+
+	log := slog.New(h)
+	log.Debug("from test")
+	log.With("request", "x").Info("with attrs")
+
+	assert.Equal(2, strings.Count(buf.String(), "\n"))
+	assert.Contains(buf.String(), "request=x")
+
+	buf.Reset()
+	h, err = NewHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), slog.LevelWarn, map[string]slog.Level{"fed": slog.LevelDebug})
+	assert.NoError(err)
+
+	log = slog.New(h)
+	log.Info("filtered")
+	log.Warn("not filtered")
+
+	assert.NotContains(buf.String(), "msg=filtered")
+	assert.Contains(buf.String(), "msg=\"not filtered\"")
+}
+
+func TestHandler_UnknownSubsystem(t *testing.T) {
+	_, err := NewHandler(slog.NewTextHandler(&bytes.Buffer{}, nil), slog.LevelInfo, map[string]slog.Level{"web": slog.LevelDebug})
+	assert.Error(t, err)
+}
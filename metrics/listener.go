@@ -0,0 +1,82 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+)
+
+// Listener exposes metrics over HTTP, for scraping by Prometheus.
+type Listener struct {
+	Config *cfg.Config
+	DB     *sql.DB
+	Addr   string
+}
+
+func (l *Listener) handle(w http.ResponseWriter, r *http.Request) {
+	var incoming, outgoing int64
+	if err := l.DB.QueryRowContext(r.Context(), `select count(*) from inbox`).Scan(&incoming); err != nil {
+		slog.Warn("Failed to count incoming activities", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := l.DB.QueryRowContext(r.Context(), `select count(*) from outbox where sent = 0 and attempts < ?`, l.Config.MaxDeliveryAttempts).Scan(&outgoing); err != nil {
+		slog.Warn("Failed to count outgoing activities", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	Write(w)
+
+	fmt.Fprintf(w, "# HELP tootik_queue_depth Activities waiting in a queue\n# TYPE tootik_queue_depth gauge\n")
+	fmt.Fprintf(w, "tootik_queue_depth{queue=\"incoming\"} %d\n", incoming)
+	fmt.Fprintf(w, "tootik_queue_depth{queue=\"outgoing\"} %d\n", outgoing)
+}
+
+// ListenAndServe handles metrics requests.
+func (l *Listener) ListenAndServe(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", l.handle)
+
+	server := http.Server{
+		Addr:        l.Addr,
+		Handler:     mux,
+		ReadTimeout: time.Second * 30,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
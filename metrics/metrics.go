@@ -0,0 +1,209 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics collects counters and exports them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metric struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels []string
+
+	lock   sync.Mutex
+	values map[string]float64
+	counts map[string]float64
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	m *metric
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	m *metric
+}
+
+// Summary tracks the count and the sum of observed values, like durations.
+type Summary struct {
+	m *metric
+}
+
+// maxSeries is the maximum number of label value combinations per metric. Once reached, values with new combinations
+// are added up under "other", so labels with values that come from other servers can't grow without bound.
+const maxSeries = 256
+
+var (
+	registryLock sync.Mutex
+	registry     []*metric
+
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func register(name, help, typ string, labels []string) *metric {
+	m := &metric{
+		Name:   name,
+		Help:   help,
+		Type:   typ,
+		Labels: labels,
+		values: map[string]float64{},
+		counts: map[string]float64{},
+	}
+
+	registryLock.Lock()
+	registry = append(registry, m)
+	registryLock.Unlock()
+
+	return m
+}
+
+// NewCounter registers a new counter.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{register(name, help, "counter", labels)}
+}
+
+// NewGauge registers a new gauge.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{register(name, help, "gauge", labels)}
+}
+
+// NewSummary registers a new summary.
+func NewSummary(name, help string, labels ...string) *Summary {
+	return &Summary{register(name, help, "summary", labels)}
+}
+
+func (m *metric) key(values []string) string {
+	if len(values) != len(m.Labels) {
+		panic(fmt.Sprintf("%s has %d labels but got %d values", m.Name, len(m.Labels), len(values)))
+	}
+
+	var b strings.Builder
+	for i, label := range m.Labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+
+	return b.String()
+}
+
+// bucket returns the key of the "other" series if key is new and the metric has too many series.
+func (m *metric) bucket(key string) string {
+	if _, ok := m.values[key]; ok || len(m.values) < maxSeries {
+		return key
+	}
+
+	other := make([]string, len(m.Labels))
+	for i := range other {
+		other[i] = "other"
+	}
+
+	return m.key(other)
+}
+
+func (m *metric) add(v float64, values []string) {
+	key := m.key(values)
+
+	m.lock.Lock()
+	key = m.bucket(key)
+	m.values[key] += v
+	m.counts[key]++
+	m.lock.Unlock()
+}
+
+func (m *metric) set(v float64, values []string) {
+	key := m.key(values)
+
+	m.lock.Lock()
+	key = m.bucket(key)
+	m.values[key] = v
+	m.lock.Unlock()
+}
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc(values ...string) {
+	c.m.add(1, values)
+}
+
+// Add increments the counter by v.
+func (c *Counter) Add(v float64, values ...string) {
+	c.m.add(v, values)
+}
+
+// Set sets the value of the gauge.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.m.set(v, values)
+}
+
+// Observe adds a value to the summary.
+func (s *Summary) Observe(v float64, values ...string) {
+	s.m.add(v, values)
+}
+
+func (m *metric) writeTo(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.Name, m.Help, m.Name, m.Type)
+
+	for _, key := range keys {
+		if m.Type == "summary" {
+			writeSample(w, m.Name+"_sum", key, m.values[key])
+			writeSample(w, m.Name+"_count", key, m.counts[key])
+		} else {
+			writeSample(w, m.Name, key, m.values[key])
+		}
+	}
+}
+
+func writeSample(w io.Writer, name, key string, v float64) {
+	if key == "" {
+		fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(v, 'g', -1, 64))
+	} else {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, key, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+}
+
+// Write writes all registered metrics in the Prometheus text format.
+func Write(w io.Writer) {
+	registryLock.Lock()
+	metrics := slices.Clone(registry)
+	registryLock.Unlock()
+
+	for _, m := range metrics {
+		m.writeTo(w)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_Write(t *testing.T) {
+	assert := assert.New(t)
+
+	counter := NewCounter("test_requests_total", "Test requests", "domain", "result")
+	counter.Inc("a.localdomain", "success")
+	counter.Inc("a.localdomain", "success")
+	counter.Inc(`b"\.localdomain`, "failure")
+
+	gauge := NewGauge("test_active", "Test gauge")
+	gauge.Set(1)
+	gauge.Set(0)
+
+	summary := NewSummary("test_duration_seconds", "Test durations", "job")
+	summary.Observe(1.5, "gc")
+	summary.Observe(2, "gc")
+
+	var b bytes.Buffer
+	Write(&b)
+
+	assert.Contains(b.String(), "# HELP test_requests_total Test requests\n# TYPE test_requests_total counter\ntest_requests_total{domain=\"a.localdomain\",result=\"success\"} 2\ntest_requests_total{domain=\"b\\\"\\\\.localdomain\",result=\"failure\"} 1\n")
+	assert.Contains(b.String(), "# TYPE test_active gauge\ntest_active 0\n")
+	assert.Contains(b.String(), "test_duration_seconds_sum{job=\"gc\"} 3.5\ntest_duration_seconds_count{job=\"gc\"} 2\n")
+
+	assert.Panics(func() { counter.Inc("a.localdomain") })
+}
+
+func TestMetrics_MaxSeries(t *testing.T) {
+	assert := assert.New(t)
+
+	counter := NewCounter("test_bounded_total", "Test bounded counter", "domain")
+	for i := range maxSeries + 10 {
+		counter.Inc(fmt.Sprintf("%d.localdomain", i))
+	}
+	counter.Inc("0.localdomain")
+
+	var b bytes.Buffer
+	Write(&b)
+
+	assert.Contains(b.String(), "test_bounded_total{domain=\"0.localdomain\"} 2\n")
+	assert.Contains(b.String(), "test_bounded_total{domain=\"other\"} 10\n")
+	assert.NotContains(b.String(), fmt.Sprintf("test_bounded_total{domain=\"%d.localdomain\"}", maxSeries))
+}
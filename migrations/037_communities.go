@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func communities(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE communities(id STRING NOT NULL PRIMARY KEY, owner STRING NOT NULL, pinned STRING, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX communitiesowner ON communities(owner)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE bans(community STRING NOT NULL, actor STRING NOT NULL, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX banscommunityactor ON bans(community, actor)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE removals(community STRING NOT NULL, note STRING NOT NULL, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX removalscommunitynote ON removals(community, note)`)
+	return err
+}
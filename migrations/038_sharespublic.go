@@ -0,0 +1,11 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func sharespublic(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE shares ADD COLUMN public INTEGER NOT NULL DEFAULT 1`)
+	return err
+}
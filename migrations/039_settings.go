@@ -0,0 +1,11 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func settings(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE settings(actor STRING NOT NULL PRIMARY KEY, language STRING, languages STRING)`)
+	return err
+}
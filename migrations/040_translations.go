@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func translations(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE translations(note STRING NOT NULL, language STRING NOT NULL, content STRING NOT NULL, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX translationsnotelanguage ON translations(note, language)`)
+	return err
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func feedrank(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE feed ADD COLUMN rank INTEGER`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE feed SET rank = inserted`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX feedfollowerrank ON feed(follower, rank)`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN feed STRING`)
+	return err
+}
@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func filters(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE filters(id INTEGER PRIMARY KEY, actor STRING NOT NULL, phrase STRING NOT NULL, feed INTEGER NOT NULL, notifications INTEGER NOT NULL, threads INTEGER NOT NULL, action STRING NOT NULL, expires INTEGER, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE INDEX filtersactor ON filters(actor)`)
+	return err
+}
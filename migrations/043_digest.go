@@ -0,0 +1,11 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func digest(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN digest INTEGER`)
+	return err
+}
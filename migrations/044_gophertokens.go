@@ -0,0 +1,11 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func gophertokens(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE gophertokens(actor STRING NOT NULL PRIMARY KEY, hash STRING NOT NULL UNIQUE, inserted INTEGER DEFAULT (UNIXEPOCH()))`)
+	return err
+}
@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func invitations(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE invitations(code STRING NOT NULL PRIMARY KEY, inviter STRING NOT NULL, invited STRING, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE INDEX invitationsinviter ON invitations(inviter)`)
+	return err
+}
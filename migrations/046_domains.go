@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func domains(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE domains(host STRING NOT NULL PRIMARY KEY, lastsuccess INTEGER, lastfailure INTEGER, failures INTEGER NOT NULL DEFAULT 0, paused INTEGER NOT NULL DEFAULT 0)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE undelivered(host STRING NOT NULL, activity STRING NOT NULL, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX undeliveredhostactivity ON undelivered(host, activity)`)
+	return err
+}
@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func dormant(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE domains ADD COLUMN failingsince INTEGER`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `ALTER TABLE domains ADD COLUMN dormant INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
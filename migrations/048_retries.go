@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func retries(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE retries(activity STRING NOT NULL, inbox STRING NOT NULL, attempts INTEGER NOT NULL, last INTEGER NOT NULL, jitter REAL NOT NULL, notbefore INTEGER NOT NULL DEFAULT 0)`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX retriesactivityinbox ON retries(activity, inbox)`)
+	return err
+}
@@ -0,0 +1,11 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func outboxpriority(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE outbox ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func avatars(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE avatars(actor STRING NOT NULL PRIMARY KEY, hash STRING NOT NULL, url STRING NOT NULL, fetched INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX avatarshash ON avatars(hash)`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE TABLE avatarvariants(hash STRING NOT NULL, size INTEGER NOT NULL, buf BLOB NOT NULL, PRIMARY KEY(hash, size))`)
+	return err
+}
@@ -0,0 +1,11 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func headers(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE headers(name STRING NOT NULL PRIMARY KEY, buf BLOB NOT NULL)`)
+	return err
+}
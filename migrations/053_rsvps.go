@@ -0,0 +1,11 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func rsvps(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE rsvps(event STRING NOT NULL, actor STRING NOT NULL, response STRING NOT NULL, activity STRING NOT NULL, inserted INTEGER DEFAULT (UNIXEPOCH()), PRIMARY KEY(event, actor))`)
+	return err
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func preferences(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN pagesize INTEGER`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN hideshares INTEGER`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN relativetime INTEGER`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN noemoji INTEGER`)
+	return err
+}
@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func announced(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE persons ADD COLUMN announced STRING`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE persons SET announced = actor WHERE host = ?`, domain)
+	return err
+}
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func tombstones(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE tombstones(id STRING NOT NULL PRIMARY KEY, parent STRING, inserted INTEGER NOT NULL, deleted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX tombstonesparent ON tombstones(parent) WHERE parent IS NOT NULL`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE INDEX tombstonesdeleted ON tombstones(deleted)`)
+	return err
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func deliverylog(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE deliverylog(activity STRING NOT NULL, inbox STRING NOT NULL, host STRING NOT NULL, status INTEGER, attempts INTEGER NOT NULL DEFAULT 0, error STRING, updated INTEGER NOT NULL DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX deliverylogactivityinbox ON deliverylog(activity, inbox)`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE INDEX deliveryloghostupdated ON deliverylog(host, updated)`)
+	return err
+}
+
+func deliverylogDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE deliverylog`)
+	return err
+}
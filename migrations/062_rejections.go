@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func rejections(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE rejections(activity STRING, sender STRING, host STRING, client STRING, reason STRING NOT NULL, error STRING NOT NULL, inserted INTEGER NOT NULL DEFAULT (UNIXEPOCH()))`)
+	return err
+}
+
+func rejectionsDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE rejections`)
+	return err
+}
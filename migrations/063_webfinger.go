@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func webfinger(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE webfinger(resource STRING NOT NULL PRIMARY KEY, actor STRING NOT NULL, updated INTEGER NOT NULL DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE INDEX webfingeractor ON webfinger(actor)`)
+	return err
+}
+
+func webfingerDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE webfinger`)
+	return err
+}
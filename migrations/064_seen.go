@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func seen(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE seen(activity STRING NOT NULL PRIMARY KEY, inserted INTEGER NOT NULL DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE INDEX seeninserted ON seen(inserted)`)
+	return err
+}
+
+func seenDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE seen`)
+	return err
+}
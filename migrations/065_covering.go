@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func covering(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX notesinreplyto ON notes(object->>'$.inReplyTo') WHERE object->>'$.inReplyTo' IS NOT NULL`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX notesauthorinserted ON notes(author, inserted)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX notesauthor`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX followsfollowedaccepted ON follows(followed, accepted)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX followsfollowed`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX feednotefollower ON feed(note->>'$.id', follower)`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DROP INDEX feednote`)
+	return err
+}
+
+func coveringDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX feednote ON feed(note->>'$.id')`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX feednotefollower`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX followsfollowed ON follows(followed)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX followsfollowedaccepted`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX notesauthor ON notes(author)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX notesauthorinserted`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DROP INDEX notesinreplyto`)
+	return err
+}
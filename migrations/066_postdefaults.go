@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func postdefaults(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN audience STRING`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN cw STRING`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN hashtags JSON`)
+	return err
+}
+
+func postdefaultsDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE settings DROP COLUMN hashtags`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE settings DROP COLUMN cw`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `ALTER TABLE settings DROP COLUMN audience`)
+	return err
+}
@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func domainblocks(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE domainblocks(host STRING NOT NULL PRIMARY KEY, severity STRING NOT NULL, comment STRING, inserted INTEGER DEFAULT (UNIXEPOCH()))`)
+	return err
+}
+
+func domainblocksDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE domainblocks`)
+	return err
+}
@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func domainblocksfile(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE domainblocks ADD COLUMN file INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
+
+func domainblocksfileDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE domainblocks DROP COLUMN file`)
+	return err
+}
@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func replypolicy(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE settings ADD COLUMN replies STRING`)
+	return err
+}
+
+func replypolicyDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE settings DROP COLUMN replies`)
+	return err
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func rules(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE rulesacceptance(actor STRING NOT NULL, version STRING NOT NULL, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX rulesacceptanceactorversion ON rulesacceptance(actor, version)`)
+	return err
+}
+
+func rulesDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE rulesacceptance`)
+	return err
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func translationsupdated(ctx context.Context, domain string, tx *sql.Tx) error {
+	// translations are a cache, so it's safe to throw them away instead of guessing which version they belong to
+	if _, err := tx.ExecContext(ctx, `DELETE FROM translations`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX translationsnotelanguage`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE translations ADD COLUMN updated INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX translationsnotelanguageupdated ON translations(note, language, updated)`)
+	return err
+}
+
+func translationsupdatedDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM translations`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX translationsnotelanguageupdated`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE translations DROP COLUMN updated`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX translationsnotelanguage ON translations(note, language)`)
+	return err
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func followedhashtags(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE followed_hashtags(follower STRING NOT NULL, hashtag STRING COLLATE NOCASE NOT NULL, inserted INTEGER DEFAULT (UNIXEPOCH()))`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX followedhashtagsfollowerhashtag ON followed_hashtags(follower, hashtag)`)
+	return err
+}
+
+func followedhashtagsDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE followed_hashtags`)
+	return err
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func inboxforwarded(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM inbox WHERE EXISTS (SELECT 1 FROM inbox dup WHERE dup.activity->>'$.id' = inbox.activity->>'$.id' AND dup.raw->>'$.id' = inbox.raw->>'$.id' AND dup.id < inbox.id)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX inboxdedup`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX inboxdedup ON inbox(activity->>'$.id', raw->>'$.id')`)
+	return err
+}
+
+func inboxforwardedDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM inbox WHERE EXISTS (SELECT 1 FROM inbox dup WHERE dup.sender = inbox.sender AND dup.raw->>'$.id' = inbox.raw->>'$.id' AND dup.id < inbox.id)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX inboxdedup`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX inboxdedup ON inbox(sender, raw->>'$.id')`)
+	return err
+}
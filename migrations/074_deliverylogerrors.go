@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func deliverylogerrors(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE INDEX deliveryloghosterror ON deliverylog(host, updated) WHERE error IS NOT NULL`)
+	return err
+}
+
+func deliverylogerrorsDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP INDEX deliveryloghosterror`)
+	return err
+}
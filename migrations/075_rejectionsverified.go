@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func rejectionsverified(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE rejections ADD COLUMN verified INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE rejections SET verified = 1 WHERE reason = 'invalid'`)
+	return err
+}
+
+func rejectionsverifiedDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE rejections DROP COLUMN verified`)
+	return err
+}
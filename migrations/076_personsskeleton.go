@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+)
+
+// nameSkeleton is a copy of user.skeleton, at the time of this migration.
+func nameSkeleton(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch r {
+		case 'I', '1', '|':
+			b.WriteRune('l')
+		case '0':
+			b.WriteRune('o')
+		default:
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func personsskeleton(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE persons ADD COLUMN skeleton TEXT`); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, actor->>'$.preferredUsername' FROM persons WHERE host = $1 ORDER BY inserted, rowid`, domain)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// if existing users have names that look alike, only the oldest one gets a skeleton
+	skeletons := map[string]string{}
+	taken := map[string]struct{}{}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+
+		skeleton := nameSkeleton(name)
+		if _, ok := taken[skeleton]; ok {
+			continue
+		}
+
+		skeletons[id] = skeleton
+		taken[skeleton] = struct{}{}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, skeleton := range skeletons {
+		if _, err := tx.ExecContext(ctx, `UPDATE persons SET skeleton = $1 WHERE id = $2`, skeleton, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE UNIQUE INDEX personsskeleton ON persons(skeleton) WHERE skeleton IS NOT NULL`)
+	return err
+}
+
+func personsskeletonDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `DROP INDEX personsskeleton`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `ALTER TABLE persons DROP COLUMN skeleton`)
+	return err
+}
@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func notesurl(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE INDEX notesurl ON notes(object->>'$.url') WHERE object->>'$.url' IS NOT NULL`)
+	return err
+}
+
+func notesurlDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP INDEX notesurl`)
+	return err
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func deliverylogupdated(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX deliverylogupdated ON deliverylog(updated)`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `CREATE INDEX retrieslast ON retries(last)`)
+	return err
+}
+
+func deliverylogupdatedDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `DROP INDEX retrieslast`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `DROP INDEX deliverylogupdated`)
+	return err
+}
@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+func pollrefreshes(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE pollrefreshes(poll STRING NOT NULL PRIMARY KEY, attempts INTEGER NOT NULL, last INTEGER NOT NULL)`)
+	return err
+}
+
+func pollrefreshesDown(ctx context.Context, domain string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE pollrefreshes`)
+	return err
+}
@@ -25,7 +25,9 @@ EOF
 ls [0-9][0-9][0-9]_*.go | sort -n | while read f; do
 	id=${f%.go}
 	id=${id#*_}
-	echo "	{\"$id\", $id}," >> migrations.go
+	down=nil
+	grep -q "^func ${id}Down(" $f && down=${id}Down
+	echo "	{\"$id\", $id, $down}," >> migrations.go
 done
 
 echo "}" >> migrations.go
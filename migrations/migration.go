@@ -18,7 +18,10 @@ limitations under the License.
 //
 // migrations.go is generated by go generate and lists migrations to run.
 //
-// To add a new, empty migration, run add.sh.
+// To add a new, empty migration, run add.sh. To make a migration reversible, add a function named after the
+// migration, with a Down suffix, that undoes it.
+//
+// The schema version is the number of the last applied migration.
 package migrations
 
 import (
@@ -30,10 +33,17 @@ import (
 )
 
 type migration struct {
-	ID string
-	Up func(context.Context, string, *sql.Tx) error
+	ID   string
+	Up   func(context.Context, string, *sql.Tx) error
+	Down func(context.Context, string, *sql.Tx) error
 }
 
+// ErrNewerSchema is returned if the database schema is newer than the latest known migration.
+var ErrNewerSchema = errors.New("database schema is newer than this version of tootik")
+
+// ErrIrreversible is returned if a migration cannot be undone.
+var ErrIrreversible = errors.New("migration cannot be undone")
+
 //go:generate ./list.sh
 
 func applyMigration(ctx context.Context, domain string, db *sql.DB, m migration) error {
@@ -58,18 +68,127 @@ func applyMigration(ctx context.Context, domain string, db *sql.DB, m migration)
 	return nil
 }
 
+func revertMigration(ctx context.Context, domain string, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to revert %s: %w", m.ID, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.Down(ctx, domain, tx); err != nil {
+		return fmt.Errorf("failed to revert %s: %w", m.ID, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `delete from migrations where id = ?`, m.ID); err != nil {
+		return fmt.Errorf("failed to record %s: %w", m.ID, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s: %w", m.ID, err)
+	}
+
+	return nil
+}
+
+// applied returns the IDs of applied migrations and fails if any of them is unknown.
+func applied(ctx context.Context, db *sql.DB) (map[string]struct{}, error) {
+	if _, err := db.ExecContext(ctx, `create table if not exists migrations(id string not null primary key, applied integer default (unixepoch()))`); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `select id from migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	known := make(map[string]struct{}, len(migrations))
+	for _, m := range migrations {
+		known[m.ID] = struct{}{}
+	}
+
+	ids := map[string]struct{}{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+		}
+
+		if _, ok := known[id]; !ok {
+			return nil, fmt.Errorf("%w: unknown migration %s", ErrNewerSchema, id)
+		}
+
+		ids[id] = struct{}{}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	return ids, nil
+}
+
+// Latest returns the latest schema version.
+func Latest() int {
+	return len(migrations) - 1
+}
+
+// Version returns the schema version of a database, or -1 if no migration was applied.
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	ids, err := applied(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	version := -1
+	for i, m := range migrations {
+		if _, ok := ids[m.ID]; ok {
+			version = i
+		}
+	}
+
+	return version, nil
+}
+
 // Run runs all migrations.
 func Run(ctx context.Context, domain string, db *sql.DB) error {
-	if _, err := db.ExecContext(ctx, `create table if not exists migrations(id string not null primary key, applied integer default (unixepoch()))`); err != nil {
+	return Migrate(ctx, domain, db, Latest())
+}
+
+// Migrate applies or reverts migrations, until the schema version is to.
+// Each migration is applied or reverted in a separate transaction.
+func Migrate(ctx context.Context, domain string, db *sql.DB, to int) error {
+	if to < -1 || to > Latest() {
+		return fmt.Errorf("invalid schema version: %d", to)
+	}
+
+	ids, err := applied(ctx, db)
+	if err != nil {
 		return err
 	}
 
-	for _, m := range migrations {
-		var applied string
-		if err := db.QueryRowContext(ctx, `select datetime(applied, 'unixepoch') from migrations where id = ?`, m.ID).Scan(&applied); err != nil && !errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("failed to check if %s is applied: %w", m.ID, err)
-		} else if err == nil {
-			slog.Debug("Skipping migration", "id", m.ID, "applied", applied)
+	// fail before reverting anything if one of the migrations to revert is irreversible
+	for i := len(migrations) - 1; i > to; i-- {
+		if _, ok := ids[migrations[i].ID]; ok && migrations[i].Down == nil {
+			return fmt.Errorf("failed to revert %s: %w", migrations[i].ID, ErrIrreversible)
+		}
+	}
+
+	for i := len(migrations) - 1; i > to; i-- {
+		m := migrations[i]
+		if _, ok := ids[m.ID]; !ok {
+			continue
+		}
+
+		slog.Info("Reverting migration", "id", m.ID)
+		if err := revertMigration(ctx, domain, db, m); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range migrations[:to+1] {
+		if _, ok := ids[m.ID]; ok {
+			slog.Debug("Skipping migration", "id", m.ID)
 			continue
 		}
 
@@ -54,9 +54,10 @@ func Accept(ctx context.Context, domain string, followed, follower, followID str
 
 	if _, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO outbox (activity, sender) VALUES(?,?)`,
+		`INSERT INTO outbox (activity, sender, priority) VALUES(?, ?, ?)`,
 		&accept,
 		followed,
+		highPriority,
 	); err != nil {
 		return fmt.Errorf("failed to insert Accept: %w", err)
 	}
@@ -0,0 +1,78 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// ActorUpdater queues Update activities for local actors changed without one, like actors changed by migrations.
+type ActorUpdater struct {
+	Domain string
+	DB     *sql.DB
+}
+
+// Run queues an Update activity for each local actor that differs from the actor sent to followers.
+func (u *ActorUpdater) Run(ctx context.Context) error {
+	rows, err := u.DB.QueryContext(ctx, `select id from persons where host = ? and announced is not actor`, u.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to fetch changed actors: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			slog.Error("Failed to scan changed actor", "error", err)
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		slog.Info("Sending actor update", "actor", id)
+
+		if err := u.update(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *ActorUpdater) update(ctx context.Context, id string) error {
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", id, err)
+	}
+	defer tx.Rollback()
+
+	if err := UpdateActor(ctx, u.Domain, tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update %s: %w", id, err)
+	}
+
+	return nil
+}
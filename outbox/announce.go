@@ -26,7 +26,8 @@ import (
 )
 
 // Announce queues an Announce activity for delivery.
-func Announce(ctx context.Context, domain string, tx *sql.Tx, actor *ap.Actor, note *ap.Object) error {
+// If public is false, the Announce activity is addressed to followers of the actor and the author of the post.
+func Announce(ctx context.Context, domain string, tx *sql.Tx, actor *ap.Actor, note *ap.Object, public bool) error {
 	now := time.Now()
 	announceID, err := NewID(domain, "announce")
 	if err != nil {
@@ -34,11 +35,16 @@ func Announce(ctx context.Context, domain string, tx *sql.Tx, actor *ap.Actor, n
 	}
 
 	to := ap.Audience{}
-	to.Add(ap.Public)
-
 	cc := ap.Audience{}
-	to.Add(note.AttributedTo)
-	to.Add(actor.Followers)
+
+	if public {
+		to.Add(ap.Public)
+		to.Add(note.AttributedTo)
+		to.Add(actor.Followers)
+	} else {
+		to.Add(actor.Followers)
+		cc.Add(note.AttributedTo)
+	}
 
 	announce := ap.Activity{
 		Context:   "https://www.w3.org/ns/activitystreams",
@@ -53,9 +59,10 @@ func Announce(ctx context.Context, domain string, tx *sql.Tx, actor *ap.Actor, n
 
 	if _, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO shares (note, by) VALUES(?,?)`,
+		`INSERT INTO shares (note, by, public) VALUES(?,?,?)`,
 		note.ID,
 		actor.ID,
+		public,
 	); err != nil {
 		return fmt.Errorf("failed to insert share: %w", err)
 	}
@@ -64,8 +71,8 @@ func Announce(ctx context.Context, domain string, tx *sql.Tx, actor *ap.Actor, n
 		if _, err := tx.ExecContext(
 			ctx,
 			`
-			INSERT INTO feed (follower, note, author, sharer, inserted)
-			SELECT $1, $2, authors.actor, $3, UNIXEPOCH()
+			INSERT INTO feed (follower, note, author, sharer, inserted, rank)
+			SELECT $1, $2, authors.actor, $3, UNIXEPOCH(), UNIXEPOCH()
 			FROM persons authors
 			WHERE authors.id = $4
 			`,
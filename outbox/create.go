@@ -73,11 +73,17 @@ func Create(ctx context.Context, domain string, cfg *cfg.Config, db *sql.DB, pos
 		return fmt.Errorf("failed to insert note: %w", err)
 	}
 
-	if _, err = tx.ExecContext(ctx, `insert into feed(follower, note, author, inserted) values(?, ?, ?, unixepoch())`, author.ID, post, author); err != nil {
+	if _, err = tx.ExecContext(ctx, `insert into feed(follower, note, author, inserted, rank) values(?, ?, ?, unixepoch(), unixepoch())`, author.ID, post, author); err != nil {
 		return fmt.Errorf("failed to insert Create: %w", err)
 	}
 
-	if _, err = tx.ExecContext(ctx, `insert into outbox (activity, sender) values(?,?)`, string(j), author.ID); err != nil {
+	// direct messages have the same priority as follow requests
+	priority := normalPriority
+	if !post.IsPublic() && !post.To.Contains(author.Followers) && !post.CC.Contains(author.Followers) {
+		priority = highPriority
+	}
+
+	if _, err = tx.ExecContext(ctx, `insert into outbox (activity, sender, priority) values(?, ?, ?)`, string(j), author.ID, priority); err != nil {
 		return fmt.Errorf("failed to insert Create: %w", err)
 	}
 
@@ -75,6 +75,15 @@ func Delete(ctx context.Context, domain string, cfg *cfg.Config, db *sql.DB, not
 		return fmt.Errorf("failed to insert delete activity: %w", err)
 	}
 
+	// leave a tombstone, so replies to this post stay in the thread
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT OR IGNORE INTO tombstones (id, parent, inserted) SELECT id, object->>'$.inReplyTo', inserted FROM notes WHERE id = ?`,
+		note.ID,
+	); err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+
 	if _, err := tx.ExecContext(
 		ctx,
 		`DELETE FROM notes WHERE id = ?`,
@@ -0,0 +1,106 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/cfg"
+)
+
+// Expirer deletes posts by local users who chose to delete their posts after a number of days.
+type Expirer struct {
+	Domain string
+	Config *cfg.Config
+	DB     *sql.DB
+
+	failures map[string]int
+}
+
+// Run queues a Delete activity for each expired post, except poll votes, posts bookmarked by their authors and
+// posts pinned in a community. A post that fails to be deleted [cfg.Config.MaxPostExpiryAttempts] times is skipped,
+// so it doesn't block deletion of other posts.
+func (e *Expirer) Run(ctx context.Context) error {
+	if e.failures == nil {
+		e.failures = map[string]int{}
+	}
+
+	skipped := []string{}
+	for id, attempts := range e.failures {
+		if attempts >= e.Config.MaxPostExpiryAttempts {
+			skipped = append(skipped, id)
+		}
+	}
+
+	skippedJSON, err := json.Marshal(skipped)
+	if err != nil {
+		return fmt.Errorf("failed to encode skipped posts: %w", err)
+	}
+
+	rows, err := e.DB.QueryContext(
+		ctx,
+		`select notes.object from settings
+		join notes on notes.author = settings.actor
+		where
+			settings.expiry > 0 and
+			notes.host = $1 and
+			notes.inserted < unixepoch() - settings.expiry * 60 * 60 * 24 and
+			not (notes.object->>'$.name' is not null and notes.object->>'$.inReplyTo' is not null) and
+			not exists (select 1 from bookmarks where bookmarks.note = notes.id and bookmarks.by = notes.author) and
+			not exists (select 1 from communities where communities.pinned = notes.id) and
+			notes.id not in (select value from json_each($2))
+		order by notes.inserted
+		limit $3`,
+		e.Domain,
+		string(skippedJSON),
+		e.Config.PostExpiryBatchSize,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fetch expired posts: %w", err)
+	}
+
+	var notes []ap.Object
+	for rows.Next() {
+		var note ap.Object
+		if err := rows.Scan(&note); err != nil {
+			slog.Warn("Failed to scan expired post", "error", err)
+			continue
+		}
+
+		notes = append(notes, note)
+	}
+	rows.Close()
+
+	for _, note := range notes {
+		slog.Info("Deleting expired post", "post", note.ID, "author", note.AttributedTo)
+
+		if err := Delete(ctx, e.Domain, e.Config, e.DB, &note); err != nil {
+			e.failures[note.ID]++
+			slog.Warn("Failed to delete expired post", "post", note.ID, "attempts", e.failures[note.ID], "error", err)
+			continue
+		}
+
+		delete(e.failures, note.ID)
+	}
+
+	return nil
+}
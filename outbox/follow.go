@@ -70,9 +70,10 @@ func Follow(ctx context.Context, domain string, follower *ap.Actor, followed str
 	if !isLocal {
 		if _, err := tx.ExecContext(
 			ctx,
-			`INSERT INTO outbox (activity, sender) VALUES(?,?)`,
+			`INSERT INTO outbox (activity, sender, priority) VALUES(?, ?, ?)`,
 			&follow,
 			follower.ID,
+			highPriority,
 		); err != nil {
 			return fmt.Errorf("failed to insert follow activity: %w", err)
 		}
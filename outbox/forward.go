@@ -19,10 +19,12 @@ package outbox
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/cfg"
@@ -79,7 +81,7 @@ func forwardToGroup(ctx context.Context, domain string, tx *sql.Tx, note *ap.Obj
 	}
 
 	var following int
-	if err := tx.QueryRowContext(ctx, `select exists (select 1 from follows where follower = ? and followed = ? and accepted = 1)`, note.AttributedTo, group.ID).Scan(&following); err != nil {
+	if err := tx.QueryRowContext(ctx, `select exists (select 1 from follows where follower = $1 and followed = $2 and accepted = 1) and not exists (select 1 from bans where community = $2 and actor in ($1, $3))`, note.AttributedTo, group.ID, activity.Actor).Scan(&following); err != nil {
 		return true, err
 	}
 
@@ -87,23 +89,36 @@ func forwardToGroup(ctx context.Context, domain string, tx *sql.Tx, note *ap.Obj
 		return true, nil
 	}
 
+	var removed int
+	if err := tx.QueryRowContext(ctx, `select exists (select 1 from removals where community = ? and note in (?, ?))`, group.ID, firstPostID, note.ID).Scan(&removed); err != nil {
+		return true, err
+	}
+
+	if removed == 1 {
+		return true, nil
+	}
+
 	slog.Info("Forwarding post to group followers", "activity", activity.ID, "note", note.ID, "group", group.ID)
 
-	if _, err := tx.ExecContext(
-		ctx,
-		`insert into outbox(activity, sender) values(?, ?)`,
-		rawActivity,
-		group.ID,
-	); err != nil {
+	if err := announceActivity(ctx, domain, tx, &group, rawActivity); err != nil {
 		return true, err
 	}
 
-	if activity.Type != ap.Create && activity.Type != ap.Update && activity.Type != ap.Delete {
+	if activity.Type != ap.Create && activity.Type != ap.Update {
 		return true, nil
 	}
 
-	// if this is a new post and we're passing the Create activity to followers, also share the post
-	if err := Announce(ctx, domain, tx, &group, note); err != nil {
+	var shared int
+	if err := tx.QueryRowContext(ctx, `select exists (select 1 from shares where note = ? and by = ?)`, note.ID, group.ID).Scan(&shared); err != nil {
+		return true, err
+	}
+
+	if shared == 1 {
+		return true, nil
+	}
+
+	// servers that don't understand announced activities still see new posts in the group, as shared posts
+	if err := Announce(ctx, domain, tx, &group, note, note.IsPublic()); err != nil {
 		return true, err
 	}
 
@@ -119,8 +134,94 @@ func forwardToGroup(ctx context.Context, domain string, tx *sql.Tx, note *ap.Obj
 	return true, nil
 }
 
+// announceActivity wraps an activity with an Announce activity by a group, like FEP-1b12 says.
+func announceActivity(ctx context.Context, domain string, tx *sql.Tx, group *ap.Actor, rawActivity string) error {
+	announceID, err := NewID(domain, "announce")
+	if err != nil {
+		return err
+	}
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	cc := ap.Audience{}
+	cc.Add(group.Followers)
+
+	announce := ap.Activity{
+		Context:   "https://www.w3.org/ns/activitystreams",
+		ID:        announceID,
+		Type:      ap.Announce,
+		Actor:     group.ID,
+		Published: &ap.Time{Time: time.Now()},
+		To:        to,
+		CC:        cc,
+		Object:    json.RawMessage(rawActivity),
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO outbox (activity, sender) VALUES(?,?)`,
+		&announce,
+		group.ID,
+	); err != nil {
+		return fmt.Errorf("failed to insert announce activity: %w", err)
+	}
+
+	return nil
+}
+
+// ForwardUndo forwards an Undo activity to followers of a group, if this group has forwarded the undone activity.
+func ForwardUndo(ctx context.Context, domain string, db *sql.DB, activity *ap.Activity, rawActivity string) error {
+	inner, ok := activity.Object.(*ap.Activity)
+	if !ok || inner.ID == "" {
+		return nil
+	}
+
+	rows, err := db.QueryContext(
+		ctx,
+		`select distinct persons.actor from outbox join persons on persons.id = outbox.sender where outbox.activity->>'$.type' = 'Announce' and outbox.activity->>'$.object.id' = ? and persons.host = ? and persons.actor->>'$.type' = 'Group'`,
+		inner.ID,
+		domain,
+	)
+	if err != nil {
+		return err
+	}
+
+	var groups []ap.Actor
+	for rows.Next() {
+		var group ap.Actor
+		if err := rows.Scan(&group); err != nil {
+			rows.Close()
+			return err
+		}
+		groups = append(groups, group)
+	}
+	rows.Close()
+
+	if len(groups) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, group := range groups {
+		slog.Info("Forwarding undo to group followers", "activity", activity.ID, "undone", inner.ID, "group", group.ID)
+
+		if err := announceActivity(ctx, domain, tx, &group, rawActivity); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 // ForwardActivity forwards an activity if needed.
 // A reply by B in a thread started by A is forwarded to all followers of A.
+// A reply to a post by A, addressed to followers of A, is forwarded to all followers of A.
 // A post by a follower of a local group, which mentions the group or replies to a post in the group, is forwarded to followers of the group.
 func ForwardActivity(ctx context.Context, domain string, cfg *cfg.Config, tx *sql.Tx, note *ap.Object, activity *ap.Activity, rawActivity string) error {
 	// poll votes don't need to be forwarded
@@ -161,7 +262,7 @@ func ForwardActivity(ctx context.Context, domain string, cfg *cfg.Config, tx *sq
 	prefix := fmt.Sprintf("https://%s/", domain)
 	if !strings.HasPrefix(threadStarterID, prefix) {
 		slog.Debug("Thread starter is federated", "activity", activity.ID, "note", note.ID)
-		return nil
+		return forwardToParentAuthorFollowers(ctx, domain, tx, note, activity, rawActivity, threadStarterID)
 	}
 
 	var shouldForward int
@@ -170,7 +271,7 @@ func ForwardActivity(ctx context.Context, domain string, cfg *cfg.Config, tx *sq
 	}
 	if shouldForward == 0 {
 		slog.Debug("Activity does not need to be forwarded", "activity", activity.ID, "note", note.ID)
-		return nil
+		return forwardToParentAuthorFollowers(ctx, domain, tx, note, activity, rawActivity, threadStarterID)
 	}
 
 	if _, err := tx.ExecContext(
@@ -183,5 +284,43 @@ func ForwardActivity(ctx context.Context, domain string, cfg *cfg.Config, tx *sq
 	}
 
 	slog.Info("Forwarding activity to followers of thread starter", "activity", activity.ID, "note", note.ID, "thread", firstPostID, "starter", threadStarterID)
+	return forwardToParentAuthorFollowers(ctx, domain, tx, note, activity, rawActivity, threadStarterID)
+}
+
+// forwardToParentAuthorFollowers forwards a reply to a local post, addressed to followers of the post author, to these followers.
+// Unlike forwarding to followers of the thread starter, this works in threads started by federated users.
+func forwardToParentAuthorFollowers(ctx context.Context, domain string, tx *sql.Tx, note *ap.Object, activity *ap.Activity, rawActivity, threadStarterID string) error {
+	var parentAuthorID, followers string
+	if err := tx.QueryRowContext(
+		ctx,
+		`select persons.id, persons.actor->>'$.followers' from notes join persons on persons.id = notes.author where notes.id = ? and persons.host = ?`,
+		note.InReplyTo,
+		domain,
+	).Scan(&parentAuthorID, &followers); errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch parent post author: %w", err)
+	}
+
+	// followers of the thread starter receive the reply anyway, and the author's followers receive it from the author
+	if parentAuthorID == threadStarterID || parentAuthorID == note.AttributedTo {
+		return nil
+	}
+
+	if !note.To.Contains(followers) && !note.CC.Contains(followers) {
+		slog.Debug("Reply is not addressed to followers of parent post author", "activity", activity.ID, "note", note.ID)
+		return nil
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT OR IGNORE INTO outbox (activity, sender) VALUES(?,?)`,
+		rawActivity,
+		parentAuthorID,
+	); err != nil {
+		return err
+	}
+
+	slog.Info("Forwarding activity to followers of parent post author", "activity", activity.ID, "note", note.ID, "parent", note.InReplyTo, "author", parentAuthorID)
 	return nil
 }
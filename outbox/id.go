@@ -17,6 +17,9 @@ package outbox
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/dimkr/tootik/cfg"
 
 	"github.com/google/uuid"
 )
@@ -30,3 +33,8 @@ func NewID(domain, prefix string) (string, error) {
 
 	return fmt.Sprintf("https://%s/%s/%s", domain, prefix, u.String()), nil
 }
+
+// PostURL returns the canonical URL of a local post.
+func PostURL(domain string, cfg *cfg.Config, id string) string {
+	return strings.NewReplacer("{domain}", domain, "{post}", strings.TrimPrefix(id, "https://")).Replace(cfg.PostURL)
+}
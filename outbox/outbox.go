@@ -18,3 +18,10 @@ limitations under the License.
 //
 // Outgoing activities are queued and delivered by [fed.Queue].
 package outbox
+
+// Activities are delivered by priority, so small, interactive activities like follow requests don't wait
+// behind wide deliveries of public posts.
+const (
+	normalPriority = 0
+	highPriority   = 1
+)
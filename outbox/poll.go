@@ -37,63 +37,83 @@ type pollResult struct {
 	PollID, Option string
 }
 
+type pollVote struct {
+	PollID, Voter string
+}
+
 func (p *Poller) Run(ctx context.Context) error {
-	rows, err := p.DB.QueryContext(ctx, `select poll, option, count(*) from (select polls.id as poll, votes.object->>'$.name' as option, votes.author as voter from notes polls left join notes votes on votes.object->>'$.inReplyTo' = polls.id where polls.object->>'$.type' = 'Question' and polls.id like $1 and polls.object->>'$.closed' is null and (votes.object->>'$.name' is not null or votes.id is null) group by poll, option, voter) group by poll, option`, fmt.Sprintf("https://%s/%%", p.Domain))
+	rows, err := p.DB.QueryContext(ctx, `select polls.id, votes.object->>'$.name', votes.author from notes polls left join notes votes on votes.object->>'$.inReplyTo' = polls.id and votes.object->>'$.name' is not null where polls.object->>'$.type' = 'Question' and polls.id like $1 and polls.object->>'$.closed' is null order by votes.inserted, votes.rowid`, fmt.Sprintf("https://%s/%%", p.Domain))
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
 	votes := map[pollResult]int64{}
+	voters := map[pollVote]struct{}{}
+	votersCount := map[string]int64{}
+	counted := map[pollVote]map[string]struct{}{}
 	polls := map[string]*ap.Object{}
 
 	for rows.Next() {
 		var pollID string
-		var option sql.NullString
-		var count int64
-		if err := rows.Scan(&pollID, &option, &count); err != nil {
+		var option, voter sql.NullString
+		if err := rows.Scan(&pollID, &option, &voter); err != nil {
 			slog.Warn("Failed to scan poll result", "error", err)
 			continue
 		}
 
-		if _, ok := polls[pollID]; ok {
-			if option.Valid {
-				votes[pollResult{PollID: pollID, Option: option.String}] = count
+		poll, ok := polls[pollID]
+		if !ok {
+			var obj ap.Object
+			if err := p.DB.QueryRowContext(ctx, "select object from notes where id = ?", pollID).Scan(&obj); err != nil {
+				slog.Warn("Failed to fetch poll", "poll", pollID, "error", err)
+				continue
 			}
+
+			poll = &obj
+			polls[pollID] = poll
+		}
+
+		if !option.Valid || !voter.Valid {
 			continue
 		}
 
-		var obj ap.Object
-		if err := p.DB.QueryRowContext(ctx, "select object from notes where id = ?", pollID).Scan(&obj); err != nil {
-			slog.Warn("Failed to fetch poll", "poll", pollID, "error", err)
+		vote := pollVote{PollID: pollID, Voter: voter.String}
+
+		if _, ok := voters[vote]; !ok {
+			voters[vote] = struct{}{}
+			votersCount[pollID]++
+			counted[vote] = map[string]struct{}{}
+		} else if len(poll.OneOf) > 0 {
+			// in a single choice poll, only the first vote counts
 			continue
 		}
 
-		polls[pollID] = &obj
-		if option.Valid {
-			votes[pollResult{PollID: pollID, Option: option.String}] = count
+		// each voter can vote for each option once
+		if _, ok := counted[vote][option.String]; ok {
+			continue
 		}
+		counted[vote][option.String] = struct{}{}
+
+		votes[pollResult{PollID: pollID, Option: option.String}]++
 	}
 	rows.Close()
 
 	now := ap.Time{Time: time.Now()}
 
 	for _, poll := range polls {
-		changed := false
-
-		poll.VotersCount = 0
+		changed := poll.VotersCount != votersCount[poll.ID]
+		poll.VotersCount = votersCount[poll.ID]
 
-		for i := range poll.AnyOf {
-			count, ok := votes[pollResult{PollID: poll.ID, Option: poll.AnyOf[i].Name}]
-			if !ok {
-				changed = changed || poll.AnyOf[i].Replies.TotalItems > 0
-				poll.AnyOf[i].Replies.TotalItems = 0
-				continue
-			}
+		options := poll.AnyOf
+		if len(poll.OneOf) > 0 {
+			options = poll.OneOf
+		}
 
-			changed = changed || poll.AnyOf[i].Replies.TotalItems != count
-			poll.AnyOf[i].Replies.TotalItems = count
-			poll.VotersCount += count
+		for i := range options {
+			count := votes[pollResult{PollID: poll.ID, Option: options[i].Name}]
+			changed = changed || options[i].Replies.TotalItems != count
+			options[i].Replies.TotalItems = count
 		}
 
 		if poll.EndTime == nil || now.After(poll.EndTime.Time) {
@@ -0,0 +1,120 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dimkr/tootik/ap"
+)
+
+// Remove queues a Remove activity for delivery, when a post is removed from a community.
+// Once removed, activities about this post or replies to it are no longer forwarded to followers of the community.
+// If the community has shared this post, the Announce activity is undone.
+func Remove(ctx context.Context, domain string, tx *sql.Tx, group *ap.Actor, note *ap.Object) error {
+	removeID, err := NewID(domain, "remove")
+	if err != nil {
+		return err
+	}
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	cc := ap.Audience{}
+	cc.Add(group.Followers)
+	cc.Add(note.AttributedTo)
+
+	remove := ap.Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		ID:      removeID,
+		Type:    ap.Remove,
+		Actor:   group.ID,
+		Object:  note.ID,
+		Target:  group.ID,
+		To:      to,
+		CC:      cc,
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT OR IGNORE INTO removals (community, note) VALUES(?,?)`,
+		group.ID,
+		note.ID,
+	); err != nil {
+		return fmt.Errorf("failed to record removal: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO outbox (activity, sender) VALUES(?,?)`,
+		&remove,
+		group.ID,
+	); err != nil {
+		return fmt.Errorf("failed to insert remove activity: %w", err)
+	}
+
+	var announce ap.Activity
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT activity FROM outbox WHERE activity->>'$.type' = 'Announce' AND activity->>'$.actor' = ? AND activity->>'$.object' = ? AND sender = ?`,
+		group.ID,
+		note.ID,
+		group.ID,
+	).Scan(&announce); errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch announce activity: %w", err)
+	}
+
+	undoID, err := NewID(domain, "undo")
+	if err != nil {
+		return err
+	}
+
+	undo := ap.Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		ID:      undoID,
+		Type:    ap.Undo,
+		Actor:   group.ID,
+		To:      announce.To,
+		CC:      announce.CC,
+		Object:  &announce,
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM shares WHERE note = ? AND by = ?`,
+		note.ID,
+		group.ID,
+	); err != nil {
+		return fmt.Errorf("failed to remove share: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO outbox (activity, sender) VALUES(?,?)`,
+		&undo,
+		group.ID,
+	); err != nil {
+		return fmt.Errorf("failed to insert undo activity: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dimkr/tootik/ap"
+)
+
+// RSVP queues an Accept, TentativeAccept or Reject activity for delivery to the organizer of an event.
+func RSVP(ctx context.Context, domain string, actor *ap.Actor, event *ap.Object, response ap.ActivityType, db *sql.DB) error {
+	if response != ap.Accept && response != ap.TentativeAccept && response != ap.Reject {
+		return fmt.Errorf("invalid response: %s", response)
+	}
+
+	if event.Type != ap.Event {
+		return fmt.Errorf("%s is not an event", event.ID)
+	}
+
+	id, err := NewID(domain, "rsvp")
+	if err != nil {
+		return err
+	}
+
+	to := ap.Audience{}
+	to.Add(event.AttributedTo)
+
+	rsvp := ap.Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		ID:      id,
+		Type:    response,
+		Actor:   actor.ID,
+		Object:  event.ID,
+		To:      to,
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO outbox (activity, sender, priority) VALUES(?, ?, ?)`,
+		&rsvp,
+		actor.ID,
+		highPriority,
+	); err != nil {
+		return fmt.Errorf("failed to insert %s activity: %w", response, err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO rsvps (event, actor, response, activity) VALUES($1, $2, $3, $4) ON CONFLICT(event, actor) DO UPDATE SET response = $3, activity = $4, inserted = UNIXEPOCH()`,
+		event.ID,
+		actor.ID,
+		response,
+		id,
+	); err != nil {
+		return fmt.Errorf("failed to save response to %s: %w", event.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s failed to respond to %s: %w", actor.ID, event.ID, err)
+	}
+
+	return nil
+}
@@ -37,15 +37,12 @@ func Undo(ctx context.Context, domain string, db *sql.DB, activity *ap.Activity)
 		return err
 	}
 
-	to := activity.To
-	to.Add(ap.Public)
-
 	undo := ap.Activity{
 		Context: "https://www.w3.org/ns/activitystreams",
 		ID:      id,
 		Type:    ap.Undo,
 		Actor:   activity.Actor,
-		To:      to,
+		To:      activity.To,
 		CC:      activity.CC,
 		Object:  activity,
 	}
@@ -76,9 +73,10 @@ func Undo(ctx context.Context, domain string, db *sql.DB, activity *ap.Activity)
 
 	if _, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO outbox (activity, sender) VALUES(?,?)`,
+		`INSERT INTO outbox (activity, sender, priority) VALUES(?, ?, ?)`,
 		&undo,
 		activity.Actor,
+		highPriority,
 	); err != nil {
 		return fmt.Errorf("failed to insert undo activity: %w", err)
 	}
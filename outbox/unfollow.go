@@ -70,9 +70,10 @@ func Unfollow(ctx context.Context, domain string, db *sql.DB, follower, followed
 
 	if _, err := tx.ExecContext(
 		ctx,
-		`INSERT INTO outbox (activity, sender) VALUES(?,?)`,
+		`INSERT INTO outbox (activity, sender, priority) VALUES(?, ?, ?)`,
 		&unfollow,
 		follower,
+		highPriority,
 	); err != nil {
 		return fmt.Errorf("failed to insert undo for %s: %w", followID, err)
 	}
@@ -143,5 +143,9 @@ func UpdateActor(ctx context.Context, domain string, tx *sql.Tx, actorID string)
 		return fmt.Errorf("failed to insert update activity: %w", err)
 	}
 
+	if _, err := tx.ExecContext(ctx, `UPDATE persons SET announced = actor WHERE id = ?`, actorID); err != nil {
+		return fmt.Errorf("failed to update %s: %w", actorID, err)
+	}
+
 	return nil
 }
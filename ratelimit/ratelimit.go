@@ -0,0 +1,185 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ratelimit limits the number and rate of connections.
+package ratelimit
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/dimkr/tootik/cfg"
+)
+
+// Limiter limits the number of concurrent connections, the number of concurrent connections per IP address and
+// the rate of new connections per IP address, using a token bucket.
+type Limiter struct {
+	MaxConnections      int
+	MaxConnectionsPerIP int
+	MaxReserved         int
+	Interval            time.Duration
+	Burst               int
+
+	lock     sync.Mutex
+	total    int
+	reserved int
+	clients  map[netip.Addr]*client
+	pruned   time.Time
+}
+
+type client struct {
+	active  int
+	tokens  float64
+	updated time.Time
+}
+
+const pruneInterval = time.Minute
+
+var (
+	ErrTooManyConnections = errors.New("too many connections")
+	ErrTooManyClientConns = errors.New("too many connections from address")
+	ErrRateLimited        = errors.New("too many new connections from address")
+)
+
+// New returns a new [Limiter] configured according to cfg.
+func New(cfg *cfg.Config) *Limiter {
+	return &Limiter{
+		MaxConnections:      cfg.MaxConnections,
+		MaxConnectionsPerIP: cfg.MaxConnectionsPerIP,
+		MaxReserved:         cfg.MaxSlowDownConnections,
+		Interval:            cfg.ConnectionInterval,
+		Burst:               cfg.ConnectionBurst,
+	}
+}
+
+func clientAddr(addr net.Addr) netip.Addr {
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return ap.Addr().Unmap()
+}
+
+// refill adds tokens that have accumulated since the last update.
+func (l *Limiter) refill(c *client, now time.Time) {
+	c.tokens = min(float64(l.Burst), c.tokens+float64(now.Sub(c.updated))/float64(l.Interval))
+	c.updated = now
+}
+
+// prune forgets idle clients with a full bucket.
+func (l *Limiter) prune(now time.Time) {
+	for addr, c := range l.clients {
+		if c.active > 0 {
+			continue
+		}
+
+		l.refill(c, now)
+		if c.tokens >= float64(l.Burst) {
+			delete(l.clients, addr)
+		}
+	}
+
+	l.pruned = now
+}
+
+// Acquire checks if a new connection is allowed and reserves a slot for it.
+// If successful, the caller must call [Limiter.Release] when the connection is closed.
+func (l *Limiter) Acquire(addr net.Addr) error {
+	ip := clientAddr(addr)
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.clients == nil {
+		l.clients = map[netip.Addr]*client{}
+	}
+
+	if now.Sub(l.pruned) >= pruneInterval {
+		l.prune(now)
+	}
+
+	if l.total >= l.MaxConnections {
+		return ErrTooManyConnections
+	}
+
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &client{tokens: float64(l.Burst), updated: now}
+		l.clients[ip] = c
+	} else {
+		l.refill(c, now)
+	}
+
+	if c.active >= l.MaxConnectionsPerIP {
+		return ErrTooManyClientConns
+	}
+
+	if c.tokens < 1 {
+		return ErrRateLimited
+	}
+
+	c.tokens--
+	c.active++
+	l.total++
+
+	return nil
+}
+
+// Release frees the slot reserved by [Limiter.Acquire].
+func (l *Limiter) Release(addr net.Addr) {
+	ip := clientAddr(addr)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if c, ok := l.clients[ip]; ok && c.active > 0 {
+		c.active--
+		l.total--
+	}
+}
+
+// Reserve reserves a slot for a connection that exceeds a per-address limit but still needs to be handled, without
+// counting it against the address. It returns false if there are too many connections, or too many reserved
+// connections.
+// If successful, the caller must call [Limiter.Unreserve] when the connection is closed.
+func (l *Limiter) Reserve() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.total >= l.MaxConnections || l.reserved >= l.MaxReserved {
+		return false
+	}
+
+	l.total++
+	l.reserved++
+	return true
+}
+
+// Unreserve frees the slot reserved by [Limiter.Reserve].
+func (l *Limiter) Unreserve() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.reserved > 0 {
+		l.reserved--
+		l.total--
+	}
+}
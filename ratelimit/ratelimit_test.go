@@ -0,0 +1,152 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_MaxConnectionsPerIP(t *testing.T) {
+	assert := assert.New(t)
+
+	l := Limiter{MaxConnections: 10, MaxConnectionsPerIP: 2, Interval: time.Millisecond, Burst: 10}
+
+	a := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1000}
+	a2 := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1001}
+	b := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1000}
+
+	assert.NoError(l.Acquire(a))
+	assert.NoError(l.Acquire(a2))
+	assert.ErrorIs(l.Acquire(a), ErrTooManyClientConns)
+	assert.NoError(l.Acquire(b))
+
+	l.Release(a)
+	assert.NoError(l.Acquire(a2))
+}
+
+func TestLimiter_MaxConnections(t *testing.T) {
+	assert := assert.New(t)
+
+	l := Limiter{MaxConnections: 2, MaxConnectionsPerIP: 2, Interval: time.Millisecond, Burst: 10}
+
+	a := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1000}
+	b := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1000}
+	c := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1000}
+
+	assert.NoError(l.Acquire(a))
+	assert.NoError(l.Acquire(b))
+	assert.ErrorIs(l.Acquire(c), ErrTooManyConnections)
+
+	l.Release(b)
+	assert.NoError(l.Acquire(c))
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	assert := assert.New(t)
+
+	l := Limiter{MaxConnections: 10, MaxConnectionsPerIP: 10, Interval: time.Hour, Burst: 3}
+
+	a := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1000}
+	mapped := &net.TCPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 1000}
+	b := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1000}
+
+	for range 3 {
+		assert.NoError(l.Acquire(a))
+		l.Release(a)
+	}
+
+	assert.ErrorIs(l.Acquire(a), ErrRateLimited)
+	assert.ErrorIs(l.Acquire(mapped), ErrRateLimited)
+	assert.NoError(l.Acquire(b))
+
+	// one token is added after an interval
+	l.clients[clientAddr(a)].updated = time.Now().Add(-time.Hour)
+	assert.NoError(l.Acquire(a))
+	assert.ErrorIs(l.Acquire(a), ErrRateLimited)
+}
+
+func TestLimiter_Prune(t *testing.T) {
+	assert := assert.New(t)
+
+	l := Limiter{MaxConnections: 10, MaxConnectionsPerIP: 10, Interval: time.Millisecond, Burst: 3}
+
+	a := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1000}
+	b := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1000}
+
+	assert.NoError(l.Acquire(a))
+	assert.NoError(l.Acquire(b))
+	l.Release(a)
+
+	time.Sleep(time.Millisecond * 10)
+	l.pruned = time.Time{}
+
+	c := &net.TCPAddr{IP: net.ParseIP("192.0.2.3"), Port: 1000}
+	assert.NoError(l.Acquire(c))
+
+	// a is idle and its bucket is full again, while b is still connected
+	assert.NotContains(l.clients, clientAddr(a))
+	assert.Contains(l.clients, clientAddr(b))
+	assert.Contains(l.clients, clientAddr(c))
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	assert := assert.New(t)
+
+	l := Limiter{MaxConnections: 2, MaxConnectionsPerIP: 1, MaxReserved: 2, Interval: time.Millisecond, Burst: 10}
+
+	a := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1000}
+	b := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1000}
+
+	assert.NoError(l.Acquire(a))
+	assert.ErrorIs(l.Acquire(a), ErrTooManyClientConns)
+
+	// a rejected connection still occupies a slot until it's closed
+	assert.True(l.Reserve())
+	assert.False(l.Reserve())
+	assert.ErrorIs(l.Acquire(b), ErrTooManyConnections)
+
+	l.Unreserve()
+	assert.NoError(l.Acquire(b))
+}
+
+func TestLimiter_MaxReserved(t *testing.T) {
+	assert := assert.New(t)
+
+	l := Limiter{MaxConnections: 10, MaxConnectionsPerIP: 1, MaxReserved: 2, Interval: time.Millisecond, Burst: 10}
+
+	a := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1000}
+	b := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1000}
+
+	assert.NoError(l.Acquire(a))
+
+	// rejected connections can't take more than a few slots
+	assert.True(l.Reserve())
+	assert.True(l.Reserve())
+	assert.False(l.Reserve())
+	assert.NoError(l.Acquire(b))
+
+	l.Unreserve()
+	assert.True(l.Reserve())
+
+	// releasing a regular connection doesn't free a reserved slot
+	l.Release(b)
+	assert.False(l.Reserve())
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/front/user"
+	"github.com/dimkr/tootik/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActorUpdater_Changed(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	updater := outbox.ActorUpdater{
+		Domain: domain,
+		DB:     server.db,
+	}
+
+	countUpdates := func() int {
+		var count int
+		assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Update' and activity->>'$.object' = ?`, server.Alice.ID).Scan(&count))
+		return count
+	}
+
+	assert.NoError(updater.Run(context.Background()))
+	assert.Equal(0, countUpdates())
+
+	_, err := server.db.Exec(`update persons set actor = json_set(actor, '$.name', 'Alice') where id = ?`, server.Alice.ID)
+	assert.NoError(err)
+
+	assert.NoError(updater.Run(context.Background()))
+	assert.Equal(1, countUpdates())
+
+	assert.NoError(updater.Run(context.Background()))
+	assert.Equal(1, countUpdates())
+}
+
+func TestActorUpdater_AlreadySent(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+
+	summary := server.Handle("/users/bio?Hello%20world", server.Alice)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), summary)
+
+	updater := outbox.ActorUpdater{
+		Domain: domain,
+		DB:     server.db,
+	}
+	assert.NoError(updater.Run(context.Background()))
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Update' and activity->>'$.object' = ?`, server.Alice.ID).Scan(&count))
+	assert.Equal(1, count)
+}
+
+func TestActorUpdater_RegisteredWithCertificate(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	erinKeyPair, err := tls.X509KeyPair([]byte(erinCert), []byte(erinKey))
+	assert.NoError(err)
+
+	cert, err := x509.ParseCertificate(erinKeyPair.Certificate[0])
+	assert.NoError(err)
+
+	erin, _, err := user.Create(context.Background(), domain, server.cfg, server.db, "erin", ap.Person, cert)
+	assert.NoError(err)
+
+	updater := outbox.ActorUpdater{
+		Domain: domain,
+		DB:     server.db,
+	}
+	assert.NoError(updater.Run(context.Background()))
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Update' and activity->>'$.object' = ?`, erin.ID).Scan(&count))
+	assert.Equal(0, count)
+}
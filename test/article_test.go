@@ -0,0 +1,101 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *server) receiveArticle(assert *assert.Assertions, content string) {
+	_, err := s.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	article := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/articles/1","type":"Article","attributedTo":"https://127.0.0.1/user/dan","name":"My &amp; article","content":"` + content + `","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`
+
+	s.Receive("https://127.0.0.1/user/dan", article)
+}
+
+func TestArticle_View(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.receiveArticle(assert, `<p>Intro</p><ul><li>a</li><li>b</li></ul><blockquote><p>quote</p></blockquote><pre>x := 1\n\ny := 2</pre><p>Outro</p>`)
+
+	view := server.Handle("/users/view/127.0.0.1/articles/1", server.Alice)
+	assert.Contains(view, "# 📣 Article by dan\n")
+	assert.Contains(view, "## My & article\n\nIntro\n\n* a\n* b\n\n> quote\n\n```Preformatted text\nx := 1\n\ny := 2\n```\n\nOutro\n")
+	assert.NotContains(view, "Next page")
+
+	outbox := server.Handle("/users/outbox/127.0.0.1/user/dan", server.Alice)
+	assert.Contains(outbox, "> My & article\n")
+	assert.NotContains(outbox, "Intro")
+}
+
+func TestArticle_Pages(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.ArticlePageMaxRunes = 10
+
+	server.receiveArticle(assert, `<p>First paragraph</p><p>Second paragraph</p><p>Third paragraph</p>`)
+
+	view := server.Handle("/users/view/127.0.0.1/articles/1", server.Alice)
+	assert.Contains(view, "## My & article\n\nFirst paragraph\n")
+	assert.NotContains(view, "Second paragraph")
+	assert.Contains(view, "=> /users/read/127.0.0.1/articles/1?2 📖 Next page (2/3)\n")
+
+	second := server.Handle("/users/read/127.0.0.1/articles/1?2", server.Alice)
+	assert.Contains(second, "# 📖 My & article (2/3)\n")
+	assert.Contains(second, "=> /users/outbox/127.0.0.1/user/dan dan\n")
+	assert.Contains(second, "Second paragraph\n")
+	assert.NotContains(second, "First paragraph")
+	assert.Contains(second, "=> /users/read/127.0.0.1/articles/1?1 Previous page (1/3)\n")
+	assert.Contains(second, "=> /users/read/127.0.0.1/articles/1?3 Next page (3/3)\n")
+	assert.Contains(second, "=> /users/view/127.0.0.1/articles/1 💬 Back to post\n")
+
+	third := server.Handle("/read/127.0.0.1/articles/1?3", nil)
+	assert.Contains(third, "Third paragraph\n")
+	assert.NotContains(third, "Next page")
+	assert.Contains(third, "=> /read/127.0.0.1/articles/1?2 Previous page (2/3)\n")
+
+	assert.Equal("40 Invalid page\r\n", server.Handle("/users/read/127.0.0.1/articles/1?4", server.Alice))
+	assert.True(strings.HasPrefix(server.Handle("/users/read/127.0.0.1/articles/1", server.Alice), "20 text/gemini\r\n"))
+}
+
+func TestArticle_NotArticle(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+	assert.Equal("40 Post is not an article\r\n", server.Handle("/users/read/"+id, server.Alice))
+}
@@ -17,17 +17,21 @@ limitations under the License.
 package test
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/fed"
 	"github.com/stretchr/testify/assert"
 )
 
 var avatar = []byte("\x47\x49\x46\x38\x37\x61\x10\x00\x10\x00\xf0\x00\x00\x00\x00\x00\xff\x00\x00\x2c\x00\x00\x00\x00\x10\x00\x10\x00\x00\x02\x1e\x8c\x8f\xa9\xab\xe0\x0f\x1d\x8a\x14\xcc\x0a\x2f\x96\x67\x3f\xbd\x81\x98\x58\x91\x94\x19\xa1\x59\xe7\x59\xcc\x0b\x2b\x05\x00\x3b")
 
+var webpAvatar = []byte("\x52\x49\x46\x46\xb2\x01\x00\x00\x57\x45\x42\x50\x56\x50\x38\x4c\xa5\x01\x00\x00\x2f\x4a\xc0\x18\x00\x0f\x30\xff\xf3\x3f\xff\xf3\x1f\x78\x90\x24\x6d\x7b\xda\x48\x6e\xe6\xf1\x0d\xc6\x7d\x84\x81\x25\xe9\x30\x43\x3b\x66\xfc\x87\x19\x96\x0c\x27\x99\x62\x26\x9f\x60\x4a\xed\xa1\x66\x06\xd9\xd5\x8a\xbe\xaa\xff\xff\x15\x3a\x41\x44\xff\x19\xb8\x6d\xa4\xc8\xbb\xc7\x38\xf0\x0a\xc4\xa3\xaf\x81\xdf\x31\x4a\x62\x59\xf7\xa6\xa0\xa5\x48\x22\x97\xd1\xb7\xa0\x15\x30\x17\x14\xe2\xd7\x1d\x2c\x85\xf1\xc0\x8d\x71\x91\x06\xe0\xec\xb0\xb8\x0e\x0a\x55\x57\xc9\x0a\x20\x2b\x53\xb1\x80\x80\x92\x3c\xfa\x52\x4f\xfc\xe2\x8c\x4f\xf7\xc1\x02\x37\xaf\x83\x57\x18\x07\xb6\x15\x90\x5b\x96\x81\xad\xa5\xc8\xf8\xb9\x23\x41\xc5\xcb\x96\x13\xa5\x62\x07\x83\x44\x59\xa6\x49\xe2\x45\x55\xbd\xa1\xd1\xc0\x28\xec\x28\xb1\x6b\x8e\x19\xdc\x48\xca\x7d\x8e\xbd\xa0\x83\xbe\x18\x3f\xc1\xee\x93\xc1\xa7\x4f\x04\xf6\xea\x05\x5e\x7c\x32\xc2\xe6\x30\x9f\x32\x66\x73\x96\x93\xc4\x91\xcf\x83\x7e\x42\x8c\x8f\x2f\xe3\x27\x6a\x6c\xcc\xbd\xc1\x35\xac\x73\x44\xaf\xdd\x45\xf4\x62\x99\x3d\x55\x1c\x4b\xdc\x3b\x3e\x18\x47\xdf\xab\x2e\x07\xda\x8f\x79\x86\xff\xa0\xb9\x3a\x72\xe4\xe2\x27\x4c\x0e\x2b\x79\xb9\x87\x57\x0a\x8d\x6e\x84\x55\x90\x98\x30\xae\xdd\xc5\xc2\x82\x05\xd8\x0f\xf4\x79\x0a\xaf\xd8\x24\x00\xed\x8f\xf0\x62\x99\x19\x65\x5d\x20\x06\xad\x41\xaf\xb5\x20\x3a\x6d\xea\xac\xa8\xad\x5c\x1d\xcb\x4d\x71\x75\x6f\x09\x91\xf9\x3a\xc6\x31\x17\x99\x54\x10\xf8\x74\x1d\x16\xbe\x8e\x2a\x12\x0d\xdf\x87\x57\x5a\xad\x3e\xd2\xaa\xfa\x10\x94\x82\x79\xe5\x4b\x1f\xdf\xa0\xbc\x64\xcb\xca\xa3\x3a\xe4\xf4\x38\xe2\x28\x73\x95\x35\xf1\x40\xa8\xca\x6c\x0b\xec\x85\x78\x22\xaf\xb2\xe2\x97\xdc\x38\x2f\x66\xef\x33\x27\x26\x8d\x07\x2a\x5d\xa3\x02\x3b\xa0\x65\x63\x6f\x22\xf8\x53\x8b\xcd\xb7\xc8\xd6\xf1\x2a\xc4\x08\x68\xb6\x87\x00\x00")
+
 func TestAvatar_HappyFlow(t *testing.T) {
 	server := newTestServer()
 	defer server.Shutdown()
@@ -160,3 +164,53 @@ func TestAvatar_ExactlySizeLimit(t *testing.T) {
 	server.cfg.MaxAvatarSize = 63
 	assert.Equal(fmt.Sprintf("30 gemini://localhost.localdomain:8443/users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Upload("/users/upload/avatar;mime=image/gif;size=63", server.Alice, avatar))
 }
+
+func TestAvatar_Cached(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+	assert.Equal(fmt.Sprintf("30 gemini://localhost.localdomain:8443/users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Upload("/users/upload/avatar;mime=image/gif;size=63", server.Alice, avatar))
+
+	outbox := server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Contains(outbox, "=> https://localhost.localdomain:8443/icon/alice.gif#")
+
+	assert.NoError((&fed.AvatarFetcher{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	outbox = server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Regexp(`=> https://localhost\.localdomain:8443/avatar/192/[0-9a-f]{64}\.gif Avatar\n`, outbox)
+}
+
+func TestAvatar_WebP(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+	assert.Equal(fmt.Sprintf("30 gemini://localhost.localdomain:8443/users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Upload(fmt.Sprintf("/users/upload/avatar;mime=image/webp;size=%d", len(webpAvatar)), server.Alice, webpAvatar))
+}
+
+func TestAvatar_AVIF(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+	assert.Equal("40 Unsupported image type\r\n", server.Upload("/users/upload/avatar;mime=image/avif;size=16", server.Alice, []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00")))
+}
+
+func TestAvatar_TooManyPixels(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.MaxAvatarPixels = 16*16 - 1
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+	assert.Equal("40 Error\r\n", server.Upload("/users/upload/avatar;mime=image/gif;size=63", server.Alice, avatar))
+}
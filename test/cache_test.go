@@ -0,0 +1,141 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/inbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_InvalidatedByPost(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	outbox := "/outbox/" + strings.TrimPrefix(server.Alice.ID, "https://")
+
+	assert.NotContains(server.Handle("/local", nil), "Hello #world")
+	assert.NotContains(server.Handle("/hashtag/world", nil), "Hello #world")
+	assert.NotContains(server.Handle(outbox, nil), "Hello #world")
+
+	say := server.Handle("/users/say?Hello%20%23world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.Contains(server.Handle("/local", nil), "Hello #world")
+	assert.Contains(server.Handle("/hashtag/world", nil), "Hello #world")
+	assert.Contains(server.Handle(outbox, nil), "Hello #world")
+
+	_, err := server.db.Exec(`delete from notes`)
+	assert.NoError(err)
+
+	assert.Contains(server.Handle("/local", nil), "Hello #world")
+	assert.Contains(server.Handle("/hashtag/world", nil), "Hello #world")
+	assert.Contains(server.Handle(outbox, nil), "Hello #world")
+}
+
+func TestCache_AuthenticationState(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.NotContains(server.Handle("/local", nil), "📻 My feed")
+	assert.Contains(server.Handle("/local", server.Alice), "📻 My feed")
+	assert.NotContains(server.Handle("/local", nil), "📻 My feed")
+}
+
+func TestCache_InvalidatedByRemotePost(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","id":"https://127.0.0.1/user/dan","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	assert.NotContains(server.Handle("/hashtag/world", nil), "Hello #world")
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello #world","to":["https://www.w3.org/ns/activitystreams#Public"],"tag":[{"type":"Hashtag","name":"#world"}]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+		Cache:     &server.handler,
+	}
+
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	assert.Contains(server.Handle("/hashtag/world", nil), "Hello #world")
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/delete/1","type":"Delete","actor":"https://127.0.0.1/user/dan","object":"https://127.0.0.1/note/1","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+	assert.NoError(err)
+
+	n, err = queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	assert.NotContains(server.Handle("/hashtag/world", nil), "Hello #world")
+}
+
+func TestCache_InvalidatedByActorUpdate(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","id":"https://127.0.0.1/user/dan","preferredUsername":"dan","inbox":"https://127.0.0.1/inbox/dan","name":"Dan"}`,
+	)
+	assert.NoError(err)
+
+	assert.Contains(server.Handle("/outbox/127.0.0.1/user/dan", nil), "Dan")
+
+	server.Receive(
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/update/1","type":"Update","actor":"https://127.0.0.1/user/dan","object":{"type":"Person","id":"https://127.0.0.1/user/dan","preferredUsername":"dan","inbox":"https://127.0.0.1/inbox/dan","name":"Daniel"},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+
+	assert.Contains(server.Handle("/outbox/127.0.0.1/user/dan", nil), "Daniel")
+}
@@ -18,6 +18,7 @@ package test
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -44,5 +45,114 @@ func TestCommunities_OneCommunity(t *testing.T) {
 	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
 
 	communities := server.Handle("/users/communities", server.Bob)
-	assert.Contains(strings.Split(communities, "\n"), fmt.Sprintf("=> /users/outbox/%s/user/alice %s alice", domain, time.Now().Format(time.DateOnly)))
+	assert.Contains(strings.Split(communities, "\n"), fmt.Sprintf("=> /users/outbox/%s/user/alice %s alice (one member)", domain, time.Now().Format(time.DateOnly)))
+}
+
+func TestCommunities_NoPosts(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`update persons set actor = json_set(actor, '$.type', 'Group') where id = $1`,
+		server.Alice.ID,
+	)
+	assert.NoError(err)
+
+	communities := server.Handle("/communities", nil)
+	assert.Contains(strings.Split(communities, "\n"), fmt.Sprintf("=> /outbox/%s/user/alice alice (0 members)", domain))
+}
+
+func TestCommunities_CreateDisabled(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	communities := server.Handle("/users/communities", server.Bob)
+	assert.NotContains(communities, "/users/communities/create")
+
+	create := server.Handle("/users/communities/create?fountainpens", server.Bob)
+	assert.Equal("40 Community creation is disabled\r\n", create)
+}
+
+func TestCommunities_Create(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.EnableCommunityCreation = true
+
+	communities := server.Handle("/users/communities", server.Bob)
+	assert.Contains(strings.Split(communities, "\n"), "=> /users/communities/create 🏗️ Create a community")
+
+	create := server.Handle("/users/communities/create?fountainpens", server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s/user/fountainpens\r\n", domain), create)
+
+	communities = server.Handle("/users/communities", server.Bob)
+	assert.Contains(strings.Split(communities, "\n"), fmt.Sprintf("=> /users/outbox/%s/user/fountainpens fountainpens (one member)", domain))
+
+	outbox := server.Handle(fmt.Sprintf("/users/outbox/%s/user/fountainpens", domain), server.Bob)
+	assert.Contains(strings.Split(outbox, "\n"), "=> /users/communities/manage/fountainpens 🛡️ Moderate")
+
+	create = server.Handle("/users/communities/create?fountainpens", server.Bob)
+	assert.Equal("40 Name is already taken\r\n", create)
+
+	create = server.Handle("/users/communities/create?alice", server.Carol)
+	assert.Equal("40 Name is already taken\r\n", create)
+}
+
+func TestCommunities_CreateLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.EnableCommunityCreation = true
+	server.cfg.MaxCommunitiesPerUser = 1
+
+	create := server.Handle("/users/communities/create?fountainpens", server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s/user/fountainpens\r\n", domain), create)
+
+	create = server.Handle("/users/communities/create?inkwells", server.Bob)
+	assert.Equal("40 Reached communities limit\r\n", create)
+}
+
+func TestCommunities_CreateNamePolicy(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.EnableCommunityCreation = true
+	server.cfg.MaxCommunitiesPerUser = 10
+
+	create := server.Handle("/users/communities/create?Alice", server.Bob)
+	assert.Equal("40 Name is already taken\r\n", create)
+
+	create = server.Handle("/users/communities/create?AIice", server.Bob)
+	assert.Equal("40 Name is already taken\r\n", create)
+
+	create = server.Handle("/users/communities/create?Admin", server.Bob)
+	assert.Equal("40 Name is reserved\r\n", create)
+
+	create = server.Handle("/users/communities/create?nobody", server.Bob)
+	assert.Equal("40 Name is already taken\r\n", create)
+
+	create = server.Handle("/users/communities/create?%D0%B0lice", server.Bob)
+	assert.Equal("40 Invalid community name\r\n", create)
+
+	server.cfg.UserNameRegex = `^\p{L}{4,32}$`
+	server.cfg.CompiledUserNameRegex = regexp.MustCompile(server.cfg.UserNameRegex)
+
+	create = server.Handle("/users/communities/create?%D0%B0lice", server.Bob)
+	assert.Equal("40 Name contains confusable characters\r\n", create)
+
+	create = server.Handle("/users/communities/create?%D0%B0%D0%BB%D0%B8%D1%81%D0%B0", server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s/user/алиса\r\n", domain), create)
+
+	create = server.Handle("/users/communities/create?fountainpens", server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s/user/fountainpens\r\n", domain), create)
 }
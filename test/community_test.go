@@ -70,7 +70,7 @@ func TestCommunity_NewThread(t *testing.T) {
 	id := say[15 : len(say)-2]
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Create' and activity->>'$.object.id' = 'https://' || ? and activity->>'$.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.object.type' = 'Create' and activity->>'$.object.object.id' = 'https://' || ? and activity->>'$.object.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(1, forwarded)
 
 	var shared int
@@ -114,7 +114,7 @@ func TestCommunity_NewThreadNotFollowing(t *testing.T) {
 	id := say[15 : len(say)-2]
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Create' and activity->>'$.object.id' = 'https://' || ? and activity->>'$.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.object.type' = 'Create' and activity->>'$.object.object.id' = 'https://' || ? and activity->>'$.object.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(0, forwarded)
 
 	var shared int
@@ -161,7 +161,7 @@ func TestCommunity_NewThreadNotPublic(t *testing.T) {
 	id := whisper[15 : len(whisper)-2]
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Create' and activity->>'$.object.id' = 'https://' || ? and activity->>'$.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.object.type' = 'Create' and activity->>'$.object.object.id' = 'https://' || ? and activity->>'$.object.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(0, forwarded)
 
 	var shared int
@@ -246,7 +246,7 @@ func TestCommunity_ReplyInThread(t *testing.T) {
 	assert.Equal(1, n)
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where cast(activity as text) = ? and sender = ?`, &reply, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->'$.object' = ? and sender = ?`, &reply, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(1, forwarded)
 
 	var shared int
@@ -317,7 +317,7 @@ func TestCommunity_ReplyInThreadAuthorNotFollowing(t *testing.T) {
 	assert.Equal(1, n)
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity = ? and sender = ?`, &reply, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->'$.object' = ? and sender = ?`, &reply, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(0, forwarded)
 
 	var shared int
@@ -406,7 +406,7 @@ func TestCommunity_ReplyInThreadSenderNotFollowing(t *testing.T) {
 	assert.Equal(1, n)
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity = ? and sender = ?`, &reply, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->'$.object' = ? and sender = ?`, &reply, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(1, forwarded)
 
 	var shared int
@@ -502,7 +502,7 @@ func TestCommunity_DuplicateReplyInThread(t *testing.T) {
 	assert.Equal(1, n)
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where cast(activity as text) = ? and sender = ?`, &reply, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->'$.object' = ? and sender = ?`, &reply, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(1, forwarded)
 
 	var shared int
@@ -588,7 +588,7 @@ func TestCommunity_EditedReplyInThread(t *testing.T) {
 	assert.Equal(1, n)
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Create' and activity->>'$.object.id' = 'https://' || ? and activity->>'$.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.object.type' = 'Create' and activity->>'$.object.object.id' = 'https://' || ? and activity->>'$.object.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(1, forwarded)
 
 	var shared int
@@ -623,7 +623,7 @@ func TestCommunity_EditedReplyInThread(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(1, n)
 
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where cast(activity as text) = ? and sender = ?`, &update, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->'$.object' = ? and sender = ?`, &update, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(1, forwarded)
 }
 
@@ -704,10 +704,42 @@ func TestCommunity_UnknownEditedReplyInThread(t *testing.T) {
 	assert.Equal(1, n)
 
 	var forwarded int
-	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Update' and activity->>'$.object.id' = 'https://127.0.0.1/note/1' and activity->>'$.actor' = 'https://127.0.0.1/user/dan' and sender = ?`, server.Alice.ID).Scan(&forwarded))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.object.type' = 'Update' and activity->>'$.object.object.id' = 'https://127.0.0.1/note/1' and activity->>'$.object.actor' = 'https://127.0.0.1/user/dan' and sender = ?`, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(1, forwarded)
 
 	var shared int
 	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.object' = 'https://127.0.0.1/note/1' and sender = ?`, server.Alice.ID).Scan(&shared))
 	assert.Equal(1, shared)
 }
+
+func TestCommunity_DeletedThread(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`update persons set actor = json_set(actor, '$.type', 'Group') where id = $1`,
+		server.Alice.ID,
+	)
+	assert.NoError(err)
+
+	follow := server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), follow)
+
+	say := server.Handle("/users/say?Hello%20%40alice%40localhost.localdomain%3a8443", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	delete := server.Handle("/users/delete/"+id, server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), delete)
+
+	var forwarded int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.object.type' = 'Delete' and activity->>'$.object.object.id' = 'https://' || ? and activity->>'$.object.actor' = ? and sender = ?`, id, server.Bob.ID, server.Alice.ID).Scan(&forwarded))
+	assert.Equal(1, forwarded)
+
+	var shared int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.actor' = $1 and activity->>'$.object' = 'https://' || $2 and sender = $1`, server.Alice.ID, id).Scan(&shared))
+	assert.Equal(1, shared)
+}
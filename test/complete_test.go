@@ -0,0 +1,68 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplete_Prefix(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("10 Prefix (name or name@domain)\r\n", server.Handle("/users/complete", server.Alice))
+
+	complete := server.Handle("/users/complete?b", server.Alice)
+	assert.Contains(complete, "# 🔭 Users Matching 'b'\n")
+	assert.Contains(complete, "=> /users/outbox/localhost.localdomain:8443/user/bob @bob@localhost.localdomain:8443\n")
+	assert.NotContains(complete, "alice")
+
+	complete = server.Handle("/users/complete?%40BO%40localhost", server.Alice)
+	assert.Contains(complete, "=> /users/outbox/localhost.localdomain:8443/user/bob @bob@localhost.localdomain:8443\n")
+
+	assert.Contains(server.Handle("/users/complete?bob%40example.com", server.Alice), "No matches.\n")
+	assert.Contains(server.Handle("/users/complete?%25", server.Alice), "No matches.\n")
+	assert.Equal("40 Bad input\r\n", server.Handle("/users/complete?%40", server.Alice))
+	assert.Equal("30 /users\r\n", server.Handle("/users/complete?b", nil))
+}
+
+func TestComplete_FollowedFirst(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/carl",
+		`{"id":"https://127.0.0.1/user/carl","type":"Person","preferredUsername":"carl"}`,
+	)
+	assert.NoError(err)
+
+	complete := server.Handle("/users/complete?c", server.Alice)
+	assert.Regexp("(?s)@carol@localhost.localdomain:8443\n.*@carl@127.0.0.1\n", complete)
+
+	_, err = server.db.Exec(`insert into follows (id, follower, followed, accepted) values('https://localhost.localdomain:8443/follow/1', $1, 'https://127.0.0.1/user/carl', 1)`, server.Alice.ID)
+	assert.NoError(err)
+
+	complete = server.Handle("/users/complete?c", server.Alice)
+	assert.Regexp("(?s)@carl@127.0.0.1\n.*@carol@localhost.localdomain:8443\n", complete)
+}
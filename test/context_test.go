@@ -0,0 +1,99 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/inbox/note"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_Centered(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.ContextAncestors = 2
+	server.cfg.ContextDepth = 1
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	// 1 <- 2 <- 3 <- 4 <- 5 <- 6
+	for i, author := range []*ap.Actor{server.Alice, server.Bob, server.Carol, server.Alice, server.Bob, server.Carol} {
+		reply := ap.Object{
+			ID:           fmt.Sprintf("https://localhost.localdomain:8443/note/%d", i+1),
+			Type:         ap.Note,
+			AttributedTo: author.ID,
+			Content:      "hello",
+			To:           to,
+		}
+		if i > 0 {
+			reply.InReplyTo = fmt.Sprintf("https://localhost.localdomain:8443/note/%d", i)
+		}
+		assert.NoError(note.Insert(context.Background(), tx, &reply))
+	}
+
+	assert.NoError(tx.Commit())
+
+	view := server.Handle("/users/context/localhost.localdomain:8443/note/4", server.Alice)
+	assert.NotContains(view, "note/1 ")
+	assert.NotContains(view, "note/6 ")
+
+	lines := strings.Split(view, "\n")
+	assert.Equal("# 🧵 Context", lines[1])
+	assert.Regexp(`^=> /users/view/localhost.localdomain:8443/note/2 \S+ bob$`, lines[3])
+	assert.Regexp(`^=> /users/view/localhost.localdomain:8443/note/3 \S+ · carol$`, lines[4])
+	assert.Regexp(`^=> /users/view/localhost.localdomain:8443/note/4 \S+ ·· alice ┃ 📍$`, lines[5])
+	assert.Regexp(`^=> /users/view/localhost.localdomain:8443/note/5 \S+ ··· bob$`, lines[6])
+	assert.Contains(lines, "=> /users/thread/localhost.localdomain:8443/note/2 View thread")
+
+	lines = strings.Split(server.Handle("/context/localhost.localdomain:8443/note/1", nil), "\n")
+	assert.Regexp(`^=> /view/localhost.localdomain:8443/note/1 \S+ alice ┃ 📍$`, lines[3])
+	assert.Regexp(`^=> /view/localhost.localdomain:8443/note/2 \S+ · bob$`, lines[4])
+
+	assert.Equal("40 Post not found\r\n", server.Handle("/users/context/localhost.localdomain:8443/note/7", server.Alice))
+}
+
+func TestContext_FeedLink(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?Welcome%%20Bob", say[15:len(say)-2]), server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	outbox := server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Contains(strings.Split(outbox, "\n"), "=> /users/context/"+reply[15:len(reply)-2]+" 🧵 Context")
+
+	view := server.Handle(say[3:len(say)-2], server.Alice)
+	assert.NotContains(view, "🧵 Context")
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaults_AudiencePublic(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	compose := server.Handle("/users/compose?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, compose)
+
+	var note ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, compose[15:len(compose)-2]).Scan(&note))
+	assert.True(note.To.Contains(ap.Public))
+	assert.True(note.CC.Contains(server.Alice.Followers))
+	assert.False(note.Sensitive)
+}
+
+func TestDefaults_AudienceFollowers(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/audience/followers", server.Alice))
+
+	compose := server.Handle("/users/compose?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, compose)
+
+	var note ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, compose[15:len(compose)-2]).Scan(&note))
+	assert.False(note.To.Contains(ap.Public))
+	assert.False(note.CC.Contains(ap.Public))
+	assert.True(note.To.Contains(server.Alice.Followers))
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/audience/public", server.Alice))
+
+	compose = server.Handle("/users/compose?Hello%20again", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, compose)
+
+	var public ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, compose[15:len(compose)-2]).Scan(&public))
+	assert.True(public.To.Contains(ap.Public))
+}
+
+func TestDefaults_ContentWarning(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+
+	assert.Equal("10 Content warning for new posts\r\n", server.Handle("/users/cw", server.Alice))
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/cw?Spoilers", server.Alice))
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var note ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&note))
+	assert.True(note.Sensitive)
+	assert.Equal("Spoilers", note.Summary)
+
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, server.Handle("/users/reply/"+say[15:len(say)-2]+"?Hello%20Alice", server.Bob))
+
+	var reply ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where object->>'$.inReplyTo' = ?`, note.ID).Scan(&reply))
+	assert.True(reply.Sensitive)
+	assert.Equal("Spoilers", reply.Summary)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/cw/clear", server.Alice))
+
+	whisper := server.Handle("/users/whisper?Hello%20again", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, whisper)
+
+	var plain ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, whisper[15:len(whisper)-2]).Scan(&plain))
+	assert.False(plain.Sensitive)
+	assert.Empty(plain.Summary)
+}
+
+func TestDefaults_Hashtags(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+
+	assert.Equal("40 Invalid hashtag\r\n", server.Handle("/users/tags?%23a%20%23b-c", server.Alice))
+	assert.Equal("40 Too many hashtags\r\n", server.Handle("/users/tags?a,b,c,d,e,f", server.Alice))
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/tags?%23Gemini%20%23smolweb", server.Alice))
+
+	say := server.Handle("/users/say?Hello%20%23gemini", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var note ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&note))
+	assert.Equal(
+		ap.Array[ap.Tag]{
+			{Type: ap.Hashtag, Name: "#gemini", Href: "gemini://localhost.localdomain:8443/hashtag/gemini"},
+			{Type: ap.Hashtag, Name: "#smolweb", Href: "gemini://localhost.localdomain:8443/hashtag/smolweb"},
+		},
+		note.Tag,
+	)
+
+	poll := server.Handle("/users/say?%5BPOLL%20Favorite%20color%5D%20red%7Cgreen", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, poll)
+
+	var question ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, poll[15:len(poll)-2]).Scan(&question))
+	assert.Equal(ap.Question, question.Type)
+	assert.Len(question.AnyOf, 2)
+	assert.Equal("green", question.AnyOf[1].Name)
+	assert.Empty(question.Tag)
+
+	dm := server.Handle("/users/dm?%40bob%20Hello", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, dm)
+
+	var private ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, dm[15:len(dm)-2]).Scan(&private))
+	assert.Len(private.Tag, 1)
+	assert.Equal(ap.Mention, private.Tag[0].Type)
+
+	server.cfg.MaxPostsLength = 10
+	assert.Equal("40 Post is too long\r\n", server.Handle("/users/say?0123456789", server.Alice))
+	server.cfg.MaxPostsLength = 500
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/tags/clear", server.Alice))
+
+	say = server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var untagged ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&untagged))
+	assert.Empty(untagged.Tag)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveries_HappyFlow(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	view := server.Handle("/users/view/"+id, server.Alice)
+	assert.Contains(view, "=> /users/deliveries/"+id+" 📬 Deliveries\n")
+
+	deliveries := server.Handle("/users/deliveries/"+id, server.Alice)
+	assert.Contains(deliveries, "## Create (")
+	assert.Contains(deliveries, "Waiting for delivery.\n")
+
+	var create string
+	assert.NoError(server.db.QueryRow(`select activity->>'$.id' from outbox where activity->>'$.type' = 'Create' and activity->>'$.object.id' = 'https://' || ?`, id).Scan(&create))
+
+	_, err := server.db.Exec(`insert into deliverylog(activity, inbox, host, status, attempts) values(?, 'https://a.localdomain/inbox/dan', 'a.localdomain', 202, 1)`, create)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into deliverylog(activity, inbox, host, status, attempts, error) values(?, 'https://b.localdomain/inbox/erin', 'b.localdomain', 401, 3, 'failed to send request: 401')`, create)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`update outbox set attempts = 3 where activity->>'$.id' = ?`, create)
+	assert.NoError(err)
+
+	deliveries = server.Handle("/users/deliveries/"+id, server.Alice)
+	assert.Contains(deliveries, "Delivery to some recipients has failed and will be retried.\n")
+	assert.Regexp(`\* ✅ a\.localdomain/inbox/dan: delivered \(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\)\n`, deliveries)
+	assert.Regexp(`\* ❌ b\.localdomain/inbox/erin: 401 after 3 attempts \(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\): failed to send request: 401\n`, deliveries)
+	assert.Contains(deliveries, "🔄 Refresh\n")
+}
+
+func TestDeliveries_NotAuthor(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	view := server.Handle("/users/view/"+id, server.Bob)
+	assert.NotContains(view, "📬 Deliveries")
+
+	assert.Equal("40 Post not found\r\n", server.Handle("/users/deliveries/"+id, server.Bob))
+}
+
+func TestDeliveries_UnauthenticatedUser(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`30 /users/view/\S+\r\n$`, say)
+
+	assert.Equal("30 /users\r\n", server.Handle("/users/deliveries/"+say[15:len(say)-2], nil))
+}
+
+func TestDeliveries_ErrorGemtext(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	var create string
+	assert.NoError(server.db.QueryRow(`select activity->>'$.id' from outbox where activity->>'$.type' = 'Create' and activity->>'$.object.id' = 'https://' || ?`, id).Scan(&create))
+
+	_, err := server.db.Exec(`insert into deliverylog(activity, inbox, host, status, attempts, error) values(?, 'https://b.localdomain/inbox/erin', 'b.localdomain', 500, 1, ?)`, create, "failed to send request: 500: oops\n# Hacked\n=> gemini://evil.localdomain Click me")
+	assert.NoError(err)
+
+	deliveries := server.Handle("/users/deliveries/"+id, server.Alice)
+	assert.Regexp(`: failed to send request: 500: oops # Hacked => gemini://evil\.localdomain Click me\n`, deliveries)
+	assert.NotContains(deliveries, "\n# Hacked")
+	assert.NotContains(deliveries, "\n=> gemini://evil.localdomain")
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/inbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigest_Empty(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	digest := server.Handle("/users/digest", server.Alice)
+	assert.Contains(digest, "# 📰 Digest\n")
+	assert.Contains(digest, "No active threads.")
+	assert.Contains(digest, "No new followers.")
+	assert.Contains(digest, "No poll results.")
+
+	assert.Equal("30 /users\r\n", server.Handle("/users/digest", nil))
+}
+
+func TestDigest_Activity(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Alice))
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Carol))
+
+	say := server.Handle("/users/say?Hello%20world", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?Welcome%%20Bob", say[15:len(say)-2]), server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	poll := server.Handle("/users/say?%5BPOLL%20Vanilla%20or%20chocolate%3F%5D%20vanilla%20%7C%20chocolate", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, poll)
+
+	_, err := server.db.Exec(`update notes set object = json_set(object, '$.endTime', ?) where id = 'https://' || ?`, time.Now().Add(-time.Minute).UTC().Format(time.RFC3339), poll[15:len(poll)-2])
+	assert.NoError(err)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	digest := server.Handle("/users/digest", server.Alice)
+	assert.Contains(digest, "> Hello world")
+	assert.Contains(digest, "=> /users/outbox/localhost.localdomain:8443/user/carol carol\n")
+	assert.Contains(digest, "=> /users/view/"+poll[15:len(poll)-2]+" Vanilla or chocolate?\n")
+
+	// the next digest starts after this one
+	_, err = server.db.Exec(`update settings set digest = digest + 1 where actor = ?`, server.Alice.ID)
+	assert.NoError(err)
+
+	digest = server.Handle("/users/digest", server.Alice)
+	assert.Contains(digest, "No active threads.")
+	assert.Contains(digest, "No new followers.")
+	assert.Contains(digest, "No poll results.")
+}
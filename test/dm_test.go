@@ -144,3 +144,25 @@ func TestDM_MaxRecipients(t *testing.T) {
 	view = server.Handle("/users/view/"+id, server.Bob)
 	assert.Contains(view, "Hello @alice@localhost.localdomain:8443 and @carol@localhost.localdomain:8443")
 }
+
+func TestDM_Priority(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	dm := server.Handle("/users/dm?Hello%20%40alice%40localhost.localdomain%3a8443", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, dm)
+
+	server.cfg.PostThrottleUnit = 0
+
+	say := server.Handle("/users/say?Hello%20world", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var priority int
+	assert.NoError(server.db.QueryRow(`select priority from outbox where activity->>'$.object.id' = 'https://' || ?`, dm[15:len(dm)-2]).Scan(&priority))
+	assert.Equal(1, priority)
+
+	assert.NoError(server.db.QueryRow(`select priority from outbox where activity->>'$.object.id' = 'https://' || ?`, say[15:len(say)-2]).Scan(&priority))
+	assert.Equal(0, priority)
+}
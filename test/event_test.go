@@ -0,0 +1,120 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *server) receiveEvent(assert *assert.Assertions, startTime string) {
+	_, err := s.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	event := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/events/1","type":"Event","attributedTo":"https://127.0.0.1/user/dan","name":"Picnic","content":"<p>Bring snacks</p>","startTime":"` + startTime + `","endTime":"2099-10-01T14:00:00Z","location":{"type":"Place","name":"Central park","address":{"type":"PostalAddress","streetAddress":"1 Main St.","addressLocality":"Springfield","addressCountry":"USA"}},"to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`
+
+	s.Receive("https://127.0.0.1/user/dan", event)
+}
+
+func TestEvent_View(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.receiveEvent(assert, "2099-10-01T10:00:00Z")
+
+	view := server.Handle("/users/view/127.0.0.1/events/1", server.Alice)
+	assert.Contains(view, "# 📣 Event by dan\n")
+	assert.Contains(view, "> 📅 Event: Picnic\n")
+	assert.Contains(view, "> 🕒 Starts: 2099-10-01 10:00:00\n")
+	assert.Contains(view, "> 🏁 Ends: 2099-10-01 14:00:00\n")
+	assert.Contains(view, "> 📍 Location: Central park, 1 Main St., Springfield, USA\n")
+	assert.Contains(view, "> Bring snacks\n")
+	assert.Contains(view, "## 📅 RSVP\n")
+	assert.Contains(view, "You haven't responded yet.\n")
+	assert.Contains(view, "=> /users/rsvp/accept/127.0.0.1/events/1 ✅ Going\n")
+	assert.Contains(view, "=> /users/rsvp/tentative/127.0.0.1/events/1 🤔 Maybe\n")
+	assert.Contains(view, "=> /users/rsvp/reject/127.0.0.1/events/1 ❌ Not going\n")
+
+	assert.NotContains(server.Handle("/view/127.0.0.1/events/1", nil), "RSVP")
+
+	outbox := server.Handle("/users/outbox/127.0.0.1/user/dan", server.Alice)
+	assert.Contains(outbox, "> 📅 Event: Picnic (2099-10-01 10:00:00)\n")
+}
+
+func TestEvent_RSVP(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.receiveEvent(assert, "2099-10-01T10:00:00Z")
+
+	assert.Equal("30 /users/view/127.0.0.1/events/1\r\n", server.Handle("/users/rsvp/accept/127.0.0.1/events/1", server.Alice))
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where sender = $1 and activity->>'$.type' = 'Accept' and activity->>'$.actor' = $1 and activity->>'$.object' = 'https://127.0.0.1/events/1' and activity->>'$.to[0]' = 'https://127.0.0.1/user/dan'`, server.Alice.ID).Scan(&count))
+	assert.Equal(1, count)
+
+	view := server.Handle("/users/view/127.0.0.1/events/1", server.Alice)
+	assert.Contains(view, "Your response: ✅ Going\n")
+	assert.NotContains(view, "=> /users/rsvp/accept/127.0.0.1/events/1 ")
+	assert.Contains(view, "=> /users/rsvp/reject/127.0.0.1/events/1 ❌ Not going\n")
+
+	// responding again with the same response does nothing
+	assert.Equal("30 /users/view/127.0.0.1/events/1\r\n", server.Handle("/users/rsvp/accept/127.0.0.1/events/1", server.Alice))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where sender = ?`, server.Alice.ID).Scan(&count))
+	assert.Equal(1, count)
+
+	assert.Equal("30 /users/view/127.0.0.1/events/1\r\n", server.Handle("/users/rsvp/tentative/127.0.0.1/events/1", server.Alice))
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where sender = ? and activity->>'$.type' = 'TentativeAccept'`, server.Alice.ID).Scan(&count))
+	assert.Equal(1, count)
+
+	assert.Contains(server.Handle("/users/view/127.0.0.1/events/1", server.Alice), "Your response: 🤔 Maybe\n")
+	assert.Contains(server.Handle("/users/view/127.0.0.1/events/1", server.Bob), "You haven't responded yet.\n")
+}
+
+func TestEvent_RSVPStarted(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.receiveEvent(assert, "2020-10-01T10:00:00Z")
+
+	assert.NotContains(server.Handle("/users/view/127.0.0.1/events/1", server.Alice), "/users/rsvp/")
+	assert.Equal("40 Event has already started\r\n", server.Handle("/users/rsvp/accept/127.0.0.1/events/1", server.Alice))
+}
+
+func TestEvent_RSVPNotEvent(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.Equal("40 Event not found\r\n", server.Handle("/users/rsvp/accept/"+say[15:len(say)-2], server.Alice))
+	assert.Equal("30 /users\r\n", server.Handle("/users/rsvp/accept/127.0.0.1/events/1", nil))
+}
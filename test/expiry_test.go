@@ -0,0 +1,115 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dimkr/tootik/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiry_OldPosts(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/expiry?7", server.Alice))
+
+	old := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, old)
+
+	bookmarked := server.Handle("/users/say?Hello%20again", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, bookmarked)
+
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, server.Handle("/users/bookmark/"+bookmarked[15:len(bookmarked)-2], server.Alice))
+
+	bob := server.Handle("/users/say?Hello%20from%20Bob", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, bob)
+
+	_, err := server.db.Exec(`update notes set inserted = inserted - 60*60*24*8`)
+	assert.NoError(err)
+
+	recent := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, recent)
+
+	expirer := outbox.Expirer{
+		Domain: domain,
+		Config: server.cfg,
+		DB:     server.db,
+	}
+	assert.NoError(expirer.Run(context.Background()))
+
+	exists := func(post string) bool {
+		var exists int
+		assert.NoError(server.db.QueryRow(`select exists (select 1 from notes where id = 'https://' || ?)`, post[15:len(post)-2]).Scan(&exists))
+		return exists == 1
+	}
+
+	assert.False(exists(old))
+	assert.True(exists(bookmarked))
+	assert.True(exists(bob))
+	assert.True(exists(recent))
+
+	var deletes int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Delete' and activity->>'$.object.id' = 'https://' || ?`, old[15:len(old)-2]).Scan(&deletes))
+	assert.Equal(1, deletes)
+}
+
+func TestExpiry_Disabled(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/expiry?7", server.Alice))
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/expiry?0", server.Alice))
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	_, err := server.db.Exec(`update notes set inserted = inserted - 60*60*24*8`)
+	assert.NoError(err)
+
+	expirer := outbox.Expirer{
+		Domain: domain,
+		Config: server.cfg,
+		DB:     server.db,
+	}
+	assert.NoError(expirer.Run(context.Background()))
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from notes`).Scan(&count))
+	assert.Equal(1, count)
+}
+
+func TestExpiry_InvalidInput(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("10 Delete posts older than (1-3650 days, 0 to keep posts)\r\n", server.Handle("/users/expiry", server.Alice))
+	assert.Equal("40 Invalid number of days\r\n", server.Handle("/users/expiry?-1", server.Alice))
+	assert.Equal("40 Invalid number of days\r\n", server.Handle("/users/expiry?3651", server.Alice))
+	assert.Equal("40 Invalid number of days\r\n", server.Handle("/users/expiry?abc", server.Alice))
+	assert.Equal("30 /users\r\n", server.Handle("/users/expiry?7", nil))
+}
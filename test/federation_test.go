@@ -0,0 +1,111 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFederation_NotAdmin(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("30 /users\r\n", server.Handle("/users/admin/federation", nil))
+	assert.Equal("40 Forbidden\r\n", server.Handle("/users/admin/federation", server.Alice))
+	assert.Equal("40 Forbidden\r\n", server.Handle("/users/admin/federation/pause/ip6-allnodes", server.Alice))
+}
+
+func TestFederation_PauseResume(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.Admins = []string{server.Alice.ID}
+
+	_, err := server.db.Exec(`insert into domains(host, lastsuccess, failures) values('ip6-allnodes', unixepoch(), 3)`)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into undelivered(host, activity) values('ip6-allnodes', 'https://localhost.localdomain:8443/create/1')`)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into outbox(activity, sender) values(json_object('id', 'https://localhost.localdomain:8443/create/1'), ?)`, server.Alice.ID)
+	assert.NoError(err)
+
+	federation := server.Handle("/users/admin/federation", server.Alice)
+	assert.Contains(federation, "## ip6-allnodes\n")
+	assert.Contains(federation, "* Consecutive failures: 3\n")
+	assert.Contains(federation, "* Queued activities: 1\n")
+	assert.Contains(federation, "=> /users/admin/federation/pause/ip6-allnodes ⏸️ Pause delivery\n")
+
+	assert.Equal("30 /users/admin/federation\r\n", server.Handle("/users/admin/federation/pause/ip6-allnodes", server.Alice))
+
+	federation = server.Handle("/users/admin/federation", server.Alice)
+	assert.Contains(federation, "## ip6-allnodes (paused)\n")
+	assert.Contains(federation, "=> /users/admin/federation/resume/ip6-allnodes ▶️ Resume delivery\n")
+
+	assert.Equal("30 /users/admin/federation\r\n", server.Handle("/users/admin/federation/resume/ip6-allnodes", server.Alice))
+	assert.Contains(server.Handle("/users/admin/federation", server.Alice), "## ip6-allnodes\n")
+
+	assert.Equal("40 Cannot pause local delivery\r\n", server.Handle("/users/admin/federation/pause/"+domain, server.Alice))
+}
+
+func TestFederation_LastError(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.Admins = []string{server.Alice.ID}
+
+	_, err := server.db.Exec(`insert into domains(host, lastsuccess, failures) values('ip6-allnodes', unixepoch(), 2)`)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into deliverylog(activity, inbox, host, status, attempts, error, updated) values('https://localhost.localdomain:8443/create/1', 'https://ip6-allnodes/inbox/dan', 'ip6-allnodes', 500, 1, 'failed to send request: 500', unixepoch() - 60)`)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into deliverylog(activity, inbox, host, status, attempts, error) values('https://localhost.localdomain:8443/create/2', 'https://ip6-allnodes/inbox/dan', 'ip6-allnodes', 401, 1, 'failed to send request: 401')`)
+	assert.NoError(err)
+
+	federation := server.Handle("/users/admin/federation", server.Alice)
+	assert.Contains(federation, "## ip6-allnodes\n")
+	assert.Contains(federation, "* Last error: failed to send request: 401\n")
+	assert.NotContains(federation, "500")
+}
+
+func TestFederation_LastErrorGemtext(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.Admins = []string{server.Alice.ID}
+
+	_, err := server.db.Exec(`insert into domains(host, lastsuccess, failures) values('ip6-allnodes', unixepoch(), 1)`)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into deliverylog(activity, inbox, host, status, attempts, error) values('https://localhost.localdomain:8443/create/1', 'https://ip6-allnodes/inbox/dan', 'ip6-allnodes', 500, 1, ?)`, "failed to send request: 500: oops\r\n=> gemini://evil.localdomain Click me\n```")
+	assert.NoError(err)
+
+	federation := server.Handle("/users/admin/federation", server.Alice)
+	assert.Contains(federation, "* Last error: failed to send request: 500: oops => gemini://evil.localdomain Click me ```\n")
+	assert.NotContains(federation, "\n=> gemini://evil.localdomain")
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFields_Throttled(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	add := server.Handle("/users/fields/add?Blog%3dhttps%3a%2f%2fblog.example.com", server.Alice)
+	assert.Regexp(`^40 Please wait for \S+\r\n$`, add)
+}
+
+func TestFields_AddVerifyAndRemove(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+
+	add := server.Handle("/users/fields/add?Blog%3dhttps%3a%2f%2fblog.example.com", server.Alice)
+	assert.Equal("30 /users/fields\r\n", add)
+
+	outbox := server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Contains(strings.Split(outbox, "\n"), "=> https://blog.example.com Blog")
+
+	_, err := server.db.Exec(`update persons set actor = json_set(actor, '$.attachment[0].verifiedAt', ?) where id = ?`, time.Now().UTC().Format(time.RFC3339), server.Alice.ID)
+	assert.NoError(err)
+
+	outbox = server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Contains(strings.Split(outbox, "\n"), "=> https://blog.example.com Blog ✓")
+
+	var alice ap.Actor
+	assert.NoError(server.db.QueryRow(`select actor from persons where id = ?`, server.Alice.ID).Scan(&alice))
+	assert.Len(alice.Attachment, 1)
+	assert.NotNil(alice.Attachment[0].VerifiedAt)
+
+	alice.Updated.Time = alice.Updated.Time.Add(-time.Hour)
+
+	fields := server.Handle("/users/fields", &alice)
+	assert.Contains(strings.Split(fields, "\n"), "Blog: https://blog.example.com ✓")
+	assert.Contains(strings.Split(fields, "\n"), "=> /users/fields/remove/0 ➖ Remove Blog")
+
+	remove := server.Handle("/users/fields/remove/0", &alice)
+	assert.Equal("30 /users/fields\r\n", remove)
+
+	outbox = server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.NotContains(outbox, "blog.example.com")
+}
+
+func TestFields_TooMany(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+	server.Alice.Attachment = make([]ap.Attachment, server.cfg.MaxProfileFields)
+
+	add := server.Handle("/users/fields/add?Pronouns%3dthey%2fthem", server.Alice)
+	assert.Equal("40 Reached fields limit\r\n", add)
+}
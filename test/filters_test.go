@@ -0,0 +1,207 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/inbox"
+	"github.com/dimkr/tootik/inbox/note"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilters_Hide(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Alice))
+
+	say := server.Handle("/users/say?Big%20Spoiler%20ahead", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	assert.Contains(server.Handle("/users", server.Alice), "Big Spoiler ahead")
+
+	assert.Equal("10 Word or phrase (e.g. [feed 1d] spoiler)\r\n", server.Handle("/users/filters/add/hide", server.Alice))
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/add/hide?spoiler", server.Alice))
+
+	filters := server.Handle("/users/filters", server.Alice)
+	assert.Contains(filters, "* spoiler: hide in feed, notifications, threads\n=> /users/filters/remove/1 ➖ Remove spoiler\n")
+
+	users := server.Handle("/users", server.Alice)
+	assert.NotContains(users, "Spoiler")
+	assert.Contains(users, "No posts.")
+
+	local := server.Handle("/users/local", server.Alice)
+	assert.NotContains(local, "Spoiler")
+
+	local = server.Handle("/users/local", server.Carol)
+	assert.Contains(local, "Big Spoiler ahead")
+
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/remove/1", server.Bob))
+	assert.Contains(server.Handle("/users/filters", server.Alice), "spoiler")
+
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/remove/1", server.Alice))
+	assert.Contains(server.Handle("/users/filters", server.Alice), "No filters.")
+
+	assert.Contains(server.Handle("/users", server.Alice), "Big Spoiler ahead")
+}
+
+func TestFilters_HidePageSize(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	for i := range 10 {
+		content := "hello"
+		if i >= 5 {
+			content = "spoiler"
+		}
+
+		assert.NoError(
+			note.Insert(
+				context.Background(),
+				tx,
+				&ap.Object{
+					ID:           fmt.Sprintf("https://localhost.localdomain:8443/note/%d", i),
+					Type:         ap.Note,
+					AttributedTo: server.Bob.ID,
+					Content:      content,
+					To:           to,
+				},
+			),
+		)
+	}
+
+	_, err = tx.Exec(`update notes set inserted = inserted + 1 where object->>'$.content' = 'spoiler'`)
+	assert.NoError(err)
+
+	assert.NoError(tx.Commit())
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/pagesize?5", server.Alice))
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/add/hide?spoiler", server.Alice))
+
+	local := server.Handle("/users/local", server.Alice)
+	assert.Equal(5, strings.Count(local, "> hello\n"))
+	assert.NotContains(local, "> spoiler\n")
+}
+
+func TestFilters_Collapse(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Alice))
+
+	say := server.Handle("/users/say?Big%20Spoiler%20ahead", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/add/collapse?big%20spoiler", server.Alice))
+
+	users := server.Handle("/users", server.Alice)
+	assert.NotContains(users, "Big Spoiler ahead")
+	assert.Contains(users, "> [Filtered: big spoiler]")
+
+	assert.Contains(server.Handle(say[3:len(say)-2], server.Alice), "Big Spoiler ahead")
+}
+
+func TestFilters_Contexts(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?Spoiler%%20in%%20reply", say[15:len(say)-2]), server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/add/hide?%5Bnotifications%2Cfeed%5D%20spoiler", server.Alice))
+	assert.Contains(server.Handle("/users/filters", server.Alice), "* spoiler: hide in feed, notifications\n")
+
+	assert.Contains(server.Handle(say[3:len(say)-2], server.Alice), "Spoiler in reply")
+
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/add/hide?%5Bthreads%5D%20spoiler", server.Alice))
+
+	view := server.Handle(say[3:len(say)-2], server.Alice)
+	assert.NotContains(view, "Spoiler in reply")
+	assert.Contains(view, "💬 Replies")
+
+	assert.Contains(server.Handle(say[3:len(say)-2], server.Carol), "Spoiler in reply")
+}
+
+func TestFilters_Expiry(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Alice))
+
+	say := server.Handle("/users/say?Big%20Spoiler%20ahead", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/add/hide?%5B2d%5D%20spoiler", server.Alice))
+	assert.Regexp(`\* spoiler: hide in feed, notifications, threads, until \d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\n`, server.Handle("/users/filters", server.Alice))
+	assert.NotContains(server.Handle("/users", server.Alice), "Big Spoiler ahead")
+
+	_, err := server.db.Exec(`update filters set expires = unixepoch() - 1`)
+	assert.NoError(err)
+
+	assert.Contains(server.Handle("/users/filters", server.Alice), "No filters.")
+	assert.Contains(server.Handle("/users", server.Alice), "Big Spoiler ahead")
+}
+
+func TestFilters_Invalid(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("40 Invalid filter\r\n", server.Handle("/users/filters/add/hide?%5Bfoo%5D%20spoiler", server.Alice))
+	assert.Equal("40 Invalid filter\r\n", server.Handle("/users/filters/add/hide?%5B1d%202d%5D%20spoiler", server.Alice))
+	assert.Equal("40 Invalid filter\r\n", server.Handle("/users/filters/add/hide?%5Bfeed%20spoiler", server.Alice))
+	assert.Equal("40 Empty filter\r\n", server.Handle("/users/filters/add/hide?%5Bfeed%5D", server.Alice))
+	assert.Equal("40 Empty filter\r\n", server.Handle("/users/filters/add/hide?%20", server.Alice))
+	assert.Equal("40 Filter is too long\r\n", server.Handle("/users/filters/add/hide?"+strings.Repeat("a", 101), server.Alice))
+
+	server.cfg.MaxFiltersPerUser = 1
+
+	assert.Equal("30 /users/filters\r\n", server.Handle("/users/filters/add/hide?a", server.Alice))
+	assert.Equal("40 Reached filters limit\r\n", server.Handle("/users/filters/add/hide?b", server.Alice))
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/front/finger"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *server) Finger(request string) string {
+	client, srv := net.Pipe()
+
+	l := finger.Listener{Domain: domain, Config: s.cfg, DB: s.db}
+
+	done := make(chan struct{})
+	go func() {
+		l.Handle(context.Background(), srv)
+		srv.Close()
+		close(done)
+	}()
+
+	if _, err := client.Write([]byte(request + "\r\n")); err != nil {
+		panic(err)
+	}
+
+	resp, err := io.ReadAll(client)
+	if err != nil {
+		panic(err)
+	}
+
+	<-done
+	client.Close()
+
+	return string(resp)
+}
+
+func TestFinger_Outbox(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+	server.cfg.FingerMaxPosts = 2
+	server.cfg.FingerLineWidth = 10
+
+	assert.Equal("Login: alice\r\nPosts:\r\nNo posts.\r\n", server.Finger("alice+outbox@"+domain))
+
+	for i, content := range []string{"First", "Second", "Third%20post%20is%20long"} {
+		say := server.Handle("/users/say?"+content, server.Alice)
+		assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+		_, err := server.db.Exec(`update notes set inserted = inserted + ? where id = 'https://' || ?`, i, say[15:len(say)-2])
+		assert.NoError(err)
+	}
+
+	today := time.Now().Format(time.DateOnly)
+	assert.Equal(fmt.Sprintf("Login: alice\r\nPosts:\r\n%s\r\nThird\r\npost is\r\nlong\r\n\r\n%s\r\nSecond\r\n", today, today), server.Finger("alice+outbox"))
+
+	assert.Equal("", server.Finger("alice+inbox"))
+}
+
+func TestFinger_Followers(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.FingerMaxFollowers = 1
+
+	assert.Equal("Login: alice\r\nFollowers: 0\r\n", server.Finger("alice+followers@"+domain))
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob))
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Carol))
+
+	followers := server.Finger("alice+followers")
+	assert.Regexp(`^Login: alice\r\nFollowers: 2\r\n(bob|carol)@`+domain+`\r\nAnd 1 more.\r\n$`, followers)
+}
+
+func TestFinger_ASCII(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.FingerASCII = true
+
+	say := server.Handle("/users/say?%E2%AD%90%20Hello%20%F0%9F%91%8B%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	today := time.Now().Format(time.DateOnly)
+	assert.Equal(fmt.Sprintf("Login: alice\r\nPosts:\r\n%s\r\nHello world\r\n", today), server.Finger("alice+outbox"))
+}
@@ -265,3 +265,32 @@ func TestFollow_UnauthenticatedUser(t *testing.T) {
 	follow := server.Handle("/users/follow/localhost.localdomain:8443/user/erin", nil)
 	assert.Equal("30 /users\r\n", follow)
 }
+
+func TestFollow_Rejected(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	follow := server.Handle("/users/follow/127.0.0.1/user/dan", server.Alice)
+	assert.Equal("30 /users/outbox/127.0.0.1/user/dan\r\n", follow)
+
+	var followID string
+	assert.NoError(server.db.QueryRow(`select id from follows where follower = ? and followed = ?`, server.Alice.ID, "https://127.0.0.1/user/dan").Scan(&followID))
+
+	server.Receive(
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/reject/1","type":"Reject","actor":"https://127.0.0.1/user/dan","object":"`+followID+`"}`,
+	)
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from follows where follower = ?`, server.Alice.ID).Scan(&count))
+	assert.Equal(0, count)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat_Gemtext(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/format/gemtext", server.Alice))
+
+	post := "Hello\n=> gemini://example.com/page A page\n=> /users/outbox/" + strings.TrimPrefix(server.Bob.ID, "https://") + " Bob\n=> /users/hashtag/gemini\n=> /users/view/localhost.localdomain:8443/post/1\n\n* one\n* two\n> quoted"
+
+	say := server.Handle("/users/say?"+url.PathEscape(post), server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var note ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&note))
+	assert.Equal(`<p>Hello</p><p><a href="gemini://example.com/page" target="_blank" rel="nofollow noopener noreferrer">A page</a><br/><span class="h-card" translate="no"><a href="`+server.Bob.ID+`" class="u-url mention">Bob</a></span><br/><a href="gemini://localhost.localdomain:8443/hashtag/gemini" class="mention hashtag" rel="tag">#gemini</a><br/><a href="gemini://localhost.localdomain:8443/view/localhost.localdomain:8443/post/1" target="_blank" rel="nofollow noopener noreferrer">gemini://localhost.localdomain:8443/view/localhost.localdomain:8443/post/1</a></p><ul><li>one</li><li>two</li></ul><blockquote><p>quoted</p></blockquote>`, note.Content)
+
+	assert.Equal(
+		ap.Array[ap.Tag]{
+			{Type: ap.Mention, Name: "@bob@localhost.localdomain:8443", Href: server.Bob.ID},
+			{Type: ap.Hashtag, Name: "#gemini", Href: "gemini://localhost.localdomain:8443/hashtag/gemini"},
+		},
+		note.Tag,
+	)
+	assert.True(note.CC.Contains(server.Bob.ID))
+
+	view := server.Handle(say[3:len(say)-2], server.Bob)
+	assert.Contains(view, "> Hello\n")
+	assert.Contains(view, "=> gemini://example.com/page gemini://example.com/page\n")
+	assert.Contains(view, "> * one\n")
+}
+
+func TestFormat_Plain(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/format/gemtext", server.Alice))
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/format/plain", server.Alice))
+
+	say := server.Handle("/users/say?"+url.PathEscape("=> gemini://example.com A page"), server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var content string
+	assert.NoError(server.db.QueryRow(`select object->>'$.content' from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&content))
+	assert.Equal(`<p>=> <a href="gemini://example.com" target="_blank" rel="nofollow noopener noreferrer">gemini://example.com</a> A page</p>`, content)
+}
@@ -1428,3 +1428,157 @@ func TestForward_DeletedDeletedReplyToPublicPost(t *testing.T) {
 	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity = ? and sender = ?`, delete, server.Alice.ID).Scan(&forwarded))
 	assert.Equal(1, forwarded)
 }
+
+func TestForward_ReplyToLocalReplyInFederatedThread(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	assert.NoError(
+		note.Insert(
+			context.Background(),
+			tx,
+			&ap.Object{
+				ID:           "https://127.0.0.1/note/1",
+				Type:         ap.Note,
+				AttributedTo: "https://127.0.0.1/user/erin",
+				Content:      "hello",
+				To:           to,
+			},
+		),
+	)
+
+	assert.NoError(
+		note.Insert(
+			context.Background(),
+			tx,
+			&ap.Object{
+				ID:           "https://localhost.localdomain:8443/post/1",
+				Type:         ap.Note,
+				AttributedTo: server.Alice.ID,
+				InReplyTo:    "https://127.0.0.1/note/1",
+				Content:      "hi",
+				To:           to,
+			},
+		),
+	)
+
+	assert.NoError(tx.Commit())
+
+	_, err = server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	reply := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/2","type":"Note","attributedTo":"https://127.0.0.1/user/dan","inReplyTo":"https://localhost.localdomain:8443/post/1","content":"bye","to":["https://localhost.localdomain:8443/user/alice"],"cc":["https://localhost.localdomain:8443/followers/alice"]},"to":["https://localhost.localdomain:8443/user/alice"],"cc":["https://localhost.localdomain:8443/followers/alice"]}`
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		reply,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	var forwarded int
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from outbox where activity = ? and sender = ?)`, reply, server.Alice.ID).Scan(&forwarded))
+	assert.Equal(1, forwarded)
+}
+
+func TestForward_ReplyToLocalReplyInFederatedThreadNotToFollowers(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	assert.NoError(
+		note.Insert(
+			context.Background(),
+			tx,
+			&ap.Object{
+				ID:           "https://127.0.0.1/note/1",
+				Type:         ap.Note,
+				AttributedTo: "https://127.0.0.1/user/erin",
+				Content:      "hello",
+				To:           to,
+			},
+		),
+	)
+
+	assert.NoError(
+		note.Insert(
+			context.Background(),
+			tx,
+			&ap.Object{
+				ID:           "https://localhost.localdomain:8443/post/1",
+				Type:         ap.Note,
+				AttributedTo: server.Alice.ID,
+				InReplyTo:    "https://127.0.0.1/note/1",
+				Content:      "hi",
+				To:           to,
+			},
+		),
+	)
+
+	assert.NoError(tx.Commit())
+
+	_, err = server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	reply := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/2","type":"Note","attributedTo":"https://127.0.0.1/user/dan","inReplyTo":"https://localhost.localdomain:8443/post/1","content":"bye","to":["https://localhost.localdomain:8443/user/alice"],"cc":["https://127.0.0.1/followers/dan"]},"to":["https://localhost.localdomain:8443/user/alice"],"cc":["https://127.0.0.1/followers/dan"]}`
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		reply,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	var forwarded int
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from outbox where activity = ?)`, reply).Scan(&forwarded))
+	assert.Equal(0, forwarded)
+}
@@ -251,3 +251,59 @@ func TestFTS_SearchByMentionID(t *testing.T) {
 	fts := server.Handle("/users/fts?%22https%3a%2f%2flocalhost.localdomain%3a8443%2fuser%2fbob%22", server.Bob)
 	assert.Contains(fts, "Hello @abc")
 }
+
+func TestFTS_SilencedDomain(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	cc := ap.Audience{}
+	cc.Add("https://127.0.0.1/followers/dan")
+
+	assert.NoError(
+		note.Insert(
+			context.Background(),
+			tx,
+			&ap.Object{
+				ID:           "https://127.0.0.1/note/1",
+				Type:         ap.Note,
+				AttributedTo: "https://127.0.0.1/user/dan",
+				Content:      "Hello world",
+				To:           to,
+				CC:           cc,
+			},
+		),
+	)
+
+	assert.NoError(tx.Commit())
+
+	assert.Contains(server.Handle("/fts?world", nil), "Hello world")
+	assert.Contains(server.Handle("/users/fts?world", server.Bob), "Hello world")
+
+	_, err = server.db.Exec(`insert into domainblocks (host, severity) values('127.0.0.1', 'silence')`)
+	assert.NoError(err)
+
+	assert.NotContains(server.Handle("/fts?world", nil), "Hello world")
+	assert.NotContains(server.Handle("/users/fts?world", server.Bob), "Hello world")
+
+	_, err = server.db.Exec(`insert into follows (id, follower, followed, accepted) values('https://localhost.localdomain:8443/follow/1', $1, 'https://127.0.0.1/user/dan', 1)`, server.Bob.ID)
+	assert.NoError(err)
+
+	assert.NotContains(server.Handle("/fts?world", nil), "Hello world")
+	assert.Contains(server.Handle("/users/fts?world", server.Bob), "Hello world")
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGarbageCollector_MaxDatabaseSize(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into follows (id, follower, followed, accepted) values('https://localhost.localdomain:8443/follow/1', ?, 'https://127.0.0.1/user/dan', 1)`, server.Alice.ID)
+	assert.NoError(err)
+
+	content := strings.Repeat("a", 4096)
+	now := time.Now().Unix()
+	for i := range 100 {
+		_, err := server.db.Exec(
+			`insert into notes (id, author, object, public, inserted) values(?, 'https://127.0.0.1/user/dan', ?, 1, ?)`,
+			fmt.Sprintf("https://127.0.0.1/note/%d", i),
+			fmt.Sprintf(`{"id":"https://127.0.0.1/note/%d","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"%s","to":["https://www.w3.org/ns/activitystreams#Public"]}`, i, content),
+			now-int64(100-i),
+		)
+		assert.NoError(err)
+	}
+
+	_, err = server.db.Exec(`insert into bookmarks (note, by) values('https://127.0.0.1/note/0', ?)`, server.Alice.ID)
+	assert.NoError(err)
+
+	var pageCount, freePages, pageSize int64
+	assert.NoError(server.db.QueryRow(`select page_count, freelist_count, page_size from pragma_page_count(), pragma_freelist_count(), pragma_page_size()`).Scan(&pageCount, &freePages, &pageSize))
+
+	gc := data.GarbageCollector{
+		Domain: domain,
+		Config: server.cfg,
+		DB:     server.db,
+	}
+
+	// 50 posts take at least 200 KB, so they don't fit in 50 KB less than the current size
+	server.cfg.MaxDatabaseSize = (pageCount-freePages)*pageSize - 50*1024
+	server.cfg.EvictionBatchSize = 10
+	assert.NoError(gc.Run(context.Background()))
+
+	exists := func(id string) bool {
+		var exists int
+		assert.NoError(server.db.QueryRow(`select exists (select 1 from notes where id = ?)`, id).Scan(&exists))
+		return exists == 1
+	}
+
+	assert.True(exists("https://127.0.0.1/note/0"))
+	assert.False(exists("https://127.0.0.1/note/1"))
+	assert.True(exists("https://127.0.0.1/note/99"))
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from notes`).Scan(&count))
+	assert.Less(count, 100)
+	assert.Greater(count, 50)
+}
+
+func TestGarbageCollector_DryRun(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public, inserted) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', ?, 1, ?)`,
+		`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"hello","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+		time.Now().Add(-server.cfg.NotesTTL-time.Hour).Unix(),
+	)
+	assert.NoError(err)
+
+	gc := data.GarbageCollector{
+		Domain: domain,
+		Config: server.cfg,
+		DB:     server.db,
+	}
+
+	var b strings.Builder
+	assert.NoError(gc.DryRun(context.Background(), &b))
+	assert.Contains(strings.Split(b.String(), "\n"), "Would delete 1 rows from notes")
+	assert.Regexp(`(?m)^Would free \d+ bytes$`, b.String())
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from notes`).Scan(&count))
+	assert.Equal(1, count)
+
+	assert.NoError(gc.Run(context.Background()))
+
+	assert.NoError(server.db.QueryRow(`select count(*) from notes`).Scan(&count))
+	assert.Equal(0, count)
+}
+
+func TestGarbageCollector_Tombstones(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	old := time.Now().Add(-server.cfg.NotesTTL - time.Hour).Unix()
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public, inserted) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', ?, 1, ?), ('https://127.0.0.1/note/2', 'https://127.0.0.1/user/dan', ?, 1, ?)`,
+		`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"hello","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+		old,
+		`{"id":"https://127.0.0.1/note/2","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"goodbye","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+		old,
+	)
+	assert.NoError(err)
+
+	reply := server.Handle("/users/reply/127.0.0.1/note/1?Hi", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	gc := data.GarbageCollector{
+		Domain: domain,
+		Config: server.cfg,
+		DB:     server.db,
+	}
+	assert.NoError(gc.Run(context.Background()))
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from notes where author = 'https://127.0.0.1/user/dan'`).Scan(&count))
+	assert.Equal(0, count)
+
+	var id string
+	assert.NoError(server.db.QueryRow(`select id from tombstones`).Scan(&id))
+	assert.Equal("https://127.0.0.1/note/1", id)
+}
+
+func TestGarbageCollector_DeliveryLog(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var create string
+	assert.NoError(server.db.QueryRow(`select activity->>'$.id' from outbox where activity->>'$.type' = 'Create'`).Scan(&create))
+
+	old := time.Now().Add(-server.cfg.DeliveryTTL - time.Hour).Unix()
+
+	_, err := server.db.Exec(`insert into deliverylog(activity, inbox, host, status, attempts, updated) values(?, 'https://a.localdomain/inbox/dan', 'a.localdomain', 202, 1, ?), (?, 'https://b.localdomain/inbox/erin', 'b.localdomain', 202, 1, unixepoch())`, create, old, create)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into retries(activity, inbox, attempts, last, jitter) values(?, 'https://a.localdomain/inbox/dan', 1, ?, 0), (?, 'https://b.localdomain/inbox/erin', 1, unixepoch(), 0)`, create, old, create)
+	assert.NoError(err)
+
+	gc := data.GarbageCollector{
+		Domain: domain,
+		Config: server.cfg,
+		DB:     server.db,
+	}
+	assert.NoError(gc.Run(context.Background()))
+
+	// local activities are never removed from the outbox, but their delivery log expires
+	var outbox int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.id' = ?`, create).Scan(&outbox))
+	assert.Equal(1, outbox)
+
+	var inbox string
+	assert.NoError(server.db.QueryRow(`select inbox from deliverylog`).Scan(&inbox))
+	assert.Equal("https://b.localdomain/inbox/erin", inbox)
+
+	assert.NoError(server.db.QueryRow(`select inbox from retries`).Scan(&inbox))
+	assert.Equal("https://b.localdomain/inbox/erin", inbox)
+}
+
+func TestConsistencyChecker_Orphans(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	// rows that belong to erin were left behind when erin was deleted
+	for _, query := range []string{
+		`insert into follows (id, follower, followed, accepted) values('https://localhost.localdomain:8443/follow/1', 'https://localhost.localdomain:8443/user/alice', 'https://127.0.0.1/user/dan', 1)`,
+		`insert into follows (id, follower, followed, accepted) values('https://localhost.localdomain:8443/follow/2', 'https://localhost.localdomain:8443/user/alice', 'https://127.0.0.1/user/erin', 1)`,
+		`insert into follows (id, follower, followed, accepted) values('https://127.0.0.1/follow/3', 'https://127.0.0.1/user/erin', 'https://localhost.localdomain:8443/user/alice', 1)`,
+		`insert into notes (id, author, object, public) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', '{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"hello","to":["https://www.w3.org/ns/activitystreams#Public"]}', 1)`,
+		`insert into notes (id, author, object, public) values('https://127.0.0.1/note/2', 'https://127.0.0.1/user/erin', '{"id":"https://127.0.0.1/note/2","type":"Note","attributedTo":"https://127.0.0.1/user/erin","content":"hello","to":["https://www.w3.org/ns/activitystreams#Public"]}', 1)`,
+		`insert into shares (note, by) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/erin')`,
+		`insert into feed (follower, note, author, sharer, inserted) values('https://localhost.localdomain:8443/user/alice', '{"id":"https://127.0.0.1/note/1"}', '{"id":"https://127.0.0.1/user/dan"}', '{"id":"https://127.0.0.1/user/erin"}', unixepoch())`,
+		`insert into feed (follower, note, author, inserted) values('https://localhost.localdomain:8443/user/alice', '{"id":"https://127.0.0.1/note/1"}', '{"id":"https://127.0.0.1/user/dan"}', unixepoch())`,
+		`insert into webfinger (resource, actor) values('erin@127.0.0.1', 'https://127.0.0.1/user/erin')`,
+	} {
+		_, err := server.db.Exec(query)
+		assert.NoError(err)
+	}
+
+	cfg := *server.cfg
+	cfg.ConsistencyCheckBatchSize = 1
+
+	checker := data.ConsistencyChecker{
+		Domain: domain,
+		Config: &cfg,
+		DB:     server.db,
+	}
+	assert.NoError(checker.Run(context.Background()))
+
+	count := func(query string) int {
+		var n int
+		assert.NoError(server.db.QueryRow(query).Scan(&n))
+		return n
+	}
+
+	assert.Equal(1, count(`select count(*) from follows`))
+	assert.Equal(1, count(`select count(*) from notes where host != 'localhost.localdomain:8443'`))
+	assert.Equal(0, count(`select count(*) from shares`))
+	assert.Equal(1, count(`select count(*) from feed`))
+	assert.Equal(0, count(`select count(*) from webfinger`))
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dimkr/tootik/cfg"
+	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/front"
+	"github.com/dimkr/tootik/front/gemini"
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func geminiRequest(t *testing.T, cfg *cfg.Config, request string) string {
+	assert := assert.New(t)
+
+	dbPath := fmt.Sprintf("/tmp/%s.sqlite3?_journal_mode=WAL", t.Name())
+	defer os.Remove(fmt.Sprintf("/tmp/%s.sqlite3", t.Name()))
+	db, err := sql.Open("sqlite3", dbPath)
+	assert.NoError(err)
+	defer db.Close()
+
+	assert.NoError(migrations.Run(context.Background(), domain, db))
+
+	serverKeyPair, err := tls.X509KeyPair([]byte(serverCert), []byte(serverKey))
+	assert.NoError(err)
+
+	serverCfg := tls.Config{
+		Certificates: []tls.Certificate{serverKeyPair},
+		MinVersion:   tls.VersionTLS12,
+		ClientAuth:   tls.RequestClientCert,
+	}
+
+	clientCfg := tls.Config{
+		InsecureSkipVerify: true,
+	}
+
+	socketPath := fmt.Sprintf("/tmp/%s.socket", t.Name())
+
+	localListener, err := net.Listen("unix", socketPath)
+	assert.NoError(err)
+	defer os.Remove(socketPath)
+
+	tlsListener := tls.NewListener(localListener, &serverCfg)
+	defer tlsListener.Close()
+
+	unixReader, err := net.Dial("unix", socketPath)
+	assert.NoError(err)
+	defer unixReader.Close()
+
+	tlsWriter, err := tlsListener.Accept()
+	assert.NoError(err)
+
+	tlsReader := tls.Client(unixReader, &clientCfg)
+	defer tlsReader.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		assert.NoError(tlsReader.Handshake())
+		wg.Done()
+	}()
+	go func() {
+		assert.NoError(tlsWriter.(*tls.Conn).Handshake())
+		wg.Done()
+	}()
+	wg.Wait()
+
+	_, err = tlsReader.Write([]byte(request))
+	assert.NoError(err)
+
+	handler, err := front.NewHandler(domain, false, cfg, fed.NewResolver(nil, domain, cfg, &http.Client{}, db), db, db)
+	assert.NoError(err)
+
+	var resp []byte
+	wg.Add(1)
+	go func() {
+		resp, err = io.ReadAll(tlsReader)
+		assert.NoError(err)
+		wg.Done()
+	}()
+
+	l := gemini.Listener{
+		Domain:  domain,
+		Config:  cfg,
+		Handler: handler,
+		DB:      db,
+	}
+	l.Handle(context.Background(), tlsWriter)
+
+	tlsWriter.Close()
+	wg.Wait()
+
+	return string(resp)
+}
+
+func TestGemini_ResponseWithinBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+
+	resp := geminiRequest(t, &cfg, "gemini://localhost.localdomain:8965/help\r\n")
+	assert.True(strings.HasPrefix(resp, "20 text/gemini\r\n"))
+	assert.NotContains(resp, "(Response truncated)")
+}
+
+func TestGemini_ResponseTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MaxGeminiResponseSize = 1024
+
+	resp := geminiRequest(t, &cfg, "gemini://localhost.localdomain:8965/help\r\n")
+	assert.True(strings.HasPrefix(resp, "20 text/gemini\r\n"))
+	assert.True(strings.HasSuffix(resp, "\n(Response truncated)\n"))
+	assert.LessOrEqual(len(resp), 1024+len("\n(Response truncated)\n"))
+}
+
+func TestGemini_ResponseTooBig(t *testing.T) {
+	assert := assert.New(t)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.MaxGeminiResponseSize = 8
+
+	assert.Equal("40 Response is too big\r\n", geminiRequest(t, &cfg, "gemini://localhost.localdomain:8965/help\r\n"))
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimkr/tootik/front/gopher"
+	"github.com/stretchr/testify/assert"
+)
+
+var gopherTokenRegex = regexp.MustCompile(`/u/([0-9a-f]{32})/users`)
+
+func (s *server) Gopher(request string) string {
+	client, srv := net.Pipe()
+
+	l := gopher.Listener{Domain: domain, Config: s.cfg, Handler: s.handler}
+
+	done := make(chan struct{})
+	go func() {
+		l.Handle(context.Background(), srv)
+		srv.Close()
+		close(done)
+	}()
+
+	if _, err := client.Write([]byte(request + "\r\n")); err != nil {
+		panic(err)
+	}
+
+	resp, err := io.ReadAll(client)
+	if err != nil {
+		panic(err)
+	}
+
+	<-done
+	client.Close()
+
+	return string(resp)
+}
+
+func TestGopher_Anonymous(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Gopher("/users/say\tHello")
+	assert.Contains(say, "Redirected to /users")
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from notes`).Scan(&count))
+	assert.Equal(0, count)
+}
+
+func TestGopher_InvalidToken(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("i40: Invalid token\t/\t0\t0\r\n", server.Gopher("/u/"+strings.Repeat("a", 32)+"/users"))
+}
+
+func TestGopher_Write(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Contains(server.Handle("/users/gopher", server.Alice), "Gopher access is disabled.")
+
+	generate := server.Handle("/users/gopher/generate", server.Alice)
+	m := gopherTokenRegex.FindStringSubmatch(generate)
+	assert.NotNil(m)
+	prefix := "/u/" + m[1]
+
+	assert.Contains(server.Handle("/users/gopher", server.Alice), "Gopher access is enabled")
+
+	assert.Equal(fmt.Sprintf("7Post content\t%s/users/say\t%s\t70\r\n", prefix, domain), server.Gopher(prefix+"/users/say"))
+
+	say := server.Gopher(prefix + "/users/say\tHello from Gopher")
+	m = regexp.MustCompile(`^1Redirected to\t(` + prefix + `/users/view/\S+)\t` + domain + `\t70\r\n`).FindStringSubmatch(say)
+	assert.NotNil(m)
+
+	var content string
+	assert.NoError(server.db.QueryRow(`select object->>'$.content' from notes where author = ?`, server.Alice.ID).Scan(&content))
+	assert.Equal("<p>Hello from Gopher</p>", content)
+
+	view := server.Gopher(m[1])
+	assert.Contains(view, "i> Hello from Gopher\t")
+	assert.Contains(view, "\t"+prefix+"/users/reply/")
+
+	follow := server.Gopher(prefix + "/users/follow/" + strings.TrimPrefix(server.Bob.ID, "https://"))
+	assert.Contains(follow, fmt.Sprintf("\t%s/users/outbox/%s\t", prefix, strings.TrimPrefix(server.Bob.ID, "https://")))
+
+	var following int
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from follows where follower = ? and followed = ?)`, server.Alice.ID, server.Bob.ID).Scan(&following))
+	assert.Equal(1, following)
+
+	assert.Equal("30 /users/gopher\r\n", server.Handle("/users/gopher/revoke", server.Alice))
+	assert.Equal("i40: Invalid token\t/\t0\t0\r\n", server.Gopher(prefix+"/users"))
+}
+
+func TestGopher_ASCII(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Contains(server.Gopher("/"), "1📡 Local feed\t/local\t")
+
+	server.cfg.GopherASCII = true
+
+	home := server.Gopher("/")
+	assert.Contains(home, "1[local] Local feed\t/local\t")
+	assert.NotContains(home, "📡")
+}
+
+func TestGopher_ExpiredToken(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	generate := server.Handle("/users/gopher/generate", server.Alice)
+	m := gopherTokenRegex.FindStringSubmatch(generate)
+	assert.NotNil(m)
+	prefix := "/u/" + m[1]
+
+	assert.Contains(server.Gopher(prefix+"/users"), "My feed")
+
+	_, err := server.db.Exec(`update gophertokens set inserted = ? where actor = ?`, time.Now().Add(-server.cfg.GopherTokenTTL-time.Minute).Unix(), server.Alice.ID)
+	assert.NoError(err)
+
+	assert.Equal("i40: Invalid token\t/\t0\t0\r\n", server.Gopher(prefix+"/users"))
+	assert.Contains(server.Handle("/users/gopher", server.Alice), "Gopher access is disabled.")
+}
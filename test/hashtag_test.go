@@ -17,10 +17,12 @@ limitations under the License.
 package test
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/dimkr/tootik/inbox"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -209,3 +211,207 @@ func TestHashtag_EmptyHashtagUnauthenticatedUser(t *testing.T) {
 	hashtag := server.Handle("/hashtag/", nil)
 	assert.Equal("30 /oops\r\n", hashtag)
 }
+
+func TestHashtag_Follow(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+
+	hashtag := server.Handle("/users/hashtag/world", server.Alice)
+	assert.Contains(hashtag, "=> /users/hashtag/world/follow ⚡ Follow #world")
+
+	assert.Equal("30 /users/hashtag/world\r\n", server.Handle("/users/hashtag/world/follow", server.Alice))
+
+	hashtag = server.Handle("/users/hashtag/world", server.Alice)
+	assert.Contains(hashtag, "=> /users/hashtag/world/unfollow 🔌 Unfollow #world")
+
+	say := server.Handle("/users/say?Hello%20%23World", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	whisper := server.Handle("/users/whisper?Hello%20again%20%23world", server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, whisper)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	users := server.Handle("/users", server.Alice)
+	assert.Contains(users, "Hello #World")
+	assert.NotContains(users, "Hello again")
+
+	assert.Equal("30 /users/hashtag/world\r\n", server.Handle("/users/hashtag/world/unfollow", server.Alice))
+
+	hashtag = server.Handle("/users/hashtag/world", server.Alice)
+	assert.Contains(hashtag, "=> /users/hashtag/world/follow ⚡ Follow #world")
+
+	say = server.Handle("/users/say?Goodbye%20%23world", server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	users = server.Handle("/users", server.Alice)
+	assert.NotContains(users, "Goodbye")
+}
+
+func TestHashtag_FollowTooMany(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.MaxFollowedHashtags = 1
+
+	assert.Equal("30 /users/hashtag/a\r\n", server.Handle("/users/hashtag/a/follow", server.Alice))
+	assert.Equal("40 Following too many hashtags\r\n", server.Handle("/users/hashtag/b/follow", server.Alice))
+}
+
+func TestHashtag_SilencedDomain(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://social.127.0.0.1/user/dan",
+		`{"id":"https://social.127.0.0.1/user/dan","type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public, inserted) values('https://social.127.0.0.1/note/1', 'https://social.127.0.0.1/user/dan', '{"id":"https://social.127.0.0.1/note/1","type":"Note","attributedTo":"https://social.127.0.0.1/user/dan","content":"Hello #world","to":["https://www.w3.org/ns/activitystreams#Public"]}', 1, unixepoch() - 60*60*24)`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into hashtags (note, hashtag) values('https://social.127.0.0.1/note/1', 'world')`)
+	assert.NoError(err)
+
+	hashtag := server.Handle("/users/hashtag/world", server.Bob)
+	assert.Contains(hashtag, "Hello #world")
+	assert.Contains(hashtag, "## Posts Per Day\n")
+
+	_, err = server.db.Exec(`insert into domainblocks (host, severity) values('127.0.0.1', 'silence')`)
+	assert.NoError(err)
+
+	hashtag = server.Handle("/users/hashtag/world", server.Bob)
+	assert.NotContains(hashtag, "Hello #world")
+	assert.Contains(hashtag, "No posts.")
+	assert.NotContains(hashtag, "## Posts Per Day\n")
+
+	view := server.Handle("/users/view/social.127.0.0.1/note/1", server.Bob)
+	assert.Contains(view, "Hello #world")
+}
+
+func TestHashtag_Sort(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+
+	say := server.Handle("/users/say?Hello%20%23world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	_, err := server.db.Exec(`update notes set inserted = inserted - 60 where id = ?`, "https://"+id)
+	assert.NoError(err)
+
+	say = server.Handle("/users/say?Bye%20%23world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	share := server.Handle("/users/share/"+id, server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/view/%s\r\n", id), share)
+
+	hashtag := server.Handle("/users/hashtag/world", server.Carol)
+	assert.Contains(hashtag, "# Posts Tagged #world")
+	assert.Less(strings.Index(hashtag, "> Bye #world"), strings.Index(hashtag, "> Hello #world"))
+	assert.Contains(hashtag, "=> /users/hashtag/world/recent ⏳ Most recent\n")
+	assert.Contains(hashtag, "=> /users/hashtag/world/shared ♻️ Most shared\n")
+	assert.NotContains(hashtag, "Most discussed")
+
+	recent := server.Handle("/users/hashtag/world/recent", server.Carol)
+	assert.Contains(recent, "# Recent Posts Tagged #world")
+	assert.Less(strings.Index(recent, "> Bye #world"), strings.Index(recent, "> Hello #world"))
+	assert.Contains(recent, "=> /users/hashtag/world 💬 Most discussed\n")
+	assert.NotContains(recent, "Most recent")
+
+	shared := server.Handle("/hashtag/world/shared", nil)
+	assert.Contains(shared, "# Most Shared Posts Tagged #world")
+	assert.Less(strings.Index(shared, "> Hello #world"), strings.Index(shared, "> Bye #world"))
+	assert.Contains(shared, "=> /hashtag/world/recent ⏳ Most recent\n")
+	assert.NotContains(shared, "Most shared")
+}
+
+func TestHashtag_PostsPerDay(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20%23world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	// the graph doesn't include today
+	hashtag := server.Handle("/users/hashtag/world", server.Bob)
+	assert.NotContains(hashtag, "## Posts Per Day\n")
+
+	_, err := server.db.Exec(`update notes set inserted = inserted - 60*60*24 where id = ?`, "https://"+say[15:len(say)-2])
+	assert.NoError(err)
+
+	hashtag = server.Handle("/hashtag/world", nil)
+	assert.Contains(hashtag, "## Posts Per Day\n")
+	assert.Contains(hashtag, "=> https://localhost.localdomain:8443/hashtag/world/rss 🗞️ RSS feed of #world\n")
+}
+
+func TestHashtag_PostsPerDayEightDays(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	// the oldest post is less than 7 days old, but the posts span 8 calendar days
+	for i := range 8 {
+		id := fmt.Sprintf("https://localhost.localdomain:8443/post/%d", i)
+
+		_, err := server.db.Exec(
+			`insert into notes (id, author, object, public, inserted) values(?, ?, ?, 1, unixepoch() - ?*60*60*24 + 60)`,
+			id,
+			server.Alice.ID,
+			fmt.Sprintf(`{"id":"%s","type":"Note","attributedTo":"%s","content":"Hello #world","to":["https://www.w3.org/ns/activitystreams#Public"]}`, id, server.Alice.ID),
+			i,
+		)
+		assert.NoError(err)
+
+		_, err = server.db.Exec(`insert into hashtags (note, hashtag) values(?, 'world')`, id)
+		assert.NoError(err)
+	}
+
+	hashtag := server.Handle("/hashtag/world", nil)
+	assert.Contains(hashtag, "## Posts Per Day\n")
+}
+
+func TestHashtag_Pagination(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+	server.cfg.PostsPerPage = 3
+
+	for i := range server.cfg.PostsPerPage + 1 {
+		say := server.Handle(fmt.Sprintf("/users/say?Hello%%20%%23world%%20%d", i), server.Alice)
+		assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+	}
+
+	recent := server.Handle("/users/hashtag/world/recent", server.Bob)
+	assert.Equal(server.cfg.PostsPerPage, strings.Count(recent, "> Hello #world"))
+	assert.Contains(recent, fmt.Sprintf("=> /users/hashtag/world/recent?%d Next page", server.cfg.PostsPerPage))
+
+	recent = server.Handle(fmt.Sprintf("/users/hashtag/world/recent?%d", server.cfg.PostsPerPage), server.Bob)
+	assert.Equal(1, strings.Count(recent, "> Hello #world"))
+	assert.Contains(recent, "=> /users/hashtag/world/recent?0 Previous page")
+}
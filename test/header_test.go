@@ -0,0 +1,89 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader_HappyFlow(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+	assert.Equal(fmt.Sprintf("30 gemini://localhost.localdomain:8443/users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Upload("/users/upload/header;mime=image/gif;size=63", server.Alice, avatar))
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from headers where name = 'alice'`).Scan(&count))
+	assert.Equal(1, count)
+
+	outbox := server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Regexp(`=> https://localhost\.localdomain:8443/header/alice\.png#\d+ Header\n`, outbox)
+}
+
+func TestHeader_ChangedRecently(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Regexp(`^40 Please wait for \S+\r\n$`, server.Upload("/users/upload/header;mime=image/gif;size=63", server.Alice, avatar))
+}
+
+func TestHeader_TooBigSize(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.MaxHeaderSize = 62
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+	assert.Equal("40 Image is too big\r\n", server.Upload("/users/upload/header;mime=image/gif;size=63", server.Alice, avatar))
+}
+
+func TestHeader_AspectRatio(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	var b bytes.Buffer
+	assert.NoError(png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 1000, 1000))))
+
+	server.Alice.Published.Time = server.Alice.Published.Time.Add(-time.Hour)
+	assert.Equal(fmt.Sprintf("30 gemini://localhost.localdomain:8443/users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Upload(fmt.Sprintf("/users/upload/header;mime=image/png;size=%d", b.Len()), server.Alice, b.Bytes()))
+
+	var header []byte
+	assert.NoError(server.db.QueryRow(`select buf from headers where name = 'alice'`).Scan(&header))
+
+	dim, format, err := image.DecodeConfig(bytes.NewReader(header))
+	assert.NoError(err)
+	assert.Equal("png", format)
+	assert.Equal(1000, dim.Width)
+	assert.Equal(333, dim.Height)
+}
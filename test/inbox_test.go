@@ -0,0 +1,401 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/inbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInbox_CreateAndDeleteInBatch(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	for _, activity := range []string{
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/3","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/2","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/2","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"World","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/delete/1","type":"Delete","actor":"https://127.0.0.1/user/dan","object":"https://127.0.0.1/note/1","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	} {
+		_, err := server.db.Exec(`insert into inbox (sender, activity, raw) values($1, $2, $2)`, "https://127.0.0.1/user/dan", activity)
+		assert.NoError(err)
+	}
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(4, n)
+
+	var ids string
+	assert.NoError(server.db.QueryRow(`select group_concat(id) from notes`).Scan(&ids))
+	assert.Equal("https://127.0.0.1/note/2", ids)
+}
+
+func TestInbox_UndoAnnounce(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	for _, name := range []string{"dan", "erin"} {
+		_, err := server.db.Exec(
+			`insert into persons (id, actor) values(?,?)`,
+			"https://127.0.0.1/user/"+name,
+			fmt.Sprintf(`{"id":"https://127.0.0.1/user/%s","type":"Person","preferredUsername":"%s","followers":"https://127.0.0.1/followers/%s"}`, name, name, name),
+		)
+		assert.NoError(err)
+	}
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+
+	process := func(sender string, activities ...string) {
+		for _, activity := range activities {
+			_, err := server.db.Exec(`insert into inbox (sender, activity, raw) values($1, $2, $2)`, sender, activity)
+			assert.NoError(err)
+		}
+
+		n, err := queue.ProcessBatch(context.Background())
+		assert.NoError(err)
+		assert.Equal(len(activities), n)
+	}
+
+	process(
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+
+	process(
+		"https://127.0.0.1/user/erin",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/announce/1","type":"Announce","actor":"https://127.0.0.1/user/erin","object":"https://127.0.0.1/note/1","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+
+	var shares int
+	assert.NoError(server.db.QueryRow(`select count(*) from shares where note = 'https://127.0.0.1/note/1' and by = 'https://127.0.0.1/user/erin'`).Scan(&shares))
+	assert.Equal(1, shares)
+
+	// dan can't undo a share by erin
+	process(
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/undo/1","type":"Undo","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/announce/1","type":"Announce","actor":"https://127.0.0.1/user/erin","object":"https://127.0.0.1/note/1"},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+
+	assert.NoError(server.db.QueryRow(`select count(*) from shares where note = 'https://127.0.0.1/note/1' and by = 'https://127.0.0.1/user/erin'`).Scan(&shares))
+	assert.Equal(1, shares)
+
+	process(
+		"https://127.0.0.1/user/erin",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/undo/2","type":"Undo","actor":"https://127.0.0.1/user/erin","object":{"id":"https://127.0.0.1/announce/1","type":"Announce","actor":"https://127.0.0.1/user/erin","object":{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello","to":["https://www.w3.org/ns/activitystreams#Public"]}},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+
+	assert.NoError(server.db.QueryRow(`select count(*) from shares where note = 'https://127.0.0.1/note/1' and by = 'https://127.0.0.1/user/erin'`).Scan(&shares))
+	assert.Equal(0, shares)
+}
+
+func TestInbox_UndoUntracked(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	for _, activity := range []string{
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/like/1","type":"Like","actor":"https://127.0.0.1/user/dan","object":"https://localhost.localdomain:8443/post/1"}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/undo/1","type":"Undo","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/like/1","type":"Like","actor":"https://127.0.0.1/user/dan","object":"https://localhost.localdomain:8443/post/1"}}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/undo/2","type":"Undo","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/react/1","type":"EmojiReact","actor":"https://127.0.0.1/user/dan","object":"https://localhost.localdomain:8443/post/1","content":"👍"}}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/block/1","type":"Block","actor":"https://127.0.0.1/user/dan","object":"https://localhost.localdomain:8443/user/alice"}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/undo/3","type":"Undo","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/block/1","type":"Block","actor":"https://127.0.0.1/user/dan","object":"https://localhost.localdomain:8443/user/alice"}}`,
+	} {
+		_, err := server.db.Exec(`insert into inbox (sender, activity, raw) values($1, $2, $2)`, "https://127.0.0.1/user/dan", activity)
+		assert.NoError(err)
+	}
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(5, n)
+
+	var queued int
+	assert.NoError(server.db.QueryRow(`select count(*) from inbox`).Scan(&queued))
+	assert.Equal(0, queued)
+}
+
+func BenchmarkInbox_Backlog(b *testing.B) {
+	const backlog = 10000
+
+	for range b.N {
+		b.StopTimer()
+
+		server := newTestServer()
+
+		// don't drop activities when the queue is full
+		server.cfg.MaxActivitiesQueueSize = backlog * 2
+
+		if _, err := server.db.Exec(
+			`insert into persons (id, actor) values(?,?)`,
+			"https://127.0.0.1/user/dan",
+			`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+		); err != nil {
+			b.Fatal(err)
+		}
+
+		for i := range backlog {
+			if _, err := server.db.Exec(
+				`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+				"https://127.0.0.1/user/dan",
+				fmt.Sprintf(`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/%d","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/%d","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello #world %d","tag":[{"type":"Hashtag","name":"#world"}],"to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["https://127.0.0.1/followers/dan"]},"to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["https://127.0.0.1/followers/dan"]}`, i, i, i),
+			); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		queue := inbox.Queue{
+			Domain:    domain,
+			Config:    server.cfg,
+			BlockList: &fed.BlockList{},
+			DB:        server.db,
+			Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+			Key:       server.NobodyKey,
+		}
+
+		b.StartTimer()
+
+		for {
+			n, err := queue.ProcessBatch(context.Background())
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if n == 0 {
+				break
+			}
+		}
+
+		b.StopTimer()
+
+		var count int
+		if err := server.db.QueryRow(`select count(*) from notes where author = 'https://127.0.0.1/user/dan'`).Scan(&count); err != nil {
+			b.Fatal(err)
+		} else if count != backlog {
+			b.Fatalf("%d != %d", count, backlog)
+		}
+
+		server.Shutdown()
+	}
+}
+
+func TestInbox_UpdateActor(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","name":"Dan","inbox":"https://127.0.0.1/inbox/dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into feed (follower, note, author, inserted) select $1, $2, actor, unixepoch() from persons where id = $3`,
+		server.Alice.ID,
+		`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+		"https://127.0.0.1/user/dan",
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into webfinger (resource, actor) values('dan@127.0.0.1', 'https://127.0.0.1/user/dan')`)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/update/1","type":"Update","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"daniel","name":"Daniel","inbox":"https://127.0.0.1/inbox/dan","followers":"https://127.0.0.1/followers/dan","movedTo":"https://127.0.0.1/user/daniel"},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	var name, handle, movedTo string
+	assert.NoError(server.db.QueryRow(`select actor->>'$.name', actor->>'$.preferredUsername', actor->>'$.movedTo' from persons where id = 'https://127.0.0.1/user/dan'`).Scan(&name, &handle, &movedTo))
+	assert.Equal("Daniel", name)
+	assert.Equal("daniel", handle)
+	assert.Equal("https://127.0.0.1/user/daniel", movedTo)
+
+	assert.NoError(server.db.QueryRow(`select author->>'$.name' from feed where note->>'$.id' = 'https://127.0.0.1/note/1'`).Scan(&name))
+	assert.Equal("Daniel", name)
+
+	var cached int
+	assert.NoError(server.db.QueryRow(`select count(*) from webfinger`).Scan(&cached))
+	assert.Equal(0, cached)
+}
+
+func TestInbox_UpdateActorWrongActor(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	for _, name := range []string{"dan", "erin"} {
+		_, err := server.db.Exec(
+			`insert into persons (id, actor) values(?,?)`,
+			"https://127.0.0.1/user/"+name,
+			fmt.Sprintf(`{"id":"https://127.0.0.1/user/%s","type":"Person","preferredUsername":"%s","inbox":"https://127.0.0.1/inbox/%s"}`, name, name, name),
+		)
+		assert.NoError(err)
+	}
+
+	_, err := server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/update/1","type":"Update","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/user/erin","type":"Person","preferredUsername":"erin","name":"Dan was here","inbox":"https://127.0.0.1/inbox/erin"},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	var updated int
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from persons where actor->>'$.name' is not null)`).Scan(&updated))
+	assert.Equal(0, updated)
+}
+
+func TestInbox_DeleteActor(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	for _, query := range []string{
+		`insert into follows (id, follower, followed, accepted) values('https://localhost.localdomain:8443/follow/1', 'https://localhost.localdomain:8443/user/alice', 'https://127.0.0.1/user/dan', 1)`,
+		`insert into follows (id, follower, followed, accepted) values('https://127.0.0.1/follow/1', 'https://127.0.0.1/user/dan', 'https://localhost.localdomain:8443/user/alice', 1)`,
+		`insert into notes (id, author, object, public) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', '{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"hello","to":["https://www.w3.org/ns/activitystreams#Public"]}', 1)`,
+		`insert into shares (note, by) values('https://localhost.localdomain:8443/note/1', 'https://127.0.0.1/user/dan')`,
+		`insert into feed (follower, note, author, inserted) select 'https://localhost.localdomain:8443/user/alice', object, '{"id":"https://127.0.0.1/user/dan"}', unixepoch() from notes`,
+		`insert into webfinger (resource, actor) values('dan@127.0.0.1', 'https://127.0.0.1/user/dan')`,
+	} {
+		_, err := server.db.Exec(query)
+		assert.NoError(err)
+	}
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/user/dan#delete","type":"Delete","actor":"https://127.0.0.1/user/dan","object":"https://127.0.0.1/user/dan","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	for _, table := range []string{"follows", "shares", "feed", "webfinger"} {
+		var count int
+		assert.NoError(server.db.QueryRow(`select count(*) from ` + table).Scan(&count))
+		assert.Equal(0, count, table)
+	}
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from notes where author = 'https://127.0.0.1/user/dan'`).Scan(&count))
+	assert.Equal(0, count)
+
+	assert.NoError(server.db.QueryRow(`select count(*) from persons where id = 'https://127.0.0.1/user/dan'`).Scan(&count))
+	assert.Equal(0, count)
+	assert.NoError(server.db.QueryRow(`select count(*) from tombstones where id = 'https://127.0.0.1/note/1'`).Scan(&count))
+	assert.Equal(1, count)
+}
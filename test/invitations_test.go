@@ -0,0 +1,56 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvitations_Create(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.RequireInvitation = true
+	server.cfg.MaxInvitationsPerUser = 2
+
+	invitations := server.Handle("/users/invitations", server.Alice)
+	assert.Contains(invitations, "No invitations.")
+	assert.Contains(invitations, "=> /users/invitations/create ➕ Create invitation\n")
+	assert.Contains(invitations, "https://"+domain+"/register")
+
+	assert.Equal("30 /users/invitations\r\n", server.Handle("/users/invitations/create", server.Alice))
+	assert.Equal("30 /users/invitations\r\n", server.Handle("/users/invitations/create", server.Alice))
+	assert.Equal("40 Reached invitations limit\r\n", server.Handle("/users/invitations/create", server.Alice))
+
+	invitations = server.Handle("/users/invitations", server.Alice)
+	assert.Regexp(`\* [0-9a-f]{16}: unused\n\* [0-9a-f]{16}: unused\n`, invitations)
+	assert.NotContains(invitations, "Create invitation")
+
+	var code string
+	assert.NoError(server.db.QueryRow(`select code from invitations limit 1`).Scan(&code))
+
+	_, err := server.db.Exec(`update invitations set invited = 'erin' where code = ?`, code)
+	assert.NoError(err)
+	assert.Contains(server.Handle("/users/invitations", server.Alice), "* "+code+": used by erin\n")
+
+	assert.Contains(server.Handle("/users/invitations", server.Bob), "No invitations.")
+	assert.Equal("30 /users\r\n", server.Handle("/users/invitations/create", nil))
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/inbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguages_PostLanguage(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?%5bLANG%20de%5d%20Hallo%20Welt", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var content, de string
+	assert.NoError(server.db.QueryRow(`select object->>'$.content', object->>'$.contentMap.de' from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&content, &de))
+	assert.Equal("<p>Hallo Welt</p>", content)
+	assert.Equal(content, de)
+
+	view := server.Handle(say[3:len(say)-2], server.Bob)
+	assert.Contains(strings.Split(view, "\n"), "> Hallo Welt")
+}
+
+func TestLanguages_DefaultLanguage(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("10 Language code (e.g. en)\r\n", server.Handle("/users/language", server.Alice))
+	assert.Equal("40 Invalid language code\r\n", server.Handle("/users/language?english", server.Alice))
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/language?en", server.Alice))
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var en string
+	assert.NoError(server.db.QueryRow(`select object->>'$.contentMap.en' from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&en))
+	assert.Equal("<p>Hello world</p>", en)
+
+	say = server.Handle("/users/say?Hello%20world", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var exists int
+	assert.NoError(server.db.QueryRow(`select object->'$.contentMap' is not null from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&exists))
+	assert.Equal(0, exists)
+}
+
+func TestLanguages_Filter(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	follow := server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), follow)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/languages?en%2c%20FR", server.Bob))
+
+	say := server.Handle("/users/say?%5bLANG%20de%5d%20Hallo%20Welt", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	say = server.Handle("/users/say?%5bLANG%20fr-CA%5d%20Bonjour", server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	local := server.Handle("/local", nil)
+	assert.Contains(local, "Hallo Welt")
+	assert.Contains(local, "Bonjour")
+
+	local = server.Handle("/users/local", server.Bob)
+	assert.NotContains(local, "Hallo Welt")
+	assert.Contains(local, "Bonjour")
+
+	assert.NotContains(server.Handle("/users", server.Bob), "Hallo Welt")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/languages/all", server.Bob))
+
+	local = server.Handle("/users/local", server.Bob)
+	assert.Contains(local, "Hallo Welt")
+	assert.Contains(local, "Bonjour")
+
+	assert.Contains(server.Handle("/users", server.Bob), "Hallo Welt")
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits_Empty(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(`update persons set actor = json_set(actor, '$.published', ?) where id = ?`, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), server.Alice.ID)
+	assert.NoError(err)
+	server.Alice.Published.Time = time.Now().Add(-time.Hour)
+
+	limits := server.Handle("/users/limits", server.Alice)
+	assert.Contains(limits, "* Published during the last day: 0/30\n* Next post: now\n")
+	assert.Contains(limits, "* Shared or unshared during the last day: 0\n* Next share: now\n")
+	assert.Contains(limits, "* Profile: now\n")
+
+	assert.Equal("30 /users\r\n", server.Handle("/users/limits", nil))
+}
+
+func TestLimits_Throttled(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = time.Hour
+	server.cfg.EditThrottleUnit = time.Hour
+
+	say := server.Handle("/users/say?Hello", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.Regexp(`^40 Please wait for \S+\r\n$`, server.Handle("/users/say?Hello%20again", server.Alice))
+
+	limits := server.Handle("/users/limits", server.Alice)
+	assert.Contains(limits, "* Published during the last day: 1/30\n")
+	assert.Regexp(`\* Next post: in 59m5\ds\n`, limits)
+	assert.Regexp(`=> `+say[3:len(say)-2]+` \d{4}-\d{2}-\d{2} post: in 3h59m5\ds\n`, limits)
+
+	server.cfg.MaxPostsPerDay = 1
+	assert.Contains(server.Handle("/users/limits", server.Alice), "* Published during the last day: 1/1\n* Next post: after some of these posts are a day old\n")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinks_LocalPost(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	var note ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, id).Scan(&note))
+	assert.Equal("gemini://"+domain+"/view/"+id, note.URL)
+
+	view := server.Handle("/users/view/"+id, server.Bob)
+	assert.NotContains(view, "=> gemini://"+domain+"/view/"+id)
+
+	links := strings.Split(server.Handle("/users/links/"+id, server.Bob), "\n")
+	assert.Contains(links, "=> https://"+id+" https://"+id)
+	assert.Contains(links, "=> gemini://"+domain+"/view/"+id+" View on "+domain)
+	assert.Contains(links, "=> gemini://"+domain+"/view/"+id+" gemini://"+domain+"/view/"+id)
+
+	assert.Contains(server.Handle("/links/"+id, nil), "=> gemini://"+domain+"/view/"+id+" View on "+domain)
+
+	assert.Equal(links, strings.Split(server.Handle("/users/links/gemini://"+domain+"/view/"+id, server.Bob), "\n"))
+}
+
+func TestLinks_PostURL(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostURL = "https://{domain}/web/view/{post}"
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	var note ap.Object
+	assert.NoError(server.db.QueryRow(`select object from notes where id = 'https://' || ?`, id).Scan(&note))
+	assert.Equal("https://"+domain+"/web/view/"+id, note.URL)
+
+	assert.Contains(server.Handle("/users/view/https://"+domain+"/web/view/"+id, server.Bob), "Hello world")
+	assert.Contains(strings.Split(server.Handle("/users/links/"+id, server.Bob), "\n"), "=> https://"+domain+"/web/view/"+id+" https://"+domain+"/web/view/"+id)
+}
+
+func TestLinks_RemotePost(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', '{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"hello","url":"https://127.0.0.1/@dan/1","to":["https://www.w3.org/ns/activitystreams#Public"]}', 1)`,
+	)
+	assert.NoError(err)
+
+	links := strings.Split(server.Handle("/users/links/https://127.0.0.1/@dan/1", server.Bob), "\n")
+	assert.Contains(links, "=> https://127.0.0.1/note/1 https://127.0.0.1/note/1")
+	assert.Contains(links, "=> gemini://"+domain+"/view/127.0.0.1/note/1 View on "+domain)
+	assert.Contains(links, "=> https://127.0.0.1/@dan/1 https://127.0.0.1/@dan/1")
+
+	assert.Equal("40 Post not found\r\n", server.Handle("/users/links/127.0.0.1/note/2", server.Bob))
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dimkr/tootik/inbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedia_PeerTubeChannel(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?), (?,?)`,
+		"https://127.0.0.1/accounts/dan",
+		`{"id":"https://127.0.0.1/accounts/dan","type":"Person","preferredUsername":"dan"}`,
+		"https://127.0.0.1/video-channels/cats",
+		`{"id":"https://127.0.0.1/video-channels/cats","type":"Group","preferredUsername":"cats","followers":"https://127.0.0.1/video-channels/cats/followers","image":[{"type":"Image","mediaType":"image/jpeg","url":"https://127.0.0.1/banner.jpg"}]}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into follows (id, follower, followed, accepted) values(?,?,?,1)`,
+		"https://localhost.localdomain:8443/follow/1",
+		server.Alice.ID,
+		"https://127.0.0.1/video-channels/cats",
+	)
+	assert.NoError(err)
+
+	server.Receive(
+		"https://127.0.0.1/accounts/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/videos/watch/1/activity","type":"Create","actor":"https://127.0.0.1/accounts/dan","object":{"id":"https://127.0.0.1/videos/watch/1","type":"Video","name":"Cats & dogs","duration":"PT83S","licence":{"identifier":"1","name":"Attribution"},"content":"Cute cats","attributedTo":[{"type":"Person","id":"https://127.0.0.1/accounts/dan"},{"type":"Group","id":"https://127.0.0.1/video-channels/cats"}],"url":[{"type":"Link","mediaType":"text/html","href":"https://127.0.0.1/w/1"},{"type":"Link","mediaType":"video/mp4","href":"https://127.0.0.1/1-720.mp4","height":720}],"published":"2025-01-02T03:04:05Z","to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["https://127.0.0.1/video-channels/cats/followers"]},"to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["https://127.0.0.1/video-channels/cats/followers"]}`,
+	)
+
+	view := server.Handle("/users/view/127.0.0.1/videos/watch/1", server.Alice)
+	assert.Contains(view, "# 📣 Video by cats\n")
+	assert.Contains(view, "> 🎞️ Video: Cats & dogs\n> ⏱️ Duration: 1:23\n> ⚖️ License: Attribution\n")
+	assert.Contains(view, "=> https://127.0.0.1/w/1 https://127.0.0.1/w/1\n")
+	assert.Contains(view, "=> https://127.0.0.1/1-720.mp4 🎞️ Video (video/mp4, 1:23)\n")
+
+	outbox := server.Handle("/users/outbox/127.0.0.1/video-channels/cats", server.Alice)
+	assert.Contains(outbox, "=> https://127.0.0.1/banner.jpg Header\n")
+	assert.Contains(outbox, "> 🎞️ Video: Cats & dogs (1:23)\n")
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	assert.Contains(server.Handle("/users", server.Alice), "> 🎞️ Video: Cats & dogs (1:23)\n")
+}
+
+func TestMedia_FunkwhaleListen(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?), (?,?)`,
+		"https://127.0.0.1/federation/actors/band",
+		`{"id":"https://127.0.0.1/federation/actors/band","type":"Person","preferredUsername":"band"}`,
+		"https://127.0.0.1/federation/actors/dan",
+		`{"id":"https://127.0.0.1/federation/actors/dan","type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	server.Receive(
+		"https://127.0.0.1/federation/actors/band",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/federation/music/uploads/1/activity","type":"Create","actor":"https://127.0.0.1/federation/actors/band","object":{"id":"https://127.0.0.1/federation/music/uploads/1","type":"Audio","name":"Song","duration":254,"license":"http://creativecommons.org/licenses/by/4.0/","attributedTo":"https://127.0.0.1/federation/actors/band","url":[{"type":"Link","mediaType":"text/html","href":"https://127.0.0.1/library/tracks/1"},{"type":"Link","mediaType":"audio/ogg","href":"https://127.0.0.1/1.ogg"}],"published":"2025-01-02T03:04:05Z","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+
+	view := server.Handle("/users/view/127.0.0.1/federation/music/uploads/1", server.Alice)
+	assert.Contains(view, "# 📣 Audio by band\n")
+	assert.Contains(view, "> 🔊 Audio: Song\n> ⏱️ Duration: 4:14\n> ⚖️ License: http://creativecommons.org/licenses/by/4.0/\n")
+	assert.Contains(view, "=> https://127.0.0.1/1.ogg 🔊 Audio (audio/ogg, 4:14)\n")
+
+	server.Receive(
+		"https://127.0.0.1/federation/actors/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/federation/listens/1","type":"Listen","actor":"https://127.0.0.1/federation/actors/dan","object":"https://127.0.0.1/federation/music/uploads/1","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+
+	var exists bool
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from shares where note = 'https://127.0.0.1/federation/music/uploads/1' and by = 'https://127.0.0.1/federation/actors/dan')`).Scan(&exists))
+	assert.False(exists)
+}
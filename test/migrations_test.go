@@ -0,0 +1,132 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/dimkr/tootik/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations_Downgrade(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	version, err := migrations.Version(context.Background(), server.db)
+	assert.NoError(err)
+	assert.Equal(migrations.Latest(), version)
+
+	assert.NoError(migrations.Migrate(context.Background(), domain, server.db, migrations.Latest()-16))
+
+	version, err = migrations.Version(context.Background(), server.db)
+	assert.NoError(err)
+	assert.Equal(migrations.Latest()-16, version)
+
+	var exists bool
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from sqlite_master where type = 'table' and name = 'seen')`).Scan(&exists))
+	assert.False(exists)
+
+	assert.NoError(migrations.Run(context.Background(), domain, server.db))
+
+	version, err = migrations.Version(context.Background(), server.db)
+	assert.NoError(err)
+	assert.Equal(migrations.Latest(), version)
+
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from sqlite_master where type = 'table' and name = 'seen')`).Scan(&exists))
+	assert.True(exists)
+}
+
+func TestMigrations_DowngradeForwardedActivities(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	// the same activity, forwarded by the same sender inside two different activities
+	_, err := server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $4), ($1, $3, $4)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/announce/1","type":"Announce"}`,
+		`{"id":"https://127.0.0.1/announce/2","type":"Announce"}`,
+		`{"id":"https://127.0.0.1/create/1","type":"Create"}`,
+	)
+	assert.NoError(err)
+
+	assert.NoError(migrations.Migrate(context.Background(), domain, server.db, migrations.Latest()-7))
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from inbox where sender = 'https://127.0.0.1/user/dan'`).Scan(&count))
+	assert.Equal(1, count)
+
+	assert.NoError(migrations.Run(context.Background(), domain, server.db))
+}
+
+func TestMigrations_Irreversible(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.ErrorIs(migrations.Migrate(context.Background(), domain, server.db, 0), migrations.ErrIrreversible)
+
+	// nothing is reverted if one of the migrations is irreversible
+	version, err := migrations.Version(context.Background(), server.db)
+	assert.NoError(err)
+	assert.Equal(migrations.Latest(), version)
+}
+
+func TestMigrations_NewerSchema(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(`insert into migrations(id) values('future')`)
+	assert.NoError(err)
+
+	assert.ErrorIs(migrations.Run(context.Background(), domain, server.db), migrations.ErrNewerSchema)
+}
+
+func TestMigrations_LookalikeNames(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.NoError(migrations.Migrate(context.Background(), domain, server.db, migrations.Latest()-4))
+
+	_, err := server.db.Exec(
+		`insert into persons(id, actor, inserted) values($1, json_object('id', $1, 'type', 'Person', 'preferredUsername', 'AIice'), unixepoch() + 60)`,
+		"https://"+domain+"/user/AIice",
+	)
+	assert.NoError(err)
+
+	assert.NoError(migrations.Run(context.Background(), domain, server.db))
+
+	// the older user keeps the name
+	var skeleton sql.NullString
+	assert.NoError(server.db.QueryRow(`select skeleton from persons where id = ?`, server.Alice.ID).Scan(&skeleton))
+	assert.Equal(sql.NullString{String: "alice", Valid: true}, skeleton)
+
+	assert.NoError(server.db.QueryRow(`select skeleton from persons where id = ?`, "https://"+domain+"/user/AIice").Scan(&skeleton))
+	assert.False(skeleton.Valid)
+}
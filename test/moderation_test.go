@@ -0,0 +1,148 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModeration_NotOwner(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`update persons set actor = json_set(actor, '$.type', 'Group') where id = $1`,
+		server.Alice.ID,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into communities(id, owner) values(?, ?)`, server.Alice.ID, server.Carol.ID)
+	assert.NoError(err)
+
+	assert.Equal("40 No such community\r\n", server.Handle("/users/communities/manage/alice", server.Bob))
+	assert.Equal("40 No such community\r\n", server.Handle("/users/communities/ban/alice?"+url.QueryEscape(server.Bob.ID), server.Bob))
+}
+
+func TestModeration_Ban(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`update persons set actor = json_set(actor, '$.type', 'Group') where id = $1`,
+		server.Alice.ID,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into communities(id, owner) values(?, ?)`, server.Alice.ID, server.Carol.ID)
+	assert.NoError(err)
+
+	follow := server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), follow)
+
+	outbox := server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Carol)
+	assert.Contains(strings.Split(outbox, "\n"), "=> /users/communities/manage/alice 🛡️ Moderate")
+
+	ban := server.Handle("/users/communities/ban/alice?"+url.QueryEscape(server.Bob.ID), server.Carol)
+	assert.Equal("30 /users/communities/manage/alice\r\n", ban)
+
+	manage := server.Handle("/users/communities/manage/alice", server.Carol)
+	assert.Contains(strings.Split(manage, "\n"), fmt.Sprintf("=> /users/communities/unban/alice?%s Unban bob (%s)", url.QueryEscape(server.Bob.ID), server.Bob.ID))
+
+	var following int
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from follows where follower = ? and followed = ?)`, server.Bob.ID, server.Alice.ID).Scan(&following))
+	assert.Equal(0, following)
+
+	follow = server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Equal("40 Banned from community\r\n", follow)
+
+	unban := server.Handle("/users/communities/unban/alice?"+url.QueryEscape(server.Bob.ID), server.Carol)
+	assert.Equal("30 /users/communities/manage/alice\r\n", unban)
+
+	follow = server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), follow)
+}
+
+func TestModeration_RemoveAndPin(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`update persons set actor = json_set(actor, '$.type', 'Group') where id = $1`,
+		server.Alice.ID,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into communities(id, owner) values(?, ?)`, server.Alice.ID, server.Carol.ID)
+	assert.NoError(err)
+
+	follow := server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), follow)
+
+	say := server.Handle("/users/say?Hello%20%40alice%40localhost.localdomain%3a8443", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	view := server.Handle("/users/view/"+id, server.Carol)
+	assert.Contains(strings.Split(view, "\n"), "=> /users/communities/pin/"+id+" 📌 Pin in community")
+	assert.Contains(strings.Split(view, "\n"), "=> /users/communities/remove/"+id+" 🧹 Remove from community")
+
+	view = server.Handle("/users/view/"+id, server.Bob)
+	assert.NotContains(view, "/users/communities/remove/")
+
+	pin := server.Handle("/users/communities/pin/"+id, server.Carol)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), pin)
+
+	outbox := server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Carol)
+	assert.Contains(strings.Split(outbox, "\n"), "## 📌 Pinned")
+
+	remove := server.Handle("/users/communities/remove/"+id, server.Carol)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), remove)
+
+	outbox = server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Carol)
+	assert.NotContains(strings.Split(outbox, "\n"), "## 📌 Pinned")
+	assert.NotContains(outbox, "Hello")
+
+	var removed int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Remove' and activity->>'$.object' = 'https://' || ? and sender = ?`, id, server.Alice.ID).Scan(&removed))
+	assert.Equal(1, removed)
+
+	var undone int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Undo' and activity->>'$.object.type' = 'Announce' and activity->>'$.object.object' = 'https://' || ? and sender = ?`, id, server.Alice.ID).Scan(&undone))
+	assert.Equal(1, undone)
+
+	follow = server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Carol)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), follow)
+
+	reply := server.Handle("/users/reply/"+id+"?Welcome", server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	var forwarded int
+	assert.NoError(server.db.QueryRow(`select count(*) from outbox where activity->>'$.type' = 'Announce' and activity->>'$.object.type' = 'Create' and activity->>'$.object.object.id' = 'https://' || ? and sender = ?`, reply[15:len(reply)-2], server.Alice.ID).Scan(&forwarded))
+	assert.Equal(0, forwarded)
+}
@@ -566,3 +566,76 @@ func TestMove_LocalToFederatedAlreadyMoved(t *testing.T) {
 	move = server.Handle("/users/move?alice%40%3a%3a1", server.Alice)
 	assert.Equal("40 Already moved to https://127.0.0.1/user/alice\r\n", move)
 }
+
+func TestMove_Aliases(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.MinActorEditInterval = 1
+	server.cfg.MaxAliases = 2
+
+	assert.Contains(server.Handle("/users/aliases", server.Alice), "# 🔗 Account Aliases\n\nNo aliases.\n\n=> /users/alias ➕ Add alias\n")
+
+	alias := server.Handle("/users/alias?bob%40localhost.localdomain%3a8443", server.Alice)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), alias)
+	assert.NoError(server.db.QueryRow(`select actor from persons where id = ?`, server.Alice.ID).Scan(&server.Alice))
+
+	alias = server.Handle("/users/alias?carol%40localhost.localdomain%3a8443", server.Alice)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Carol.ID, "https://")), alias)
+	assert.NoError(server.db.QueryRow(`select actor from persons where id = ?`, server.Alice.ID).Scan(&server.Alice))
+
+	assert.Equal([]string{server.Bob.ID, server.Carol.ID}, server.Alice.AlsoKnownAs.CollectKeys())
+
+	aliases := server.Handle("/users/aliases", server.Alice)
+	assert.Contains(aliases, "=> /users/aliases/remove/0 ➖ Remove "+server.Bob.ID+"\n")
+	assert.Contains(aliases, "=> /users/aliases/remove/1 ➖ Remove "+server.Carol.ID+"\n")
+	assert.NotContains(aliases, "Add alias")
+	assert.Contains(aliases, "=> /users/move 📦 Move account\n")
+
+	assert.Equal("40 Reached aliases limit\r\n", server.Handle("/users/alias?nobody%40localhost.localdomain%3a8443", server.Alice))
+
+	assert.Equal("40 No such alias\r\n", server.Handle("/users/aliases/remove/2", server.Alice))
+	assert.Equal("30 /users/aliases\r\n", server.Handle("/users/aliases/remove/0", server.Alice))
+	assert.NoError(server.db.QueryRow(`select actor from persons where id = ?`, server.Alice.ID).Scan(&server.Alice))
+
+	assert.Equal([]string{server.Carol.ID}, server.Alice.AlsoKnownAs.CollectKeys())
+}
+
+func TestMove_Frozen(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.MinActorEditInterval = 1
+
+	say := server.Handle("/users/say?Hello%20world", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	alias := server.Handle("/users/alias?bob%40localhost.localdomain%3a8443", server.Alice)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), alias)
+
+	alias = server.Handle("/users/alias?alice%40localhost.localdomain%3a8443", server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), alias)
+
+	assert.NoError(server.db.QueryRow(`select actor from persons where id = ?`, server.Alice.ID).Scan(&server.Alice))
+
+	move := server.Handle("/users/move?bob%40localhost.localdomain%3a8443", server.Alice)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), move)
+
+	assert.NoError(server.db.QueryRow(`select actor from persons where id = ?`, server.Alice.ID).Scan(&server.Alice))
+
+	assert.Equal(server.Bob.ID, server.Alice.MovedTo)
+	assert.Equal("40 Account was moved to "+server.Bob.ID+"\r\n", server.Handle("/users/say?Hello%20again", server.Alice))
+	assert.Equal("40 Account was moved to "+server.Bob.ID+"\r\n", server.Handle("/users/reply/"+say[15:len(say)-2]+"?Hi", server.Alice))
+	assert.Equal("40 Account was moved to "+server.Bob.ID+"\r\n", server.Handle("/users/share/"+say[15:len(say)-2], server.Alice))
+	assert.Equal("40 Account was moved to "+server.Bob.ID+"\r\n", server.Handle("/users/follow/"+strings.TrimPrefix(server.Carol.ID, "https://"), server.Alice))
+	assert.Equal("40 Account was moved to "+server.Bob.ID+"\r\n", server.Handle("/users/rsvp/accept/"+say[15:len(say)-2], server.Alice))
+	assert.Contains(server.Handle("/users/aliases", server.Alice), "This account was moved to "+server.Bob.ID+" and can no longer publish, edit or share posts, follow users or respond to events.\n")
+
+	var count int
+	assert.NoError(server.db.QueryRow(`select count(*) from notes where author = ?`, server.Alice.ID).Scan(&count))
+	assert.Equal(0, count)
+}
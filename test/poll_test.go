@@ -19,11 +19,16 @@ package test
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/front"
+	"github.com/dimkr/tootik/httpsig"
 	"github.com/dimkr/tootik/inbox"
 	"github.com/dimkr/tootik/outbox"
 	"github.com/stretchr/testify/assert"
@@ -951,3 +956,379 @@ func TestPoll_LocalSelfVote(t *testing.T) {
 	assert.Contains(strings.Split(view, "\n"), "1 ████████ Hell yeah!")
 	assert.Contains(strings.Split(view, "\n"), "1 ████████ I couldn't care less")
 }
+
+func TestPoll_Visibility(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	follow := server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), follow)
+
+	whisper := server.Handle("/users/whisper?%5bPOLL%20Cats%20or%20dogs%3f%5d%20Cats%20%7c%20Dogs", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, whisper)
+
+	view := server.Handle(whisper[3:len(whisper)-2], server.Bob)
+	assert.Contains(strings.Split(view, "\n"), "# 🔔 Poll by alice")
+
+	dm := server.Handle("/users/dm?%5bPOLL%20Cats%20or%20dogs%3f%5d%20Cats%20%7c%20Dogs%20%40carol%40localhost.localdomain%3a8443", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, dm)
+
+	view = server.Handle(dm[3:len(dm)-2], server.Carol)
+	assert.Contains(strings.Split(view, "\n"), "# 💌 Poll by bob")
+
+	view = server.Handle(dm[3:len(dm)-2], server.Alice)
+	assert.NotContains(view, "Cats or dogs?")
+}
+
+func TestPoll_LocalSingleChoice(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?%5bPOLL:one%20Vanilla%20or%20chocolate%3f%5d%20vanilla%20%7c%20chocolate", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var oneOf, anyOf int
+	assert.NoError(server.db.QueryRow(`select json_array_length(object->'$.oneOf'), coalesce(json_array_length(object->'$.anyOf'), 0) from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&oneOf, &anyOf))
+	assert.Equal(2, oneOf)
+	assert.Equal(0, anyOf)
+
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?vanilla", say[15:len(say)-2]), server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	_, err := server.db.Exec(`update outbox set inserted = inserted - 3600 where activity->>'$.type' = 'Create'`)
+	assert.NoError(err)
+
+	assert.Equal("40 Already voted\r\n", server.Handle(fmt.Sprintf("/users/reply/%s?chocolate", say[15:len(say)-2]), server.Bob))
+
+	poller := outbox.Poller{
+		Domain: domain,
+		DB:     server.db,
+	}
+	assert.NoError(poller.Run(context.Background()))
+
+	view := server.Handle(say[3:len(say)-2], server.Bob)
+	assert.Contains(view, "📊 Results (one voter)")
+	assert.Contains(strings.Split(view, "\n"), "1 ████████ vanilla")
+	assert.Contains(strings.Split(view, "\n"), "0          chocolate")
+	assert.NotContains(view, "Multiple choices allowed.")
+	assert.Contains(view, "Poll ends on ")
+}
+
+func TestPoll_LocalMultipleChoice(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?%5bPOLL%20Vanilla%20or%20chocolate%3f%5d%20vanilla%20%7c%20chocolate", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var anyOf int
+	assert.NoError(server.db.QueryRow(`select json_array_length(object->'$.anyOf') from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&anyOf))
+	assert.Equal(2, anyOf)
+
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?vanilla", say[15:len(say)-2]), server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	_, err := server.db.Exec(`update outbox set inserted = inserted - 3600 where activity->>'$.type' = 'Create'`)
+	assert.NoError(err)
+
+	reply = server.Handle(fmt.Sprintf("/users/reply/%s?chocolate", say[15:len(say)-2]), server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	reply = server.Handle(fmt.Sprintf("/users/reply/%s?chocolate", say[15:len(say)-2]), server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	poller := outbox.Poller{
+		Domain: domain,
+		DB:     server.db,
+	}
+	assert.NoError(poller.Run(context.Background()))
+
+	view := server.Handle(say[3:len(say)-2], server.Bob)
+	assert.Contains(view, "📊 Results (2 voters)")
+	assert.Contains(strings.Split(view, "\n"), "1 ████     vanilla")
+	assert.Contains(strings.Split(view, "\n"), "2 ████████ chocolate")
+	assert.Contains(view, "Multiple choices allowed.")
+}
+
+func TestPoll_LocalDuration(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?%5bPOLL:3h%20Vanilla%20or%20chocolate%3f%5d%20vanilla%20%7c%20chocolate", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	var duration int64
+	assert.NoError(server.db.QueryRow(`select unixepoch(object->>'$.endTime') - unixepoch(object->>'$.published') from notes where id = 'https://' || ?`, say[15:len(say)-2]).Scan(&duration))
+	assert.InDelta(3*60*60, duration, 1)
+}
+
+func TestPoll_LocalInvalidModifier(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("40 Invalid poll option: 3w\r\n", server.Handle("/users/say?%5bPOLL:3w%20Vanilla%20or%20chocolate%3f%5d%20vanilla%20%7c%20chocolate", server.Alice))
+}
+
+func TestPoll_LocalTooLong(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("40 Polls cannot last more than 720h0m0s\r\n", server.Handle("/users/say?%5bPOLL:any:31d%20Vanilla%20or%20chocolate%3f%5d%20vanilla%20%7c%20chocolate", server.Alice))
+}
+
+type pollResolver struct {
+	ap.Resolver
+	Poll     string
+	Requests int
+}
+
+func (r *pollResolver) Get(ctx context.Context, key httpsig.Key, url string) (*http.Response, error) {
+	r.Requests++
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(r.Poll))}, nil
+}
+
+func TestPoll_Refresh(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	poll := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/poll/1","type":"Question","attributedTo":"https://127.0.0.1/user/dan","content":"vanilla or chocolate?","oneOf":[{"type":"Note","name":"vanilla","replies":{"type":"Collection","totalItems":4}},{"type":"Note","name":"chocolate","replies":{"type":"Collection","totalItems":6}}],"votersCount":10,"endTime":"2099-10-01T05:35:36Z","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		poll,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	resolver := pollResolver{
+		Poll: `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/poll/1","type":"Question","attributedTo":"https://127.0.0.1/user/dan","content":"strawberry?","oneOf":[{"type":"Note","name":"vanilla","replies":{"type":"Collection","totalItems":2}},{"type":"Note","name":"chocolate","replies":{"type":"Collection","totalItems":10}}],"votersCount":12,"endTime":"2099-10-01T05:35:36Z","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	}
+
+	server.handler, err = front.NewHandler(domain, false, server.cfg, &resolver, server.db, server.db)
+	assert.NoError(err)
+
+	view := server.Handle("/users/view/127.0.0.1/poll/1", server.Alice)
+	assert.Contains(view, "=> /users/refresh/127.0.0.1/poll/1 🔄 Refresh results\n")
+	assert.NotContains(server.Handle("/view/127.0.0.1/poll/1", nil), "🔄 Refresh results")
+
+	// results were just received
+	assert.Equal("30 /users/view/127.0.0.1/poll/1\r\n", server.Handle("/users/refresh/127.0.0.1/poll/1", server.Alice))
+	assert.Equal(0, resolver.Requests)
+
+	_, err = server.db.Exec(`update notes set inserted = inserted - 3600 where id = 'https://127.0.0.1/poll/1'`)
+	assert.NoError(err)
+
+	assert.Equal("30 /users/view/127.0.0.1/poll/1\r\n", server.Handle("/users/refresh/127.0.0.1/poll/1", server.Alice))
+	assert.Equal(1, resolver.Requests)
+
+	view = server.Handle("/users/view/127.0.0.1/poll/1", server.Alice)
+	assert.Contains(view, "> vanilla or chocolate?\n")
+	assert.Contains(strings.Split(view, "\n"), "## 📊 Results (12 voters)")
+	assert.Contains(strings.Split(view, "\n"), "2  █▌       vanilla")
+	assert.Contains(strings.Split(view, "\n"), "10 ████████ chocolate")
+
+	assert.Equal("30 /users/view/127.0.0.1/poll/1\r\n", server.Handle("/users/refresh/127.0.0.1/poll/1", server.Alice))
+	assert.Equal(1, resolver.Requests)
+}
+
+func TestPoll_RefreshLocal(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?%5bPOLL%20Vanilla%20or%20chocolate%3f%5d%20vanilla%20%7c%20chocolate", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.NotContains(server.Handle(say[3:len(say)-2], server.Bob), "🔄 Refresh results")
+	assert.Equal("40 Poll not found\r\n", server.Handle("/users/refresh/"+say[15:len(say)-2], server.Bob))
+}
+
+func TestPoll_RefreshEnded(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	poll := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/poll/1","type":"Question","attributedTo":"https://127.0.0.1/user/dan","content":"vanilla or chocolate?","oneOf":[{"type":"Note","name":"vanilla","replies":{"type":"Collection","totalItems":4}},{"type":"Note","name":"chocolate","replies":{"type":"Collection","totalItems":6}}],"votersCount":10,"endTime":"2099-10-01T05:35:36Z","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		poll,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, server.Handle("/users/reply/127.0.0.1/poll/1?vanilla", server.Alice))
+
+	resolver := pollResolver{
+		Poll: `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/poll/1","type":"Question","attributedTo":"https://127.0.0.1/user/dan","content":"vanilla or chocolate?","oneOf":[{"type":"Note","name":"vanilla","replies":{"type":"Collection","totalItems":5}},{"type":"Note","name":"chocolate","replies":{"type":"Collection","totalItems":6}}],"votersCount":11,"endTime":"2020-10-01T05:35:36Z","closed":"2020-10-01T05:35:36Z","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	}
+
+	refresher := inbox.PollRefresher{
+		Domain:   domain,
+		Config:   server.cfg,
+		DB:       server.db,
+		Resolver: &resolver,
+		Key:      server.NobodyKey,
+	}
+
+	// the poll hasn't ended yet
+	assert.NoError(refresher.Run(context.Background()))
+	assert.Equal(0, resolver.Requests)
+
+	_, err = server.db.Exec(`update notes set object = json_set(object, '$.endTime', '2020-10-01T05:35:36Z') where id = 'https://127.0.0.1/poll/1'`)
+	assert.NoError(err)
+
+	assert.NoError(refresher.Run(context.Background()))
+	assert.Equal(1, resolver.Requests)
+
+	view := server.Handle("/users/view/127.0.0.1/poll/1", server.Alice)
+	assert.Contains(strings.Split(view, "\n"), "## 📊 Results (11 voters)")
+	assert.Contains(strings.Split(view, "\n"), "5 ██████▋  vanilla")
+	assert.Contains(strings.Split(view, "\n"), "6 ████████ chocolate")
+	assert.Contains(view, "Poll closed on 2020-10-01 05:35:36.")
+
+	// final results are fetched once
+	assert.NoError(refresher.Run(context.Background()))
+	assert.Equal(1, resolver.Requests)
+}
+
+func TestPoll_RefreshEndedRetry(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	poll := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/poll/1","type":"Question","attributedTo":"https://127.0.0.1/user/dan","content":"vanilla or chocolate?","oneOf":[{"type":"Note","name":"vanilla","replies":{"type":"Collection","totalItems":4}},{"type":"Note","name":"chocolate","replies":{"type":"Collection","totalItems":6}}],"votersCount":10,"endTime":"2099-10-01T05:35:36Z","to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		poll,
+	)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, server.Handle("/users/reply/127.0.0.1/poll/1?vanilla", server.Alice))
+
+	_, err = server.db.Exec(`update notes set object = json_set(object, '$.endTime', '2020-10-01T05:35:36Z') where id = 'https://127.0.0.1/poll/1'`)
+	assert.NoError(err)
+
+	// the response is empty, so the refresh fails
+	resolver := pollResolver{}
+
+	refresher := inbox.PollRefresher{
+		Domain:   domain,
+		Config:   server.cfg,
+		DB:       server.db,
+		Resolver: &resolver,
+		Key:      server.NobodyKey,
+	}
+
+	assert.NoError(refresher.Run(context.Background()))
+	assert.Equal(1, resolver.Requests)
+
+	// the failed refresh isn't retried immediately
+	assert.NoError(refresher.Run(context.Background()))
+	assert.Equal(1, resolver.Requests)
+
+	for i := 2; i <= server.cfg.MaxPollRefreshAttempts; i++ {
+		_, err = server.db.Exec(`update pollrefreshes set last = last - $1 * attempts where poll = 'https://127.0.0.1/poll/1'`, int64(server.cfg.PollRefreshRetryInterval/time.Second))
+		assert.NoError(err)
+
+		assert.NoError(refresher.Run(context.Background()))
+		assert.Equal(i, resolver.Requests)
+	}
+
+	// polls are refreshed a limited number of times
+	_, err = server.db.Exec(`update pollrefreshes set last = 0 where poll = 'https://127.0.0.1/poll/1'`)
+	assert.NoError(err)
+
+	assert.NoError(refresher.Run(context.Background()))
+	assert.Equal(server.cfg.MaxPollRefreshAttempts, resolver.Requests)
+
+	// a successful refresh forgets previous failures
+	_, err = server.db.Exec(`update pollrefreshes set attempts = 1 where poll = 'https://127.0.0.1/poll/1'`)
+	assert.NoError(err)
+
+	resolver.Poll = `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/poll/1","type":"Question","attributedTo":"https://127.0.0.1/user/dan","content":"vanilla or chocolate?","oneOf":[{"type":"Note","name":"vanilla","replies":{"type":"Collection","totalItems":5}},{"type":"Note","name":"chocolate","replies":{"type":"Collection","totalItems":6}}],"votersCount":11,"endTime":"2020-10-01T05:35:36Z","closed":"2020-10-01T05:35:36Z","to":["https://www.w3.org/ns/activitystreams#Public"]}`
+
+	assert.NoError(refresher.Run(context.Background()))
+	assert.Equal(server.cfg.MaxPollRefreshAttempts+1, resolver.Requests)
+	assert.Contains(strings.Split(server.Handle("/users/view/127.0.0.1/poll/1", server.Alice), "\n"), "## 📊 Results (11 voters)")
+
+	var attempts int
+	assert.NoError(server.db.QueryRow(`select count(*) from pollrefreshes`).Scan(&attempts))
+	assert.Equal(0, attempts)
+}
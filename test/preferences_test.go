@@ -0,0 +1,237 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/inbox/note"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferences_PageSize(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	for i := range 6 {
+		assert.NoError(
+			note.Insert(
+				context.Background(),
+				tx,
+				&ap.Object{
+					ID:           fmt.Sprintf("https://localhost.localdomain:8443/note/%d", i),
+					Type:         ap.Note,
+					AttributedTo: server.Alice.ID,
+					Content:      "hello",
+					To:           to,
+				},
+			),
+		)
+	}
+
+	assert.NoError(tx.Commit())
+
+	outbox := "/users/outbox/" + strings.TrimPrefix(server.Alice.ID, "https://")
+	assert.NotContains(server.Handle(outbox, server.Bob), "Next page")
+
+	assert.Equal("10 Posts per page (5-100, 0 for default)\r\n", server.Handle("/users/pagesize", server.Bob))
+	assert.Equal("40 Invalid number of posts\r\n", server.Handle("/users/pagesize?4", server.Bob))
+	assert.Equal("40 Invalid number of posts\r\n", server.Handle("/users/pagesize?101", server.Bob))
+	assert.Equal("40 Invalid number of posts\r\n", server.Handle("/users/pagesize?five", server.Bob))
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/pagesize?5", server.Bob))
+
+	page := server.Handle(outbox, server.Bob)
+	assert.Equal(5, strings.Count(page, "> hello\n"))
+	assert.Contains(page, "=> "+outbox+"?5 Next page (5-10)\n")
+	assert.Equal(1, strings.Count(server.Handle(outbox+"?5", server.Bob), "> hello\n"))
+
+	assert.NotContains(server.Handle(outbox, server.Carol), "Next page")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/pagesize?0", server.Bob))
+	assert.NotContains(server.Handle(outbox, server.Bob), "Next page")
+}
+
+func TestPreferences_HideShares(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+	assert.Equal(fmt.Sprintf("30 /users/view/%s\r\n", id), server.Handle("/users/share/"+id, server.Bob))
+
+	outbox := "/users/outbox/" + strings.TrimPrefix(server.Bob.ID, "https://")
+	assert.Contains(server.Handle(outbox, server.Carol), "> Hello world\n")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/shares/hide", server.Carol))
+	assert.NotContains(server.Handle(outbox, server.Carol), "> Hello world\n")
+	assert.Contains(server.Handle(outbox, server.Alice), "> Hello world\n")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/shares/show", server.Carol))
+	assert.Contains(server.Handle(outbox, server.Carol), "> Hello world\n")
+}
+
+func TestPreferences_HideSharesPageSize(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	for i := range 10 {
+		author := server.Bob.ID
+		if i >= 5 {
+			author = server.Alice.ID
+		}
+
+		assert.NoError(
+			note.Insert(
+				context.Background(),
+				tx,
+				&ap.Object{
+					ID:           fmt.Sprintf("https://localhost.localdomain:8443/note/%d", i),
+					Type:         ap.Note,
+					AttributedTo: author,
+					Content:      "hello " + author,
+					To:           to,
+				},
+			),
+		)
+
+		if i >= 5 {
+			_, err := tx.Exec(`insert into shares (note, by, inserted) values(?, ?, unixepoch() + 1)`, fmt.Sprintf("https://localhost.localdomain:8443/note/%d", i), server.Bob.ID)
+			assert.NoError(err)
+		}
+	}
+
+	assert.NoError(tx.Commit())
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/pagesize?5", server.Carol))
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/shares/hide", server.Carol))
+
+	outbox := "/users/outbox/" + strings.TrimPrefix(server.Bob.ID, "https://")
+	page := server.Handle(outbox, server.Carol)
+	assert.Equal(5, strings.Count(page, "> hello "+server.Bob.ID+"\n"))
+	assert.NotContains(page, "> hello "+server.Alice.ID+"\n")
+}
+
+func TestPreferences_RelativeTime(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	outbox := "/users/outbox/" + strings.TrimPrefix(server.Alice.ID, "https://")
+	assert.NotContains(server.Handle(outbox, server.Bob), "just now")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/timestamps/relative", server.Bob))
+	assert.Contains(server.Handle(outbox, server.Bob), " just now alice\n")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/timestamps/absolute", server.Bob))
+	assert.NotContains(server.Handle(outbox, server.Bob), "just now")
+}
+
+func TestPreferences_NoEmoji(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Contains(server.Handle("/users/settings", server.Alice), "# ⚙️ Settings\n")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/emoji/off", server.Alice))
+
+	settings := server.Handle("/users/settings", server.Alice)
+	assert.Contains(settings, "# Settings\n")
+	assert.Contains(settings, "=> /users/mentions [mentions] Mentions\n")
+	assert.Contains(settings, "=> /users/emoji/on Show emoji\n")
+	assert.NotContains(settings, "⚙️")
+
+	assert.Contains(server.Handle("/users/settings", server.Bob), "# ⚙️ Settings\n")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/emoji/on", server.Alice))
+	assert.Contains(server.Handle("/users/settings", server.Alice), "# ⚙️ Settings\n")
+}
+
+func TestPreferences_NoEmojiPreformatted(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/emoji/off", server.Alice))
+
+	server.receiveArticle(assert, `<p>📡 Intro ⭐ text</p><pre>a ┃ b 📡</pre>`)
+
+	view := server.Handle("/users/view/127.0.0.1/articles/1", server.Alice)
+	assert.Contains(view, "# [post] Article by dan\n")
+	assert.Contains(view, "\nIntro text\n")
+	assert.Contains(view, "```Preformatted text\na ┃ b 📡\n```\n")
+}
+
+func TestPreferences_TimeZone(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	_, err := server.db.Exec(`update notes set inserted = unixepoch('2024-01-01 12:00:00') where id = 'https://' || ?`, id)
+	assert.NoError(err)
+
+	assert.Equal("10 Time zone (for example, Europe/Berlin or UTC)\r\n", server.Handle("/users/timezone", server.Bob))
+	assert.Equal("40 Invalid time zone\r\n", server.Handle("/users/timezone?Mars/Olympus_Mons", server.Bob))
+
+	outbox := "/users/outbox/" + strings.TrimPrefix(server.Alice.ID, "https://")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/timezone?Pacific/Kiritimati", server.Bob))
+	assert.Contains(server.Handle(outbox, server.Bob), " 2024-01-02 alice\n")
+	assert.Contains(server.Handle("/users/thread/"+id, server.Bob), " 2024-01-02 alice\n")
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/timezone?Pacific/Pago_Pago", server.Bob))
+	assert.Contains(server.Handle(outbox, server.Bob), " 2024-01-01 alice\n")
+	assert.Contains(server.Handle("/users/thread/"+id, server.Bob), " 2024-01-01 alice\n")
+}
@@ -19,6 +19,7 @@ package test
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"fmt"
 	"io"
@@ -27,6 +28,7 @@ import (
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
 	"github.com/dimkr/tootik/cfg"
@@ -186,7 +188,7 @@ func TestRegister_RedirectNoCertificate(t *testing.T) {
 	_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users\r\n"))
 	assert.NoError(err)
 
-	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 	assert.NoError(err)
 
 	l := gemini.Listener{
@@ -269,7 +271,7 @@ func TestRegister_Redirect(t *testing.T) {
 	_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users\r\n"))
 	assert.NoError(err)
 
-	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 	assert.NoError(err)
 
 	l := gemini.Listener{
@@ -349,7 +351,7 @@ func TestRegister_NoCertificate(t *testing.T) {
 	_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users/register\r\n"))
 	assert.NoError(err)
 
-	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 	assert.NoError(err)
 
 	l := gemini.Listener{
@@ -432,7 +434,7 @@ func TestRegister_HappyFlow(t *testing.T) {
 	_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users/register\r\n"))
 	assert.NoError(err)
 
-	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 	assert.NoError(err)
 
 	l := gemini.Listener{
@@ -515,7 +517,7 @@ func TestRegister_HappyFlowRegistrationClosed(t *testing.T) {
 	_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users/register\r\n"))
 	assert.NoError(err)
 
-	handler, err := front.NewHandler(domain, true, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+	handler, err := front.NewHandler(domain, true, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 	assert.NoError(err)
 
 	l := gemini.Listener{
@@ -599,10 +601,10 @@ func TestRegister_AlreadyRegistered(t *testing.T) {
 	_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users/register\r\n"))
 	assert.NoError(err)
 
-	_, _, err = user.Create(context.Background(), domain, db, "erin", ap.Person, erinKeyPair.Leaf)
+	_, _, err = user.Create(context.Background(), domain, &cfg, db, "erin", ap.Person, erinKeyPair.Leaf)
 	assert.NoError(err)
 
-	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 	assert.NoError(err)
 
 	l := gemini.Listener{
@@ -691,7 +693,7 @@ func TestRegister_Twice(t *testing.T) {
 		_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users/register\r\n"))
 		assert.NoError(err)
 
-		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 		assert.NoError(err)
 
 		l := gemini.Listener{
@@ -790,7 +792,7 @@ func TestRegister_Throttling(t *testing.T) {
 		_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users/register\r\n"))
 		assert.NoError(err)
 
-		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 		assert.NoError(err)
 
 		l := gemini.Listener{
@@ -889,7 +891,7 @@ func TestRegister_Throttling30Minutes(t *testing.T) {
 		_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users/register\r\n"))
 		assert.NoError(err)
 
-		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 		assert.NoError(err)
 
 		l := gemini.Listener{
@@ -991,7 +993,7 @@ func TestRegister_Throttling1Hour(t *testing.T) {
 		_, err = tlsReader.Write([]byte("gemini://localhost.localdomain:8965/users/register\r\n"))
 		assert.NoError(err)
 
-		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 		assert.NoError(err)
 
 		l := gemini.Listener{
@@ -1109,7 +1111,7 @@ func TestRegister_TwoCertificates(t *testing.T) {
 		_, err = tlsReader.Write([]byte(data.url))
 		assert.NoError(err)
 
-		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+		handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 		assert.NoError(err)
 
 		l := gemini.Listener{
@@ -1128,3 +1130,120 @@ func TestRegister_TwoCertificates(t *testing.T) {
 		assert.Regexp(data.expected, string(resp))
 	}
 }
+
+func TestRegister_Invitation(t *testing.T) {
+	assert := assert.New(t)
+
+	dbPath := fmt.Sprintf("/tmp/%s.sqlite3?_journal_mode=WAL", t.Name())
+	defer os.Remove(fmt.Sprintf("/tmp/%s.sqlite3", t.Name()))
+	db, err := sql.Open("sqlite3", dbPath)
+	assert.NoError(err)
+
+	var cfg cfg.Config
+	cfg.FillDefaults()
+	cfg.RequireInvitation = true
+
+	assert.NoError(migrations.Run(context.Background(), domain, db))
+
+	serverKeyPair, err := tls.X509KeyPair([]byte(serverCert), []byte(serverKey))
+	assert.NoError(err)
+
+	serverCfg := tls.Config{
+		Certificates: []tls.Certificate{serverKeyPair},
+		MinVersion:   tls.VersionTLS12,
+		ClientAuth:   tls.RequestClientCert,
+	}
+
+	erinKeyPair, err := tls.X509KeyPair([]byte(erinCert), []byte(erinKey))
+	assert.NoError(err)
+
+	clientCfg := tls.Config{
+		Certificates:       []tls.Certificate{erinKeyPair},
+		InsecureSkipVerify: true,
+	}
+
+	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
+	assert.NoError(err)
+
+	l := gemini.Listener{
+		Domain:  domain,
+		Config:  &cfg,
+		Handler: handler,
+		DB:      db,
+	}
+
+	socketPath := fmt.Sprintf("/tmp/%s.socket", t.Name())
+
+	localListener, err := net.Listen("unix", socketPath)
+	assert.NoError(err)
+	defer os.Remove(socketPath)
+
+	tlsListener := tls.NewListener(localListener, &serverCfg)
+	defer tlsListener.Close()
+
+	register := func(request string) string {
+		unixReader, err := net.Dial("unix", socketPath)
+		assert.NoError(err)
+		defer unixReader.Close()
+
+		tlsWriter, err := tlsListener.Accept()
+		assert.NoError(err)
+
+		tlsReader := tls.Client(unixReader, &clientCfg)
+		defer tlsReader.Close()
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			assert.NoError(tlsReader.Handshake())
+			wg.Done()
+		}()
+		go func() {
+			assert.NoError(tlsWriter.(*tls.Conn).Handshake())
+			wg.Done()
+		}()
+		wg.Wait()
+
+		_, err = tlsReader.Write([]byte(request))
+		assert.NoError(err)
+
+		l.Handle(context.Background(), tlsWriter)
+
+		tlsWriter.Close()
+
+		resp, err := io.ReadAll(tlsReader)
+		assert.NoError(err)
+
+		return string(resp)
+	}
+
+	assert.Equal("10 Invitation code\r\n", register("gemini://localhost.localdomain:8965/users/register\r\n"))
+	assert.Equal("40 Invalid invitation code\r\n", register("gemini://localhost.localdomain:8965/users/register?abcd\r\n"))
+
+	_, err = db.Exec(`insert into invitations(code, inviter) values('abcd', 'https://localhost.localdomain:8443/user/alice')`)
+	assert.NoError(err)
+
+	assert.Equal("30 /users\r\n", register("gemini://localhost.localdomain:8965/users/register?abcd\r\n"))
+
+	var invited string
+	assert.NoError(db.QueryRow(`select invited from invitations where code = 'abcd'`).Scan(&invited))
+	assert.Equal("erin", invited)
+}
+
+func TestRegister_LookalikeRace(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	// Alice passes CheckName before alice is inserted
+	_, _, err := user.Create(context.Background(), domain, server.cfg, server.db, "Alice", ap.Person, nil)
+	assert.ErrorIs(err, user.ErrNameTaken)
+
+	_, _, err = user.Create(context.Background(), domain, server.cfg, server.db, "AIice", ap.Person, &x509.Certificate{Raw: []byte("AIice"), NotAfter: time.Now().Add(time.Hour)})
+	assert.ErrorIs(err, user.ErrNameTaken)
+
+	var exists bool
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from certificates where user = 'AIice')`).Scan(&exists))
+	assert.False(exists)
+}
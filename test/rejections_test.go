@@ -0,0 +1,86 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejections_NotAdmin(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("40 Forbidden\r\n", server.Handle("/users/admin/rejections", server.Alice))
+	assert.Equal("30 /users\r\n", server.Handle("/users/admin/rejections", nil))
+}
+
+func TestRejections_HappyFlow(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.Admins = []string{server.Alice.ID}
+
+	assert.Contains(server.Handle("/users/admin/rejections", server.Alice), "No rejected activities.\n")
+
+	_, err := server.db.Exec(`insert into rejections(activity, sender, host, client, reason, error) values('https://ip6-allnodes/create/1', 'https://ip6-allnodes/user/dan', 'ip6-allnodes', '127.0.0.1:1234', 'signature', 'failed to verify message: wrong host: 127.0.0.1:8443')`)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(`insert into rejections(activity, sender, host, client, reason, error, verified) values('https://ip6-allnodes/create/2', 'https://ip6-allnodes/user/dan', 'ip6-allnodes', '127.0.0.1:1234', 'invalid', 'invalid object host: 1.1.1.1', 1)`)
+	assert.NoError(err)
+
+	rejections := server.Handle("/users/admin/rejections", server.Alice)
+	assert.Contains(rejections, "## ip6-allnodes (unverified): Invalid signature\n")
+	assert.Contains(rejections, "* Activity: https://ip6-allnodes/create/1\n")
+	assert.Contains(rejections, "* Error: failed to verify message: wrong host: 127.0.0.1:8443\n")
+	assert.Contains(rejections, "## ip6-allnodes: Invalid activity\n")
+	assert.Contains(rejections, "* Error: invalid object host: 1.1.1.1\n")
+	assert.Less(strings.Index(rejections, "Invalid activity"), strings.Index(rejections, "Invalid signature"))
+
+	assert.Contains(server.Handle("/users/admin/federation", server.Alice), "=> /users/admin/rejections 🚫 Rejected activities\n")
+}
+
+func TestRejections_Gemtext(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.Admins = []string{server.Alice.ID}
+
+	_, err := server.db.Exec(
+		`insert into rejections(activity, sender, host, client, reason, error) values(?, ?, 'ip6-allnodes', '127.0.0.1:1234', 'signature', ?)`,
+		"https://ip6-allnodes/create/1\n=> gemini://evil.localdomain Click me",
+		"\n# Hacked",
+		"failed to verify message\r\n```",
+	)
+	assert.NoError(err)
+
+	rejections := server.Handle("/users/admin/rejections", server.Alice)
+	assert.Contains(rejections, "* Activity: https://ip6-allnodes/create/1 => gemini://evil.localdomain Click me\n")
+	assert.Contains(rejections, "* Sender: Hacked\n")
+	assert.Contains(rejections, "* Error: failed to verify message ```\n")
+	assert.NotContains(rejections, "\n=> gemini://evil.localdomain")
+	assert.NotContains(rejections, "\n# Hacked")
+	assert.NotContains(rejections, "\n```")
+}
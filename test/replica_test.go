@@ -0,0 +1,65 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/dimkr/tootik/data"
+	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/front"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplica_ReadOnlyPages(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	// the replica is a snapshot of the database, so it doesn't see later writes
+	replicaPath := server.dbPath + ".replica"
+	assert.NoError(data.Backup(context.Background(), server.db, replicaPath))
+	defer os.Remove(replicaPath)
+
+	replica, err := sql.Open("sqlite3", "file:"+replicaPath+"?"+server.cfg.ReadDatabaseOptions)
+	assert.NoError(err)
+	defer replica.Close()
+
+	server.handler, err = front.NewHandler(domain, false, server.cfg, fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db), server.db, replica)
+	assert.NoError(err)
+
+	server.cfg.PostThrottleUnit = 0
+	say = server.Handle("/users/say?Hello%20again", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	view := server.Handle(say[3:len(say)-2], server.Alice)
+	assert.Contains(view, "Hello again")
+
+	assert.Contains(server.Handle(say[9:len(say)-2], nil), "40 Post not found")
+
+	local := server.Handle("/local", nil)
+	assert.Contains(local, "Hello world")
+	assert.NotContains(local, "Hello again")
+}
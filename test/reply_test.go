@@ -518,3 +518,183 @@ func TestReply_NoSuchPost(t *testing.T) {
 	reply := server.Handle("/users/reply/x?Welcome%%20Bob", server.Alice)
 	assert.Equal("40 Post not found\r\n", reply)
 }
+
+func TestReply_RestrictedToFollowers(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/replies/followers", server.Alice))
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	var policy string
+	assert.NoError(server.db.QueryRow(`select object->>'$.interactionPolicy' from notes where id = ?`, "https://"+id).Scan(&policy))
+	assert.Equal(`{"canReply":{"always":["`+server.Alice.Followers+`"],"approvalRequired":[]}}`, policy)
+
+	view := server.Handle("/users/view/"+id, server.Carol)
+	assert.Contains(view, "Hello world")
+	assert.NotContains(view, "💬 Reply")
+
+	assert.Equal("40 Replies are restricted\r\n", server.Handle(fmt.Sprintf("/users/reply/%s?Welcome%%20Alice", id), server.Carol))
+
+	follow := server.Handle("/users/follow/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Carol)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), follow)
+
+	assert.Contains(server.Handle("/users/view/"+id, server.Carol), "💬 Reply")
+
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?Welcome%%20Alice", id), server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	reply = server.Handle(fmt.Sprintf("/users/reply/%s?Thanks", id), server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/replies/everyone", server.Alice))
+
+	say = server.Handle("/users/say?Hello%20again", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id = say[15 : len(say)-2]
+
+	var restricted bool
+	assert.NoError(server.db.QueryRow(`select object->>'$.interactionPolicy' is not null from notes where id = ?`, "https://"+id).Scan(&restricted))
+	assert.False(restricted)
+
+	assert.Contains(server.Handle("/users/view/"+id, server.Bob), "💬 Reply")
+}
+
+func TestReply_RestrictedToMentioned(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/replies/mentioned", server.Alice))
+
+	say := server.Handle("/users/say?Hello%20%40bob", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	assert.NotContains(server.Handle("/users/view/"+id, server.Carol), "💬 Reply")
+	assert.Equal("40 Replies are restricted\r\n", server.Handle(fmt.Sprintf("/users/reply/%s?Hi", id), server.Carol))
+
+	assert.Contains(server.Handle("/users/view/"+id, server.Bob), "💬 Reply")
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?Hi", id), server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, reply)
+}
+
+func TestReply_RestrictedRemoteReply(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/replies/followers", server.Alice))
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?), (?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan"}`,
+		"https://127.0.0.1/user/erin",
+		`{"id":"https://127.0.0.1/user/erin","type":"Person","preferredUsername":"erin"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into follows (id, follower, followed, accepted) values(?,?,?,1)`,
+		"https://127.0.0.1/follow/1",
+		"https://127.0.0.1/user/erin",
+		server.Alice.ID,
+	)
+	assert.NoError(err)
+
+	server.Receive(
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","inReplyTo":"https://`+id+`","content":"Hi from dan","to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["`+server.Alice.ID+`"]},"to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["`+server.Alice.ID+`"]}`,
+	)
+
+	server.Receive(
+		"https://127.0.0.1/user/erin",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/2","type":"Create","actor":"https://127.0.0.1/user/erin","object":{"id":"https://127.0.0.1/note/2","type":"Note","attributedTo":"https://127.0.0.1/user/erin","inReplyTo":"https://`+id+`","content":"Hi from erin","to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["`+server.Alice.ID+`"]},"to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["`+server.Alice.ID+`"]}`,
+	)
+
+	view := server.Handle("/users/view/"+id, server.Alice)
+	assert.NotContains(view, "Hi from dan")
+	assert.Contains(view, "Hi from erin")
+}
+
+func TestReply_RestrictedRemotePollVote(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/replies/followers", server.Alice))
+
+	say := server.Handle("/users/say?%5BPOLL%20Favorite%20color%5D%20red%7Cgreen", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	server.Receive(
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","inReplyTo":"https://`+id+`","name":"green","to":["`+server.Alice.ID+`"]},"to":["`+server.Alice.ID+`"]}`,
+	)
+
+	server.Receive(
+		"https://127.0.0.1/user/dan",
+		`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/2","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/2","type":"Note","attributedTo":"https://127.0.0.1/user/dan","inReplyTo":"https://`+id+`","content":"Hi from dan","to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["`+server.Alice.ID+`"]},"to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["`+server.Alice.ID+`"]}`,
+	)
+
+	var vote, reply bool
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from notes where id = 'https://127.0.0.1/note/1'), exists (select 1 from notes where id = 'https://127.0.0.1/note/2')`).Scan(&vote, &reply))
+	assert.True(vote)
+	assert.False(reply)
+}
+
+func TestReply_RemotePostInteractionPolicy(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public) values(?, 'https://127.0.0.1/user/dan', ?, 1), (?, 'https://127.0.0.1/user/dan', ?, 1)`,
+		"https://127.0.0.1/note/1",
+		`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Followers only","to":["https://www.w3.org/ns/activitystreams#Public"],"interactionPolicy":{"canReply":{"always":["https://127.0.0.1/followers/dan"],"approvalRequired":[]}}}`,
+		"https://127.0.0.1/note/2",
+		`{"id":"https://127.0.0.1/note/2","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"With approval","to":["https://www.w3.org/ns/activitystreams#Public"],"interactionPolicy":{"canReply":{"always":["https://127.0.0.1/user/dan"],"approvalRequired":["https://www.w3.org/ns/activitystreams#Public"]}}}`,
+	)
+	assert.NoError(err)
+
+	assert.NotContains(server.Handle("/users/view/127.0.0.1/note/1", server.Bob), "💬 Reply")
+	assert.Equal("40 Replies are restricted\r\n", server.Handle("/users/reply/127.0.0.1/note/1?Hi", server.Bob))
+
+	assert.Contains(strings.Split(server.Handle("/users/view/127.0.0.1/note/2", server.Bob), "\n"), "=> /users/reply/127.0.0.1/note/2 💬 Reply (requires approval)")
+}
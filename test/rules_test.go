@@ -0,0 +1,100 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRules_NoRules(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Contains(server.Handle("/rules", nil), "This server has no rules.")
+	assert.NotContains(server.Handle("/help", nil), "📜 Rules")
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+}
+
+func TestRules_AcceptBeforePosting(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.Rules = []string{"Be nice", "No spam"}
+	server.cfg.RulesVersion = "1"
+
+	rules := server.Handle("/rules", nil)
+	assert.Contains(rules, "* Be nice\n* No spam\n")
+	assert.NotContains(rules, "Accept rules")
+
+	assert.Equal("30 /users/rules\r\n", server.Handle("/users/say?Hello%20world", server.Alice))
+
+	rules = server.Handle("/users/rules", server.Alice)
+	assert.Contains(rules, "* Be nice\n* No spam\n")
+	assert.Contains(rules, "=> /users/rules/accept ✅ Accept rules\n")
+
+	assert.Equal("30 /users\r\n", server.Handle("/users/rules/accept", server.Alice))
+
+	rules = server.Handle("/users/rules", server.Alice)
+	assert.NotContains(rules, "Accept rules")
+	assert.Contains(rules, "You accepted these rules on ")
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.Equal("30 /users/rules\r\n", server.Handle("/users/say?Hello%20world", server.Bob))
+}
+
+func TestRules_NewVersion(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostThrottleUnit = 0
+	server.cfg.Rules = []string{"Be nice"}
+	server.cfg.RulesVersion = "1"
+
+	assert.Equal("30 /users\r\n", server.Handle("/users/rules/accept", server.Alice))
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	server.cfg.Rules = append(server.cfg.Rules, "No spam")
+	server.cfg.RulesVersion = "2"
+
+	assert.Equal("30 /users/rules\r\n", server.Handle("/users/say?Hello%20again", server.Alice))
+
+	var versions int
+	assert.NoError(server.db.QueryRow(`select count(*) from rulesacceptance where actor = ?`, server.Alice.ID).Scan(&versions))
+	assert.Equal(1, versions)
+
+	assert.Equal("30 /users\r\n", server.Handle("/users/rules/accept", server.Alice))
+
+	say = server.Handle("/users/say?Hello%20again", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.Equal(2, strings.Count(server.Handle("/users/outbox/"+strings.TrimPrefix(server.Alice.ID, "https://"), server.Bob), "> Hello"))
+}
@@ -68,3 +68,23 @@ func TestSay_Throttling(t *testing.T) {
 	assert.Contains(local, "Hello world")
 	assert.NotContains(local, "Hello once more, world")
 }
+
+func TestSay_Replies(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := "https://" + say[15:len(say)-2]
+
+	var replies string
+	assert.NoError(server.db.QueryRow(`select object->>'$.replies' from notes where id = ?`, id).Scan(&replies))
+	assert.Equal(strings.Replace(id, "/post/", "/replies/", 1), replies)
+
+	var create string
+	assert.NoError(server.db.QueryRow(`select activity->>'$.object.replies' from outbox where activity->>'$.object.id' = ? and activity->>'$.type' = 'Create'`, id).Scan(&create))
+	assert.Equal(replies, create)
+}
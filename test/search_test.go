@@ -91,3 +91,43 @@ func TestSearch_UnathenticatedUser(t *testing.T) {
 	search := server.Handle("/search?world", nil)
 	assert.Equal("30 /hashtag/world\r\n", search)
 }
+
+func TestSearch_GeminiURL(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	assert.Equal("30 /users/view/"+id+"\r\n", server.Handle("/users/search?gemini://"+domain+"/view/"+id, server.Bob))
+	assert.Equal("30 /users/view/"+id+"\r\n", server.Handle("/users/search?gemini://"+domain+"/users/view/"+id, server.Bob))
+	assert.Equal("30 /view/"+id+"\r\n", server.Handle("/search?https://"+id, nil))
+}
+
+func TestSearch_UnknownURL(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal("40 Post not found\r\n", server.Handle("/users/search?https://127.0.0.1/post/1", server.Bob))
+	assert.Equal("40 Post not found\r\n", server.Handle("/users/search?gemini://127.0.0.1/view/127.0.0.1/post/1", server.Bob))
+}
+
+func TestSearch_RemoteURL(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into notes (id, author, object, public) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', '{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"hello","url":"https://127.0.0.1/@dan/1","to":["https://www.w3.org/ns/activitystreams#Public"]}', 1)`,
+	)
+	assert.NoError(err)
+
+	assert.Equal("30 /users/view/127.0.0.1/note/1\r\n", server.Handle("/users/search?https://127.0.0.1/@dan/1", server.Bob))
+}
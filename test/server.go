@@ -32,6 +32,7 @@ import (
 	"github.com/dimkr/tootik/front/text/gmi"
 	"github.com/dimkr/tootik/front/user"
 	"github.com/dimkr/tootik/httpsig"
+	"github.com/dimkr/tootik/inbox"
 	"github.com/dimkr/tootik/migrations"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -75,27 +76,27 @@ func newTestServer() *server {
 		panic(err)
 	}
 
-	alice, _, err := user.Create(context.Background(), domain, db, "alice", ap.Person, nil)
+	alice, _, err := user.Create(context.Background(), domain, &cfg, db, "alice", ap.Person, nil)
 	if err != nil {
 		panic(err)
 	}
 
-	bob, _, err := user.Create(context.Background(), domain, db, "bob", ap.Person, nil)
+	bob, _, err := user.Create(context.Background(), domain, &cfg, db, "bob", ap.Person, nil)
 	if err != nil {
 		panic(err)
 	}
 
-	carol, _, err := user.Create(context.Background(), domain, db, "carol", ap.Person, nil)
+	carol, _, err := user.Create(context.Background(), domain, &cfg, db, "carol", ap.Person, nil)
 	if err != nil {
 		panic(err)
 	}
 
-	_, nobodyKey, err := user.CreateNobody(context.Background(), domain, db)
+	_, nobodyKey, err := user.CreateNobody(context.Background(), domain, &cfg, db)
 	if err != nil {
 		panic(err)
 	}
 
-	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db)
+	handler, err := front.NewHandler(domain, false, &cfg, fed.NewResolver(nil, domain, &cfg, &http.Client{}, db), db, db)
 	if err != nil {
 		panic(err)
 	}
@@ -157,3 +158,29 @@ func (s *server) Upload(request string, user *ap.Actor, body []byte) string {
 
 	return buf.String()
 }
+
+// Receive queues an activity sent by sender and processes it.
+func (s *server) Receive(sender, activity string) {
+	if _, err := s.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		sender,
+		activity,
+	); err != nil {
+		panic(err)
+	}
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    s.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        s.db,
+		Resolver:  fed.NewResolver(nil, domain, s.cfg, &http.Client{}, s.db),
+		Key:       s.NobodyKey,
+		Cache:     &s.handler,
+	}
+	if n, err := queue.ProcessBatch(context.Background()); err != nil {
+		panic(err)
+	} else if n != 1 {
+		panic("activity was not processed")
+	}
+}
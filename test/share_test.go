@@ -193,3 +193,73 @@ func TestShare_ShareAfterUnshare(t *testing.T) {
 	outbox = strings.Split(server.Handle("/users/outbox/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Carol), "\n")
 	assert.Contains(outbox, "> Hello world")
 }
+
+func TestShare_FollowersOnly(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	share := server.Handle("/users/share/followers/"+id, server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/view/%s\r\n", id), share)
+
+	var public int
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from outbox where activity->>'$.type' = 'Announce' and activity->>'$.actor' = ? and exists (select 1 from json_each(activity->'$.to') where value = 'https://www.w3.org/ns/activitystreams#Public'))`, server.Bob.ID).Scan(&public))
+	assert.Equal(0, public)
+
+	outbox := strings.Split(server.Handle("/users/outbox/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Carol), "\n")
+	assert.NotContains(outbox, "> Hello world")
+
+	view := strings.Split(server.Handle("/users/view/"+id, server.Carol), "\n")
+	assert.NotContains(view, "=> /users/outbox/"+strings.TrimPrefix(server.Bob.ID, "https://")+" 🔄 bob")
+
+	follow := server.Handle("/users/follow/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Carol)
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), follow)
+
+	outbox = strings.Split(server.Handle("/users/outbox/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Carol), "\n")
+	assert.Contains(outbox, "> Hello world")
+
+	view = strings.Split(server.Handle("/users/view/"+id, server.Carol), "\n")
+	assert.Contains(view, "=> /users/outbox/"+strings.TrimPrefix(server.Bob.ID, "https://")+" 🔄 bob")
+
+	unshare := server.Handle("/users/unshare/"+id, server.Bob)
+	assert.Equal(fmt.Sprintf("30 /users/view/%s\r\n", id), unshare)
+
+	assert.NoError(server.db.QueryRow(`select exists (select 1 from outbox where activity->>'$.type' = 'Undo' and activity->>'$.actor' = ? and exists (select 1 from json_each(activity->'$.to') where value = 'https://www.w3.org/ns/activitystreams#Public'))`, server.Bob.ID).Scan(&public))
+	assert.Equal(0, public)
+}
+
+func TestShare_SilencedDomain(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', '{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello world","to":["https://www.w3.org/ns/activitystreams#Public"]}', 1)`,
+	)
+	assert.NoError(err)
+
+	share := server.Handle("/users/share/127.0.0.1/note/1", server.Bob)
+	assert.Equal("30 /users/view/127.0.0.1/note/1\r\n", share)
+
+	_, err = server.db.Exec(`insert into domainblocks (host, severity) values('127.0.0.1', 'silence')`)
+	assert.NoError(err)
+
+	outbox := strings.Split(server.Handle("/users/outbox/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Carol), "\n")
+	assert.Contains(outbox, "> Hello world")
+
+	assert.NotContains(strings.Split(server.Handle("/users/local", server.Carol), "\n"), "> Hello world")
+}
@@ -21,8 +21,10 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dimkr/tootik/ap"
+	"github.com/dimkr/tootik/data"
 	"github.com/dimkr/tootik/inbox/note"
 	"github.com/stretchr/testify/assert"
 )
@@ -458,3 +460,177 @@ func TestThread_SubTree(t *testing.T) {
 	assert.Contains(thread, " ·· carol")
 	assert.Contains(strings.Split(thread, "\n"), "=> /view/localhost.localdomain:8443/note/1 View first post in thread")
 }
+
+func TestThread_Collapsed(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.ThreadMaxDepth = 2
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	// 1 <- 2 <- 3 <- 4 <- 5, 4 <- 6
+	for i, parent := range []string{"", "1", "2", "3", "4", "4"} {
+		reply := ap.Object{
+			ID:           fmt.Sprintf("https://localhost.localdomain:8443/note/%d", i+1),
+			Type:         ap.Note,
+			AttributedTo: server.Alice.ID,
+			Content:      "hello",
+			To:           to,
+		}
+		if parent != "" {
+			reply.InReplyTo = "https://localhost.localdomain:8443/note/" + parent
+		}
+		assert.NoError(note.Insert(context.Background(), tx, &reply))
+	}
+
+	assert.NoError(tx.Commit())
+
+	thread := server.Handle("/users/thread/localhost.localdomain:8443/note/1", server.Bob)
+	assert.Contains(thread, " ·· alice\n")
+	assert.NotContains(thread, " ··· alice\n")
+	assert.Regexp(`=> /users/thread/localhost.localdomain:8443/note/3 \S+ ··· ↳ 3 more replies\n`, thread)
+
+	expanded := server.Handle("/users/thread/localhost.localdomain:8443/note/3", server.Bob)
+	assert.Contains(expanded, " ·· alice\n")
+	assert.NotContains(expanded, "more replies")
+	assert.Contains(strings.Split(expanded, "\n"), "=> /users/view/localhost.localdomain:8443/note/1 View first post in thread")
+
+	view := server.Handle("/users/view/localhost.localdomain:8443/note/3", server.Bob)
+	assert.Contains(view, "# 💬 Reply by alice\n\n=> /users/view/localhost.localdomain:8443/note/2 View parent post\n=> /users/view/localhost.localdomain:8443/note/1 View first post in thread\n\n")
+}
+
+func TestThread_Subtrees(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.PostsPerPage = 2
+
+	tx, err := server.db.BeginTx(context.Background(), nil)
+	assert.NoError(err)
+	defer tx.Rollback()
+
+	to := ap.Audience{}
+	to.Add(ap.Public)
+
+	// 1 <- 2 <- 3, 1 <- 4 <- 5, 1 <- 6
+	for i, parent := range []string{"", "1", "2", "1", "4", "1"} {
+		reply := ap.Object{
+			ID:           fmt.Sprintf("https://localhost.localdomain:8443/note/%d", i+1),
+			Type:         ap.Note,
+			AttributedTo: server.Alice.ID,
+			Content:      "hello",
+			To:           to,
+		}
+		if parent != "" {
+			reply.InReplyTo = "https://localhost.localdomain:8443/note/" + parent
+		}
+		assert.NoError(note.Insert(context.Background(), tx, &reply))
+	}
+
+	_, err = tx.Exec(`update notes set inserted = inserted + cast(substr(id, 41) as integer)`)
+	assert.NoError(err)
+	assert.NoError(tx.Commit())
+
+	first := server.Handle("/thread/localhost.localdomain:8443/note/1", nil)
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		assert.Contains(first, "=> /view/localhost.localdomain:8443/note/"+id+" ")
+	}
+	assert.NotContains(first, "note/6 ")
+	assert.Contains(first, "=> /thread/localhost.localdomain:8443/note/1?2 Next page (2-4)\n")
+
+	second := server.Handle("/thread/localhost.localdomain:8443/note/1?2", nil)
+	assert.Contains(second, "=> /view/localhost.localdomain:8443/note/6 ")
+	assert.NotContains(second, "note/1 ")
+	assert.NotContains(second, "note/5 ")
+	assert.NotContains(second, "Next page")
+	assert.Contains(second, "=> /thread/localhost.localdomain:8443/note/1?0 Previous page (0-2)\n")
+}
+
+func TestThread_DeletedReply(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n`, say)
+
+	id := say[15 : len(say)-2]
+
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?Welcome%%20Bob", id), server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n`, reply)
+
+	replyID := reply[15 : len(reply)-2]
+
+	nested := server.Handle(fmt.Sprintf("/users/reply/%s?Hi%%20Bob", replyID), server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n`, nested)
+
+	nestedID := nested[15 : len(nested)-2]
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Alice.ID, "https://")), server.Handle("/users/delete/"+replyID, server.Alice))
+
+	lines := strings.Split(server.Handle("/users/thread/"+id, server.Alice), "\n")
+	assert.Equal("# 🧵 Replies to 😈 bob (bob@localhost.localdomain:8443)", lines[1])
+	assert.Regexp(fmt.Sprintf(`^=> /users/view/%s \S+ bob$`, id), lines[3])
+	assert.Regexp(`^\S+ · \[deleted\]$`, lines[4])
+	assert.Regexp(fmt.Sprintf(`^=> /users/view/%s \S+ ·· carol$`, nestedID), lines[5])
+
+	lines = strings.Split(server.Handle("/users/thread/"+replyID, server.Alice), "\n")
+	assert.Equal("# 🧵 Replies to [deleted]", lines[1])
+	assert.Regexp(`^\S+ \[deleted\]$`, lines[3])
+	assert.Regexp(fmt.Sprintf(`^=> /users/view/%s \S+ · carol$`, nestedID), lines[4])
+	assert.Contains(lines, fmt.Sprintf("=> /users/view/%s View first post in thread", id))
+
+	lines = strings.Split(server.Handle("/users/context/"+nestedID, server.Alice), "\n")
+	assert.Regexp(fmt.Sprintf(`^=> /users/view/%s \S+ bob$`, id), lines[3])
+	assert.Regexp(`^\S+ · \[deleted\]$`, lines[4])
+	assert.Regexp(fmt.Sprintf(`^=> /users/view/%s \S+ ·· carol ┃ 📍$`, nestedID), lines[5])
+}
+
+func TestThread_DeletedFirstPost(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n`, say)
+
+	id := say[15 : len(say)-2]
+
+	reply := server.Handle(fmt.Sprintf("/users/reply/%s?Welcome%%20Bob", id), server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n`, reply)
+
+	replyID := reply[15 : len(reply)-2]
+
+	nested := server.Handle(fmt.Sprintf("/users/reply/%s?Hi%%20Bob", replyID), server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n`, nested)
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), server.Handle("/users/delete/"+id, server.Bob))
+
+	thread := server.Handle("/users/thread/"+replyID, server.Alice)
+	assert.Contains(strings.Split(thread, "\n"), fmt.Sprintf("=> /users/thread/%s View first post in thread", id))
+
+	_, err := server.db.Exec(`update tombstones set deleted = deleted - 3600`)
+	assert.NoError(err)
+
+	server.cfg.TombstonesTTL = time.Minute
+	assert.NoError((&data.GarbageCollector{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	var tombstones int
+	assert.NoError(server.db.QueryRow(`select count(*) from tombstones`).Scan(&tombstones))
+	assert.Equal(0, tombstones)
+
+	thread = server.Handle("/users/thread/"+replyID, server.Alice)
+	assert.NotContains(thread, "View first post in thread")
+}
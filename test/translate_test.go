@@ -0,0 +1,193 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/fed"
+	"github.com/dimkr/tootik/inbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func receiveRemotePost(t *testing.T, server *server) {
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(t, err)
+
+	create := `{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/create/1","type":"Create","actor":"https://127.0.0.1/user/dan","object":{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"hallo","contentMap":{"de":"hallo"},"to":["https://www.w3.org/ns/activitystreams#Public"]},"to":["https://www.w3.org/ns/activitystreams#Public"]}`
+
+	_, err = server.db.Exec(
+		`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+		"https://127.0.0.1/user/dan",
+		create,
+	)
+	assert.NoError(t, err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestTranslate_Disabled(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	receiveRemotePost(t, server)
+
+	view := server.Handle("/users/view/127.0.0.1/note/1", server.Alice)
+	assert.Contains(view, "hallo")
+	assert.NotContains(view, "🌐 Translate")
+
+	assert.Equal("40 Translation is disabled\r\n", server.Handle("/users/translate/127.0.0.1/note/1", server.Alice))
+}
+
+func TestTranslate_HappyFlow(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	requests := 0
+	libreTranslate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		var req map[string]string
+		if r.URL.Path != "/translate" || json.NewDecoder(r.Body).Decode(&req) != nil || req["api_key"] != "a" || req["source"] != "de" || req["target"] != "en" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"bad request"}`))
+			return
+		}
+
+		w.Write([]byte(`{"translatedText":"` + strings.ReplaceAll(req["q"], "hallo", "hello") + `"}`))
+	}))
+	defer libreTranslate.Close()
+
+	server.cfg.TranslationURL = libreTranslate.URL
+	server.cfg.TranslationKey = "a"
+
+	receiveRemotePost(t, server)
+
+	view := server.Handle("/users/view/127.0.0.1/note/1", server.Alice)
+	assert.Contains(view, "=> /users/translate/127.0.0.1/note/1 🌐 Translate")
+	assert.NotContains(view, "hello")
+
+	assert.Equal("30 /users/view/127.0.0.1/note/1\r\n", server.Handle("/users/translate/127.0.0.1/note/1", server.Alice))
+	assert.Equal(1, requests)
+
+	view = server.Handle("/users/view/127.0.0.1/note/1", server.Alice)
+	assert.Contains(view, "## 🌐 Translation\n\n> hello\n")
+
+	assert.Equal("30 /users/view/127.0.0.1/note/1\r\n", server.Handle("/users/translate/127.0.0.1/note/1", server.Bob))
+	assert.Equal(1, requests)
+
+	view = server.Handle("/users/view/127.0.0.1/note/1", server.Carol)
+	assert.Contains(view, "> hello")
+}
+
+func TestTranslate_LocalPost(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.TranslationURL = "http://localhost:1"
+
+	say := server.Handle("/users/say?hallo", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	view := server.Handle("/users/view/"+id, server.Bob)
+	assert.NotContains(view, "🌐 Translate")
+
+	assert.Equal("40 Post not found\r\n", server.Handle("/users/translate/"+id, server.Bob))
+}
+
+func TestTranslate_Edited(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	requests := 0
+	libreTranslate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		var req map[string]string
+		if json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(`{"translatedText":"` + strings.NewReplacer("hallo", "hello", "welt", "world").Replace(req["q"]) + `"}`))
+	}))
+	defer libreTranslate.Close()
+
+	server.cfg.TranslationURL = libreTranslate.URL
+
+	receiveRemotePost(t, server)
+
+	assert.Equal("30 /users/view/127.0.0.1/note/1\r\n", server.Handle("/users/translate/127.0.0.1/note/1", server.Alice))
+	assert.Equal(1, requests)
+
+	_, err := server.db.Exec(`update notes set object = json_set(object, '$.content', 'hallo welt'), updated = unixepoch() + 1 where id = 'https://127.0.0.1/note/1'`)
+	assert.NoError(err)
+
+	view := server.Handle("/users/view/127.0.0.1/note/1", server.Alice)
+	assert.NotContains(view, "🌐 Translation")
+
+	assert.Equal("30 /users/view/127.0.0.1/note/1\r\n", server.Handle("/users/translate/127.0.0.1/note/1", server.Alice))
+	assert.Equal(2, requests)
+
+	view = server.Handle("/users/view/127.0.0.1/note/1", server.Alice)
+	assert.Contains(view, "## 🌐 Translation\n\n> hello world\n")
+}
+
+func TestTranslate_NotVisible(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	server.cfg.TranslationURL = "http://localhost:1"
+
+	receiveRemotePost(t, server)
+
+	_, err := server.db.Exec(`update notes set public = 0, object = json_set(object, '$.to', json('["https://127.0.0.1/followers/dan"]')) where id = 'https://127.0.0.1/note/1'`)
+	assert.NoError(err)
+
+	assert.Equal("40 Post not found\r\n", server.Handle("/users/translate/127.0.0.1/note/1", server.Alice))
+}
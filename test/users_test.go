@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/dimkr/tootik/fed"
 	"github.com/dimkr/tootik/inbox"
@@ -209,6 +210,69 @@ func TestUsers_PublicPostShared(t *testing.T) {
 	assert.Contains(users, "Hello world")
 }
 
+func TestUsers_PublicPostSharedMultipleTimes(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public) values(?,?,?,?)`,
+		"https://127.0.0.1/note/1",
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"Hello world","to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["https://127.0.0.1/followers/dan"]}`,
+		1,
+	)
+	assert.NoError(err)
+
+	for _, sharer := range []string{"erin", "frank", "grace"} {
+		_, err = server.db.Exec(
+			`insert into persons (id, actor) values(?,?)`,
+			"https://127.0.0.1/user/"+sharer,
+			fmt.Sprintf(`{"id":"https://127.0.0.1/user/%s","type":"Person","preferredUsername":"%s","followers":"https://127.0.0.1/followers/%s"}`, sharer, sharer, sharer),
+		)
+		assert.NoError(err)
+
+		follow := server.Handle("/users/follow/127.0.0.1/user/"+sharer, server.Alice)
+		assert.Equal("30 /users/outbox/127.0.0.1/user/"+sharer+"\r\n", follow)
+
+		_, err = server.db.Exec(
+			`insert into inbox (sender, activity, raw) values($1, $2, $2)`,
+			"https://127.0.0.1/user/"+sharer,
+			fmt.Sprintf(`{"@context":["https://www.w3.org/ns/activitystreams"],"id":"https://127.0.0.1/announce/%s","type":"Announce","actor":"https://127.0.0.1/user/%s","object":"https://127.0.0.1/note/1","to":["https://www.w3.org/ns/activitystreams#Public"],"cc":["https://127.0.0.1/followers/%s"]}`, sharer, sharer, sharer),
+		)
+		assert.NoError(err)
+	}
+
+	_, err = server.db.Exec(`update follows set accepted = 1`)
+	assert.NoError(err)
+
+	queue := inbox.Queue{
+		Domain:    domain,
+		Config:    server.cfg,
+		BlockList: &fed.BlockList{},
+		DB:        server.db,
+		Resolver:  fed.NewResolver(nil, domain, server.cfg, &http.Client{}, server.db),
+		Key:       server.NobodyKey,
+	}
+	n, err := queue.ProcessBatch(context.Background())
+	assert.NoError(err)
+	assert.Equal(3, n)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	users := server.Handle("/users", server.Alice)
+	assert.Equal(1, strings.Count(users, "Hello world"))
+	assert.Regexp(`┃ 🔄 (erin|frank|grace) and 2 others you follow\n`, users)
+}
+
 func TestUsers_PublicPostSharedNotFollowing(t *testing.T) {
 	server := newTestServer()
 	defer server.Shutdown()
@@ -262,3 +326,116 @@ func TestUsers_PublicPostSharedNotFollowing(t *testing.T) {
 	users := server.Handle("/users", server.Alice)
 	assert.NotContains(users, "Hello world")
 }
+
+func TestUsers_FeedAlgorithms(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Alice))
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Carol.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Carol.ID, "https://"), server.Alice))
+
+	say := server.Handle("/users/say?old%20post%20%23tag", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	now := time.Now()
+
+	// Bob's post was published and received before Carol's post was received, but after it was published
+	_, err := server.db.Exec(`update notes set inserted = $1, object = json_set(object, '$.published', $2) where id = $3`, now.Add(-time.Minute*3).Unix(), now.Add(-time.Minute*3).UTC().Format(time.RFC3339), "https://"+say[15:len(say)-2])
+	assert.NoError(err)
+
+	say = server.Handle("/users/say?new%20post", server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	_, err = server.db.Exec(`update notes set inserted = $1, object = json_set(object, '$.published', $2) where id = $3`, now.Add(-time.Minute).Unix(), now.Add(-time.Minute*5).UTC().Format(time.RFC3339), "https://"+say[15:len(say)-2])
+	assert.NoError(err)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	users := server.Handle("/users", server.Alice)
+	assert.Less(strings.Index(users, "new post"), strings.Index(users, "old post"))
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/feed/chronological", server.Alice))
+
+	users = server.Handle("/users", server.Alice)
+	assert.Less(strings.Index(users, "old post"), strings.Index(users, "new post"))
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/feed/hashtags", server.Alice))
+
+	users = server.Handle("/users", server.Alice)
+	assert.Less(strings.Index(users, "new post"), strings.Index(users, "old post"))
+
+	_, err = server.db.Exec(`insert into followed_hashtags(follower, hashtag) values(?, ?)`, server.Alice.ID, "tag")
+	assert.NoError(err)
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/feed/hashtags", server.Alice))
+
+	users = server.Handle("/users", server.Alice)
+	assert.Less(strings.Index(users, "old post"), strings.Index(users, "new post"))
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/feed/default", server.Alice))
+
+	users = server.Handle("/users", server.Alice)
+	assert.Less(strings.Index(users, "new post"), strings.Index(users, "old post"))
+}
+
+func TestUsers_FeedChronologicalFuturePost(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Alice))
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Carol.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Carol.ID, "https://"), server.Alice))
+
+	assert.Equal("30 /users/settings\r\n", server.Handle("/users/feed/chronological", server.Alice))
+
+	say := server.Handle("/users/say?future%20post", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	now := time.Now()
+
+	// Bob's post claims to be published tomorrow
+	_, err := server.db.Exec(`update notes set inserted = $1, object = json_set(object, '$.published', $2) where id = $3`, now.Add(-time.Minute*3).Unix(), now.Add(time.Hour*24).UTC().Format(time.RFC3339), "https://"+say[15:len(say)-2])
+	assert.NoError(err)
+
+	say = server.Handle("/users/say?new%20post", server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	_, err = server.db.Exec(`update notes set inserted = $1, object = json_set(object, '$.published', $2) where id = $3`, now.Add(-time.Minute).Unix(), now.Add(-time.Minute).UTC().Format(time.RFC3339), "https://"+say[15:len(say)-2])
+	assert.NoError(err)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	users := server.Handle("/users", server.Alice)
+	assert.Less(strings.Index(users, "new post"), strings.Index(users, "future post"))
+}
+
+func TestUsers_SharedPostRank(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal(fmt.Sprintf("30 /users/outbox/%s\r\n", strings.TrimPrefix(server.Bob.ID, "https://")), server.Handle("/users/follow/"+strings.TrimPrefix(server.Bob.ID, "https://"), server.Alice))
+
+	say := server.Handle("/users/say?old%20post", server.Carol)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	_, err := server.db.Exec(`update notes set inserted = $1 where id = $2`, time.Now().Add(-time.Hour).Unix(), "https://"+id)
+	assert.NoError(err)
+
+	say = server.Handle("/users/say?new%20post", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.NoError((inbox.FeedUpdater{Domain: domain, Config: server.cfg, DB: server.db}).Run(context.Background()))
+
+	assert.Equal("30 /users/view/"+id+"\r\n", server.Handle("/users/share/"+id, server.Alice))
+
+	users := server.Handle("/users", server.Alice)
+	assert.Contains(users, "old post")
+	assert.Less(strings.Index(users, "old post"), strings.Index(users, "new post"))
+}
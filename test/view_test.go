@@ -43,6 +43,22 @@ func TestView_NoReplies(t *testing.T) {
 	assert.Contains(view, "Hello world")
 }
 
+func TestView_GeminiURL(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20world", server.Bob)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	id := say[15 : len(say)-2]
+
+	assert.Contains(server.Handle("/users/view/gemini://"+domain+"/view/"+id, server.Alice), "Hello world")
+	assert.Contains(server.Handle("/view/https://"+id, nil), "Hello world")
+	assert.Equal("40 Post not found\r\n", server.Handle("/users/view/gemini://127.0.0.1/view/"+id, server.Alice))
+}
+
 func TestView_OneReply(t *testing.T) {
 	server := newTestServer()
 	defer server.Shutdown()
@@ -903,3 +919,30 @@ func TestView_PostInGroupNotPublicAndGroupFollowedWithPrivateReply(t *testing.T)
 	assert.Contains(view, "hello @people")
 	assert.NotContains(view, "hello dan")
 }
+
+func TestView_RemotePostCounts(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', '{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"hello","to":["https://www.w3.org/ns/activitystreams#Public"],"likes":{"id":"https://127.0.0.1/note/1/likes","type":"Collection","totalItems":34},"shares":{"id":"https://127.0.0.1/note/1/shares","type":"Collection","totalItems":12}}', 1)`,
+	)
+	assert.NoError(err)
+
+	assert.Contains(strings.Split(server.Handle("/users/view/127.0.0.1/note/1", server.Bob), "\n"), "♻ 12 · ⭐ 34")
+	assert.Contains(strings.Split(server.Handle("/view/127.0.0.1/note/1", nil), "\n"), "♻ 12 · ⭐ 34")
+
+	say := server.Handle("/users/say?Hello%20world", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	assert.NotContains(server.Handle(say[3:len(say)-2], server.Bob), "⭐")
+}
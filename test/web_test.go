@@ -0,0 +1,107 @@
+/*
+Copyright 2025 Dima Krasner
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimkr/tootik/front/web"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *server) Web(path string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.Handle("GET /web/{path...}", &web.Handler{Domain: domain, Config: s.cfg, Handler: s.handler})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://"+domain+path, nil))
+	return w
+}
+
+func TestWeb_Post(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	say := server.Handle("/users/say?Hello%20%26%20goodbye", server.Alice)
+	assert.Regexp(`^30 /users/view/\S+\r\n$`, say)
+
+	view := server.Web("/web/view/" + say[15:len(say)-2])
+	assert.Equal(http.StatusOK, view.Code)
+	assert.Equal("text/html; charset=utf-8", view.Header().Get("Content-Type"))
+
+	body := view.Body.String()
+	assert.True(strings.HasPrefix(body, "<!DOCTYPE html>\n"))
+	assert.Contains(body, "<blockquote>Hello &amp; goodbye</blockquote>")
+	assert.Contains(body, `<a href="/web/outbox/`+strings.TrimPrefix(server.Alice.ID, "https://")+`">`)
+	assert.Contains(body, `<a href="gemini://`+domain+`/users">`)
+
+	local := server.Web("/web/local")
+	assert.Equal(http.StatusOK, local.Code)
+	assert.Contains(local.Body.String(), "Hello &amp; goodbye")
+
+	outbox := server.Web("/web/outbox/" + strings.TrimPrefix(server.Alice.ID, "https://"))
+	assert.Equal(http.StatusOK, outbox.Code)
+	assert.Contains(outbox.Body.String(), "<title>")
+	assert.Contains(outbox.Body.String(), "Hello &amp; goodbye")
+}
+
+func TestWeb_NotFound(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	assert.Equal(http.StatusNotFound, server.Web("/web/users").Code)
+	assert.Equal(http.StatusNotFound, server.Web("/web/view/"+domain+"/post/x").Code)
+
+	server.cfg.RequireRegistration = true
+	assert.Equal(http.StatusNotFound, server.Web("/web/local").Code)
+}
+
+func TestWeb_UnsafeLinks(t *testing.T) {
+	server := newTestServer()
+	defer server.Shutdown()
+
+	assert := assert.New(t)
+
+	_, err := server.db.Exec(
+		`insert into persons (id, actor) values(?,?)`,
+		"https://127.0.0.1/user/dan",
+		`{"id":"https://127.0.0.1/user/dan","type":"Person","preferredUsername":"dan","followers":"https://127.0.0.1/followers/dan"}`,
+	)
+	assert.NoError(err)
+
+	_, err = server.db.Exec(
+		`insert into notes (id, author, object, public) values('https://127.0.0.1/note/1', 'https://127.0.0.1/user/dan', ?, 1)`,
+		`{"id":"https://127.0.0.1/note/1","type":"Note","attributedTo":"https://127.0.0.1/user/dan","content":"<p><a href=\"javascript:alert(1)\">click</a> <a href=\"DATA:text/html,x\">here</a> <a href=\"https://example.com\">or here</a></p>","to":["https://www.w3.org/ns/activitystreams#Public"]}`,
+	)
+	assert.NoError(err)
+
+	view := server.Web("/web/view/127.0.0.1/note/1")
+	assert.Equal(http.StatusOK, view.Code)
+	assert.Equal("default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'", view.Header().Get("Content-Security-Policy"))
+
+	body := view.Body.String()
+	assert.NotContains(body, `href="javascript:`)
+	assert.NotContains(body, `href="DATA:`)
+	assert.Contains(body, `<a href="https://example.com">`)
+}